@@ -1,3 +1,6 @@
+// Package counts provides saturating counters and the formatting of
+// counts in human-readable form. See "Using git-sizer as a library"
+// in README.md for the compatibility guarantees.
 package counts
 
 import (
@@ -1,7 +1,9 @@
 package counts_test
 
 import (
+	"math"
 	"testing"
+	"testing/quick"
 
 	"github.com/github/git-sizer/counts"
 
@@ -51,3 +53,64 @@ func TestCount64(t *testing.T) {
 	assert.Equalf(uint64(0xffffffffffffffff), value, "Count64(0xffffffffffffffff).ToUint64() value")
 	assert.True(overflow, "NewCount64(0xffffffffffffffff).ToUint64() overflows")
 }
+
+func TestCount32Saturation(t *testing.T) {
+	f := func(a, b uint32) bool {
+		sum := uint64(a) + uint64(b)
+		if sum > math.MaxUint32 {
+			sum = math.MaxUint32
+		}
+		return uint64(counts.Count32(a).Plus(counts.Count32(b))) == sum
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+
+	g := func(n uint64) bool {
+		expected := n
+		if expected > math.MaxUint32 {
+			expected = math.MaxUint32
+		}
+		return uint64(counts.NewCount32(n)) == expected
+	}
+	if err := quick.Check(g, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCount64Saturation(t *testing.T) {
+	f := func(a, b uint64) bool {
+		sum := a + b
+		if sum < a {
+			sum = math.MaxUint64
+		}
+		return uint64(counts.Count64(a).Plus(counts.Count64(b))) == sum
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCountMaxProperties(t *testing.T) {
+	f := func(a, b uint32) bool {
+		n := counts.Count32(a)
+		adjusted := n.AdjustMaxIfNecessary(counts.Count32(b))
+		if uint32(n) != maxUint32(a, b) || adjusted != (b > a) {
+			return false
+		}
+
+		n = counts.Count32(a)
+		adjusted = n.AdjustMaxIfPossible(counts.Count32(b))
+		return uint32(n) == maxUint32(a, b) && adjusted == (b >= a)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func maxUint32(a, b uint32) uint32 {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,244 @@
+package counts
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// BucketScheme determines how the values that are added to a
+// `Histogram` are assigned to its buckets. In either scheme, bucket 0
+// holds only the value 0.
+type BucketScheme int
+
+const (
+	// Log2Buckets assigns the values in `[2^(i-1), 2^i - 1]` to
+	// bucket `i`, for `i` from 1 to 64.
+	Log2Buckets BucketScheme = iota
+
+	// DecadeBuckets assigns the values in `[10^(i-1), 10^i - 1]`
+	// to bucket `i`, for `i` from 1 to 20 (the last of which is
+	// capped at `math.MaxUint64`).
+	DecadeBuckets
+)
+
+// decades holds the powers of ten that fit in a `uint64`.
+var decades = func() []uint64 {
+	ds := []uint64{1}
+	for ds[len(ds)-1] <= math.MaxUint64/10 {
+		ds = append(ds, ds[len(ds)-1]*10)
+	}
+	return ds
+}()
+
+// String returns the name of `s` ("log2" or "decade").
+func (s BucketScheme) String() string {
+	switch s {
+	case Log2Buckets:
+		return "log2"
+	case DecadeBuckets:
+		return "decade"
+	default:
+		return fmt.Sprintf("BucketScheme(%d)", int(s))
+	}
+}
+
+// BucketCount returns the number of buckets in the scheme, including
+// bucket 0.
+func (s BucketScheme) BucketCount() int {
+	switch s {
+	case DecadeBuckets:
+		return len(decades) + 1
+	default:
+		return 65
+	}
+}
+
+// Bucket returns the index of the bucket that holds `value`.
+func (s BucketScheme) Bucket(value uint64) int {
+	switch s {
+	case DecadeBuckets:
+		i := 0
+		for i < len(decades) && value >= decades[i] {
+			i++
+		}
+		return i
+	default:
+		return bits.Len64(value)
+	}
+}
+
+// Bounds returns the smallest and the largest value that bucket `i`
+// holds.
+func (s BucketScheme) Bounds(i int) (uint64, uint64) {
+	if i <= 0 {
+		return 0, 0
+	}
+	switch s {
+	case DecadeBuckets:
+		if i >= len(decades) {
+			return decades[len(decades)-1], math.MaxUint64
+		}
+		return decades[i-1], decades[i] - 1
+	default:
+		if i >= 64 {
+			return 1 << 63, math.MaxUint64
+		}
+		return 1 << (i - 1), 1<<i - 1
+	}
+}
+
+// HistogramBucket is one (non-empty) bucket of a `Histogram`: the
+// number of values in `[Min, Max]` that were added, and their total.
+type HistogramBucket struct {
+	Min   uint64  `json:"min"`
+	Max   uint64  `json:"max"`
+	Count Count64 `json:"count"`
+	Total Count64 `json:"total"`
+}
+
+// Histogram counts values (e.g., object sizes) in buckets whose
+// bounds are determined by its `BucketScheme`, and totals the values
+// in each bucket. The counts and totals saturate like `Count64`.
+// The zero value is an empty histogram with `Log2Buckets`.
+type Histogram struct {
+	scheme BucketScheme
+	counts []Count64
+	totals []Count64
+}
+
+// NewHistogram returns an empty histogram that uses `scheme`.
+func NewHistogram(scheme BucketScheme) *Histogram {
+	return &Histogram{scheme: scheme}
+}
+
+// Scheme returns the bucket scheme of `h`.
+func (h *Histogram) Scheme() BucketScheme {
+	return h.scheme
+}
+
+// init allocates the buckets of `h`, if that hasn't happened yet.
+func (h *Histogram) init() {
+	if h.counts == nil {
+		n := h.scheme.BucketCount()
+		h.counts = make([]Count64, n)
+		h.totals = make([]Count64, n)
+	}
+}
+
+// Add adds `n` (e.g., a `Count32` or `Count64`) to the histogram.
+func (h *Histogram) Add(n Humanable) {
+	value, _ := n.ToUint64()
+	h.AddValue(value)
+}
+
+// AddValue adds `value` to the histogram.
+func (h *Histogram) AddValue(value uint64) {
+	h.init()
+	i := h.scheme.Bucket(value)
+	h.counts[i].Increment(1)
+	h.totals[i].Increment(Count64(value))
+}
+
+// Merge adds the values that were added to `other` to `h`. The
+// histograms must use the same bucket scheme.
+func (h *Histogram) Merge(other *Histogram) error {
+	if other.scheme != h.scheme {
+		return fmt.Errorf(
+			"can't merge a histogram with %s buckets into one with %s buckets",
+			other.scheme, h.scheme,
+		)
+	}
+	if other.counts == nil {
+		return nil
+	}
+	h.init()
+	for i := range other.counts {
+		h.counts[i].Increment(other.counts[i])
+		h.totals[i].Increment(other.totals[i])
+	}
+	return nil
+}
+
+// Count returns the number of values that were added.
+func (h *Histogram) Count() Count64 {
+	var n Count64
+	for _, c := range h.counts {
+		n.Increment(c)
+	}
+	return n
+}
+
+// Total returns the sum of the values that were added.
+func (h *Histogram) Total() Count64 {
+	var n Count64
+	for _, t := range h.totals {
+		n.Increment(t)
+	}
+	return n
+}
+
+// Buckets returns the non-empty buckets, smallest values first.
+func (h *Histogram) Buckets() []HistogramBucket {
+	buckets := []HistogramBucket{}
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		lo, hi := h.scheme.Bounds(i)
+		buckets = append(buckets, HistogramBucket{
+			Min:   lo,
+			Max:   hi,
+			Count: c,
+			Total: h.totals[i],
+		})
+	}
+	return buckets
+}
+
+// Quantile estimates the `q`-quantile (for `q` between 0 and 1) of
+// the values that were added; e.g., `Quantile(0.5)` estimates the
+// median. It finds the bucket that holds the quantile and interpolates
+// linearly between its bounds, so the estimate is always within the
+// right bucket. It returns 0 if no values were added.
+func (h *Histogram) Quantile(q float64) uint64 {
+	switch {
+	case q < 0 || math.IsNaN(q):
+		q = 0
+	case q > 1:
+		q = 1
+	}
+
+	count := h.Count()
+	if count == 0 {
+		return 0
+	}
+
+	rank := q * float64(count)
+	var seen float64
+	for i, c := range h.counts {
+		if c == 0 {
+			continue
+		}
+		if seen+float64(c) < rank {
+			seen += float64(c)
+			continue
+		}
+		lo, hi := h.scheme.Bounds(i)
+		fraction := (rank - seen) / float64(c)
+		estimate := float64(lo) + fraction*float64(hi-lo)
+		if estimate >= float64(hi) {
+			return hi
+		}
+		return uint64(estimate)
+	}
+
+	// This can only be reached if the counts saturated:
+	for i := len(h.counts) - 1; i >= 0; i-- {
+		if h.counts[i] != 0 {
+			_, hi := h.scheme.Bounds(i)
+			return hi
+		}
+	}
+	return 0
+}
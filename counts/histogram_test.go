@@ -0,0 +1,103 @@
+package counts_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/github/git-sizer/counts"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBucketSchemes(t *testing.T) {
+	for _, p := range []struct {
+		scheme   counts.BucketScheme
+		value    uint64
+		bucket   int
+		min, max uint64
+	}{
+		{counts.Log2Buckets, 0, 0, 0, 0},
+		{counts.Log2Buckets, 1, 1, 1, 1},
+		{counts.Log2Buckets, 2, 2, 2, 3},
+		{counts.Log2Buckets, 1000, 10, 512, 1023},
+		{counts.Log2Buckets, 1024, 11, 1024, 2047},
+		{counts.Log2Buckets, math.MaxUint64, 64, 1 << 63, math.MaxUint64},
+		{counts.DecadeBuckets, 0, 0, 0, 0},
+		{counts.DecadeBuckets, 9, 1, 1, 9},
+		{counts.DecadeBuckets, 10, 2, 10, 99},
+		{counts.DecadeBuckets, 123456, 6, 100000, 999999},
+		{counts.DecadeBuckets, math.MaxUint64, 20, 1e19, math.MaxUint64},
+	} {
+		bucket := p.scheme.Bucket(p.value)
+		assert.Equalf(t, p.bucket, bucket, "%s bucket of %d", p.scheme, p.value)
+		min, max := p.scheme.Bounds(bucket)
+		assert.Equalf(t, p.min, min, "%s bucket %d minimum", p.scheme, bucket)
+		assert.Equalf(t, p.max, max, "%s bucket %d maximum", p.scheme, bucket)
+		assert.Less(t, bucket, p.scheme.BucketCount())
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	var h counts.Histogram
+	assert.Equal(t, counts.Log2Buckets, h.Scheme())
+	assert.Equal(t, uint64(0), h.Quantile(0.5), "empty histogram")
+	assert.Empty(t, h.Buckets())
+
+	h.Add(counts.Count32(0))
+	h.Add(counts.Count32(5))
+	h.Add(counts.Count64(6))
+	h.AddValue(100)
+
+	assert.Equal(t, counts.Count64(4), h.Count())
+	assert.Equal(t, counts.Count64(111), h.Total())
+	assert.Equal(
+		t,
+		[]counts.HistogramBucket{
+			{Min: 0, Max: 0, Count: 1, Total: 0},
+			{Min: 4, Max: 7, Count: 2, Total: 11},
+			{Min: 64, Max: 127, Count: 1, Total: 100},
+		},
+		h.Buckets(),
+	)
+
+	// The estimates stay within the bucket that holds the quantile:
+	assert.Equal(t, uint64(0), h.Quantile(0))
+	assert.Equal(t, uint64(0), h.Quantile(0.25))
+	assert.Equal(t, uint64(5), h.Quantile(0.5))
+	assert.Equal(t, uint64(7), h.Quantile(0.75))
+	assert.Equal(t, uint64(127), h.Quantile(1))
+	assert.Equal(t, uint64(127), h.Quantile(2), "q is capped at 1")
+
+	other := counts.NewHistogram(counts.Log2Buckets)
+	other.AddValue(6)
+	require.NoError(t, h.Merge(other))
+	assert.Equal(t, counts.Count64(5), h.Count())
+	assert.Equal(t, counts.Count64(3), h.Buckets()[1].Count)
+
+	// Merging an empty histogram changes nothing:
+	require.NoError(t, h.Merge(counts.NewHistogram(counts.Log2Buckets)))
+	assert.Equal(t, counts.Count64(5), h.Count())
+
+	assert.Error(t, h.Merge(counts.NewHistogram(counts.DecadeBuckets)), "different schemes")
+}
+
+func TestHistogramDecades(t *testing.T) {
+	h := counts.NewHistogram(counts.DecadeBuckets)
+	for _, v := range []uint64{3, 30, 300, 3000, 3001} {
+		h.AddValue(v)
+	}
+	buckets := h.Buckets()
+	require.Len(t, buckets, 4)
+	assert.Equal(t, counts.HistogramBucket{Min: 1000, Max: 9999, Count: 2, Total: 6001}, buckets[3])
+	assert.Equal(t, uint64(99), h.Quantile(0.4))
+	assert.Equal(t, uint64(549), h.Quantile(0.5))
+}
+
+func TestHistogramSaturates(t *testing.T) {
+	h := counts.NewHistogram(counts.Log2Buckets)
+	h.Add(counts.Count64(math.MaxUint64))
+	h.Add(counts.Count64(math.MaxUint64))
+	assert.Equal(t, counts.Count64(math.MaxUint64), h.Total())
+	assert.Equal(t, uint64(math.MaxUint64), h.Quantile(1))
+}
@@ -52,7 +52,17 @@ var Binary = Humaner{
 	},
 }
 
-// Name returns the name of `h` ("metric" or "binary").
+// Plain is a Humaner that never uses a prefix. It is meant for
+// quantities like ages in days, for which "7.86 kd" would be more
+// confusing than "7860 d".
+var Plain = Humaner{
+	name: "plain",
+	prefixes: []Prefix{
+		{"", 1},
+	},
+}
+
+// Name returns the name of `h` ("metric", "binary", or "plain").
 func (h *Humaner) Name() string {
 	return h.name
 }
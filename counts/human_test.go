@@ -109,6 +109,25 @@ func TestBinary(t *testing.T) {
 	}
 }
 
+func TestPlain(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, ht := range []humanTest{
+		{0, "0", "d"},
+		{999, "999", "d"},
+		{7860, "7860", "d"},
+		{1000000, "1000000", "d"},
+	} {
+		number, unit := counts.Plain.FormatNumber(ht.n, "d")
+		assert.Equalf(ht.number, number, "Number for %d in plain", ht.n)
+		assert.Equalf(ht.unit, unit, "Unit for %d in plain", ht.n)
+	}
+
+	number, unit := counts.Plain.Format(counts.NewCount32(0xffffffff), "d")
+	assert.Equalf("∞", number, "Number for Count32(0xffffffff) in plain")
+	assert.Equalf("d", unit, "Unit for Count32(0xffffffff) in plain")
+}
+
 func TestLimits32(t *testing.T) {
 	assert := assert.New(t)
 
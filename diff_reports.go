@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/pflag"
+
+	"github.com/github/git-sizer/sizes"
+)
+
+// diffReportsCommand is the name of the subcommand that compares two
+// saved JSON reports.
+const diffReportsCommand = "diff-reports"
+
+const diffReportsUsage = `usage: git-sizer diff-reports [OPTS] BEFORE AFTER
+
+ Compare two JSON reports that were saved by earlier scans (using
+ '--json' or '--tee-json'), without needing the repository. The
+ reports must have the same JSON version, and may be gzipped.
+
+ If both reports hold refgroup totals (i.e., the scans used
+ '--save-baseline' or '--baseline'), the growth of each refgroup is
+ reported as for '--baseline'. Then the statistics that changed are
+ listed, with their values in both reports.
+
+      --json                   output the comparison in JSON format
+
+`
+
+// diffReports implements the `diff-reports` subcommand.
+func diffReports(stdout io.Writer, args []string) error {
+	var jsonOutput bool
+
+	flags := pflag.NewFlagSet("git-sizer diff-reports", pflag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(stdout, diffReportsUsage)
+	}
+	flags.BoolVarP(&jsonOutput, "json", "j", false, "output the comparison in JSON format")
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if flags.NArg() != 2 {
+		return errors.New("diff-reports takes exactly two reports")
+	}
+
+	before, err := sizes.ReadReport(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	after, err := sizes.ReadReport(flags.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	d, err := sizes.DiffReports(before, after)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		j, err := json.MarshalIndent(d, "", "    ")
+		if err != nil {
+			return fmt.Errorf("could not convert the comparison to JSON: %w", err)
+		}
+		fmt.Fprintf(stdout, "%s\n", j)
+		return nil
+	}
+
+	fmt.Fprint(stdout, d.String())
+	return nil
+}
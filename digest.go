@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+)
+
+// signatureNamespace is the namespace passed to `ssh-keygen -Y` when
+// signing reports. It has to be passed to `ssh-keygen -Y verify`,
+// too.
+const signatureNamespace = "git-sizer"
+
+// reportDigest is the tamper-evidence that `--digest` and
+// `--sign-key` add to the JSON output.
+type reportDigest struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"value"`
+	Namespace string `json:"namespace,omitempty"`
+	Signature string `json:"signature,omitempty"`
+}
+
+// canonicalJSON returns the canonical form of the JSON document `j`:
+// object keys sorted, no insignificant whitespace, no HTML escaping,
+// and numbers exactly as they appear in `j`.
+func canonicalJSON(j []byte) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(j))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// addReportDigest adds a digest of the JSON report `j` to it, under
+// `key`, and returns the result, indented by `indent`. The digest is
+// the SHA-256 of the canonical form of `j`. If `signKey` is set, the
+// canonical form is also signed using `ssh-keygen -Y sign` with that
+// private key.
+func addReportDigest(
+	ctx context.Context, j []byte, key, signKey, indent string,
+) ([]byte, error) {
+	canonical, err := canonicalJSON(j)
+	if err != nil {
+		return nil, fmt.Errorf("canonicalizing report: %w", err)
+	}
+
+	sum := sha256.Sum256(canonical)
+	digest := reportDigest{
+		Algorithm: "sha256",
+		Value:     hex.EncodeToString(sum[:]),
+	}
+
+	if signKey != "" {
+		signature, err := sshSign(ctx, signKey, canonical)
+		if err != nil {
+			return nil, err
+		}
+		digest.Namespace = signatureNamespace
+		digest.Signature = signature
+	}
+
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(j, &m); err != nil {
+		return nil, err
+	}
+	if _, ok := m[key]; ok {
+		return nil, fmt.Errorf("report already contains a %q field", key)
+	}
+	m[key], err = json.Marshal(digest)
+	if err != nil {
+		return nil, err
+	}
+
+	if indent == "" {
+		return json.Marshal(m)
+	}
+	return json.MarshalIndent(m, "", indent)
+}
+
+// sshSign signs `data` using `ssh-keygen -Y sign` with the private
+// key in the file `keyFile`, and returns the armored signature.
+func sshSign(ctx context.Context, keyFile string, data []byte) (string, error) {
+	cmd := exec.CommandContext(
+		ctx, "ssh-keygen", "-Y", "sign", "-f", keyFile, "-n", signatureNamespace,
+	)
+	cmd.Stdin = bytes.NewReader(data)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return "", fmt.Errorf(
+				"signing report with 'ssh-keygen': %w: %s", err, bytes.TrimSpace(stderr.Bytes()),
+			)
+		}
+		return "", fmt.Errorf("signing report with 'ssh-keygen': %w", err)
+	}
+	return string(out), nil
+}
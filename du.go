@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/internal/refopts"
+	"github.com/github/git-sizer/meter"
+	"github.com/github/git-sizer/sizes"
+)
+
+// duCommand is the name of the subcommand that shows the cumulative
+// historical size of a part of the repository's tree.
+const duCommand = "du"
+
+const duUsage = `usage: git-sizer du [OPTS] [PATH]
+
+ Show the cumulative historical size of PATH (a file or directory;
+ by default, the whole tree) and of each of its entries: the number
+ of unique blobs and trees that were ever found there, and the total
+ size of the blobs. Each object is counted at the first path at which
+ 'git rev-list --objects' finds it.
+
+      --deep                   show all of the files and directories
+                               beneath PATH, not just its entries
+      --json                   output the sizes in JSON format
+      --[no-]progress          report (don't report) progress to stderr.
+                               Default: report progress if stderr is a
+                               terminal.
+
+ The options for selecting references that 'git-sizer' accepts
+ (e.g., '--branches' or '--include=PREFIX') can also be used. By
+ default, all references are walked.
+
+`
+
+// du implements the `du` subcommand.
+func du(ctx context.Context, stdout, stderr io.Writer, args []string) error {
+	var deep bool
+	var jsonOutput bool
+	var progress bool
+
+	repo, repoErr := git.NewRepositoryFromPathWithOptions(ctx, ".", git.RepositoryOptions{})
+
+	flags := pflag.NewFlagSet("git-sizer du", pflag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(stdout, duUsage)
+	}
+	flags.BoolVar(&deep, "deep", false, "show everything beneath PATH")
+	flags.BoolVarP(&jsonOutput, "json", "j", false, "output the sizes in JSON format")
+	flags.BoolVar(&progress, "progress", isTerminal(stderr), "report progress to stderr")
+	flags.Var(&NegatedBoolValue{&progress}, "no-progress", "suppress progress output")
+	flags.Lookup("no-progress").NoOptDefVal = "true"
+
+	var configger refopts.Configger
+	if repo != nil {
+		configger = repo
+	}
+	rgb, err := refopts.NewRefGroupBuilder(ctx, configger)
+	if err != nil {
+		return err
+	}
+	rgb.AddRefopts(flags)
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if flags.NArg() > 1 {
+		return errors.New("du takes at most one path")
+	}
+	path := flags.Arg(0)
+
+	if repoErr != nil {
+		return fmt.Errorf("couldn't open Git repository: %w", repoErr)
+	}
+
+	rg, err := rgb.Finish(true)
+	if err != nil {
+		return err
+	}
+	refRoots, err := sizes.CollectReferences(ctx, repo, rg)
+	if err != nil {
+		return fmt.Errorf("determining which reference to scan: %w", err)
+	}
+	roots := make([]sizes.Root, 0, len(refRoots))
+	for _, refRoot := range refRoots {
+		roots = append(roots, refRoot)
+	}
+
+	var progressMeter meter.Progress = meter.NoProgressMeter
+	if progress {
+		progressMeter = meter.NewProgressMeter(stderr, 100*time.Millisecond)
+	}
+
+	// None of the statistics are needed, only the sizes of the
+	// blobs:
+	historySize, err := sizes.ScanRepositoryUsingGraph(
+		ctx, repo, roots, sizes.NameStyleNone, progressMeter,
+		sizes.ScanOptions{
+			Stats:          sizes.StatSet{},
+			DirectorySizes: true,
+		},
+	)
+	if err != nil {
+		return fmt.Errorf("error scanning repository: %w", err)
+	}
+
+	top, ok := historySize.DirectorySizes.Lookup(path)
+	if !ok {
+		return fmt.Errorf("nothing was ever found at %q", path)
+	}
+	entries := []sizes.DirectorySize{top}
+	var addChildren func(path string)
+	addChildren = func(path string) {
+		for _, child := range historySize.DirectorySizes.Children(path) {
+			entries = append(entries, child)
+			if deep {
+				addChildren(child.Path)
+			}
+		}
+	}
+	addChildren(top.Path)
+
+	if jsonOutput {
+		j, err := json.MarshalIndent(entries, "", "    ")
+		if err != nil {
+			return fmt.Errorf("could not convert the sizes to JSON: %w", err)
+		}
+		fmt.Fprintf(stdout, "%s\n", j)
+		return nil
+	}
+
+	for _, e := range entries {
+		sizeString, sizeUnit := counts.Binary.Format(e.BlobSize, "B")
+		countString, countUnit := counts.Metric.Format(e.ObjectCount, "")
+		name := e.Path
+		if name == "" {
+			name = "."
+		}
+		fmt.Fprintf(
+			stdout, "%5s %-3s  %5s %-1s  %s\n",
+			sizeString, sizeUnit, countString, countUnit, name,
+		)
+	}
+	return nil
+}
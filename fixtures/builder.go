@@ -0,0 +1,235 @@
+package fixtures
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/github/git-sizer/git"
+)
+
+// The modes of the kinds of entries that can appear in a tree.
+const (
+	ModeFile       = "100644"
+	ModeExecutable = "100755"
+	ModeSymlink    = "120000"
+	ModeTree       = "40000"
+	ModeSubmodule  = "160000"
+)
+
+// baseTimestamp is the author and committer timestamp of the first
+// commit written by a `Builder`.
+const baseTimestamp = 1112911993
+
+// Builder writes objects and references to a repository. Everything
+// that it writes is determined by its arguments, so the same calls
+// produce the same OIDs every time and with every version of Git.
+type Builder struct {
+	repo *git.Repository
+
+	// commits is the number of commits written so far. Each commit
+	// gets a timestamp one day later than the one before it.
+	commits int
+}
+
+// NewBuilder returns a `Builder` that writes to `repo`.
+func NewBuilder(repo *git.Repository) *Builder {
+	return &Builder{repo: repo}
+}
+
+// TreeEntry is an entry in a tree written by `Builder.Tree()`.
+type TreeEntry struct {
+	// Mode is one of the `Mode*` constants.
+	Mode string
+	Name string
+	OID  git.OID
+}
+
+// Blob writes a blob with the specified contents.
+func (b *Builder) Blob(ctx context.Context, contents string) (git.OID, error) {
+	return b.Object(ctx, "blob", func(w io.Writer) error {
+		_, err := io.WriteString(w, contents)
+		return err
+	})
+}
+
+// Tree writes a tree with the specified entries, which can be given
+// in any order.
+func (b *Builder) Tree(ctx context.Context, entries ...TreeEntry) (git.OID, error) {
+	entries = append([]TreeEntry(nil), entries...)
+
+	// Git sorts the entries of a tree by name, except that the names
+	// of subtrees are compared as if they ended with a slash:
+	sortName := func(e TreeEntry) string {
+		if e.Mode == ModeTree {
+			return e.Name + "/"
+		}
+		return e.Name
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return sortName(entries[i]) < sortName(entries[j])
+	})
+
+	return b.Object(ctx, "tree", func(w io.Writer) error {
+		for _, e := range entries {
+			if _, err := fmt.Fprintf(w, "%s %s\x00%s", e.Mode, e.Name, e.OID.Bytes()); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Commit writes a commit with the specified tree, message, and
+// parents.
+func (b *Builder) Commit(
+	ctx context.Context, tree git.OID, message string, parents ...git.OID,
+) (git.OID, error) {
+	timestamp := baseTimestamp + b.commits*24*60*60
+	b.commits++
+
+	return b.Object(ctx, "commit", func(w io.Writer) error {
+		if _, err := fmt.Fprintf(w, "tree %s\n", tree); err != nil {
+			return err
+		}
+		for _, parent := range parents {
+			if _, err := fmt.Fprintf(w, "parent %s\n", parent); err != nil {
+				return err
+			}
+		}
+		_, err := fmt.Fprintf(
+			w,
+			"author Example <example@example.com> %d -0700\n"+
+				"committer Example <example@example.com> %d -0700\n"+
+				"\n"+
+				"%s\n",
+			timestamp, timestamp, message,
+		)
+		return err
+	})
+}
+
+// Tag writes an annotated tag called `name` that points at `target`,
+// which is an object of type `targetType`.
+func (b *Builder) Tag(
+	ctx context.Context, name string, target git.OID, targetType git.ObjectType, message string,
+) (git.OID, error) {
+	return b.Object(ctx, "tag", func(w io.Writer) error {
+		_, err := fmt.Fprintf(
+			w,
+			"object %s\n"+
+				"type %s\n"+
+				"tag %s\n"+
+				"tagger Example <example@example.com> %d -0700\n"+
+				"\n"+
+				"%s\n",
+			target, targetType, name, baseTimestamp, message,
+		)
+		return err
+	})
+}
+
+// GitBomb writes a "git bomb" and returns the OID of the commit at
+// its top. The commit's tree has `breadth` entries, all referring to
+// the same subtree, which has `breadth` entries referring to the same
+// subtree, and so on, `depth` levels deep. At the bottom, a tree has
+// `breadth` entries referring to a blob with the contents `body`. So
+// the commit's checkout has `breadth^depth` files, even though it
+// consists of only `depth + 2` objects. Unlike the commits written by
+// `Commit()`, the commit always has the same timestamp.
+func (b *Builder) GitBomb(
+	ctx context.Context, depth, breadth int, body string,
+) (git.OID, error) {
+	oid, err := b.Blob(ctx, body)
+	if err != nil {
+		return git.NullOID, err
+	}
+
+	digits := len(fmt.Sprintf("%d", breadth-1))
+
+	mode := ModeFile
+	prefix := "f"
+
+	for ; depth > 0; depth-- {
+		child := oid
+		oid, err = b.Object(ctx, "tree", func(w io.Writer) error {
+			for i := 0; i < breadth; i++ {
+				_, err := fmt.Fprintf(
+					w, "%s %s%0*d\x00%s",
+					mode, prefix, digits, i, child.Bytes(),
+				)
+				if err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return git.NullOID, err
+		}
+
+		mode = ModeTree
+		prefix = "d"
+	}
+
+	return b.Object(ctx, "commit", func(w io.Writer) error {
+		_, err := fmt.Fprintf(
+			w,
+			"tree %s\n"+
+				"author Example <example@example.com> %d -0700\n"+
+				"committer Example <example@example.com> %d -0700\n"+
+				"\n"+
+				"Test git bomb\n",
+			oid, baseTimestamp, baseTimestamp,
+		)
+		return err
+	})
+}
+
+// Object writes a Git object of the specified type. `writer` is a
+// function that generates the object's contents in `git hash-object`
+// input format.
+func (b *Builder) Object(
+	ctx context.Context, otype git.ObjectType, writer func(io.Writer) error,
+) (git.OID, error) {
+	cmd := b.repo.GitCommandContext(
+		ctx, "hash-object", "-w", "-t", string(otype), "--stdin",
+	)
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return git.NullOID, err
+	}
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	if err := cmd.Start(); err != nil {
+		return git.NullOID, fmt.Errorf("running 'git hash-object': %w", err)
+	}
+
+	err = writer(in)
+	if err2 := in.Close(); err == nil {
+		err = err2
+	}
+	if err2 := cmd.Wait(); err2 != nil {
+		return git.NullOID, fmt.Errorf(
+			"writing %s object: %w: %s", otype, err2, bytes.TrimSpace(stderr.Bytes()),
+		)
+	}
+	if err != nil {
+		return git.NullOID, fmt.Errorf("writing %s object: %w", otype, err)
+	}
+
+	return git.NewOID(string(bytes.TrimSpace(out.Bytes())))
+}
+
+// SetRef points the reference `refname` at `oid`.
+func (b *Builder) SetRef(ctx context.Context, refname string, oid git.OID) error {
+	cmd := b.repo.GitCommandContext(ctx, "update-ref", refname, oid.String())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("setting reference '%s': %w: %s", refname, err, out)
+	}
+	return nil
+}
@@ -0,0 +1,229 @@
+// Package fixtures builds small synthetic Git repositories whose
+// objects, and therefore git-sizer's reports about them, are the same
+// every time and with every version of Git. git-sizer checks its
+// output for them against golden files; other programs that consume
+// that output can use them to test their own integrations.
+package fixtures
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/github/git-sizer/git"
+)
+
+// Fixture is a recipe for the contents of a synthetic repository.
+type Fixture struct {
+	// Name identifies the fixture (e.g., in the names of golden
+	// files).
+	Name string
+
+	// Description says what the fixture is meant to exercise.
+	Description string
+
+	build func(ctx context.Context, b *Builder) error
+}
+
+// Build writes the fixture's objects and references to `repo`, which
+// should be empty.
+func (f Fixture) Build(ctx context.Context, repo *git.Repository) error {
+	if err := f.build(ctx, NewBuilder(repo)); err != nil {
+		return fmt.Errorf("building fixture '%s': %w", f.Name, err)
+	}
+	return nil
+}
+
+// All returns all of the fixtures, in a fixed order.
+func All() []Fixture {
+	return []Fixture{
+		{
+			Name:        "empty",
+			Description: "a repository with no objects or references",
+			build:       func(context.Context, *Builder) error { return nil },
+		},
+		{
+			Name:        "linear",
+			Description: "a short linear history with a branch and an annotated tag",
+			build:       buildLinear,
+		},
+		{
+			Name:        "merge",
+			Description: "two branches and a merge, with an executable, a symlink, and a submodule",
+			build:       buildMerge,
+		},
+		{
+			Name:        "bomb",
+			Description: "a small git bomb",
+			build:       buildBomb,
+		},
+	}
+}
+
+// Lookup returns the fixture called `name`, if there is one.
+func Lookup(name string) (Fixture, bool) {
+	for _, f := range All() {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return Fixture{}, false
+}
+
+func buildLinear(ctx context.Context, b *Builder) error {
+	var parents []git.OID
+	for i := 1; i <= 3; i++ {
+		readme, err := b.Blob(ctx, strings.Repeat(fmt.Sprintf("Version %d\n", i), 10*i))
+		if err != nil {
+			return err
+		}
+		main, err := b.Blob(ctx, fmt.Sprintf("int main(void) { return %d; }\n", i))
+		if err != nil {
+			return err
+		}
+		src, err := b.Tree(ctx, TreeEntry{ModeFile, "main.c", main})
+		if err != nil {
+			return err
+		}
+		tree, err := b.Tree(
+			ctx,
+			TreeEntry{ModeFile, "README", readme},
+			TreeEntry{ModeTree, "src", src},
+		)
+		if err != nil {
+			return err
+		}
+		commit, err := b.Commit(ctx, tree, fmt.Sprintf("Version %d", i), parents...)
+		if err != nil {
+			return err
+		}
+		parents = []git.OID{commit}
+	}
+
+	tag, err := b.Tag(ctx, "v1.0", parents[0], "commit", "Release 1.0")
+	if err != nil {
+		return err
+	}
+
+	if err := b.SetRef(ctx, "refs/heads/main", parents[0]); err != nil {
+		return err
+	}
+	return b.SetRef(ctx, "refs/tags/v1.0", tag)
+}
+
+func buildMerge(ctx context.Context, b *Builder) error {
+	script, err := b.Blob(ctx, "#!/bin/sh\necho hello\n")
+	if err != nil {
+		return err
+	}
+	link, err := b.Blob(ctx, "run.sh")
+	if err != nil {
+		return err
+	}
+	base, err := b.Tree(
+		ctx,
+		TreeEntry{ModeExecutable, "run.sh", script},
+		TreeEntry{ModeSymlink, "start", link},
+	)
+	if err != nil {
+		return err
+	}
+	root, err := b.Commit(ctx, base, "Add a script")
+	if err != nil {
+		return err
+	}
+
+	// The submodule's commit doesn't have to exist in this repository:
+	submodule, err := git.NewOID(strings.Repeat("1234567890", 4))
+	if err != nil {
+		return err
+	}
+	gitmodules, err := b.Blob(
+		ctx, "[submodule \"lib\"]\n\tpath = lib\n\turl = https://example.com/lib.git\n",
+	)
+	if err != nil {
+		return err
+	}
+	topicTree, err := b.Tree(
+		ctx,
+		TreeEntry{ModeFile, ".gitmodules", gitmodules},
+		TreeEntry{ModeSubmodule, "lib", submodule},
+		TreeEntry{ModeExecutable, "run.sh", script},
+		TreeEntry{ModeSymlink, "start", link},
+	)
+	if err != nil {
+		return err
+	}
+	topic, err := b.Commit(ctx, topicTree, "Add a submodule", root)
+	if err != nil {
+		return err
+	}
+
+	notes, err := b.Blob(ctx, "Some notes.\n")
+	if err != nil {
+		return err
+	}
+	docs, err := b.Tree(ctx, TreeEntry{ModeFile, "notes.txt", notes})
+	if err != nil {
+		return err
+	}
+	mainTree, err := b.Tree(
+		ctx,
+		TreeEntry{ModeTree, "docs", docs},
+		TreeEntry{ModeExecutable, "run.sh", script},
+		TreeEntry{ModeSymlink, "start", link},
+	)
+	if err != nil {
+		return err
+	}
+	main, err := b.Commit(ctx, mainTree, "Add some notes", root)
+	if err != nil {
+		return err
+	}
+
+	mergedTree, err := b.Tree(
+		ctx,
+		TreeEntry{ModeFile, ".gitmodules", gitmodules},
+		TreeEntry{ModeTree, "docs", docs},
+		TreeEntry{ModeSubmodule, "lib", submodule},
+		TreeEntry{ModeExecutable, "run.sh", script},
+		TreeEntry{ModeSymlink, "start", link},
+	)
+	if err != nil {
+		return err
+	}
+	merge, err := b.Commit(ctx, mergedTree, "Merge branch 'topic'", main, topic)
+	if err != nil {
+		return err
+	}
+
+	// A tag of a tag:
+	tag, err := b.Tag(ctx, "v1", merge, "commit", "Version 1")
+	if err != nil {
+		return err
+	}
+	approved, err := b.Tag(ctx, "v1-approved", tag, "tag", "Approved")
+	if err != nil {
+		return err
+	}
+
+	for refname, oid := range map[string]git.OID{
+		"refs/heads/main":       merge,
+		"refs/heads/topic":      topic,
+		"refs/tags/v1":          tag,
+		"refs/tags/v1-approved": approved,
+	} {
+		if err := b.SetRef(ctx, refname, oid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func buildBomb(ctx context.Context, b *Builder) error {
+	oid, err := b.GitBomb(ctx, 5, 4, "boom!\n")
+	if err != nil {
+		return err
+	}
+	return b.SetRef(ctx, "refs/heads/bomb", oid)
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/spf13/pflag"
+
+	"github.com/github/git-sizer/fixtures"
+	"github.com/github/git-sizer/git"
+)
+
+// generateTestRepoCommand is the name of the hidden subcommand that
+// writes a git bomb to a repository.
+const generateTestRepoCommand = "generate-test-repo"
+
+const generateTestRepoUsage = `usage: git-sizer generate-test-repo [OPTS]
+
+ Write a "git bomb" to the Git repository in the current directory: a
+ commit whose checkout has BREADTH^DEPTH identical files, even though
+ it consists of only DEPTH+2 objects. The objects are the same every
+ time, so the result can be used to reproduce problems and to check
+ how servers and tools cope with pathological repositories.
+
+      --depth=N                nest the trees N levels deep. Default: 10
+      --breadth=M              give each tree M entries. Default: 10
+      --body=TEXT              use TEXT as the contents of the files.
+                               Default: 'boom!\n'
+      --ref=REFNAME            point REFNAME, which must not exist yet,
+                               at the commit. Default: 'refs/heads/git-bomb'
+
+`
+
+// generateTestRepo implements the `generate-test-repo` subcommand.
+func generateTestRepo(ctx context.Context, stdout io.Writer, args []string) error {
+	var depth, breadth int
+	var body, refname string
+
+	flags := pflag.NewFlagSet("git-sizer generate-test-repo", pflag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(stdout, generateTestRepoUsage)
+	}
+	flags.IntVar(&depth, "depth", 10, "how many levels deep to nest the trees")
+	flags.IntVar(&breadth, "breadth", 10, "how many entries to give each tree")
+	flags.StringVar(&body, "body", "boom!\n", "the contents of the files")
+	flags.StringVar(&refname, "ref", "refs/heads/git-bomb", "the reference to create")
+
+	if err := flags.Parse(args); err != nil {
+		if errors.Is(err, pflag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if flags.NArg() != 0 {
+		return errors.New("generate-test-repo doesn't take any arguments")
+	}
+	if depth < 1 || breadth < 1 {
+		return errors.New("the depth and breadth must be positive")
+	}
+
+	repo, err := git.NewRepositoryFromPathWithOptions(ctx, ".", git.RepositoryOptions{})
+	if err != nil {
+		return fmt.Errorf("couldn't open Git repository: %w", err)
+	}
+
+	oid, err := fixtures.NewBuilder(repo).GitBomb(ctx, depth, breadth, body)
+	if err != nil {
+		return err
+	}
+
+	// Passing the null OID as the old value makes `update-ref` refuse
+	// to overwrite an existing reference:
+	cmd := repo.GitCommandContext(ctx, "update-ref", refname, oid.String(), git.NullOID.String())
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("creating reference '%s': %w: %s", refname, err, out)
+	}
+
+	fmt.Fprintf(stdout, "%s %s\n", oid, refname)
+	return nil
+}
@@ -7,22 +7,29 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"runtime/pprof"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/pflag"
 
+	"github.com/github/git-sizer/counts"
 	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/internal/isatty"
+	"github.com/github/git-sizer/internal/objdump"
 	"github.com/github/git-sizer/internal/refopts"
-	"github.com/github/git-sizer/isatty"
+	"github.com/github/git-sizer/internal/tui"
 	"github.com/github/git-sizer/meter"
 	"github.com/github/git-sizer/sizes"
 )
 
 const usage = `usage: git-sizer [OPTS] [ROOT...]
+       git-sizer diff-reports [OPTS] BEFORE AFTER
+       git-sizer du [OPTS] [PATH]
 
  Scan objects in your Git repository and emit statistics about them.
+ Or, with 'diff-reports', compare two saved JSON reports (see
+ 'git-sizer diff-reports --help'), or, with 'du', show the cumulative
+ historical size of part of the tree (see 'git-sizer du --help').
 
       --threshold THRESHOLD    minimum level of concern (i.e., number of stars)
                                that should be reported. Default:
@@ -33,6 +40,53 @@ const usage = `usage: git-sizer [OPTS] [ROOT...]
       --no-verbose             equivalent to '--threshold=1'
       --critical               only report critical statistics; equivalent
                                to '--threshold=30'
+      --profile=[small|default|monorepo|forge]
+                               judge the statistics by reference values
+                               calibrated for the specified class of
+                               repository. E.g., 'monorepo' tolerates more
+                               trees and tree entries, but is stricter
+                               about large blobs. Default:
+                               '--profile=default'. Can be set via
+                               gitconfig: 'sizer.profile'.
+      --reference-value=SYMBOL=VALUE
+                               judge the statistic with the specified symbol
+                               (e.g., 'maxBlobSize') by the specified
+                               reference value (the value at which its level
+                               of concern is one star), overriding both its
+                               default and the profile. Can be repeated, and
+                               can be set via gitconfig (also multi-valued):
+                               'sizer.referenceValue', which the option
+                               overrides symbol by symbol
+      --doc-link=SYMBOL=URL    link the row of the statistic with the specified
+                               symbol (e.g., 'maxBlobSize') to the document
+                               at URL (e.g., a remediation runbook), via a
+                               footnote in the table and 'docLink' in the
+                               JSON output (version 2). Can be repeated, and
+                               can be set via gitconfig:
+                               'sizer.link.SYMBOL', which the option
+                               overrides symbol by symbol
+      --concern-weight=SYMBOL=FUNCTION[:WEIGHT]
+                               compute the level of concern of the statistic
+                               with the specified symbol by applying
+                               FUNCTION ('linear', the default, or 'log',
+                               which yields one more star each time the
+                               ratio of the value to the reference value,
+                               plus one, doubles) to that ratio and
+                               multiplying the result by WEIGHT (default:
+                               1). Can be repeated, and can be set via
+                               gitconfig: 'sizer.concernWeight.SYMBOL',
+                               which the option overrides symbol by symbol
+      --thresholds-file=FILE   judge the statistics by the policy in FILE, a
+                               YAML or JSON file with a 'version' (1) and
+                               optionally a 'name', a 'profile', a
+                               'threshold', a 'reject_threshold' (for push
+                               hooks), and 'reference_values' keyed by
+                               symbol. The file is validated, and its
+                               settings replace the gitconfig settings
+                               'sizer.profile', 'sizer.threshold', and
+                               'sizer.referenceValue'; command-line options
+                               still take precedence. Can be set via
+                               gitconfig: 'sizer.thresholdsFile'.
       --names=[none|hash|full] display names of large objects in the specified
                                style. Values:
                                * 'none' - omit footnotes entirely
@@ -40,13 +94,503 @@ const usage = `usage: git-sizer [OPTS] [ROOT...]
                                * 'full' - show full names
                                Default is '--names=full'. Can be set via
                                gitconfig: 'sizer.names'.
+      --names-budget=OBJECTS[,LENGTH]
+                               limit the work done to find the names of the
+                               objects in the footnotes: seek the paths of
+                               at most OBJECTS objects at a time, and follow
+                               paths of at most LENGTH objects. Objects
+                               that exceed the budget are named by their
+                               OIDs. 0 means no limit. Default: 0. Can be
+                               set via gitconfig: 'sizer.namesBudget'.
+      --top-objects=N          for each statistic that cites an object
+                               (e.g., 'Maximum size' of blobs), list the N
+                               objects with the biggest values, after the
+                               table (or as 'topObjects' in the JSON
+                               output). Default: 1 (only cite the biggest).
+                               Can be set via gitconfig: 'sizer.topObjects'.
+      --anomaly-examples=N     for each statistic that counts anomalies
+                               (e.g., 'Windows-unsafe paths'), include the
+                               N objects with the lowest OIDs among those
+                               counted in the JSON output (as
+                               'anomalyExamples'), or 0 to omit them.
+                               Default: 5. Can be set via gitconfig:
+                               'sizer.anomalyExamples'.
+      --notes-ref=REF,...      show the 'git notes' in the comma-separated
+                               notes refs that are attached to cited
+                               objects next to those objects (e.g., to
+                               record why a known-large object is
+                               acceptable). Notes refs that don't exist
+                               are ignored; '' disables this. Default:
+                               'refs/notes/size-exemptions'. Can be set via
+                               gitconfig: 'sizer.notesRef'.
+      --health-score           summarize the levels of concern as a single
+                               repository health score from 0 to 100, and
+                               list how much each section of the table
+                               contributes to it. Can be set via gitconfig:
+                               'sizer.healthScore'.
+      --anonymize              replace the components of paths and refnames
+                               in the output with opaque names (consistent
+                               within a run), so that the report can be
+                               shared publicly. Can be set via gitconfig:
+                               'sizer.anonymize'.
+      --color=[auto|always|never]
+                               color the level of concern of each row of the
+                               table by severity, and highlight the rows
+                               whose level of concern is off the scale.
+                               'auto' colors the table only if stdout is a
+                               terminal (which requires a build with isatty
+                               support) and 'NO_COLOR' isn't set. Default:
+                               '--color=auto'. Can be set via gitconfig:
+                               'sizer.color'.
   -j, --json                   output results in JSON format
       --json-version=[1|2]     choose which JSON format version to output.
                                Default: --json-version=1. Can be set via
                                gitconfig: 'sizer.jsonVersion'.
+      --json-indent=N          indent JSON output by N spaces per level.
+                               '--json-indent=0' is equivalent to
+                               '--json-compact'. Default: --json-indent=4.
+                               Can be set via gitconfig: 'sizer.jsonIndent'.
+      --json-compact           output JSON on a single line
+      --output-format=FORMAT   output results as a 'table' (the default),
+                               as 'json' (equivalent to '--json'), or as
+                               'proto', a protobuf 'ScanResult' message as
+                               defined in 'scanpb/scan_result.proto'
+      --tee-json=FILE          also write the report in JSON format to FILE,
+                               in addition to the usual output on stdout
+                               (e.g., the table). The JSON options above
+                               apply to FILE, too. FILE is compressed with
+                               gzip if its name ends in '.gz'.
+      --digest                 add the SHA-256 of the canonical form of the
+                               JSON report to the JSON output (requires
+                               '--json'). See README.md for how to verify it.
+      --sign-key=FILE          also sign the canonical form of the JSON
+                               report using 'ssh-keygen -Y sign' with the
+                               private key in FILE (implies '--digest')
+      --dump-objects=FILE      also write a record of each object that is
+                               scanned (its OID, type, size, and size on
+                               disk) to FILE, in the format chosen by
+                               '--dump-format', and compressed with gzip
+                               if FILE's name ends in '.gz'. Can't be
+                               combined with '--resume'
+      --remote=URL             scan the repository at URL instead of the one
+                               in the current directory, by making a
+                               temporary mirror clone of it, which is
+                               removed afterwards. Can't be combined with
+                               '--resume'
+      --remote-filter=SPEC     make the temporary clone of '--remote' a
+                               partial clone using 'git clone
+                               --filter=SPEC' (e.g., 'blob:none'), so that
+                               only the objects that the scan reads are
+                               downloaded (on demand)
+      --objects-from=FILE      scan the objects listed in FILE ('-' for
+                               stdin) instead of running 'git rev-list'
+                               to find the objects reachable from the
+                               references. Each line holds an object name,
+                               optionally followed by a space and a path,
+                               as in the output of 'git rev-list --objects
+                               --date-order'. The list must be complete
+                               (every object that a listed commit, tree, or
+                               tag refers to must be listed), and list
+                               commits before their parents. A gzipped
+                               FILE is decompressed automatically.
+      --index-reflog-names     also name objects after the index and reflog
+                               entries that refer to them (e.g., ':README'
+                               or 'HEAD@{3}'), for objects that no
+                               reference leads to. This is useful with
+                               '--objects-from' and a list generated by
+                               'git rev-list --objects --date-order --all
+                               --reflog --indexed-objects'. Can be set via
+                               gitconfig: 'sizer.indexReflogNames'.
+      --dump-format=FORMAT     the format of '--dump-objects': 'ndjson' (one
+                               JSON object per line), 'gob' (a stream of
+                               records encoded with Go's encoding/gob), or
+                               'parquet' (only in builds with '-tags
+                               parquet'). Default: 'ndjson'
       --[no-]progress          report (don't report) progress to stderr. Can
                                be set via gitconfig: 'sizer.progress'.
+      --progress-fd=N          also write progress records to file descriptor
+                               N, as JSON, one per line, with the phase, the
+                               count of items processed so far, the total
+                               (or -1 if it isn't known), the rates of items
+                               and bytes per second, and whether the phase
+                               is done
+      --progress-file=PATH     like '--progress-fd', but write the progress
+                               records to the file or named pipe PATH
+      --tui                    show the progress of the scan and the biggest
+                               objects found so far in a dashboard on the
+                               terminal, then show the results using Git's
+                               pager
+      --stats=STAT[,STAT...]   compute and report only the specified
+                               statistics, named as in the JSON output
+                               (e.g., '--stats=uniqueBlobSize,maxBlobSize').
+                               Data that aren't needed for them are not
+                               collected. The estimates of git-sizer's own
+                               memory usage (e.g., 'graphMemory'), the
+                               detected features of git ('gitCapabilities'),
+                               and the merge bubbles ('maxMergeBubble' and
+                               'averageMergeBubble'), which are expensive to
+                               measure, are only reported if requested this
+                               way. Can be set via gitconfig: 'sizer.stats'.
+      --sections=SECTION[,SECTION...]
+                               report only the specified sections of the
+                               main table ('overall', 'reference-tips',
+                               'biggest-objects', 'history-structure', and
+                               'biggest-checkouts'). Data that are only
+                               needed for the other sections are not
+                               collected. Can be set via gitconfig:
+                               'sizer.sections'.
+      --skip-sections=SECTION[,SECTION...]
+                               omit the specified sections of the main
+                               table. Can be set via gitconfig:
+                               'sizer.skipSections'.
+      --exact-counts           fail with an error, rather than reporting '∞',
+                               if any counter overflows
+      --max-expanded-entries=N
+                               stop expanding the checkout of any tree that
+                               has more than N entries (including
+                               duplicates), many times more than its
+                               distinct objects, and report it as a
+                               potential git bomb. Default: no limit. Can be
+                               set via gitconfig: 'sizer.maxExpandedEntries'.
+      --dump-checkout-manifest=FILE
+                               write the list of the files in the checkout
+                               with the largest total size of files, one per
+                               line as '<path> TAB <blob OID> TAB <size>', to
+                               FILE. FILE is compressed with gzip if its name
+                               ends in '.gz'.
+      --exact-checkout         list the files in the checkout with the most
+                               blobs, and report its number of distinct
+                               paths and distinct blobs next to the expanded
+                               counts, which are saturated if the expansion
+                               was limited. Can be set via gitconfig:
+                               'sizer.exactCheckout'.
+      --checkout-extensions    break down the checkout with the largest total
+                               size of files by the files' extensions
+                               (showing the top 20), to see whether it
+                               consists of, e.g., media assets or generated
+                               code. Can be set via gitconfig:
+                               'sizer.checkoutExtensions'.
+      --sharing-matrix=K       estimate how many bytes of unique objects are
+                               shared between each pair of the K refgroups
+                               with the most references, and how many are
+                               reachable from only one of them. Default: 0
+                               (don't compute). Can be set via gitconfig:
+                               'sizer.sharingMatrix'.
+      --shared-trees=N         list the N heaviest trees (with at least 1 MiB
+                               of blobs) that appear at several paths in the
+                               checkouts of the references, under different
+                               top-level directories or in different
+                               refgroups, like copies of vendored code.
+                               Default: 0 (don't list). Can be set via
+                               gitconfig: 'sizer.sharedTrees'.
+      --compressibility=N      estimate how well the N largest blobs
+                               compress, by compressing the first MiB of
+                               each with zlib. Default: 0 (don't estimate).
+                               Can be set via gitconfig:
+                               'sizer.compressibility'.
+      --line-ending-duplicates=N
+                               look for text files among the N largest
+                               blobs that only differ by their line
+                               endings (CRLF vs. LF), which indicates
+                               missing '.gitattributes' settings.
+                               Default: 0 (don't look). Can be set via
+                               gitconfig: 'sizer.lineEndingDuplicates'.
+      --normalized-duplicates=KIB
+                               count the text blobs of at most KIB KiB that
+                               only differ from others by whitespace at the
+                               ends of lines, empty lines at the end, or a
+                               byte order mark, and list the sets of such
+                               blobs with the most redundant data. Default:
+                               0 (don't count). Can be set via gitconfig:
+                               'sizer.normalizedDuplicates'.
+      --size-budget-report=P   list the smallest set of paths whose unique
+                               blobs account for P percent of the total
+                               size of the unique blobs, biggest first.
+                               Default: 0 (don't list). Can be set via
+                               gitconfig: 'sizer.sizeBudgetReport'.
+      --import-artifacts       count the files and commit trailers that an
+                               import from Subversion, CVS, or TFS probably
+                               left behind (e.g., '.svn/' directories or
+                               'git-svn-id' trailers), which suggest that
+                               the import needs a cleanup pass. Can be set
+                               via gitconfig: 'sizer.importArtifacts'.
+      --html-treemap=FILE      also write a self-contained HTML page to FILE
+                               that shows the cumulative historical size of
+                               the paths in the tree (as with 'git-sizer
+                               du') as a zoomable treemap
+      --lfs-cutoff=MIB         estimate how much smaller the object database
+                               would be if the files whose blobs are larger
+                               than MIB MiB were migrated to Git LFS
+                               throughout the history, and add it to the
+                               recommendations. Default: 0 (don't
+                               estimate). Can be set via gitconfig:
+                               'sizer.lfsCutoff'.
+      --hosting-limits=PRESET,...
+                               count the blobs that exceed the file size
+                               limits of these hosting services, which
+                               would cause a push to be rejected or to
+                               warn, and list the largest of them. The
+                               presets are 'github' (warns above 50 MiB,
+                               rejects above 100 MiB), 'github-web'
+                               (rejects above 25 MiB), and 'huggingface'
+                               (rejects above 10 MiB), or 'all'. Can be set
+                               via gitconfig: 'sizer.hostingLimits'.
+      --clone-bandwidth=MBPS   estimate how long a clone takes over a
+                               connection with a bandwidth of MBPS megabits
+                               per second, and report it under
+                               "Recommendations". Default: 0 (no estimate).
+                               Can be set via gitconfig:
+                               'sizer.cloneBandwidth'.
+      --clone-latency=MS       assume a connection with a latency of MS
+                               milliseconds when estimating how long a clone
+                               takes. Default: 50. Can be set via gitconfig:
+                               'sizer.cloneLatency'.
+      --recent-blobs=DAYS      for each refgroup, report the largest blob
+                               that was added by commits from the last
+                               DAYS days, e.g., to alert when a huge file
+                               is pushed. Default: 0 (don't report). Can be
+                               set via gitconfig: 'sizer.recentBlobs'.
+      --recent-commits=N       total the objects of each type that were
+                               introduced by the last N first-parent
+                               commits on the branch that HEAD points at,
+                               to show how the repository is used now.
+                               Default: 0 (don't report). Can be set via
+                               gitconfig: 'sizer.recentCommits'.
+      --refgroup-activity=PERIOD
+                               count the commits in each refgroup per
+                               PERIOD ('year' or 'month'), to show which
+                               refgroups are still active and which are
+                               dormant. Can be set via gitconfig:
+                               'sizer.refgroupActivity'.
+      --empty-commits-by-refgroup
+                               count the empty commits (those whose tree is
+                               identical to that of one of their parents)
+                               in each refgroup, e.g., to find references
+                               to which CI systems push "bump" commits. Can
+                               be set via gitconfig:
+                               'sizer.emptyCommitsByRefgroup'.
+      --age-buckets            group the unique objects by the year of the
+                               earliest commit that contains them, and
+                               report the number and size of the objects
+                               in each year. Can be set via gitconfig:
+                               'sizer.ageBuckets'.
+      --packfiles              list the packfiles in the object database, with
+                               their sizes, object counts, and auxiliary
+                               files, flag small packfiles that should be
+                               consolidated, and count the objects that
+                               take more than one pack access to read
+                               (e.g., deltas against the bases appended to
+                               thin packs). Can be set via gitconfig:
+                               'sizer.packfiles'.
+      --reflogs                measure the objects that are reachable only
+                               from reflogs (including the stash and the
+                               commits of deleted branches), and how much
+                               space expiring old reflog entries would
+                               reclaim. Can be set via gitconfig:
+                               'sizer.reflogs'.
+      --reflog-expire=DAYS     the age beyond which reflog entries are
+                               considered for expiry by '--reflogs'.
+                               Default: 30. Can be set via gitconfig:
+                               'sizer.reflogExpire'.
+      --force-pushes           measure the history that was replaced by force
+                               pushes, rebases, and other non-fast-forward
+                               updates of branches, and that is only kept
+                               until their reflog entries expire. Can be
+                               set via gitconfig: 'sizer.forcePushes'.
+      --ref-churn=DAYS         list the references that were updated most
+                               often in the last DAYS days, and count the
+                               updates to all references, going by the
+                               reflogs. References that are updated very
+                               often cause many small packs. Default: 0
+                               (don't report). Can be set via gitconfig:
+                               'sizer.refChurn'.
+      --redundant-refs         list the branches whose tips have the same
+                               tree as another branch's tip, or are strict
+                               ancestors of another branch's tip, and so
+                               are candidates for deletion. Can be set via
+                               gitconfig: 'sizer.redundantRefs'.
+      --refgroup-attribution   list, along with the name of each object
+                               cited by a statistic, the refgroups of the
+                               references from which it is reachable. This
+                               walks the history once per cited commit, so
+                               it can be slow in big repositories. Can be
+                               set via gitconfig:
+                               'sizer.refgroupAttribution'.
+      --unreachable            measure the objects that aren't reachable
+                               from any reference, reflog, or the index,
+                               bucketed by age, and how much space 'git gc'
+                               would reclaim under various settings of
+                               'gc.pruneExpire'. Can be set via gitconfig:
+                               'sizer.unreachable'.
+      --prune-expire=SETTING,...
+                               the settings of 'gc.pruneExpire' (e.g.,
+                               'now' or '2.weeks.ago') whose effects
+                               '--unreachable' simulates, in addition to
+                               the repository's current setting. Default:
+                               'now', '1.day.ago', '2.weeks.ago',
+                               '1.month.ago', '3.months.ago', and
+                               '1.year.ago'. Can be set via gitconfig:
+                               'sizer.pruneExpire'.
+      --top-committers=N       list the N committers whose commits are
+                               biggest in total, which can reveal automated
+                               processes that create many or big commits.
+                               Default: 0 (don't list). Can be set via
+                               gitconfig: 'sizer.topCommitters'.
+      --file-lineage=N         follow the histories of the files holding the
+                               N largest blobs across renames, and report
+                               the total size of all versions of each file.
+                               Requires '--names=full' and can't be combined
+                               with '--anonymize'. Default: 0 (don't
+                               follow). Can be set via gitconfig:
+                               'sizer.fileLineage'.
+      --bloom-filters          follow the histories of files for
+                               '--file-lineage' with path-limited history
+                               walks, which skip the commits whose
+                               changed-path Bloom filters (written by 'git
+                               commit-graph write --changed-paths') show
+                               that they didn't touch the file, rather than
+                               with 'git log --follow', which can't use the
+                               filters. Can be set via gitconfig:
+                               'sizer.bloomFilters'.
+      --write-commit-graph     after the scan, if the repository has no
+                               commit-graph, write one with 'git
+                               commit-graph write --reachable', and report
+                               how long a walk of the whole history took
+                               before and after
+      --allow-shallow          scan a shallow clone, rather than refusing to.
+                               The statistics then only cover the fetched
+                               part of the history; the commits where it
+                               is cut off are listed, and if the 'origin'
+                               remote is a local repository, the commits
+                               and objects beyond them are counted there.
+                               Can be set via gitconfig: 'sizer.allowShallow'.
+      --save-baseline=FILE     save the number and total size of the unique
+                               objects reachable from each refgroup to
+                               FILE, for use with '--baseline' in a later
+                               scan. FILE is compressed with gzip if its
+                               name ends in '.gz'.
+      --baseline=FILE          report how much each refgroup has grown since
+                               the scan that saved FILE using
+                               '--save-baseline', largest growth first. A
+                               gzipped FILE is decompressed automatically.
+      --save-state=FILE        save the names of the packfiles and loose
+                               objects in the object database to FILE, for
+                               use with '--since-state' in a later scan.
+                               FILE is compressed with gzip if its name
+                               ends in '.gz'.
+      --since-state=FILE       summarize the objects that were added to the
+                               object database since the scan that saved
+                               FILE using '--save-state', whether or not
+                               they are reachable. A gzipped FILE is
+                               decompressed automatically.
+      --stale-ref-age=DAYS     count references whose tips are older than
+                               DAYS days as stale. Default:
+                               '--stale-ref-age=365'. Can be set via
+                               gitconfig: 'sizer.staleRefAge'.
+      --batch-buffer-size=BYTES
+                               use buffers of BYTES bytes for the pipes to
+                               and from the git subprocesses. Default: 4096.
+                               Can be set via gitconfig:
+                               'sizer.batchBufferSize'.
+      --rev-list-window=N      allow up to N object requests and results to
+                               be in flight between git-sizer and the git
+                               subprocesses. Larger values can help on
+                               high-latency filesystems. Default: 0. Can be
+                               set via gitconfig: 'sizer.revListWindow'.
+      --commit-workers=N       register commits using N goroutines,
+                               processing commits of the same generation
+                               (none of which is an ancestor of another)
+                               concurrently, which can speed up the commit
+                               phase on wide histories. 0 means one per
+                               CPU. Default: 1. Can be set via gitconfig:
+                               'sizer.commitWorkers'.
+      --size-oracle=CMD        get the types and sizes of objects from the
+                               shell command CMD instead of from 'git
+                               cat-file --batch-check'. CMD reads object
+                               names, one per line, and writes '<oid>
+                               <type> <size> [<disk-size>]' or '<oid>
+                               missing' for each, in order. Can be set via
+                               gitconfig: 'sizer.sizeOracle'.
+      --ref-backend=[native|git]
+                               read references directly from the
+                               'packed-refs' file and loose reference files
+                               ('native'), which is faster in repositories
+                               with very many references, or via 'git
+                               for-each-ref' ('git'). 'native' falls back to
+                               'git' for repositories whose references it
+                               can't read (e.g., those using reftable).
+                               Default: '--ref-backend=git'. Can be set via
+                               gitconfig: 'sizer.refBackend'.
+      --nice=N                 run the git subprocesses with their
+                               scheduling priority lowered by N (using
+                               'nice'), so that they don't compete with
+                               more important work. Default: 0. Can be set
+                               via gitconfig: 'sizer.nice'.
+      --idle-io                run the git subprocesses in the 'idle' I/O
+                               scheduling class (using 'ionice'; Linux
+                               only). Can be set via gitconfig:
+                               'sizer.idleIO'.
+      --threads=N              use at most N threads in git-sizer and in
+                               each git subprocess (via 'pack.threads' and
+                               'index.threads'). Default: 0 (no limit). Can
+                               be set via gitconfig: 'sizer.threads'.
+      --resume                 save the intermediate results of the scan
+                               after each phase in the repository's
+                               'git-sizer-checkpoint' file, and resume from
+                               the last completed phase if an earlier scan
+                               with the same options was interrupted. The
+                               file is removed when the scan completes.
+      --max-duration=DURATION  give up with an error if the scan takes longer
+                               than DURATION (e.g., '10m' or '1h30m'). Any
+                               git subprocesses are killed. Default: no
+                               limit. Can be set via gitconfig:
+                               'sizer.maxDuration'.
+      --debug-pipelines        log each git command that git-sizer runs,
+                               including the stages of its pipelines (e.g.,
+                               'git rev-list' and 'git cat-file'), to
+                               stderr, along with the environment variables
+                               that git-sizer sets for it, so that it can
+                               be pasted into a shell to reproduce and time
+                               that step
       --version                only report the git-sizer version number
+      --explain-stats          only describe how each statistic is
+                               computed (e.g., whether it counts distinct
+                               objects or the expanded checkout, which
+                               objects it covers, and when its counter
+                               saturates), as text or, with '--json', as
+                               JSON. Honors '--stats', '--sections',
+                               '--profile', and '--reference-value'
+      --odb-totals             only total all of the objects in the object
+                               database (including unreachable ones and
+                               those in alternates) by type, as text or,
+                               with '--json', as JSON. This enumerates the
+                               objects in storage order rather than walking
+                               the history, so it is much faster than a
+                               full scan
+      --check-latest           only check whether a newer release of
+                               git-sizer is available. This queries the URL
+                               set by '--latest-release-url' (by default, the
+                               GitHub releases API), via any proxy set in
+                               the 'HTTPS_PROXY' environment variable
+      --latest-release-url=URL query URL for the latest release version; it
+                               should return JSON with a 'tag_name' field or
+                               a plain version number. Can be set via
+                               gitconfig: 'sizer.latestReleaseURL'.
+      --serve-stdio            run as a worker that reads scan requests from
+                               stdin and writes progress events and results
+                               to stdout, each as a JSON message preceded by
+                               its length (see README.md), until stdin is
+                               closed
+      --github-repo=OWNER/NAME also fetch the disk usage that GitHub reports
+                               for OWNER/NAME, and compare it with the sizes
+                               measured locally. For private repositories,
+                               set the 'GITHUB_TOKEN' (or 'GH_TOKEN')
+                               environment variable. Can be set via
+                               gitconfig: 'sizer.githubRepo'.
+      --github-api-url=URL     the URL of the GitHub API that
+                               '--github-repo' queries. Default:
+                               'https://api.github.com'. Can be set via
+                               gitconfig: 'sizer.githubAPIURL'.
 
  Object selection:
 
@@ -66,6 +610,19 @@ const usage = `usage: git-sizer [OPTS] [ROOT...]
  line but _no_ reference selection options, then _only_ the specified
  ROOTs are traversed, and no references.
 
+ When ROOTs are specified, the largest blob and the biggest checkout
+ reachable from each of them are also reported separately, after the
+ main table, so that several ROOTs can be compared in one run.
+
+      --[no-]head              also traverse [don't traverse] the object that
+                               HEAD resolves to, even if no reference
+                               selected for processing leads to it (e.g.,
+                               a detached HEAD), and report what HEAD
+                               points at after the scan scope. Unlike
+                               ROOTs, this doesn't stop references from
+                               being processed. Can be set via gitconfig:
+                               'sizer.head'.
+
  Reference selection:
 
  The following options can be used to limit which references to
@@ -89,7 +646,28 @@ const usage = `usage: git-sizer [OPTS] [ROOT...]
       --include @REFGROUP, --exclude @REFGROUP
                                process [don't process] references in the
                                specified reference group (see below)
-      --show-refs              show which refs are being included/excluded
+      --show-refs[=FORMAT]     show which refs are being included/excluded.
+                               FORMAT is 'text' (the default), which lists
+                               them on stderr, marking the included ones
+                               with '+', or 'json', which writes an array
+                               of {refname, included, groups} to stdout
+                               and exits without scanning
+      --show-refs-file=FILE    with '--show-refs=json', write the array to
+                               FILE (compressed with gzip if FILE ends in
+                               '.gz') and go on to scan the repository
+      --strict-attribution     compute the maxima only over objects that
+                               aren't reachable from any excluded reference
+                               (even if they are also reachable from an
+                               included reference or ROOT). Can be set via
+                               gitconfig: 'sizer.strictAttribution'.
+      --objects-since=DATE     count only the objects that were introduced by
+                               commits made on or after DATE (YYYY-MM-DD or
+                               an RFC 3339 timestamp); i.e., those that
+                               aren't reachable from any older commit. Can
+                               be set via gitconfig: 'sizer.objectsSince'.
+      --list-ignored-refs      include the names of references that were not
+                               walked (up to 100 of them) in the JSON output.
+                               Implied by '--show-refs'.
 
  PREFIX must match at a boundary; for example 'refs/foo' matches
  'refs/foo' and 'refs/foo/bar' but not 'refs/foobar'.
@@ -97,9 +675,12 @@ const usage = `usage: git-sizer [OPTS] [ROOT...]
  REGEXP patterns must match the full reference name.
 
  REFGROUP can be the name of a predefined reference group ('branches',
- 'tags', 'remotes', 'pulls', 'changes', 'notes', or 'stash'), or one
- defined via gitconfig settings like the following (the
- include/exclude settings can be repeated):
+ 'tags', 'remotes', 'pulls' (GitHub's and Gitea's pull requests),
+ 'changes' (Gerrit's changesets), 'merge-requests', 'keep-around', and
+ 'environments' (GitLab's merge requests, kept-around commits, and
+ deployments), 'notes', or 'stash'), or one defined via gitconfig
+ settings like the following (the include/exclude settings can be
+ repeated):
 
    * 'refgroup.REFGROUP.name=NAME'
    * 'refgroup.REFGROUP.include=PREFIX'
@@ -112,6 +693,11 @@ const usage = `usage: git-sizer [OPTS] [ROOT...]
 var ReleaseVersion string
 var BuildVersion string
 
+// maxListedIgnoredRefs is the maximum number of references that are
+// listed by name in the JSON output when `--list-ignored-refs` is
+// used.
+const maxListedIgnoredRefs = 100
+
 func main() {
 	ctx := context.Background()
 
@@ -123,75 +709,66 @@ func main() {
 }
 
 func mainImplementation(ctx context.Context, stdout, stderr io.Writer, args []string) error {
-	var nameStyle sizes.NameStyle = sizes.NameStyleFull
-	var cpuprofile string
-	var jsonOutput bool
-	var jsonVersion int
-	var threshold sizes.Threshold = 1
-	var progress bool
-	var version bool
-	var showRefs bool
+	if len(args) > 0 && args[0] == generateTestRepoCommand {
+		return generateTestRepo(ctx, stdout, args[1:])
+	}
+	if len(args) > 0 && args[0] == diffReportsCommand {
+		return diffReports(stdout, args[1:])
+	}
+	if len(args) > 0 && args[0] == duCommand {
+		return du(ctx, stdout, stderr, args[1:])
+	}
 
-	// Try to open the repository, but it's not an error yet if this
-	// fails, because the user might only be asking for `--help`.
-	repo, repoErr := git.NewRepositoryFromPath(".")
+	return runSizer(ctx, runEnv{dir: ".", stdin: os.Stdin}, stdout, stderr, args)
+}
 
-	flags := pflag.NewFlagSet("git-sizer", pflag.ContinueOnError)
-	flags.Usage = func() {
-		fmt.Fprint(stdout, usage)
-	}
+// runEnv holds the settings of a run of git-sizer that don't come
+// from its command line.
+type runEnv struct {
+	// dir is the directory in which to look for the repository.
+	dir string
 
-	flags.VarP(
-		sizes.NewThresholdFlagValue(&threshold, 0),
-		"verbose", "v", "report all statistics, whether concerning or not",
-	)
-	flags.Lookup("verbose").NoOptDefVal = "true"
+	// stdin is where `--objects-from=-` reads the object list from,
+	// and `--serve-stdio` its requests. If it is nil, neither can be
+	// used.
+	stdin io.Reader
 
-	flags.Var(
-		sizes.NewThresholdFlagValue(&threshold, 1),
-		"no-verbose", "report statistics that are at all concerning",
-	)
-	flags.Lookup("no-verbose").NoOptDefVal = "true"
+	// progress, if non-nil, is told about the progress of the scan
+	// instead of the progress meter selected by the options.
+	progress meter.Progress
+}
 
-	flags.Var(
-		&threshold, "threshold",
-		"minimum level of concern (i.e., number of stars) that should be\n"+
-			"                              reported",
-	)
+// runSizer runs git-sizer with the command-line arguments `args`.
+func runSizer(
+	ctx context.Context, env runEnv, stdout, stderr io.Writer, args []string,
+) error {
+	var o options
 
-	flags.Var(
-		sizes.NewThresholdFlagValue(&threshold, 30),
-		"critical", "only report critical statistics",
-	)
-	flags.Lookup("critical").NoOptDefVal = "true"
-
-	flags.Var(
-		&nameStyle, "names",
-		"display names of large objects in the specified `style`:\n"+
-			"        --names=none            omit footnotes entirely\n"+
-			"        --names=hash            show only the SHA-1s of objects\n"+
-			"        --names=full            show full names",
+	// Try to open the repository, but it's not an error yet if this
+	// fails, because the user might only be asking for `--help`.
+	// Whether a shallow clone is acceptable isn't known until the
+	// options have been parsed, so that is checked below.
+	repo, repoErr := git.NewRepositoryFromPathWithOptions(
+		ctx, env.dir, git.RepositoryOptions{AllowShallow: true},
 	)
 
-	flags.BoolVarP(&jsonOutput, "json", "j", false, "output results in JSON format")
-	flags.IntVar(&jsonVersion, "json-version", 1, "JSON format version to output (1 or 2)")
-
-	defaultProgress := false
-	if f, ok := stderr.(*os.File); ok {
-		atty, err := isatty.Isatty(f.Fd())
-		if err == nil && atty {
-			defaultProgress = true
-		}
+	flags := pflag.NewFlagSet("git-sizer", pflag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprint(stdout, usage)
 	}
 
-	flags.BoolVar(&progress, "progress", defaultProgress, "report progress to stderr")
-	flags.BoolVar(&version, "version", false, "report the git-sizer version number")
-	flags.Var(&NegatedBoolValue{&progress}, "no-progress", "suppress progress output")
-	flags.Lookup("no-progress").NoOptDefVal = "true"
+	stderrIsTerminal := isTerminal(stderr)
 
-	flags.StringVar(&cpuprofile, "cpuprofile", "", "write cpu profile to file")
-	if err := flags.MarkHidden("cpuprofile"); err != nil {
-		return fmt.Errorf("marking option hidden: %w", err)
+	o.addJudgingFlags(flags)
+	o.addOutputFlags(flags, stderrIsTerminal)
+	o.addModeFlags(flags)
+	o.addReportFlags(flags)
+	o.addTreeAnalysisFlags(flags)
+	o.addBlobAnalysisFlags(flags)
+	o.addHistoryAnalysisFlags(flags)
+	o.addComparisonFlags(flags)
+	if err := o.addTuningFlags(flags); err != nil {
+		return err
 	}
 
 	var configger refopts.Configger
@@ -199,14 +776,13 @@ func mainImplementation(ctx context.Context, stdout, stderr io.Writer, args []st
 		configger = repo
 	}
 
-	rgb, err := refopts.NewRefGroupBuilder(configger)
+	rgb, err := refopts.NewRefGroupBuilder(ctx, configger)
 	if err != nil {
 		return err
 	}
 
 	rgb.AddRefopts(flags)
-
-	flags.BoolVar(&showRefs, "show-refs", false, "list the references being processed")
+	o.addRefFlags(flags)
 
 	flags.SortFlags = false
 
@@ -218,18 +794,7 @@ func mainImplementation(ctx context.Context, stdout, stderr io.Writer, args []st
 		return err
 	}
 
-	if cpuprofile != "" {
-		f, err := os.Create(cpuprofile)
-		if err != nil {
-			return fmt.Errorf("couldn't set up cpuprofile file: %w", err)
-		}
-		if err := pprof.StartCPUProfile(f); err != nil {
-			return fmt.Errorf("starting CPU profiling: %w", err)
-		}
-		defer pprof.StopCPUProfile()
-	}
-
-	if version {
+	if o.version {
 		if ReleaseVersion != "" {
 			fmt.Fprintf(stdout, "git-sizer release %s\n", ReleaseVersion)
 		} else {
@@ -238,121 +803,750 @@ func mainImplementation(ctx context.Context, stdout, stderr io.Writer, args []st
 		return nil
 	}
 
+	if o.checkLatestRelease {
+		// This doesn't need a repository, but if we're in one, its
+		// configuration can override the URL:
+		if repoErr == nil {
+			r := configReader{ctx: ctx, repo: repo, flags: flags}
+			if err := r.stringVar(&o.latestReleaseURL, "latest-release-url", "sizer.latestReleaseURL"); err != nil {
+				return err
+			}
+		}
+
+		localVersion := ReleaseVersion
+		if localVersion == "" {
+			localVersion = BuildVersion
+		}
+		return checkLatest(ctx, stdout, o.latestReleaseURL, localVersion)
+	}
+
+	if o.serveStdioMode {
+		if env.stdin == nil {
+			return errors.New("--serve-stdio can't be used in a request")
+		}
+		return serveStdio(ctx, env.stdin, stdout)
+	}
+
+	if o.remoteURL != "" {
+		dir, err := os.MkdirTemp("", "git-sizer-remote-")
+		if err != nil {
+			return fmt.Errorf("creating directory for the clone: %w", err)
+		}
+		defer os.RemoveAll(dir)
+
+		repo, repoErr = cloneRemote(ctx, env, stderr, &o, dir)
+	} else if o.remoteFilter != "" {
+		return errors.New("'--remote-filter' requires '--remote'")
+	}
+
 	if repoErr != nil {
 		return fmt.Errorf("couldn't open Git repository: %w", repoErr)
 	}
 
-	if jsonOutput {
-		if !flags.Changed("json-version") {
-			v, err := repo.ConfigIntDefault("sizer.jsonVersion", jsonVersion)
-			if err != nil {
-				return err
-			}
-			jsonVersion = v
-			if !(jsonVersion == 1 || jsonVersion == 2) {
-				return fmt.Errorf("JSON version (read from gitconfig) must be 1 or 2")
-			}
-		} else if !(jsonVersion == 1 || jsonVersion == 2) {
-			return fmt.Errorf("JSON version must be 1 or 2")
+	if o.debugPipelines {
+		repo.SetDebugLog(stderr)
+	}
+
+	r := configReader{ctx: ctx, repo: repo, flags: flags}
+
+	if err := o.readComparisonConfig(r); err != nil {
+		return err
+	}
+
+	// Query GitHub before scanning, so that a mistake (e.g., a
+	// missing token) is reported right away:
+	var githubSize counts.Count64
+	if o.githubRepo != "" {
+		githubSize, err = fetchGitHubSize(ctx, o.githubAPIURL, o.githubRepo)
+		if err != nil {
+			return fmt.Errorf("fetching the size reported by GitHub: %w", err)
+		}
+	}
+
+	if err := o.readShallowConfig(r); err != nil {
+		return err
+	}
+
+	if err := repo.CheckObjectFormat(ctx); err != nil {
+		return fmt.Errorf("couldn't open Git repository: %w", err)
+	}
+
+	for _, read := range []func(configReader) error{
+		o.readOutputConfig,
+		o.readJudgingConfig,
+		o.readReportConfig,
+		o.readTreeAnalysisConfig,
+		o.readBlobAnalysisConfig,
+	} {
+		if err := read(r); err != nil {
+			return err
 		}
 	}
+	if err := o.readHistoryAnalysisConfig(r, stderr); err != nil {
+		return err
+	}
+	if err := o.readTuningConfig(r); err != nil {
+		return err
+	}
+
+	o.color, err = useColor(o.colorMode, stdout)
+	if err != nil {
+		return err
+	}
+
+	if o.maxDuration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, o.maxDuration)
+		defer cancel()
+	}
+
+	o.stats, err = sizes.ParseStatSet(o.statsList)
+	if err != nil {
+		return err
+	}
+
+	// The "Scan scope" section is only worth reading if the scan
+	// didn't simply walk all of the references:
+	o.showScope = o.threshold == 0 || rgb.Filtered() || len(flags.Args()) > 0 || o.head
 
-	if !flags.Changed("threshold") &&
-		!flags.Changed("verbose") &&
-		!flags.Changed("no-verbose") &&
-		!flags.Changed("critical") {
-		s, err := repo.ConfigStringDefault("sizer.threshold", fmt.Sprintf("%g", threshold))
+	rg, err := rgb.Finish(len(flags.Args()) == 0)
+	if err != nil {
+		return checkDeadline(ctx, o.maxDuration, err)
+	}
+
+	if o.sectionsList != "" || o.skipSectionsList != "" {
+		sectionStats, err := selectSections(o.sectionsList, o.skipSectionsList, rg.Groups())
 		if err != nil {
 			return err
 		}
-		v, err := strconv.ParseFloat(s, 64)
+		o.stats = o.stats.Intersect(sectionStats)
+	}
+
+	if o.checkoutManifest != "" && !o.stats.Contains("maxCheckoutBlobSize") {
+		return errors.New("'--dump-checkout-manifest' requires the 'maxCheckoutBlobSize' statistic")
+	}
+
+	if o.protoOutput && (o.explainStats || o.odbTotals) {
+		return errors.New("'--output-format=proto' is only supported for the report")
+	}
+
+	if o.explainStats {
+		defs := sizes.DefinitionsWithReferenceValues(rg.Groups(), o.profile, o.referenceValues, o.stats)
+		if !o.jsonOutput {
+			_, err := io.WriteString(stdout, sizes.DefinitionsString(defs))
+			return err
+		}
+		j, err := marshalJSON(map[string][]sizes.StatDefinition{"definitions": defs}, o.jsonIndent)
 		if err != nil {
-			return fmt.Errorf("parsing gitconfig value for 'sizer.threshold': %w", err)
+			return fmt.Errorf("could not convert definitions to JSON: %w", err)
 		}
-		threshold = sizes.Threshold(v)
+		_, err = fmt.Fprintf(stdout, "%s\n", j)
+		return err
 	}
 
-	if !flags.Changed("names") {
-		s, err := repo.ConfigStringDefault("sizer.names", "full")
+	if o.odbTotals {
+		totals, err := sizes.MeasureODBTotals(ctx, repo)
 		if err != nil {
+			return fmt.Errorf("error totaling objects: %w", err)
+		}
+		if !o.jsonOutput {
+			_, err := io.WriteString(stdout, totals.String())
 			return err
 		}
-		err = nameStyle.Set(s)
+		j, err := marshalJSON(totals, o.jsonIndent)
 		if err != nil {
-			return fmt.Errorf("parsing gitconfig value for 'sizer.names': %w", err)
+			return fmt.Errorf("could not convert object totals to JSON: %w", err)
 		}
+		_, err = fmt.Fprintf(stdout, "%s\n", j)
+		return err
 	}
 
-	if !flags.Changed("progress") && !flags.Changed("no-progress") {
-		v, err := repo.ConfigBoolDefault("sizer.progress", progress)
+	if o.showRefsFile != "" && o.showRefs != "json" {
+		return errors.New("'--show-refs-file' requires '--show-refs=json'")
+	}
+	switch o.showRefs {
+	case "":
+	case "text":
+		fmt.Fprintf(stderr, "References (included references marked with '+'):\n")
+		rg = refopts.NewShowRefGrouper(rg, stderr)
+	case "json":
+		refRoots, err := sizes.CollectReferences(ctx, repo, rg)
 		if err != nil {
-			return fmt.Errorf("parsing gitconfig value for 'sizer.progress': %w", err)
+			return fmt.Errorf("determining which reference to scan: %w", err)
 		}
-		progress = v
+		j, err := marshalJSON(refopts.ShowRefs(refRoots), o.jsonIndent)
+		if err != nil {
+			return fmt.Errorf("could not convert references to JSON: %w", err)
+		}
+		if o.showRefsFile == "" {
+			_, err = fmt.Fprintf(stdout, "%s\n", j)
+			return err
+		}
+		if err := sizes.WriteCompressible(o.showRefsFile, append(j, '\n')); err != nil {
+			return fmt.Errorf("writing reference listing: %w", err)
+		}
+	default:
+		return fmt.Errorf("invalid --show-refs format %q; use 'text' or 'json'", o.showRefs)
 	}
 
-	rg, err := rgb.Finish(len(flags.Args()) == 0)
+	var progressMeter meter.Progress = meter.NoProgressMeter
+	var live *sizes.LiveStats
+	var dashboard *tui.Dashboard
+	switch {
+	case env.progress != nil:
+		progressMeter = env.progress
+	case o.tuiMode:
+		if !stderrIsTerminal {
+			return errors.New("--tui requires stderr to be a terminal")
+		}
+		live = &sizes.LiveStats{}
+		dashboard = tui.New(
+			stderr, fmt.Sprintf("git-sizer: scanning %s", repo.GitDir()), live,
+		)
+		progressMeter = dashboard.Progress()
+	case o.progress:
+		progressMeter = meter.NewProgressMeter(stderr, 100*time.Millisecond)
+	}
+
+	progressRecords, closeProgressRecords, err := openProgressRecords(o.progressFD, o.progressFile)
 	if err != nil {
 		return err
 	}
+	defer closeProgressRecords()
+	if progressRecords != nil {
+		progressMeter = meter.Tee(progressMeter, newProgressRecorder(progressRecords))
+	}
 
-	if showRefs {
-		fmt.Fprintf(stderr, "References (included references marked with '+'):\n")
-		rg = refopts.NewShowRefGrouper(rg, stderr)
+	// Profile only the scan itself, not the setup or the output:
+	if err := o.prof.start(); err != nil {
+		return err
 	}
+	defer func() {
+		_ = o.prof.stop()
+	}()
 
-	var progressMeter meter.Progress = meter.NoProgressMeter
-	if progress {
-		progressMeter = meter.NewProgressMeter(stderr, 100*time.Millisecond)
+	var checkpoint *sizes.Checkpoint
+	if o.resume {
+		path, err := repo.GitPath("git-sizer-checkpoint")
+		if err != nil {
+			return err
+		}
+		var discarded bool
+		checkpoint, discarded, err = sizes.OpenCheckpoint(
+			path, checkpointKey(args, o.statsList, o.sectionsList, o.skipSectionsList),
+		)
+		if err != nil {
+			return err
+		}
+		if discarded {
+			fmt.Fprintf(
+				stderr,
+				"warning: discarding checkpoint %s from a scan with different options\n",
+				path,
+			)
+		}
+	}
+
+	var headInfo *git.Head
+	if o.head {
+		h, err := repo.ResolveHead(ctx)
+		if err != nil {
+			return err
+		}
+		headInfo = &h
+	}
+
+	roots, err := scanRoots(ctx, repo, rg, flags.Args(), headInfo, checkpoint)
+	if err != nil {
+		return checkDeadline(ctx, o.maxDuration, err)
+	}
+
+	scanOpts, err := o.scanOptions(len(flags.Args()) != 0)
+	if err != nil {
+		return err
+	}
+	scanOpts.Head = headInfo
+	scanOpts.Live = live
+	scanOpts.Checkpoint = checkpoint
+	scanOpts.CustomStats, err = sizes.ReadCustomStats(ctx, repo)
+	if err != nil {
+		return checkDeadline(ctx, o.maxDuration, err)
+	}
+
+	// Record the state before scanning, so that objects that are
+	// added during the scan are reported by the next one:
+	var odbState *sizes.ODBState
+	if o.saveStatePath != "" {
+		odbState, err = sizes.CurrentODBState(ctx, repo)
+		if err != nil {
+			return err
+		}
+	}
+
+	if o.objectsFrom == "-" {
+		if env.stdin == nil {
+			return errors.New("--objects-from=- can't be used with --serve-stdio")
+		}
+		scanOpts.ObjectList = env.stdin
+	} else if o.objectsFrom != "" {
+		f, err := sizes.OpenDecompressing(o.objectsFrom)
+		if err != nil {
+			return fmt.Errorf("opening object list: %w", err)
+		}
+		defer f.Close()
+		scanOpts.ObjectList = f
+	}
+
+	var dumpFile io.WriteCloser
+	var dumper objdump.Writer
+	if o.dumpObjects != "" {
+		dumpFile, err = sizes.CreateCompressible(o.dumpObjects)
+		if err != nil {
+			return fmt.Errorf("creating object dump: %w", err)
+		}
+		defer dumpFile.Close()
+		dumper, err = objdump.New(o.dumpFormat, dumpFile)
+		if err != nil {
+			return err
+		}
+		scanOpts.ObjectDumper = dumper
+	}
+
+	if dashboard != nil {
+		dashboard.Start(200 * time.Millisecond)
+	}
+	historySize, err := sizes.ScanRepositoryUsingGraph(
+		ctx, repo, roots, o.nameStyle, progressMeter, scanOpts,
+	)
+	if dashboard != nil {
+		dashboard.Stop()
+	}
+	if errors.Is(err, sizes.ErrOverflow) {
+		return fmt.Errorf("the exact counts cannot be reported: %w", err)
+	}
+	if err != nil {
+		return checkDeadline(ctx, o.maxDuration, fmt.Errorf("error scanning repository: %w", err))
+	}
+
+	if err := checkpoint.Remove(); err != nil {
+		return err
+	}
+
+	if o.githubRepo != "" {
+		historySize.SetHostedSize(sizes.HostedSize{
+			Host:       "GitHub",
+			Repository: o.githubRepo,
+			DiskSize:   githubSize,
+		})
+	}
+
+	if dumper != nil {
+		if err := dumper.Close(); err != nil {
+			return fmt.Errorf("writing object dump: %w", err)
+		}
+		if err := dumpFile.Close(); err != nil {
+			return fmt.Errorf("writing object dump: %w", err)
+		}
+	}
+
+	if o.saveBaselinePath != "" {
+		if err := historySize.Baseline().Write(o.saveBaselinePath); err != nil {
+			return err
+		}
+	}
+
+	if odbState != nil {
+		if err := odbState.Write(o.saveStatePath); err != nil {
+			return err
+		}
+	}
+
+	if o.healthScore {
+		historySize.ComputeHealthScore(rg.Groups())
+	}
+
+	if err := o.prof.stop(); err != nil {
+		return err
+	}
+
+	return writeReport(ctx, repo, stdout, &o, &historySize, rg.Groups())
+}
+
+// cloneRemote makes a mirror clone of the repository at
+// `o.remoteURL` in `dir` and opens it.
+func cloneRemote(
+	ctx context.Context, env runEnv, stderr io.Writer, o *options, dir string,
+) (*git.Repository, error) {
+	if o.resume {
+		return nil, errors.New("'--remote' can't be combined with '--resume'")
+	}
+
+	cloneOpts := git.CloneOptions{Filter: o.remoteFilter}
+	if o.progress && env.progress == nil {
+		cloneOpts.Progress = stderr
+	}
+	if err := git.CloneMirror(ctx, o.remoteURL, dir, cloneOpts); err != nil {
+		return nil, fmt.Errorf("cloning '%s': %w", o.remoteURL, err)
+	}
+
+	return git.NewRepositoryFromPathWithOptions(
+		ctx, dir, git.RepositoryOptions{AllowShallow: true},
+	)
+}
+
+// scanRoots returns the roots of the scan: the references selected by
+// `rg`, the objects named by `args`, and HEAD, if `head` is set. If an
+// interrupted scan already determined the roots, it returns the same
+// ones, because the saved results of the later phases depend on them.
+func scanRoots(
+	ctx context.Context, repo *git.Repository, rg sizes.RefGrouper, args []string,
+	head *git.Head, checkpoint *sizes.Checkpoint,
+) ([]sizes.Root, error) {
+	roots, resumed, err := checkpoint.Roots()
+	if err != nil || resumed {
+		return roots, err
 	}
 
 	refRoots, err := sizes.CollectReferences(ctx, repo, rg)
 	if err != nil {
-		return fmt.Errorf("determining which reference to scan: %w", err)
+		return nil, fmt.Errorf("determining which reference to scan: %w", err)
 	}
 
-	roots := make([]sizes.Root, 0, len(refRoots)+len(flags.Args()))
+	roots = make([]sizes.Root, 0, len(refRoots)+len(args))
 	for _, refRoot := range refRoots {
 		roots = append(roots, refRoot)
 	}
 
-	for _, arg := range flags.Args() {
-		oid, err := repo.ResolveObject(arg)
+	for _, arg := range args {
+		oid, err := repo.ResolveObjectContext(ctx, arg)
 		if err != nil {
-			return fmt.Errorf("resolving command-line argument %q: %w", arg, err)
+			return nil, fmt.Errorf("resolving command-line argument %q: %w", arg, err)
 		}
 		roots = append(roots, sizes.NewExplicitRoot(arg, oid))
 	}
 
-	historySize, err := sizes.ScanRepositoryUsingGraph(
-		ctx, repo, roots, nameStyle, progressMeter,
-	)
-	if err != nil {
-		return fmt.Errorf("error scanning repository: %w", err)
+	if head != nil && !head.Unborn() {
+		roots = append(roots, sizes.NewExplicitRoot("HEAD", head.OID))
 	}
 
-	if jsonOutput {
-		var j []byte
+	if err := checkpoint.SaveRoots(roots); err != nil {
+		return nil, err
+	}
+	return roots, nil
+}
+
+// writeReport writes the report about `historySize` to `stdout`, in
+// the format selected by `o`, along with the other files that `o`
+// asks for.
+func writeReport(
+	ctx context.Context, repo *git.Repository, stdout io.Writer, o *options,
+	historySize *sizes.HistorySize, refGroups []sizes.RefGroup,
+) error {
+	var j []byte
+	if o.jsonOutput || o.teeJSON != "" {
 		var err error
-		switch jsonVersion {
+		indent := strings.Repeat(" ", o.jsonIndent)
+		switch o.jsonVersion {
 		case 1:
-			j, err = json.MarshalIndent(historySize, "", "    ")
+			j, err = marshalJSON(historySize, o.jsonIndent)
 		case 2:
-			j, err = historySize.JSON(rg.Groups(), threshold, nameStyle)
+			j, err = historySize.JSON(refGroups, o.threshold, o.nameStyle, indent)
 		default:
 			return fmt.Errorf("JSON version must be 1 or 2")
 		}
 		if err != nil {
 			return fmt.Errorf("could not convert %v to json: %w", historySize, err)
 		}
-		fmt.Fprintf(stdout, "%s\n", j)
-	} else {
-		if _, err := io.WriteString(
-			stdout, historySize.TableString(rg.Groups(), threshold, nameStyle),
-		); err != nil {
-			return fmt.Errorf("writing output: %w", err)
+		if o.digest {
+			key := "reportDigest"
+			if o.jsonVersion == 1 {
+				key = "report_digest"
+			}
+			j, err = addReportDigest(ctx, j, key, o.signKey, indent)
+			if err != nil {
+				return fmt.Errorf("computing report digest: %w", err)
+			}
+		}
+	}
+
+	if o.teeJSON != "" {
+		if err := sizes.WriteCompressible(o.teeJSON, append(j, '\n')); err != nil {
+			return fmt.Errorf("writing JSON report: %w", err)
+		}
+	}
+
+	if o.checkoutManifest != "" {
+		if err := writeCheckoutManifest(ctx, repo, historySize, o.checkoutManifest); err != nil {
+			return fmt.Errorf("writing checkout manifest: %w", err)
 		}
 	}
 
+	if o.htmlTreemap != "" {
+		if err := writeHTMLTreemap(historySize, o.htmlTreemap); err != nil {
+			return fmt.Errorf("writing HTML treemap: %w", err)
+		}
+	}
+
+	var output string
+	switch {
+	case o.protoOutput:
+		output = string(historySize.ScanResult(refGroups).Marshal())
+	case o.jsonOutput:
+		output = string(j) + "\n"
+	default:
+		historySize.SetColor(o.color)
+		historySize.SetShowScope(o.showScope)
+		output = historySize.TableString(refGroups, o.threshold, o.nameStyle) +
+			historySize.TopObjectsTableString(refGroups, o.threshold, o.nameStyle) +
+			historySize.ScanIntegrityString() +
+			historySize.HardLimitsString() +
+			historySize.HealthScoreString() +
+			historySize.SharingTableString() +
+			historySize.SharedTreesTableString() +
+			historySize.GrowthTableString() +
+			historySize.RefGroupActivityTableString() +
+			historySize.RefGroupEmptyCommitsTableString() +
+			historySize.RootMaximaTableString() +
+			historySize.ExactCheckoutTableString() +
+			historySize.CheckoutExtensionsTableString() +
+			historySize.RecentBlobsTableString() +
+			historySize.RecentActivityTableString() +
+			historySize.AgeBucketsTableString() +
+			historySize.PackfilesTableString() +
+			historySize.ODBDeltaTableString() +
+			historySize.ReflogOnlyString() +
+			historySize.ForcePushesTableString() +
+			historySize.RefChurnTableString() +
+			historySize.RedundantRefsTableString() +
+			historySize.UnreachableObjectsString() +
+			historySize.TopCommittersTableString() +
+			historySize.LockfilesTableString(o.threshold) +
+			historySize.CompressibilityTableString() +
+			historySize.FileLineageTableString() +
+			historySize.LineEndingDuplicatesTableString() +
+			historySize.NormalizedDuplicatesTableString() +
+			historySize.SizeBudgetTableString() +
+			historySize.ImportArtifactsTableString() +
+			historySize.HostedSizeString() +
+			historySize.HostingLimitsString() +
+			historySize.RecommendationsString() +
+			historySize.CommitGraphWriteString()
+	}
+
+	if o.tuiMode && !o.protoOutput && isTerminal(stdout) {
+		// Let the user scroll through the results:
+		return tui.Page(ctx, repo, stdout, output)
+	}
+	if _, err := io.WriteString(stdout, output); err != nil {
+		return fmt.Errorf("writing output: %w", err)
+	}
+
 	return nil
 }
+
+// marshalJSON returns the JSON encoding of `v`, indented by `indent`
+// spaces per level, or on a single line if `indent` is zero.
+func marshalJSON(v interface{}, indent int) ([]byte, error) {
+	if indent == 0 {
+		return json.Marshal(v)
+	}
+	return json.MarshalIndent(v, "", strings.Repeat(" ", indent))
+}
+
+// writeCheckoutManifest writes the list of the files in the biggest
+// checkout found by the scan (see `HistorySize.WriteCheckoutManifest()`)
+// to the file at `path`, compressed if its name ends in ".gz".
+func writeCheckoutManifest(
+	ctx context.Context, repo *git.Repository, historySize *sizes.HistorySize, path string,
+) error {
+	w, err := sizes.CreateCompressible(path)
+	if err != nil {
+		return err
+	}
+	if err := historySize.WriteCheckoutManifest(ctx, repo, w); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// writeHTMLTreemap writes the treemap of the historical size of the
+// paths (see `HistorySize.WriteHTMLTreemap()`) to the file at `path`.
+func writeHTMLTreemap(historySize *sizes.HistorySize, path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := historySize.WriteHTMLTreemap(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// parseDate parses `s`, which can be a date like "2024-01-01"
+// (meaning midnight, local time), a date and time like "2024-01-01
+// 12:00:00" (local time), or an RFC 3339 timestamp.
+func parseDate(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	t, err := time.ParseInLocation("2006-01-02", s, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a date like YYYY-MM-DD or an RFC 3339 timestamp", s)
+	}
+	return t, nil
+}
+
+// localRemote returns the repository that the remote called `name`
+// refers to, if it is a repository on the local filesystem, or nil
+// otherwise.
+func localRemote(ctx context.Context, repo *git.Repository, name string) *git.Repository {
+	url, err := repo.ConfigStringDefaultContext(ctx, "remote."+name+".url", "")
+	if err != nil || url == "" {
+		return nil
+	}
+	url = strings.TrimPrefix(url, "file://")
+	if fi, err := os.Stat(url); err != nil || !fi.IsDir() {
+		return nil
+	}
+	remote, err := git.NewRepositoryFromPathWithOptions(
+		ctx, url, git.RepositoryOptions{AllowShallow: true},
+	)
+	if err != nil {
+		return nil
+	}
+	return remote
+}
+
+// useColor returns true iff the table should be colored, according
+// to `mode` ("auto", "always", or "never"). In "auto" mode, it is
+// colored only if `stdout` is known to be a terminal and the
+// `NO_COLOR` environment variable isn't set.
+func useColor(mode string, stdout io.Writer) (bool, error) {
+	switch mode {
+	case "always":
+		return true, nil
+	case "never":
+		return false, nil
+	case "auto":
+		return isatty.Available && isTerminal(stdout) &&
+			os.Getenv("NO_COLOR") == "" && os.Getenv("TERM") != "dumb", nil
+	default:
+		return false, fmt.Errorf(
+			"invalid color mode %q (must be 'auto', 'always', or 'never')", mode,
+		)
+	}
+}
+
+// isTerminal returns true iff `w` is a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	atty, err := isatty.Isatty(f.Fd())
+	return err == nil && atty
+}
+
+// checkpointKey describes the options of a scan that determine the
+// results of the phases saved in a checkpoint. A checkpoint is only
+// resumed by a scan with the same key.
+func checkpointKey(args []string, statsList, sectionsList, skipSectionsList string) string {
+	var key []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--resume", arg == "--progress", arg == "--no-progress", arg == "--tui",
+			strings.HasPrefix(arg, "--max-duration="):
+			// These don't affect the results.
+			continue
+		case arg == "--max-duration":
+			// Neither does this, nor its value.
+			i++
+			continue
+		}
+		key = append(key, arg)
+	}
+	k := fmt.Sprintf("%q stats=%q", key, statsList)
+	if sectionsList != "" || skipSectionsList != "" {
+		k += fmt.Sprintf(" sections=%q skip-sections=%q", sectionsList, skipSectionsList)
+	}
+	return k
+}
+
+// configReferenceValues returns the reference values that are set via
+// the multi-valued gitconfig setting 'sizer.referenceValue', with
+// those in `overrides` (from the command line) taking precedence.
+func configReferenceValues(
+	ctx context.Context, repo *git.Repository, overrides sizes.ReferenceValues,
+) (sizes.ReferenceValues, error) {
+	config, err := repo.GetConfigContext(ctx, "sizer")
+	if err != nil {
+		return nil, err
+	}
+
+	var referenceValues sizes.ReferenceValues
+	for _, entry := range config.Entries {
+		if entry.Key != "referencevalue" {
+			continue
+		}
+		if err := referenceValues.Set(entry.Value); err != nil {
+			return nil, fmt.Errorf(
+				"parsing gitconfig value for '%s': %w", config.FullKey("referenceValue"), err,
+			)
+		}
+	}
+	for symbol, v := range overrides {
+		if referenceValues == nil {
+			referenceValues = make(sizes.ReferenceValues)
+		}
+		referenceValues[symbol] = v
+	}
+	return referenceValues, nil
+}
+
+// selectSections returns the statistics in the sections listed in
+// `sectionsList` (or in all sections, if it is empty), except for
+// those listed in `skipSectionsList`.
+func selectSections(
+	sectionsList, skipSectionsList string, refGroups []sizes.RefGroup,
+) (sizes.StatSet, error) {
+	sections := sizes.ReportSections
+	if sectionsList != "" {
+		var err error
+		sections, err = sizes.ParseSections(sectionsList)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	skip, err := sizes.ParseSections(skipSectionsList)
+	if err != nil {
+		return nil, err
+	}
+	skipped := make(map[string]bool, len(skip))
+	for _, name := range skip {
+		skipped[name] = true
+	}
+
+	var selected []string
+	for _, name := range sections {
+		if !skipped[name] {
+			selected = append(selected, name)
+		}
+	}
+	return sizes.SectionStatSet(selected, refGroups)
+}
+
+// checkDeadline returns an error saying that the scan took longer
+// than `maxDuration` if `ctx`'s deadline has passed, which is usually
+// the underlying reason for `err`. Otherwise, it returns `err`.
+func checkDeadline(ctx context.Context, maxDuration time.Duration, err error) error {
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return fmt.Errorf("the scan took longer than --max-duration=%s: %w", maxDuration, err)
+	}
+	return err
+}
@@ -0,0 +1,67 @@
+package git
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/github/git-sizer/counts"
+)
+
+// ObjectTypeTotals is the number of objects of one type, their total
+// size, and the number of bytes that they occupy on disk.
+type ObjectTypeTotals struct {
+	Count    counts.Count32
+	Size     counts.Count64
+	DiskSize counts.Count64
+}
+
+// AllObjectTotals totals the objects in `repo`'s object database by
+// type, whether or not they are reachable. The objects are
+// enumerated via `git cat-file --batch-all-objects --unordered`,
+// which reads them in the order that they are stored without walking
+// any history, so this is much faster than a scan. Objects in
+// alternate object databases are included, and an object that is
+// stored more than once is counted each time.
+func (repo *Repository) AllObjectTotals(ctx context.Context) (map[ObjectType]ObjectTypeTotals, error) {
+	cmd := repo.GitCommandContext(
+		ctx, "cat-file", "--batch-all-objects", "--unordered",
+		"--batch-check=%(objectname) %(objecttype) %(objectsize) %(objectsize:disk)",
+	)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("enumerating objects: %w", err)
+	}
+
+	totals := make(map[ObjectType]ObjectTypeTotals)
+	f := bufio.NewReader(out)
+	for {
+		line, err := f.ReadString('\n')
+		if err == io.EOF && line == "" {
+			break
+		} else if err != nil {
+			_ = cmd.Wait()
+			return nil, fmt.Errorf("enumerating objects: %w", err)
+		}
+
+		header, err := ParseBatchHeader("", line)
+		if err != nil {
+			_ = cmd.Wait()
+			return nil, err
+		}
+		t := totals[header.ObjectType]
+		t.Count.Increment(1)
+		t.Size.Increment(counts.Count64(header.ObjectSize))
+		t.DiskSize.Increment(header.DiskSize)
+		totals[header.ObjectType] = t
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("enumerating objects: %w", err)
+	}
+	return totals, nil
+}
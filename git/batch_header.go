@@ -1,6 +1,7 @@
 package git
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
@@ -12,6 +13,11 @@ type BatchHeader struct {
 	OID        OID
 	ObjectType ObjectType
 	ObjectSize counts.Count32
+
+	// DiskSize is the number of bytes that the object occupies in
+	// the object database. It is only set if the header was
+	// requested with a format that includes `%(objectsize:disk)`.
+	DiskSize counts.Count64
 }
 
 var missingHeader = BatchHeader{
@@ -19,8 +25,12 @@ var missingHeader = BatchHeader{
 }
 
 // Parse a `cat-file --batch[-check]` output header line (including
-// the trailing LF). `spec`, if not "", is used in error messages.
+// the trailing LF, or NUL if `-Z` was used). `spec`, if not "", is
+// used in error messages.
 func ParseBatchHeader(spec string, header string) (BatchHeader, error) {
+	if header == "" {
+		return missingHeader, errors.New("empty header from 'git cat-file'")
+	}
 	header = header[:len(header)-1]
 	words := strings.Split(header, " ")
 	if words[len(words)-1] == "missing" {
@@ -29,19 +39,48 @@ func ParseBatchHeader(spec string, header string) (BatchHeader, error) {
 		}
 		return missingHeader, fmt.Errorf("missing object %s", spec)
 	}
+	if len(words) < 3 || len(words) > 4 {
+		return missingHeader, fmt.Errorf("malformed header from 'git cat-file': %q", header)
+	}
 
 	oid, err := NewOID(words[0])
 	if err != nil {
-		return missingHeader, err
+		return missingHeader, fmt.Errorf("malformed OID in 'git cat-file' header %q: %w", header, err)
 	}
 
-	size, err := strconv.ParseUint(words[2], 10, 0)
+	size, err := strconv.ParseUint(words[2], 10, 64)
 	if err != nil {
-		return missingHeader, err
+		return missingHeader, fmt.Errorf("malformed size of %s in 'git cat-file' header: %w", oid, err)
 	}
-	return BatchHeader{
+	bh := BatchHeader{
 		OID:        oid,
 		ObjectType: ObjectType(words[1]),
 		ObjectSize: counts.NewCount32(size),
-	}, nil
+	}
+	if len(words) > 3 {
+		diskSize, err := strconv.ParseUint(words[3], 10, 64)
+		if err != nil {
+			return missingHeader, fmt.Errorf(
+				"malformed disk size of %s in 'git cat-file' header: %w", oid, err,
+			)
+		}
+		bh.DiskSize = counts.NewCount64(diskSize)
+	}
+	return bh, nil
+}
+
+// parseMissingHeader checks whether `header` (including its
+// terminator) is the header that `git cat-file --batch` emits for an
+// object that doesn't exist. If so, it returns the name of that
+// object, which must be a full OID.
+func parseMissingHeader(header string) (OID, bool) {
+	words := strings.Split(header[:len(header)-1], " ")
+	if len(words) != 2 || words[1] != "missing" {
+		return NullOID, false
+	}
+	oid, err := NewOID(words[0])
+	if err != nil {
+		return NullOID, false
+	}
+	return oid, true
 }
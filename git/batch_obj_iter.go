@@ -1,7 +1,6 @@
 package git
 
 import (
-	"bufio"
 	"context"
 	"fmt"
 	"io"
@@ -14,30 +13,74 @@ type ObjectRecord struct {
 	Data []byte
 }
 
-// BatchObjectIter iterates over objects whose names are fed into its
-// stdin. The output is buffered, so it has to be closed before you
-// can be sure that you have gotten all of the objects.
+// BatchObjectIter iterates over objects whose names are fed into it
+// via `RequestObject()`. At most a fixed number of objects (see
+// `BatchOptions.Window`) can be in flight (i.e., requested but not yet
+// returned by `Next()`); after that, `RequestObject()` blocks until
+// the consumer catches up. Whenever the iterator runs out of requests
+// to forward, it makes sure that `git cat-file` flushes its output,
+// so objects can be requested and read from the same goroutine,
+// too.
 type BatchObjectIter struct {
 	ctx   context.Context
 	p     *pipe.Pipeline
 	oidCh chan OID
 	objCh chan ObjectRecord
-	errCh chan error
+
+	// slots holds one token for each object that is in flight.
+	slots chan struct{}
+
+	// allowMissing is true if objects that can't be found should be
+	// returned as records with `ObjectType` "missing" rather than
+	// making the iterator fail.
+	allowMissing bool
 }
 
-// NewBatchObjectIter returns a `*BatchObjectIterator` and an
-// `io.WriteCloser`. The iterator iterates over objects whose names
-// are fed into the `io.WriteCloser`, one per line. The
-// `io.WriteCloser` should normally be closed and the iterator's
-// output drained before `Close()` is called.
+// NewBatchObjectIter returns a `*BatchObjectIterator` that reads the
+// objects whose names are passed to its `RequestObject()` method.
+// `Close()` should be called after the last object has been
+// requested, and the iterator's output drained before the pipeline
+// is finished. To abandon the iterator early, cancel `ctx`.
 func (repo *Repository) NewBatchObjectIter(ctx context.Context) (*BatchObjectIter, error) {
+	return repo.newBatchObjectIter(ctx, false)
+}
+
+// NewBatchObjectIterAllowingMissing is like `NewBatchObjectIter()`,
+// except that an object that doesn't exist is returned as a record
+// whose `OID` is the requested name and whose `ObjectType` is
+// "missing", so that the caller can decide how to handle it.
+func (repo *Repository) NewBatchObjectIterAllowingMissing(ctx context.Context) (*BatchObjectIter, error) {
+	return repo.newBatchObjectIter(ctx, true)
+}
+
+func (repo *Repository) newBatchObjectIter(ctx context.Context, allowMissing bool) (*BatchObjectIter, error) {
 	iter := BatchObjectIter{
-		ctx:   ctx,
-		p:     pipe.New(),
-		oidCh: make(chan OID),
-		objCh: make(chan ObjectRecord),
-		errCh: make(chan error),
+		ctx:          ctx,
+		p:            pipe.New(),
+		oidCh:        make(chan OID, repo.batchOptions.batchObjectWindow()),
+		objCh:        make(chan ObjectRecord, repo.batchOptions.Window),
+		slots:        make(chan struct{}, repo.batchOptions.batchObjectWindow()),
+		allowMissing: allowMissing,
+	}
+
+	// If possible, use `--batch-command`, so that we can tell `git
+	// cat-file` when to flush its output. Otherwise, have it flush
+	// after every object. If possible, also use NUL-terminated input
+	// and output:
+	capabilities := repo.Capabilities(ctx)
+	batchCommand := capabilities.BatchCommand
+	var catFileArgs []string
+	if batchCommand {
+		catFileArgs = []string{"cat-file", "--batch-command", "--buffer"}
+	} else {
+		catFileArgs = []string{"cat-file", "--batch"}
 	}
+	terminator := byte('\n')
+	if capabilities.CatFileNUL {
+		catFileArgs = append(catFileArgs, "-Z")
+		terminator = 0
+	}
+	catFile := repo.commandStage("git-cat-file", catFileArgs...)
 
 	iter.p.Add(
 		// Read OIDs from `iter.oidCh` and write them to `git
@@ -45,19 +88,45 @@ func (repo *Repository) NewBatchObjectIter(ctx context.Context) (*BatchObjectIte
 		pipe.Function(
 			"request-objects",
 			func(ctx context.Context, _ pipe.Env, _ io.Reader, stdout io.Writer) error {
-				out := bufio.NewWriter(stdout)
+				out := repo.newBatchWriter(stdout)
 
 				for {
+					var oid OID
+					var ok bool
 					select {
-					case oid, ok := <-iter.oidCh:
-						if !ok {
-							return out.Flush()
+					case oid, ok = <-iter.oidCh:
+					default:
+						// There are no more requests waiting, so make
+						// sure that `git cat-file` processes (and
+						// emits) everything that we have sent so far
+						// before we wait for more:
+						if batchCommand {
+							if _, err := fmt.Fprintf(out, "flush%c", terminator); err != nil {
+								return fmt.Errorf("writing to 'git cat-file': %w", err)
+							}
 						}
-						if _, err := fmt.Fprintln(out, oid.String()); err != nil {
+						if err := out.Flush(); err != nil {
 							return fmt.Errorf("writing to 'git cat-file': %w", err)
 						}
-					case <-ctx.Done():
-						return ctx.Err()
+
+						select {
+						case oid, ok = <-iter.oidCh:
+						case <-ctx.Done():
+							return ctx.Err()
+						}
+					}
+					if !ok {
+						return out.Flush()
+					}
+
+					var err error
+					if batchCommand {
+						_, err = fmt.Fprintf(out, "contents %s%c", oid, terminator)
+					} else {
+						_, err = fmt.Fprintf(out, "%s%c", oid, terminator)
+					}
+					if err != nil {
+						return fmt.Errorf("writing to 'git cat-file': %w", err)
 					}
 				}
 			},
@@ -65,10 +134,7 @@ func (repo *Repository) NewBatchObjectIter(ctx context.Context) (*BatchObjectIte
 
 		// Read OIDs from `stdin` and output a header line followed by
 		// the contents of the corresponding Git objects:
-		pipe.CommandStage(
-			"git-cat-file",
-			repo.GitCommand("cat-file", "--batch", "--buffer"),
-		),
+		catFile,
 
 		// Parse the object headers and read the object contents, and
 		// shove both into `objCh`:
@@ -77,23 +143,49 @@ func (repo *Repository) NewBatchObjectIter(ctx context.Context) (*BatchObjectIte
 			func(ctx context.Context, _ pipe.Env, stdin io.Reader, _ io.Writer) error {
 				defer close(iter.objCh)
 
-				f := bufio.NewReader(stdin)
+				f := repo.newBatchReader(stdin)
 
 				for {
-					header, err := f.ReadString('\n')
+					header, err := f.ReadString(terminator)
 					if err != nil {
 						if err == io.EOF {
 							return nil
 						}
 						return fmt.Errorf("reading from 'git cat-file': %w", err)
 					}
+					if iter.allowMissing {
+						if oid, ok := parseMissingHeader(header); ok {
+							select {
+							case iter.objCh <- ObjectRecord{
+								BatchHeader: BatchHeader{
+									OID:        oid,
+									ObjectType: "missing",
+								},
+							}:
+								continue
+							case <-ctx.Done():
+								return ctx.Err()
+							}
+						}
+					}
 					batchHeader, err := ParseBatchHeader("", header)
 					if err != nil {
 						return fmt.Errorf("parsing output of 'git cat-file': %w", err)
 					}
 
-					// Read the object contents plus the trailing LF
-					// (which is discarded below while creating the
+					// The contents are read into memory, so objects
+					// whose sizes don't even fit in a `Count32` can't
+					// be processed (and they would make the
+					// arithmetic below overflow):
+					if _, overflow := batchHeader.ObjectSize.ToUint64(); overflow {
+						return fmt.Errorf(
+							"%s '%s' is too large to be read",
+							batchHeader.ObjectType, batchHeader.OID,
+						)
+					}
+
+					// Read the object contents plus the trailing LF or
+					// NUL (which is discarded below while creating the
 					// `ObjectRecord`):
 					data := make([]byte, batchHeader.ObjectSize+1)
 					if _, err := io.ReadFull(f, data); err != nil {
@@ -108,8 +200,8 @@ func (repo *Repository) NewBatchObjectIter(ctx context.Context) (*BatchObjectIte
 						BatchHeader: batchHeader,
 						Data:        data[:batchHeader.ObjectSize],
 					}:
-					case <-iter.ctx.Done():
-						return iter.ctx.Err()
+					case <-ctx.Done():
+						return ctx.Err()
 					}
 				}
 			},
@@ -125,8 +217,16 @@ func (repo *Repository) NewBatchObjectIter(ctx context.Context) (*BatchObjectIte
 
 // RequestObject requests that the object with the specified `oid` be
 // processed. The objects registered via this method can be read using
-// `Next()` in the order that they were requested.
+// `Next()` in the order that they were requested. If too many objects
+// are already in flight, it blocks until `Next()` has been called
+// enough times to make room, or until the context is canceled.
 func (iter *BatchObjectIter) RequestObject(oid OID) error {
+	select {
+	case iter.slots <- struct{}{}:
+	case <-iter.ctx.Done():
+		return iter.ctx.Err()
+	}
+
 	select {
 	case iter.oidCh <- oid:
 		return nil
@@ -136,15 +236,16 @@ func (iter *BatchObjectIter) RequestObject(oid OID) error {
 }
 
 // Close closes the iterator and frees up resources. Close must be
-// called exactly once.
+// called exactly once, after the last call to `RequestObject()`.
 func (iter *BatchObjectIter) Close() {
 	close(iter.oidCh)
 }
 
 // Next either returns the next object (its header and contents), or a
-// `false` boolean value if no more objects are left. Objects need to
-// be read asynchronously, but the last objects won't necessarily show
-// up here until `Close()` has been called.
+// `false` boolean value if no more objects are left. It blocks until
+// the next requested object is available; it returns `false` only
+// after `Close()` has been called and all of the requested objects
+// have been returned, or if the context is canceled.
 func (iter *BatchObjectIter) Next() (ObjectRecord, bool, error) {
 	obj, ok := <-iter.objCh
 	if !ok {
@@ -152,5 +253,6 @@ func (iter *BatchObjectIter) Next() (ObjectRecord, bool, error) {
 			BatchHeader: missingHeader,
 		}, false, iter.p.Wait()
 	}
+	<-iter.slots
 	return obj, true, nil
 }
@@ -0,0 +1,162 @@
+package git_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/internal/testutils"
+)
+
+// createBlobs creates `n` distinct small blobs in `repo` and returns
+// their OIDs.
+func createBlobs(t *testing.T, repo *testutils.TestRepo, n int) []git.OID {
+	t.Helper()
+
+	oids := make([]git.OID, n)
+	for i := range oids {
+		oids[i] = repo.CreateObject(t, "blob", func(w io.Writer) error {
+			_, err := fmt.Fprintf(w, "blob %d\n", i)
+			return err
+		})
+	}
+	return oids
+}
+
+func TestBatchObjectIter(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, true, "batch-obj-iter")
+	defer testRepo.Remove(t)
+
+	oids := createBlobs(t, testRepo, 20)
+
+	const window = 4
+	repo := testRepo.Repository(t)
+	repo.SetBatchOptions(git.BatchOptions{Window: window})
+
+	t.Run("synchronous", func(t *testing.T) {
+		// Request each object and read it back before requesting the
+		// next one. This only works if the iterator doesn't wait for
+		// more requests before emitting objects.
+		iter, err := repo.NewBatchObjectIter(context.Background())
+		require.NoError(t, err)
+
+		for i, oid := range oids {
+			require.NoError(t, iter.RequestObject(oid))
+			obj, ok, err := iter.Next()
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, oid, obj.OID)
+			assert.Equal(t, fmt.Sprintf("blob %d\n", i), string(obj.Data))
+		}
+		iter.Close()
+
+		_, ok, err := iter.Next()
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("slow-consumer", func(t *testing.T) {
+		iter, err := repo.NewBatchObjectIter(context.Background())
+		require.NoError(t, err)
+
+		var requested int32
+		errCh := make(chan error, 1)
+		go func() {
+			defer iter.Close()
+			errCh <- func() error {
+				for _, oid := range oids {
+					if err := iter.RequestObject(oid); err != nil {
+						return err
+					}
+					atomic.AddInt32(&requested, 1)
+				}
+				return nil
+			}()
+		}()
+
+		for i, oid := range oids {
+			// Give the requester a chance to get ahead:
+			time.Sleep(5 * time.Millisecond)
+			assert.LessOrEqual(t, int(atomic.LoadInt32(&requested)), i+window, "too many in flight")
+
+			obj, ok, err := iter.Next()
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, oid, obj.OID)
+		}
+
+		_, ok, err := iter.Next()
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.NoError(t, <-errCh)
+	})
+
+	t.Run("early-close", func(t *testing.T) {
+		iter, err := repo.NewBatchObjectIter(context.Background())
+		require.NoError(t, err)
+
+		for _, oid := range oids[:3] {
+			require.NoError(t, iter.RequestObject(oid))
+		}
+		iter.Close()
+
+		for _, oid := range oids[:3] {
+			obj, ok, err := iter.Next()
+			require.NoError(t, err)
+			require.True(t, ok)
+			assert.Equal(t, oid, obj.OID)
+		}
+		_, ok, err := iter.Next()
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		iter, err := repo.NewBatchObjectIter(ctx)
+		require.NoError(t, err)
+
+		// Nobody is reading, so the requester blocks once the window
+		// is full, until the context is canceled:
+		errCh := make(chan error, 1)
+		go func() {
+			defer iter.Close()
+			errCh <- func() error {
+				for _, oid := range oids {
+					if err := iter.RequestObject(oid); err != nil {
+						return err
+					}
+				}
+				return nil
+			}()
+		}()
+
+		select {
+		case err := <-errCh:
+			t.Fatalf("requester finished despite full window: %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+
+		cancel()
+		assert.ErrorIs(t, <-errCh, context.Canceled)
+
+		// Drain the iterator; it must terminate:
+		for {
+			_, ok, _ := iter.Next()
+			if !ok {
+				break
+			}
+		}
+	})
+}
@@ -0,0 +1,70 @@
+package git
+
+import (
+	"bufio"
+	"context"
+	"io"
+)
+
+// defaultBatchObjectWindow is the maximum number of objects that a
+// `BatchObjectIter` may have requested but not yet returned, if
+// `BatchOptions.Window` is zero.
+const defaultBatchObjectWindow = 1024
+
+// BatchOptions tune the pipelines that feed object names to, and
+// read results from, `git rev-list` and `git cat-file`. The zero
+// value selects the default behavior.
+type BatchOptions struct {
+	// BufferSize is the size, in bytes, of the buffers used for
+	// reading from and writing to the `git` subprocesses. If it is
+	// zero, the `bufio` default is used.
+	BufferSize int
+
+	// Window is the number of object requests and results that can
+	// be in flight between the goroutine that requests objects and
+	// the goroutine that reads them. If it is zero, each request is
+	// handed off synchronously. It also limits the number of objects
+	// that a `BatchObjectIter` may have requested but not yet
+	// returned (or `defaultBatchObjectWindow`, if it is zero).
+	Window int
+}
+
+// batchObjectWindow returns the maximum number of objects that a
+// `BatchObjectIter` may have in flight.
+func (opts BatchOptions) batchObjectWindow() int {
+	if opts.Window > 0 {
+		return opts.Window
+	}
+	return defaultBatchObjectWindow
+}
+
+// SetBatchOptions sets the options that are used by iterators that
+// are created for `repo` from now on.
+func (repo *Repository) SetBatchOptions(opts BatchOptions) {
+	repo.batchOptions = opts
+}
+
+// newBatchWriter returns a buffered writer for `w` that respects
+// `repo`'s batch options.
+func (repo *Repository) newBatchWriter(w io.Writer) *bufio.Writer {
+	if repo.batchOptions.BufferSize > 0 {
+		return bufio.NewWriterSize(w, repo.batchOptions.BufferSize)
+	}
+	return bufio.NewWriter(w)
+}
+
+// newBatchReader returns a buffered reader for `r` that respects
+// `repo`'s batch options.
+func (repo *Repository) newBatchReader(r io.Reader) *bufio.Reader {
+	if repo.batchOptions.BufferSize > 0 {
+		return bufio.NewReaderSize(r, repo.batchOptions.BufferSize)
+	}
+	return bufio.NewReader(r)
+}
+
+// supportsBatchCommand returns true iff `repo`'s `git` supports `git
+// cat-file --batch-command --buffer`, which lets us tell it when to
+// flush its output. (It was added in Git 2.36.)
+func (repo *Repository) supportsBatchCommand(ctx context.Context) bool {
+	return repo.Capabilities(ctx).BatchCommand
+}
@@ -0,0 +1,41 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// ReadBlobPrefix returns the first `n` bytes of the blob named by
+// `oid`, or all of it if it is shorter. The rest of the blob is not
+// read.
+func (repo *Repository) ReadBlobPrefix(ctx context.Context, oid OID, n int64) ([]byte, error) {
+	cmd := repo.GitCommandContext(ctx, "cat-file", "blob", oid.String())
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("reading blob '%s': %w", oid, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("reading blob '%s': %w", oid, err)
+	}
+
+	prefix, readErr := io.ReadAll(io.LimitReader(out, n))
+
+	if int64(len(prefix)) < n {
+		// We've read the whole blob, so `git cat-file` should exit
+		// successfully:
+		if err := cmd.Wait(); err != nil {
+			return nil, fmt.Errorf("reading blob '%s': %w", oid, err)
+		}
+	} else {
+		// We don't need the rest of the blob, so there's no point
+		// letting `git cat-file` finish writing it:
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}
+
+	if readErr != nil {
+		return nil, fmt.Errorf("reading blob '%s': %w", oid, readErr)
+	}
+	return prefix, nil
+}
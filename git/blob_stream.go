@@ -0,0 +1,49 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// CopyBlob writes the contents of the blob named by `oid` to `w`. If
+// writing to `w` fails, the rest of the blob is not read and the error
+// is returned as is, so that `w` can stop the copy early by returning
+// an error of its own.
+func (repo *Repository) CopyBlob(ctx context.Context, oid OID, w io.Writer) error {
+	cmd := repo.GitCommandContext(ctx, "cat-file", "blob", oid.String())
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("reading blob '%s': %w", oid, err)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("reading blob '%s': %w", oid, err)
+	}
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, readErr := out.Read(buf)
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				// There's no point letting `git cat-file` finish
+				// writing the blob:
+				_ = cmd.Process.Kill()
+				_ = cmd.Wait()
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+			return fmt.Errorf("reading blob '%s': %w", oid, readErr)
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("reading blob '%s': %w", oid, err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package git
+
+import (
+	"context"
+	"strings"
+)
+
+// Capabilities describes the optional features of the `git`
+// executable that git-sizer can take advantage of. Features that
+// appeared in different Git versions are probed for individually,
+// rather than being inferred from the version number, because some
+// distributions backport features.
+type Capabilities struct {
+	// Version is the version reported by `git version` (e.g.,
+	// "2.39.5"), or the empty string if it couldn't be determined.
+	Version string `json:"version"`
+
+	// BatchCommand is true iff `git cat-file --batch-command
+	// --buffer` is supported (Git 2.36), which lets git-sizer tell
+	// `git cat-file` when to flush its output.
+	BatchCommand bool `json:"batch_command"`
+
+	// RevListDiskUsage is true iff `git rev-list --disk-usage` is
+	// supported (Git 2.31).
+	RevListDiskUsage bool `json:"rev_list_disk_usage"`
+
+	// RevListNoObjectNames is true iff `git rev-list --objects
+	// --no-object-names` is supported (Git 2.22), which omits the
+	// paths that would otherwise follow the object names.
+	RevListNoObjectNames bool `json:"rev_list_no_object_names"`
+
+	// CatFileNUL is true iff `git cat-file -Z` is supported (Git
+	// 2.40), which makes both the input and the output of the batch
+	// modes NUL-terminated.
+	CatFileNUL bool `json:"cat_file_nul"`
+}
+
+// Capabilities returns the capabilities of the `git` executable that
+// is used for `repo`. It is probed the first time that it is needed;
+// after that, the answer is cached.
+func (repo *Repository) Capabilities(ctx context.Context) Capabilities {
+	repo.capabilitiesOnce.Do(func() {
+		repo.capabilities = Capabilities{
+			Version:          repo.gitVersion(ctx),
+			BatchCommand:     repo.probe(ctx, "cat-file", "--batch-command", "--buffer"),
+			RevListDiskUsage: repo.probe(ctx, "rev-list", "--disk-usage", "--stdin"),
+			RevListNoObjectNames: repo.probe(
+				ctx, "rev-list", "--objects", "--no-object-names", "--stdin",
+			),
+			CatFileNUL: repo.probe(ctx, "cat-file", "-Z", "--batch-check"),
+		}
+	})
+	return repo.capabilities
+}
+
+// gitVersion returns the version of `repo`'s `git` executable, or the
+// empty string if it can't be determined.
+func (repo *Repository) gitVersion(ctx context.Context) string {
+	out, err := repo.GitCommandContext(ctx, "version").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimPrefix(strings.TrimSpace(string(out)), "git version ")
+}
+
+// probe returns true iff the git command with the specified arguments
+// succeeds when given empty input, which shows that `git` understands
+// the options.
+func (repo *Repository) probe(ctx context.Context, args ...string) bool {
+	cmd := repo.GitCommandContext(ctx, args...)
+	cmd.Stdin = nil
+	return cmd.Run() == nil
+}
@@ -0,0 +1,74 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// CloneOptions configures `CloneMirror()`.
+type CloneOptions struct {
+	// Filter, if non-empty, is passed to `git clone --filter`, to
+	// make a partial clone (e.g., "blob:none"). Git fetches the
+	// objects that were filtered out on demand when they are read.
+	Filter string
+
+	// Progress, if non-nil, is where the progress output of `git
+	// clone` is written. Otherwise, the clone is quiet.
+	Progress io.Writer
+}
+
+// CloneMirror makes a bare mirror clone of the repository at `url`
+// (anything that `git clone` accepts) in the directory `dir`, which
+// must either not exist or be empty.
+func CloneMirror(ctx context.Context, url, dir string, opts CloneOptions) error {
+	gitBin, err := findGitBin()
+	if err != nil {
+		return fmt.Errorf(
+			"could not find 'git' executable (is it in your PATH?): %w", err,
+		)
+	}
+
+	args := []string{"clone", "--mirror"}
+	if opts.Filter != "" {
+		args = append(args, "--filter="+opts.Filter)
+	}
+	var stderr bytes.Buffer
+	if opts.Progress != nil {
+		args = append(args, "--progress")
+	} else {
+		args = append(args, "--quiet")
+	}
+	// The "--" keeps a URL that starts with '-' from being taken for
+	// an option:
+	args = append(args, "--", url, dir)
+
+	// If the progress is shown, so is any error message from git;
+	// otherwise, it is included in the returned error:
+	cmd := exec.CommandContext(ctx, gitBin, args...)
+	if opts.Progress != nil {
+		cmd.Stderr = opts.Progress
+	} else {
+		cmd.Stderr = &stderr
+	}
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("git clone: %w: %s", err, lastLine(msg))
+		}
+		return fmt.Errorf("git clone: %w", err)
+	}
+	return nil
+}
+
+// lastLine returns the last line of `s`, which is where git puts the
+// reason that a command failed.
+func lastLine(s string) string {
+	if i := strings.LastIndexAny(s, "\r\n"); i >= 0 {
+		return s[i+1:]
+	}
+	return s
+}
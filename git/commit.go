@@ -2,6 +2,8 @@ package git
 
 import (
 	"fmt"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/github/git-sizer/counts"
 )
@@ -11,6 +13,54 @@ type Commit struct {
 	Size    counts.Count32
 	Parents []OID
 	Tree    OID
+
+	// HeaderSize is the size in bytes of the commit's header block
+	// (i.e., everything before the blank line preceding the log
+	// message).
+	HeaderSize counts.Count32
+
+	// NonstandardHeaderCount is the number of headers that Git
+	// itself doesn't write, plus the number of repeated headers that
+	// should appear only once.
+	NonstandardHeaderCount counts.Count32
+
+	// Encoding is the value of the commit's `encoding` header, or
+	// the empty string if it has none (which means UTF-8).
+	Encoding string
+
+	// InvalidUTF8Message is true iff the commit's log message is not
+	// valid UTF-8 (regardless of its declared encoding).
+	InvalidUTF8Message bool
+
+	// Author and Committer are the identities (e.g., "A U Thor
+	// <author@example.com>") from the commit's `author` and
+	// `committer` headers, without the timestamps.
+	Author    string
+	Committer string
+}
+
+// HasNonUTF8Encoding returns true iff the commit declares an encoding
+// other than UTF-8.
+func (c *Commit) HasNonUTF8Encoding() bool {
+	switch strings.ToLower(c.Encoding) {
+	case "", "utf-8", "utf8":
+		return false
+	default:
+		return true
+	}
+}
+
+// standardCommitHeaders are the headers that Git writes in commits.
+// The value tells whether the header may appear more than once.
+var standardCommitHeaders = map[string]bool{
+	"tree":          false,
+	"parent":        true,
+	"author":        false,
+	"committer":     false,
+	"encoding":      false,
+	"gpgsig":        false,
+	"gpgsig-sha256": false,
+	"mergetag":      true,
 }
 
 // ParseCommit parses the commit object whose contents are in `data`.
@@ -19,39 +69,73 @@ func ParseCommit(oid OID, data []byte) (*Commit, error) {
 	var parents []OID
 	var tree OID
 	var treeFound bool
-	iter, err := NewObjectHeaderIter(oid.String(), data)
+	var encoding string
+	var author, committer string
+	iter, err := NewObjectHeaderIter("commit "+oid.String(), data)
 	if err != nil {
 		return nil, err
 	}
+	headerSize := len(iter.data)
+	var headers headerCounter
 	for iter.HasNext() {
+		offset := iter.Offset()
 		key, value, err := iter.Next()
 		if err != nil {
 			return nil, err
 		}
+		headers.add(standardCommitHeaders, key)
 		switch key {
 		case "parent":
 			parent, err := NewOID(value)
 			if err != nil {
-				return nil, fmt.Errorf("malformed parent header in commit %s", oid)
+				return nil, fmt.Errorf(
+					"malformed parent header in commit %s at offset %d", oid, offset,
+				)
 			}
 			parents = append(parents, parent)
 		case "tree":
 			if treeFound {
-				return nil, fmt.Errorf("multiple trees found in commit %s", oid)
+				return nil, fmt.Errorf(
+					"multiple trees found in commit %s (at offset %d)", oid, offset,
+				)
 			}
 			tree, err = NewOID(value)
 			if err != nil {
-				return nil, fmt.Errorf("malformed tree header in commit %s", oid)
+				return nil, fmt.Errorf(
+					"malformed tree header in commit %s at offset %d", oid, offset,
+				)
 			}
 			treeFound = true
+		case "encoding":
+			encoding = value
+		case "author":
+			author = identity(value)
+		case "committer":
+			committer = identity(value)
 		}
 	}
 	if !treeFound {
 		return nil, fmt.Errorf("no tree found in commit %s", oid)
 	}
 	return &Commit{
-		Size:    counts.NewCount32(uint64(len(data))),
-		Parents: parents,
-		Tree:    tree,
+		Size:                   counts.NewCount32(uint64(len(data))),
+		Parents:                parents,
+		Tree:                   tree,
+		HeaderSize:             counts.NewCount32(uint64(headerSize)),
+		NonstandardHeaderCount: headers.nonstandard,
+		Encoding:               encoding,
+		InvalidUTF8Message:     !utf8.Valid(data[headerSize:]),
+		Author:                 author,
+		Committer:              committer,
 	}, nil
 }
+
+// identity returns the identity part of the value of an `author`,
+// `committer`, or `tagger` header; i.e., everything up to and
+// including the closing `>` of the email address.
+func identity(value string) string {
+	if i := strings.LastIndexByte(value, '>'); i >= 0 {
+		return value[:i+1]
+	}
+	return value
+}
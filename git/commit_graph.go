@@ -0,0 +1,160 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+)
+
+// commitGraphSignature is the signature at the start of a
+// commit-graph file.
+var commitGraphSignature = []byte("CGPH")
+
+// The IDs of the chunks of a commit-graph file that hold the
+// changed-path Bloom filters:
+var (
+	bloomIndexChunkID = []byte("BIDX")
+	bloomDataChunkID  = []byte("BDAT")
+)
+
+// commitGraphFiles returns the paths of the files of `repo`'s
+// commit-graph, which is either a single file or a chain of them. It
+// returns nil if there is no commit-graph. Commit-graphs in alternate
+// object databases aren't considered.
+func (repo *Repository) commitGraphFiles(ctx context.Context) ([]string, error) {
+	infoDir, err := repo.gitPath(ctx, "objects/info")
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	single := filepath.Join(infoDir, "commit-graph")
+	if _, err := os.Stat(single); err == nil {
+		files = append(files, single)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("reading commit-graph: %w", err)
+	}
+
+	chainDir := filepath.Join(infoDir, "commit-graphs")
+	chain, err := os.ReadFile(filepath.Join(chainDir, "commit-graph-chain"))
+	switch {
+	case err == nil:
+		scanner := bufio.NewScanner(bytes.NewReader(chain))
+		for scanner.Scan() {
+			if hash := strings.TrimSpace(scanner.Text()); hash != "" {
+				files = append(files, filepath.Join(chainDir, "graph-"+hash+".graph"))
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("reading commit-graph chain: %w", err)
+		}
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, fmt.Errorf("reading commit-graph chain: %w", err)
+	}
+
+	return files, nil
+}
+
+// HasCommitGraph returns true iff `repo` has a commit-graph (either a
+// single file or a chain of them). Commit-graphs in alternate object
+// databases aren't considered.
+func (repo *Repository) HasCommitGraph(ctx context.Context) (bool, error) {
+	files, err := repo.commitGraphFiles(ctx)
+	if err != nil {
+		return false, err
+	}
+	return len(files) != 0, nil
+}
+
+// WriteCommitGraph writes a commit-graph for all of the commits that
+// are reachable from `repo`'s references, using `git commit-graph
+// write --reachable`.
+func (repo *Repository) WriteCommitGraph(ctx context.Context) error {
+	cmd := repo.GitCommandContext(ctx, "commit-graph", "write", "--reachable")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf(
+			"writing commit-graph: %w: %s", err, strings.TrimSpace(string(out)),
+		)
+	}
+	return nil
+}
+
+// CountAllCommits returns the number of commits that are reachable
+// from `repo`'s references, as counted by `git rev-list --count
+// --all`. This walks the whole history, so timing it shows how
+// quickly Git can do so.
+func (repo *Repository) CountAllCommits(ctx context.Context) (counts.Count64, error) {
+	return repo.revListCount(ctx, nil, "--all")
+}
+
+// HasChangedPathFilters returns true iff `repo` has a commit-graph
+// (either a single file or a chain of them), and every file of it
+// includes changed-path Bloom filters (see git-commit-graph(1)). Git
+// uses those filters to skip the commits that can't have changed the
+// paths given to path-limited commands like `git log -- <path>`.
+// Commit-graphs in alternate object databases aren't considered.
+func (repo *Repository) HasChangedPathFilters(ctx context.Context) (bool, error) {
+	files, err := repo.commitGraphFiles(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if len(files) == 0 {
+		return false, nil
+	}
+	for _, path := range files {
+		ok, err := commitGraphHasBloomFilters(path)
+		if err != nil {
+			return false, err
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// commitGraphHasBloomFilters returns true iff the commit-graph file at
+// `path` has both of the chunks that hold changed-path Bloom filters.
+// The file starts with an 8-byte header, whose seventh byte is the
+// number of chunks, followed by a table of contents with a 12-byte
+// entry (a 4-byte chunk ID and an 8-byte offset) for each chunk.
+func commitGraphHasBloomFilters(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, fmt.Errorf("reading commit-graph: %w", err)
+	}
+	defer f.Close()
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(f, header); err != nil {
+		return false, fmt.Errorf("reading commit-graph %s: %w", path, err)
+	}
+	if !bytes.Equal(header[:4], commitGraphSignature) {
+		return false, fmt.Errorf("%s is not a commit-graph file", path)
+	}
+
+	toc := make([]byte, 12*int(header[6]))
+	if _, err := io.ReadFull(f, toc); err != nil {
+		return false, fmt.Errorf("reading commit-graph %s: %w", path, err)
+	}
+	var index, data bool
+	for i := 0; i < len(toc); i += 12 {
+		switch id := toc[i : i+4]; {
+		case bytes.Equal(id, bloomIndexChunkID):
+			index = true
+		case bytes.Equal(id, bloomDataChunkID):
+			data = true
+		}
+	}
+	return index && data, nil
+}
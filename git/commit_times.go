@@ -0,0 +1,48 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// CommitTimes returns the committer times (in seconds since the
+// epoch) of the commits that are reachable from `tips`, newest first.
+// Any `tips` that are not commits are ignored, except that tags are
+// peeled.
+func (repo *Repository) CommitTimes(ctx context.Context, tips []OID) ([]int64, error) {
+	if len(tips) == 0 {
+		return nil, nil
+	}
+
+	stdin := &bytes.Buffer{}
+	for _, oid := range tips {
+		fmt.Fprintln(stdin, oid)
+	}
+
+	cmd := repo.GitCommandContext(ctx, "rev-list", "--stdin", "--timestamp")
+	cmd.Stdin = stdin
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing commit times: %w", err)
+	}
+
+	var times []int64
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		i := strings.IndexByte(line, ' ')
+		if i < 0 {
+			return nil, fmt.Errorf("unexpected output from 'git rev-list': %q", line)
+		}
+		t, err := strconv.ParseInt(line[:i], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected output from 'git rev-list': %q", line)
+		}
+		times = append(times, t)
+	}
+	return times, scanner.Err()
+}
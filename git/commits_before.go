@@ -0,0 +1,39 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+)
+
+// CommitsBefore returns the commits that are reachable from `tips`
+// and whose committer dates are earlier than `t`. Any `tips` that
+// are not commits are ignored, except that tags are peeled.
+func (repo *Repository) CommitsBefore(ctx context.Context, tips []OID, t time.Time) ([]OID, error) {
+	stdin := &bytes.Buffer{}
+	for _, oid := range tips {
+		fmt.Fprintln(stdin, oid)
+	}
+
+	cmd := repo.GitCommandContext(
+		ctx, "rev-list", "--stdin", fmt.Sprintf("--min-age=%d", t.Unix()-1),
+	)
+	cmd.Stdin = stdin
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing commits before %s: %w", t.Format(time.RFC3339), err)
+	}
+
+	var commits []OID
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		oid, err := NewOID(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("unexpected output from 'git rev-list': %w", err)
+		}
+		commits = append(commits, oid)
+	}
+	return commits, scanner.Err()
+}
@@ -0,0 +1,41 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+)
+
+// CommitsMatching returns the commits that are reachable from `tips`
+// and whose log messages have a line matching the extended regular
+// expression `pattern` (as for `git rev-list -E --grep`). Any `tips`
+// that are not commits are ignored, except that tags are peeled.
+func (repo *Repository) CommitsMatching(ctx context.Context, tips []OID, pattern string) ([]OID, error) {
+	if len(tips) == 0 {
+		return nil, nil
+	}
+
+	stdin := &bytes.Buffer{}
+	for _, oid := range tips {
+		fmt.Fprintln(stdin, oid)
+	}
+
+	cmd := repo.GitCommandContext(ctx, "rev-list", "--stdin", "-E", "--grep="+pattern)
+	cmd.Stdin = stdin
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing commits matching %q: %w", pattern, err)
+	}
+
+	var commits []OID
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		oid, err := NewOID(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("unexpected output from 'git rev-list': %w", err)
+		}
+		commits = append(commits, oid)
+	}
+	return commits, scanner.Err()
+}
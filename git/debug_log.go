@@ -0,0 +1,66 @@
+package git
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/github/go-pipe/pipe"
+)
+
+// SetDebugLog causes a line to be written to `w` for each `git`
+// command that is run for `repo` from now on, or stops that if `w` is
+// nil. Each line can be pasted into a POSIX shell to run the command
+// the same way: it sets the environment variables that `repo` adds
+// to the inherited environment, followed by the command and its
+// arguments. The commands that are stages of a pipeline are labeled
+// with the name of their stage (e.g., "git-cat-file"); their input
+// is the output of the previous stage (e.g., the object names
+// written by `git rev-list`) or comes from git-sizer itself.
+func (repo *Repository) SetDebugLog(w io.Writer) {
+	repo.debugLock.Lock()
+	defer repo.debugLock.Unlock()
+
+	repo.debugLog = w
+}
+
+// commandStage returns a pipeline stage called `name` that runs
+// `git` with `args` in `repo`.
+func (repo *Repository) commandStage(name string, args ...string) pipe.Stage {
+	cmd := repo.newGitCommand(context.Background(), args...)
+	repo.logCommand("pipeline stage "+name, cmd)
+	return pipe.CommandStage(name, cmd)
+}
+
+// logCommand writes `cmd` to the debug log, if there is one, with
+// the specified `label`.
+func (repo *Repository) logCommand(label string, cmd *exec.Cmd) {
+	repo.debugLock.Lock()
+	defer repo.debugLock.Unlock()
+
+	if repo.debugLog == nil {
+		return
+	}
+
+	var words []string
+	for _, v := range repo.commandEnv() {
+		if i := strings.IndexByte(v, '='); i > 0 {
+			words = append(words, v[:i+1]+shellQuote(v[i+1:]))
+		}
+	}
+	for _, arg := range cmd.Args {
+		words = append(words, shellQuote(arg))
+	}
+	fmt.Fprintf(repo.debugLog, "git-sizer: %s: %s\n", label, strings.Join(words, " "))
+}
+
+// shellQuote returns `s`, quoted if necessary so that a POSIX shell
+// reads it as a single word.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789_@%+=:,./-") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,46 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+)
+
+// ObjectsDiskSize returns the number of bytes that the objects `oids`
+// occupy in `repo`'s object database, as reported by `git cat-file
+// --batch-check=%(objectsize:disk)`. Objects that are missing are
+// ignored.
+func (repo *Repository) ObjectsDiskSize(ctx context.Context, oids []OID) (counts.Count64, error) {
+	if len(oids) == 0 {
+		return 0, nil
+	}
+
+	input := &bytes.Buffer{}
+	for _, oid := range oids {
+		fmt.Fprintf(input, "%s\n", oid)
+	}
+
+	cmd := repo.GitCommandContext(ctx, "cat-file", "--batch-check=%(objectsize:disk)")
+	cmd.Stdin = input
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("reading object sizes in %s: %w", repo.GitDir(), err)
+	}
+
+	var total counts.Count64
+	for _, line := range strings.Split(strings.TrimSuffix(string(out), "\n"), "\n") {
+		if line == "" || strings.HasSuffix(line, " missing") {
+			continue
+		}
+		n, err := strconv.ParseUint(line, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("unexpected output from 'git cat-file': %q", line)
+		}
+		total.Increment(counts.NewCount64(n))
+	}
+	return total, nil
+}
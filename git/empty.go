@@ -0,0 +1,26 @@
+package git
+
+import (
+	"context"
+	"fmt"
+)
+
+// IsEmpty returns true iff `repo` has no references and its `HEAD` is
+// unborn, as in a repository that was just initialized (or a bare
+// repository that nothing has been pushed to yet).
+func (repo *Repository) IsEmpty(ctx context.Context) (bool, error) {
+	cmd := repo.GitCommandContext(ctx, "for-each-ref", "--count=1", "--format=%(refname)")
+	out, err := cmd.Output()
+	if err != nil {
+		return false, fmt.Errorf("listing references: %w", err)
+	}
+	if len(out) != 0 {
+		return false, nil
+	}
+
+	head, err := repo.ResolveHead(ctx)
+	if err != nil {
+		return false, err
+	}
+	return head.Unborn(), nil
+}
@@ -0,0 +1,33 @@
+package git
+
+// RevListObjectName exposes `revListObjectName()` to the tests.
+var RevListObjectName = revListObjectName
+
+// RevListObjectNamer exposes `revListObjectNamer()` to the tests.
+var RevListObjectNamer = revListObjectNamer
+
+// CopyObjectName exposes `copyObjectName()` to the tests.
+var CopyObjectName = copyObjectName
+
+// SetCapabilities overrides the capabilities that would otherwise be
+// probed for `repo`, so that the fallbacks for older versions of Git
+// can be tested.
+func SetCapabilities(repo *Repository, capabilities Capabilities) {
+	repo.capabilitiesOnce.Do(func() {})
+	repo.capabilities = capabilities
+}
+
+// PackedRefsPeeled returns the peeled values that `readPackedRefs()`
+// reads from the `packed-refs` file at `path`, keyed by refname. The
+// null OID means that the peeled value isn't known.
+func PackedRefsPeeled(path string) (map[string]OID, error) {
+	refs := make(map[string]nativeRef)
+	if err := readPackedRefs(path, refs); err != nil {
+		return nil, err
+	}
+	peeled := make(map[string]OID, len(refs))
+	for refname, ref := range refs {
+		peeled[refname] = ref.peeled
+	}
+	return peeled, nil
+}
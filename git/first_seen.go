@@ -0,0 +1,112 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FirstSeenTimes returns, for each commit, tree, and blob that is
+// reachable from the commits in `tips`, the earliest committer time
+// (in seconds since the epoch) of the commits that contain it. The
+// objects of submodules aren't included. Tips that aren't commits or
+// tags pointing at commits are ignored.
+//
+// It runs `git log` over the whole history, showing the objects that
+// each commit adds or changes relative to each of its parents (or,
+// for root commits, all of their objects). Every object that a commit
+// contains is either shown for that commit or contained in one of its
+// parents, so the earliest time at which an object is shown is the
+// earliest time of the commits that contain it.
+func (repo *Repository) FirstSeenTimes(ctx context.Context, tips []OID) (map[OID]int64, error) {
+	times := make(map[OID]int64)
+	if len(tips) == 0 {
+		return times, nil
+	}
+
+	revs := &bytes.Buffer{}
+	for _, oid := range tips {
+		fmt.Fprintf(revs, "%s\n", oid)
+	}
+
+	cmd := repo.GitCommandContext(
+		ctx, "log", "--stdin", "--format=%ct %H %T", "--raw", "-t", "-m", "--root",
+		"--no-renames", "--no-abbrev",
+	)
+	cmd.Stdin = revs
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	record := func(oid OID, t int64) {
+		if oldT, ok := times[oid]; !ok || t < oldT {
+			times[oid] = t
+		}
+	}
+
+	var t int64
+	scanner := bufio.NewScanner(out)
+	scanner.Buffer(nil, 1024*1024)
+	parseErr := func() error {
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case line == "":
+			case line[0] == ':':
+				// The line has the form
+				//
+				//	:<old mode> <new mode> <old OID> <new OID> <status>\t<path>
+				words := strings.Fields(strings.SplitN(line[1:], "\t", 2)[0])
+				if len(words) != 5 {
+					return fmt.Errorf("unexpected output from 'git log': %q", line)
+				}
+				if words[1] == "160000" {
+					// A submodule's commit.
+					continue
+				}
+				oid, err := NewOID(words[3])
+				if err != nil {
+					return fmt.Errorf("unexpected output from 'git log': %q", line)
+				}
+				if oid != NullOID {
+					record(oid, t)
+				}
+			default:
+				words := strings.Split(line, " ")
+				if len(words) != 3 {
+					return fmt.Errorf("unexpected output from 'git log': %q", line)
+				}
+				var err error
+				t, err = strconv.ParseInt(words[0], 10, 64)
+				if err != nil {
+					return fmt.Errorf("unexpected output from 'git log': %q", line)
+				}
+				for _, word := range words[1:] {
+					oid, err := NewOID(word)
+					if err != nil {
+						return fmt.Errorf("unexpected output from 'git log': %q", line)
+					}
+					record(oid, t)
+				}
+			}
+		}
+		return scanner.Err()
+	}()
+
+	if parseErr != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, parseErr
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("listing the history of %s: %w", repo.GitDir(), err)
+	}
+	return times, nil
+}
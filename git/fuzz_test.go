@@ -0,0 +1,139 @@
+//go:build go1.18
+// +build go1.18
+
+package git
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func FuzzParseReference(f *testing.F) {
+	f.Add("6fc39af32cfa576495b52db5841d1be2832fc00b commit 112 1112911993 refs/heads/master")
+	f.Add("6fc39af32cfa576495b52db5841d1be2832fc00b blob 3  refs/tags/blob")
+	f.Add("6fc39af32cfa576495b52db5841d1be2832fc00b commit 112 1112911993 refs/heads/a b\nc")
+	f.Add("6fc39af32cfa576495b52db5841d1be2832fc00b commit 112 refs/heads/master")
+
+	f.Fuzz(func(t *testing.T, line string) {
+		ref, err := ParseReference(line)
+		if err != nil {
+			return
+		}
+		if !strings.HasSuffix(line, " "+ref.Refname) {
+			t.Errorf("refname %q is not the end of %q", ref.Refname, line)
+		}
+		if !strings.EqualFold(line[:len(ref.OID.String())+1], ref.OID.String()+" ") {
+			t.Errorf("OID %s is not the start of %q", ref.OID, line)
+		}
+	})
+}
+
+func FuzzRevListObjectName(f *testing.F) {
+	f.Add([]byte("6fc39af32cfa576495b52db5841d1be2832fc00b"))
+	f.Add([]byte("6fc39af32cfa576495b52db5841d1be2832fc00b path/to/file"))
+	f.Add([]byte("6fc39af32cfa576495b52db5841d1be2832fc00bextra"))
+	f.Add([]byte("the rest of a path"))
+
+	f.Fuzz(func(t *testing.T, line []byte) {
+		name, ok := revListObjectName(line, 40)
+		if !ok {
+			return
+		}
+		if !bytes.HasPrefix(line, name) {
+			t.Errorf("name %q is not the start of %q", name, line)
+		}
+		if _, err := NewOID(string(name)); err != nil {
+			t.Errorf("name %q is not a valid OID: %v", name, err)
+		}
+	})
+}
+
+// fuzzOID is the OID that the fuzz targets pass to the parsers, for
+// their error messages.
+var fuzzOID = OID{v: [20]byte{0x6f, 0xc3, 0x9a, 0xf3}}
+
+func FuzzParseTree(f *testing.F) {
+	f.Add([]byte("100644 README\x00" + strings.Repeat("\x01", 20)))
+	f.Add([]byte("40000 dir\x00" + strings.Repeat("\x02", 20) + "120000 link\x00" + strings.Repeat("\x03", 20)))
+	f.Add([]byte("100644 truncated\x00\x01\x02"))
+	f.Add([]byte("999 bad-mode\x00" + strings.Repeat("\x01", 20)))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		tree, err := ParseTree(fuzzOID, data)
+		if err != nil {
+			return
+		}
+		iter := tree.Iter()
+		for {
+			_, ok, err := iter.NextEntry()
+			if err != nil {
+				if !strings.Contains(err.Error(), fuzzOID.String()) {
+					t.Errorf("error %q doesn't name the tree", err)
+				}
+				return
+			}
+			if !ok {
+				return
+			}
+		}
+	})
+}
+
+func FuzzParseCommit(f *testing.F) {
+	f.Add([]byte(
+		"tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n" +
+			"parent 6fc39af32cfa576495b52db5841d1be2832fc00b\n" +
+			"author A U Thor <author@example.com> 1112911993 -0700\n" +
+			"committer C O Mitter <committer@example.com> 1112911993 -0700\n" +
+			"\nThe log message\n",
+	))
+	f.Add([]byte("tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n"))
+	f.Add([]byte("tree nonsense\n\n"))
+	f.Add([]byte(""))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		commit, err := ParseCommit(fuzzOID, data)
+		if err != nil {
+			return
+		}
+		if int(commit.HeaderSize) > len(data) {
+			t.Errorf("header size %d exceeds object size %d", commit.HeaderSize, len(data))
+		}
+	})
+}
+
+func FuzzParseTag(f *testing.F) {
+	f.Add([]byte(
+		"object 6fc39af32cfa576495b52db5841d1be2832fc00b\n" +
+			"type commit\n" +
+			"tag v1.0\n" +
+			"tagger T A Gger <tagger@example.com> 1112911993 -0700\n" +
+			"\nRelease 1.0\n",
+	))
+	f.Add([]byte("object 6fc39af32cfa576495b52db5841d1be2832fc00b\ntype commit\n"))
+	f.Add([]byte("object 6fc39af32cfa576495b52db5841d1be2832fc00b\n"))
+	f.Add([]byte("\n\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		_, _ = ParseTag(fuzzOID, data)
+	})
+}
+
+func FuzzParseBatchHeader(f *testing.F) {
+	f.Add("6fc39af32cfa576495b52db5841d1be2832fc00b commit 112\n")
+	f.Add("6fc39af32cfa576495b52db5841d1be2832fc00b blob 5000000000 4096\x00")
+	f.Add("HEAD missing\n")
+	f.Add("")
+	f.Add("\n")
+
+	f.Fuzz(func(t *testing.T, header string) {
+		bh, err := ParseBatchHeader("", header)
+		if err != nil {
+			return
+		}
+		if !strings.EqualFold(header[:41], bh.OID.String()+" ") {
+			t.Errorf("OID %s is not the start of %q", bh.OID, header)
+		}
+	})
+}
@@ -1,19 +1,34 @@
+// Package git reads objects, references, and configuration from a Git
+// repository by running `git` subprocesses. See "Using git-sizer as a
+// library" in README.md for the compatibility guarantees.
+//
+// Functions and methods that take a `context.Context` kill the `git`
+// commands that they run if it is done before they finish. A few
+// older methods have a `...Context()` variant that takes one; the
+// original runs its commands under `context.Background()`.
 package git
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sync"
 )
 
 // ObjectType represents the type of a Git object ("blob", "tree",
 // "commit", "tag", or "missing").
 type ObjectType string
 
+// ErrShallowClone is returned when opening a repository that is a
+// shallow clone, unless `RepositoryOptions.AllowShallow` is set.
+var ErrShallowClone = errors.New("this appears to be a shallow clone; full clone required")
+
 // Repository represents a Git repository on disk.
 type Repository struct {
 	// gitDir is the path to the `GIT_DIR` for this repository. It
@@ -24,6 +39,45 @@ type Repository struct {
 	// gitBin is the path of the `git` executable that should be used
 	// when running commands in this repository.
 	gitBin string
+
+	// batchOptions tune the pipelines used by the object iterators.
+	// See `SetBatchOptions()`.
+	batchOptions BatchOptions
+
+	// sizeOracle, if set, is used by the object iterators instead of
+	// `git cat-file --batch-check`. See `SetSizeOracle()`.
+	sizeOracle SizeOracle
+
+	// refBackend selects how references are read. See
+	// `SetRefBackend()`.
+	refBackend RefBackend
+
+	// processWrapper is the command (e.g., `nice -n 10`), if any,
+	// that `git` commands are run under, and processConfig holds
+	// configuration options that are passed to them. See
+	// `SetProcessOptions()`.
+	processWrapper []string
+	processConfig  []string
+
+	// env holds environment variables that are set for the `git`
+	// commands, in addition to the ones that are always set. See
+	// `SetQuarantine()`.
+	env []string
+
+	// capabilities records which optional features `git` supports.
+	// It is set by `Capabilities()`.
+	capabilitiesOnce sync.Once
+	capabilities     Capabilities
+
+	// objectFormat is the hash algorithm that the repository uses.
+	// It is set by `ObjectFormat()`.
+	objectFormatOnce sync.Once
+	objectFormat     ObjectFormat
+
+	// debugLog, if set, is where the `git` commands are logged. See
+	// `SetDebugLog()`.
+	debugLock sync.Mutex
+	debugLog  io.Writer
 }
 
 // smartJoin returns `relPath` if it is an absolute path. If not, it
@@ -39,8 +93,26 @@ func smartJoin(path, relPath string) string {
 
 // NewRepositoryFromGitDir creates a new `Repository` object that can
 // be used for running `git` commands, given the value of `GIT_DIR`
-// for the repository.
+// for the repository. If `gitDir` can't be used as a repository, the
+// error is a `*RepositoryError` that says why.
 func NewRepositoryFromGitDir(gitDir string) (*Repository, error) {
+	return NewRepositoryFromGitDirWithOptions(context.Background(), gitDir, RepositoryOptions{})
+}
+
+// RepositoryOptions holds options that affect how a `Repository` is
+// opened.
+type RepositoryOptions struct {
+	// AllowShallow, if set, allows a shallow clone to be opened. By
+	// default that is an error, because the history of a shallow
+	// clone is incomplete, so statistics about it are misleading.
+	AllowShallow bool
+}
+
+// NewRepositoryFromGitDirWithOptions is like
+// `NewRepositoryFromGitDir()`, but with the options in `opts`.
+func NewRepositoryFromGitDirWithOptions(
+	ctx context.Context, gitDir string, opts RepositoryOptions,
+) (*Repository, error) {
 	// Find the `git` executable to be used:
 	gitBin, err := findGitBin()
 	if err != nil {
@@ -54,12 +126,18 @@ func NewRepositoryFromGitDir(gitDir string) (*Repository, error) {
 		gitBin: gitBin,
 	}
 
-	full, err := repo.IsFull()
-	if err != nil {
-		return nil, fmt.Errorf("determining whether the repository is a full clone: %w", err)
+	if err := repo.validate(ctx); err != nil {
+		return nil, err
 	}
-	if !full {
-		return nil, errors.New("this appears to be a shallow clone; full clone required")
+
+	if !opts.AllowShallow {
+		full, err := repo.isFull(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("determining whether the repository is a full clone: %w", err)
+		}
+		if !full {
+			return nil, ErrShallowClone
+		}
 	}
 
 	return &repo, nil
@@ -68,8 +146,17 @@ func NewRepositoryFromGitDir(gitDir string) (*Repository, error) {
 // NewRepositoryFromPath creates a new `Repository` object that can be
 // used for running `git` commands within `path`. It does so by asking
 // `git` what `GIT_DIR` to use. Git, in turn, bases its decision on
-// the path and the environment.
+// the path and the environment. If no usable repository is found,
+// the error is a `*RepositoryError` that says why.
 func NewRepositoryFromPath(path string) (*Repository, error) {
+	return NewRepositoryFromPathWithOptions(context.Background(), path, RepositoryOptions{})
+}
+
+// NewRepositoryFromPathWithOptions is like `NewRepositoryFromPath()`,
+// but with the options in `opts`.
+func NewRepositoryFromPathWithOptions(
+	ctx context.Context, path string, opts RepositoryOptions,
+) (*Repository, error) {
 	gitBin, err := findGitBin()
 	if err != nil {
 		return nil, fmt.Errorf(
@@ -77,9 +164,17 @@ func NewRepositoryFromPath(path string) (*Repository, error) {
 		)
 	}
 
+	// `git -C` would fail for these, too, but with a less helpful
+	// message:
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrPermission) {
+		return nil, &RepositoryError{Path: path, Kind: ErrPermissionDenied, Err: err}
+	} else if err != nil {
+		return nil, &RepositoryError{Path: path, Kind: ErrNotRepository, Err: err}
+	}
+
 	//nolint:gosec // `gitBin` is chosen carefully, and `path` is the
 	// path to the repository.
-	cmd := exec.Command(gitBin, "-C", path, "rev-parse", "--git-dir")
+	cmd := exec.CommandContext(ctx, gitBin, "-C", path, "rev-parse", "--git-dir")
 	out, err := cmd.Output()
 	if err != nil {
 		switch err := err.(type) {
@@ -88,6 +183,9 @@ func NewRepositoryFromPath(path string) (*Repository, error) {
 				"could not run '%s': %w", gitBin, err.Err,
 			)
 		case *exec.ExitError:
+			if repoErr := classifyGitError(path, false, err.Stderr); repoErr != nil {
+				return nil, repoErr
+			}
 			return nil, fmt.Errorf(
 				"git rev-parse failed: %s", err.Stderr,
 			)
@@ -97,12 +195,45 @@ func NewRepositoryFromPath(path string) (*Repository, error) {
 	}
 	gitDir := smartJoin(path, string(bytes.TrimSpace(out)))
 
-	return NewRepositoryFromGitDir(gitDir)
+	return NewRepositoryFromGitDirWithOptions(ctx, gitDir, opts)
+}
+
+// validate checks that `git` can use `repo.gitDir` as a repository,
+// and that its object store exists. If not, it returns a
+// `*RepositoryError` that says why.
+func (repo *Repository) validate(ctx context.Context) error {
+	cmd := repo.newGitCommand(ctx, "rev-parse", "--git-path", "objects")
+	out, err := cmd.Output()
+	if err != nil {
+		switch err := err.(type) {
+		case *exec.Error:
+			return fmt.Errorf(
+				"could not run '%s': %w", repo.gitBin, err.Err,
+			)
+		case *exec.ExitError:
+			if repoErr := classifyGitError(repo.gitDir, true, err.Stderr); repoErr != nil {
+				return repoErr
+			}
+			return fmt.Errorf(
+				"git rev-parse failed: %s", err.Stderr,
+			)
+		default:
+			return err
+		}
+	}
+
+	// `git` doesn't check this for a linked worktree or if
+	// `GIT_OBJECT_DIRECTORY` is set:
+	return checkObjects(repo.gitDir, string(bytes.TrimSpace(out)))
 }
 
 // IsFull returns `true` iff `repo` appears to be a full clone.
 func (repo *Repository) IsFull() (bool, error) {
-	shallow, err := repo.GitPath("shallow")
+	return repo.isFull(context.Background())
+}
+
+func (repo *Repository) isFull(ctx context.Context) (bool, error) {
+	shallow, err := repo.gitPath(ctx, "shallow")
 	if err != nil {
 		return false, err
 	}
@@ -121,7 +252,22 @@ func (repo *Repository) IsFull() (bool, error) {
 	return true, nil
 }
 
+// GitCommand returns a command that runs `git` with `callerArgs` in
+// `repo`.
 func (repo *Repository) GitCommand(callerArgs ...string) *exec.Cmd {
+	return repo.GitCommandContext(context.Background(), callerArgs...)
+}
+
+// GitCommandContext is like `GitCommand()`, but takes a context.
+func (repo *Repository) GitCommandContext(ctx context.Context, callerArgs ...string) *exec.Cmd {
+	cmd := repo.newGitCommand(ctx, callerArgs...)
+	repo.logCommand("command", cmd)
+	return cmd
+}
+
+// newGitCommand is like `GitCommandContext()`, but it doesn't write
+// the command to the debug log.
+func (repo *Repository) newGitCommand(ctx context.Context, callerArgs ...string) *exec.Cmd {
 	args := []string{
 		// Disable replace references when running our commands:
 		"--no-replace-objects",
@@ -132,22 +278,40 @@ func (repo *Repository) GitCommand(callerArgs ...string) *exec.Cmd {
 		"-c", "advice.graftFileDeprecated=false",
 	}
 
+	args = append(args, repo.processConfig...)
 	args = append(args, callerArgs...)
 
-	//nolint:gosec // `gitBin` is chosen carefully, and the rest of
-	// the args have been checked.
-	cmd := exec.Command(repo.gitBin, args...)
+	name := repo.gitBin
+	if len(repo.processWrapper) != 0 {
+		// The wrapper `exec`s `git`, so it is `git` that is killed
+		// if `ctx` is canceled:
+		name = repo.processWrapper[0]
+		wrapperArgs := make([]string, 0, len(repo.processWrapper)+len(args))
+		wrapperArgs = append(wrapperArgs, repo.processWrapper[1:]...)
+		wrapperArgs = append(wrapperArgs, repo.gitBin)
+		args = append(wrapperArgs, args...)
+	}
 
-	cmd.Env = append(
-		os.Environ(),
-		"GIT_DIR="+repo.gitDir,
-		// Disable grafts when running our commands:
-		"GIT_GRAFT_FILE="+os.DevNull,
-	)
+	//nolint:gosec // `gitBin` and the wrapper are chosen carefully,
+	// and the rest of the args have been checked.
+	cmd := exec.CommandContext(ctx, name, args...)
+
+	cmd.Env = append(os.Environ(), repo.commandEnv()...)
 
 	return cmd
 }
 
+// commandEnv returns the environment variables that are set for the
+// `git` commands run in `repo`, in addition to the inherited ones.
+func (repo *Repository) commandEnv() []string {
+	env := []string{
+		"GIT_DIR=" + repo.gitDir,
+		// Disable grafts when running our commands:
+		"GIT_GRAFT_FILE=" + os.DevNull,
+	}
+	return append(env, repo.env...)
+}
+
 // GitDir returns the path to `repo`'s `GIT_DIR`. It might be absolute
 // or it might be relative to the current directory.
 func (repo *Repository) GitDir() string {
@@ -158,7 +322,11 @@ func (repo *Repository) GitDir() string {
 // calling `git rev-parse --git-path $relPath`. The returned path is
 // relative to the current directory.
 func (repo *Repository) GitPath(relPath string) (string, error) {
-	cmd := repo.GitCommand("rev-parse", "--git-path", relPath)
+	return repo.gitPath(context.Background(), relPath)
+}
+
+func (repo *Repository) gitPath(ctx context.Context, relPath string) (string, error) {
+	cmd := repo.GitCommandContext(ctx, "rev-parse", "--git-path", relPath)
 	out, err := cmd.Output()
 	if err != nil {
 		return "", fmt.Errorf(
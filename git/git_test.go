@@ -0,0 +1,270 @@
+package git_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cli/safeexec"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/internal/testutils"
+)
+
+func TestCanceledContext(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "canceled-context")
+	defer testRepo.Remove(t)
+
+	repo := testRepo.Repository(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := repo.ConfigStringDefaultContext(ctx, "sizer.names", "full")
+	assert.Error(t, err)
+
+	_, err = repo.ResolveObjectContext(ctx, "HEAD")
+	assert.Error(t, err)
+
+	_, err = git.NewRepositoryFromPathWithOptions(ctx, testRepo.Path, git.RepositoryOptions{})
+	assert.Error(t, err)
+
+	v, err := repo.ConfigStringDefaultContext(context.Background(), "sizer.names", "full")
+	assert.NoError(t, err)
+	assert.Equal(t, "full", v)
+}
+
+func TestCapabilities(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "capabilities")
+	defer testRepo.Remove(t)
+
+	repo := testRepo.Repository(t)
+	ctx := context.Background()
+
+	capabilities := repo.Capabilities(ctx)
+	assert.NotEmpty(t, capabilities.Version)
+
+	cmd := testRepo.GitCommand(t, "cat-file", "--batch-command", "--buffer")
+	assert.Equal(t, cmd.Run() == nil, capabilities.BatchCommand)
+
+	cmd = testRepo.GitCommand(t, "cat-file", "-Z", "--batch-check")
+	assert.Equal(t, cmd.Run() == nil, capabilities.CatFileNUL)
+
+	// The answer is cached, even if the context has been canceled
+	// since:
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	assert.Equal(t, capabilities, repo.Capabilities(canceled))
+}
+
+func TestProcessOptions(t *testing.T) {
+	t.Parallel()
+
+	if _, err := safeexec.LookPath("nice"); err != nil {
+		t.Skip("'nice' is not available")
+	}
+
+	testRepo := testutils.NewTestRepo(t, false, "process-options")
+	defer testRepo.Remove(t)
+
+	repo := testRepo.Repository(t)
+	ctx := context.Background()
+
+	require.NoError(t, repo.SetProcessOptions(git.ProcessOptions{Nice: 5, Threads: 2}))
+
+	cmd := repo.GitCommandContext(ctx, "config", "pack.threads")
+	assert.Equal(t, "nice", filepath.Base(cmd.Path))
+	assert.Equal(t, []string{"-n", "5"}, cmd.Args[1:3])
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Equal(t, "2\n", string(out))
+
+	// The zero value removes the limits again:
+	require.NoError(t, repo.SetProcessOptions(git.ProcessOptions{}))
+	cmd = repo.GitCommandContext(ctx, "config", "pack.threads")
+	assert.NotEqual(t, "nice", filepath.Base(cmd.Path))
+}
+
+func TestDebugLog(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "debug-log")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", "hello\n")
+	testRepo.Commit(t, "-m", "it's a commit")
+
+	repo := testRepo.Repository(t)
+	var log bytes.Buffer
+	repo.SetDebugLog(&log)
+
+	// A command whose arguments have to be quoted can be reproduced
+	// by pasting the logged line into a shell:
+	expected, err := repo.GitCommand("log", "--format=%s (by %an)").Output()
+	require.NoError(t, err)
+
+	line, err := log.ReadString('\n')
+	require.NoError(t, err)
+	require.True(t, strings.HasPrefix(line, "git-sizer: command: GIT_DIR="), "%q", line)
+	line = strings.TrimPrefix(line, "git-sizer: command: ")
+	assert.Contains(t, line, `'--format=%s (by %an)'`)
+
+	sh, err := safeexec.LookPath("sh")
+	require.NoError(t, err)
+	output, err := exec.Command(sh, "-c", line).Output()
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(output))
+
+	// The stages of pipelines are labeled:
+	iter, err := repo.NewBatchObjectIter(context.Background())
+	require.NoError(t, err)
+	iter.Close()
+	for {
+		_, ok, err := iter.Next()
+		require.NoError(t, err)
+		if !ok {
+			break
+		}
+	}
+	assert.Contains(t, log.String(), "git-sizer: pipeline stage git-cat-file: GIT_DIR=")
+
+	repo.SetDebugLog(nil)
+	log.Reset()
+	_, err = repo.GitCommand("version").Output()
+	require.NoError(t, err)
+	assert.Empty(t, log.String())
+}
+
+func TestRepositoryErrors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	opts := git.RepositoryOptions{AllowShallow: true}
+
+	// brokenRepo returns a new repository and its `GIT_DIR`, after
+	// `breakIt` has been applied to the latter:
+	brokenRepo := func(t *testing.T, breakIt func(gitDir string)) (*testutils.TestRepo, string) {
+		t.Helper()
+
+		testRepo := testutils.NewTestRepo(t, false, "repository-errors")
+		t.Cleanup(func() { testRepo.Remove(t) })
+		gitDir := filepath.Join(testRepo.Path, ".git")
+		require.NoError(t, os.Mkdir(filepath.Join(testRepo.Path, "subdir"), 0o755))
+		breakIt(gitDir)
+		return testRepo, gitDir
+	}
+
+	// checkErr checks that `err` is a `*git.RepositoryError` of the
+	// specified `kind`, with a hint.
+	checkErr := func(t *testing.T, err error, kind error) {
+		t.Helper()
+
+		require.Error(t, err)
+		var repoErr *git.RepositoryError
+		require.True(t, errors.As(err, &repoErr), "%v", err)
+		assert.ErrorIs(t, err, kind)
+		assert.Contains(t, err.Error(), "\nhint: ")
+	}
+
+	t.Run("not-a-repository", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+
+		_, err := git.NewRepositoryFromPathWithOptions(ctx, dir, opts)
+		checkErr(t, err, git.ErrNotRepository)
+
+		_, err = git.NewRepositoryFromPathWithOptions(ctx, filepath.Join(dir, "nonexistent"), opts)
+		checkErr(t, err, git.ErrNotRepository)
+
+		_, err = git.NewRepositoryFromGitDirWithOptions(ctx, dir, opts)
+		checkErr(t, err, git.ErrNotRepository)
+	})
+
+	for _, p := range []struct {
+		name    string
+		breakIt func(gitDir string)
+		kind    error
+	}{
+		{
+			name: "corrupt-head",
+			breakIt: func(gitDir string) {
+				require.NoError(t, os.WriteFile(filepath.Join(gitDir, "HEAD"), []byte("garbage\n"), 0o644))
+			},
+			kind: git.ErrCorruptHead,
+		},
+		{
+			name: "missing-head",
+			breakIt: func(gitDir string) {
+				require.NoError(t, os.Remove(filepath.Join(gitDir, "HEAD")))
+			},
+			kind: git.ErrCorruptHead,
+		},
+		{
+			name: "corrupt-config",
+			breakIt: func(gitDir string) {
+				f, err := os.OpenFile(filepath.Join(gitDir, "config"), os.O_APPEND|os.O_WRONLY, 0)
+				require.NoError(t, err)
+				_, err = f.WriteString("[core\n")
+				require.NoError(t, err)
+				require.NoError(t, f.Close())
+			},
+			kind: git.ErrCorruptConfig,
+		},
+		{
+			name: "missing-objects",
+			breakIt: func(gitDir string) {
+				require.NoError(t, os.RemoveAll(filepath.Join(gitDir, "objects")))
+			},
+			kind: git.ErrMissingObjects,
+		},
+	} {
+		p := p
+		t.Run(p.name, func(t *testing.T) {
+			t.Parallel()
+
+			testRepo, gitDir := brokenRepo(t, p.breakIt)
+
+			_, err := git.NewRepositoryFromPathWithOptions(ctx, testRepo.Path, opts)
+			checkErr(t, err, p.kind)
+
+			_, err = git.NewRepositoryFromPathWithOptions(ctx, filepath.Join(testRepo.Path, "subdir"), opts)
+			checkErr(t, err, p.kind)
+
+			_, err = git.NewRepositoryFromGitDirWithOptions(ctx, gitDir, opts)
+			checkErr(t, err, p.kind)
+		})
+	}
+
+	t.Run("permission-denied", func(t *testing.T) {
+		t.Parallel()
+
+		if os.Geteuid() == 0 {
+			t.Skip("permissions aren't enforced for root")
+		}
+
+		testRepo, gitDir := brokenRepo(t, func(gitDir string) {
+			require.NoError(t, os.Chmod(gitDir, 0))
+		})
+		defer func() {
+			require.NoError(t, os.Chmod(gitDir, 0o755))
+		}()
+
+		_, err := git.NewRepositoryFromPathWithOptions(ctx, testRepo.Path, opts)
+		checkErr(t, err, git.ErrPermissionDenied)
+
+		_, err = git.NewRepositoryFromGitDirWithOptions(ctx, gitDir, opts)
+		checkErr(t, err, git.ErrPermissionDenied)
+	})
+}
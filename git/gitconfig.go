@@ -2,6 +2,7 @@ package git
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"os/exec"
@@ -37,7 +38,12 @@ type Config struct {
 // `configKeyMatchesPrefix()`), and strip off the prefix in the keys
 // that are returned.
 func (repo *Repository) GetConfig(prefix string) (*Config, error) {
-	cmd := repo.GitCommand("config", "--list", "-z")
+	return repo.GetConfigContext(context.Background(), prefix)
+}
+
+// GetConfigContext is like `GetConfig()`, but takes a context.
+func (repo *Repository) GetConfigContext(ctx context.Context, prefix string) (*Config, error) {
+	cmd := repo.GitCommandContext(ctx, "config", "--list", "-z")
 
 	out, err := cmd.Output()
 	if err != nil {
@@ -114,11 +120,21 @@ func configKeyMatchesPrefix(key, prefix string) (bool, string) {
 	return false, ""
 }
 
+// ConfigStringDefault returns the value of the gitconfig setting `key`, or
+// `defaultValue` if it is not set.
 func (repo *Repository) ConfigStringDefault(key string, defaultValue string) (string, error) {
+	return repo.ConfigStringDefaultContext(context.Background(), key, defaultValue)
+}
+
+// ConfigStringDefaultContext is like `ConfigStringDefault()`, but takes a
+// context.
+func (repo *Repository) ConfigStringDefaultContext(
+	ctx context.Context, key string, defaultValue string,
+) (string, error) {
 	// Note that `git config --get` didn't get `--default` until Git
 	// 2.18 (released 2018-06-21).
-	cmd := repo.GitCommand(
-		"config", "--get", key,
+	cmd := repo.GitCommandContext(
+		ctx, "config", "--get", key,
 	)
 
 	out, err := cmd.Output()
@@ -137,11 +153,21 @@ func (repo *Repository) ConfigStringDefault(key string, defaultValue string) (st
 	return string(out), nil
 }
 
+// ConfigBoolDefault returns the value of the gitconfig setting `key`, or
+// `defaultValue` if it is not set.
 func (repo *Repository) ConfigBoolDefault(key string, defaultValue bool) (bool, error) {
+	return repo.ConfigBoolDefaultContext(context.Background(), key, defaultValue)
+}
+
+// ConfigBoolDefaultContext is like `ConfigBoolDefault()`, but takes a
+// context.
+func (repo *Repository) ConfigBoolDefaultContext(
+	ctx context.Context, key string, defaultValue bool,
+) (bool, error) {
 	// Note that `git config --get` didn't get `--type=bool` or
 	// `--default` until Git 2.18 (released 2018-06-21).
-	cmd := repo.GitCommand(
-		"config", "--get", "--bool", key,
+	cmd := repo.GitCommandContext(
+		ctx, "config", "--get", "--bool", key,
 	)
 
 	out, err := cmd.Output()
@@ -162,11 +188,21 @@ func (repo *Repository) ConfigBoolDefault(key string, defaultValue bool) (bool,
 	return value, nil
 }
 
+// ConfigIntDefault returns the value of the gitconfig setting `key`, or
+// `defaultValue` if it is not set.
 func (repo *Repository) ConfigIntDefault(key string, defaultValue int) (int, error) {
+	return repo.ConfigIntDefaultContext(context.Background(), key, defaultValue)
+}
+
+// ConfigIntDefaultContext is like `ConfigIntDefault()`, but takes a
+// context.
+func (repo *Repository) ConfigIntDefaultContext(
+	ctx context.Context, key string, defaultValue int,
+) (int, error) {
 	// Note that `git config --get` didn't get `--type=int` or
 	// `--default` until Git 2.18 (released 2018-06-21).
-	cmd := repo.GitCommand(
-		"config", "--get", "--int", key,
+	cmd := repo.GitCommandContext(
+		ctx, "config", "--get", "--int", key,
 	)
 
 	out, err := cmd.Output()
@@ -0,0 +1,61 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+)
+
+// Head describes what `HEAD` refers to.
+type Head struct {
+	// Target is the reference that `HEAD` points at, or the empty
+	// string if `HEAD` is detached.
+	Target string `json:"target,omitempty"`
+
+	// OID is the object that `HEAD` resolves to, or `NullOID` if
+	// `HEAD` is unborn; i.e., if `Target` doesn't exist (yet).
+	OID OID `json:"oid"`
+}
+
+// Detached returns true iff `HEAD` points directly at an object
+// rather than at a reference.
+func (h Head) Detached() bool {
+	return h.Target == ""
+}
+
+// Unborn returns true iff `HEAD` points at a reference that doesn't
+// exist, as in a new repository, or a bare repository whose `HEAD`
+// names a branch that was never pushed.
+func (h Head) Unborn() bool {
+	return h.OID == NullOID
+}
+
+// ResolveHead determines what `HEAD` refers to in `repo`.
+func (repo *Repository) ResolveHead(ctx context.Context) (Head, error) {
+	var head Head
+
+	cmd := repo.GitCommandContext(ctx, "symbolic-ref", "-q", "HEAD")
+	out, err := cmd.Output()
+	if err == nil {
+		head.Target = string(bytes.TrimSpace(out))
+	} else if err, ok := err.(*exec.ExitError); !ok || err.ExitCode() != 1 {
+		return Head{}, fmt.Errorf("reading HEAD: %w", err)
+	}
+	// Otherwise, `HEAD` is detached.
+
+	cmd = repo.GitCommandContext(ctx, "rev-parse", "-q", "--verify", "HEAD")
+	out, err = cmd.Output()
+	if err != nil {
+		if err, ok := err.(*exec.ExitError); ok && err.ExitCode() == 1 {
+			// `HEAD` is unborn.
+			return head, nil
+		}
+		return Head{}, fmt.Errorf("resolving HEAD: %w", err)
+	}
+	head.OID, err = NewOID(string(bytes.TrimSpace(out)))
+	if err != nil {
+		return Head{}, fmt.Errorf("parsing output %q from 'rev-parse': %w", out, err)
+	}
+	return head, nil
+}
@@ -0,0 +1,80 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// IndexEntry is an entry of the index, recording that the object
+// `OID` is staged at `Path`.
+type IndexEntry struct {
+	Path string
+
+	// Stage is 0 for a normal entry, or 1, 2, or 3 for the base,
+	// "ours", and "theirs" versions of a file with a merge conflict.
+	Stage int
+
+	OID OID
+}
+
+// IndexEntries returns the entries of `repo`'s index, as listed by
+// `git ls-files --stage`, except for submodules, whose commits aren't
+// stored in `repo`. If there is no index (e.g., because `repo` is
+// bare), the result is empty.
+func (repo *Repository) IndexEntries(ctx context.Context) ([]IndexEntry, error) {
+	indexPath, err := repo.gitPath(ctx, "index")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(indexPath); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	cmd := repo.GitCommandContext(ctx, "ls-files", "--stage", "-z")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("reading index: %w", err)
+	}
+
+	var entries []IndexEntry
+	for _, line := range bytes.Split(out, []byte{0}) {
+		if len(line) == 0 {
+			continue
+		}
+
+		// Each entry has the form
+		//
+		//     <mode> SP <oid> SP <stage> TAB <path>
+		i := bytes.IndexByte(line, '\t')
+		if i == -1 {
+			return nil, fmt.Errorf("unexpected output from 'git ls-files': %q", line)
+		}
+		words := strings.Split(string(line[:i]), " ")
+		if len(words) != 3 {
+			return nil, fmt.Errorf("unexpected output from 'git ls-files': %q", line)
+		}
+		if words[0] == "160000" {
+			continue
+		}
+		oid, err := NewOID(words[1])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected output from 'git ls-files': %q", line)
+		}
+		stage, err := strconv.Atoi(words[2])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected output from 'git ls-files': %q", line)
+		}
+		entries = append(entries, IndexEntry{
+			Path:  string(line[i+1:]),
+			Stage: stage,
+			OID:   oid,
+		})
+	}
+	return entries, nil
+}
@@ -0,0 +1,137 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/github/git-sizer/counts"
+)
+
+// LooseObjects summarizes the loose objects in the repository's
+// object database.
+type LooseObjects struct {
+	// Count is the number of loose objects.
+	Count counts.Count32
+
+	// MaxShard is the fan-out directory (named after the first two
+	// hex digits of the objects' names) that holds the most loose
+	// objects, and MaxShardCount is how many it holds.
+	MaxShard      string
+	MaxShardCount counts.Count32
+
+	// OldestModTime is the modification time of the oldest loose
+	// object, or the zero `time.Time` if there are none.
+	OldestModTime time.Time
+}
+
+// LooseObjects counts the loose objects in `repo`'s object database.
+// It doesn't include objects in alternate object databases.
+func (repo *Repository) LooseObjects(ctx context.Context) (LooseObjects, error) {
+	objectsDir, err := repo.gitPath(ctx, "objects")
+	if err != nil {
+		return LooseObjects{}, err
+	}
+
+	var loose LooseObjects
+	for i := 0; i < 256; i++ {
+		if err := ctx.Err(); err != nil {
+			return LooseObjects{}, err
+		}
+
+		shard := fmt.Sprintf("%02x", i)
+		entries, err := os.ReadDir(filepath.Join(objectsDir, shard))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return LooseObjects{}, fmt.Errorf("reading loose objects: %w", err)
+		}
+
+		var shardCount counts.Count32
+		for _, entry := range entries {
+			if !isLooseObjectName(entry.Name()) {
+				// E.g., a temporary file for an object that is
+				// being written.
+				continue
+			}
+			info, err := entry.Info()
+			if errors.Is(err, fs.ErrNotExist) {
+				// The object was packed or pruned while we were
+				// reading.
+				continue
+			} else if err != nil {
+				return LooseObjects{}, fmt.Errorf("reading loose objects: %w", err)
+			}
+
+			shardCount.Increment(1)
+			if loose.OldestModTime.IsZero() || info.ModTime().Before(loose.OldestModTime) {
+				loose.OldestModTime = info.ModTime()
+			}
+		}
+
+		loose.Count.Increment(shardCount)
+		if shardCount > loose.MaxShardCount {
+			loose.MaxShard = shard
+			loose.MaxShardCount = shardCount
+		}
+	}
+
+	return loose, nil
+}
+
+// LooseObjectIDs returns the names of the loose objects in `repo`'s
+// object database, in order. It doesn't include objects in alternate
+// object databases.
+func (repo *Repository) LooseObjectIDs(ctx context.Context) ([]OID, error) {
+	objectsDir, err := repo.gitPath(ctx, "objects")
+	if err != nil {
+		return nil, err
+	}
+
+	var oids []OID
+	for i := 0; i < 256; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		shard := fmt.Sprintf("%02x", i)
+		entries, err := os.ReadDir(filepath.Join(objectsDir, shard))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("reading loose objects: %w", err)
+		}
+
+		for _, entry := range entries {
+			if !isLooseObjectName(entry.Name()) {
+				continue
+			}
+			oid, err := NewOID(shard + entry.Name())
+			if err != nil {
+				return nil, err
+			}
+			oids = append(oids, oid)
+		}
+	}
+
+	return oids, nil
+}
+
+// isLooseObjectName returns true iff `name` looks like the filename
+// of a loose object within its fan-out directory; i.e., the last 38
+// (for SHA-1) or 62 (for SHA-256) hex digits of its name.
+func isLooseObjectName(name string) bool {
+	if len(name) != 38 && len(name) != 62 {
+		return false
+	}
+	for _, c := range name {
+		if !(('0' <= c && c <= '9') || ('a' <= c && c <= 'f')) {
+			return false
+		}
+	}
+	return true
+}
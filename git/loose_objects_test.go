@@ -0,0 +1,63 @@
+package git_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/internal/testutils"
+)
+
+func TestLooseObjects(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "loose-objects")
+	defer testRepo.Remove(t)
+
+	repo := testRepo.Repository(t)
+	ctx := context.Background()
+
+	loose, err := repo.LooseObjects(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, counts.Count32(0), loose.Count)
+	assert.True(t, loose.OldestModTime.IsZero())
+
+	shardCounts := make(map[string]counts.Count32)
+	var oldestPath string
+	for i := 0; i < 20; i++ {
+		cmd := testRepo.GitCommand(t, "hash-object", "-w", "--stdin")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("blob %d\n", i))
+		out, err := cmd.Output()
+		require.NoError(t, err)
+		hex := strings.TrimSpace(string(out))
+		shardCounts[hex[:2]]++
+		if i == 7 {
+			oldestPath = filepath.Join(testRepo.Path, ".git", "objects", hex[:2], hex[2:])
+		}
+	}
+
+	oldest := time.Unix(1112911993, 0)
+	require.NoError(t, os.Chtimes(oldestPath, oldest, oldest))
+
+	// Files that aren't objects are ignored:
+	require.NoError(t, os.WriteFile(
+		filepath.Join(filepath.Dir(oldestPath), "tmp_obj_abcdef"), []byte("junk"), 0o644,
+	))
+
+	loose, err = repo.LooseObjects(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, counts.Count32(20), loose.Count)
+	assert.Equal(t, shardCounts[loose.MaxShard], loose.MaxShardCount)
+	for _, n := range shardCounts {
+		assert.LessOrEqual(t, n, loose.MaxShardCount)
+	}
+	assert.True(t, oldest.Equal(loose.OldestModTime))
+}
@@ -0,0 +1,66 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// maxNoteSize is the most of a note that `Notes()` reads. Notes that
+// are used to annotate objects are expected to be short.
+const maxNoteSize = 4096
+
+// Notes returns the text of the notes in the notes reference
+// `notesRef` (e.g., "refs/notes/size-exemptions") that are attached
+// to any of the objects in `oids`, keyed by the annotated object. If
+// `notesRef` doesn't exist, it returns an empty map.
+func (repo *Repository) Notes(ctx context.Context, notesRef string, oids []OID) (map[OID]string, error) {
+	notes := make(map[OID]string)
+	if len(oids) == 0 {
+		return notes, nil
+	}
+
+	wanted := make(map[OID]struct{}, len(oids))
+	for _, oid := range oids {
+		wanted[oid] = struct{}{}
+	}
+
+	cmd := repo.GitCommandContext(ctx, "notes", "--ref="+notesRef, "list")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing notes in '%s': %w", notesRef, err)
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		words := strings.Fields(line)
+		if len(words) != 2 {
+			return nil, fmt.Errorf("unexpected output from 'git notes list': %q", line)
+		}
+		noteOID, err := NewOID(words[0])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected output from 'git notes list': %q", line)
+		}
+		oid, err := NewOID(words[1])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected output from 'git notes list': %q", line)
+		}
+		if _, ok := wanted[oid]; !ok {
+			continue
+		}
+
+		text, err := repo.ReadBlobPrefix(ctx, noteOID, maxNoteSize)
+		if err != nil {
+			return nil, fmt.Errorf("reading note for '%s' in '%s': %w", oid, notesRef, err)
+		}
+		notes[oid] = string(bytes.TrimSpace(text))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return notes, nil
+}
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"strings"
+
+	"github.com/github/git-sizer/counts"
 )
 
 // ObjectHeaderIter iterates over the headers within a commit or tag
@@ -11,6 +13,9 @@ import (
 type ObjectHeaderIter struct {
 	name string
 	data string
+
+	// offset is the position of `data` within the object.
+	offset int
 }
 
 // NewObjectHeaderIter returns an `ObjectHeaderIter` that iterates
@@ -31,9 +36,9 @@ func NewObjectHeaderIter(name string, data []byte) (ObjectHeaderIter, error) {
 			return ObjectHeaderIter{}, fmt.Errorf("%s has no terminating LF", name)
 		}
 
-		return ObjectHeaderIter{name, string(data)}, nil
+		return ObjectHeaderIter{name: name, data: string(data)}, nil
 	}
-	return ObjectHeaderIter{name, string(data[:headerEnd+1])}, nil
+	return ObjectHeaderIter{name: name, data: string(data[:headerEnd+1])}, nil
 }
 
 // HasNext returns true iff there are more headers to retrieve.
@@ -49,15 +54,54 @@ func (iter *ObjectHeaderIter) Next() (string, string, error) {
 	header := iter.data
 	keyEnd := strings.IndexByte(header, ' ')
 	if keyEnd == -1 {
-		return "", "", fmt.Errorf("malformed header in %s", iter.name)
+		return "", "", fmt.Errorf("malformed header in %s at offset %d", iter.name, iter.offset)
 	}
 	key := header[:keyEnd]
 	header = header[keyEnd+1:]
 	valueEnd := strings.IndexByte(header, '\n')
 	if valueEnd == -1 {
-		return "", "", fmt.Errorf("malformed header in %s", iter.name)
+		return "", "", fmt.Errorf("malformed header in %s at offset %d", iter.name, iter.offset)
 	}
 	value := header[:valueEnd]
 	iter.data = header[valueEnd+1:]
+	iter.offset += keyEnd + 1 + valueEnd + 1
 	return key, value, nil
 }
+
+// Offset returns the byte offset, within the object, of the header
+// that `Next()` will return next.
+func (iter *ObjectHeaderIter) Offset() int {
+	return iter.offset
+}
+
+// headerCounter counts the nonstandard headers in a commit or tag
+// object. The zero value is ready to use.
+type headerCounter struct {
+	seen        map[string]bool
+	nonstandard counts.Count32
+}
+
+// add records a header with the specified `key`. `standard` maps the
+// keys of the headers that Git writes to whether they may appear more
+// than once. Continuation lines, whose keys are empty, are ignored.
+func (c *headerCounter) add(standard map[string]bool, key string) {
+	if key == "" {
+		return
+	}
+	repeatable, ok := standard[key]
+	if !ok {
+		c.nonstandard.Increment(1)
+		return
+	}
+	if repeatable {
+		return
+	}
+	if c.seen[key] {
+		c.nonstandard.Increment(1)
+		return
+	}
+	if c.seen == nil {
+		c.seen = make(map[string]bool)
+	}
+	c.seen[key] = true
+}
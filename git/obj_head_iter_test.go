@@ -0,0 +1,126 @@
+package git_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+func TestNonstandardHeaders(t *testing.T) {
+	t.Parallel()
+
+	oid, err := git.NewOID("6fc39af32cfa576495b52db5841d1be2832fc00b")
+	require.NoError(t, err)
+
+	const tree = "tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n"
+	const parent = "parent 6fc39af32cfa576495b52db5841d1be2832fc00b\n"
+	const author = "author A U Thor <author@example.com> 1112911993 -0700\n"
+	const committer = "committer C O Mitter <committer@example.com> 1112911993 -0700\n"
+
+	for _, p := range []struct {
+		name        string
+		header      string
+		nonstandard counts.Count32
+	}{
+		{
+			name:   "plain",
+			header: tree + parent + parent + author + committer,
+		},
+		{
+			name: "signed",
+			header: tree + author + committer +
+				"gpgsig -----BEGIN PGP SIGNATURE-----\n" +
+				" \n" +
+				" iQEzBAABCAAdFiEE\n" +
+				" -----END PGP SIGNATURE-----\n",
+		},
+		{
+			name:        "custom",
+			header:      tree + author + committer + "x-build-id 12345\n" + "x-build-log a\n b\n",
+			nonstandard: 2,
+		},
+		{
+			name:        "duplicated",
+			header:      tree + author + author + committer + "encoding UTF-8\n" + "encoding UTF-8\n",
+			nonstandard: 2,
+		},
+	} {
+		p := p
+		t.Run(p.name, func(t *testing.T) {
+			t.Parallel()
+
+			data := []byte(p.header + "\nThe log message\n")
+			commit, err := git.ParseCommit(oid, data)
+			require.NoError(t, err)
+			assert.Equal(t, counts.NewCount32(uint64(len(p.header))), commit.HeaderSize)
+			assert.Equal(t, p.nonstandard, commit.NonstandardHeaderCount)
+			assert.Equal(t, "A U Thor <author@example.com>", commit.Author)
+			assert.Equal(t, "C O Mitter <committer@example.com>", commit.Committer)
+		})
+	}
+
+	tag, err := git.ParseTag(
+		oid,
+		[]byte(
+			"object 6fc39af32cfa576495b52db5841d1be2832fc00b\n"+
+				"type commit\n"+
+				"tag v1.0\n"+
+				"tag v1.0\n"+
+				"tagger T A Gger <tagger@example.com> 1112911993 -0700\n"+
+				"x-release-notes none\n"+
+				"\nRelease 1.0\n",
+		),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, counts.Count32(2), tag.NonstandardHeaderCount)
+}
+
+func TestParseErrors(t *testing.T) {
+	t.Parallel()
+
+	oid, err := git.NewOID("6fc39af32cfa576495b52db5841d1be2832fc00b")
+	require.NoError(t, err)
+
+	_, err = git.ParseCommit(
+		oid,
+		[]byte(
+			"tree 4b825dc642cb6eb9a060e54bf8d69288fbee4904\n"+
+				"parent 6fc39af3\n"+
+				"\nThe log message\n",
+		),
+	)
+	assert.EqualError(
+		t, err,
+		"malformed parent header in commit 6fc39af32cfa576495b52db5841d1be2832fc00b at offset 46",
+	)
+
+	_, err = git.ParseTag(oid, []byte("object 6fc39af32cfa576495b52db5841d1be2832fc00b\ntype"))
+	assert.EqualError(
+		t, err,
+		"tag 6fc39af32cfa576495b52db5841d1be2832fc00b has no terminating LF",
+	)
+
+	_, err = git.ParseTag(oid, []byte("object 6fc39af32cfa576495b52db5841d1be2832fc00b\ntype\n"))
+	assert.EqualError(
+		t, err,
+		"malformed header in tag 6fc39af32cfa576495b52db5841d1be2832fc00b at offset 48",
+	)
+
+	tree, err := git.ParseTree(oid, []byte("100644 README\x00\x01\x02"))
+	require.NoError(t, err)
+	_, _, err = tree.Iter().NextEntry()
+	assert.EqualError(
+		t, err,
+		"malformed tree 6fc39af32cfa576495b52db5841d1be2832fc00b at offset 14: "+
+			"tree entry ends unexpectedly",
+	)
+
+	for _, header := range []string{"", "\n", "6fc39af32cfa576495b52db5841d1be2832fc00b\n"} {
+		_, err := git.ParseBatchHeader("", header)
+		assert.Error(t, err)
+	}
+}
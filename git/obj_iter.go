@@ -2,6 +2,7 @@ package git
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -13,7 +14,7 @@ import (
 type ObjectIter struct {
 	ctx      context.Context
 	p        *pipe.Pipeline
-	oidCh    chan OID
+	revCh    chan string
 	errCh    chan error
 	headerCh chan BatchHeader
 }
@@ -23,29 +24,31 @@ type ObjectIter struct {
 // second return value is the stdin of the `rev-list` command. The
 // caller can feed values into it but must close it in any case.
 func (repo *Repository) NewObjectIter(ctx context.Context) (*ObjectIter, error) {
-	iter := ObjectIter{
-		ctx:      ctx,
-		p:        pipe.New(),
-		oidCh:    make(chan OID),
-		errCh:    make(chan error),
-		headerCh: make(chan BatchHeader),
+	// If possible, have `git rev-list` omit the paths, which might
+	// contain newlines:
+	capabilities := repo.Capabilities(ctx)
+	hexLen := repo.ObjectFormat(ctx).HexLen()
+	revListArgs := []string{"rev-list", "--objects", "--stdin", "--date-order"}
+	if capabilities.RevListNoObjectNames {
+		revListArgs = append(revListArgs, "--no-object-names")
 	}
 
+	iter := newObjectIter(ctx, repo.batchOptions.Window)
 	iter.p.Add(
-		// Read OIDs from `iter.oidCh` and write them to `git
+		// Read revisions from `iter.revCh` and write them to `git
 		// rev-list`:
 		pipe.Function(
 			"request-objects",
 			func(ctx context.Context, _ pipe.Env, _ io.Reader, stdout io.Writer) error {
-				out := bufio.NewWriter(stdout)
+				out := repo.newBatchWriter(stdout)
 
 				for {
 					select {
-					case oid, ok := <-iter.oidCh:
+					case rev, ok := <-iter.revCh:
 						if !ok {
 							return out.Flush()
 						}
-						if _, err := fmt.Fprintln(out, oid.String()); err != nil {
+						if _, err := fmt.Fprintln(out, rev); err != nil {
 							return fmt.Errorf("writing to 'git cat-file': %w", err)
 						}
 					case <-ctx.Done():
@@ -58,35 +61,112 @@ func (repo *Repository) NewObjectIter(ctx context.Context) (*ObjectIter, error)
 		// Walk starting at the OIDs on `stdin` and output the OIDs
 		// (possibly followed by paths) of all of the Git objects
 		// found.
-		pipe.CommandStage(
-			"git-rev-list",
-			repo.GitCommand("rev-list", "--objects", "--stdin", "--date-order"),
-		),
+		repo.commandStage("git-rev-list", revListArgs...),
+	)
 
-		// Read the output of `git rev-list --objects`, strip off any
-		// trailing information, and write the OIDs to `git cat-file`:
-		pipe.LinewiseFunction(
-			"copy-oids",
-			func(_ context.Context, _ pipe.Env, line []byte, stdout *bufio.Writer) error {
-				if len(line) < 40 {
-					return fmt.Errorf("line too short: '%s'", line)
-				}
-				if _, err := stdout.Write(line[:40]); err != nil {
-					return fmt.Errorf("writing OID to 'git cat-file': %w", err)
-				}
-				if err := stdout.WriteByte('\n'); err != nil {
-					return fmt.Errorf("writing LF to 'git cat-file': %w", err)
-				}
-				return nil
+	// Read the output of `git rev-list --objects`, strip off any
+	// trailing information, and write the OIDs to `git cat-file`:
+	objectName := revListObjectNamer(hexLen, !capabilities.RevListNoObjectNames)
+	repo.addBatchCheckStages(ctx, iter, objectName)
+
+	if err := iter.p.Start(ctx); err != nil {
+		return nil, err
+	}
+
+	return iter, nil
+}
+
+// NewObjectIterFromList returns an iterator over the objects listed
+// in `r`, instead of those found by `git rev-list`. Each line of `r`
+// holds the name of an object, optionally followed by a space and a
+// path, as in the output of `git rev-list --objects`; empty lines are
+// ignored, as are objects that were already listed. It is an error if
+// a listed object is missing. The iterator's `AddRoot()` and
+// `ExcludeRoot()` must not be called.
+func (repo *Repository) NewObjectIterFromList(ctx context.Context, r io.Reader) (*ObjectIter, error) {
+	hexLen := repo.ObjectFormat(ctx).HexLen()
+
+	iter := newObjectIter(ctx, repo.batchOptions.Window, pipe.WithStdin(r))
+
+	seen := make(map[string]struct{})
+	objectName := func(line []byte) ([]byte, bool, error) {
+		if len(line) == 0 {
+			return nil, false, nil
+		}
+		name, ok := revListObjectName(line, hexLen)
+		if !ok {
+			return nil, false, fmt.Errorf("malformed line in object list: '%s'", line)
+		}
+		if _, ok := seen[string(name)]; ok {
+			return nil, false, nil
+		}
+		seen[string(name)] = struct{}{}
+		return name, true, nil
+	}
+	repo.addBatchCheckStages(ctx, iter, objectName)
+
+	if err := iter.p.Start(ctx); err != nil {
+		return nil, err
+	}
+
+	return iter, nil
+}
+
+// newObjectIter returns an `ObjectIter` with an empty pipeline, which
+// is created with `options`.
+func newObjectIter(ctx context.Context, window int, options ...pipe.Option) *ObjectIter {
+	return &ObjectIter{
+		ctx:      ctx,
+		p:        pipe.New(options...),
+		revCh:    make(chan string, window),
+		errCh:    make(chan error),
+		headerCh: make(chan BatchHeader, window),
+	}
+}
+
+// addBatchCheckStages adds the stages to `iter`'s pipeline that read
+// lines from the previous stage, extract object names from them
+// using `objectName` (which returns false for lines that should be
+// skipped), look up the objects' headers using `git cat-file` (or
+// the repository's size oracle, if one is set; see
+// `SetSizeOracle()`), and shove the headers into `iter.headerCh`. It
+// is an error if any of the objects is missing.
+func (repo *Repository) addBatchCheckStages(
+	ctx context.Context, iter *ObjectIter, objectName func(line []byte) ([]byte, bool, error),
+) {
+	// If possible, have `git cat-file` use NUL-terminated input and
+	// output:
+	catFileArgs := []string{
+		"cat-file",
+		"--batch-check=%(objectname) %(objecttype) %(objectsize) %(objectsize:disk)",
+		"--buffer",
+	}
+	terminator := byte('\n')
+	var lookUpStage pipe.Stage
+	if repo.sizeOracle != nil {
+		// The size oracle speaks the same protocol, but always
+		// linewise:
+		oracle := repo.sizeOracle
+		lookUpStage = pipe.Function(
+			"size-oracle",
+			func(ctx context.Context, _ pipe.Env, stdin io.Reader, stdout io.Writer) error {
+				return oracle.LookUpObjects(ctx, stdin, stdout)
 			},
-		),
+		)
+	} else {
+		if repo.Capabilities(ctx).CatFileNUL {
+			catFileArgs = append(catFileArgs, "-Z")
+			terminator = 0
+		}
+		lookUpStage = repo.commandStage("git-cat-file", catFileArgs...)
+	}
+
+	iter.p.Add(
+		pipe.LinewiseFunction("copy-oids", copyObjectName(objectName, terminator)),
 
 		// Process the OIDs from stdin and, for each object, output a
-		// header:
-		pipe.CommandStage(
-			"git-cat-file",
-			repo.GitCommand("cat-file", "--batch-check", "--buffer"),
-		),
+		// header, including the object's size on disk:
+		lookUpStage,
 
 		// Parse the object headers and shove them into `headerCh`:
 		pipe.Function(
@@ -94,10 +174,10 @@ func (repo *Repository) NewObjectIter(ctx context.Context) (*ObjectIter, error)
 			func(ctx context.Context, _ pipe.Env, stdin io.Reader, _ io.Writer) error {
 				defer close(iter.headerCh)
 
-				f := bufio.NewReader(stdin)
+				f := repo.newBatchReader(stdin)
 
 				for {
-					header, err := f.ReadString('\n')
+					header, err := f.ReadString(terminator)
 					if err != nil {
 						if err == io.EOF {
 							return nil
@@ -114,18 +194,68 @@ func (repo *Repository) NewObjectIter(ctx context.Context) (*ObjectIter, error)
 			},
 		),
 	)
+}
 
-	if err := iter.p.Start(ctx); err != nil {
-		return nil, err
+// copyObjectName returns a function that extracts the object name
+// from a line using `objectName` and writes it, followed by
+// `terminator`, to the stdin of `git cat-file`. Lines for which
+// `objectName` returns false are skipped.
+func copyObjectName(
+	objectName func(line []byte) ([]byte, bool, error), terminator byte,
+) func(context.Context, pipe.Env, []byte, *bufio.Writer) error {
+	return func(_ context.Context, _ pipe.Env, line []byte, stdout *bufio.Writer) error {
+		name, ok, err := objectName(line)
+		if err != nil || !ok {
+			return err
+		}
+		if _, err := stdout.Write(name); err != nil {
+			return fmt.Errorf("writing OID to 'git cat-file': %w", err)
+		}
+		if err := stdout.WriteByte(terminator); err != nil {
+			return fmt.Errorf("writing terminator to 'git cat-file': %w", err)
+		}
+		return nil
 	}
+}
 
-	return &iter, nil
+// revListObjectNamer returns a function that extracts the object
+// name from a line of `git rev-list --objects` output, whose object
+// names have `hexLen` hex digits. If `withPaths` is false, the output
+// was generated using `--no-object-names`, and any line that doesn't
+// consist of an object name is an error.
+//
+// Otherwise, each object name may be followed by a space and a path.
+// Git cuts each path off at its first newline, so the rest of a path
+// never spills onto a line of its own. Any line that doesn't start
+// with an object name is skipped nevertheless, so that it never
+// reaches `git cat-file`.
+func revListObjectNamer(hexLen int, withPaths bool) func(line []byte) ([]byte, bool, error) {
+	return func(line []byte) ([]byte, bool, error) {
+		name, ok := revListObjectName(line, hexLen)
+		if !ok {
+			if !withPaths {
+				return nil, false, fmt.Errorf("unexpected output from 'git rev-list': '%s'", line)
+			}
+			return nil, false, nil
+		}
+		return name, true, nil
+	}
 }
 
 // AddRoot adds another OID to be included in the walk.
 func (iter *ObjectIter) AddRoot(oid OID) error {
+	return iter.addRev(oid.String())
+}
+
+// ExcludeRoot excludes the objects reachable from `oid` from the
+// walk.
+func (iter *ObjectIter) ExcludeRoot(oid OID) error {
+	return iter.addRev("^" + oid.String())
+}
+
+func (iter *ObjectIter) addRev(rev string) error {
 	select {
-	case iter.oidCh <- oid:
+	case iter.revCh <- rev:
 		return nil
 	case <-iter.ctx.Done():
 		return iter.ctx.Err()
@@ -134,7 +264,7 @@ func (iter *ObjectIter) AddRoot(oid OID) error {
 
 // Close closes the iterator and frees up resources.
 func (iter *ObjectIter) Close() {
-	close(iter.oidCh)
+	close(iter.revCh)
 }
 
 // Next returns either the next object (its OID, type, and size), or a
@@ -146,3 +276,25 @@ func (iter *ObjectIter) Next() (BatchHeader, bool, error) {
 	}
 	return header, true, nil
 }
+
+// revListObjectName returns the object name at the start of `line`, a
+// line of `git rev-list --objects` output, in which the name may be
+// followed by a space and a path. The name must consist of `hexLen`
+// lowercase hex digits, as appropriate for the repository's object
+// format. Anything that doesn't start with an object name followed
+// by a space or the end of the line yields false.
+func revListObjectName(line []byte, hexLen int) ([]byte, bool) {
+	name := line
+	if i := bytes.IndexByte(line, ' '); i >= 0 {
+		name = line[:i]
+	}
+	if len(name) != hexLen {
+		return nil, false
+	}
+	for _, c := range name {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return nil, false
+		}
+	}
+	return name, true
+}
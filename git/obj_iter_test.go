@@ -0,0 +1,234 @@
+package git_test
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/github/go-pipe/pipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/internal/testutils"
+)
+
+func TestRevListObjectName(t *testing.T) {
+	t.Parallel()
+
+	sha1 := "6fc39af32cfa576495b52db5841d1be2832fc00b"
+	sha256 := "f3c9e0a1d4a1b0b8b1c7a4d2e6e9e7c1d5a3f0b2c4d6e8f0a1b3c5d7e9f1a3b5"
+
+	for _, p := range []struct {
+		name     string
+		line     string
+		hexLen   int
+		expected string
+	}{
+		{"sha1", sha1, 40, sha1},
+		{"sha1-path", sha1 + " path/to/file", 40, sha1},
+		{"sha1-path-with-spaces", sha1 + " a  path ", 40, sha1},
+		{"sha1-empty-path", sha1 + " ", 40, sha1},
+		{"sha1-extra", sha1 + "extra", 40, ""},
+		{"sha1-as-sha256", sha1, 64, ""},
+		{"sha256", sha256, 64, sha256},
+		{"sha256-path", sha256 + " path/to/file", 64, sha256},
+		{"sha256-as-sha1", sha256, 40, ""},
+		{"sha256-prefix-as-sha1", sha256[:40] + " " + sha256[40:], 40, sha256[:40]},
+		{"uppercase", strings.ToUpper(sha1), 40, ""},
+		{"continuation", "the rest of a path", 40, ""},
+		{"leading-space", " " + sha1, 40, ""},
+		{"empty", "", 40, ""},
+	} {
+		p := p
+		t.Run(p.name, func(t *testing.T) {
+			t.Parallel()
+
+			name, ok := git.RevListObjectName([]byte(p.line), p.hexLen)
+			if p.expected == "" {
+				assert.False(t, ok)
+			} else if assert.True(t, ok) {
+				assert.Equal(t, p.expected, string(name))
+			}
+		})
+	}
+}
+
+func TestObjectIterUnusualPaths(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "unusual-paths")
+	defer testRepo.Remove(t)
+
+	// The last two paths contain lines that look like object names:
+	testRepo.AddFile(t, "with spaces", "spaces\n")
+	testRepo.AddFile(t, " leading space", "leading\n")
+	testRepo.AddFile(t, "new\nline", "newline\n")
+	testRepo.AddFile(t, "dir\nname/file", "nested\n")
+	testRepo.AddFile(t, "x\n"+strings.Repeat("ab", 20), "impostor\n")
+	testRepo.AddFile(t, "y\n"+strings.Repeat("cd", 20)+" z", "impostor\n")
+	testRepo.Commit(t, "-m", "unusual paths")
+
+	out, err := testRepo.GitCommand(
+		t, "rev-list", "--objects", "--no-object-names", "HEAD",
+	).Output()
+	require.NoError(t, err)
+	expected := strings.Fields(string(out))
+	sort.Strings(expected)
+
+	// Check both with the capabilities of the installed Git and
+	// without any optional features, as with old versions of Git,
+	// which can't omit the paths:
+	for _, probed := range []bool{true, false} {
+		probed := probed
+		name := "probed"
+		if !probed {
+			name = "no-capabilities"
+		}
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			repo := testRepo.Repository(t)
+			if !probed {
+				git.SetCapabilities(repo, git.Capabilities{})
+			}
+
+			head, err := repo.ResolveObjectContext(ctx, "HEAD")
+			require.NoError(t, err)
+
+			iter, err := repo.NewObjectIter(ctx)
+			require.NoError(t, err)
+			require.NoError(t, iter.AddRoot(head))
+			iter.Close()
+
+			var oids []string
+			for {
+				header, ok, err := iter.Next()
+				require.NoError(t, err)
+				if !ok {
+					break
+				}
+				oids = append(oids, header.OID.String())
+			}
+			sort.Strings(oids)
+			assert.Equal(t, expected, oids)
+
+			objectCount, diskSize, err := repo.CountReachable(ctx, []git.OID{head}, nil)
+			require.NoError(t, err)
+			assert.EqualValues(t, len(expected), objectCount)
+			assert.NotZero(t, diskSize)
+		})
+	}
+}
+
+func TestCopyObjectNameSHA256(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "copy-object-name-sha256")
+	defer testRepo.Remove(t)
+
+	require.NoError(t, os.RemoveAll(filepath.Join(testRepo.Path, ".git")))
+	if err := testRepo.GitCommand(t, "init", "--object-format=sha256").Run(); err != nil {
+		t.Skip("this version of Git can't create SHA-256 repositories")
+	}
+	testRepo.AddFile(t, "with spaces", "spaces\n")
+	testRepo.AddFile(t, "new\nline", "newline\n")
+	testRepo.AddFile(t, "x\n"+strings.Repeat("ab", 32), "impostor\n")
+	testRepo.AddFile(t, "y\n"+strings.Repeat("cd", 32)+" z", "impostor\n")
+	testRepo.Commit(t, "-m", "unusual paths")
+
+	expected, err := testRepo.GitCommand(
+		t, "rev-list", "--objects", "--no-object-names", "HEAD",
+	).Output()
+	require.NoError(t, err)
+
+	for _, withPaths := range []bool{true, false} {
+		withPaths := withPaths
+		name := "with-paths"
+		args := []string{"rev-list", "--objects", "HEAD"}
+		if !withPaths {
+			name = "no-object-names"
+			args = append(args, "--no-object-names")
+		}
+		t.Run(name, func(t *testing.T) {
+			out, err := testRepo.GitCommand(t, args...).Output()
+			require.NoError(t, err)
+
+			// Feed the output through the stage that sits between `git
+			// rev-list` and `git cat-file`:
+			copyName := git.CopyObjectName(git.RevListObjectNamer(64, withPaths), '\n')
+			var buf bytes.Buffer
+			w := bufio.NewWriter(&buf)
+			for _, line := range bytes.Split(bytes.TrimSuffix(out, []byte("\n")), []byte("\n")) {
+				require.NoError(t, copyName(context.Background(), pipe.Env{}, line, w))
+			}
+			require.NoError(t, w.Flush())
+			assert.Equal(t, string(expected), buf.String())
+
+			// `git cat-file` knows every one of the objects:
+			cmd := testRepo.GitCommand(t, "cat-file", "--batch-check")
+			cmd.Stdin = &buf
+			headers, err := cmd.Output()
+			require.NoError(t, err)
+			assert.NotContains(t, string(headers), "missing")
+			assert.Equal(t, bytes.Count(expected, []byte("\n")), bytes.Count(headers, []byte("\n")))
+		})
+	}
+
+	// Without paths, a line that isn't a SHA-256 object name is an
+	// error:
+	copyName := git.CopyObjectName(git.RevListObjectNamer(64, false), '\n')
+	w := bufio.NewWriter(&bytes.Buffer{})
+	assert.Error(t, copyName(context.Background(), pipe.Env{}, []byte(strings.Repeat("ab", 20)), w))
+}
+
+func TestObjectFormat(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	testRepo := testutils.NewTestRepo(t, false, "object-format-sha1")
+	defer testRepo.Remove(t)
+
+	repo := testRepo.Repository(t)
+	assert.Equal(t, git.ObjectFormatSHA1, repo.ObjectFormat(ctx))
+	assert.Equal(t, 40, repo.ObjectFormat(ctx).HexLen())
+	assert.NoError(t, repo.CheckObjectFormat(ctx))
+
+	sha256Repo := testutils.NewTestRepo(t, false, "object-format-sha256")
+	defer sha256Repo.Remove(t)
+
+	require.NoError(t, os.RemoveAll(filepath.Join(sha256Repo.Path, ".git")))
+	if err := sha256Repo.GitCommand(t, "init", "--object-format=sha256").Run(); err != nil {
+		t.Skip("this version of Git can't create SHA-256 repositories")
+	}
+	sha256Repo.AddFile(t, "file with spaces", "contents\n")
+	sha256Repo.Commit(t, "-m", "initial")
+
+	repo = sha256Repo.Repository(t)
+	assert.Equal(t, git.ObjectFormatSHA256, repo.ObjectFormat(ctx))
+	assert.Equal(t, 64, repo.ObjectFormat(ctx).HexLen())
+
+	err := repo.CheckObjectFormat(ctx)
+	assert.True(t, errors.Is(err, git.ErrUnsupportedObjectFormat), "error: %v", err)
+
+	// Every line of `git rev-list --objects` output has a SHA-256
+	// object name, which wouldn't be recognized as a SHA-1 name:
+	out, err := sha256Repo.GitCommand(t, "rev-list", "--objects", "HEAD").Output()
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	assert.Len(t, lines, 3)
+	for _, line := range lines {
+		name, ok := git.RevListObjectName([]byte(line), 64)
+		if assert.True(t, ok, "line %q", line) {
+			assert.Len(t, name, 64)
+		}
+		_, ok = git.RevListObjectName([]byte(line), 40)
+		assert.False(t, ok, "line %q", line)
+	}
+}
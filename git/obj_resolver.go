@@ -2,11 +2,19 @@ package git
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 )
 
+// ResolveObject returns the OID of the object named by `name`, which
+// can be any revision expression understood by `git rev-parse`.
 func (repo *Repository) ResolveObject(name string) (OID, error) {
-	cmd := repo.GitCommand("rev-parse", "--verify", "--end-of-options", name)
+	return repo.ResolveObjectContext(context.Background(), name)
+}
+
+// ResolveObjectContext is like `ResolveObject()`, but takes a context.
+func (repo *Repository) ResolveObjectContext(ctx context.Context, name string) (OID, error) {
+	cmd := repo.GitCommandContext(ctx, "rev-parse", "--verify", "--end-of-options", name)
 	output, err := cmd.Output()
 	if err != nil {
 		return NullOID, fmt.Errorf("resolving object %q: %w", name, err)
@@ -0,0 +1,62 @@
+package git
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ObjectFormat is the hash algorithm that a repository uses to name
+// its objects ("sha1" or "sha256").
+type ObjectFormat string
+
+const (
+	ObjectFormatSHA1   ObjectFormat = "sha1"
+	ObjectFormatSHA256 ObjectFormat = "sha256"
+)
+
+// HexLen returns the length of the hexadecimal object names of this
+// format.
+func (f ObjectFormat) HexLen() int {
+	if f == ObjectFormatSHA256 {
+		return 64
+	}
+	return 40
+}
+
+// ErrUnsupportedObjectFormat is returned (wrapped) for a repository
+// whose objects are named using a hash algorithm other than SHA-1,
+// which git-sizer can't represent yet.
+var ErrUnsupportedObjectFormat = errors.New("unsupported object format")
+
+// ObjectFormat returns the hash algorithm that `repo` uses to name
+// its objects. It is determined the first time that it is needed;
+// after that, the answer is cached. Versions of Git that are too old
+// to ask (before 2.28) can only handle SHA-1 repositories anyway.
+func (repo *Repository) ObjectFormat(ctx context.Context) ObjectFormat {
+	repo.objectFormatOnce.Do(func() {
+		repo.objectFormat = ObjectFormatSHA1
+		out, err := repo.GitCommandContext(ctx, "rev-parse", "--show-object-format").Output()
+		if err != nil {
+			return
+		}
+		if f := strings.TrimSpace(string(out)); f != "" {
+			repo.objectFormat = ObjectFormat(f)
+		}
+	})
+	return repo.objectFormat
+}
+
+// CheckObjectFormat returns an error wrapping
+// `ErrUnsupportedObjectFormat` if `repo` doesn't use SHA-1 object
+// names.
+func (repo *Repository) CheckObjectFormat(ctx context.Context) error {
+	if f := repo.ObjectFormat(ctx); f != ObjectFormatSHA1 {
+		return fmt.Errorf(
+			"%w: %s uses the '%s' object format, but git-sizer only supports '%s' so far",
+			ErrUnsupportedObjectFormat, repo.GitDir(), f, ObjectFormatSHA1,
+		)
+	}
+	return nil
+}
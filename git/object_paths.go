@@ -0,0 +1,63 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// ObjectPaths calls `fn` for each object that is reachable from
+// `tips`, with the path at which `git rev-list --objects` first
+// encountered it (which is empty for commits, for tags, and for the
+// top-level trees of commits). Each object is reported once, so if
+// it appears at several paths, only one of them is reported. Lines
+// that don't start with an object name (e.g., the continuation of a
+// path that contains a newline) are skipped.
+func (repo *Repository) ObjectPaths(
+	ctx context.Context, tips []OID, fn func(oid OID, path string),
+) error {
+	if len(tips) == 0 {
+		return nil
+	}
+
+	revs := &bytes.Buffer{}
+	for _, oid := range tips {
+		fmt.Fprintf(revs, "%s\n", oid)
+	}
+
+	cmd := repo.GitCommandContext(ctx, "rev-list", "--objects", "--stdin")
+	cmd.Stdin = revs
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(out)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		name, path := line, ""
+		if i := strings.IndexByte(line, ' '); i >= 0 {
+			name, path = line[:i], line[i+1:]
+		}
+		oid, err := NewOID(name)
+		if err != nil {
+			continue
+		}
+		fn(oid, path)
+	}
+	if err := scanner.Err(); err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return fmt.Errorf("reading the output of 'git rev-list': %w", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("listing the objects in %s: %w", repo.GitDir(), err)
+	}
+	return nil
+}
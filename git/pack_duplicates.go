@@ -0,0 +1,309 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+)
+
+// PackDuplicates summarizes the objects that are stored in more than
+// one packfile. Such duplicates are created, for example, by fetches
+// that transfer objects that the repository already has, and they
+// persist until the packfiles are consolidated by a full repack.
+type PackDuplicates struct {
+	// ObjectCount is the number of distinct objects that are stored
+	// in more than one packfile.
+	ObjectCount counts.Count32
+
+	// RedundantCopyCount is the number of copies of those objects
+	// beyond the first.
+	RedundantCopyCount counts.Count32
+
+	// WastedSize is the number of bytes taken up by the copies of
+	// those objects beyond the smallest one.
+	WastedSize counts.Count64
+}
+
+// PackDuplicates finds the objects that are stored in more than one
+// of the packfiles in `repo`'s object database, by enumerating the
+// packfiles' indexes. It doesn't include packfiles in alternate
+// object databases.
+func (repo *Repository) PackDuplicates(ctx context.Context) (PackDuplicates, error) {
+	packs, err := repo.Packfiles(ctx)
+	if err != nil {
+		return PackDuplicates{}, err
+	}
+	if len(packs) < 2 {
+		return PackDuplicates{}, nil
+	}
+
+	packDir, err := repo.gitPath(ctx, "objects/pack")
+	if err != nil {
+		return PackDuplicates{}, err
+	}
+
+	var iters packIndexHeap
+	defer func() {
+		for _, iter := range iters {
+			iter.Close()
+		}
+	}()
+	for _, p := range packs {
+		iter, err := newPackIndexIter(
+			filepath.Join(packDir, strings.TrimSuffix(p.Name, ".pack")+".idx"), uint64(p.Size),
+		)
+		if errors.Is(err, fs.ErrNotExist) {
+			// The packfile was removed by a concurrent repack.
+			continue
+		} else if err != nil {
+			return PackDuplicates{}, err
+		}
+		ok, err := iter.Next()
+		if err != nil {
+			iter.Close()
+			return PackDuplicates{}, err
+		}
+		if !ok {
+			iter.Close()
+			continue
+		}
+		iters = append(iters, iter)
+	}
+	heap.Init(&iters)
+
+	// The packfile indexes are sorted by OID, so merge them, and
+	// look for runs of the same OID:
+	var dups PackDuplicates
+	var objectCount int64
+	for len(iters) > 0 {
+		objectCount++
+		if objectCount%100000 == 0 {
+			if err := ctx.Err(); err != nil {
+				return PackDuplicates{}, err
+			}
+		}
+
+		oid := iters[0].oid
+		copies := 0
+		var totalSize, minSize uint64
+		for len(iters) > 0 && iters[0].oid == oid {
+			iter := iters[0]
+			copies++
+			totalSize += iter.size
+			if copies == 1 || iter.size < minSize {
+				minSize = iter.size
+			}
+
+			ok, err := iter.Next()
+			if err != nil {
+				return PackDuplicates{}, err
+			}
+			if ok {
+				heap.Fix(&iters, 0)
+			} else {
+				iter.Close()
+				heap.Pop(&iters)
+			}
+		}
+
+		if copies > 1 {
+			dups.ObjectCount.Increment(1)
+			dups.RedundantCopyCount.Increment(counts.NewCount32(uint64(copies - 1)))
+			dups.WastedSize.Increment(counts.NewCount64(totalSize - minSize))
+		}
+	}
+
+	return dups, nil
+}
+
+// packIndexIter iterates over the objects listed in a pack index, in
+// order of OID, along with the number of bytes that each one takes
+// up in the packfile.
+type packIndexIter struct {
+	f *os.File
+
+	// oids yields the OIDs, in binary form, in the order that they
+	// appear in the index.
+	oids io.Reader
+
+	// sizes holds the number of bytes that each object takes up in
+	// the packfile, in the order that they appear in the index.
+	sizes []uint64
+
+	// The current object and its size, and its position in the
+	// index:
+	oid  OID
+	size uint64
+	i    int
+}
+
+// newPackIndexIter opens the pack index at `path`, whose packfile
+// has the size `packSize`, and prepares to iterate over its objects.
+func newPackIndexIter(path string, packSize uint64) (*packIndexIter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	iter := &packIndexIter{f: f, i: -1}
+	if err := iter.init(packSize); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("reading pack index %s: %w", path, err)
+	}
+	return iter, nil
+}
+
+// init reads the offsets of the objects from the index, computes
+// their sizes, and positions `iter.oids` at the start of the OIDs.
+func (iter *packIndexIter) init(packSize uint64) error {
+	oids, offsets, err := readPackIndexEntries(iter.f)
+	if err != nil {
+		return err
+	}
+	iter.oids = oids
+	iter.sizes = packObjectSizes(offsets, packSize, len(NullOID.v))
+	return nil
+}
+
+// readPackIndexEntries reads the pack index in `f`, returning a
+// reader that yields the OIDs of the objects, in binary form and in
+// the order that they appear in the index (i.e., sorted), and the
+// offsets of the objects in the packfile, in the same order.
+func readPackIndexEntries(f *os.File) (io.Reader, []uint64, error) {
+	const hashSize = len(NullOID.v)
+
+	var header [8 + 256*4]byte
+	if _, err := f.ReadAt(header[:], 0); err != nil {
+		return nil, nil, err
+	}
+
+	if !bytes.HasPrefix(header[:], packIndexSignature) {
+		// Version 1: the fanout table is followed by the entries,
+		// each of which is a four-byte offset followed by the OID.
+		n := int(binary.BigEndian.Uint32(header[255*4:]))
+		entries := make([]byte, n*(4+hashSize))
+		if _, err := f.ReadAt(entries, 256*4); err != nil {
+			return nil, nil, err
+		}
+		offsets := make([]uint64, n)
+		oids := make([]byte, 0, n*hashSize)
+		for i := 0; i < n; i++ {
+			entry := entries[i*(4+hashSize) : (i+1)*(4+hashSize)]
+			offsets[i] = uint64(binary.BigEndian.Uint32(entry))
+			oids = append(oids, entry[4:]...)
+		}
+		return bytes.NewReader(oids), offsets, nil
+	}
+
+	if version := binary.BigEndian.Uint32(header[4:8]); version != 2 {
+		return nil, nil, fmt.Errorf("unsupported version %d", version)
+	}
+
+	// Version 2: the fanout table is followed by the OIDs, the CRCs,
+	// the four-byte offsets, and the eight-byte offsets that don't
+	// fit in four bytes.
+	n := int64(binary.BigEndian.Uint32(header[8+255*4:]))
+	oidsStart := int64(len(header))
+	offsetsStart := oidsStart + n*int64(hashSize) + n*4
+
+	offsetBytes := make([]byte, n*4)
+	if _, err := f.ReadAt(offsetBytes, offsetsStart); err != nil {
+		return nil, nil, err
+	}
+	offsets := make([]uint64, n)
+	for i := range offsets {
+		offset := binary.BigEndian.Uint32(offsetBytes[i*4:])
+		if offset&0x80000000 == 0 {
+			offsets[i] = uint64(offset)
+			continue
+		}
+		var large [8]byte
+		if _, err := f.ReadAt(
+			large[:], offsetsStart+n*4+int64(offset&0x7fffffff)*8,
+		); err != nil {
+			return nil, nil, err
+		}
+		offsets[i] = binary.BigEndian.Uint64(large[:])
+	}
+
+	return bufio.NewReader(io.NewSectionReader(f, oidsStart, n*int64(hashSize))), offsets, nil
+}
+
+// packObjectSizes returns the number of bytes taken up by each of the
+// objects at `offsets` in a packfile of size `packSize`. Each object
+// extends to the start of the next one, or to the trailing checksum.
+func packObjectSizes(offsets []uint64, packSize uint64, hashSize int) []uint64 {
+	order := make([]int, len(offsets))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(i, j int) bool {
+		return offsets[order[i]] < offsets[order[j]]
+	})
+
+	sizes := make([]uint64, len(offsets))
+	end := packSize - uint64(hashSize)
+	for k := len(order) - 1; k >= 0; k-- {
+		i := order[k]
+		if offsets[i] < end {
+			sizes[i] = end - offsets[i]
+		}
+		end = offsets[i]
+	}
+	return sizes
+}
+
+// Next advances to the next object. It returns false if there are no
+// more objects.
+func (iter *packIndexIter) Next() (bool, error) {
+	if iter.i+1 >= len(iter.sizes) {
+		return false, nil
+	}
+	iter.i++
+	if _, err := io.ReadFull(iter.oids, iter.oid.v[:]); err != nil {
+		return false, fmt.Errorf("reading pack index %s: %w", iter.f.Name(), err)
+	}
+	iter.size = iter.sizes[iter.i]
+	return true, nil
+}
+
+// Close closes the pack index.
+func (iter *packIndexIter) Close() {
+	iter.f.Close()
+}
+
+// packIndexHeap is a min-heap of `packIndexIter`s, ordered by their
+// current OIDs.
+type packIndexHeap []*packIndexIter
+
+func (h packIndexHeap) Len() int { return len(h) }
+
+func (h packIndexHeap) Less(i, j int) bool {
+	return bytes.Compare(h[i].oid.v[:], h[j].oid.v[:]) < 0
+}
+
+func (h packIndexHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *packIndexHeap) Push(x interface{}) {
+	*h = append(*h, x.(*packIndexIter))
+}
+
+func (h *packIndexHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[:n-1]
+	return x
+}
@@ -0,0 +1,182 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+)
+
+// packEntryRefDelta is the type of the packfile entries that are
+// deltas whose bases are named by their OIDs (`REF_DELTA`). The
+// other deltas (`OFS_DELTA`) name their bases by their offsets
+// earlier in the same packfile.
+const packEntryRefDelta = 7
+
+// PackFixups summarizes the deltas in the packfiles whose bases
+// can't simply be read from earlier in the same packfile, so that
+// reading them takes more than one access to the packs. When a thin
+// pack (e.g., one received by a fetch or push) is stored, the objects
+// against which its deltas were computed are appended to it (by `git
+// index-pack --fix-thin`), so those deltas have to look up their
+// bases by OID and seek forward to them. Such packfiles accumulate
+// until they are consolidated by a repack.
+type PackFixups struct {
+	// PackfileCount is the number of packfiles that have any such
+	// deltas.
+	PackfileCount counts.Count32
+
+	// DeltaCount is the number of deltas whose bases were appended
+	// to their packfiles to complete thin packs.
+	DeltaCount counts.Count32
+
+	// BaseCount and BaseSize are the number of those appended bases
+	// and the number of bytes that they take up in the packfiles.
+	BaseCount counts.Count32
+	BaseSize  counts.Count64
+
+	// CrossPackDeltaCount is the number of deltas whose bases aren't
+	// in their packfiles at all, so they have to be found in another
+	// packfile. Git itself doesn't write such packfiles.
+	CrossPackDeltaCount counts.Count32
+}
+
+// PackFixups reads the headers of the objects in the packfiles in
+// `repo`'s object database (using their indexes to find them) to
+// find the deltas whose bases were appended to complete thin packs
+// or are in other packfiles. It doesn't include packfiles in
+// alternate object databases.
+func (repo *Repository) PackFixups(ctx context.Context) (PackFixups, error) {
+	packs, err := repo.Packfiles(ctx)
+	if err != nil {
+		return PackFixups{}, err
+	}
+
+	packDir, err := repo.gitPath(ctx, "objects/pack")
+	if err != nil {
+		return PackFixups{}, err
+	}
+
+	var fixups PackFixups
+	for _, p := range packs {
+		if err := ctx.Err(); err != nil {
+			return PackFixups{}, err
+		}
+		pf, err := readPackFixups(filepath.Join(packDir, strings.TrimSuffix(p.Name, ".pack")))
+		if errors.Is(err, fs.ErrNotExist) {
+			// The packfile was removed by a concurrent repack.
+			continue
+		} else if err != nil {
+			return PackFixups{}, err
+		}
+		if pf.DeltaCount == 0 && pf.CrossPackDeltaCount == 0 {
+			continue
+		}
+		fixups.PackfileCount.Increment(1)
+		fixups.DeltaCount.Increment(pf.DeltaCount)
+		fixups.BaseCount.Increment(pf.BaseCount)
+		fixups.BaseSize.Increment(pf.BaseSize)
+		fixups.CrossPackDeltaCount.Increment(pf.CrossPackDeltaCount)
+	}
+	return fixups, nil
+}
+
+// readPackFixups finds the deltas with appended or missing bases in
+// the packfile whose path, without the ".pack" or ".idx" suffix, is
+// `base`.
+func readPackFixups(base string) (PackFixups, error) {
+	const hashSize = len(NullOID.v)
+
+	idx, err := os.Open(base + ".idx")
+	if err != nil {
+		return PackFixups{}, err
+	}
+	defer idx.Close()
+
+	oidReader, offsets, err := readPackIndexEntries(idx)
+	if err != nil {
+		return PackFixups{}, fmt.Errorf("reading pack index %s: %w", idx.Name(), err)
+	}
+	oids := make([]byte, len(offsets)*hashSize)
+	if _, err := io.ReadFull(oidReader, oids); err != nil {
+		return PackFixups{}, fmt.Errorf("reading pack index %s: %w", idx.Name(), err)
+	}
+
+	pack, err := os.Open(base + ".pack")
+	if err != nil {
+		return PackFixups{}, err
+	}
+	defer pack.Close()
+	info, err := pack.Stat()
+	if err != nil {
+		return PackFixups{}, err
+	}
+	sizes := packObjectSizes(offsets, uint64(info.Size()), hashSize)
+
+	// lookup returns the position of `oid` in the index, or -1 if
+	// it isn't there.
+	lookup := func(oid []byte) int {
+		i := sort.Search(len(offsets), func(i int) bool {
+			return bytes.Compare(oids[i*hashSize:(i+1)*hashSize], oid) >= 0
+		})
+		if i < len(offsets) && bytes.Equal(oids[i*hashSize:(i+1)*hashSize], oid) {
+			return i
+		}
+		return -1
+	}
+
+	var fixups PackFixups
+	appended := make(map[int]struct{})
+	// The longest header is that of a `REF_DELTA` whose size takes
+	// ten bytes, followed by the base's OID:
+	buf := make([]byte, 10+hashSize)
+	for _, offset := range offsets {
+		n, err := pack.ReadAt(buf, int64(offset))
+		if err != nil && err != io.EOF {
+			return PackFixups{}, fmt.Errorf("reading packfile %s: %w", pack.Name(), err)
+		}
+		header := buf[:n]
+		if len(header) == 0 {
+			return PackFixups{}, fmt.Errorf("packfile %s is truncated", pack.Name())
+		}
+		if (header[0]>>4)&7 != packEntryRefDelta {
+			continue
+		}
+
+		// Skip the rest of the variable-length size:
+		j := 0
+		for j < len(header) && header[j]&0x80 != 0 {
+			j++
+		}
+		j++
+		if j+hashSize > len(header) {
+			return PackFixups{}, fmt.Errorf(
+				"packfile %s has a malformed entry at offset %d", pack.Name(), offset,
+			)
+		}
+
+		b := lookup(header[j : j+hashSize])
+		switch {
+		case b == -1:
+			fixups.CrossPackDeltaCount.Increment(1)
+		case offsets[b] > offset:
+			// `git pack-objects` writes the bases of deltas before
+			// the deltas, so this base was appended later:
+			fixups.DeltaCount.Increment(1)
+			if _, ok := appended[b]; !ok {
+				appended[b] = struct{}{}
+				fixups.BaseCount.Increment(1)
+				fixups.BaseSize.Increment(counts.NewCount64(sizes[b]))
+			}
+		}
+	}
+	return fixups, nil
+}
@@ -0,0 +1,187 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/github/git-sizer/counts"
+)
+
+// Packfile describes a packfile in the repository's object database.
+type Packfile struct {
+	// Name is the filename of the packfile, like
+	// "pack-<hash>.pack".
+	Name string
+
+	// ObjectCount is the number of objects in the packfile, as
+	// recorded in its index.
+	ObjectCount counts.Count32
+
+	// Size is the size of the packfile in bytes, not including its
+	// index and other auxiliary files.
+	Size counts.Count64
+
+	// ModTime is the modification time of the packfile, which is
+	// when its newest objects were written.
+	ModTime time.Time
+
+	// HasBitmap and HasRevIndex tell whether the packfile has a
+	// reachability bitmap (".bitmap") and a reverse index (".rev").
+	HasBitmap   bool
+	HasRevIndex bool
+
+	// Keep tells whether the packfile has a ".keep" file, which
+	// prevents `git repack` from deleting it.
+	Keep bool
+}
+
+// packIndexSignature is the signature at the start of version 2 and
+// later pack index files. Version 1 files start directly with the
+// fanout table.
+var packIndexSignature = []byte{'\377', 't', 'O', 'c'}
+
+// Packfiles returns the packfiles in `repo`'s object database,
+// ordered by name. It doesn't include packfiles in alternate object
+// databases.
+func (repo *Repository) Packfiles(ctx context.Context) ([]Packfile, error) {
+	packDir, err := repo.gitPath(ctx, "objects/pack")
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(packDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading pack directory: %w", err)
+	}
+
+	files := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		files[entry.Name()] = true
+	}
+
+	var packs []Packfile
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasSuffix(name, ".pack") {
+			continue
+		}
+		base := strings.TrimSuffix(name, ".pack")
+		if !files[base+".idx"] {
+			// The packfile is still being written, or is broken.
+			continue
+		}
+
+		info, err := entry.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			// The packfile was removed by a concurrent repack.
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("reading packfile %s: %w", name, err)
+		}
+
+		objectCount, err := readPackIndexObjectCount(filepath.Join(packDir, base+".idx"))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		packs = append(packs, Packfile{
+			Name:        name,
+			ObjectCount: objectCount,
+			Size:        counts.NewCount64(uint64(info.Size())),
+			ModTime:     info.ModTime(),
+			HasBitmap:   files[base+".bitmap"],
+			HasRevIndex: files[base+".rev"],
+			Keep:        files[base+".keep"],
+		})
+	}
+
+	sort.Slice(packs, func(i, j int) bool {
+		return packs[i].Name < packs[j].Name
+	})
+	return packs, nil
+}
+
+// readPackIndexObjectCount returns the number of objects in the
+// packfile whose index is at `path`, which is the last entry of the
+// index's fanout table.
+func readPackIndexObjectCount(path string) (counts.Count32, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	// The signature and version, if present, followed by the fanout
+	// table, which consists of 256 four-byte entries:
+	var header [8 + 256*4]byte
+	if _, err := io.ReadFull(f, header[:]); err != nil {
+		return 0, fmt.Errorf("reading pack index %s: %w", path, err)
+	}
+
+	fanout := header[:256*4]
+	if bytes.HasPrefix(header[:], packIndexSignature) {
+		version := binary.BigEndian.Uint32(header[4:8])
+		if version != 2 {
+			return 0, fmt.Errorf("pack index %s has unsupported version %d", path, version)
+		}
+		fanout = header[8:]
+	}
+
+	return counts.NewCount32(uint64(binary.BigEndian.Uint32(fanout[255*4:]))), nil
+}
+
+// PackfileObjects returns the names of the objects in the packfile
+// called `name` (like "pack-<hash>.pack") in `repo`'s object
+// database, as listed by `git show-index`.
+func (repo *Repository) PackfileObjects(ctx context.Context, name string) ([]OID, error) {
+	packDir, err := repo.gitPath(ctx, "objects/pack")
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := os.Open(filepath.Join(packDir, strings.TrimSuffix(name, ".pack")+".idx"))
+	if err != nil {
+		return nil, fmt.Errorf("reading index of packfile %s: %w", name, err)
+	}
+	defer idx.Close()
+
+	cmd := repo.GitCommandContext(ctx, "show-index")
+	cmd.Stdin = idx
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("running 'git show-index' for packfile %s: %w", name, err)
+	}
+
+	// Each line looks like "<offset> <oid> (<crc32>)", but the CRC is
+	// missing for version 1 indexes:
+	var oids []OID
+	for _, line := range strings.Split(string(out), "\n") {
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			continue
+		}
+		if len(words) < 2 {
+			return nil, fmt.Errorf("unexpected output from 'git show-index': %q", line)
+		}
+		oid, err := NewOID(words[1])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected output from 'git show-index': %q", line)
+		}
+		oids = append(oids, oid)
+	}
+	return oids, nil
+}
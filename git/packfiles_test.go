@@ -0,0 +1,161 @@
+package git_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/internal/testutils"
+)
+
+func TestPackfiles(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "packfiles")
+	defer testRepo.Remove(t)
+
+	run := func(args ...string) {
+		t.Helper()
+		cmd := testRepo.AuthoredCommand(t, args...)
+		require.NoError(t, cmd.Run(), "running git %v", args)
+	}
+
+	repo := testRepo.Repository(t)
+	ctx := context.Background()
+
+	packs, err := repo.Packfiles(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, packs)
+
+	testRepo.AddFile(t, "a.txt", "a\n")
+	run("commit", "-m", "a")
+	run("repack", "-a", "-d", "-b")
+	run("tag", "-m", "tag", "annotated")
+	run("repack", "-d")
+
+	packs, err = repo.Packfiles(ctx)
+	require.NoError(t, err)
+	require.Len(t, packs, 2)
+
+	var objectCount counts.Count32
+	var bitmaps int
+	for _, p := range packs {
+		assert.Regexp(t, `^pack-[0-9a-f]{40}\.pack$`, p.Name)
+		assert.Greater(t, uint64(p.Size), uint64(0))
+		assert.False(t, p.ModTime.IsZero())
+		objectCount += p.ObjectCount
+		if p.HasBitmap {
+			bitmaps++
+			assert.Equal(t, counts.Count32(3), p.ObjectCount)
+		}
+	}
+	// The commit, its tree, and its blob, plus the tag:
+	assert.Equal(t, counts.Count32(4), objectCount)
+	assert.Equal(t, 1, bitmaps)
+}
+
+func TestPackDuplicates(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "pack-duplicates")
+	defer testRepo.Remove(t)
+
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := testRepo.AuthoredCommand(t, args...)
+		out, err := cmd.Output()
+		require.NoError(t, err, "running git %v", args)
+		return strings.TrimSpace(string(out))
+	}
+
+	repo := testRepo.Repository(t)
+	ctx := context.Background()
+
+	testRepo.AddFile(t, "a.txt", strings.Repeat("a\n", 1000))
+	run("commit", "-m", "a")
+	run("repack", "-a", "-d")
+	testRepo.AddFile(t, "b.txt", "b\n")
+	run("commit", "-m", "b")
+	run("repack", "-d")
+
+	dups, err := repo.PackDuplicates(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, git.PackDuplicates{}, dups)
+
+	// Write another packfile holding a second copy of the first
+	// blob:
+	blob := run("rev-parse", "HEAD~:a.txt")
+	cmd := testRepo.GitCommand(t, "pack-objects", filepath.Join(repo.GitDir(), "objects", "pack", "pack"))
+	cmd.Stdin = strings.NewReader(blob + "\n")
+	require.NoError(t, cmd.Run())
+
+	dups, err = repo.PackDuplicates(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, counts.Count32(1), dups.ObjectCount)
+	assert.Equal(t, counts.Count32(1), dups.RedundantCopyCount)
+	assert.Greater(t, uint64(dups.WastedSize), uint64(10))
+	assert.Less(t, uint64(dups.WastedSize), uint64(100))
+}
+
+func TestPackFixups(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "pack-fixups")
+	defer testRepo.Remove(t)
+
+	run := func(args ...string) {
+		t.Helper()
+		cmd := testRepo.AuthoredCommand(t, args...)
+		require.NoError(t, cmd.Run(), "running git %v", args)
+	}
+
+	repo := testRepo.Repository(t)
+	ctx := context.Background()
+
+	var lines strings.Builder
+	for i := 0; i < 3000; i++ {
+		fmt.Fprintf(&lines, "line %d\n", i)
+	}
+	testRepo.AddFile(t, "a.txt", lines.String())
+	run("commit", "-m", "a")
+	run("repack", "-a", "-d")
+
+	fixups, err := repo.PackFixups(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, git.PackFixups{}, fixups)
+
+	// Store a thin pack holding the second commit, as a fetch would.
+	// Its blob is a delta against the first version, which is
+	// appended to complete it:
+	testRepo.AddFile(t, "a.txt", lines.String()+"one more line\n")
+	run("commit", "-m", "b")
+	thin := testRepo.GitCommand(t, "pack-objects", "--thin", "--stdout", "--revs")
+	thin.Stdin = strings.NewReader("HEAD\n^HEAD~\n")
+	pack, err := thin.Output()
+	require.NoError(t, err)
+	cmd := testRepo.GitCommand(t, "index-pack", "--stdin", "--fix-thin")
+	cmd.Stdin = bytes.NewReader(pack)
+	require.NoError(t, cmd.Run())
+
+	fixups, err = repo.PackFixups(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, counts.Count32(1), fixups.PackfileCount)
+	assert.Equal(t, counts.Count32(1), fixups.DeltaCount)
+	assert.Equal(t, counts.Count32(1), fixups.BaseCount)
+	assert.Greater(t, uint64(fixups.BaseSize), uint64(100))
+	assert.Equal(t, counts.Count32(0), fixups.CrossPackDeltaCount)
+
+	// A full repack resolves them:
+	run("repack", "-a", "-d")
+	fixups, err = repo.PackFixups(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, git.PackFixups{}, fixups)
+}
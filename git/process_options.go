@@ -0,0 +1,64 @@
+package git
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/cli/safeexec"
+)
+
+// ProcessOptions limit the resources that the `git` subprocesses use,
+// so that scans (e.g., scheduled scans on busy servers) don't compete
+// with more important work. The zero value imposes no limits.
+type ProcessOptions struct {
+	// Nice, if positive, is the amount by which the scheduling
+	// priority of the subprocesses is lowered, using `nice(1)`.
+	Nice int
+
+	// IdleIO, if set, puts the subprocesses in the "idle" I/O
+	// scheduling class, using `ionice(1)`, so that they only get to
+	// use the disk when nobody else needs it. This only works on
+	// Linux.
+	IdleIO bool
+
+	// Threads, if positive, is the maximum number of threads that
+	// `git` uses for operations that it can parallelize (via
+	// `pack.threads` and `index.threads`).
+	Threads int
+}
+
+// SetProcessOptions sets the options that are used for the `git`
+// commands that are run for `repo` from now on. It returns an error
+// if the tools that are needed to apply them can't be found.
+func (repo *Repository) SetProcessOptions(opts ProcessOptions) error {
+	var wrapper []string
+
+	if opts.IdleIO {
+		ionice, err := safeexec.LookPath("ionice")
+		if err != nil {
+			return fmt.Errorf("lowering the I/O priority of git: %w", err)
+		}
+		wrapper = append(wrapper, ionice, "-c", "3")
+	}
+
+	if opts.Nice > 0 {
+		nice, err := safeexec.LookPath("nice")
+		if err != nil {
+			return fmt.Errorf("lowering the priority of git: %w", err)
+		}
+		wrapper = append(wrapper, nice, "-n", strconv.Itoa(opts.Nice))
+	}
+
+	var config []string
+	if opts.Threads > 0 {
+		threads := strconv.Itoa(opts.Threads)
+		config = []string{
+			"-c", "pack.threads=" + threads,
+			"-c", "index.threads=" + threads,
+		}
+	}
+
+	repo.processWrapper = wrapper
+	repo.processConfig = config
+	return nil
+}
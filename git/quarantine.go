@@ -0,0 +1,40 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+)
+
+// SetQuarantine makes the `git` commands that are run for `repo` from
+// now on see the objects in the quarantine directory at `path`, in
+// addition to those in the repository's own object database. While a
+// push is being received, Git keeps the pushed objects in such a
+// directory (named by `GIT_QUARANTINE_PATH` in the environment of the
+// `pre-receive` hook) until the hook has accepted them.
+func (repo *Repository) SetQuarantine(ctx context.Context, path string) error {
+	// Within a hook, `GIT_OBJECT_DIRECTORY` might already point at
+	// the quarantine, so derive the path of the repository's own
+	// object database from its common directory instead:
+	cmd := repo.GitCommandContext(ctx, "rev-parse", "--git-common-dir")
+	out, err := cmd.Output()
+	if err != nil {
+		return fmt.Errorf("running 'git rev-parse --git-common-dir': %w", err)
+	}
+	objects, err := filepath.Abs(filepath.Join(string(bytes.TrimSpace(out)), "objects"))
+	if err != nil {
+		return err
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	repo.env = []string{
+		"GIT_QUARANTINE_PATH=" + path,
+		"GIT_OBJECT_DIRECTORY=" + path,
+		"GIT_ALTERNATE_OBJECT_DIRECTORIES=" + objects,
+	}
+	return nil
+}
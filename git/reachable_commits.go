@@ -0,0 +1,40 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+)
+
+// ReachableCommits returns the names of the commits that are
+// reachable from `tips`, newest first. Any `tips` that are not
+// commits are ignored, except that tags are peeled.
+func (repo *Repository) ReachableCommits(ctx context.Context, tips []OID) ([]OID, error) {
+	if len(tips) == 0 {
+		return nil, nil
+	}
+
+	stdin := &bytes.Buffer{}
+	for _, oid := range tips {
+		fmt.Fprintln(stdin, oid)
+	}
+
+	cmd := repo.GitCommandContext(ctx, "rev-list", "--stdin")
+	cmd.Stdin = stdin
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing commits: %w", err)
+	}
+
+	var oids []OID
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		oid, err := NewOID(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("unexpected output from 'git rev-list': %q", scanner.Text())
+		}
+		oids = append(oids, oid)
+	}
+	return oids, scanner.Err()
+}
@@ -2,9 +2,12 @@ package git
 
 import (
 	"bufio"
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"strings"
 
 	"github.com/github/go-pipe/pipe"
 )
@@ -18,20 +21,48 @@ type ReferenceIter struct {
 // NewReferenceIter returns an iterator that iterates over all of the
 // references in `repo`.
 func (repo *Repository) NewReferenceIter(ctx context.Context) (*ReferenceIter, error) {
+	return repo.newReferenceIter(ctx, true)
+}
+
+// NewReferenceIterWithoutDates is like `NewReferenceIter()`, except
+// that the references' `CommitterDate` is left zero. This saves
+// reading the commits (and annotated tags) that they point at.
+func (repo *Repository) NewReferenceIterWithoutDates(ctx context.Context) (*ReferenceIter, error) {
+	return repo.newReferenceIter(ctx, false)
+}
+
+func (repo *Repository) newReferenceIter(ctx context.Context, withDates bool) (*ReferenceIter, error) {
+	if repo.refBackend == RefBackendNative {
+		refs, err := repo.readNativeReferences(ctx, withDates)
+		switch {
+		case err == nil:
+			return newSliceReferenceIter(ctx, refs), nil
+		case !errors.Is(err, errNativeRefsUnsupported):
+			return nil, err
+		}
+		// Otherwise, fall back to `git for-each-ref`.
+	}
+
 	iter := ReferenceIter{
 		refCh: make(chan Reference),
 		errCh: make(chan error),
 	}
 
+	// Leaving the date out of the format (but not its separator)
+	// saves `git for-each-ref` from having to read the objects:
+	dateFormat := ""
+	if withDates {
+		dateFormat = "%(committerdate:unix)%(*committerdate:unix)"
+	}
+
 	p := pipe.New()
 	p.Add(
 		// Output all references and their values:
-		pipe.CommandStage(
+		repo.commandStage(
 			"git-for-each-ref",
-			repo.GitCommand(
-				"for-each-ref",
-				"--format=%(objectname) %(objecttype) %(objectsize) %(refname)",
-			),
+			"for-each-ref",
+			"--format=%(objectname) %(objecttype) %(objectsize) "+
+				dateFormat+" %(refname)%00",
 		),
 
 		// Read the references and send them to `iter.refCh`, then close
@@ -41,17 +72,22 @@ func (repo *Repository) NewReferenceIter(ctx context.Context) (*ReferenceIter, e
 			func(ctx context.Context, env pipe.Env, stdin io.Reader, stdout io.Writer) error {
 				defer close(iter.refCh)
 
+				// Each record is terminated by a NUL (from the
+				// format) and an LF (which `git for-each-ref` adds),
+				// so that refnames can't be confused with the
+				// separators:
 				in := bufio.NewReader(stdin)
 				for {
-					line, err := in.ReadBytes('\n')
+					record, err := in.ReadBytes(0)
 					if err != nil {
-						if err == io.EOF {
+						if err == io.EOF && len(bytes.TrimLeft(record, "\n")) == 0 {
 							return nil
 						}
 						return fmt.Errorf("reading 'git for-each-ref' output: %w", err)
 					}
+					record = bytes.TrimPrefix(record[:len(record)-1], []byte{'\n'})
 
-					ref, err := ParseReference(string(line[:len(line)-1]))
+					ref, err := ParseReference(string(record))
 					if err != nil {
 						return fmt.Errorf("parsing 'git for-each-ref' output: %w", err)
 					}
@@ -77,6 +113,31 @@ func (repo *Repository) NewReferenceIter(ctx context.Context) (*ReferenceIter, e
 	return &iter, nil
 }
 
+// newSliceReferenceIter returns an iterator that iterates over
+// `refs`.
+func newSliceReferenceIter(ctx context.Context, refs []Reference) *ReferenceIter {
+	iter := ReferenceIter{
+		refCh: make(chan Reference),
+		errCh: make(chan error, 1),
+	}
+
+	go func() {
+		defer close(iter.refCh)
+
+		for _, ref := range refs {
+			select {
+			case iter.refCh <- ref:
+			case <-ctx.Done():
+				iter.errCh <- ctx.Err()
+				return
+			}
+		}
+		iter.errCh <- nil
+	}()
+
+	return &iter
+}
+
 // Next returns either the next reference or a boolean `false` value
 // indicating that the iteration is over. On errors, return an error
 // (in this case, the caller must still call `Close()`).
@@ -88,3 +149,24 @@ func (iter *ReferenceIter) Next() (Reference, bool, error) {
 
 	return ref, true, nil
 }
+
+// RefsContaining returns the names of the references whose history
+// contains the commit `oid`, in sorted order.
+func (repo *Repository) RefsContaining(ctx context.Context, oid OID) ([]string, error) {
+	cmd := repo.GitCommandContext(
+		ctx, "for-each-ref", "--format=%(refname)%00", "--contains", oid.String(),
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing references containing %s: %w", oid, err)
+	}
+
+	// Each refname is terminated by a NUL and an LF:
+	var refnames []string
+	for _, record := range strings.Split(string(out), "\x00") {
+		if refname := strings.TrimPrefix(record, "\n"); refname != "" {
+			refnames = append(refnames, refname)
+		}
+	}
+	return refnames, nil
+}
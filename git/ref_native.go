@@ -0,0 +1,519 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RefBackend selects how the references of a repository are read.
+type RefBackend string
+
+const (
+	// RefBackendGit reads references by running `git for-each-ref`.
+	RefBackendGit RefBackend = "git"
+
+	// RefBackendNative reads the `packed-refs` file and loose
+	// references directly, which is faster in repositories with very
+	// many references. It falls back to `RefBackendGit` for
+	// repositories whose references it can't read, such as those
+	// using the reftable format.
+	RefBackendNative RefBackend = "native"
+)
+
+// ParseRefBackend parses the name of a `RefBackend`.
+func ParseRefBackend(s string) (RefBackend, error) {
+	switch RefBackend(s) {
+	case RefBackendGit, RefBackendNative:
+		return RefBackend(s), nil
+	default:
+		return "", fmt.Errorf("ref backend must be 'native' or 'git', not %q", s)
+	}
+}
+
+// SetRefBackend sets how `repo`'s references are read by reference
+// iterators that are created from now on.
+func (repo *Repository) SetRefBackend(backend RefBackend) {
+	repo.refBackend = backend
+}
+
+// errNativeRefsUnsupported is returned by `readNativeReferences()` if
+// the repository's references can't be read natively.
+var errNativeRefsUnsupported = errors.New("references can't be read natively")
+
+// maxSymrefDepth is the maximum number of symbolic references that
+// are followed when resolving a symbolic reference.
+const maxSymrefDepth = 5
+
+// nativeRef is a reference as read from `packed-refs` or a loose
+// reference file. Exactly one of `oid` and `target` is set.
+type nativeRef struct {
+	oid    OID
+	target string
+
+	// peeled is the OID of the object that `oid` peels to, if
+	// `packed-refs` tells us. It is `oid` itself if `oid` is known
+	// not to be an annotated tag.
+	peeled OID
+}
+
+// readNativeReferences reads all of the references under `refs/` in
+// `repo`, in the order that `git for-each-ref` would output them. Only
+// the headers of the objects that they point at are read, unless
+// `withDates` is set, in which case the commits that they peel to are
+// read, too (and any annotated tags whose peeled values aren't
+// recorded in `packed-refs`).
+func (repo *Repository) readNativeReferences(ctx context.Context, withDates bool) ([]Reference, error) {
+	refStorage, err := repo.ConfigStringDefaultContext(ctx, "extensions.refStorage", "files")
+	if err != nil {
+		return nil, err
+	}
+	if refStorage != "files" {
+		return nil, errNativeRefsUnsupported
+	}
+
+	refsDir, err := repo.gitPath(ctx, "refs")
+	if err != nil {
+		return nil, err
+	}
+	if filepath.Clean(refsDir) != filepath.Join(repo.gitDir, "refs") {
+		// This is a linked worktree, some of whose references are
+		// stored separately from the shared ones.
+		return nil, errNativeRefsUnsupported
+	}
+	packedRefsPath, err := repo.gitPath(ctx, "packed-refs")
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make(map[string]nativeRef)
+	if err := readPackedRefs(packedRefsPath, refs); err != nil {
+		return nil, err
+	}
+	// Loose references take precedence over packed ones:
+	if err := readLooseRefs(refsDir, refs); err != nil {
+		return nil, err
+	}
+
+	refnames := make([]string, 0, len(refs))
+	resolved := make([]nativeRef, 0, len(refs))
+	oids := make([]OID, 0, len(refs))
+	// The objects whose headers are needed: the ones that the
+	// references point at, plus, if dates are needed, the ones that
+	// they peel to.
+	headerOIDs := make([]OID, 0, len(refs))
+	for refname := range refs {
+		ref, ok := resolveNativeRef(refs, refname)
+		if !ok {
+			// `git for-each-ref` skips broken references, too.
+			continue
+		}
+		refnames = append(refnames, refname)
+		resolved = append(resolved, ref)
+		oids = append(oids, ref.oid)
+		headerOIDs = append(headerOIDs, ref.oid)
+		if withDates && ref.peeled != NullOID && ref.peeled != ref.oid {
+			headerOIDs = append(headerOIDs, ref.peeled)
+		}
+	}
+
+	headers, err := repo.readObjectHeaders(ctx, headerOIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var infos map[OID]refObjectInfo
+	if withDates {
+		// Read the commits that the references peel to, using the
+		// peeled values where we know them, and otherwise reading
+		// any annotated tags along the way:
+		var wanted []OID
+		for _, ref := range resolved {
+			switch {
+			case ref.peeled != NullOID:
+				if headers[ref.peeled].ObjectType == "commit" {
+					wanted = append(wanted, ref.peeled)
+				}
+			case headers[ref.oid].ObjectType == "commit" || headers[ref.oid].ObjectType == "tag":
+				wanted = append(wanted, ref.oid)
+			}
+		}
+		infos, err = repo.readRefObjects(ctx, wanted)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	references := make([]Reference, len(refnames))
+	for i, refname := range refnames {
+		header := headers[oids[i]]
+		references[i] = Reference{
+			Refname:    refname,
+			ObjectType: header.ObjectType,
+			ObjectSize: header.ObjectSize,
+			OID:        oids[i],
+		}
+		if withDates {
+			peeled := resolved[i].peeled
+			if peeled == NullOID {
+				peeled = oids[i]
+			}
+			references[i].CommitterDate = peeledCommitterDate(infos, peeled)
+		}
+	}
+	sort.Slice(references, func(i, j int) bool {
+		return references[i].Refname < references[j].Refname
+	})
+	return references, nil
+}
+
+// readPackedRefs adds the references in the `packed-refs` file at
+// `path`, if it exists, to `refs`, along with their peeled values if
+// the file records them.
+func readPackedRefs(path string, refs map[string]nativeRef) error {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("reading packed-refs: %w", err)
+	}
+	defer f.Close()
+
+	// The traits in the header tell which references' peeled values
+	// are recorded: all of them ("fully-peeled"), those of the tags
+	// ("peeled"), or none. A reference whose peeled value would be
+	// recorded, but isn't, doesn't point at an annotated tag.
+	var peeled, fullyPeeled bool
+	var last string
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			continue
+		case strings.HasPrefix(line, "# pack-refs with:"):
+			for _, trait := range strings.Fields(line[len("# pack-refs with:"):]) {
+				switch trait {
+				case "peeled":
+					peeled = true
+				case "fully-peeled":
+					fullyPeeled = true
+				}
+			}
+			continue
+		case line[0] == '#':
+			continue
+		case line[0] == '^':
+			oid, err := NewOID(line[1:])
+			if err != nil {
+				return fmt.Errorf("malformed peeled value in packed-refs: %q", line)
+			}
+			if ref, ok := refs[last]; ok {
+				ref.peeled = oid
+				refs[last] = ref
+			}
+			continue
+		}
+
+		i := strings.IndexByte(line, ' ')
+		if i == -1 {
+			return fmt.Errorf("malformed line in packed-refs: %q", line)
+		}
+		oid, err := NewOID(line[:i])
+		if err != nil {
+			// Probably a different hash algorithm.
+			return errNativeRefsUnsupported
+		}
+		refname := line[i+1:]
+		last = refname
+		if !strings.HasPrefix(refname, "refs/") {
+			continue
+		}
+		ref := nativeRef{oid: oid}
+		if fullyPeeled || (peeled && strings.HasPrefix(refname, "refs/tags/")) {
+			// Unless a peeled value follows, the reference
+			// doesn't need peeling:
+			ref.peeled = oid
+		}
+		refs[refname] = ref
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading packed-refs: %w", err)
+	}
+	return nil
+}
+
+// readLooseRefs adds the loose references under `refsDir` to `refs`.
+func readLooseRefs(refsDir string, refs map[string]nativeRef) error {
+	return filepath.WalkDir(refsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				// The reference was deleted while we were reading.
+				return nil
+			}
+			return fmt.Errorf("reading loose references: %w", err)
+		}
+		if d.IsDir() || strings.HasSuffix(d.Name(), ".lock") {
+			return nil
+		}
+
+		rel, err := filepath.Rel(refsDir, path)
+		if err != nil {
+			return err
+		}
+		refname := "refs/" + filepath.ToSlash(rel)
+
+		contents, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("reading reference %q: %w", refname, err)
+		}
+		contents = bytes.TrimSpace(contents)
+		if target := bytes.TrimPrefix(contents, []byte("ref: ")); len(target) < len(contents) {
+			refs[refname] = nativeRef{target: string(target)}
+			return nil
+		}
+		oid, err := NewOID(string(contents))
+		if err != nil {
+			// A broken reference, which `git for-each-ref` would
+			// skip, too.
+			delete(refs, refname)
+			return nil
+		}
+		refs[refname] = nativeRef{oid: oid}
+		return nil
+	})
+}
+
+// resolveNativeRef returns the non-symbolic reference that `refname`
+// refers to, either directly or via symbolic references.
+func resolveNativeRef(refs map[string]nativeRef, refname string) (nativeRef, bool) {
+	for i := 0; i <= maxSymrefDepth; i++ {
+		ref, ok := refs[refname]
+		if !ok {
+			return nativeRef{}, false
+		}
+		if ref.target == "" {
+			return ref, true
+		}
+		refname = ref.target
+	}
+	return nativeRef{}, false
+}
+
+// readObjectHeaders returns the headers of the objects named by
+// `oids`, as reported by `git cat-file --batch-check`, without
+// reading the objects' contents.
+func (repo *Repository) readObjectHeaders(ctx context.Context, oids []OID) (map[OID]BatchHeader, error) {
+	headers := make(map[OID]BatchHeader, len(oids))
+	if len(oids) == 0 {
+		return headers, nil
+	}
+
+	// Many references typically point at the same objects, which
+	// only have to be looked up once:
+	input := &bytes.Buffer{}
+	seen := make(map[OID]bool, len(oids))
+	for _, oid := range oids {
+		if !seen[oid] {
+			seen[oid] = true
+			fmt.Fprintf(input, "%s\n", oid)
+		}
+	}
+
+	cmd := repo.GitCommandContext(
+		ctx, "cat-file", "--batch-check=%(objectname) %(objecttype) %(objectsize)",
+	)
+	cmd.Stdin = input
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("reading object headers in %s: %w", repo.GitDir(), err)
+	}
+
+	for _, line := range strings.SplitAfter(string(out), "\n") {
+		if line == "" {
+			continue
+		}
+		if oid, ok := parseMissingHeader(line); ok {
+			// A reference can point at an object that doesn't
+			// exist (e.g., in a corrupt repository). Rather than
+			// failing to list any references, give the object the
+			// type "missing":
+			headers[oid] = BatchHeader{OID: oid, ObjectType: "missing"}
+			continue
+		}
+		header, err := ParseBatchHeader("", line)
+		if err != nil {
+			return nil, err
+		}
+		headers[header.OID] = header
+	}
+	return headers, nil
+}
+
+// refObjectInfo holds what we need to know about an object that a
+// reference peels to (or an annotated tag along the way) to find its
+// committer date.
+type refObjectInfo struct {
+	objectType ObjectType
+
+	// committerDate is set if the object is a commit.
+	committerDate time.Time
+
+	// referent is set if the object is a tag.
+	referent OID
+}
+
+// readRefObjects reads the objects named by `oids`, plus any objects
+// that annotated tags among them refer to (recursively), and returns
+// information about each of them.
+func (repo *Repository) readRefObjects(
+	ctx context.Context, oids []OID,
+) (map[OID]refObjectInfo, error) {
+	infos := make(map[OID]refObjectInfo)
+
+	// Read the objects in rounds, where each round reads the objects
+	// that tags from the previous round refer to:
+	for len(oids) != 0 {
+		var wanted []OID
+		seen := make(map[OID]bool)
+		for _, oid := range oids {
+			if _, ok := infos[oid]; !ok && !seen[oid] {
+				seen[oid] = true
+				wanted = append(wanted, oid)
+			}
+		}
+		if len(wanted) == 0 {
+			break
+		}
+
+		referents, err := repo.readRefObjectsRound(ctx, wanted, infos)
+		if err != nil {
+			return nil, err
+		}
+		oids = referents
+	}
+
+	return infos, nil
+}
+
+// readRefObjectsRound reads the objects named by `oids`, adds
+// information about them to `infos`, and returns the referents of
+// any tags among them.
+func (repo *Repository) readRefObjectsRound(
+	ctx context.Context, oids []OID, infos map[OID]refObjectInfo,
+) ([]OID, error) {
+	objectIter, err := repo.NewBatchObjectIter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		defer objectIter.Close()
+
+		errChan <- func() error {
+			for _, oid := range oids {
+				if err := objectIter.RequestObject(oid); err != nil {
+					return fmt.Errorf("requesting object '%s': %w", oid, err)
+				}
+			}
+			return nil
+		}()
+	}()
+
+	var referents []OID
+	for range oids {
+		obj, ok, err := objectIter.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errors.New("fewer objects read than expected")
+		}
+
+		info := refObjectInfo{
+			objectType: obj.ObjectType,
+		}
+		switch obj.ObjectType {
+		case "commit":
+			info.committerDate, err = parseCommitterDate(obj.OID, obj.Data)
+			if err != nil {
+				return nil, err
+			}
+		case "tag":
+			tag, err := ParseTag(obj.OID, obj.Data)
+			if err != nil {
+				return nil, err
+			}
+			info.referent = tag.Referent
+			referents = append(referents, tag.Referent)
+		}
+		infos[obj.OID] = info
+	}
+
+	if err := <-errChan; err != nil {
+		return nil, err
+	}
+	return referents, nil
+}
+
+// peeledCommitterDate returns the committer date of the commit that
+// `oid` refers to, either directly or via annotated tags, or the zero
+// `time.Time` if it doesn't refer to a commit.
+func peeledCommitterDate(infos map[OID]refObjectInfo, oid OID) time.Time {
+	for {
+		info, ok := infos[oid]
+		switch {
+		case !ok:
+			return time.Time{}
+		case info.objectType == "commit":
+			return info.committerDate
+		case info.objectType == "tag":
+			oid = info.referent
+		default:
+			return time.Time{}
+		}
+	}
+}
+
+// parseCommitterDate returns the committer date of the commit whose
+// contents are `data`. `oid` is used only in error messages.
+func parseCommitterDate(oid OID, data []byte) (time.Time, error) {
+	iter, err := NewObjectHeaderIter("commit "+oid.String(), data)
+	if err != nil {
+		return time.Time{}, err
+	}
+	for iter.HasNext() {
+		key, value, err := iter.Next()
+		if err != nil {
+			return time.Time{}, err
+		}
+		if key != "committer" {
+			continue
+		}
+
+		// The value looks like `Name <email> 1112911993 -0700`:
+		words := strings.Fields(value[strings.LastIndexByte(value, '>')+1:])
+		if len(words) == 0 {
+			break
+		}
+		timestamp, err := strconv.ParseInt(words[0], 10, 64)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("malformed committer in commit %s", oid)
+		}
+		return time.Unix(timestamp, 0), nil
+	}
+	return time.Time{}, nil
+}
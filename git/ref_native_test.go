@@ -0,0 +1,288 @@
+package git_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/internal/testutils"
+)
+
+func readReferences(t *testing.T, repo *git.Repository, withDates bool) []git.Reference {
+	t.Helper()
+
+	newIter := repo.NewReferenceIter
+	if !withDates {
+		newIter = repo.NewReferenceIterWithoutDates
+	}
+	iter, err := newIter(context.Background())
+	require.NoError(t, err)
+
+	var refs []git.Reference
+	for {
+		ref, ok, err := iter.Next()
+		require.NoError(t, err)
+		if !ok {
+			return refs
+		}
+		refs = append(refs, ref)
+	}
+}
+
+func TestNativeReferences(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "native-refs")
+	defer testRepo.Remove(t)
+
+	run := func(args ...string) {
+		t.Helper()
+		cmd := testRepo.AuthoredCommand(t, args...)
+		require.NoError(t, cmd.Run(), "running git %v", args)
+	}
+
+	run("commit", "--allow-empty", "-m", "first")
+	run("tag", "-m", "annotated", "annotated")
+	run("tag", "-m", "tree", "tree-tag", "HEAD^{tree}")
+	run("branch", "packed-then-loose")
+	run("update-ref", "refs/remotes/origin/main", "HEAD")
+	run("symbolic-ref", "refs/remotes/origin/HEAD", "refs/remotes/origin/main")
+	run("pack-refs", "--all")
+
+	// Loose references, one of which overrides a packed one:
+	run("commit", "--allow-empty", "-m", "second")
+	run("branch", "loose")
+	run("update-ref", "refs/heads/packed-then-loose", "HEAD")
+	run("tag", "lightweight")
+	// An annotated tag whose peeled value isn't recorded anywhere:
+	run("tag", "-m", "loose", "loose-annotated")
+
+	// A broken reference, which both backends skip:
+	require.NoError(t, os.WriteFile(
+		filepath.Join(testRepo.Path, ".git", "refs", "heads", "broken"),
+		[]byte("not an object name\n"), 0o644,
+	))
+	run("symbolic-ref", "refs/heads/dangling", "refs/heads/nonexistent")
+
+	repo := testRepo.Repository(t)
+	expected := readReferences(t, repo, true)
+	expectedWithoutDates := readReferences(t, repo, false)
+
+	repo.SetRefBackend(git.RefBackendNative)
+	refs := readReferences(t, repo, true)
+	assert.Equal(t, expected, refs)
+	assert.Equal(t, expectedWithoutDates, readReferences(t, repo, false))
+
+	for i, ref := range expectedWithoutDates {
+		assert.True(t, ref.CommitterDate.IsZero(), "date of %s", ref.Refname)
+		ref.CommitterDate = expected[i].CommitterDate
+		assert.Equal(t, expected[i], ref)
+	}
+
+	var refnames []string
+	for _, ref := range refs {
+		refnames = append(refnames, ref.Refname)
+	}
+	assert.Equal(
+		t,
+		[]string{
+			"refs/heads/loose", "refs/heads/master", "refs/heads/packed-then-loose",
+			"refs/remotes/origin/HEAD", "refs/remotes/origin/main",
+			"refs/tags/annotated", "refs/tags/lightweight", "refs/tags/loose-annotated",
+			"refs/tags/tree-tag",
+		},
+		refnames,
+	)
+}
+
+func TestNativeReferencesMissingObject(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "native-refs-missing-object")
+	defer testRepo.Remove(t)
+
+	testRepo.Commit(t, "--allow-empty", "-m", "first")
+
+	// A reference to an object that doesn't exist is listed, rather
+	// than making the listing fail:
+	missing := "1234567890123456789012345678901234567890"
+	require.NoError(t, os.WriteFile(
+		filepath.Join(testRepo.Path, ".git", "refs", "heads", "missing"),
+		[]byte(missing+"\n"), 0o644,
+	))
+
+	repo := testRepo.Repository(t)
+	repo.SetRefBackend(git.RefBackendNative)
+	for _, withDates := range []bool{true, false} {
+		refs := readReferences(t, repo, withDates)
+		require.Len(t, refs, 2)
+		assert.Equal(t, "refs/heads/master", refs[0].Refname)
+		assert.Equal(t, git.ObjectType("commit"), refs[0].ObjectType)
+		assert.Equal(t, "refs/heads/missing", refs[1].Refname)
+		assert.Equal(t, missing, refs[1].OID.String())
+		assert.Equal(t, git.ObjectType("missing"), refs[1].ObjectType)
+		assert.True(t, refs[1].CommitterDate.IsZero())
+	}
+}
+
+func TestPackedRefsPeeled(t *testing.T) {
+	t.Parallel()
+
+	const (
+		commit = "1111111111111111111111111111111111111111"
+		tag    = "2222222222222222222222222222222222222222"
+		peeled = "3333333333333333333333333333333333333333"
+	)
+	oid := func(s string) git.OID {
+		t.Helper()
+		oid, err := git.NewOID(s)
+		require.NoError(t, err)
+		return oid
+	}
+	refs := commit + " refs/heads/main\n" +
+		tag + " refs/tags/annotated\n" +
+		"^" + peeled + "\n" +
+		commit + " refs/tags/lightweight\n"
+
+	for _, p := range []struct {
+		name     string
+		header   string
+		expected map[string]git.OID
+	}{
+		{
+			name:   "fully-peeled",
+			header: "# pack-refs with: peeled fully-peeled sorted \n",
+			expected: map[string]git.OID{
+				"refs/heads/main":       oid(commit),
+				"refs/tags/annotated":   oid(peeled),
+				"refs/tags/lightweight": oid(commit),
+			},
+		},
+		{
+			name:   "peeled",
+			header: "# pack-refs with: peeled \n",
+			expected: map[string]git.OID{
+				"refs/heads/main":       git.NullOID,
+				"refs/tags/annotated":   oid(peeled),
+				"refs/tags/lightweight": oid(commit),
+			},
+		},
+		{
+			// Without the trait, the peeled values can't be trusted
+			// to be complete, but those that are there are right:
+			name:   "no-traits",
+			header: "",
+			expected: map[string]git.OID{
+				"refs/heads/main":       git.NullOID,
+				"refs/tags/annotated":   oid(peeled),
+				"refs/tags/lightweight": git.NullOID,
+			},
+		},
+	} {
+		p := p
+		t.Run(p.name, func(t *testing.T) {
+			t.Parallel()
+
+			path := filepath.Join(t.TempDir(), "packed-refs")
+			require.NoError(t, os.WriteFile(path, []byte(p.header+refs), 0o644))
+			peeledValues, err := git.PackedRefsPeeled(path)
+			require.NoError(t, err)
+			assert.Equal(t, p.expected, peeledValues)
+		})
+	}
+}
+
+// BenchmarkReferenceIter compares the backends for reading
+// references, with and without the dates of the commits that they
+// point at, in a repository with many packed references, half of
+// which point at annotated tags.
+func BenchmarkReferenceIter(b *testing.B) {
+	testRepo := testutils.NewTestRepo(b, true, "ref-iter-bench")
+	defer testRepo.Remove(b)
+
+	cmd := testRepo.AuthoredCommand(b, "commit-tree", "-m", "commit", "4b825dc642cb6eb9a060e54bf8d69288fbee4904")
+	out, err := cmd.Output()
+	require.NoError(b, err)
+	commit := strings.TrimSpace(string(out))
+
+	var updates strings.Builder
+	for i := 0; i < 5000; i++ {
+		fmt.Fprintf(&updates, "create refs/heads/branch-%d %s\n", i, commit)
+	}
+	cmd = testRepo.GitCommand(b, "update-ref", "--stdin")
+	cmd.Stdin = strings.NewReader(updates.String())
+	require.NoError(b, cmd.Run())
+
+	updates.Reset()
+	for i := 0; i < 5000; i++ {
+		tag := testRepo.CreateObject(b, "tag", func(w io.Writer) error {
+			_, err := fmt.Fprintf(
+				w,
+				"object %s\ntype commit\ntag tag-%d\n"+
+					"tagger Example <example@example.com> 1112911993 -0700\n\ntag\n",
+				commit, i,
+			)
+			return err
+		})
+		fmt.Fprintf(&updates, "create refs/tags/tag-%d %s\n", i, tag)
+	}
+	cmd = testRepo.GitCommand(b, "update-ref", "--stdin")
+	cmd.Stdin = strings.NewReader(updates.String())
+	require.NoError(b, cmd.Run())
+	require.NoError(b, testRepo.GitCommand(b, "pack-refs", "--all").Run())
+	require.NoError(b, testRepo.GitCommand(b, "repack", "-adq").Run())
+
+	for _, backend := range []git.RefBackend{git.RefBackendGit, git.RefBackendNative} {
+		for _, withDates := range []bool{true, false} {
+			name := string(backend)
+			if !withDates {
+				name += "-without-dates"
+			}
+			b.Run(name, func(b *testing.B) {
+				repo := testRepo.Repository(b)
+				repo.SetRefBackend(backend)
+				newIter := repo.NewReferenceIter
+				if !withDates {
+					newIter = repo.NewReferenceIterWithoutDates
+				}
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					iter, err := newIter(context.Background())
+					require.NoError(b, err)
+					n := 0
+					for {
+						_, ok, err := iter.Next()
+						require.NoError(b, err)
+						if !ok {
+							break
+						}
+						n++
+					}
+					require.Equal(b, 10000, n)
+				}
+			})
+		}
+	}
+}
+
+func TestParseRefBackend(t *testing.T) {
+	t.Parallel()
+
+	for _, s := range []string{"native", "git"} {
+		backend, err := git.ParseRefBackend(s)
+		assert.NoError(t, err)
+		assert.Equal(t, git.RefBackend(s), backend)
+	}
+
+	_, err := git.ParseRefBackend("reftable")
+	assert.Error(t, err)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/github/git-sizer/counts"
 )
@@ -21,16 +22,24 @@ type Reference struct {
 
 	// OID is the OID of the referred-to object.
 	OID OID
+
+	// CommitterDate is the committer date of the commit that the
+	// reference points at, either directly or via an annotated tag.
+	// It is the zero `time.Time` if the reference doesn't point at
+	// a commit.
+	CommitterDate time.Time
 }
 
 // ParseReference parses `line` (a non-LF-terminated line) into a
 // `Reference`. It is assumed that `line` is formatted like the output
 // of
 //
-//     git for-each-ref --format='%(objectname) %(objecttype) %(objectsize) %(refname)'
+//     git for-each-ref --format='%(objectname) %(objecttype) %(objectsize) %(committerdate:unix)%(*committerdate:unix) %(refname)'
+//
+// Everything after the fourth space is taken to be the refname.
 func ParseReference(line string) (Reference, error) {
-	words := strings.Split(line, " ")
-	if len(words) != 4 {
+	words := strings.SplitN(line, " ", 5)
+	if len(words) != 5 {
 		return Reference{}, fmt.Errorf("line improperly formatted: %#v", line)
 	}
 	oid, err := NewOID(words[0])
@@ -42,11 +51,20 @@ func ParseReference(line string) (Reference, error) {
 	if err != nil {
 		return Reference{}, fmt.Errorf("object size improperly formatted: %#v", words[2])
 	}
-	refname := words[3]
+	var committerDate time.Time
+	if words[3] != "" {
+		timestamp, err := strconv.ParseInt(words[3], 10, 64)
+		if err != nil {
+			return Reference{}, fmt.Errorf("committer date improperly formatted: %#v", words[3])
+		}
+		committerDate = time.Unix(timestamp, 0)
+	}
+	refname := words[4]
 	return Reference{
-		Refname:    refname,
-		ObjectType: objectType,
-		ObjectSize: counts.Count32(objectSize),
-		OID:        oid,
+		Refname:       refname,
+		ObjectType:    objectType,
+		ObjectSize:    counts.Count32(objectSize),
+		OID:           oid,
+		CommitterDate: committerDate,
 	}, nil
 }
@@ -0,0 +1,63 @@
+package git_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+func TestParseReference(t *testing.T) {
+	t.Parallel()
+
+	oid, err := git.NewOID("6fc39af32cfa576495b52db5841d1be2832fc00b")
+	require.NoError(t, err)
+
+	for _, p := range []struct {
+		name     string
+		line     string
+		expected git.Reference
+	}{
+		{
+			name: "commit",
+			line: "6fc39af32cfa576495b52db5841d1be2832fc00b commit 112 1112911993 refs/heads/master",
+			expected: git.Reference{
+				Refname:       "refs/heads/master",
+				ObjectType:    "commit",
+				ObjectSize:    counts.Count32(112),
+				OID:           oid,
+				CommitterDate: time.Unix(1112911993, 0),
+			},
+		},
+		{
+			name: "blob",
+			line: "6fc39af32cfa576495b52db5841d1be2832fc00b blob 3  refs/tags/blob",
+			expected: git.Reference{
+				Refname:    "refs/tags/blob",
+				ObjectType: "blob",
+				ObjectSize: counts.Count32(3),
+				OID:        oid,
+			},
+		},
+	} {
+		p := p
+		t.Run(p.name, func(t *testing.T) {
+			ref, err := git.ParseReference(p.line)
+			if assert.NoError(t, err) {
+				assert.Equal(t, p.expected, ref)
+			}
+		})
+	}
+
+	for _, line := range []string{
+		"6fc39af32cfa576495b52db5841d1be2832fc00b commit 112 refs/heads/master",
+		"6fc39af32cfa576495b52db5841d1be2832fc00b commit 112 yesterday refs/heads/master",
+	} {
+		_, err := git.ParseReference(line)
+		assert.Errorf(t, err, "parsing %q", line)
+	}
+}
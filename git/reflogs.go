@@ -0,0 +1,218 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/github/git-sizer/counts"
+)
+
+// ReflogEntry is one entry of a reflog, recording that a reference
+// was changed from `Old` to `New` at `Time`.
+type ReflogEntry struct {
+	// Refname is the name of the reference whose reflog contains
+	// the entry (e.g., "HEAD" or "refs/stash").
+	Refname string
+
+	Old  OID
+	New  OID
+	Time time.Time
+}
+
+// ReflogEntries returns the entries of all of the reflogs in `repo`.
+// Note that the reflog of a branch is deleted along with the branch,
+// but the reflog of `HEAD` still remembers the commits that were
+// checked out on it. Only reflogs that are stored as files are read;
+// for a repository that uses another reference backend, the result is
+// empty.
+func (repo *Repository) ReflogEntries(ctx context.Context) ([]ReflogEntry, error) {
+	logsDir, err := repo.gitPath(ctx, "logs")
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []ReflogEntry
+	err = filepath.WalkDir(logsDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(logsDir, path)
+		if err != nil {
+			return err
+		}
+		refname := filepath.ToSlash(rel)
+		if refname != "HEAD" && !strings.HasPrefix(refname, "refs/") {
+			return nil
+		}
+		refEntries, err := readReflog(path, refname)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, refEntries...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("reading reflogs: %w", err)
+	}
+	return entries, nil
+}
+
+// readReflog reads the reflog file at `path`, which holds the reflog
+// of `refname`. Each line has the form
+//
+//	<old> SP <new> SP <name> SP <<email>> SP <timestamp> SP <tz> TAB <message> LF
+//
+// Lines that can't be parsed are skipped, as Git itself does.
+func readReflog(path, refname string) ([]ReflogEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []ReflogEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '\t'); i >= 0 {
+			line = line[:i]
+		}
+		words := strings.Split(line, " ")
+		if len(words) < 4 {
+			continue
+		}
+		oldOID, err := NewOID(words[0])
+		if err != nil {
+			continue
+		}
+		newOID, err := NewOID(words[1])
+		if err != nil {
+			continue
+		}
+		timestamp, err := strconv.ParseInt(words[len(words)-2], 10, 64)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, ReflogEntry{
+			Refname: refname,
+			Old:     oldOID,
+			New:     newOID,
+			Time:    time.Unix(timestamp, 0),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// CountReachable counts the objects that are reachable from `tips`
+// but not from `excluded`, and the number of bytes that they occupy
+// in the object database. Tips that don't exist are ignored.
+func (repo *Repository) CountReachable(
+	ctx context.Context, tips, excluded []OID,
+) (objectCount, diskSize counts.Count64, err error) {
+	if len(tips) == 0 {
+		return 0, 0, nil
+	}
+
+	revs := &bytes.Buffer{}
+	for _, oid := range tips {
+		fmt.Fprintf(revs, "%s\n", oid)
+	}
+	for _, oid := range excluded {
+		fmt.Fprintf(revs, "^%s\n", oid)
+	}
+
+	objectCount, err = repo.revListCount(ctx, revs.Bytes(), "--objects", "--ignore-missing")
+	if err != nil {
+		return 0, 0, err
+	}
+	diskSize, err = repo.revListDiskUsage(ctx, revs.Bytes())
+	if err != nil {
+		return 0, 0, err
+	}
+	return objectCount, diskSize, nil
+}
+
+// IsAncestor returns true iff the commit `ancestor` is reachable
+// from the commit `descendant` (which includes the case that they are
+// the same). Commits that don't exist are ignored, as
+// `CountReachable()` does; in particular, a missing `ancestor` is
+// considered to be an ancestor of anything.
+func (repo *Repository) IsAncestor(ctx context.Context, ancestor, descendant OID) (bool, error) {
+	revs := fmt.Sprintf("%s\n^%s\n", ancestor, descendant)
+	n, err := repo.revListCount(ctx, []byte(revs), "--ignore-missing")
+	if err != nil {
+		return false, err
+	}
+	return n == 0, nil
+}
+
+// revListDiskUsage returns the number of bytes that the objects
+// reachable from `revs` (given in the format expected by `git
+// rev-list --stdin`) occupy in the object database. If `git rev-list
+// --disk-usage` isn't supported, the objects are listed and their
+// sizes looked up using `git cat-file`.
+func (repo *Repository) revListDiskUsage(ctx context.Context, revs []byte) (counts.Count64, error) {
+	if repo.Capabilities(ctx).RevListDiskUsage {
+		cmd := repo.GitCommandContext(
+			ctx, "rev-list", "--objects", "--disk-usage", "--ignore-missing", "--stdin",
+		)
+		cmd.Stdin = bytes.NewReader(revs)
+		out, err := cmd.Output()
+		if err != nil {
+			return 0, fmt.Errorf("computing disk usage in %s: %w", repo.GitDir(), err)
+		}
+		n, err := strconv.ParseUint(string(bytes.TrimSpace(out)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("unexpected output from 'git rev-list --disk-usage': %q", out)
+		}
+		return counts.NewCount64(n), nil
+	}
+
+	args := []string{"rev-list", "--objects", "--ignore-missing", "--stdin"}
+	if repo.Capabilities(ctx).RevListNoObjectNames {
+		args = append(args, "--no-object-names")
+	}
+	cmd := repo.GitCommandContext(ctx, args...)
+	cmd.Stdin = bytes.NewReader(revs)
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("listing objects in %s: %w", repo.GitDir(), err)
+	}
+	hexLen := repo.ObjectFormat(ctx).HexLen()
+	var oids []OID
+	for _, line := range bytes.Split(out, []byte{'\n'}) {
+		name, ok := revListObjectName(line, hexLen)
+		if !ok {
+			continue
+		}
+		oid, err := NewOID(string(name))
+		if err != nil {
+			return 0, err
+		}
+		// A line that is the continuation of a path that contains
+		// a newline but looks like an object name is reported as
+		// missing, and therefore ignored:
+		oids = append(oids, oid)
+	}
+
+	return repo.ObjectsDiskSize(ctx, oids)
+}
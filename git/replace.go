@@ -0,0 +1,72 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ReplaceRefs returns the names of the replace references in `repo`;
+// i.e., those under `refs/replace/`. The commands that git-sizer runs
+// ignore them (see `GitCommandContext()`), so other `git` commands
+// might show a different history than the one that is measured.
+func (repo *Repository) ReplaceRefs(ctx context.Context) ([]string, error) {
+	cmd := repo.GitCommandContext(ctx, "for-each-ref", "--format=%(refname)", "refs/replace/")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing replace references: %w", err)
+	}
+
+	refnames := []string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if line != "" {
+			refnames = append(refnames, line)
+		}
+	}
+	return refnames, nil
+}
+
+// Grafts returns the path of `repo`'s grafts file (`info/grafts`)
+// and the number of grafts that it contains, or the empty string and
+// zero if there is no such file. Like replace references, grafts are
+// ignored by the commands that git-sizer runs.
+func (repo *Repository) Grafts(ctx context.Context) (string, int, error) {
+	// `git rev-parse --git-path info/grafts` would honor
+	// `GIT_GRAFT_FILE`, which we set to `/dev/null`, so look up the
+	// `info` directory instead:
+	info, err := repo.gitPath(ctx, "info")
+	if err != nil {
+		return "", 0, err
+	}
+	path := filepath.Join(info, "grafts")
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", 0, nil
+	} else if err != nil {
+		return "", 0, err
+	}
+
+	count := 0
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			count++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", 0, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if count == 0 {
+		return "", 0, nil
+	}
+	return path, count, nil
+}
@@ -0,0 +1,270 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// The reasons why a repository can't be opened. A `*RepositoryError`
+// matches one of them according to `errors.Is()`.
+var (
+	ErrNotRepository    = errors.New("not a Git repository")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrCorruptHead      = errors.New("corrupt HEAD")
+	ErrCorruptConfig    = errors.New("corrupt config")
+	ErrMissingObjects   = errors.New("object store is missing")
+)
+
+// RepositoryError is returned by the `NewRepositoryFrom*()`
+// functions when the path that they are given doesn't lead to a
+// usable repository.
+type RepositoryError struct {
+	// Path is the repository's `GIT_DIR`, if it was found, or else
+	// the path in which a repository was sought.
+	Path string
+
+	// Kind is the reason why the repository can't be opened; one of
+	// `ErrNotRepository`, `ErrPermissionDenied`, `ErrCorruptHead`,
+	// `ErrCorruptConfig`, or `ErrMissingObjects`.
+	Kind error
+
+	// Err, if set, describes the problem in more detail (e.g., it is
+	// the error message from `git`).
+	Err error
+}
+
+func (e *RepositoryError) Error() string {
+	msg := fmt.Sprintf("'%s': %s", e.Path, e.Kind)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg + "\nhint: " + e.hint()
+}
+
+// hint returns a suggestion for what the user can do about `e`.
+func (e *RepositoryError) hint() string {
+	switch e.Kind {
+	case ErrPermissionDenied:
+		if e.Err != nil && strings.Contains(e.Err.Error(), "safe.directory") {
+			return "if you trust the repository, add it to 'safe.directory' as described above"
+		}
+		return "run git-sizer as a user who can read all of the repository's files"
+	case ErrCorruptHead:
+		return fmt.Sprintf(
+			"point HEAD at a branch, e.g., by writing 'ref: refs/heads/main' to '%s'",
+			filepath.Join(e.Path, "HEAD"),
+		)
+	case ErrCorruptConfig:
+		return "fix or remove the offending line of the config file named above"
+	case ErrMissingObjects:
+		return "restore the 'objects' directory from a backup, or clone the repository again"
+	default:
+		return "run git-sizer within a Git repository, or set 'GIT_DIR' to the repository's path"
+	}
+}
+
+// Is reports whether `target` is the reason for `e`.
+func (e *RepositoryError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *RepositoryError) Unwrap() error {
+	return e.Err
+}
+
+// notRepositoryRE matches the message with which `git` refuses to
+// use a `GIT_DIR` (or the directory that it found) as a repository.
+var notRepositoryRE = regexp.MustCompile(`not a git repository(?:: '([^']*)')?`)
+
+// classifyGitError turns the error output `stderr` of a `git`
+// command that failed to open the repository at (or within) `path`
+// into a `*RepositoryError`, or returns nil if it isn't about that.
+// If `git` only says that there is no repository, look for one that
+// it rejected, in `path` or in the directories above it (like `git`
+// does, unless `explicit` is set), to find out why.
+func classifyGitError(path string, explicit bool, stderr []byte) error {
+	detail := bytes.TrimSpace(stderr)
+	detail = bytes.TrimPrefix(detail, []byte("fatal: "))
+	err := errors.New(string(detail))
+
+	switch {
+	case bytes.Contains(stderr, []byte("bad config")):
+		return &RepositoryError{Path: path, Kind: ErrCorruptConfig, Err: err}
+	case bytes.Contains(stderr, []byte("Permission denied")),
+		bytes.Contains(stderr, []byte("dubious ownership")):
+		return &RepositoryError{Path: path, Kind: ErrPermissionDenied, Err: err}
+	}
+
+	m := notRepositoryRE.FindSubmatch(stderr)
+	if m == nil {
+		return nil
+	}
+	if len(m[1]) != 0 {
+		// `git` names the `GIT_DIR` that it rejected:
+		path, explicit = string(m[1]), true
+	}
+
+	if explicit {
+		// Don't blame a directory that isn't meant to be a repository
+		// for not being a good one:
+		if fi, err := os.Stat(path); err != nil || !fi.IsDir() || looksLikeGitDir(path) {
+			if e := diagnoseGitDir(path); e != nil {
+				return e
+			}
+		}
+	} else {
+		for dir := path; ; {
+			if e := diagnoseGitDir(filepath.Join(dir, ".git")); e != nil {
+				return e
+			}
+			if looksLikeGitDir(dir) {
+				if e := diagnoseGitDir(dir); e != nil {
+					return e
+				}
+			}
+			parent := filepath.Dir(dir)
+			if parent == dir {
+				break
+			}
+			dir = parent
+		}
+	}
+	if explicit {
+		return &RepositoryError{Path: path, Kind: ErrNotRepository}
+	}
+	return &RepositoryError{
+		Path: path,
+		Kind: ErrNotRepository,
+		Err:  errors.New("nor is any of its parent directories"),
+	}
+}
+
+// looksLikeGitDir reports whether `dir` looks like it is meant to be
+// a bare repository (even if it is a broken one). If we aren't
+// allowed to look, assume that it is.
+func looksLikeGitDir(dir string) bool {
+	exists := func(name string) bool {
+		_, err := os.Lstat(filepath.Join(dir, name))
+		return err == nil || errors.Is(err, fs.ErrPermission)
+	}
+	return exists("HEAD") || exists("objects") && exists("refs")
+}
+
+// diagnoseGitDir returns a `*RepositoryError` describing why the
+// `GIT_DIR` `gitDir` can't be used as a repository, or nil if there
+// is no such directory or no problem can be found with it.
+func diagnoseGitDir(gitDir string) error {
+	fi, err := os.Stat(gitDir)
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return &RepositoryError{Path: gitDir, Kind: ErrPermissionDenied, Err: err}
+	case err != nil:
+		return nil
+	case !fi.IsDir():
+		// This might be a `.git` file that refers to the real
+		// `GIT_DIR` (e.g., of a linked worktree or a submodule):
+		contents, err := os.ReadFile(gitDir)
+		if err != nil {
+			return nil
+		}
+		target := strings.TrimSpace(string(contents))
+		if !strings.HasPrefix(target, "gitdir: ") {
+			return nil
+		}
+		return diagnoseGitDir(smartJoin(filepath.Dir(gitDir), strings.TrimPrefix(target, "gitdir: ")))
+	}
+
+	if err := checkHead(gitDir); err != nil {
+		return err
+	}
+
+	// The objects of a linked worktree are in the common directory:
+	commonDir := gitDir
+	if contents, err := os.ReadFile(filepath.Join(gitDir, "commondir")); err == nil {
+		commonDir = smartJoin(gitDir, strings.TrimSpace(string(contents)))
+	}
+	return checkObjects(gitDir, filepath.Join(commonDir, "objects"))
+}
+
+// checkObjects returns a `*RepositoryError` if `objects`, the object
+// directory of the repository at `gitDir`, is missing or can't be
+// read.
+func checkObjects(gitDir, objects string) error {
+	f, err := os.Open(objects)
+	if err == nil {
+		_, err = f.Readdirnames(1)
+		f.Close()
+		if err == io.EOF {
+			err = nil
+		}
+	}
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, fs.ErrPermission):
+		return &RepositoryError{Path: gitDir, Kind: ErrPermissionDenied, Err: err}
+	default:
+		return &RepositoryError{
+			Path: gitDir,
+			Kind: ErrMissingObjects,
+			Err:  fmt.Errorf("'%s' is not a directory", objects),
+		}
+	}
+}
+
+// checkHead returns a `*RepositoryError` if the `HEAD` file in
+// `gitDir` is missing, unreadable, or neither a symbolic reference
+// nor an object name.
+func checkHead(gitDir string) error {
+	path := filepath.Join(gitDir, "HEAD")
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&fs.ModeSymlink != 0 {
+		// Old versions of Git made `HEAD` a symlink to the branch.
+		return nil
+	}
+
+	contents, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrPermission):
+		return &RepositoryError{Path: gitDir, Kind: ErrPermissionDenied, Err: err}
+	case errors.Is(err, fs.ErrNotExist):
+		return &RepositoryError{
+			Path: gitDir, Kind: ErrCorruptHead, Err: errors.New("there is no HEAD file"),
+		}
+	case err != nil:
+		return &RepositoryError{Path: gitDir, Kind: ErrCorruptHead, Err: err}
+	}
+
+	head := strings.TrimSpace(string(contents))
+	if strings.HasPrefix(head, "ref: refs/") || isObjectName(head) {
+		return nil
+	}
+	if len(head) > 40 {
+		head = head[:40] + "..."
+	}
+	return &RepositoryError{
+		Path: gitDir,
+		Kind: ErrCorruptHead,
+		Err:  fmt.Errorf("HEAD contains %q, which is neither a reference nor an object name", head),
+	}
+}
+
+// isObjectName reports whether `s` is a full SHA-1 or SHA-256 object
+// name in hex.
+func isObjectName(s string) bool {
+	if len(s) != 40 && len(s) != 64 {
+		return false
+	}
+	for _, c := range s {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
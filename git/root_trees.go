@@ -0,0 +1,47 @@
+package git
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// RootTrees returns the names of the root trees of `commits`, in the
+// same order. Tags are peeled. The entry for any of `commits` that
+// doesn't lead to a commit is `NullOID`.
+func (repo *Repository) RootTrees(ctx context.Context, commits []OID) ([]OID, error) {
+	if len(commits) == 0 {
+		return nil, nil
+	}
+
+	input := &bytes.Buffer{}
+	for _, oid := range commits {
+		fmt.Fprintf(input, "%s^{commit}^{tree}\n", oid)
+	}
+
+	cmd := repo.GitCommandContext(ctx, "cat-file", "--batch-check=%(objectname)")
+	cmd.Stdin = input
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("reading root trees in %s: %w", repo.GitDir(), err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	if len(lines) != len(commits) {
+		return nil, fmt.Errorf(
+			"'git cat-file' output %d lines for %d commits", len(lines), len(commits),
+		)
+	}
+	trees := make([]OID, len(commits))
+	for i, line := range lines {
+		if strings.HasSuffix(line, " missing") {
+			continue
+		}
+		trees[i], err = NewOID(line)
+		if err != nil {
+			return nil, fmt.Errorf("unexpected output from 'git cat-file': %q", line)
+		}
+	}
+	return trees, nil
+}
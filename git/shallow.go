@@ -0,0 +1,98 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+)
+
+// ShallowCommits returns the commits at the boundary of a shallow
+// clone; i.e., the commits whose parents were not fetched. It returns
+// an empty slice if `repo` is a full clone.
+func (repo *Repository) ShallowCommits(ctx context.Context) ([]OID, error) {
+	path, err := repo.gitPath(ctx, "shallow")
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []OID{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	oids := []OID{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		oid, err := NewOID(line)
+		if err != nil {
+			return nil, fmt.Errorf("reading %s: %w", path, err)
+		}
+		oids = append(oids, oid)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return oids, nil
+}
+
+// CountBeyond counts the commits in `repo` that are ancestors of the
+// commits in `boundary` (not including `boundary` itself), and the
+// objects (including those commits) that are reachable from them but
+// not from the trees of the `boundary` commits. If `boundary` is the
+// boundary of a shallow clone of `repo`, these are the commits and
+// objects that the clone is missing (in its shallow history).
+func (repo *Repository) CountBeyond(
+	ctx context.Context, boundary []OID,
+) (commitCount, objectCount counts.Count64, err error) {
+	parents := &bytes.Buffer{}
+	withTrees := &bytes.Buffer{}
+	for _, oid := range boundary {
+		fmt.Fprintf(parents, "%s^@\n", oid)
+		fmt.Fprintf(withTrees, "%s^@\n^%s^{tree}\n", oid, oid)
+	}
+
+	commitCount, err = repo.revListCount(ctx, parents.Bytes())
+	if err != nil {
+		return 0, 0, err
+	}
+	objectCount, err = repo.revListCount(ctx, withTrees.Bytes(), "--objects")
+	if err != nil {
+		return 0, 0, err
+	}
+	return commitCount, objectCount, nil
+}
+
+// revListCount runs `git rev-list --count` with `args`, reading the
+// revisions from `revs`, and returns the count.
+func (repo *Repository) revListCount(
+	ctx context.Context, revs []byte, args ...string,
+) (counts.Count64, error) {
+	cmd := repo.GitCommandContext(
+		ctx, append([]string{"rev-list", "--count", "--stdin"}, args...)...,
+	)
+	cmd.Stdin = bytes.NewReader(revs)
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("counting commits in %s: %w", repo.GitDir(), err)
+	}
+	n, err := strconv.ParseUint(string(bytes.TrimSpace(out)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected output from 'git rev-list --count': %q", out)
+	}
+	return counts.NewCount64(n), nil
+}
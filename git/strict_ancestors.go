@@ -0,0 +1,48 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+)
+
+// StrictAncestors returns the members of `commits` that are
+// reachable from another member (i.e., those that are ancestors of
+// another member but not identical to it). This takes one walk of
+// the history of `commits`.
+func (repo *Repository) StrictAncestors(ctx context.Context, commits []OID) (map[OID]bool, error) {
+	if len(commits) == 0 {
+		return nil, nil
+	}
+
+	// A commit is a strict ancestor of another one iff it is
+	// reachable from one of the latter's parents, so walk the
+	// history from the parents of all of `commits` at once:
+	stdin := &bytes.Buffer{}
+	wanted := make(map[OID]bool, len(commits))
+	for _, oid := range commits {
+		fmt.Fprintf(stdin, "%s^@\n", oid)
+		wanted[oid] = true
+	}
+
+	cmd := repo.GitCommandContext(ctx, "rev-list", "--stdin")
+	cmd.Stdin = stdin
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing ancestors: %w", err)
+	}
+
+	ancestors := make(map[OID]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		oid, err := NewOID(scanner.Text())
+		if err != nil {
+			return nil, fmt.Errorf("unexpected output from 'git rev-list': %q", scanner.Text())
+		}
+		if wanted[oid] {
+			ancestors[oid] = true
+		}
+	}
+	return ancestors, scanner.Err()
+}
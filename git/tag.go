@@ -1,6 +1,7 @@
 package git
 
 import (
+	"bytes"
 	"fmt"
 
 	"github.com/github/git-sizer/counts"
@@ -11,6 +12,49 @@ type Tag struct {
 	Size         counts.Count32
 	Referent     OID
 	ReferentType ObjectType
+
+	// NonstandardHeaderCount is the number of headers that Git
+	// itself doesn't write, plus the number of repeated headers that
+	// should appear only once.
+	NonstandardHeaderCount counts.Count32
+
+	// Signed is true iff the tag carries a signature, either
+	// appended to its message (as `git tag -s` does) or in a
+	// `gpgsig` header.
+	Signed bool
+}
+
+// standardTagHeaders are the headers that Git writes in tags. None of
+// them may appear more than once.
+var standardTagHeaders = map[string]bool{
+	"object":        false,
+	"type":          false,
+	"tag":           false,
+	"tagger":        false,
+	"gpgsig":        false,
+	"gpgsig-sha256": false,
+}
+
+// tagSignatureMarkers are the lines (including the preceding LF) that
+// start the signatures that Git appends to tag messages (OpenPGP,
+// X.509, and SSH).
+var tagSignatureMarkers = [][]byte{
+	[]byte("\n-----BEGIN PGP SIGNATURE-----\n"),
+	[]byte("\n-----BEGIN PGP MESSAGE-----\n"),
+	[]byte("\n-----BEGIN SIGNED MESSAGE-----\n"),
+	[]byte("\n-----BEGIN SSH SIGNATURE-----\n"),
+}
+
+// hasTagSignature returns true iff `body`, the part of a tag following
+// its headers (starting with the LF that ends them), contains a line
+// that starts a signature.
+func hasTagSignature(body []byte) bool {
+	for _, marker := range tagSignatureMarkers {
+		if bytes.Contains(body, marker) {
+			return true
+		}
+	}
+	return false
 }
 
 // ParseTag parses the Git tag object whose contents are contained in
@@ -20,31 +64,44 @@ func ParseTag(oid OID, data []byte) (*Tag, error) {
 	var referentFound bool
 	var referentType ObjectType
 	var referentTypeFound bool
-	iter, err := NewObjectHeaderIter(oid.String(), data)
+	var signed bool
+	iter, err := NewObjectHeaderIter("tag "+oid.String(), data)
 	if err != nil {
 		return nil, err
 	}
+	headerSize := len(iter.data)
+	var headers headerCounter
 	for iter.HasNext() {
+		offset := iter.Offset()
 		key, value, err := iter.Next()
 		if err != nil {
 			return nil, err
 		}
+		headers.add(standardTagHeaders, key)
 		switch key {
 		case "object":
 			if referentFound {
-				return nil, fmt.Errorf("multiple referents found in tag %s", oid)
+				return nil, fmt.Errorf(
+					"multiple referents found in tag %s (at offset %d)", oid, offset,
+				)
 			}
 			referent, err = NewOID(value)
 			if err != nil {
-				return nil, fmt.Errorf("malformed object header in tag %s", oid)
+				return nil, fmt.Errorf(
+					"malformed object header in tag %s at offset %d", oid, offset,
+				)
 			}
 			referentFound = true
 		case "type":
 			if referentTypeFound {
-				return nil, fmt.Errorf("multiple types found in tag %s", oid)
+				return nil, fmt.Errorf(
+					"multiple types found in tag %s (at offset %d)", oid, offset,
+				)
 			}
 			referentType = ObjectType(value)
 			referentTypeFound = true
+		case "gpgsig", "gpgsig-sha256":
+			signed = true
 		}
 	}
 	if !referentFound {
@@ -54,8 +111,10 @@ func ParseTag(oid OID, data []byte) (*Tag, error) {
 		return nil, fmt.Errorf("no type found in tag %s", oid)
 	}
 	return &Tag{
-		Size:         counts.NewCount32(uint64(len(data))),
-		Referent:     referent,
-		ReferentType: referentType,
+		Size:                   counts.NewCount32(uint64(len(data))),
+		Referent:               referent,
+		ReferentType:           referentType,
+		NonstandardHeaderCount: headers.nonstandard,
+		Signed:                 signed || hasTagSignature(data[headerSize:]),
 	}, nil
 }
@@ -1,7 +1,7 @@
 package git
 
 import (
-	"errors"
+	"fmt"
 	"strconv"
 	"strings"
 
@@ -10,13 +10,15 @@ import (
 
 // Tree represents a Git tree object.
 type Tree struct {
+	oid  OID
 	data string
 }
 
 // ParseTree parses the tree object whose contents are contained in
-// `data`. `oid` is currently unused.
+// `data`. The entries are only parsed when they are iterated over.
+// `oid` is used only in error messages.
 func ParseTree(oid OID, data []byte) (*Tree, error) {
-	return &Tree{string(data)}, nil
+	return &Tree{oid: oid, data: string(data)}, nil
 }
 
 // Size returns the size of the tree object.
@@ -35,6 +37,10 @@ type TreeEntry struct {
 
 // TreeIter is an iterator over the entries in a Git tree object.
 type TreeIter struct {
+	// The OID and size of the tree, for error messages.
+	oid  OID
+	size int
+
 	// The as-yet-unread part of the tree's data.
 	data string
 }
@@ -42,10 +48,21 @@ type TreeIter struct {
 // Iter returns an iterator over the entries in `tree`.
 func (tree *Tree) Iter() *TreeIter {
 	return &TreeIter{
+		oid:  tree.oid,
+		size: len(tree.data),
 		data: tree.data,
 	}
 }
 
+// errorf returns an error about the malformed entry at the current
+// position of `iter`, naming the tree and the byte offset.
+func (iter *TreeIter) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf(
+		"malformed tree %s at offset %d: %s",
+		iter.oid, iter.size-len(iter.data), fmt.Sprintf(format, args...),
+	)
+}
+
 // NextEntry returns either the next entry in a Git tree, or a `false`
 // boolean value if there are no more entries.
 func (iter *TreeIter) NextEntry() (TreeEntry, bool, error) {
@@ -57,25 +74,25 @@ func (iter *TreeIter) NextEntry() (TreeEntry, bool, error) {
 
 	spAt := strings.IndexByte(iter.data, ' ')
 	if spAt < 0 {
-		return TreeEntry{}, false, errors.New("failed to find SP after mode")
+		return TreeEntry{}, false, iter.errorf("failed to find SP after mode")
 	}
 	mode, err := strconv.ParseUint(iter.data[:spAt], 8, 32)
 	if err != nil {
-		return TreeEntry{}, false, err
+		return TreeEntry{}, false, iter.errorf("invalid mode %q", iter.data[:spAt])
 	}
 	entry.Filemode = uint(mode)
 
 	iter.data = iter.data[spAt+1:]
 	nulAt := strings.IndexByte(iter.data, 0)
 	if nulAt < 0 {
-		return TreeEntry{}, false, errors.New("failed to find NUL after filename")
+		return TreeEntry{}, false, iter.errorf("failed to find NUL after filename")
 	}
 
 	entry.Name = iter.data[:nulAt]
 
 	iter.data = iter.data[nulAt+1:]
 	if len(iter.data) < 20 {
-		return TreeEntry{}, false, errors.New("tree entry ends unexpectedly")
+		return TreeEntry{}, false, iter.errorf("tree entry ends unexpectedly")
 	}
 
 	copy(entry.OID.v[0:20], iter.data[0:20])
@@ -0,0 +1,146 @@
+package git
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+)
+
+// TreeFile is a regular (possibly executable) file in the checkout
+// of a tree.
+type TreeFile struct {
+	Path string
+	OID  OID
+	Size counts.Count64
+}
+
+// TreeFiles calls `fn` for each of the regular files in the checkout
+// of `tree`, in the order listed by `git ls-tree -r`. Symlinks and
+// submodules are skipped. If `fn` returns an error, the iteration
+// stops and that error is returned.
+func (repo *Repository) TreeFiles(ctx context.Context, tree OID, fn func(TreeFile) error) error {
+	cmd := repo.GitCommandContext(ctx, "ls-tree", "-r", "-l", "-z", tree.String())
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("listing files of tree %s: %w", tree, err)
+	}
+
+	f := bufio.NewReader(out)
+	for {
+		line, err := f.ReadString(0)
+		if err == io.EOF && line == "" {
+			break
+		} else if err != nil {
+			_ = cmd.Wait()
+			return fmt.Errorf("listing files of tree %s: %w", tree, err)
+		}
+		line = line[:len(line)-1]
+
+		// Each entry has the form
+		//
+		//     <mode> SP <type> SP <oid> SP+ <size> TAB <path>
+		i := strings.IndexByte(line, '\t')
+		if i == -1 {
+			_ = cmd.Wait()
+			return fmt.Errorf("unexpected output from 'git ls-tree': %q", line)
+		}
+		words := strings.Fields(line[:i])
+		if len(words) != 4 {
+			_ = cmd.Wait()
+			return fmt.Errorf("unexpected output from 'git ls-tree': %q", line)
+		}
+		if words[0] != "100644" && words[0] != "100755" {
+			continue
+		}
+		oid, err := NewOID(words[2])
+		if err != nil {
+			_ = cmd.Wait()
+			return fmt.Errorf("unexpected output from 'git ls-tree': %q", line)
+		}
+		size, err := strconv.ParseUint(words[3], 10, 64)
+		if err != nil {
+			_ = cmd.Wait()
+			return fmt.Errorf("unexpected output from 'git ls-tree': %q", line)
+		}
+		if err := fn(TreeFile{Path: line[i+1:], OID: oid, Size: counts.Count64(size)}); err != nil {
+			_ = cmd.Wait()
+			return err
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("listing files of tree %s: %w", tree, err)
+	}
+	return nil
+}
+
+// TreePath is an entry, at any depth, in the checkout of a tree.
+type TreePath struct {
+	Path       string
+	OID        OID
+	ObjectType ObjectType
+}
+
+// TreePaths calls `fn` for each of the entries in the checkout of
+// `tree`, including the subtrees, symlinks, and submodules, in the
+// order listed by `git ls-tree -r -t`, which lists each subtree
+// before its entries. If `fn` returns an error, the iteration stops
+// and that error is returned.
+func (repo *Repository) TreePaths(ctx context.Context, tree OID, fn func(TreePath) error) error {
+	cmd := repo.GitCommandContext(ctx, "ls-tree", "-r", "-t", "-z", tree.String())
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("listing paths of tree %s: %w", tree, err)
+	}
+
+	f := bufio.NewReader(out)
+	for {
+		line, err := f.ReadString(0)
+		if err == io.EOF && line == "" {
+			break
+		} else if err != nil {
+			_ = cmd.Wait()
+			return fmt.Errorf("listing paths of tree %s: %w", tree, err)
+		}
+		line = line[:len(line)-1]
+
+		// Each entry has the form
+		//
+		//     <mode> SP <type> SP <oid> TAB <path>
+		i := strings.IndexByte(line, '\t')
+		if i == -1 {
+			_ = cmd.Wait()
+			return fmt.Errorf("unexpected output from 'git ls-tree': %q", line)
+		}
+		words := strings.Fields(line[:i])
+		if len(words) != 3 {
+			_ = cmd.Wait()
+			return fmt.Errorf("unexpected output from 'git ls-tree': %q", line)
+		}
+		oid, err := NewOID(words[2])
+		if err != nil {
+			_ = cmd.Wait()
+			return fmt.Errorf("unexpected output from 'git ls-tree': %q", line)
+		}
+		if err := fn(TreePath{Path: line[i+1:], OID: oid, ObjectType: ObjectType(words[1])}); err != nil {
+			_ = cmd.Wait()
+			return err
+		}
+	}
+
+	if err := cmd.Wait(); err != nil {
+		return fmt.Errorf("listing paths of tree %s: %w", tree, err)
+	}
+	return nil
+}
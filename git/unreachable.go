@@ -0,0 +1,281 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cruftMtimesSignature is the signature at the start of the ".mtimes"
+// file of a cruft pack.
+var cruftMtimesSignature = []byte("MTME")
+
+// UnreachableObject is an object in the repository's object database
+// that isn't reachable from any reference, reflog, or the index, and
+// that `git gc` will therefore eventually prune.
+type UnreachableObject struct {
+	OID OID
+
+	// Size is the number of bytes that the object's copies (loose
+	// or packed) occupy on disk.
+	Size uint64
+
+	// ModTime is the newest modification time of the object's
+	// copies, which is what Git compares to `gc.pruneExpire`: the
+	// mtime of a loose object's file, the mtime recorded for the
+	// object by a cruft pack, or otherwise the mtime of its packfile.
+	ModTime time.Time
+}
+
+// UnreachableObjects returns the objects in `repo`'s object database
+// that are not reachable from any reference, reflog, or the index,
+// in order of OID. It doesn't include objects in alternate object
+// databases.
+func (repo *Repository) UnreachableObjects(ctx context.Context) ([]UnreachableObject, error) {
+	reachable, err := repo.reachableObjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make(map[OID]*UnreachableObject)
+	add := func(oid OID, size uint64, modTime time.Time) {
+		if _, ok := reachable[oid]; ok {
+			return
+		}
+		o, ok := objects[oid]
+		if !ok {
+			o = &UnreachableObject{OID: oid}
+			objects[oid] = o
+		}
+		o.Size += size
+		if modTime.After(o.ModTime) {
+			o.ModTime = modTime
+		}
+	}
+
+	if err := repo.forEachLooseObject(ctx, add); err != nil {
+		return nil, err
+	}
+	if err := repo.forEachPackedObject(ctx, add); err != nil {
+		return nil, err
+	}
+
+	oids := make([]OID, 0, len(objects))
+	for oid := range objects {
+		oids = append(oids, oid)
+	}
+	sort.Slice(oids, func(i, j int) bool {
+		return bytes.Compare(oids[i].v[:], oids[j].v[:]) < 0
+	})
+
+	unreachable := make([]UnreachableObject, len(oids))
+	for i, oid := range oids {
+		unreachable[i] = *objects[oid]
+	}
+	return unreachable, nil
+}
+
+// reachableObjects returns the set of objects that `git gc` considers
+// reachable: those reachable from references, reflogs, or the index.
+func (repo *Repository) reachableObjects(ctx context.Context) (map[OID]struct{}, error) {
+	args := []string{"rev-list", "--objects", "--all", "--reflog", "--indexed-objects"}
+	if repo.Capabilities(ctx).RevListNoObjectNames {
+		args = append(args, "--no-object-names")
+	}
+	cmd := repo.GitCommandContext(ctx, args...)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("listing reachable objects: %w", err)
+	}
+
+	hexLen := repo.ObjectFormat(ctx).HexLen()
+	reachable := make(map[OID]struct{})
+	scanner := bufio.NewScanner(out)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		// A line that is the continuation of a path that contains a
+		// newline might look like an object name, which at worst
+		// marks an unrelated object as reachable.
+		name, ok := revListObjectName(scanner.Bytes(), hexLen)
+		if !ok {
+			continue
+		}
+		oid, err := NewOID(string(name))
+		if err != nil {
+			continue
+		}
+		reachable[oid] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		_ = cmd.Wait()
+		return nil, fmt.Errorf("listing reachable objects: %w", err)
+	}
+	if err := cmd.Wait(); err != nil {
+		return nil, fmt.Errorf("listing reachable objects: %w", err)
+	}
+	return reachable, nil
+}
+
+// forEachLooseObject calls `f` for each loose object in `repo`'s
+// object database, with the size and mtime of its file.
+func (repo *Repository) forEachLooseObject(
+	ctx context.Context, f func(oid OID, size uint64, modTime time.Time),
+) error {
+	objectsDir, err := repo.gitPath(ctx, "objects")
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < 256; i++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		shard := fmt.Sprintf("%02x", i)
+		entries, err := os.ReadDir(filepath.Join(objectsDir, shard))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("reading loose objects: %w", err)
+		}
+
+		for _, entry := range entries {
+			if !isLooseObjectName(entry.Name()) {
+				continue
+			}
+			info, err := entry.Info()
+			if errors.Is(err, fs.ErrNotExist) {
+				// The object was packed or pruned while we were
+				// reading.
+				continue
+			} else if err != nil {
+				return fmt.Errorf("reading loose objects: %w", err)
+			}
+			oid, err := NewOID(shard + entry.Name())
+			if err != nil {
+				return err
+			}
+			f(oid, uint64(info.Size()), info.ModTime())
+		}
+	}
+	return nil
+}
+
+// forEachPackedObject calls `f` for each object in each of the
+// packfiles in `repo`'s object database, with the number of bytes
+// that it takes up in the packfile and its mtime. The mtimes of the
+// objects in a cruft pack are read from its ".mtimes" file; those of
+// other objects are the mtime of their packfile.
+func (repo *Repository) forEachPackedObject(
+	ctx context.Context, f func(oid OID, size uint64, modTime time.Time),
+) error {
+	packs, err := repo.Packfiles(ctx)
+	if err != nil {
+		return err
+	}
+
+	packDir, err := repo.gitPath(ctx, "objects/pack")
+	if err != nil {
+		return err
+	}
+
+	for _, p := range packs {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		base := filepath.Join(packDir, strings.TrimSuffix(p.Name, ".pack"))
+		mtimes, err := readCruftMtimes(base+".mtimes", int(p.ObjectCount))
+		if err != nil {
+			return err
+		}
+
+		iter, err := newPackIndexIter(base+".idx", uint64(p.Size))
+		if errors.Is(err, fs.ErrNotExist) {
+			// The packfile was removed by a concurrent repack.
+			continue
+		} else if err != nil {
+			return err
+		}
+		for {
+			ok, err := iter.Next()
+			if err != nil {
+				iter.Close()
+				return err
+			}
+			if !ok {
+				break
+			}
+			modTime := p.ModTime
+			if mtimes != nil {
+				modTime = time.Unix(int64(mtimes[iter.i]), 0)
+			}
+			f(iter.oid, iter.size, modTime)
+		}
+		iter.Close()
+	}
+	return nil
+}
+
+// readCruftMtimes reads the ".mtimes" file at `path`, which records
+// the mtime of each of the `n` objects of a cruft pack, in the order
+// of its index. It returns nil if there is no such file (i.e., if
+// the packfile isn't a cruft pack). The file consists of a 12-byte
+// header (the signature, the version, and the hash function ID),
+// followed by a four-byte mtime per object.
+func readCruftMtimes(path string, n int) ([]uint32, error) {
+	buf, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("reading cruft pack mtimes: %w", err)
+	}
+
+	if len(buf) < 12+4*n || !bytes.HasPrefix(buf, cruftMtimesSignature) {
+		return nil, fmt.Errorf("%s is not a valid cruft pack mtimes file", path)
+	}
+	if version := binary.BigEndian.Uint32(buf[4:8]); version != 1 {
+		return nil, fmt.Errorf("cruft pack mtimes file %s has unsupported version %d", path, version)
+	}
+
+	mtimes := make([]uint32, n)
+	for i := range mtimes {
+		mtimes[i] = binary.BigEndian.Uint32(buf[12+4*i:])
+	}
+	return mtimes, nil
+}
+
+// ExpiryCutoff converts `setting`, a value of the kind that Git
+// accepts for `gc.pruneExpire` (e.g., "2.weeks.ago", "now", or
+// "never"), into the time at or before which objects are expired.
+// "now" (or "all") expires everything, and "never" (or "false")
+// nothing. The conversion is done by Git itself, so it understands
+// exactly what Git does.
+func (repo *Repository) ExpiryCutoff(ctx context.Context, setting string) (uint64, error) {
+	const key = "sizer.expirycutoff"
+	cmd := repo.GitCommandContext(
+		ctx, "-c", key+"="+setting, "config", "--type=expiry-date", key,
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		return 0, fmt.Errorf("invalid expiry date '%s'", setting)
+	}
+	cutoff, err := strconv.ParseUint(string(bytes.TrimSpace(out)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unexpected output from 'git config --type=expiry-date': %q", out)
+	}
+	return cutoff, nil
+}
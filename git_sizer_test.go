@@ -2,14 +2,26 @@ package main_test
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/hex"
 	"encoding/json"
+	"flag"
 	"fmt"
 	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -18,9 +30,12 @@ import (
 	"github.com/stretchr/testify/require"
 
 	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/fixtures"
 	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/internal/objdump"
 	"github.com/github/git-sizer/internal/testutils"
 	"github.com/github/git-sizer/meter"
+	"github.com/github/git-sizer/scanpb"
 	"github.com/github/git-sizer/sizes"
 )
 
@@ -51,49 +66,94 @@ func TestExec(t *testing.T) {
 	assert.NoErrorf(t, err, "command failed; output: %#v", string(output))
 }
 
-func newGitBomb(t *testing.T, repo *testutils.TestRepo, depth, breadth int, body string) {
-	t.Helper()
+// The library packages must not depend on the libraries that only
+// the command needs.
+func TestLibraryDependencies(t *testing.T) {
+	t.Parallel()
 
-	oid := repo.CreateObject(t, "blob", func(w io.Writer) error {
-		_, err := io.WriteString(w, body)
-		return err
-	})
+	goExe, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("the go command is not available")
+	}
+
+	cmd := exec.Command(
+		goExe, "list", "-deps",
+		"./sizes", "./git", "./counts", "./meter", "./fixtures", "./refgroups", "./scanpb",
+	)
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	for _, dep := range strings.Split(string(output), "\n") {
+		assert.NotContains(t, dep, "pflag", "library depends on %q", dep)
+		assert.NotContains(t, dep, "isatty", "library depends on %q", dep)
+		assert.NotContains(
+			t, dep, "github.com/github/git-sizer/internal/",
+			"library depends on %q", dep,
+		)
+	}
+}
+
+func TestJSONIndent(t *testing.T) {
+	t.Parallel()
+
+	executable := sizerExe(t)
 
-	digits := len(fmt.Sprintf("%d", breadth-1))
+	testRepo := testutils.NewTestRepo(t, true, "json-indent")
+	defer testRepo.Remove(t)
+
+	testRepo.CreateReferencedOrphan(t, "refs/heads/master")
+
+	for _, p := range []struct {
+		name           string
+		args           []string
+		config         string
+		expectedIndent string
+	}{
+		{name: "default-v1", args: []string{"--json-version=1"}, expectedIndent: "    "},
+		{name: "default-v2", args: []string{"--json-version=2"}, expectedIndent: "    "},
+		{name: "indent-v1", args: []string{"--json-version=1", "--json-indent=2"}, expectedIndent: "  "},
+		{name: "indent-v2", args: []string{"--json-version=2", "--json-indent=1"}, expectedIndent: " "},
+		{name: "compact-v1", args: []string{"--json-version=1", "--json-compact"}},
+		{name: "compact-v2", args: []string{"--json-version=2", "--json-compact"}},
+		{name: "zero", args: []string{"--json-version=2", "--json-indent=0"}},
+		{name: "config", args: []string{"--json-version=2"}, config: "0"},
+		{name: "config-override", args: []string{"--json-version=2", "--json-indent=2"}, config: "0", expectedIndent: "  "},
+	} {
+		p := p
+		t.Run(p.name, func(t *testing.T) {
+			repo := testRepo.Clone(t, "json-indent")
+			defer repo.Remove(t)
+
+			if p.config != "" {
+				repo.ConfigAdd(t, "sizer.jsonIndent", p.config)
+			}
 
-	mode := "100644"
-	prefix := "f"
+			args := append([]string{"--no-progress", "--json"}, p.args...)
+			cmd := exec.Command(executable, args...)
+			cmd.Env = append(os.Environ(), "GIT_DIR="+repo.Path)
+			output, err := cmd.Output()
+			require.NoError(t, err)
 
-	for ; depth > 0; depth-- {
-		oid = repo.CreateObject(t, "tree", func(w io.Writer) error {
-			for i := 0; i < breadth; i++ {
-				_, err := fmt.Fprintf(
-					w, "%s %s%0*d\x00%s",
-					mode, prefix, digits, i, oid.Bytes(),
+			lines := strings.Split(strings.TrimSuffix(string(output), "\n"), "\n")
+			if p.expectedIndent == "" {
+				assert.Len(t, lines, 1)
+			} else if assert.Greater(t, len(lines), 2) {
+				assert.True(
+					t,
+					strings.HasPrefix(lines[1], p.expectedIndent+"\""),
+					"unexpected indentation: %q", lines[1],
 				)
-				if err != nil {
-					return err
-				}
 			}
-			return nil
+			assert.True(t, json.Valid(output), "invalid JSON output")
 		})
-
-		mode = "40000"
-		prefix = "d"
 	}
+}
 
-	oid = repo.CreateObject(t, "commit", func(w io.Writer) error {
-		_, err := fmt.Fprintf(
-			w,
-			"tree %s\n"+
-				"author Example <example@example.com> 1112911993 -0700\n"+
-				"committer Example <example@example.com> 1112911993 -0700\n"+
-				"\n"+
-				"Test git bomb\n",
-			oid,
-		)
-		return err
-	})
+func newGitBomb(t *testing.T, repo *testutils.TestRepo, depth, breadth int, body string) {
+	t.Helper()
+
+	oid, err := fixtures.NewBuilder(repo.Repository(t)).GitBomb(context.Background(), depth, breadth, body)
+	require.NoError(t, err)
 
 	repo.UpdateRef(t, "refs/heads/master", oid)
 }
@@ -133,18 +193,21 @@ func TestRefSelections(t *testing.T) {
 
 	// computeExpectations assembles and returns the results expected
 	// for test `i` from the `references` slice.
-	computeExpectations := func(i int) (string, int) {
+	computeExpectations := func(i int) (string, int, []string) {
 		var sb strings.Builder
 		fmt.Fprintln(&sb, "References (included references marked with '+'):")
 		count := 0
+		ignored := []string{}
 		for _, p := range references {
 			present := p.results[i]
 			fmt.Fprintf(&sb, "%c %s\n", present, p.refname)
 			if present == '+' {
 				count++
+			} else {
+				ignored = append(ignored, p.refname)
 			}
 		}
-		return sb.String(), count
+		return sb.String(), count, ignored
 	}
 
 	// Create a test repo with one orphan commit per refname:
@@ -283,17 +346,23 @@ func TestRefSelections(t *testing.T) {
 				err := cmd.Run()
 				assert.NoError(t, err)
 
-				expectedStderr, expectedUniqueCommitCount := computeExpectations(i)
+				expectedStderr, expectedUniqueCommitCount, expectedIgnoredRefs := computeExpectations(i)
 
 				// Make sure that the right number of commits was scanned:
 				var v struct {
 					UniqueCommitCount struct {
 						Value int
 					}
+					IgnoredRefs struct {
+						Count    int
+						Refnames []string
+					}
 				}
 				err = json.Unmarshal(stdout.Bytes(), &v)
 				if assert.NoError(t, err) {
 					assert.EqualValues(t, expectedUniqueCommitCount, v.UniqueCommitCount.Value)
+					assert.Equal(t, len(expectedIgnoredRefs), v.IgnoredRefs.Count)
+					assert.Equal(t, expectedIgnoredRefs, v.IgnoredRefs.Refnames)
 				}
 
 				// Make sure that the right references were reported scanned:
@@ -303,6 +372,47 @@ func TestRefSelections(t *testing.T) {
 	}
 }
 
+func TestForgeRefgroups(t *testing.T) {
+	t.Parallel()
+
+	repo := testutils.NewTestRepo(t, true, "forge-refgroups")
+	t.Cleanup(func() { repo.Remove(t) })
+
+	for _, refname := range []string{
+		"refs/heads/main",
+		"refs/pull/7/head",
+		"refs/merge-requests/1/head",
+		"refs/merge-requests/2/head",
+		"refs/keep-around/0123456789abcdef0123456789abcdef01234567",
+		"refs/environments/production/deployments/1",
+	} {
+		repo.CreateReferencedOrphan(t, refname)
+	}
+
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2")
+	cmd.Dir = repo.Path
+	out, err := cmd.Output()
+	require.NoError(t, err)
+
+	var v map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(out, &v))
+	for symbol, expected := range map[string]uint64{
+		"refgroup.branches":       1,
+		"refgroup.pulls":          1,
+		"refgroup.merge-requests": 2,
+		"refgroup.keep-around":    1,
+		"refgroup.environments":   1,
+	} {
+		var stat struct {
+			Value uint64
+		}
+		require.Contains(t, v, symbol)
+		require.NoError(t, json.Unmarshal(v[symbol], &stat))
+		assert.Equal(t, expected, stat.Value, symbol)
+	}
+	assert.NotContains(t, v, "refgroup.ignored")
+}
+
 func TestRefgroups(t *testing.T) {
 	t.Parallel()
 
@@ -352,6 +462,8 @@ func TestRefgroups(t *testing.T) {
 			stdout: `
 | * References                 |           |                                |
 |   * Count                    |    21     |                                |
+|   * Non-commit branches      |     0     |                                |
+|   * Non-commit tags          |     0     |                                |
 |     * Branches               |     2     |                                |
 |     * Tags                   |     4     |                                |
 |     * Remote-tracking refs   |     3     |                                |
@@ -404,6 +516,8 @@ References (included references marked with '+'):
 			stdout: `
 | * References                 |           |                                |
 |   * Count                    |    21     |                                |
+|   * Non-commit branches      |     0     |                                |
+|   * Non-commit tags          |     0     |                                |
 |     * Branches               |     2     |                                |
 |     * Tags                   |     4     |                                |
 |       * Releases             |     2     |                                |
@@ -433,6 +547,8 @@ References (included references marked with '+'):
 			stdout: `
 | * References                 |           |                                |
 |   * Count                    |    21     |                                |
+|   * Non-commit branches      |     0     |                                |
+|   * Non-commit tags          |     0     |                                |
 |     * Branches               |     2     |                                |
 |     * Tags                   |     2     |                                |
 |       * Releases             |     2     |                                |
@@ -472,6 +588,8 @@ References (included references marked with '+'):
 			stdout: `
 | * References                 |           |                                |
 |   * Count                    |    21     |                                |
+|   * Non-commit branches      |     0     |                                |
+|   * Non-commit tags          |     0     |                                |
 |     * Branches               |     2     |                                |
 |     * Tags                   |     4     |                                |
 |     * Remote-tracking refs   |     3     |                                |
@@ -542,310 +660,7147 @@ References (included references marked with '+'):
 	}
 }
 
-func pow(x uint64, n int) uint64 {
-	p := uint64(1)
-	for ; n > 0; n-- {
-		p *= x
-	}
-	return p
-}
-
-type refGrouper struct{}
-
-func (rg refGrouper) Categorize(refname string) (bool, []sizes.RefGroupSymbol) {
-	return true, nil
-}
-
-func (rg refGrouper) Groups() []sizes.RefGroup {
-	return nil
-}
-
-func TestBomb(t *testing.T) {
+func TestRefgroupAttribution(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.Background()
-
-	testRepo := testutils.NewTestRepo(t, true, "bomb")
-	t.Cleanup(func() { testRepo.Remove(t) })
-
-	newGitBomb(t, testRepo, 10, 10, "boom!\n")
+	testRepo := testutils.NewTestRepo(t, false, "refgroup-attribution")
+	defer testRepo.Remove(t)
 
-	repo := testRepo.Repository(t)
+	testRepo.AddFile(t, "a.txt", "Hello, world!\n")
+	cmd := testRepo.AuthoredCommand(t, "commit", "-m", "initial")
+	require.NoError(t, cmd.Run(), "creating initial commit")
 
-	t.Run("full", func(t *testing.T) {
-		refRoots, err := sizes.CollectReferences(ctx, repo, refGrouper{})
-		require.NoError(t, err)
+	// A big file that is only reachable from a pull request ref:
+	testRepo.AddFile(t, "big.txt", strings.Repeat("x", 10000))
+	cmd = testRepo.AuthoredCommand(t, "commit", "-m", "add big file")
+	require.NoError(t, cmd.Run(), "creating pull request commit")
+	require.NoError(t, testRepo.GitCommand(t, "update-ref", "refs/pull/1/head", "HEAD").Run())
+	require.NoError(t, testRepo.GitCommand(t, "reset", "--hard", "HEAD^").Run())
 
-		roots := make([]sizes.Root, 0, len(refRoots))
-		for _, refRoot := range refRoots {
-			roots = append(roots, refRoot)
-		}
+	// A big commit that is only reachable from a branch:
+	cmd = testRepo.AuthoredCommand(t, "commit", "--allow-empty", "-m", strings.Repeat("long message ", 100))
+	require.NoError(t, cmd.Run(), "creating branch commit")
 
-		h, err := sizes.ScanRepositoryUsingGraph(
-			ctx, repo, roots, sizes.NameStyleFull, meter.NoProgressMeter,
+	run := func(args ...string) []byte {
+		t.Helper()
+		cmd := exec.Command(
+			sizerExe(t),
+			append([]string{"--no-progress", "--json", "--json-version=2"}, args...)...,
 		)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
 		require.NoError(t, err)
+		return output
+	}
 
-		assert.Equal(t, counts.Count32(1), h.UniqueCommitCount, "unique commit count")
-		assert.Equal(t, counts.Count64(172), h.UniqueCommitSize, "unique commit size")
-		assert.Equal(t, counts.Count32(172), h.MaxCommitSize, "max commit size")
-		assert.Equal(t, "refs/heads/master", h.MaxCommitSizeCommit.BestPath(), "max commit size commit")
-		assert.Equal(t, counts.Count32(1), h.MaxHistoryDepth, "max history depth")
-		assert.Equal(t, counts.Count32(0), h.MaxParentCount, "max parent count")
-		assert.Equal(t, "refs/heads/master", h.MaxParentCountCommit.BestPath(), "max parent count commit")
+	type stat struct {
+		RefGroups []string
+	}
+	var v struct {
+		MaxBlobSize   stat
+		MaxCommitSize stat
+	}
 
-		assert.Equal(t, counts.Count32(10), h.UniqueTreeCount, "unique tree count")
-		assert.Equal(t, counts.Count64(2910), h.UniqueTreeSize, "unique tree size")
-		assert.Equal(t, counts.Count64(100), h.UniqueTreeEntries, "unique tree entries")
-		assert.Equal(t, counts.Count32(10), h.MaxTreeEntries, "max tree entries")
-		assert.Equal(t, "refs/heads/master:d0/d0/d0/d0/d0/d0/d0/d0/d0", h.MaxTreeEntriesTree.BestPath(), "max tree entries tree")
+	// Refgroups are only attributed on request:
+	require.NoError(t, json.Unmarshal(run(), &v))
+	assert.Empty(t, v.MaxBlobSize.RefGroups, "max blob size refgroups")
+	assert.Empty(t, v.MaxCommitSize.RefGroups, "max commit size refgroups")
 
-		assert.Equal(t, counts.Count32(1), h.UniqueBlobCount, "unique blob count")
-		assert.Equal(t, counts.Count64(6), h.UniqueBlobSize, "unique blob size")
-		assert.Equal(t, counts.Count32(6), h.MaxBlobSize, "max blob size")
-		assert.Equal(t, "refs/heads/master:d0/d0/d0/d0/d0/d0/d0/d0/d0/f0", h.MaxBlobSizeBlob.BestPath(), "max blob size blob")
+	require.NoError(t, json.Unmarshal(run("--refgroup-attribution"), &v))
+	assert.Equal(t, []string{"pulls"}, v.MaxBlobSize.RefGroups, "max blob size refgroups")
+	assert.Equal(t, []string{"branches"}, v.MaxCommitSize.RefGroups, "max commit size refgroups")
+}
 
-		assert.Equal(t, counts.Count32(0), h.UniqueTagCount, "unique tag count")
-		assert.Equal(t, counts.Count32(0), h.MaxTagDepth, "max tag depth")
+func TestWindowsUnsafeNames(t *testing.T) {
+	t.Parallel()
 
-		assert.Equal(t, counts.Count32(1), h.ReferenceCount, "reference count")
+	testRepo := testutils.NewTestRepo(t, false, "windows-unsafe-names")
+	defer testRepo.Remove(t)
 
-		assert.Equal(t, counts.Count32(10), h.MaxPathDepth, "max path depth")
-		assert.Equal(t, "refs/heads/master^{tree}", h.MaxPathDepthTree.BestPath(), "max path depth tree")
-		assert.Equal(t, counts.Count32(29), h.MaxPathLength, "max path length")
-		assert.Equal(t, "refs/heads/master^{tree}", h.MaxPathLengthTree.BestPath(), "max path length tree")
+	run := func(args ...string) {
+		t.Helper()
+		cmd := testRepo.AuthoredCommand(t, args...)
+		require.NoError(t, cmd.Run(), "running git %v", args)
+	}
 
-		assert.Equal(t, counts.Count32((pow(10, 10)-1)/(10-1)), h.MaxExpandedTreeCount, "max expanded tree count")
-		assert.Equal(t, "refs/heads/master^{tree}", h.MaxExpandedTreeCountTree.BestPath(), "max expanded tree count tree")
-		assert.Equal(t, counts.Count32(0xffffffff), h.MaxExpandedBlobCount, "max expanded blob count")
-		assert.Equal(t, "refs/heads/master^{tree}", h.MaxExpandedBlobCountTree.BestPath(), "max expanded blob count tree")
-		assert.Equal(t, counts.Count64(6*pow(10, 10)), h.MaxExpandedBlobSize, "max expanded blob size")
-		assert.Equal(t, "refs/heads/master^{tree}", h.MaxExpandedBlobSizeTree.BestPath(), "max expanded blob size tree")
-		assert.Equal(t, counts.Count32(0), h.MaxExpandedLinkCount, "max expanded link count")
-		assert.Nil(t, h.MaxExpandedLinkCountTree, "max expanded link count tree")
-		assert.Equal(t, counts.Count32(0), h.MaxExpandedSubmoduleCount, "max expanded submodule count")
-		assert.Nil(t, h.MaxExpandedSubmoduleCountTree, "max expanded submodule count tree")
-	})
+	// Names that are only in the history, rather than in the HEAD or
+	// biggest checkout, aren't counted:
+	testRepo.AddFile(t, "history/COM1", "Hello, world!\n")
+	run("commit", "-m", "history")
+	run("rm", "-r", "-q", "history")
 
-	t.Run("partial", func(t *testing.T) {
-		name := "master:d0/d0"
-		oid, err := repo.ResolveObject(name)
-		require.NoError(t, err)
-		roots := []sizes.Root{sizes.NewExplicitRoot(name, oid)}
+	for _, name := range []string{
+		"ok.txt", "console.txt", "lpt10", "com1x.c",
+		"docs/a:b", "docs/aux.c", "docs/notes.", "docs/NUL", "docs/trailing ",
+		"Con/readme", "sub/dir/what?",
+	} {
+		testRepo.AddFile(t, name, "Hello, world!\n")
+	}
+	run("commit", "-m", "initial")
 
-		h, err := sizes.ScanRepositoryUsingGraph(
-			ctx, repo, roots, sizes.NameStyleFull, meter.NoProgressMeter,
-		)
+	type stat struct {
+		Value             int
+		ObjectDescription string
+	}
+	var v struct {
+		MaxCheckoutWindowsUnsafeCount stat
+		WindowsUnsafeEntryCount       stat
+	}
+	scan := func() {
+		t.Helper()
+		cmd := exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2")
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
 		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(output, &v))
+	}
 
-		assert.Equal(t, counts.Count32(0), h.UniqueCommitCount, "unique commit count")
-		assert.Equal(t, counts.Count64(0), h.UniqueCommitSize, "unique commit size")
-		assert.Equal(t, counts.Count32(0), h.MaxCommitSize, "max commit size")
-		assert.Nil(t, h.MaxCommitSizeCommit)
-		assert.Equal(t, counts.Count32(0), h.MaxHistoryDepth, "max history depth")
-		assert.Equal(t, counts.Count32(0), h.MaxParentCount, "max parent count")
-		assert.Nil(t, h.MaxParentCountCommit, "max parent count commit")
+	scan()
+	assert.Equal(t, 7, v.MaxCheckoutWindowsUnsafeCount.Value, "unsafe names in checkout")
+	assert.Equal(t, "refs/heads/master^{tree}", v.MaxCheckoutWindowsUnsafeCount.ObjectDescription)
+	assert.Equal(t, 7, v.WindowsUnsafeEntryCount.Value, "unsafe entries")
+	assert.Contains(
+		t,
+		[]string{
+			"refs/heads/master:docs/a:b", "refs/heads/master:docs/aux.c",
+			"refs/heads/master:docs/notes.", "refs/heads/master:docs/NUL",
+			"refs/heads/master:docs/trailing ", "refs/heads/master:Con",
+			"refs/heads/master:sub/dir/what?",
+		},
+		v.WindowsUnsafeEntryCount.ObjectDescription,
+	)
 
-		assert.Equal(t, counts.Count32(8), h.UniqueTreeCount, "unique tree count")
-		assert.Equal(t, counts.Count64(2330), h.UniqueTreeSize, "unique tree size")
-		assert.Equal(t, counts.Count64(80), h.UniqueTreeEntries, "unique tree entries")
-		assert.Equal(t, counts.Count32(10), h.MaxTreeEntries, "max tree entries")
-		assert.Equal(t, "master:d0/d0/d0/d0/d0/d0/d0/d0/d0", h.MaxTreeEntriesTree.BestPath(), "max tree entries tree")
+	// A bigger checkout on another branch is listed as well as HEAD.
+	// Its root tree is a different one, so its "Con" entry is counted
+	// again, but the "docs" and "sub/dir" trees are the same:
+	run("checkout", "-q", "-b", "big")
+	testRepo.AddFile(t, "big/lpt1", strings.Repeat("x", 10000))
+	run("commit", "-m", "big")
+	run("checkout", "-q", "master")
 
-		assert.Equal(t, counts.Count32(1), h.UniqueBlobCount, "unique blob count")
-		assert.Equal(t, counts.Count64(6), h.UniqueBlobSize, "unique blob size")
-		assert.Equal(t, counts.Count32(6), h.MaxBlobSize, "max blob size")
-		assert.Equal(t, "master:d0/d0/d0/d0/d0/d0/d0/d0/d0/f0", h.MaxBlobSizeBlob.BestPath(), "max blob size blob")
+	scan()
+	assert.Equal(t, 8, v.MaxCheckoutWindowsUnsafeCount.Value, "unsafe names in checkout")
+	assert.Equal(t, "refs/heads/big^{tree}", v.MaxCheckoutWindowsUnsafeCount.ObjectDescription)
+	assert.Equal(t, 9, v.WindowsUnsafeEntryCount.Value, "unsafe entries")
+}
 
-		assert.Equal(t, counts.Count32(0), h.UniqueTagCount, "unique tag count")
-		assert.Equal(t, counts.Count32(0), h.MaxTagDepth, "max tag depth")
+func TestExecutables(t *testing.T) {
+	t.Parallel()
 
-		assert.Equal(t, counts.Count32(0), h.ReferenceCount, "reference count")
+	testRepo := testutils.NewTestRepo(t, false, "executables")
+	defer testRepo.Remove(t)
 
-		assert.Equal(t, counts.Count32(8), h.MaxPathDepth, "max path depth")
-		assert.Equal(t, "master:d0/d0", h.MaxPathDepthTree.BestPath(), "max path depth tree")
-		assert.Equal(t, counts.Count32(23), h.MaxPathLength, "max path length")
-		assert.Equal(t, "master:d0/d0", h.MaxPathLengthTree.BestPath(), "max path length tree")
+	testRepo.AddFile(t, "big-data.bin", strings.Repeat("d", 5000))
+	testRepo.AddFile(t, "script.sh", "#!/bin/sh\necho hello\n")
+	testRepo.AddFile(t, "bin/tool", strings.Repeat("x", 2000))
+	// The same contents, but not executable:
+	testRepo.AddFile(t, "docs/tool.txt", strings.Repeat("x", 2000))
+	cmd := testRepo.GitCommand(t, "update-index", "--chmod=+x", "script.sh", "bin/tool")
+	require.NoError(t, cmd.Run(), "marking files executable")
+	testRepo.Commit(t, "-m", "initial")
 
-		assert.Equal(t, counts.Count32((pow(10, 8)-1)/(10-1)), h.MaxExpandedTreeCount, "max expanded tree count")
-		assert.Equal(t, "master:d0/d0", h.MaxExpandedTreeCountTree.BestPath(), "max expanded tree count tree")
-		assert.Equal(t, counts.Count32(pow(10, 8)), h.MaxExpandedBlobCount, "max expanded blob count")
-		assert.Equal(t, "master:d0/d0", h.MaxExpandedBlobCountTree.BestPath(), "max expanded blob count tree")
-		assert.Equal(t, counts.Count64(6*pow(10, 8)), h.MaxExpandedBlobSize, "max expanded blob size")
-		assert.Equal(t, "master:d0/d0", h.MaxExpandedBlobSizeTree.BestPath(), "max expanded blob size tree")
-		assert.Equal(t, counts.Count32(0), h.MaxExpandedLinkCount, "max expanded link count")
-		assert.Nil(t, h.MaxExpandedLinkCountTree, "max expanded link count tree")
-		assert.Equal(t, counts.Count32(0), h.MaxExpandedSubmoduleCount, "max expanded submodule count")
-		assert.Nil(t, h.MaxExpandedSubmoduleCountTree, "max expanded submodule count tree")
-	})
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2")
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	type stat struct {
+		Value             int
+		ObjectDescription string
+	}
+	var v struct {
+		MaxCheckoutExecutableCount stat
+		MaxExecutableBlobSize      stat
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+	assert.Equal(t, 2, v.MaxCheckoutExecutableCount.Value, "executables in checkout")
+	assert.Equal(t, "refs/heads/master^{tree}", v.MaxCheckoutExecutableCount.ObjectDescription)
+	assert.Equal(t, 2000, v.MaxExecutableBlobSize.Value, "largest executable")
+	assert.Equal(t, "refs/heads/master:bin/tool", v.MaxExecutableBlobSize.ObjectDescription)
 }
 
-func TestTaggedTags(t *testing.T) {
+func TestNestedRepositories(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.Background()
-
-	testRepo := testutils.NewTestRepo(t, false, "tagged-tags")
+	testRepo := testutils.NewTestRepo(t, false, "nested-repositories")
 	defer testRepo.Remove(t)
 
-	timestamp := time.Unix(1112911993, 0)
+	ctx := context.Background()
+	b := fixtures.NewBuilder(testRepo.Repository(t))
 
-	cmd := testRepo.GitCommand(t, "commit", "-m", "initial", "--allow-empty")
-	testutils.AddAuthorInfo(cmd, &timestamp)
-	require.NoError(t, cmd.Run(), "creating commit")
+	blob, err := b.Blob(ctx, "Hello, world!\n")
+	require.NoError(t, err)
+	tree := func(entries ...fixtures.TreeEntry) git.OID {
+		t.Helper()
+		oid, err := b.Tree(ctx, entries...)
+		require.NoError(t, err)
+		return oid
+	}
+	file := func(name string) fixtures.TreeEntry {
+		return fixtures.TreeEntry{Mode: fixtures.ModeFile, Name: name, OID: blob}
+	}
+	dir := func(name string, oid git.OID) fixtures.TreeEntry {
+		return fixtures.TreeEntry{Mode: fixtures.ModeTree, Name: name, OID: oid}
+	}
 
-	// The lexicographical order of these tags is important, hence
-	// their strange names.
-	cmd = testRepo.GitCommand(t, "tag", "-m", "tag 1", "tag", "master")
-	testutils.AddAuthorInfo(cmd, &timestamp)
-	require.NoError(t, cmd.Run(), "creating tag 1")
+	// A `.git` entry, which Git itself refuses to create:
+	vendor := tree(file(".git"), file("README"))
 
-	cmd = testRepo.GitCommand(t, "tag", "-m", "tag 2", "bag", "tag")
-	testutils.AddAuthorInfo(cmd, &timestamp)
-	require.NoError(t, cmd.Run(), "creating tag 2")
+	// A bare repository committed as a directory:
+	bare := tree(
+		file("HEAD"),
+		dir("objects", tree(file("pack"))),
+		dir("refs", tree(file("heads"))),
+	)
 
-	cmd = testRepo.GitCommand(t, "tag", "-m", "tag 3", "wag", "bag")
-	testutils.AddAuthorInfo(cmd, &timestamp)
-	require.NoError(t, cmd.Run(), "creating tag 3")
+	// Something that is only superficially similar:
+	notBare := tree(
+		file("HEAD"),
+		file("objects"),
+		dir("refs", tree(file("heads"))),
+	)
 
-	repo := testRepo.Repository(t)
+	root := tree(dir("lib.git", bare), dir("other", notBare), dir("vendor", vendor))
+	commit, err := b.Commit(ctx, root, "Nested repositories")
+	require.NoError(t, err)
+	require.NoError(t, b.SetRef(ctx, "refs/heads/master", commit))
 
-	refRoots, err := sizes.CollectReferences(ctx, repo, refGrouper{})
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2")
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
 	require.NoError(t, err)
 
-	roots := make([]sizes.Root, 0, len(refRoots))
-	for _, refRoot := range refRoots {
-		roots = append(roots, refRoot)
+	var v struct {
+		NestedRepositoryTreeCount struct {
+			Value             int
+			ObjectDescription string
+		}
 	}
-
-	h, err := sizes.ScanRepositoryUsingGraph(
-		context.Background(), repo,
-		roots, sizes.NameStyleNone, meter.NoProgressMeter,
+	require.NoError(t, json.Unmarshal(output, &v))
+	assert.Equal(t, 2, v.NestedRepositoryTreeCount.Value)
+	assert.Contains(
+		t,
+		[]string{"refs/heads/master:lib.git", "refs/heads/master:vendor"},
+		v.NestedRepositoryTreeCount.ObjectDescription,
 	)
-	require.NoError(t, err, "scanning repository")
-	assert.Equal(t, counts.Count32(3), h.MaxTagDepth, "tag depth")
 }
 
-func TestFromSubdir(t *testing.T) {
+func TestUnicodeNames(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.Background()
-
-	testRepo := testutils.NewTestRepo(t, false, "subdir")
+	testRepo := testutils.NewTestRepo(t, false, "unicode-names")
 	defer testRepo.Remove(t)
 
-	timestamp := time.Unix(1112911993, 0)
-
-	testRepo.AddFile(t, "subdir/file.txt", "Hello, world!\n")
-
-	cmd := testRepo.GitCommand(t, "commit", "-m", "initial")
-	testutils.AddAuthorInfo(cmd, &timestamp)
-	require.NoError(t, cmd.Run(), "creating commit")
-
-	repo := testRepo.Repository(t)
+	for _, name := range []string{
+		"ok.txt",
+		"日本語のファイル.txt",
+		"docs/caf\u00e9.txt",  // NFC
+		"docs/cafe\u0301.txt", // NFD, which collides with the above
+		"p\u0430ypal.txt",     // Latin with a Cyrillic 'а'
+	} {
+		testRepo.AddFile(t, name, "Hello, world!\n")
+	}
+	testRepo.Commit(t, "-m", "initial")
 
-	refRoots, err := sizes.CollectReferences(ctx, repo, refGrouper{})
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2")
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
 	require.NoError(t, err)
 
-	roots := make([]sizes.Root, 0, len(refRoots))
-	for _, refRoot := range refRoots {
-		roots = append(roots, refRoot)
+	type stat struct {
+		Value             int
+		ObjectDescription string
 	}
-
-	h, err := sizes.ScanRepositoryUsingGraph(
-		context.Background(), testRepo.Repository(t),
-		roots, sizes.NameStyleNone, meter.NoProgressMeter,
+	var v struct {
+		NormalizationCollisionTreeCount stat
+		UnusualUnicodeEntryCount        stat
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+	assert.Equal(t, 1, v.NormalizationCollisionTreeCount.Value, "trees with collisions")
+	assert.Equal(t, "refs/heads/master:docs", v.NormalizationCollisionTreeCount.ObjectDescription)
+	assert.Equal(t, 2, v.UnusualUnicodeEntryCount.Value, "unusual names")
+	assert.Contains(
+		t,
+		[]string{"refs/heads/master:docs/cafe\u0301.txt", "refs/heads/master:p\u0430ypal.txt"},
+		v.UnusualUnicodeEntryCount.ObjectDescription,
 	)
-	require.NoError(t, err, "scanning repository")
-	assert.Equal(t, counts.Count32(2), h.MaxPathDepth, "max path depth")
 }
 
-func TestSubmodule(t *testing.T) {
+func TestCommitEncodings(t *testing.T) {
 	t.Parallel()
 
-	ctx := context.Background()
+	testRepo := testutils.NewTestRepo(t, false, "commit-encodings")
+	defer testRepo.Remove(t)
 
-	tmp, err := os.MkdirTemp("", "submodule")
-	require.NoError(t, err, "creating temporary directory")
+	commit := func(message string, args ...string) {
+		t.Helper()
+		msgFile := filepath.Join(testRepo.Path, ".git", "message")
+		require.NoError(t, os.WriteFile(msgFile, []byte(message), 0o666))
+		args = append(args, "commit", "--allow-empty", "-F", msgFile)
+		cmd := testRepo.AuthoredCommand(t, args...)
+		require.NoError(t, cmd.Run(), "creating commit")
+	}
+
+	commit("Plain ASCII\n")
+	commit("Caf\xc3\xa9 in UTF-8\n")
+	// Declared Latin-1, so (correctly) not valid UTF-8:
+	commit(
+		"Caf\xe9 in Latin-1, with a longer message\n",
+		"-c", "i18n.commitEncoding=ISO-8859-1",
+	)
+	// Undeclared Latin-1 (which `git commit` would have converted to
+	// UTF-8):
+	ctx := context.Background()
+	repo := testRepo.Repository(t)
+	head, err := repo.ResolveObjectContext(ctx, "HEAD")
+	require.NoError(t, err)
+	tree, err := repo.ResolveObjectContext(ctx, "HEAD^{tree}")
+	require.NoError(t, err)
+	b := fixtures.NewBuilder(repo)
+	oid, err := b.Commit(ctx, tree, "Caf\xe9 in mislabeled Latin-1", head)
+	require.NoError(t, err)
+	require.NoError(t, b.SetRef(ctx, "refs/heads/master", oid))
+
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2")
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	type stat struct {
+		Value int
+	}
+	var v struct {
+		NonUTF8EncodingCount    stat
+		InvalidUTF8MessageCount stat
+		MaxNonUTF8CommitSize    stat
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+	assert.Equal(t, 1, v.NonUTF8EncodingCount.Value, "non-UTF-8 encodings")
+	assert.Equal(t, 2, v.InvalidUTF8MessageCount.Value, "invalid UTF-8 messages")
+
+	out, err := testRepo.GitCommand(t, "cat-file", "-s", "master~1").Output()
+	require.NoError(t, err)
+	assert.Equal(t, strings.TrimSpace(string(out)), fmt.Sprint(v.MaxNonUTF8CommitSize.Value))
+}
+
+func TestScanScope(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "scan-scope")
+	defer testRepo.Remove(t)
+
+	for i := 0; i < 12; i++ {
+		testRepo.Commit(t, "--allow-empty", "-m", fmt.Sprintf("commit %d", i))
+		require.NoError(t, testRepo.GitCommand(t, "tag", fmt.Sprintf("v%02d", i)).Run())
+	}
+
+	out, err := testRepo.GitCommand(t, "rev-parse", "HEAD~3").Output()
+	require.NoError(t, err)
+	explicitOID := strings.TrimSpace(string(out))
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2", "--branches", "HEAD~3",
+	)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	var v struct {
+		ScanScope []struct {
+			Name     string
+			OID      string
+			Explicit bool
+		}
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+	if assert.Len(t, v.ScanScope, 2) {
+		assert.Equal(t, "refs/heads/master", v.ScanScope[0].Name)
+		assert.False(t, v.ScanScope[0].Explicit)
+		assert.Equal(t, "HEAD~3", v.ScanScope[1].Name)
+		assert.Equal(t, explicitOID, v.ScanScope[1].OID)
+		assert.True(t, v.ScanScope[1].Explicit)
+	}
+
+	// By default, the table omits the scope, unless the references
+	// to walk or explicit roots were specified:
+	cmd = exec.Command(sizerExe(t), "--no-progress")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
+	require.NoError(t, err)
+	assert.NotContains(t, string(output), "Scan scope:")
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--branches")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "\nScan scope:\n")
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "-v")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "\nScan scope:\n")
+	assert.Contains(t, string(output), "refs/heads/master\n")
+	assert.Contains(t, string(output), "refs/tags/v00\n")
+	assert.NotContains(t, string(output), "refs/tags/v11\n")
+	assert.Contains(t, string(output), "     ... and 3 more\n")
+}
+
+func TestRootMaxima(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "root-maxima")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "small", "small\n")
+	testRepo.Commit(t, "-m", "small")
+
+	require.NoError(t, testRepo.GitCommand(t, "checkout", "-q", "-b", "big").Run())
+	big := strings.Repeat("big\n", 1000)
+	testRepo.AddFile(t, "big1", big)
+	testRepo.AddFile(t, "big2", big+"2\n")
+	testRepo.Commit(t, "-m", "big")
+
+	type rootMaximum struct {
+		Root                 string
+		MaxBlob              string `json:"max_blob"`
+		MaxBlobSize          uint64 `json:"max_blob_size"`
+		MaxCheckoutBlobCount uint64 `json:"max_checkout_blob_count"`
+		MaxCheckoutBlobSize  uint64 `json:"max_checkout_blob_size"`
+	}
+	scan := func(args ...string) []rootMaximum {
+		t.Helper()
+		cmd := exec.Command(
+			sizerExe(t), append([]string{"--no-progress", "--json", "--json-version=2"}, args...)...,
+		)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err)
+		var v struct {
+			RootMaxima []rootMaximum
+		}
+		require.NoError(t, json.Unmarshal(output, &v))
+		return v.RootMaxima
+	}
+
+	assert.Nil(t, scan())
+
+	out, err := testRepo.GitCommand(t, "rev-parse", "big:big2").Output()
+	require.NoError(t, err)
+	bigBlob := strings.TrimSpace(string(out))
+
+	maxima := scan("master", "big", "big^{tree}")
+	if assert.Len(t, maxima, 3) {
+		assert.Equal(t, "master", maxima[0].Root)
+		assert.Equal(t, uint64(6), maxima[0].MaxBlobSize)
+		assert.Equal(t, uint64(1), maxima[0].MaxCheckoutBlobCount)
+		assert.Equal(t, uint64(6), maxima[0].MaxCheckoutBlobSize)
+
+		for _, rm := range maxima[1:] {
+			assert.Equal(t, bigBlob, rm.MaxBlob)
+			assert.Equal(t, uint64(len(big)+2), rm.MaxBlobSize)
+			assert.Equal(t, uint64(3), rm.MaxCheckoutBlobCount)
+			assert.Equal(t, uint64(6+2*len(big)+2), rm.MaxCheckoutBlobSize)
+		}
+	}
+
+	cmd := exec.Command(sizerExe(t), "--no-progress", "master", "big")
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "\nMaxima for each root:\n")
+	assert.Contains(t, string(output), bigBlob)
+}
+
+func TestReportDigest(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "report-digest")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "a<b>&c.txt", "Hello, world!\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	type digest struct {
+		Algorithm string
+		Value     string
+		Namespace string
+		Signature string
+	}
+
+	// canonical returns the canonical form of `report` (without the
+	// digest) and the digest.
+	canonical := func(output []byte, key string) ([]byte, digest) {
+		t.Helper()
+
+		var m map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(output, &m))
+		var d digest
+		require.NoError(t, json.Unmarshal(m[key], &d))
+		delete(m, key)
+
+		stripped, err := json.Marshal(m)
+		require.NoError(t, err)
+		dec := json.NewDecoder(bytes.NewReader(stripped))
+		dec.UseNumber()
+		var v interface{}
+		require.NoError(t, dec.Decode(&v))
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		require.NoError(t, enc.Encode(v))
+		return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), d
+	}
+
+	for _, p := range []struct {
+		version string
+		key     string
+	}{
+		{version: "1", key: "report_digest"},
+		{version: "2", key: "reportDigest"},
+	} {
+		cmd := exec.Command(
+			sizerExe(t), "--no-progress", "--json", "--json-version="+p.version, "--digest",
+		)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err)
+
+		report, d := canonical(output, p.key)
+		sum := sha256.Sum256(report)
+		assert.Equal(t, "sha256", d.Algorithm)
+		assert.Equal(t, hex.EncodeToString(sum[:]), d.Value, "digest for version %s", p.version)
+		assert.Empty(t, d.Signature)
+	}
+
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--digest")
+	cmd.Dir = testRepo.Path
+	assert.Error(t, cmd.Run(), "digest without JSON")
+
+	t.Run("signed", func(t *testing.T) {
+		if _, err := exec.LookPath("ssh-keygen"); err != nil {
+			t.Skip("ssh-keygen is not available")
+		}
+
+		keyDir := t.TempDir()
+		keyFile := filepath.Join(keyDir, "key")
+		require.NoError(
+			t,
+			exec.Command("ssh-keygen", "-q", "-t", "ed25519", "-N", "", "-C", "sizer", "-f", keyFile).Run(),
+		)
+
+		cmd := exec.Command(
+			sizerExe(t), "--no-progress", "--json", "--json-version=2", "--sign-key="+keyFile,
+		)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err)
+
+		report, d := canonical(output, "reportDigest")
+		assert.Equal(t, "git-sizer", d.Namespace)
+		require.NotEmpty(t, d.Signature)
+
+		pubKey, err := os.ReadFile(keyFile + ".pub")
+		require.NoError(t, err)
+		allowedSigners := filepath.Join(keyDir, "allowed_signers")
+		require.NoError(t, os.WriteFile(allowedSigners, append([]byte("sizer "), pubKey...), 0o666))
+		sigFile := filepath.Join(keyDir, "report.sig")
+		require.NoError(t, os.WriteFile(sigFile, []byte(d.Signature), 0o666))
+
+		cmd = exec.Command(
+			"ssh-keygen", "-Y", "verify", "-f", allowedSigners, "-I", "sizer",
+			"-n", d.Namespace, "-s", sigFile,
+		)
+		cmd.Stdin = bytes.NewReader(report)
+		out, err := cmd.CombinedOutput()
+		assert.NoError(t, err, "verifying signature: %s", out)
+	})
+}
+
+func TestBatchOptions(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "batch-options")
+	defer testRepo.Remove(t)
+
+	for i := 0; i < 10; i++ {
+		testRepo.AddFile(t, fmt.Sprintf("dir-%d/file.txt", i), strings.Repeat("x", 100*i))
+		testRepo.Commit(t, "-m", fmt.Sprintf("commit %d", i))
+	}
+
+	run := func(args ...string) ([]byte, error) {
+		args = append([]string{"--no-progress", "--json", "--json-version=2"}, args...)
+		cmd := exec.Command(sizerExe(t), args...)
+		cmd.Dir = testRepo.Path
+		return cmd.Output()
+	}
+
+	expected, err := run()
+	require.NoError(t, err)
+
+	// The results mustn't depend on the tuning options:
+	output, err := run("--batch-buffer-size=16", "--rev-list-window=100")
+	require.NoError(t, err)
+	assert.JSONEq(t, string(expected), string(output))
+
+	_, err = run("--batch-buffer-size=0")
+	assert.Error(t, err, "zero buffer size")
+
+	_, err = run("--rev-list-window=-1")
+	assert.Error(t, err, "negative window")
+}
+
+func TestResume(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "resume")
+	defer testRepo.Remove(t)
+
+	for i := 0; i < 5; i++ {
+		testRepo.AddFile(t, fmt.Sprintf("dir-%d/file.txt", i), strings.Repeat("x", 100*i))
+		testRepo.Commit(t, "-m", fmt.Sprintf("commit %d", i))
+	}
+
+	checkpointPath := filepath.Join(testRepo.Path, ".git", "git-sizer-checkpoint")
+
+	run := func(args ...string) ([]byte, []byte) {
+		args = append([]string{"--no-progress", "--json", "--json-version=2"}, args...)
+		cmd := exec.Command(sizerExe(t), args...)
+		cmd.Dir = testRepo.Path
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		require.NoError(t, err)
+		return output, stderr.Bytes()
+	}
+
+	expected, _ := run()
+
+	// A scan that completes removes its checkpoint:
+	output, _ := run("--resume")
+	assert.JSONEq(t, string(expected), string(output))
+	assert.NoFileExists(t, checkpointPath)
+
+	// A checkpoint that can't be used is discarded:
+	require.NoError(t, os.WriteFile(checkpointPath, []byte("garbage"), 0o644))
+	output, stderr := run("--resume")
+	assert.JSONEq(t, string(expected), string(output))
+	assert.Contains(t, string(stderr), "discarding checkpoint")
+	assert.NoFileExists(t, checkpointPath)
+
+	// Simulate a scan that was interrupted after saving its last
+	// checkpoint; resuming it must measure the history as it was
+	// then, even though the repository has changed since:
+	ctx := context.Background()
+	repo := testRepo.Repository(t)
+	scan := func(roots []sizes.Root, cp *sizes.Checkpoint) sizes.HistorySize {
+		h, err := sizes.ScanRepositoryUsingGraph(
+			ctx, repo, roots, sizes.NameStyleNone, meter.NoProgressMeter,
+			sizes.ScanOptions{Checkpoint: cp},
+		)
+		require.NoError(t, err)
+		return h
+	}
+
+	cp, discarded, err := sizes.OpenCheckpoint(checkpointPath, "key")
+	require.NoError(t, err)
+	assert.False(t, discarded)
+	refRoots, err := sizes.CollectReferences(ctx, repo, refGrouper{})
+	require.NoError(t, err)
+	roots := make([]sizes.Root, 0, len(refRoots))
+	for _, refRoot := range refRoots {
+		roots = append(roots, refRoot)
+	}
+	require.NoError(t, cp.SaveRoots(roots))
+	before := scan(roots, cp)
+
+	testRepo.AddFile(t, "new.txt", strings.Repeat("y", 10000))
+	testRepo.Commit(t, "-m", "new commit")
+
+	cp, discarded, err = sizes.OpenCheckpoint(checkpointPath, "key")
+	require.NoError(t, err)
+	assert.False(t, discarded)
+	roots, resumed, err := cp.Roots()
+	require.NoError(t, err)
+	require.True(t, resumed)
+	after := scan(roots, cp)
+	assert.Equal(t, before.UniqueCommitCount, after.UniqueCommitCount)
+	assert.Equal(t, before.MaxBlobSize, after.MaxBlobSize)
+
+	// A different key starts over:
+	cp, discarded, err = sizes.OpenCheckpoint(checkpointPath, "other key")
+	require.NoError(t, err)
+	assert.True(t, discarded)
+	_, resumed, err = cp.Roots()
+	require.NoError(t, err)
+	assert.False(t, resumed)
+	require.NoError(t, cp.Remove())
+}
+
+func TestScanIntegrity(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "scan-integrity")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "dir/file.txt", "Hello, world!\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	ctx := context.Background()
+	repo := testRepo.Repository(t)
+	checkpointPath := filepath.Join(testRepo.Path, ".git", "git-sizer-checkpoint")
+	scan := func(roots []sizes.Root, cp *sizes.Checkpoint) sizes.HistorySize {
+		h, err := sizes.ScanRepositoryUsingGraph(
+			ctx, repo, roots, sizes.NameStyleNone, meter.NoProgressMeter,
+			sizes.ScanOptions{Checkpoint: cp},
+		)
+		require.NoError(t, err)
+		return h
+	}
+
+	// Save the object headers in a checkpoint:
+	cp, _, err := sizes.OpenCheckpoint(checkpointPath, "key")
+	require.NoError(t, err)
+	refRoots, err := sizes.CollectReferences(ctx, repo, refGrouper{})
+	require.NoError(t, err)
+	roots := make([]sizes.Root, 0, len(refRoots))
+	for _, refRoot := range refRoots {
+		roots = append(roots, refRoot)
+	}
+	require.NoError(t, cp.SaveRoots(roots))
+	h := scan(roots, cp)
+	assert.Nil(t, h.ScanIntegrity)
+
+	// Then delete the subtree before resuming:
+	cmd := testRepo.GitCommand(t, "rev-parse", "HEAD:dir")
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	subtree := strings.TrimSpace(string(out))
+	require.NoError(t, os.Remove(
+		filepath.Join(testRepo.Path, ".git", "objects", subtree[:2], subtree[2:]),
+	))
+
+	cp, discarded, err := sizes.OpenCheckpoint(checkpointPath, "key")
+	require.NoError(t, err)
+	assert.False(t, discarded)
+	roots, resumed, err := cp.Roots()
+	require.NoError(t, err)
+	require.True(t, resumed)
+	h = scan(roots, cp)
+
+	require.NotNil(t, h.ScanIntegrity)
+	trees := h.ScanIntegrity.Trees
+	assert.Equal(t, counts.Count32(2), trees.Expected)
+	assert.Equal(t, counts.Count32(1), trees.Read)
+	require.Len(t, trees.Unread, 1)
+	assert.Equal(t, subtree, trees.Unread[0].OID.String())
+	assert.Equal(t, git.ObjectType("missing"), trees.Unread[0].Found)
+	assert.Equal(t, counts.Count32(1), h.ScanIntegrity.Commits.Read)
+	assert.Equal(t, counts.Count32(1), h.UniqueCommitCount)
+	assert.Contains(
+		t, h.ScanIntegrityString(), fmt.Sprintf("tree %s is missing", subtree),
+	)
+	require.NoError(t, cp.Remove())
+}
+
+func TestMaxDuration(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "max-duration")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "file.txt", "Hello, world!\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	run := func(args ...string) (string, error) {
+		args = append([]string{"--no-progress", "--json"}, args...)
+		cmd := exec.Command(sizerExe(t), args...)
+		cmd.Dir = testRepo.Path
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		err := cmd.Run()
+		return stderr.String(), err
+	}
+
+	_, err := run("--max-duration=1h")
+	assert.NoError(t, err)
+
+	stderr, err := run("--max-duration=1ns")
+	assert.Error(t, err)
+	assert.Contains(t, stderr, "took longer than --max-duration=1ns")
+
+	require.NoError(t, testRepo.GitCommand(t, "config", "sizer.maxDuration", "1ns").Run())
+	stderr, err = run()
+	assert.Error(t, err)
+	assert.Contains(t, stderr, "took longer than --max-duration=1ns")
+
+	_, err = run("--max-duration=-1s")
+	assert.Error(t, err, "negative duration")
+}
+
+func TestBlobCompressibility(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "blob-compressibility")
+	defer testRepo.Remove(t)
+
+	// Deterministic, but effectively incompressible, contents:
+	var random bytes.Buffer
+	for i := 0; random.Len() < 100000; i++ {
+		sum := sha256.Sum256([]byte(fmt.Sprintf("%d", i)))
+		random.Write(sum[:])
+	}
+
+	testRepo.AddFile(t, "huge.txt", strings.Repeat("a", 2<<20))
+	testRepo.AddFile(t, "text.txt", strings.Repeat("all work and no play\n", 10000))
+	testRepo.AddFile(t, "random.bin", random.String())
+	testRepo.AddFile(t, "small.txt", "small\n")
+	testRepo.Commit(t, "-m", "blobs")
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2", "--compressibility=3",
+	)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	var v struct {
+		BlobCompressibility []struct {
+			Name                 string  `json:"name"`
+			Size                 uint64  `json:"size"`
+			SampleSize           uint64  `json:"sample_size"`
+			CompressedSampleSize uint64  `json:"compressed_sample_size"`
+			Ratio                float64 `json:"ratio"`
+		} `json:"blobCompressibility"`
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+	require.Len(t, v.BlobCompressibility, 3)
+
+	huge, text, rand := v.BlobCompressibility[0], v.BlobCompressibility[1], v.BlobCompressibility[2]
+	assert.Contains(t, huge.Name, "master:huge.txt")
+	assert.EqualValues(t, 2<<20, huge.Size)
+	assert.EqualValues(t, 1<<20, huge.SampleSize, "only a sample is compressed")
+	assert.Less(t, huge.Ratio, 0.01)
+
+	assert.Contains(t, text.Name, "master:text.txt")
+	assert.Equal(t, text.Size, text.SampleSize)
+	assert.Less(t, text.Ratio, 0.1)
+
+	assert.Contains(t, rand.Name, "master:random.bin")
+	assert.Equal(t, rand.Size, rand.SampleSize)
+	assert.Greater(t, rand.Ratio, 0.95)
+	assert.InDelta(t, float64(rand.CompressedSampleSize), rand.Ratio*float64(rand.SampleSize), 1)
+}
+
+func TestTagOnlyObjects(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "tag-only")
+	defer testRepo.Remove(t)
+
+	run := func(args ...string) {
+		t.Helper()
+		cmd := testRepo.AuthoredCommand(t, args...)
+		require.NoError(t, cmd.Run(), "running git %v", args)
+	}
+
+	// A big blob on the branch, which also has a tag:
+	testRepo.AddFile(t, "big.txt", strings.Repeat("x", 20000))
+	run("commit", "-m", "big")
+	run("tag", "on-branch")
+
+	// A smaller one that is only on a tag:
+	run("checkout", "--detach")
+	testRepo.AddFile(t, "release.tar", strings.Repeat("y", 5000))
+	run("commit", "-m", "release")
+	run("tag", "release")
+	run("checkout", "master")
+
+	type value struct {
+		Value             int
+		ObjectDescription string
+	}
+	var v struct {
+		MaxBlobSize        value `json:"maxBlobSize"`
+		MaxTagOnlyBlobSize value `json:"maxTagOnlyBlobSize"`
+		MaxTagOnlyTreeSize value `json:"maxTagOnlyTreeSize"`
+	}
+
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2")
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(output, &v))
+
+	assert.Equal(t, 20000, v.MaxBlobSize.Value)
+	assert.Equal(t, 5000, v.MaxTagOnlyBlobSize.Value)
+	assert.Equal(t, "refs/tags/release:release.tar", v.MaxTagOnlyBlobSize.ObjectDescription)
+	// The tree with both files:
+	assert.NotZero(t, v.MaxTagOnlyTreeSize.Value)
+	assert.Equal(t, "refs/tags/release^{tree}", v.MaxTagOnlyTreeSize.ObjectDescription)
+
+	// Without tags, nothing is tag-only:
+	v.MaxTagOnlyBlobSize = value{}
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2", "--no-tags")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(output, &v))
+	assert.Zero(t, v.MaxTagOnlyBlobSize.Value)
+}
+
+func TestAnonymize(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "anonymize")
+	defer testRepo.Remove(t)
+
+	run := func(args ...string) {
+		t.Helper()
+		cmd := testRepo.AuthoredCommand(t, args...)
+		require.NoError(t, cmd.Run(), "running git %v", args)
+	}
+
+	testRepo.AddFile(t, "secret-project/secret-project", strings.Repeat("x", 10000))
+	run("commit", "-m", "secret")
+	run("branch", "-m", "secret-branch")
+	run("tag", "secret-tag")
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2", "--anonymize",
+		"--refgroup-attribution",
+	)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+	assert.NotContains(t, string(output), "secret")
+
+	type value struct {
+		ObjectDescription string
+		RefGroups         []string
+	}
+	var v struct {
+		MaxBlobSize    value `json:"maxBlobSize"`
+		BranchesRefLen value `json:"refgroup.branches.maxRefnameLength"`
+		ScanScope      []struct {
+			Name string
+		} `json:"scanScope"`
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+
+	// The same components are replaced by the same names:
+	m := regexp.MustCompile(`^refs/(?:heads|tags)/ref-[0-9a-f]{10}:(path-[0-9a-f]{10})/(.*)$`).
+		FindStringSubmatch(v.MaxBlobSize.ObjectDescription)
+	require.NotNil(t, m, "blob description %q", v.MaxBlobSize.ObjectDescription)
+	assert.Equal(t, m[1], m[2], "same component, same name")
+
+	assert.Regexp(t, `^refs/heads/ref-[0-9a-f]{10}$`, v.BranchesRefLen.ObjectDescription)
+	if assert.Len(t, v.ScanScope, 2) {
+		assert.Equal(t, v.BranchesRefLen.ObjectDescription, v.ScanScope[0].Name)
+	}
+
+	// Refgroups are still attributed:
+	assert.NotEmpty(t, v.MaxBlobSize.RefGroups)
+}
+
+func TestRefGroupTipAges(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "tip-ages")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", "hello\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	// The ages are whole numbers of days, without a metric prefix:
+	cmd := exec.Command(sizerExe(t), "--no-progress", "-v")
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Regexp(t, `\* Oldest tip age +\[[0-9]+\] \| +[0-9]{4,} d +\|`, string(output))
+	assert.NotRegexp(t, `Oldest tip age .*kd`, string(output))
+
+	// The time of the scan isn't part of the JSON output, so that
+	// two scans of the same repository give the same output:
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=1")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
+	require.NoError(t, err)
+	assert.NotContains(t, string(output), "scan_time")
+}
+
+func TestCloneEstimate(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "clone-estimate")
+	defer testRepo.Remove(t)
+
+	for i := 0; i < 10; i++ {
+		testRepo.AddFile(t, fmt.Sprintf("file-%d.txt", i), strings.Repeat(fmt.Sprintf("%d\n", i), 1000))
+	}
+	testRepo.Commit(t, "-m", "files")
+
+	run := func(args ...string) []byte {
+		t.Helper()
+		cmd := exec.Command(sizerExe(t), append([]string{"--no-progress"}, args...)...)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err)
+		return output
+	}
+
+	var v struct {
+		CloneEstimate *struct {
+			BandwidthMbps     float64 `json:"bandwidth_mbps"`
+			LatencyMillis     int64   `json:"latency_ms"`
+			TransferSize      uint64  `json:"transfer_size"`
+			ObjectCount       uint64  `json:"object_count"`
+			CheckoutFileCount uint64  `json:"checkout_file_count"`
+			TransferSeconds   float64 `json:"transfer_seconds"`
+			IndexSeconds      float64 `json:"index_seconds"`
+			CheckoutSeconds   float64 `json:"checkout_seconds"`
+			TotalSeconds      float64 `json:"total_seconds"`
+		} `json:"cloneEstimate"`
+	}
+	output := run("--json", "--json-version=2", "--clone-bandwidth=8", "--clone-latency=100")
+	require.NoError(t, json.Unmarshal(output, &v))
+	require.NotNil(t, v.CloneEstimate)
+	e := v.CloneEstimate
+	assert.EqualValues(t, 8, e.BandwidthMbps)
+	assert.EqualValues(t, 100, e.LatencyMillis)
+	// The blobs compress well, so they take less space on disk than
+	// their contents:
+	assert.NotZero(t, e.TransferSize)
+	assert.Less(t, e.TransferSize, uint64(10*2000))
+	assert.EqualValues(t, 12, e.ObjectCount, "10 blobs, a tree, and a commit")
+	assert.EqualValues(t, 10, e.CheckoutFileCount)
+	// At 8 Mbit/s, each byte takes a microsecond, plus three round
+	// trips:
+	assert.InDelta(t, 0.3+float64(e.TransferSize)*1e-6, e.TransferSeconds, 1e-9)
+	assert.InDelta(t, e.TransferSeconds+e.IndexSeconds+e.CheckoutSeconds, e.TotalSeconds, 1e-9)
+
+	output = run("--clone-bandwidth=100")
+	assert.Contains(t, string(output), "Recommendations:")
+	assert.Contains(t, string(output), "Estimated time to clone over a 100 Mbit/s connection")
+
+	// The estimate is only made if it is requested:
+	output = run()
+	assert.NotContains(t, string(output), "Recommendations:")
+
+	v.CloneEstimate = nil
+	output = run("--json", "--json-version=2", "--clone-bandwidth=8", "--stats=uniqueBlobSize")
+	require.NoError(t, json.Unmarshal(output, &v))
+	assert.Nil(t, v.CloneEstimate, "no estimate with --stats")
+}
+
+func TestCheckLatest(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "check-latest")
+	defer testRepo.Remove(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api":
+			fmt.Fprintln(w, `{"tag_name": "v1.5.0", "name": "v1.5.0"}`)
+		case "/plain":
+			fmt.Fprintln(w, "2.0.1")
+		case "/garbage":
+			fmt.Fprintln(w, "<html>Not a version</html>")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	run := func(args ...string) (string, error) {
+		cmd := exec.Command(sizerExe(t), append([]string{"--check-latest"}, args...)...)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		return string(output), err
+	}
+
+	// The test binary isn't built with a version number:
+	output, err := run("--latest-release-url=" + server.URL + "/api")
+	require.NoError(t, err)
+	assert.Equal(
+		t, "the latest release of git-sizer is v1.5.0; the version of this build is unknown\n",
+		output,
+	)
+
+	testRepo.ConfigAdd(t, "sizer.latestReleaseURL", server.URL+"/plain")
+	output, err = run()
+	require.NoError(t, err)
+	assert.Contains(t, output, "the latest release of git-sizer is 2.0.1")
+
+	_, err = run("--latest-release-url=" + server.URL + "/garbage")
+	assert.Error(t, err, "unexpected response")
+
+	_, err = run("--latest-release-url=" + server.URL + "/missing")
+	assert.Error(t, err, "missing release")
+}
+
+func TestRefgroupSharing(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "refgroup-sharing")
+	defer testRepo.Remove(t)
+
+	for i := 0; i < 20; i++ {
+		testRepo.AddFile(t, fmt.Sprintf("file-%d.txt", i), fmt.Sprintf("contents %d\n", i))
+		testRepo.Commit(t, "-m", fmt.Sprintf("commit %d", i))
+		if i == 9 {
+			require.NoError(t, testRepo.GitCommand(t, "tag", "release/v1/final").Run())
+		}
+	}
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2", "--sharing-matrix=3",
+	)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	var v struct {
+		TagsMaxRefnameDepth struct {
+			Value int
+		} `json:"refgroup.tags.maxRefnameDepth"`
+		RefgroupSharing []struct {
+			A, B                                string
+			SharedBytes, OnlyABytes, OnlyBBytes uint64
+		}
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+	assert.Equal(t, 5, v.TagsMaxRefnameDepth.Value, "max refname depth of tags")
+	if assert.Len(t, v.RefgroupSharing, 1) {
+		sharing := v.RefgroupSharing[0]
+		assert.Equal(t, "branches", sharing.A)
+		assert.Equal(t, "tags", sharing.B)
+		// Everything reachable from the tag is also reachable from
+		// the branch:
+		assert.Zero(t, sharing.OnlyBBytes, "bytes only in tags")
+		assert.Zero(t, sharing.SharedBytes%64, "shared bytes are extrapolated")
+	}
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--sharing-matrix=3")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "Estimated sharing of unique object bytes between refgroups")
+}
+
+func TestSharedTrees(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "shared-trees")
+	defer testRepo.Remove(t)
+
+	// The same "vendor" directory appears under "a" and (as
+	// "third_party") under "b":
+	big := strings.Repeat("vendored library\n", 100000)
+	for _, dir := range []string{"a/vendor", "b/third_party"} {
+		testRepo.AddFile(t, dir+"/lib/big.txt", big)
+		testRepo.AddFile(t, dir+"/lib/small.txt", "small\n")
+	}
+	testRepo.AddFile(t, "a/README", "a\n")
+	testRepo.AddFile(t, "c/big.txt", big+"not shared\n")
+	testRepo.Commit(t, "-m", "vendor a library twice")
+
+	vendorOID := testRepo.GitCommand(t, "rev-parse", "HEAD:a/vendor")
+	out, err := vendorOID.Output()
+	require.NoError(t, err)
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2", "--shared-trees=5",
+	)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	var v struct {
+		SharedTrees []struct {
+			OID               string
+			ExpandedBlobCount int `json:"expanded_blob_count"`
+			PathCount         int `json:"path_count"`
+			Paths             []string
+			TopLevelDirs      []string `json:"top_level_dirs"`
+		}
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+	// Only the outermost copy is listed, not "lib" within it:
+	if assert.Len(t, v.SharedTrees, 1) {
+		st := v.SharedTrees[0]
+		assert.Equal(t, strings.TrimSpace(string(out)), st.OID)
+		assert.Equal(t, 2, st.ExpandedBlobCount)
+		assert.Equal(t, 2, st.PathCount)
+		assert.Equal(
+			t,
+			[]string{"refs/heads/master:a/vendor", "refs/heads/master:b/third_party"},
+			st.Paths,
+		)
+		assert.Equal(t, []string{"a", "b"}, st.TopLevelDirs)
+	}
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--shared-trees=5")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "Trees shared across top-level directories or refgroups")
+	assert.Contains(t, string(output), "refs/heads/master:a/vendor")
+}
+
+func TestRefgroupCheckouts(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "refgroup-checkouts")
+	defer testRepo.Remove(t)
+
+	run := func(args ...string) {
+		t.Helper()
+		cmd := testRepo.AuthoredCommand(t, args...)
+		require.NoError(t, cmd.Run(), "running git %v", args)
+	}
+
+	testRepo.AddFile(t, "a.txt", "a\n")
+	run("commit", "-m", "one file")
+	run("branch", "small")
+	testRepo.AddFile(t, "b.txt", "bb\n")
+	testRepo.AddFile(t, "c.txt", "ccc\n")
+	run("commit", "-m", "three files")
+	run("tag", "-m", "annotated", "big")
+
+	type value struct {
+		Value             int
+		ObjectDescription string
+	}
+	var v struct {
+		BranchesCount value `json:"refgroup.branches.maxCheckoutBlobCount"`
+		BranchesSize  value `json:"refgroup.branches.maxCheckoutBlobSize"`
+		TagsCount     value `json:"refgroup.tags.maxCheckoutBlobCount"`
+	}
+
+	// Check both a full scan and one computing only these statistics:
+	for _, stats := range []string{
+		"",
+		"refgroup.branches.maxCheckoutBlobCount,refgroup.branches.maxCheckoutBlobSize," +
+			"refgroup.tags.maxCheckoutBlobCount",
+	} {
+		cmd := exec.Command(
+			sizerExe(t), "--no-progress", "--json", "--json-version=2", "--stats="+stats,
+		)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err)
+
+		require.NoError(t, json.Unmarshal(output, &v))
+		assert.Equal(t, 3, v.BranchesCount.Value, "max checkout files of branches")
+		assert.Equal(t, "refs/heads/master", v.BranchesCount.ObjectDescription)
+		assert.Equal(t, 9, v.BranchesSize.Value, "max checkout size of branches")
+		assert.Equal(t, 3, v.TagsCount.Value, "max checkout files of tags")
+		assert.Equal(t, "refs/tags/big", v.TagsCount.ObjectDescription)
+	}
+}
+
+func TestStrictAttribution(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "strict-attribution")
+	defer testRepo.Remove(t)
+
+	// A big file that is reachable from an excluded pull request
+	// ref, but also from the history of `master`:
+	testRepo.AddFile(t, "big.txt", strings.Repeat("x", 10000))
+	cmd := testRepo.AuthoredCommand(t, "commit", "-m", "add big file")
+	require.NoError(t, cmd.Run(), "creating first commit")
+	require.NoError(t, testRepo.GitCommand(t, "update-ref", "refs/pull/1/head", "HEAD").Run())
+
+	require.NoError(t, testRepo.GitCommand(t, "rm", "-q", "big.txt").Run())
+	testRepo.AddFile(t, "small.txt", "Hello, world!\n")
+	cmd = testRepo.AuthoredCommand(t, "commit", "-m", "replace big file")
+	require.NoError(t, cmd.Run(), "creating second commit")
+
+	for _, p := range []struct {
+		name             string
+		args             []string
+		expectedBlobSize int
+	}{
+		{name: "default", args: []string{"--branches"}, expectedBlobSize: 10000},
+		{name: "strict", args: []string{"--branches", "--strict-attribution"}, expectedBlobSize: 14},
+		{name: "strict-nothing-excluded", args: []string{"--strict-attribution"}, expectedBlobSize: 10000},
+	} {
+		args := append([]string{"--no-progress", "--json", "--json-version=2"}, p.args...)
+		cmd := exec.Command(sizerExe(t), args...)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err, p.name)
+
+		var v struct {
+			UniqueBlobCount struct {
+				Value int
+			}
+			MaxBlobSize struct {
+				Value int
+			}
+		}
+		require.NoError(t, json.Unmarshal(output, &v), p.name)
+		assert.Equal(t, 2, v.UniqueBlobCount.Value, "%s: unique blob count", p.name)
+		assert.Equal(t, p.expectedBlobSize, v.MaxBlobSize.Value, "%s: max blob size", p.name)
+	}
+}
+
+func pow(x uint64, n int) uint64 {
+	p := uint64(1)
+	for ; n > 0; n-- {
+		p *= x
+	}
+	return p
+}
+
+type refGrouper struct{}
+
+func (rg refGrouper) Categorize(refname string) (bool, []sizes.RefGroupSymbol) {
+	return true, nil
+}
+
+func (rg refGrouper) Groups() []sizes.RefGroup {
+	return nil
+}
+
+func TestBomb(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	testRepo := testutils.NewTestRepo(t, true, "bomb")
+	t.Cleanup(func() { testRepo.Remove(t) })
+
+	newGitBomb(t, testRepo, 10, 10, "boom!\n")
+
+	repo := testRepo.Repository(t)
+
+	t.Run("full", func(t *testing.T) {
+		refRoots, err := sizes.CollectReferences(ctx, repo, refGrouper{})
+		require.NoError(t, err)
+
+		roots := make([]sizes.Root, 0, len(refRoots))
+		for _, refRoot := range refRoots {
+			roots = append(roots, refRoot)
+		}
+
+		h, err := sizes.ScanRepositoryUsingGraph(
+			ctx, repo, roots, sizes.NameStyleFull, meter.NoProgressMeter,
+			sizes.ScanOptions{CloneBandwidth: 100},
+		)
+		require.NoError(t, err)
+
+		// The number of files in the checkout is saturated, so the
+		// time it takes to check it out can't be estimated:
+		assert.Nil(t, h.CloneEstimate, "clone estimate")
+
+		assert.Equal(t, counts.Count32(1), h.UniqueCommitCount, "unique commit count")
+		assert.Equal(t, counts.Count64(172), h.UniqueCommitSize, "unique commit size")
+		assert.Equal(t, counts.Count32(172), h.MaxCommitSize, "max commit size")
+		assert.Equal(t, "refs/heads/master", h.MaxCommitSizeCommit.BestPath(), "max commit size commit")
+		assert.Equal(t, counts.Count32(1), h.MaxHistoryDepth, "max history depth")
+		assert.Equal(t, counts.Count32(0), h.MaxParentCount, "max parent count")
+		assert.Equal(t, "refs/heads/master", h.MaxParentCountCommit.BestPath(), "max parent count commit")
+
+		assert.Equal(t, counts.Count32(10), h.UniqueTreeCount, "unique tree count")
+		assert.Equal(t, counts.Count64(2910), h.UniqueTreeSize, "unique tree size")
+		assert.Equal(t, counts.Count64(100), h.UniqueTreeEntries, "unique tree entries")
+		assert.Equal(t, counts.Count32(10), h.MaxTreeEntries, "max tree entries")
+		assert.Equal(t, "refs/heads/master:d0/d0/d0/d0/d0/d0/d0/d0/d0", h.MaxTreeEntriesTree.BestPath(), "max tree entries tree")
+		assert.Equal(t, counts.Count32(9), h.DuplicateSubtreeTreeCount, "duplicate subtree tree count")
+		assert.Equal(t, counts.Count32(10), h.MaxDuplicateSubtreeEntries, "max duplicate subtree entries")
+		assert.Equal(t, "refs/heads/master:d0/d0/d0/d0/d0/d0/d0/d0", h.MaxDuplicateSubtreeEntriesTree.BestPath(), "max duplicate subtree entries tree")
+
+		assert.Equal(t, counts.Count32(1), h.UniqueBlobCount, "unique blob count")
+		assert.Equal(t, counts.Count64(6), h.UniqueBlobSize, "unique blob size")
+		assert.Equal(t, counts.Count32(6), h.MaxBlobSize, "max blob size")
+		assert.Equal(t, "refs/heads/master:d0/d0/d0/d0/d0/d0/d0/d0/d0/f0", h.MaxBlobSizeBlob.BestPath(), "max blob size blob")
+
+		assert.Equal(t, counts.Count32(0), h.UniqueTagCount, "unique tag count")
+		assert.Equal(t, counts.Count32(0), h.MaxTagDepth, "max tag depth")
+
+		assert.Equal(t, counts.Count32(1), h.ReferenceCount, "reference count")
+
+		assert.Equal(t, counts.Count32(10), h.MaxPathDepth, "max path depth")
+		assert.Equal(t, "refs/heads/master^{tree}", h.MaxPathDepthTree.BestPath(), "max path depth tree")
+		assert.Equal(t, counts.Count32(29), h.MaxPathLength, "max path length")
+		assert.Equal(t, "refs/heads/master^{tree}", h.MaxPathLengthTree.BestPath(), "max path length tree")
+
+		assert.Equal(t, counts.Count32((pow(10, 10)-1)/(10-1)), h.MaxExpandedTreeCount, "max expanded tree count")
+		assert.Equal(t, "refs/heads/master^{tree}", h.MaxExpandedTreeCountTree.BestPath(), "max expanded tree count tree")
+		assert.Equal(t, counts.Count32(0xffffffff), h.MaxExpandedBlobCount, "max expanded blob count")
+		assert.Equal(t, "refs/heads/master^{tree}", h.MaxExpandedBlobCountTree.BestPath(), "max expanded blob count tree")
+		assert.Equal(t, counts.Count64(6*pow(10, 10)), h.MaxExpandedBlobSize, "max expanded blob size")
+		assert.Equal(t, "refs/heads/master^{tree}", h.MaxExpandedBlobSizeTree.BestPath(), "max expanded blob size tree")
+		assert.Equal(t, counts.Count32(0), h.MaxExpandedLinkCount, "max expanded link count")
+		assert.Nil(t, h.MaxExpandedLinkCountTree, "max expanded link count tree")
+		assert.Equal(t, counts.Count32(0), h.MaxExpandedSubmoduleCount, "max expanded submodule count")
+		assert.Nil(t, h.MaxExpandedSubmoduleCountTree, "max expanded submodule count tree")
+
+		table := h.TableString(refGrouper{}.Groups(), 0, sizes.NameStyleFull)
+		assert.Contains(t, table, "| * Number of files     [5][6] |     ∞     |")
+		assert.Contains(t, table, "\n[6]  ∞ means that the true value exceeds the capacity of a 32-bit counter\n")
+
+		j, err := h.JSON(refGrouper{}.Groups(), 0, sizes.NameStyleFull, "")
+		require.NoError(t, err)
+		var v struct {
+			MaxCheckoutBlobCount struct {
+				Saturated      bool
+				SaturationNote string
+			}
+			MaxCheckoutBlobSize struct {
+				Saturated bool
+			}
+		}
+		require.NoError(t, json.Unmarshal(j, &v))
+		assert.True(t, v.MaxCheckoutBlobCount.Saturated, "max checkout blob count saturated")
+		assert.Contains(t, v.MaxCheckoutBlobCount.SaturationNote, "32-bit counter")
+		assert.False(t, v.MaxCheckoutBlobSize.Saturated, "max checkout blob size saturated")
+	})
+
+	t.Run("partial", func(t *testing.T) {
+		name := "master:d0/d0"
+		oid, err := repo.ResolveObject(name)
+		require.NoError(t, err)
+		roots := []sizes.Root{sizes.NewExplicitRoot(name, oid)}
+
+		h, err := sizes.ScanRepositoryUsingGraph(
+			ctx, repo, roots, sizes.NameStyleFull, meter.NoProgressMeter,
+			sizes.ScanOptions{},
+		)
+		require.NoError(t, err)
+
+		assert.Equal(t, counts.Count32(0), h.UniqueCommitCount, "unique commit count")
+		assert.Equal(t, counts.Count64(0), h.UniqueCommitSize, "unique commit size")
+		assert.Equal(t, counts.Count32(0), h.MaxCommitSize, "max commit size")
+		assert.Nil(t, h.MaxCommitSizeCommit)
+		assert.Equal(t, counts.Count32(0), h.MaxHistoryDepth, "max history depth")
+		assert.Equal(t, counts.Count32(0), h.MaxParentCount, "max parent count")
+		assert.Nil(t, h.MaxParentCountCommit, "max parent count commit")
+
+		assert.Equal(t, counts.Count32(8), h.UniqueTreeCount, "unique tree count")
+		assert.Equal(t, counts.Count64(2330), h.UniqueTreeSize, "unique tree size")
+		assert.Equal(t, counts.Count64(80), h.UniqueTreeEntries, "unique tree entries")
+		assert.Equal(t, counts.Count32(10), h.MaxTreeEntries, "max tree entries")
+		assert.Equal(t, "master:d0/d0/d0/d0/d0/d0/d0/d0/d0", h.MaxTreeEntriesTree.BestPath(), "max tree entries tree")
+
+		assert.Equal(t, counts.Count32(1), h.UniqueBlobCount, "unique blob count")
+		assert.Equal(t, counts.Count64(6), h.UniqueBlobSize, "unique blob size")
+		assert.Equal(t, counts.Count32(6), h.MaxBlobSize, "max blob size")
+		assert.Equal(t, "master:d0/d0/d0/d0/d0/d0/d0/d0/d0/f0", h.MaxBlobSizeBlob.BestPath(), "max blob size blob")
+
+		assert.Equal(t, counts.Count32(0), h.UniqueTagCount, "unique tag count")
+		assert.Equal(t, counts.Count32(0), h.MaxTagDepth, "max tag depth")
+
+		assert.Equal(t, counts.Count32(0), h.ReferenceCount, "reference count")
+
+		assert.Equal(t, counts.Count32(8), h.MaxPathDepth, "max path depth")
+		assert.Equal(t, "master:d0/d0", h.MaxPathDepthTree.BestPath(), "max path depth tree")
+		assert.Equal(t, counts.Count32(23), h.MaxPathLength, "max path length")
+		assert.Equal(t, "master:d0/d0", h.MaxPathLengthTree.BestPath(), "max path length tree")
+
+		assert.Equal(t, counts.Count32((pow(10, 8)-1)/(10-1)), h.MaxExpandedTreeCount, "max expanded tree count")
+		assert.Equal(t, "master:d0/d0", h.MaxExpandedTreeCountTree.BestPath(), "max expanded tree count tree")
+		assert.Equal(t, counts.Count32(pow(10, 8)), h.MaxExpandedBlobCount, "max expanded blob count")
+		assert.Equal(t, "master:d0/d0", h.MaxExpandedBlobCountTree.BestPath(), "max expanded blob count tree")
+		assert.Equal(t, counts.Count64(6*pow(10, 8)), h.MaxExpandedBlobSize, "max expanded blob size")
+		assert.Equal(t, "master:d0/d0", h.MaxExpandedBlobSizeTree.BestPath(), "max expanded blob size tree")
+		assert.Equal(t, counts.Count32(0), h.MaxExpandedLinkCount, "max expanded link count")
+		assert.Nil(t, h.MaxExpandedLinkCountTree, "max expanded link count tree")
+		assert.Equal(t, counts.Count32(0), h.MaxExpandedSubmoduleCount, "max expanded submodule count")
+		assert.Nil(t, h.MaxExpandedSubmoduleCountTree, "max expanded submodule count tree")
+	})
+
+	t.Run("exact-counts", func(t *testing.T) {
+		refRoots, err := sizes.CollectReferences(ctx, repo, refGrouper{})
+		require.NoError(t, err)
+
+		roots := make([]sizes.Root, 0, len(refRoots))
+		for _, refRoot := range refRoots {
+			roots = append(roots, refRoot)
+		}
+
+		_, err = sizes.ScanRepositoryUsingGraph(
+			ctx, repo, roots, sizes.NameStyleFull, meter.NoProgressMeter,
+			sizes.ScanOptions{OverflowPolicy: sizes.OverflowError},
+		)
+		assert.ErrorIs(t, err, sizes.ErrOverflow)
+
+		// The policy only applies to the scan that it is passed to:
+		_, err = sizes.ScanRepositoryUsingGraph(
+			ctx, repo, roots, sizes.NameStyleFull, meter.NoProgressMeter,
+			sizes.ScanOptions{},
+		)
+		assert.NoError(t, err)
+
+		cmd := exec.Command(sizerExe(t), "--no-progress", "--exact-counts")
+		cmd.Env = append(os.Environ(), "GIT_DIR="+testRepo.Path)
+		output, err := cmd.CombinedOutput()
+		assert.Error(t, err)
+		assert.Contains(t, string(output), "counter overflowed")
+
+		cmd = exec.Command(sizerExe(t), "--no-progress", "--exact-counts", "--max-expanded-entries=1000")
+		cmd.Env = append(os.Environ(), "GIT_DIR="+testRepo.Path)
+		output, err = cmd.CombinedOutput()
+		assert.NoError(t, err, "output: %s", output)
+	})
+
+	t.Run("limited", func(t *testing.T) {
+		refRoots, err := sizes.CollectReferences(ctx, repo, refGrouper{})
+		require.NoError(t, err)
+
+		roots := make([]sizes.Root, 0, len(refRoots))
+		for _, refRoot := range refRoots {
+			roots = append(roots, refRoot)
+		}
+
+		h, err := sizes.ScanRepositoryUsingGraph(
+			ctx, repo, roots, sizes.NameStyleFull, meter.NoProgressMeter,
+			sizes.ScanOptions{MaxExpandedEntries: 1000},
+		)
+		require.NoError(t, err)
+
+		assert.Equal(t, counts.Count32(1), h.PotentialGitBombCount, "potential git bomb count")
+		assert.Equal(t, "refs/heads/master:d0/d0/d0/d0/d0/d0/d0", h.PotentialGitBombTree.BestPath(), "potential git bomb tree")
+		assert.Equal(t, counts.Count32(0xffffffff), h.MaxExpandedTreeCount, "max expanded tree count")
+		assert.Equal(t, counts.Count32(10), h.MaxPathDepth, "max path depth")
+	})
+
+	t.Run("stats", func(t *testing.T) {
+		refRoots, err := sizes.CollectReferences(ctx, repo, refGrouper{})
+		require.NoError(t, err)
+
+		roots := make([]sizes.Root, 0, len(refRoots))
+		for _, refRoot := range refRoots {
+			roots = append(roots, refRoot)
+		}
+
+		stats, err := sizes.ParseStatSet("uniqueBlobCount,uniqueBlobSize")
+		require.NoError(t, err)
+
+		h, err := sizes.ScanRepositoryUsingGraph(
+			ctx, repo, roots, sizes.NameStyleFull, meter.NoProgressMeter,
+			sizes.ScanOptions{Stats: stats},
+		)
+		require.NoError(t, err)
+
+		assert.Equal(t, counts.Count32(1), h.UniqueBlobCount, "unique blob count")
+		assert.Equal(t, counts.Count64(6), h.UniqueBlobSize, "unique blob size")
+
+		// Trees and commits weren't needed, so they weren't processed:
+		assert.Equal(t, counts.Count32(0), h.UniqueTreeCount, "unique tree count")
+		assert.Equal(t, counts.Count32(0), h.UniqueCommitCount, "unique commit count")
+		assert.Nil(t, h.MaxBlobSizeBlob, "max blob size blob")
+
+		_, err = sizes.ParseStatSet("uniqueBlobCount,bogus")
+		assert.Error(t, err)
+	})
+}
+
+// TestMaxExpandedEntries checks that a tree whose checkout exceeds
+// `--max-expanded-entries` is not reported as a potential git bomb if
+// its checkout consists mostly of distinct objects.
+func TestMaxExpandedEntries(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	testRepo := testutils.NewTestRepo(t, true, "max-expanded-entries")
+	t.Cleanup(func() { testRepo.Remove(t) })
+
+	repo := testRepo.Repository(t)
+	b := fixtures.NewBuilder(repo)
+
+	// Three directories of 20 distinct files each, one of which is
+	// also checked out a second time:
+	var rootEntries []fixtures.TreeEntry
+	for i := 0; i < 3; i++ {
+		var entries []fixtures.TreeEntry
+		for j := 0; j < 20; j++ {
+			blob, err := b.Blob(ctx, fmt.Sprintf("file %d/%d\n", i, j))
+			require.NoError(t, err)
+			entries = append(entries, fixtures.TreeEntry{
+				Mode: fixtures.ModeFile, Name: fmt.Sprintf("f%d", j), OID: blob,
+			})
+		}
+		tree, err := b.Tree(ctx, entries...)
+		require.NoError(t, err)
+		rootEntries = append(rootEntries, fixtures.TreeEntry{
+			Mode: fixtures.ModeTree, Name: fmt.Sprintf("d%d", i), OID: tree,
+		})
+	}
+	rootEntries = append(rootEntries, fixtures.TreeEntry{
+		Mode: fixtures.ModeTree, Name: "copy", OID: rootEntries[0].OID,
+	})
+	tree, err := b.Tree(ctx, rootEntries...)
+	require.NoError(t, err)
+	commit, err := b.Commit(ctx, tree, "Many files")
+	require.NoError(t, err)
+	require.NoError(t, b.SetRef(ctx, "refs/heads/master", commit))
+
+	refRoots, err := sizes.CollectReferences(ctx, repo, refGrouper{})
+	require.NoError(t, err)
+
+	roots := make([]sizes.Root, 0, len(refRoots))
+	for _, refRoot := range refRoots {
+		roots = append(roots, refRoot)
+	}
+
+	// The checkout has 85 entries, more than the limit, but only 64
+	// distinct objects:
+	h, err := sizes.ScanRepositoryUsingGraph(
+		ctx, repo, roots, sizes.NameStyleFull, meter.NoProgressMeter,
+		sizes.ScanOptions{MaxExpandedEntries: 50},
+	)
+	require.NoError(t, err)
+
+	assert.Equal(t, counts.Count32(0), h.PotentialGitBombCount, "potential git bomb count")
+	assert.Nil(t, h.PotentialGitBombTree, "potential git bomb tree")
+	assert.Equal(t, counts.Count32(5), h.MaxExpandedTreeCount, "max expanded tree count")
+	assert.Equal(t, counts.Count32(80), h.MaxExpandedBlobCount, "max expanded blob count")
+	assert.Equal(t, "refs/heads/master^{tree}", h.MaxExpandedBlobCountTree.BestPath(), "max expanded blob count tree")
+}
+
+func TestTaggedTags(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	testRepo := testutils.NewTestRepo(t, false, "tagged-tags")
+	defer testRepo.Remove(t)
+
+	timestamp := time.Unix(1112911993, 0)
+
+	cmd := testRepo.GitCommand(t, "commit", "-m", "initial", "--allow-empty")
+	testutils.AddAuthorInfo(cmd, &timestamp)
+	require.NoError(t, cmd.Run(), "creating commit")
+
+	// The lexicographical order of these tags is important, hence
+	// their strange names.
+	cmd = testRepo.GitCommand(t, "tag", "-m", "tag 1", "tag", "master")
+	testutils.AddAuthorInfo(cmd, &timestamp)
+	require.NoError(t, cmd.Run(), "creating tag 1")
+
+	cmd = testRepo.GitCommand(t, "tag", "-m", "tag 2", "bag", "tag")
+	testutils.AddAuthorInfo(cmd, &timestamp)
+	require.NoError(t, cmd.Run(), "creating tag 2")
+
+	cmd = testRepo.GitCommand(t, "tag", "-m", "tag 3", "wag", "bag")
+	testutils.AddAuthorInfo(cmd, &timestamp)
+	require.NoError(t, cmd.Run(), "creating tag 3")
+
+	repo := testRepo.Repository(t)
+
+	refRoots, err := sizes.CollectReferences(ctx, repo, refGrouper{})
+	require.NoError(t, err)
+
+	roots := make([]sizes.Root, 0, len(refRoots))
+	for _, refRoot := range refRoots {
+		roots = append(roots, refRoot)
+	}
+
+	h, err := sizes.ScanRepositoryUsingGraph(
+		context.Background(), repo,
+		roots, sizes.NameStyleNone, meter.NoProgressMeter,
+		sizes.ScanOptions{},
+	)
+	require.NoError(t, err, "scanning repository")
+	assert.Equal(t, counts.Count32(3), h.MaxTagDepth, "tag depth")
+}
+
+func TestTagSizes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	testRepo := testutils.NewTestRepo(t, false, "tag-sizes")
+	defer testRepo.Remove(t)
+
+	testRepo.Commit(t, "-m", "initial", "--allow-empty")
+
+	// The signatures aren't checked, so they needn't be valid:
+	messages := map[string]string{
+		"v1.0": "Version 1.0\n",
+		"v1.1": "Version 1.1\n\n" + strings.Repeat("Enormous release notes.\n", 1000),
+		"v1.2": "Version 1.2\n-----BEGIN PGP SIGNATURE-----\n\nnot really\n-----END PGP SIGNATURE-----\n",
+		"v1.3": "Version 1.3\n-----BEGIN SSH SIGNATURE-----\nnot really\n-----END SSH SIGNATURE-----\n",
+		"v1.4": "Mentions -----BEGIN PGP SIGNATURE----- but isn't signed\n",
+	}
+	var totalSize, maxSize uint64
+	var maxTag string
+	for name, message := range messages {
+		cmd := testRepo.AuthoredCommand(t, "tag", "-F", "-", name, "master")
+		cmd.Stdin = strings.NewReader(message)
+		require.NoError(t, cmd.Run(), "creating tag %s", name)
+
+		out, err := testRepo.GitCommand(t, "cat-file", "-s", name).Output()
+		require.NoError(t, err)
+		size, err := strconv.ParseUint(strings.TrimSpace(string(out)), 10, 64)
+		require.NoError(t, err)
+		totalSize += size
+		if size > maxSize {
+			maxSize, maxTag = size, name
+		}
+	}
+	assert.Equal(t, "v1.1", maxTag)
+
+	repo := testRepo.Repository(t)
+
+	refRoots, err := sizes.CollectReferences(ctx, repo, refGrouper{})
+	require.NoError(t, err)
+
+	roots := make([]sizes.Root, 0, len(refRoots))
+	for _, refRoot := range refRoots {
+		roots = append(roots, refRoot)
+	}
+
+	h, err := sizes.ScanRepositoryUsingGraph(
+		context.Background(), repo,
+		roots, sizes.NameStyleFull, meter.NoProgressMeter,
+		sizes.ScanOptions{},
+	)
+	require.NoError(t, err, "scanning repository")
+	assert.Equal(t, counts.Count32(5), h.UniqueTagCount, "tag count")
+	assert.Equal(t, counts.Count64(totalSize), h.UniqueTagSize, "total tag size")
+	assert.Equal(t, counts.Count32(maxSize), h.MaxTagSize, "maximum tag size")
+	if assert.NotNil(t, h.MaxTagSizeTag) {
+		assert.Equal(t, "refs/tags/v1.1", h.MaxTagSizeTag.Path())
+	}
+	assert.Equal(t, counts.Count32(2), h.SignedTagCount, "signed tags")
+}
+
+func TestDisconnectedHistories(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	testRepo := testutils.NewTestRepo(t, false, "disconnected-histories")
+	defer testRepo.Remove(t)
+
+	orphan := func(branch string) {
+		t.Helper()
+		require.NoError(t, testRepo.GitCommand(t, "checkout", "-q", "--orphan", branch).Run())
+		testRepo.Commit(t, "--allow-empty", "-m", branch)
+	}
+
+	testRepo.Commit(t, "--allow-empty", "-m", "initial")
+	testRepo.Commit(t, "--allow-empty", "-m", "second")
+
+	// An imported history that is merged into `master`:
+	orphan("imported")
+	testRepo.Commit(t, "--allow-empty", "-m", "imported 2")
+	require.NoError(t, testRepo.GitCommand(t, "checkout", "-q", "master").Run())
+	cmd := testRepo.AuthoredCommand(t, "merge", "-q", "--allow-unrelated-histories", "-m", "merge", "imported")
+	require.NoError(t, cmd.Run(), "merging")
+
+	// A history that is not connected to the others:
+	orphan("gh-pages")
+
+	repo := testRepo.Repository(t)
+
+	refRoots, err := sizes.CollectReferences(ctx, repo, refGrouper{})
+	require.NoError(t, err)
+
+	roots := make([]sizes.Root, 0, len(refRoots))
+	for _, refRoot := range refRoots {
+		roots = append(roots, refRoot)
+	}
+
+	h, err := sizes.ScanRepositoryUsingGraph(
+		ctx, repo, roots, sizes.NameStyleNone, meter.NoProgressMeter,
+		sizes.ScanOptions{},
+	)
+	require.NoError(t, err, "scanning repository")
+	assert.Equal(t, counts.Count32(6), h.UniqueCommitCount, "unique commit count")
+	assert.Equal(t, counts.Count32(3), h.RootCommitCount, "root commit count")
+	assert.Equal(t, counts.Count32(2), h.DisconnectedHistoryCount, "disconnected history count")
+
+	// All of the commits have empty trees:
+	assert.Equal(t, counts.Count32(2), h.EmptyCommitCount, "empty commit count")
+	assert.Equal(t, counts.Count32(1), h.EmptyMergeCount, "empty merge count")
+}
+
+func TestFromSubdir(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	testRepo := testutils.NewTestRepo(t, false, "subdir")
+	defer testRepo.Remove(t)
+
+	timestamp := time.Unix(1112911993, 0)
+
+	testRepo.AddFile(t, "subdir/file.txt", "Hello, world!\n")
+
+	cmd := testRepo.GitCommand(t, "commit", "-m", "initial")
+	testutils.AddAuthorInfo(cmd, &timestamp)
+	require.NoError(t, cmd.Run(), "creating commit")
+
+	repo := testRepo.Repository(t)
+
+	refRoots, err := sizes.CollectReferences(ctx, repo, refGrouper{})
+	require.NoError(t, err)
+
+	roots := make([]sizes.Root, 0, len(refRoots))
+	for _, refRoot := range refRoots {
+		roots = append(roots, refRoot)
+	}
+
+	h, err := sizes.ScanRepositoryUsingGraph(
+		context.Background(), testRepo.Repository(t),
+		roots, sizes.NameStyleNone, meter.NoProgressMeter,
+		sizes.ScanOptions{},
+	)
+	require.NoError(t, err, "scanning repository")
+	assert.Equal(t, counts.Count32(2), h.MaxPathDepth, "max path depth")
+}
+
+func TestSymlinks(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	testRepo := testutils.NewTestRepo(t, false, "symlinks")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "dir/file.txt", "Hello, world!\n")
+	for _, link := range []struct{ path, target string }{
+		{"abs", "/etc/passwd"},
+		{"rel", "dir/file.txt"},
+		{"dir/a", "b"},
+		{"dir/b", "./a/"},
+		{"dir/c", "file.txt"},
+		{"up", "../outside"},
+		{"dir/up", "../outside"},
+		{"dir/deep", "../../x/../y"},
+		{"dir/ok", "../dir/file.txt"},
+	} {
+		require.NoError(t, os.Symlink(link.target, filepath.Join(testRepo.Path, link.path)))
+		require.NoError(t, testRepo.GitCommand(t, "add", link.path).Run(), "adding symlink")
+	}
+
+	testRepo.Commit(t, "-m", "initial")
+
+	repo := testRepo.Repository(t)
+
+	refRoots, err := sizes.CollectReferences(ctx, repo, refGrouper{})
+	require.NoError(t, err)
+
+	roots := make([]sizes.Root, 0, len(refRoots))
+	for _, refRoot := range refRoots {
+		roots = append(roots, refRoot)
+	}
+
+	h, err := sizes.ScanRepositoryUsingGraph(
+		ctx, repo, roots, sizes.NameStyleFull, meter.NoProgressMeter,
+		sizes.ScanOptions{},
+	)
+	require.NoError(t, err, "scanning repository")
+	assert.Equal(t, counts.Count32(9), h.MaxExpandedLinkCount, "max expanded link count")
+	assert.Equal(t, counts.Count32(1), h.AbsoluteSymlinkCount, "absolute symlink count")
+	assert.Equal(t, "refs/heads/master:abs", h.AbsoluteSymlink.BestPath(), "absolute symlink")
+	assert.Equal(t, counts.Count32(1), h.SymlinkCycleTreeCount, "symlink cycle tree count")
+	assert.Equal(t, "refs/heads/master:dir", h.SymlinkCycleTree.BestPath(), "symlink cycle tree")
+	// "up" and "dir/up" have the same target, so they are counted once:
+	assert.Equal(t, counts.Count32(8), h.SymlinkTargetCount, "symlink target count")
+	assert.Equal(t, counts.Count32(15), h.MaxSymlinkTargetLength, "max symlink target length")
+	assert.Equal(
+		t, "refs/heads/master:dir/ok", h.MaxSymlinkTargetLengthSymlink.BestPath(),
+		"max symlink target length symlink",
+	)
+	// "up" escapes, even though "dir/up" doesn't, and so does
+	// "dir/deep", but not "dir/ok":
+	assert.Equal(t, counts.Count32(2), h.EscapingSymlinkCount, "escaping symlink count")
+}
+
+func TestSubmodule(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	tmp, err := os.MkdirTemp("", "submodule")
+	require.NoError(t, err, "creating temporary directory")
 
 	defer func() {
 		os.RemoveAll(tmp)
 	}()
 
-	timestamp := time.Unix(1112911993, 0)
+	timestamp := time.Unix(1112911993, 0)
+
+	submTestRepo := testutils.TestRepo{
+		Path: filepath.Join(tmp, "subm"),
+	}
+	submTestRepo.Init(t, false)
+	submTestRepo.AddFile(t, "submfile1.txt", "Hello, submodule!\n")
+	submTestRepo.AddFile(t, "submfile2.txt", "Hello again, submodule!\n")
+	submTestRepo.AddFile(t, "submfile3.txt", "Hello again, submodule!\n")
+
+	cmd := submTestRepo.GitCommand(t, "commit", "-m", "subm initial")
+	testutils.AddAuthorInfo(cmd, &timestamp)
+	require.NoError(t, cmd.Run(), "creating subm commit")
+
+	mainTestRepo := testutils.TestRepo{
+		Path: filepath.Join(tmp, "main"),
+	}
+	mainTestRepo.Init(t, false)
+
+	mainTestRepo.AddFile(t, "mainfile.txt", "Hello, main!\n")
+
+	cmd = mainTestRepo.GitCommand(t, "commit", "-m", "main initial")
+	testutils.AddAuthorInfo(cmd, &timestamp)
+	require.NoError(t, cmd.Run(), "creating main commit")
+
+	// Make subm a submodule of main:
+	cmd = mainTestRepo.GitCommand(t, "-c", "protocol.file.allow=always", "submodule", "add", submTestRepo.Path, "sub")
+	cmd.Dir = mainTestRepo.Path
+	require.NoError(t, cmd.Run(), "adding submodule")
+
+	cmd = mainTestRepo.GitCommand(t, "commit", "-m", "add submodule")
+	testutils.AddAuthorInfo(cmd, &timestamp)
+	require.NoError(t, cmd.Run(), "committing submodule to main")
+
+	mainRepo := mainTestRepo.Repository(t)
+
+	mainRefRoots, err := sizes.CollectReferences(ctx, mainRepo, refGrouper{})
+	require.NoError(t, err)
+
+	mainRoots := make([]sizes.Root, 0, len(mainRefRoots))
+	for _, refRoot := range mainRefRoots {
+		mainRoots = append(mainRoots, refRoot)
+	}
+
+	// Analyze the main repo:
+	h, err := sizes.ScanRepositoryUsingGraph(
+		context.Background(), mainTestRepo.Repository(t),
+		mainRoots, sizes.NameStyleNone, meter.NoProgressMeter,
+		sizes.ScanOptions{},
+	)
+	require.NoError(t, err, "scanning repository")
+	assert.Equal(t, counts.Count32(2), h.UniqueBlobCount, "unique blob count")
+	assert.Equal(t, counts.Count32(2), h.MaxExpandedBlobCount, "max expanded blob count")
+	assert.Equal(t, counts.Count32(1), h.MaxExpandedSubmoduleCount, "max expanded submodule count")
+
+	// Analyze the submodule:
+	submTestRepo2 := testutils.TestRepo{
+		Path: filepath.Join(mainTestRepo.Path, "sub"),
+	}
+
+	submRepo2 := submTestRepo2.Repository(t)
+
+	submRefRoots2, err := sizes.CollectReferences(ctx, submRepo2, refGrouper{})
+	require.NoError(t, err)
+
+	submRoots2 := make([]sizes.Root, 0, len(submRefRoots2))
+	for _, refRoot := range submRefRoots2 {
+		submRoots2 = append(submRoots2, refRoot)
+	}
+
+	h, err = sizes.ScanRepositoryUsingGraph(
+		context.Background(), submRepo2,
+		submRoots2, sizes.NameStyleNone, meter.NoProgressMeter,
+		sizes.ScanOptions{},
+	)
+	require.NoError(t, err, "scanning repository")
+	assert.Equal(t, counts.Count32(2), h.UniqueBlobCount, "unique blob count")
+	assert.Equal(t, counts.Count32(3), h.MaxExpandedBlobCount, "max expanded blob count")
+}
+
+func TestBaselineGrowth(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "baseline")
+	defer testRepo.Remove(t)
+
+	commit := func(filename, contents string) {
+		t.Helper()
+		testRepo.AddFile(t, filename, contents)
+		testRepo.Commit(t, "-m", "add "+filename)
+	}
+
+	commit("README", "Hello, world!\n")
+	require.NoError(t, testRepo.GitCommand(t, "tag", "v1").Run())
+
+	baselinePath := filepath.Join(testRepo.Path, "baseline.json")
+
+	run := func(args ...string) []byte {
+		t.Helper()
+		args = append([]string{"--no-progress", "--json", "--json-version=2"}, args...)
+		cmd := exec.Command(sizerExe(t), args...)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err)
+		return output
+	}
+
+	run("--save-baseline", baselinePath)
+	assert.FileExists(t, baselinePath)
+
+	// Grow the branches a lot and the tags a little:
+	commit("big.bin", strings.Repeat("x", 100000))
+	require.NoError(t, testRepo.GitCommand(t, "tag", "v2", "HEAD^").Run())
+
+	output := run("--baseline", baselinePath, "--save-baseline", baselinePath)
+
+	var v struct {
+		Growth struct {
+			RefGroups []struct {
+				RefGroup          string `json:"refgroup"`
+				ObjectCountGrowth int64  `json:"object_count_growth"`
+				ObjectSizeGrowth  int64  `json:"object_size_growth"`
+			} `json:"refgroups"`
+		} `json:"growth"`
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+
+	groups := v.Growth.RefGroups
+	require.Len(t, groups, 2)
+	assert.Equal(t, "branches", groups[0].RefGroup)
+	assert.Equal(t, int64(3), groups[0].ObjectCountGrowth)
+	assert.Greater(t, groups[0].ObjectSizeGrowth, int64(100000))
+	assert.Equal(t, "tags", groups[1].RefGroup)
+	assert.Equal(t, int64(0), groups[1].ObjectCountGrowth)
+	assert.Equal(t, int64(0), groups[1].ObjectSizeGrowth)
+
+	// The baseline was updated, so there's no more growth:
+	output = run("--baseline", baselinePath)
+	require.NoError(t, json.Unmarshal(output, &v))
+	for _, g := range v.Growth.RefGroups {
+		assert.Equal(t, int64(0), g.ObjectSizeGrowth, g.RefGroup)
+	}
+
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--baseline", "nonexistent.json")
+	cmd.Dir = testRepo.Path
+	assert.Error(t, cmd.Run(), "missing baseline")
+}
+
+func TestPackfiles(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "packfiles")
+	defer testRepo.Remove(t)
+
+	for i := 0; i < 2; i++ {
+		testRepo.AddFile(t, fmt.Sprintf("file-%d.txt", i), fmt.Sprintf("%d\n", i))
+		testRepo.Commit(t, "-m", fmt.Sprintf("commit %d", i))
+		require.NoError(t, testRepo.GitCommand(t, "repack", "-d").Run(), "repacking")
+	}
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2", "--packfiles",
+	)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	var v struct {
+		Packfiles []struct {
+			ObjectCount int  `json:"object_count"`
+			Size        int  `json:"size"`
+			Small       bool `json:"small"`
+		} `json:"packfiles"`
+		PackfileDuplicates *sizes.PackfileDuplicates `json:"packfileDuplicates"`
+		PackfileFixups     *sizes.PackfileFixups     `json:"packfileFixups"`
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+	require.Len(t, v.Packfiles, 2)
+	assert.GreaterOrEqual(t, v.Packfiles[0].Size, v.Packfiles[1].Size, "largest first")
+	for _, p := range v.Packfiles {
+		assert.Equal(t, 3, p.ObjectCount)
+		assert.True(t, p.Small)
+	}
+	// The second commit's tree refers to the first commit's blob,
+	// but each object is stored only once:
+	if assert.NotNil(t, v.PackfileDuplicates) {
+		assert.Equal(t, sizes.PackfileDuplicates{}, *v.PackfileDuplicates)
+	}
+	if assert.NotNil(t, v.PackfileFixups) {
+		assert.Equal(t, sizes.PackfileFixups{}, *v.PackfileFixups)
+	}
+
+	// Without the option, the packfiles aren't listed:
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
+	require.NoError(t, err)
+	assert.NotContains(t, string(output), `"packfiles"`)
+}
+
+func TestPackfileFixups(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "packfile-fixups")
+	defer testRepo.Remove(t)
+
+	commit := func(contents string) {
+		t.Helper()
+		testRepo.AddFile(t, "data.txt", contents)
+		testRepo.Commit(t, "-m", "update")
+	}
+
+	var lines strings.Builder
+	for i := 0; i < 3000; i++ {
+		fmt.Fprintf(&lines, "line %d\n", i)
+	}
+	commit(lines.String())
+	require.NoError(t, testRepo.GitCommand(t, "repack", "-a", "-d").Run(), "repacking")
+
+	// Store two thin packs, as fetches would, each holding a commit
+	// whose blob is a delta against an earlier version that is
+	// appended to complete the pack:
+	for i := 0; i < 2; i++ {
+		commit(lines.String() + strings.Repeat("one more line\n", i+1))
+		thin := testRepo.GitCommand(t, "pack-objects", "--thin", "--stdout", "--revs")
+		thin.Stdin = strings.NewReader("HEAD\n^HEAD~\n")
+		pack, err := thin.Output()
+		require.NoError(t, err)
+		cmd := testRepo.GitCommand(t, "index-pack", "--stdin", "--fix-thin")
+		cmd.Stdin = bytes.NewReader(pack)
+		require.NoError(t, cmd.Run(), "storing thin pack")
+	}
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2", "--packfiles",
+	)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	var v struct {
+		PackfileFixups *sizes.PackfileFixups `json:"packfileFixups"`
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+	require.NotNil(t, v.PackfileFixups)
+	f := v.PackfileFixups
+	assert.Equal(t, counts.Count32(2), f.PackfileCount)
+	assert.Equal(t, counts.Count32(2), f.DeltaCount)
+	assert.Equal(t, counts.Count32(2), f.BaseCount)
+	assert.Greater(t, uint64(f.BaseSize), uint64(100))
+	assert.Equal(t, counts.Count32(0), f.CrossPackDeltaCount)
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--packfiles")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "2 objects in 2 packfiles take more than one pack access to read")
+	assert.Contains(t, string(output), "that were appended to complete thin packs")
+}
+
+func TestSinceState(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "since-state")
+	defer testRepo.Remove(t)
+
+	commit := func(i int) {
+		t.Helper()
+		testRepo.AddFile(t, fmt.Sprintf("file-%d.txt", i), strings.Repeat(fmt.Sprintf("%d\n", i), 100*(i+1)))
+		testRepo.Commit(t, "-m", fmt.Sprintf("commit %d", i))
+	}
+
+	statePath := filepath.Join(testRepo.Path, "odb-state.json")
+
+	type total struct {
+		Count uint64
+		Size  uint64
+	}
+	type delta struct {
+		NewPackfiles        []string `json:"new_packfiles"`
+		NewLooseObjectCount uint64   `json:"new_loose_object_count"`
+		Commits             total
+		Trees               total
+		Blobs               total
+		MaxBlobSize         uint64 `json:"max_blob_size"`
+	}
+	scan := func(args ...string) *delta {
+		t.Helper()
+		cmd := exec.Command(
+			sizerExe(t), append([]string{"--no-progress", "--json", "--json-version=2"}, args...)...,
+		)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err)
+		var v struct {
+			ODBDelta *delta
+		}
+		require.NoError(t, json.Unmarshal(output, &v))
+		return v.ODBDelta
+	}
+
+	commit(0)
+	require.NoError(t, testRepo.GitCommand(t, "repack", "-d").Run(), "repacking")
+	commit(1)
+	assert.Nil(t, scan("--save-state="+statePath))
+
+	commit(2)
+	d := scan("--since-state=" + statePath)
+	if assert.NotNil(t, d) {
+		assert.Empty(t, d.NewPackfiles)
+		assert.Equal(t, uint64(3), d.NewLooseObjectCount)
+		assert.Equal(t, uint64(1), d.Commits.Count)
+		assert.Equal(t, uint64(1), d.Trees.Count)
+		assert.Equal(t, uint64(1), d.Blobs.Count)
+		assert.Equal(t, uint64(600), d.Blobs.Size)
+		assert.Equal(t, uint64(600), d.MaxBlobSize)
+	}
+
+	// Packing the loose objects that existed when the state was saved
+	// doesn't make them count as added:
+	require.NoError(t, testRepo.GitCommand(t, "repack", "-d").Run(), "repacking")
+	d = scan("--since-state=" + statePath)
+	if assert.NotNil(t, d) {
+		assert.Len(t, d.NewPackfiles, 1)
+		assert.Equal(t, uint64(0), d.NewLooseObjectCount)
+		assert.Equal(t, uint64(1), d.Commits.Count)
+		assert.Equal(t, uint64(1), d.Blobs.Count)
+	}
+
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--since-state="+statePath)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "\nObjects added to the object database since ")
+}
+
+func TestIndexEstimate(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "index-estimate")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "a.txt", "a\n")
+	testRepo.AddFile(t, "dir/b.txt", "b\n")
+	testRepo.AddFile(t, "dir/sub/c", "c\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2")
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	var v struct {
+		MaxCheckoutIndexSize struct {
+			Value             int
+			ObjectDescription string
+		}
+		IndexEstimate struct {
+			EntryCount       int  `json:"entry_count"`
+			PathLength       int  `json:"path_length"`
+			DiskSize         int  `json:"disk_size"`
+			MemorySize       int  `json:"memory_size"`
+			ManyFilesAdvised bool `json:"many_files_advised"`
+		}
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+
+	// Three entries, with paths "a.txt", "dir/b.txt", and
+	// "dir/sub/c":
+	assert.Equal(t, 3, v.IndexEstimate.EntryCount)
+	assert.Equal(t, 5+9+9, v.IndexEstimate.PathLength)
+	assert.Equal(t, 32+3*67+23, v.IndexEstimate.DiskSize)
+	assert.Equal(t, v.IndexEstimate.DiskSize, v.MaxCheckoutIndexSize.Value)
+	assert.Equal(t, "refs/heads/master^{tree}", v.MaxCheckoutIndexSize.ObjectDescription)
+	assert.Greater(t, v.IndexEstimate.MemorySize, v.IndexEstimate.DiskSize)
+	assert.False(t, v.IndexEstimate.ManyFilesAdvised)
+}
+
+func TestPackObjectsEstimate(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "pack-objects-estimate")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "small.txt", "small\n")
+	testRepo.AddFile(t, "big.txt", strings.Repeat("x", 1000))
+	testRepo.Commit(t, "-m", "initial")
+
+	type estimate struct {
+		Window          int
+		Threads         int
+		ObjectCount     int `json:"object_count"`
+		ObjectMemory    int `json:"object_memory"`
+		WindowMemory    int `json:"window_memory"`
+		DeltaBaseMemory int `json:"delta_base_memory"`
+		TotalMemory     int `json:"total_memory"`
+	}
+	scan := func(args ...string) (int, estimate) {
+		t.Helper()
+		cmd := exec.Command(
+			sizerExe(t),
+			append([]string{"--no-progress", "--json", "--json-version=2"}, args...)...,
+		)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err)
+		var v struct {
+			PackObjectsMemory struct {
+				Value int
+			}
+			PackObjectsEstimate estimate
+		}
+		require.NoError(t, json.Unmarshal(output, &v))
+		return v.PackObjectsMemory.Value, v.PackObjectsEstimate
+	}
+
+	// A commit, a tree, and two blobs:
+	value, e := scan()
+	assert.Equal(t, 4, e.ObjectCount)
+	assert.Equal(t, 10, e.Window)
+	assert.Equal(t, 8, e.Threads)
+	assert.Equal(t, 8*10*2*1000, e.WindowMemory)
+	// The average blob is (6+1000)/2 bytes, and chains are at most 50
+	// deltas deep:
+	assert.Equal(t, 8*50*503, e.DeltaBaseMemory)
+	assert.Equal(t, e.ObjectMemory+e.WindowMemory+e.DeltaBaseMemory, e.TotalMemory)
+	assert.Equal(t, e.TotalMemory, value)
+
+	// Selecting only this statistic must still count every object:
+	single, _ := scan("--stats=packObjectsMemory")
+	assert.Equal(t, value, single)
+
+	testRepo.ConfigAdd(t, "pack.threads", "2")
+	testRepo.ConfigAdd(t, "pack.windowMemory", "1k")
+	_, e = scan()
+	assert.Equal(t, 2, e.Threads)
+	assert.Equal(t, 2*1024, e.WindowMemory)
+}
+
+func TestCustomStats(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "custom-stats")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "data/a.parquet", strings.Repeat("a", 1000))
+	testRepo.AddFile(t, "b.parquet", strings.Repeat("b", 300))
+	// The same contents as "data/a.parquet", so it is counted once:
+	testRepo.AddFile(t, "copy/a.parquet", strings.Repeat("a", 1000))
+	testRepo.AddFile(t, "parquet.txt", "not data\n")
+	testRepo.Commit(t, "-m", "initial")
+	require.NoError(t, testRepo.GitCommand(t, "update-ref", "refs/pull/1/head", "HEAD").Run())
+	require.NoError(t, testRepo.GitCommand(t, "update-ref", "refs/pull/2/head", "HEAD").Run())
+
+	testRepo.ConfigAdd(t, "sizer.custom.dataFiles.pathRegexp", `\.parquet$`)
+	testRepo.ConfigAdd(t, "sizer.custom.dataFiles.metric", "totalSize")
+	testRepo.ConfigAdd(t, "sizer.custom.dataFileCount.pathRegexp", `\.parquet$`)
+	testRepo.ConfigAdd(t, "sizer.custom.biggestData.pathRegexp", `\.parquet$`)
+	testRepo.ConfigAdd(t, "sizer.custom.biggestData.metric", "maxSize")
+	testRepo.ConfigAdd(t, "sizer.custom.pulls.refRegexp", `^refs/pull/`)
+	testRepo.ConfigAdd(t, "sizer.custom.pulls.name", "Pull requests")
+
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2")
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	type stat struct {
+		Value             int
+		ObjectDescription string
+	}
+	var v struct {
+		DataFiles     stat `json:"custom.dataFiles"`
+		DataFileCount stat `json:"custom.dataFileCount"`
+		BiggestData   stat `json:"custom.biggestData"`
+		Pulls         stat `json:"custom.pulls"`
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+	assert.Equal(t, 1300, v.DataFiles.Value)
+	assert.Equal(t, 2, v.DataFileCount.Value)
+	assert.Equal(t, 1000, v.BiggestData.Value)
+	assert.Contains(
+		t, []string{"refs/heads/master:data/a.parquet", "refs/heads/master:copy/a.parquet"},
+		v.BiggestData.ObjectDescription,
+	)
+	assert.Equal(t, 2, v.Pulls.Value)
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--threshold=0")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "| Custom statistics            |")
+	assert.Contains(t, string(output), "| * Pull requests              |     2     |")
+
+	testRepo.ConfigAdd(t, "sizer.custom.pulls.metric", "totalSize")
+	cmd = exec.Command(sizerExe(t), "--no-progress")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.CombinedOutput()
+	require.Error(t, err)
+	assert.Contains(t, string(output), "its metric must be 'count'")
+}
+
+func TestServeStdio(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "serve-stdio")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "a.txt", "a\n")
+	testRepo.AddFile(t, "b.txt", "b\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	var stdin bytes.Buffer
+	writeFrame := func(body []byte) {
+		var header [4]byte
+		binary.BigEndian.PutUint32(header[:], uint32(len(body)))
+		stdin.Write(header[:])
+		stdin.Write(body)
+	}
+	writeRequest := func(req map[string]interface{}) {
+		body, err := json.Marshal(req)
+		require.NoError(t, err)
+		writeFrame(body)
+	}
+	writeRequest(map[string]interface{}{
+		"id": "1", "type": "scan", "dir": testRepo.Path,
+		"args": []string{"--stats=uniqueBlobCount"},
+	})
+	writeFrame([]byte("{not json"))
+	writeRequest(map[string]interface{}{
+		"id": "2", "type": "scan", "dir": testRepo.Path,
+		"args": []string{"--objects-from=-"},
+	})
+	writeRequest(map[string]interface{}{
+		"id": "3", "type": "scan", "dir": testRepo.Path,
+		"args": []string{"-v", "--size-oracle", "touch oracle-ran"},
+	})
+	writeRequest(map[string]interface{}{"id": "4", "type": "frobnicate"})
+
+	cmd := exec.Command(sizerExe(t), "--serve-stdio")
+	cmd.Stdin = &stdin
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	type message struct {
+		ID              string
+		Type            string
+		ProtocolVersion int
+		Phase           string
+		Done            bool
+		Result          struct {
+			UniqueBlobCount struct {
+				Value int
+			}
+		}
+		Error string
+	}
+	var messages []message
+	for len(output) > 0 {
+		require.GreaterOrEqual(t, len(output), 4)
+		size := binary.BigEndian.Uint32(output)
+		require.GreaterOrEqual(t, len(output), 4+int(size))
+		var m message
+		require.NoError(t, json.Unmarshal(output[4:4+size], &m))
+		messages = append(messages, m)
+		output = output[4+size:]
+	}
+
+	require.NotEmpty(t, messages)
+	assert.Equal(t, "ready", messages[0].Type)
+	assert.Equal(t, 1, messages[0].ProtocolVersion)
+
+	var progress []message
+	var answers []message
+	for _, m := range messages[1:] {
+		if m.Type == "progress" {
+			progress = append(progress, m)
+		} else {
+			answers = append(answers, m)
+		}
+	}
+
+	if assert.NotEmpty(t, progress) {
+		assert.Equal(t, "1", progress[0].ID)
+		assert.Equal(t, "Processing blobs", progress[0].Phase)
+		assert.True(t, progress[len(progress)-1].Done)
+	}
+
+	require.Len(t, answers, 5)
+	assert.Equal(t, "1", answers[0].ID)
+	assert.Equal(t, "result", answers[0].Type)
+	assert.Equal(t, 2, answers[0].Result.UniqueBlobCount.Value)
+
+	assert.Equal(t, "error", answers[1].Type)
+	assert.Contains(t, answers[1].Error, "parsing request")
+
+	assert.Equal(t, "2", answers[2].ID)
+	assert.Equal(t, "error", answers[2].Type)
+	assert.Contains(t, answers[2].Error, "'--objects-from' can't be used in a request")
+
+	assert.Equal(t, "3", answers[3].ID)
+	assert.Equal(t, "error", answers[3].Type)
+	assert.Contains(t, answers[3].Error, "'--size-oracle' can't be used in a request")
+	assert.NoFileExists(t, filepath.Join(testRepo.Path, "oracle-ran"))
+
+	assert.Equal(t, "4", answers[4].ID)
+	assert.Equal(t, "error", answers[4].Type)
+	assert.Contains(t, answers[4].Error, "unknown request type")
+}
+
+func TestLFSMigration(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "lfs-migration")
+	defer testRepo.Remove(t)
+
+	// Contents that don't compress well:
+	noise := func(seed string, n int) string {
+		buf := &strings.Builder{}
+		sum := sha256.Sum256([]byte(seed))
+		for buf.Len() < n {
+			sum = sha256.Sum256(sum[:])
+			buf.Write(sum[:])
+		}
+		return buf.String()[:n]
+	}
+
+	for i := 0; i < 2; i++ {
+		testRepo.AddFile(t, "media/video.bin", noise(fmt.Sprintf("video %d", i), 1500000))
+		testRepo.AddFile(t, "README", fmt.Sprintf("version %d\n", i))
+		testRepo.Commit(t, "-m", fmt.Sprintf("commit %d", i))
+	}
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2", "--lfs-cutoff=1",
+	)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	var v struct {
+		LFSMigration struct {
+			Cutoff    uint64 `json:"cutoff"`
+			BlobCount uint64 `json:"blob_count"`
+			BlobSize  uint64 `json:"blob_size"`
+			DiskSize  uint64 `json:"disk_size"`
+			Savings   uint64 `json:"savings"`
+		} `json:"lfsMigration"`
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+	m := v.LFSMigration
+	assert.EqualValues(t, 1<<20, m.Cutoff)
+	assert.EqualValues(t, 2, m.BlobCount)
+	assert.EqualValues(t, 2*1500000, m.BlobSize)
+	assert.Greater(t, m.DiskSize, uint64(2*1000000))
+	assert.Equal(t, m.DiskSize-2*130, m.Savings)
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--lfs-cutoff=1")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(
+		t, string(output),
+		"* Migrating the files whose blobs are larger than 1.00 MiB to Git LFS throughout the history would save about",
+	)
+	assert.Contains(t, string(output), "    * removing 2 blobs (2.86 MiB, ")
+	assert.Contains(t, string(output), "--above=1048576")
+
+	// Nothing is big enough:
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--lfs-cutoff=2")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
+	require.NoError(t, err)
+	assert.NotContains(t, string(output), "Git LFS")
+}
+
+func TestWideTrees(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "wide-trees")
+	defer testRepo.Remove(t)
+
+	for i := 0; i < 8; i++ {
+		testRepo.AddFile(
+			t, fmt.Sprintf("uploads/IMG_2023-01-%02dT12-00-00.jpg", i+10), fmt.Sprintf("image %d", i),
+		)
+		testRepo.AddFile(t, fmt.Sprintf("docs/%c.md", 'a'+i), fmt.Sprintf("doc %d", i))
+	}
+	testRepo.AddFile(t, "uploads/index.html", "index")
+	testRepo.Commit(t, "-m", "add files")
+
+	run := func(args ...string) []byte {
+		t.Helper()
+		cmd := exec.Command(sizerExe(t), append([]string{"--no-progress"}, args...)...)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err)
+		return output
+	}
+
+	var v struct {
+		WideTrees []struct {
+			Tree         string `json:"tree"`
+			EntryCount   int    `json:"entry_count"`
+			Pattern      string `json:"pattern"`
+			PatternCount int    `json:"pattern_count"`
+		} `json:"wideTrees"`
+	}
+	require.NoError(t, json.Unmarshal(
+		run("--json", "--json-version=2", "--reference-value=maxTreeEntries=5"), &v,
+	))
+	if assert.Len(t, v.WideTrees, 1, "docs/ has no common pattern") {
+		wt := v.WideTrees[0]
+		assert.Contains(t, wt.Tree, "(refs/heads/master:uploads)")
+		assert.Equal(t, 9, wt.EntryCount)
+		assert.Equal(t, "IMG_{n}.jpg", wt.Pattern)
+		assert.Equal(t, 8, wt.PatternCount)
+	}
+
+	output := string(run("--reference-value=maxTreeEntries=5"))
+	assert.Contains(t, output, "consider sharding them into subdirectories")
+	assert.Contains(t, output, "    * refs/heads/master:uploads: 9 entries, 8 of them named like 'IMG_{n}.jpg'\n")
+
+	output = string(run())
+	assert.NotContains(t, output, "sharding", "the trees are narrower than the reference value")
+}
+
+func TestTopObjects(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "top-objects")
+	defer testRepo.Remove(t)
+
+	for i, size := range []int{300, 100, 200} {
+		testRepo.AddFile(t, fmt.Sprintf("file%d.txt", i), strings.Repeat("x", size))
+	}
+	testRepo.Commit(t, "-m", "add files")
+
+	run := func(args ...string) []byte {
+		t.Helper()
+		cmd := exec.Command(sizerExe(t), append([]string{"--no-progress"}, args...)...)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err)
+		return output
+	}
+
+	type topObject struct {
+		Value             int
+		ObjectDescription string
+	}
+	var v struct {
+		MaxBlobSize struct {
+			Value      int
+			TopObjects []topObject
+		}
+	}
+	require.NoError(t, json.Unmarshal(
+		run("--json", "--json-version=2", "--top-objects=2"), &v,
+	))
+	assert.Equal(t, 300, v.MaxBlobSize.Value)
+	assert.Equal(
+		t,
+		[]topObject{{300, "refs/heads/master:file0.txt"}, {200, "refs/heads/master:file2.txt"}},
+		v.MaxBlobSize.TopObjects,
+	)
+
+	v.MaxBlobSize.TopObjects = nil
+	require.NoError(t, json.Unmarshal(run("--json", "--json-version=2"), &v))
+	assert.Nil(t, v.MaxBlobSize.TopObjects, "only the biggest object by default")
+
+	output := string(run("-v", "--top-objects=5"))
+	assert.Contains(
+		t, output,
+		"\nBiggest objects > Blobs > Maximum size:\n\n"+
+			"   1.    300 B   ",
+	)
+	assert.Contains(t, output, "   3.    100 B   ")
+	assert.NotContains(t, output, "   4. ")
+
+	require.NoError(t, testRepo.GitCommand(t, "config", "sizer.topObjects", "0").Run())
+	cmd := exec.Command(sizerExe(t), "--no-progress")
+	cmd.Dir = testRepo.Path
+	assert.Error(t, cmd.Run(), "invalid gitconfig value")
+}
+
+func TestAnomalyExamples(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "anomaly-examples")
+	defer testRepo.Remove(t)
+
+	for _, name := range []string{
+		"ok.txt", "docs/a:b", "docs/aux.c", "docs/NUL", "Con/readme", "sub/dir/what?",
+	} {
+		testRepo.AddFile(t, name, "Hello, world!\n")
+	}
+	testRepo.Commit(t, "-m", "initial")
+
+	type example struct {
+		ObjectName string
+		ObjectType string
+		EntryName  string
+	}
+	treeOID := func(rev string) string {
+		t.Helper()
+		out, err := testRepo.GitCommand(t, "rev-parse", rev).Output()
+		require.NoError(t, err)
+		return strings.TrimSpace(string(out))
+	}
+	expected := []example{
+		{treeOID("master^{tree}"), "tree", "Con"},
+		{treeOID("master:docs"), "tree", "NUL"},
+		{treeOID("master:docs"), "tree", "a:b"},
+		{treeOID("master:docs"), "tree", "aux.c"},
+		{treeOID("master:sub/dir"), "tree", "what?"},
+	}
+	sort.SliceStable(expected, func(i, j int) bool {
+		return expected[i].ObjectName < expected[j].ObjectName
+	})
+
+	run := func(args ...string) []byte {
+		t.Helper()
+		cmd := exec.Command(sizerExe(t), append([]string{"--no-progress", "--json"}, args...)...)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err)
+		return output
+	}
+
+	var v struct {
+		WindowsUnsafeEntryCount struct {
+			Value           int
+			AnomalyExamples []example
+		}
+	}
+	require.NoError(t, json.Unmarshal(run("--json-version=2"), &v))
+	assert.Equal(t, 5, v.WindowsUnsafeEntryCount.Value)
+	assert.Equal(t, expected, v.WindowsUnsafeEntryCount.AnomalyExamples, "lowest OIDs by default")
+
+	v.WindowsUnsafeEntryCount.AnomalyExamples = nil
+	require.NoError(t, json.Unmarshal(run("--json-version=2", "--anomaly-examples=2"), &v))
+	assert.Equal(t, expected[:2], v.WindowsUnsafeEntryCount.AnomalyExamples)
+
+	var v1 struct {
+		AnomalyExamples map[string][]struct {
+			OID        string `json:"oid"`
+			ObjectType string `json:"object_type"`
+			EntryName  string `json:"entry_name"`
+		} `json:"anomaly_examples"`
+	}
+	require.NoError(t, json.Unmarshal(run("--json-version=1", "--anomaly-examples=1"), &v1))
+	if assert.Len(t, v1.AnomalyExamples["windowsUnsafeEntryCount"], 1) {
+		e := v1.AnomalyExamples["windowsUnsafeEntryCount"][0]
+		assert.Equal(t, expected[0], example{e.OID, e.ObjectType, e.EntryName})
+	}
+
+	require.NoError(t, testRepo.GitCommand(t, "config", "sizer.anomalyExamples", "0").Run())
+	v1.AnomalyExamples = nil
+	require.NoError(t, json.Unmarshal(run("--json-version=1"), &v1))
+	assert.Nil(t, v1.AnomalyExamples, "disabled via gitconfig")
+}
+
+func TestSections(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "sections")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", "Hello, world!\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	run := func(args ...string) map[string]json.RawMessage {
+		t.Helper()
+		args = append([]string{"--no-progress", "--json", "--json-version=2"}, args...)
+		cmd := exec.Command(sizerExe(t), args...)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err)
+		var v map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(output, &v))
+		return v
+	}
+
+	v := run("--sections=history-structure,reference-tips")
+	assert.Contains(t, v, "maxHistoryDepth")
+	assert.Contains(t, v, "refgroup.branches.maxRefnameLength")
+	assert.NotContains(t, v, "uniqueCommitCount")
+	assert.NotContains(t, v, "maxBlobSize")
+	assert.NotContains(t, v, "maxCheckoutBlobCount")
+
+	v = run("--skip-sections=biggest-objects")
+	assert.Contains(t, v, "uniqueCommitCount")
+	assert.Contains(t, v, "refgroup.branches")
+	assert.Contains(t, v, "maxCheckoutBlobCount")
+	assert.NotContains(t, v, "maxBlobSize")
+
+	// The sections are combined with '--stats':
+	v = run("--sections=overall", "--stats=uniqueBlobCount,maxBlobSize")
+	assert.Contains(t, v, "uniqueBlobCount")
+	assert.NotContains(t, v, "maxBlobSize")
+	assert.NotContains(t, v, "uniqueCommitCount")
+
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--sections=nonexistent")
+	cmd.Dir = testRepo.Path
+	assert.Error(t, cmd.Run(), "unknown section")
+}
+
+func TestTeeJSON(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "tee-json")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", "Hello, world!\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	run := func(args ...string) string {
+		t.Helper()
+		args = append([]string{"--no-progress", "-v"}, args...)
+		cmd := exec.Command(sizerExe(t), args...)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err)
+		return string(output)
+	}
+
+	reportPath := filepath.Join(testRepo.Path, "report.json")
+
+	table := run("--tee-json", reportPath, "--json-version=2")
+	assert.Equal(t, run(), table)
+
+	report, err := os.ReadFile(reportPath)
+	require.NoError(t, err)
+	assert.JSONEq(t, run("--json", "--json-version=2"), string(report))
+
+	// With '--json', the same report goes to both places:
+	output := run("--json", "--json-version=2", "--json-compact", "--tee-json", reportPath)
+	report, err = os.ReadFile(reportPath)
+	require.NoError(t, err)
+	assert.Equal(t, output, string(report))
+
+	// '--digest' works with '--tee-json' alone:
+	run("--tee-json", reportPath, "--json-version=2", "--digest")
+	report, err = os.ReadFile(reportPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(report), `"reportDigest"`)
+}
+
+func TestGenerateTestRepo(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, true, "generate-test-repo")
+	defer testRepo.Remove(t)
+
+	generate := func(args ...string) ([]byte, error) {
+		t.Helper()
+		args = append([]string{"generate-test-repo"}, args...)
+		cmd := exec.Command(sizerExe(t), args...)
+		cmd.Dir = testRepo.Path
+		return cmd.CombinedOutput()
+	}
+
+	output, err := generate("--depth=4", "--breadth=5", "--ref=refs/heads/bomb")
+	require.NoError(t, err, string(output))
+	fields := strings.Fields(string(output))
+	require.Len(t, fields, 2)
+	assert.Equal(t, "refs/heads/bomb", fields[1])
+	resolve := func() string {
+		t.Helper()
+		out, err := testRepo.GitCommand(t, "rev-parse", "refs/heads/bomb").Output()
+		require.NoError(t, err)
+		return strings.TrimSpace(string(out))
+	}
+	assert.Equal(t, fields[0], resolve())
+
+	// An existing reference is never overwritten:
+	_, err = generate("--depth=2", "--breadth=2", "--ref=refs/heads/bomb")
+	assert.Error(t, err)
+	assert.Equal(t, fields[0], resolve())
+
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
+	require.NoError(t, err)
+
+	type stat struct {
+		Value uint64
+	}
+	var v struct {
+		MaxCheckoutBlobCount stat
+		UniqueBlobCount      stat
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+	assert.Equal(t, pow(5, 4), v.MaxCheckoutBlobCount.Value)
+	assert.Equal(t, uint64(1), v.UniqueBlobCount.Value)
+}
+
+// updateGolden tells `TestFixtures` to rewrite the golden files
+// instead of comparing against them: `go test -run TestFixtures
+// -update-golden`.
+var updateGolden = flag.Bool("update-golden", false, "rewrite the golden files under testdata/fixtures")
+
+func TestFixtures(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	// Ages are computed relative to the scan time, so it has to be
+	// pinned for the output to be reproducible. It is later than the
+	// fixtures' commits but earlier than their loose objects' mtimes,
+	// which therefore all count as being zero days old:
+	now := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, fixture := range fixtures.All() {
+		fixture := fixture
+		t.Run(fixture.Name, func(t *testing.T) {
+			t.Parallel()
+
+			testRepo := testutils.NewTestRepo(t, true, "fixture-"+fixture.Name)
+			defer testRepo.Remove(t)
+
+			repo := testRepo.Repository(t)
+			require.NoError(t, fixture.Build(ctx, repo))
+
+			refRoots, err := sizes.CollectReferences(ctx, repo, refGrouper{})
+			require.NoError(t, err)
+			roots := make([]sizes.Root, 0, len(refRoots))
+			for _, refRoot := range refRoots {
+				roots = append(roots, refRoot)
+			}
+
+			h, err := sizes.ScanRepositoryUsingGraph(
+				ctx, repo, roots, sizes.NameStyleFull, meter.NoProgressMeter,
+				sizes.ScanOptions{Now: now},
+			)
+			require.NoError(t, err)
+
+			// Like `git-sizer -v`:
+			h.SetShowScope(true)
+			table := h.TableString(nil, 0, sizes.NameStyleFull)
+			j, err := h.JSON(nil, 0, sizes.NameStyleFull, "    ")
+			require.NoError(t, err)
+
+			for ext, actual := range map[string][]byte{
+				"txt":  []byte(table),
+				"json": append(j, '\n'),
+			} {
+				path := filepath.Join("testdata", "fixtures", fixture.Name+"."+ext)
+				if *updateGolden {
+					require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+					require.NoError(t, os.WriteFile(path, actual, 0o644))
+					continue
+				}
+				expected, err := os.ReadFile(path)
+				require.NoError(t, err, "reading golden file; run with -update-golden to create it")
+				assert.Equal(
+					t, string(expected), string(actual),
+					"output differs from %s; run with -update-golden if the change is intended", path,
+				)
+			}
+		})
+	}
+}
+
+func TestTopCommitters(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "top-committers")
+	defer testRepo.Remove(t)
+
+	commit := func(committer, message string) {
+		t.Helper()
+		testRepo.AddFile(t, "file", message)
+		cmd := testRepo.AuthoredCommand(t, "commit", "-m", message)
+		cmd.Env = append(cmd.Env, "GIT_COMMITTER_NAME="+committer)
+		require.NoError(t, cmd.Run(), "creating commit")
+	}
+
+	commit("Constance", "initial")
+	commit("Robot", strings.Repeat("generated ", 100))
+	commit("Robot", strings.Repeat("generated again ", 100))
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2",
+		"--top-committers=1",
+	)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	type stat struct {
+		Value uint64
+	}
+	var v struct {
+		UniqueAuthorCount    stat
+		UniqueCommitterCount stat
+		TopCommitters        []sizes.CommitterTotal
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+	assert.Equal(t, uint64(1), v.UniqueAuthorCount.Value)
+	assert.Equal(t, uint64(2), v.UniqueCommitterCount.Value)
+	if assert.Len(t, v.TopCommitters, 1) {
+		assert.Equal(t, "Robot <constance@example.com>", v.TopCommitters[0].Committer)
+		assert.Equal(t, counts.Count32(2), v.TopCommitters[0].CommitCount)
+	}
+}
+
+func TestFileLineage(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "file-lineage")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", "Hello, world!\n")
+	testRepo.AddFile(t, "assets/video.bin", strings.Repeat("a", 1000))
+	testRepo.Commit(t, "-m", "initial")
+	testRepo.AddFile(t, "assets/video.bin", strings.Repeat("b", 2000))
+	testRepo.Commit(t, "-m", "bigger video")
+	require.NoError(t, testRepo.GitCommand(t, "mv", "assets", "media").Run(), "moving video")
+	testRepo.Commit(t, "-m", "move video")
+	testRepo.AddFile(t, "media/video.bin", strings.Repeat("c", 3000))
+	testRepo.Commit(t, "-m", "even bigger video")
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2",
+		"--file-lineage=2",
+	)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	var v struct {
+		FileLineage []struct {
+			Paths        []string
+			VersionCount uint64 `json:"version_count"`
+			TotalSize    uint64 `json:"total_size"`
+		}
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+
+	// The two largest blobs are versions of the same file, so only
+	// one history is reported:
+	if assert.Len(t, v.FileLineage, 1) {
+		l := v.FileLineage[0]
+		assert.Equal(t, []string{"media/video.bin", "assets/video.bin"}, l.Paths)
+		assert.Equal(t, uint64(3), l.VersionCount)
+		assert.Equal(t, uint64(6000), l.TotalSize)
+	}
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--file-lineage=1", "--anonymize")
+	cmd.Dir = testRepo.Path
+	assert.Error(t, cmd.Run(), "--file-lineage with --anonymize")
+
+	// Following the histories with path-limited walks gives the
+	// same results, with or without Bloom filters to speed them up:
+	for _, withFilters := range []bool{false, true} {
+		if withFilters {
+			require.NoError(
+				t,
+				testRepo.GitCommand(
+					t, "commit-graph", "write", "--reachable", "--changed-paths",
+				).Run(),
+				"writing commit-graph",
+			)
+		}
+
+		cmd = exec.Command(
+			sizerExe(t), "--no-progress", "--json", "--json-version=2",
+			"--file-lineage=2", "--bloom-filters",
+		)
+		cmd.Dir = testRepo.Path
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		output, err = cmd.Output()
+		require.NoError(t, err)
+		if withFilters {
+			assert.NotContains(t, stderr.String(), "Bloom filters")
+		} else {
+			assert.Contains(t, stderr.String(), "the commit-graph has no changed-path Bloom filters")
+		}
+
+		v.FileLineage = nil
+		require.NoError(t, json.Unmarshal(output, &v))
+		if assert.Len(t, v.FileLineage, 1) {
+			l := v.FileLineage[0]
+			assert.Equal(t, []string{"media/video.bin", "assets/video.bin"}, l.Paths)
+			assert.Equal(t, uint64(3), l.VersionCount)
+			assert.Equal(t, uint64(6000), l.TotalSize)
+		}
+	}
+}
+
+func TestSizeBudgetReport(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "size-budget")
+	defer testRepo.Remove(t)
+
+	// "big.bin" has three versions totaling 6000 bytes, and
+	// "medium.txt" two totaling 2000; the remaining 25 files have
+	// 40 bytes each, for 1000 in all:
+	for i := 0; i < 3; i++ {
+		testRepo.AddFile(t, "data/big.bin", strings.Repeat(strconv.Itoa(i), 2000))
+		if i < 2 {
+			testRepo.AddFile(t, "medium.txt", strings.Repeat(strconv.Itoa(i), 1000))
+		}
+		testRepo.Commit(t, "-m", fmt.Sprintf("commit %d", i))
+	}
+	for i := 0; i < 25; i++ {
+		testRepo.AddFile(t, fmt.Sprintf("small/%02d", i), fmt.Sprintf("%040d", i))
+	}
+	testRepo.Commit(t, "-m", "small files")
+
+	run := func(args ...string) []byte {
+		t.Helper()
+		cmd := exec.Command(sizerExe(t), append([]string{"--no-progress"}, args...)...)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err)
+		return output
+	}
+
+	type pathSize struct {
+		Path      string `json:"path"`
+		BlobCount int    `json:"blob_count"`
+		BlobSize  int    `json:"blob_size"`
+	}
+	var v struct {
+		SizeBudget struct {
+			Percent       int        `json:"percent"`
+			TotalBlobSize int        `json:"total_blob_size"`
+			PathCount     int        `json:"path_count"`
+			Paths         []pathSize `json:"paths"`
+			BlobSize      int        `json:"blob_size"`
+		} `json:"sizeBudget"`
+	}
+	require.NoError(t, json.Unmarshal(
+		run("--json", "--json-version=2", "--size-budget-report=80"), &v,
+	))
+	b := v.SizeBudget
+	assert.Equal(t, 80, b.Percent)
+	assert.Equal(t, 9000, b.TotalBlobSize)
+	assert.Equal(t, 27, b.PathCount)
+	assert.Equal(t, []pathSize{
+		{"data/big.bin", 3, 6000},
+		{"medium.txt", 2, 2000},
+	}, b.Paths)
+	assert.Equal(t, 8000, b.BlobSize)
+
+	output := string(run("--size-budget-report=50"))
+	assert.Contains(t, output, "1 of 27 paths account for 5.86 KiB of the 8.79 KiB of unique blobs (at least 50%):")
+	assert.Contains(t, output, "|         3 |   5.86 KiB |  66.7% | data/big.bin\n")
+	assert.NotContains(t, output, "medium.txt")
+
+	output = string(run("--size-budget-report=100"))
+	assert.Contains(t, output, "27 of 27 paths account for 8.79 KiB")
+}
+
+func TestMetadataFiles(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "metadata-files")
+	defer testRepo.Remove(t)
+
+	// Two versions of ".gitattributes", the second of which is also
+	// used in a subdirectory (but counted only once):
+	testRepo.AddFile(t, ".gitattributes", "*.bin binary\n")
+	testRepo.AddFile(t, ".githooks/pre-commit", "#!/bin/sh\nexit 0\n")
+	testRepo.AddFile(t, "update", "not a hook\n")
+	testRepo.Commit(t, "-m", "first")
+
+	attributes := strings.Repeat("*.dat filter=lfs\n", 100)
+	testRepo.AddFile(t, ".gitattributes", attributes)
+	testRepo.AddFile(t, "sub/.gitattributes", attributes)
+	testRepo.Commit(t, "-m", "second")
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2",
+		"--stats=maxGitmodulesSize,uniqueGitmodulesSize,maxGitattributesSize,"+
+			"uniqueGitattributesSize,maxHookFileSize,uniqueHookFileSize",
+	)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	type stat struct {
+		Value             uint64
+		ObjectDescription string
+	}
+	var v struct {
+		MaxGitmodulesSize       stat
+		UniqueGitmodulesSize    stat
+		MaxGitattributesSize    stat
+		UniqueGitattributesSize stat
+		MaxHookFileSize         stat
+		UniqueHookFileSize      stat
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+
+	assert.Zero(t, v.MaxGitmodulesSize.Value)
+	assert.Zero(t, v.UniqueGitmodulesSize.Value)
+	assert.Equal(t, uint64(len(attributes)), v.MaxGitattributesSize.Value)
+	assert.Contains(t, v.MaxGitattributesSize.ObjectDescription, ".gitattributes")
+	assert.Equal(
+		t, uint64(len(attributes)+len("*.bin binary\n")),
+		v.UniqueGitattributesSize.Value,
+	)
+	assert.Equal(t, uint64(len("#!/bin/sh\nexit 0\n")), v.MaxHookFileSize.Value)
+	assert.Contains(t, v.MaxHookFileSize.ObjectDescription, ".githooks/pre-commit")
+	assert.Equal(t, v.MaxHookFileSize.Value, v.UniqueHookFileSize.Value)
+}
+
+func TestRootTreeEntries(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "root-tree-entries")
+	defer testRepo.Remove(t)
+
+	// The root directory grows from 2 to 5 entries, then shrinks to
+	// 3:
+	testRepo.AddFile(t, "a", "a\n")
+	testRepo.AddFile(t, "b", "b\n")
+	testRepo.Commit(t, "-m", "first")
+
+	testRepo.AddFile(t, "c", "c\n")
+	testRepo.AddFile(t, "d", "d\n")
+	testRepo.AddFile(t, "sub/e", "e\n")
+	testRepo.Commit(t, "-m", "second")
+
+	require.NoError(t, testRepo.GitCommand(t, "rm", "-q", "c", "d").Run())
+	testRepo.Commit(t, "-m", "third")
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2",
+		"--stats=maxRootTreeEntries,minRootTreeEntries,currentRootTreeEntries",
+	)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	type stat struct {
+		Value             uint64
+		ObjectDescription string
+	}
+	var v struct {
+		MaxRootTreeEntries     stat
+		MinRootTreeEntries     stat
+		CurrentRootTreeEntries stat
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+
+	assert.Equal(t, uint64(5), v.MaxRootTreeEntries.Value)
+	assert.Equal(t, uint64(2), v.MinRootTreeEntries.Value)
+	assert.Equal(t, uint64(3), v.CurrentRootTreeEntries.Value)
+	assert.Equal(t, "refs/heads/master", v.CurrentRootTreeEntries.ObjectDescription)
+}
+
+func TestHostingLimits(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "hosting-limits")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "small.bin", strings.Repeat("x", 1<<20))
+	testRepo.AddFile(t, "models/medium.bin", strings.Repeat("y", 11<<20))
+	testRepo.AddFile(t, "models/large.bin", strings.Repeat("z", 26<<20))
+	testRepo.Commit(t, "-m", "add models")
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2",
+		"--hosting-limits=github,github-web,huggingface",
+	)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	var v struct {
+		HostingLimits []struct {
+			Preset            string
+			RejectedBlobCount int `json:"rejected_blob_count"`
+			WarnedBlobCount   int `json:"warned_blob_count"`
+			Blobs             []struct {
+				Name     string
+				Size     int
+				Rejected bool
+			}
+		}
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+	require.Len(t, v.HostingLimits, 3)
+
+	github := v.HostingLimits[0]
+	assert.Equal(t, "github", github.Preset)
+	assert.Zero(t, github.RejectedBlobCount)
+	assert.Zero(t, github.WarnedBlobCount)
+	assert.Empty(t, github.Blobs)
+
+	web := v.HostingLimits[1]
+	assert.Equal(t, 1, web.RejectedBlobCount)
+	if assert.Len(t, web.Blobs, 1) {
+		assert.Contains(t, web.Blobs[0].Name, "models/large.bin")
+		assert.Equal(t, 26<<20, web.Blobs[0].Size)
+		assert.True(t, web.Blobs[0].Rejected)
+	}
+
+	hf := v.HostingLimits[2]
+	assert.Equal(t, 2, hf.RejectedBlobCount)
+	if assert.Len(t, hf.Blobs, 2) {
+		assert.Contains(t, hf.Blobs[0].Name, "models/large.bin")
+		assert.Contains(t, hf.Blobs[1].Name, "models/medium.bin")
+	}
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--hosting-limits=all")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "huggingface (Hugging Face Hub")
+	assert.Contains(t, string(output), "blobs over 10.0 MiB that would be rejected: 2")
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--hosting-limits=nowhere")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.CombinedOutput()
+	assert.Error(t, err)
+	assert.Contains(t, string(output), "unknown hosting preset 'nowhere'")
+}
+
+func TestRemote(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, true, "remote")
+	defer testRepo.Remove(t)
+
+	for i := 0; i < 3; i++ {
+		testRepo.CreateReferencedOrphan(t, fmt.Sprintf("refs/heads/branch-%d", i))
+	}
+	require.NoError(t, testRepo.GitCommand(t, "config", "uploadpack.allowFilter", "true").Run())
+
+	// Run from a directory that isn't a repository at all:
+	workDir, err := os.MkdirTemp("", "remote-workdir")
+	require.NoError(t, err)
+	defer os.RemoveAll(workDir)
+
+	run := func(args ...string) (uint64, error) {
+		t.Helper()
+		cmd := exec.Command(
+			sizerExe(t),
+			append([]string{"--no-progress", "--json", "--json-version=2"}, args...)...,
+		)
+		cmd.Dir = workDir
+		output, err := cmd.Output()
+		if err != nil {
+			return 0, err
+		}
+		var v struct {
+			UniqueCommitCount struct {
+				Value uint64
+			}
+		}
+		require.NoError(t, json.Unmarshal(output, &v))
+		return v.UniqueCommitCount.Value, nil
+	}
+
+	n, err := run("--remote=" + testRepo.Path)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), n, "commits in the mirror clone")
+
+	n, err = run("--remote=file://"+testRepo.Path, "--remote-filter=blob:none")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), n, "commits in the partial clone")
+
+	_, err = run("--remote=" + filepath.Join(workDir, "missing"))
+	assert.Error(t, err, "nonexistent remote")
+
+	_, err = run("--remote-filter=blob:none")
+	assert.Error(t, err, "filter without remote")
+}
+
+func TestAllowShallow(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "allow-shallow")
+	defer testRepo.Remove(t)
+
+	for i := 0; i < 3; i++ {
+		testRepo.AddFile(t, "file", fmt.Sprintf("version %d\n", i))
+		testRepo.Commit(t, "-m", fmt.Sprintf("commit %d", i))
+	}
+
+	clonePath, err := os.MkdirTemp("", "allow-shallow-clone")
+	require.NoError(t, err)
+	clone := &testutils.TestRepo{Path: clonePath}
+	defer clone.Remove(t)
+	require.NoError(
+		t,
+		testRepo.GitCommand(t, "clone", "--depth=1", "file://"+testRepo.Path, clonePath).Run(),
+		"cloning",
+	)
+
+	cmd := exec.Command(sizerExe(t), "--no-progress")
+	cmd.Dir = clonePath
+	output, err := cmd.CombinedOutput()
+	assert.Error(t, err)
+	assert.Contains(t, string(output), "--allow-shallow")
+
+	cmd = exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2",
+		"--allow-shallow",
+	)
+	cmd.Dir = clonePath
+	output, err = cmd.Output()
+	require.NoError(t, err)
+
+	type stat struct {
+		Value uint64
+	}
+	var v struct {
+		UniqueCommitCount stat
+		ShallowBoundary   struct {
+			Commits           []string
+			BeyondCommitCount uint64 `json:"beyond_commit_count"`
+			BeyondObjectCount uint64 `json:"beyond_object_count"`
+		}
+		Caveats struct {
+			ShallowCommitCount int `json:"shallow_commit_count"`
+		}
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+	assert.Equal(t, uint64(1), v.UniqueCommitCount.Value)
+	assert.Len(t, v.ShallowBoundary.Commits, 1)
+	assert.Equal(t, uint64(2), v.ShallowBoundary.BeyondCommitCount)
+	// Each of the two commits beyond the boundary has its own tree
+	// and blob:
+	assert.Equal(t, uint64(6), v.ShallowBoundary.BeyondObjectCount)
+	assert.Equal(t, 1, v.Caveats.ShallowCommitCount)
+}
+
+func TestCaveats(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "caveats")
+	defer testRepo.Remove(t)
+
+	var commits []string
+	for i := 0; i < 3; i++ {
+		testRepo.AddFile(t, "file", fmt.Sprintf("version %d\n", i))
+		testRepo.Commit(t, "-m", fmt.Sprintf("commit %d", i))
+		out, err := testRepo.GitCommand(t, "rev-parse", "HEAD").Output()
+		require.NoError(t, err)
+		commits = append(commits, strings.TrimSpace(string(out)))
+	}
+
+	run := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command(sizerExe(t), append([]string{"--no-progress"}, args...)...)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err)
+		return string(output)
+	}
+
+	assert.NotContains(t, run("-v"), "Caveats")
+
+	// Make the last commit look like the first one, and the middle
+	// one look like a root commit:
+	require.NoError(t, testRepo.GitCommand(t, "replace", commits[2], commits[0]).Run())
+	require.NoError(t, os.WriteFile(
+		filepath.Join(testRepo.Path, ".git", "info", "grafts"),
+		[]byte("# a comment\n"+commits[1]+"\n"), 0o666,
+	))
+
+	type stat struct {
+		Value uint64
+	}
+	var v struct {
+		UniqueCommitCount stat
+		Caveats           struct {
+			ReplaceRefs        []string `json:"replace_refs"`
+			GraftsFile         string   `json:"grafts_file"`
+			GraftCount         int      `json:"graft_count"`
+			ShallowCommitCount int      `json:"shallow_commit_count"`
+		}
+	}
+	require.NoError(t, json.Unmarshal([]byte(run("--json", "--json-version=2")), &v))
+	// The replacement and the graft are ignored:
+	assert.Equal(t, uint64(3), v.UniqueCommitCount.Value)
+	assert.Equal(t, []string{"refs/replace/" + commits[2]}, v.Caveats.ReplaceRefs)
+	assert.Equal(t, "grafts", filepath.Base(v.Caveats.GraftsFile))
+	assert.Equal(t, 1, v.Caveats.GraftCount)
+	assert.Equal(t, 0, v.Caveats.ShallowCommitCount)
+
+	output := run("-v")
+	assert.Contains(t, output, "Caveats:")
+	assert.Contains(t, output, "1 replace reference (e.g., refs/replace/"+commits[2]+")")
+	assert.Contains(t, output, "The repository has 1 graft\n")
+	assert.NotContains(t, output, "shallow clone")
+}
+
+func TestObjectsSince(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "objects-since")
+	defer testRepo.Remove(t)
+
+	// `Commit()` advances the timestamp by one minute for each
+	// commit.
+	testRepo.SetTimestamp(time.Date(2023, 12, 31, 23, 59, 0, 0, time.UTC))
+
+	testRepo.AddFile(t, "old", strings.Repeat("o", 1000))
+	testRepo.Commit(t, "-m", "old")
+	testRepo.AddFile(t, "dir/new", strings.Repeat("n", 100))
+	testRepo.Commit(t, "-m", "new")
+	testRepo.AddFile(t, "dir/newer", strings.Repeat("n", 10))
+	testRepo.Commit(t, "-m", "newer")
+
+	type stat struct {
+		Value uint64
+	}
+	var v struct {
+		UniqueCommitCount stat
+		UniqueBlobCount   stat
+		UniqueBlobSize    stat
+		MaxBlobSize       stat
+		ObjectsSince      time.Time
+	}
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2",
+		"--objects-since=2024-01-01T00:00:00Z",
+	)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(output, &v))
+
+	assert.Equal(t, uint64(2), v.UniqueCommitCount.Value)
+	assert.Equal(t, uint64(2), v.UniqueBlobCount.Value)
+	assert.Equal(t, uint64(110), v.UniqueBlobSize.Value)
+	assert.Equal(t, uint64(100), v.MaxBlobSize.Value)
+	assert.True(t, v.ObjectsSince.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--objects-since=last tuesday")
+	cmd.Dir = testRepo.Path
+	assert.Error(t, cmd.Run(), "invalid date")
+}
+
+func TestIndexReflogNames(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "index-reflog-names")
+	defer testRepo.Remove(t)
+
+	git := func(args ...string) {
+		t.Helper()
+		cmd := testRepo.AuthoredCommand(t, args...)
+		require.NoError(t, cmd.Run(), "running 'git %s'", strings.Join(args, " "))
+	}
+
+	testRepo.AddFile(t, "README", "Hello, world!\n")
+	git("commit", "-m", "initial")
+
+	// A big file that is only remembered by the reflog of `HEAD`:
+	git("checkout", "-q", "-b", "topic")
+	testRepo.AddFile(t, "secret.bin", strings.Repeat("x", 1000))
+	git("commit", "-m", "oops")
+	git("checkout", "-q", "master")
+	git("branch", "-D", "topic")
+
+	scan := func() string {
+		t.Helper()
+		list, err := testRepo.GitCommand(
+			t, "rev-list", "--objects", "--date-order", "--all", "--reflog", "--indexed-objects",
+		).Output()
+		require.NoError(t, err)
+
+		cmd := exec.Command(
+			sizerExe(t), "--no-progress", "--json", "--json-version=2",
+			"--stats=maxBlobSize", "--objects-from=-", "--index-reflog-names",
+		)
+		cmd.Dir = testRepo.Path
+		cmd.Stdin = bytes.NewReader(list)
+		out, err := cmd.Output()
+		require.NoError(t, err)
+
+		var v struct {
+			MaxBlobSize struct {
+				ObjectDescription string
+			}
+		}
+		require.NoError(t, json.Unmarshal(out, &v))
+		return v.MaxBlobSize.ObjectDescription
+	}
+
+	assert.Regexp(t, `^HEAD@\{\d+\}:secret\.bin$`, scan())
+
+	// A bigger file that is only staged:
+	testRepo.AddFile(t, "staged.bin", strings.Repeat("y", 2000))
+	assert.Equal(t, ":staged.bin", scan())
+}
+
+func TestObjectsFrom(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "objects-from")
+	defer testRepo.Remove(t)
+
+	for i := 0; i < 3; i++ {
+		testRepo.AddFile(t, fmt.Sprintf("dir/file%d.txt", i), fmt.Sprintf("contents %d\n", i))
+		testRepo.Commit(t, "-m", fmt.Sprintf("commit %d", i))
+	}
+	cmd := testRepo.AuthoredCommand(t, "tag", "-m", "tag", "v1")
+	require.NoError(t, cmd.Run(), "creating tag")
+
+	revList := func(args ...string) string {
+		t.Helper()
+		out, err := testRepo.GitCommand(
+			t, append([]string{"rev-list", "--objects", "--date-order"}, args...)...,
+		).Output()
+		require.NoError(t, err)
+		return string(out)
+	}
+
+	writeList := func(name, contents string) string {
+		t.Helper()
+		path := filepath.Join(testRepo.Path, ".git", name)
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o666))
+		return path
+	}
+
+	type stat struct {
+		Value uint64
+	}
+	type result struct {
+		UniqueCommitCount stat
+		UniqueTreeCount   stat
+		UniqueBlobCount   stat
+		UniqueTagCount    stat
+		MaxHistoryDepth   stat
+	}
+	scan := func(stdin string, args ...string) (result, string, error) {
+		t.Helper()
+		cmd := exec.Command(
+			sizerExe(t), append([]string{"--no-progress", "--json", "--json-version=2"}, args...)...,
+		)
+		cmd.Dir = testRepo.Path
+		cmd.Stdin = strings.NewReader(stdin)
+		stderr := &bytes.Buffer{}
+		cmd.Stderr = stderr
+		out, err := cmd.Output()
+		var r result
+		if err == nil {
+			require.NoError(t, json.Unmarshal(out, &r))
+		}
+		return r, stderr.String(), err
+	}
+
+	expected, _, err := scan("")
+	require.NoError(t, err)
+
+	// The same objects as `git rev-list` would find, with duplicates
+	// and an empty line:
+	all := revList("--all")
+	r, _, err := scan("", "--objects-from="+writeList("all", all+"\n"+all))
+	require.NoError(t, err)
+	assert.Equal(t, expected, r)
+
+	// Only the history of the first two commits, via stdin:
+	r, _, err = scan(revList("HEAD~1"), "--objects-from=-")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), r.UniqueCommitCount.Value)
+	assert.Equal(t, uint64(2), r.UniqueBlobCount.Value)
+	assert.Equal(t, uint64(0), r.UniqueTagCount.Value)
+	assert.Equal(t, uint64(2), r.MaxHistoryDepth.Value)
+
+	// Incomplete or misordered lists are rejected:
+	for _, tc := range []struct {
+		name, list, message string
+	}{
+		{"no-blobs", revList("--all", "--filter=blob:none"), "wasn't among the objects scanned"},
+		{"no-trees", revList("--all", "--filter=tree:0"), "the object list is incomplete"},
+		{"reversed", revList("--all", "--reverse"), "is listed before the commit"},
+		{"malformed", "not an object name\n", "malformed line in object list"},
+		{"missing", strings.Repeat("1", 40) + "\n", "missing object"},
+	} {
+		_, stderr, err := scan("", "--objects-from="+writeList(tc.name, tc.list))
+		assert.Error(t, err, tc.name)
+		assert.Contains(t, stderr, tc.message, tc.name)
+	}
+}
+
+func TestDiffReports(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "diff-reports")
+	defer testRepo.Remove(t)
+
+	commit := func(filename, contents string) {
+		t.Helper()
+		testRepo.AddFile(t, filename, contents)
+		testRepo.Commit(t, "-m", "add "+filename)
+	}
+
+	reportsDir := t.TempDir()
+	scan := func(name string, args ...string) string {
+		t.Helper()
+		path := filepath.Join(reportsDir, name)
+		args = append(
+			[]string{
+				"--no-progress", "--tee-json=" + path,
+				"--save-baseline=" + filepath.Join(reportsDir, "baseline.json"),
+			},
+			args...,
+		)
+		cmd := exec.Command(sizerExe(t), args...)
+		cmd.Dir = testRepo.Path
+		require.NoError(t, cmd.Run())
+		return path
+	}
+
+	commit("README", "Hello, world!\n")
+	before := scan("before.json", "--json-version=2")
+	beforeV1 := scan("before-v1.json", "--json-version=1")
+	commit("big.bin", strings.Repeat("x", 100000))
+	after := scan("after.json.gz", "--json-version=2")
+	afterV1 := scan("after-v1.json", "--json-version=1")
+
+	// The reports are compared without the repository:
+	diff := func(args ...string) ([]byte, error) {
+		t.Helper()
+		cmd := exec.Command(sizerExe(t), append([]string{"diff-reports"}, args...)...)
+		cmd.Dir = reportsDir
+		return cmd.Output()
+	}
+
+	out, err := diff(before, after)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "Growth sources between the reports:\n")
+	assert.Contains(t, string(out), "| branches         |     6     |     +3     |")
+	assert.Contains(t, string(out), "| uniqueBlobCount                          |     1     |     2     |     +1     |\n")
+	assert.NotContains(t, string(out), "| referenceCount ")
+
+	out, err = diff("--json", before, after)
+	require.NoError(t, err)
+	var d sizes.ReportDiff
+	require.NoError(t, json.Unmarshal(out, &d))
+	require.NotNil(t, d.Growth)
+	require.Len(t, d.Growth.RefGroups, 1)
+	assert.Equal(t, int64(3), d.Growth.RefGroups[0].ObjectCountGrowth)
+	found := false
+	for _, sd := range d.Stats {
+		if sd.Symbol == "uniqueCommitCount" {
+			found = true
+			assert.Equal(t, uint64(1), sd.Before)
+			assert.Equal(t, uint64(2), sd.After)
+			assert.Equal(t, int64(1), sd.Change)
+		}
+	}
+	assert.True(t, found, "uniqueCommitCount is compared")
+
+	out, err = diff(beforeV1, afterV1)
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "| unique_blob_count                        |     1     |     2     |     +1     |\n")
+
+	_, err = diff(before, afterV1)
+	assert.Error(t, err, "reports of different versions")
+}
+
+func TestDumpObjects(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "dump-objects")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", "Hello, world!\n")
+	testRepo.AddFile(t, "dir/file", "Hello, again!\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	dump := func(format string) []byte {
+		t.Helper()
+		path := filepath.Join(testRepo.Path, "objects."+format)
+		cmd := exec.Command(
+			sizerExe(t), "--no-progress", "--dump-objects="+path, "--dump-format="+format,
+		)
+		cmd.Dir = testRepo.Path
+		require.NoError(t, cmd.Run())
+		contents, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return contents
+	}
+
+	// Tally the objects by type:
+	check := func(records []objdump.Record) {
+		t.Helper()
+		types := make(map[string]int)
+		for _, r := range records {
+			types[r.Type]++
+			assert.Len(t, r.OID, 40)
+			if r.OID == "af5626b4a114abcb82d63db7c8082c3c4756e51b" {
+				assert.Equal(t, "blob", r.Type)
+				assert.Equal(t, uint64(14), r.Size)
+			}
+		}
+		assert.Equal(t, map[string]int{"commit": 1, "tree": 2, "blob": 2}, types)
+	}
+
+	var records []objdump.Record
+	for _, line := range strings.Split(strings.TrimSpace(string(dump("ndjson"))), "\n") {
+		var r objdump.Record
+		require.NoError(t, json.Unmarshal([]byte(line), &r))
+		records = append(records, r)
+	}
+	check(records)
+
+	records = nil
+	dec := gob.NewDecoder(bytes.NewReader(dump("gob")))
+	for {
+		var r objdump.Record
+		if err := dec.Decode(&r); err == io.EOF {
+			break
+		} else {
+			require.NoError(t, err)
+		}
+		records = append(records, r)
+	}
+	check(records)
+
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--dump-objects=x", "--dump-format=csv")
+	cmd.Dir = testRepo.Path
+	assert.Error(t, cmd.Run(), "unknown format")
+}
+
+func TestCompressedFiles(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "compressed-files")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", "Hello, world!\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	dumpPath := filepath.Join(testRepo.Path, "objects.ndjson.gz")
+	baselinePath := filepath.Join(testRepo.Path, "baseline.json.gz")
+	reportPath := filepath.Join(testRepo.Path, "report.json.gz")
+
+	// Files whose names end in ".gz" are written gzipped:
+	gunzip := func(path string) []byte {
+		t.Helper()
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		defer f.Close()
+		r, err := gzip.NewReader(f)
+		require.NoError(t, err, path)
+		contents, err := io.ReadAll(r)
+		require.NoError(t, err, path)
+		return contents
+	}
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--dump-objects="+dumpPath,
+		"--save-baseline="+baselinePath, "--tee-json="+reportPath,
+	)
+	cmd.Dir = testRepo.Path
+	require.NoError(t, cmd.Run())
+
+	lines := strings.Split(strings.TrimSpace(string(gunzip(dumpPath))), "\n")
+	assert.Len(t, lines, 3)
+	var r objdump.Record
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &r))
+
+	var report map[string]interface{}
+	require.NoError(t, json.Unmarshal(gunzip(reportPath), &report))
+	assert.Contains(t, report, "unique_blob_count")
+
+	var baseline sizes.Baseline
+	require.NoError(t, json.Unmarshal(gunzip(baselinePath), &baseline))
+
+	// ...and gzipped files are read transparently, whatever their
+	// names:
+	plainPath := filepath.Join(testRepo.Path, "baseline.json")
+	require.NoError(t, os.Rename(baselinePath, plainPath))
+	cmd = exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2", "--baseline="+plainPath,
+	)
+	cmd.Dir = testRepo.Path
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	var output struct {
+		Growth struct {
+			RefGroups []struct {
+				RefGroup          string `json:"refgroup"`
+				ObjectCountGrowth int64  `json:"object_count_growth"`
+			} `json:"refgroups"`
+		} `json:"growth"`
+	}
+	require.NoError(t, json.Unmarshal(out, &output))
+	require.Len(t, output.Growth.RefGroups, 1)
+	assert.Equal(t, int64(0), output.Growth.RefGroups[0].ObjectCountGrowth)
+}
+
+func TestNonCommitReferences(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "non-commit-references")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", "Hello, world!\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	// `git update-ref` refuses to point a branch at a blob, so write
+	// the reference directly:
+	require.NoError(t, os.WriteFile(
+		filepath.Join(testRepo.Path, ".git", "refs", "heads", "blob"),
+		[]byte("af5626b4a114abcb82d63db7c8082c3c4756e51b\n"), 0o644,
+	))
+
+	cmd := testRepo.AuthoredCommand(t, "tag", "-m", "tree", "tree-tag", "HEAD^{tree}")
+	require.NoError(t, cmd.Run(), "tagging tree")
+
+	cmd = testRepo.AuthoredCommand(t, "tag", "-m", "commit", "commit-tag", "HEAD")
+	require.NoError(t, cmd.Run(), "tagging commit")
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2")
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	type stat struct {
+		Value             uint64
+		ObjectDescription string
+	}
+	var v struct {
+		NonCommitBranchCount stat
+		NonCommitTagCount    stat
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+
+	assert.Equal(t, uint64(1), v.NonCommitBranchCount.Value)
+	assert.Equal(t, "refs/heads/blob", v.NonCommitBranchCount.ObjectDescription)
+	assert.Equal(t, uint64(1), v.NonCommitTagCount.Value)
+	assert.Equal(t, "refs/tags/tree-tag", v.NonCommitTagCount.ObjectDescription)
+}
+
+func TestProfile(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "profile")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", "Hello, world!\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	type stat struct {
+		ReferenceValue float64
+	}
+	type output struct {
+		MaxBlobSize    stat
+		MaxTreeEntries stat
+		ReferenceCount stat
+	}
+
+	scan := func(args ...string) output {
+		t.Helper()
+		cmd := exec.Command(
+			sizerExe(t), append([]string{"--no-progress", "--json", "--json-version=2"}, args...)...,
+		)
+		cmd.Dir = testRepo.Path
+		out, err := cmd.Output()
+		require.NoError(t, err)
+		var v output
+		require.NoError(t, json.Unmarshal(out, &v))
+		return v
+	}
+
+	assert.Equal(
+		t,
+		output{stat{10e6}, stat{1000}, stat{25e3}},
+		scan(),
+	)
+	assert.Equal(
+		t,
+		output{stat{1e6}, stat{5000}, stat{250e3}},
+		scan("--profile=monorepo"),
+	)
+	assert.Equal(
+		t,
+		output{stat{10e6}, stat{1000}, stat{1e6}},
+		scan("--profile=forge"),
+	)
+
+	cmd := testRepo.GitCommand(t, "config", "sizer.profile", "small")
+	require.NoError(t, cmd.Run())
+	assert.Equal(
+		t,
+		output{stat{1e6}, stat{1000}, stat{2500}},
+		scan(),
+	)
+	assert.Equal(
+		t,
+		output{stat{10e6}, stat{1000}, stat{25e3}},
+		scan("--profile=default"),
+	)
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--profile=huge")
+	cmd.Dir = testRepo.Path
+	assert.Error(t, cmd.Run())
+}
+
+func TestReferenceValueOverrides(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "reference-value")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", strings.Repeat("x", 1000))
+	testRepo.Commit(t, "-m", "initial")
+
+	type stat struct {
+		ReferenceValue float64
+		LevelOfConcern float64
+	}
+	type referenceValue struct {
+		Value  float64
+		Source string
+	}
+	type output struct {
+		MaxBlobSize     stat
+		ReferenceCount  stat
+		EffectiveConfig struct {
+			Profile         string
+			Threshold       float64
+			NameStyle       string
+			ReferenceValues map[string]referenceValue
+		}
+	}
+
+	scan := func(args ...string) output {
+		t.Helper()
+		cmd := exec.Command(
+			sizerExe(t), append([]string{"--no-progress", "--json", "--json-version=2"}, args...)...,
+		)
+		cmd.Dir = testRepo.Path
+		out, err := cmd.Output()
+		require.NoError(t, err)
+		var v output
+		require.NoError(t, json.Unmarshal(out, &v))
+		return v
+	}
+
+	v := scan()
+	assert.Equal(t, stat{10e6, 1e-4}, v.MaxBlobSize)
+	assert.Equal(t, "default", v.EffectiveConfig.Profile)
+	assert.Equal(t, 1.0, v.EffectiveConfig.Threshold)
+	assert.Equal(t, "full", v.EffectiveConfig.NameStyle)
+	assert.Equal(
+		t, referenceValue{10e6, "default"}, v.EffectiveConfig.ReferenceValues["maxBlobSize"],
+	)
+
+	v = scan("--profile=forge", "--reference-value=maxBlobSize=500", "--threshold=2")
+	assert.Equal(t, stat{500, 2}, v.MaxBlobSize)
+	assert.Equal(t, "forge", v.EffectiveConfig.Profile)
+	assert.Equal(t, 2.0, v.EffectiveConfig.Threshold)
+	assert.Equal(
+		t, referenceValue{500, "override"}, v.EffectiveConfig.ReferenceValues["maxBlobSize"],
+	)
+	assert.Equal(
+		t, referenceValue{1e6, "profile"}, v.EffectiveConfig.ReferenceValues["referenceCount"],
+	)
+
+	require.NoError(t, testRepo.GitCommand(
+		t, "config", "--add", "sizer.referenceValue", "maxBlobSize=250",
+	).Run())
+	require.NoError(t, testRepo.GitCommand(
+		t, "config", "--add", "sizer.referenceValue", "referenceCount=10",
+	).Run())
+	v = scan("--reference-value=maxBlobSize=100")
+	assert.Equal(t, stat{100, 10}, v.MaxBlobSize, "the option overrides gitconfig")
+	assert.Equal(t, 10.0, v.ReferenceCount.ReferenceValue)
+
+	for _, arg := range []string{"maxBlobSize", "noSuchStat=5", "maxBlobSize=0", "maxBlobSize=x"} {
+		cmd := exec.Command(sizerExe(t), "--no-progress", "--reference-value="+arg)
+		cmd.Dir = testRepo.Path
+		assert.Error(t, cmd.Run(), arg)
+	}
+}
+
+func TestDocLinks(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "doc-links")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", strings.Repeat("x", 1000))
+	testRepo.Commit(t, "-m", "initial")
+
+	require.NoError(t, testRepo.GitCommand(
+		t, "config", "sizer.link.maxBlobSize", "https://wiki.example.com/big-blobs",
+	).Run())
+	require.NoError(t, testRepo.GitCommand(
+		t, "config", "sizer.link.referenceCount", "https://wiki.example.com/refs",
+	).Run())
+
+	run := func(args ...string) []byte {
+		t.Helper()
+		cmd := exec.Command(sizerExe(t), append([]string{"--no-progress"}, args...)...)
+		cmd.Dir = testRepo.Path
+		out, err := cmd.Output()
+		require.NoError(t, err)
+		return out
+	}
+
+	type stat struct {
+		DocLink string
+	}
+	var v struct {
+		MaxBlobSize    stat
+		ReferenceCount stat
+		MaxTreeEntries stat
+	}
+	out := run(
+		"--json", "--json-version=2",
+		"--doc-link=referenceCount=https://runbooks.example.com/refs",
+	)
+	require.NoError(t, json.Unmarshal(out, &v))
+	assert.Equal(t, "https://wiki.example.com/big-blobs", v.MaxBlobSize.DocLink)
+	assert.Equal(
+		t, "https://runbooks.example.com/refs", v.ReferenceCount.DocLink,
+		"the option overrides gitconfig",
+	)
+	assert.Empty(t, v.MaxTreeEntries.DocLink)
+
+	out = run("--threshold=0", "--stats=maxBlobSize")
+	assert.Regexp(t, `\* Maximum size +\[1\]\[2\] \|`, string(out))
+	assert.Contains(t, string(out), "[2]  See https://wiki.example.com/big-blobs\n")
+
+	for _, arg := range []string{"maxBlobSize", "noSuchStat=https://example.com", "maxBlobSize=wiki"} {
+		cmd := exec.Command(sizerExe(t), "--no-progress", "--doc-link="+arg)
+		cmd.Dir = testRepo.Path
+		assert.Error(t, cmd.Run(), arg)
+	}
+}
+
+func TestConcernWeights(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "concern-weights")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", strings.Repeat("x", 1500))
+	testRepo.Commit(t, "-m", "initial")
+
+	run := func(args ...string) []byte {
+		t.Helper()
+		cmd := exec.Command(
+			sizerExe(t),
+			append([]string{"--no-progress", "--reference-value=maxBlobSize=500"}, args...)...,
+		)
+		cmd.Dir = testRepo.Path
+		out, err := cmd.Output()
+		require.NoError(t, err)
+		return out
+	}
+
+	type weight struct {
+		Function string
+		Weight   float64
+	}
+	type output struct {
+		MaxBlobSize struct {
+			LevelOfConcern float64
+		}
+		EffectiveConfig struct {
+			ConcernWeights map[string]weight
+		}
+	}
+	scan := func(args ...string) output {
+		t.Helper()
+		var v output
+		require.NoError(t, json.Unmarshal(
+			run(append([]string{"--json", "--json-version=2"}, args...)...), &v,
+		))
+		return v
+	}
+
+	// The value is three times the reference value:
+	v := scan()
+	assert.Equal(t, 3.0, v.MaxBlobSize.LevelOfConcern)
+	assert.Empty(t, v.EffectiveConfig.ConcernWeights)
+
+	v = scan("--concern-weight=maxBlobSize=linear:2")
+	assert.Equal(t, 6.0, v.MaxBlobSize.LevelOfConcern)
+	assert.Equal(
+		t, map[string]weight{"maxBlobSize": {"linear", 2}}, v.EffectiveConfig.ConcernWeights,
+	)
+
+	v = scan("--concern-weight=maxBlobSize=log")
+	assert.Equal(t, 2.0, v.MaxBlobSize.LevelOfConcern)
+
+	require.NoError(t, testRepo.GitCommand(
+		t, "config", "sizer.concernWeight.maxBlobSize", "log:0.5",
+	).Run())
+	v = scan()
+	assert.Equal(t, 1.0, v.MaxBlobSize.LevelOfConcern)
+	v = scan("--concern-weight=maxBlobSize=linear")
+	assert.Equal(t, 3.0, v.MaxBlobSize.LevelOfConcern, "the option overrides gitconfig")
+
+	// The weighted level of concern determines the stars and whether
+	// the statistic passes the threshold:
+	out := string(run("--concern-weight=maxBlobSize=log", "--stats=maxBlobSize", "--threshold=2"))
+	assert.Regexp(t, `\* Maximum size +\[1\] \| +1\.46 KiB \| \*\* +\|\n`, out)
+	out = string(run("--stats=maxBlobSize", "--threshold=2"))
+	assert.NotContains(t, out, "Maximum size")
+
+	for _, arg := range []string{
+		"maxBlobSize", "noSuchStat=log", "maxBlobSize=cubic", "maxBlobSize=log:0", "maxBlobSize=linear:x",
+	} {
+		cmd := exec.Command(sizerExe(t), "--no-progress", "--concern-weight="+arg)
+		cmd.Dir = testRepo.Path
+		assert.Error(t, cmd.Run(), arg)
+	}
+}
+
+func TestColor(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "color")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", strings.Repeat("x", 1000))
+	testRepo.Commit(t, "-m", "initial")
+
+	run := func(args ...string) (string, error) {
+		t.Helper()
+		args = append(
+			[]string{
+				"--no-progress", "--stats=maxBlobSize,uniqueBlobSize",
+				"--reference-value=maxBlobSize=10", "--reference-value=uniqueBlobSize=500",
+			},
+			args...,
+		)
+		cmd := exec.Command(sizerExe(t), args...)
+		cmd.Dir = testRepo.Path
+		out, err := cmd.Output()
+		return string(out), err
+	}
+
+	out, err := run()
+	require.NoError(t, err)
+	assert.NotContains(t, out, "\x1b[", "not a terminal")
+
+	out, err = run("--color=never")
+	require.NoError(t, err)
+	assert.NotContains(t, out, "\x1b[")
+
+	out, err = run("--color=always")
+	require.NoError(t, err)
+	assert.Contains(
+		t, out,
+		"|   * Total size               |  1000 B   | \x1b[32m**\x1b[39m                             |\n",
+	)
+	assert.Contains(
+		t, out,
+		"\x1b[1m|   * Maximum size         [1] |  1000 B   | "+
+			"\x1b[31m!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!\x1b[39m |\x1b[22m\n",
+		"off-the-scale rows are highlighted",
+	)
+
+	require.NoError(t, testRepo.GitCommand(t, "config", "sizer.color", "always").Run())
+	out, err = run()
+	require.NoError(t, err)
+	assert.Contains(t, out, "\x1b[32m**\x1b[39m")
+
+	_, err = run("--color=sometimes")
+	assert.Error(t, err)
+}
+
+func TestThresholdsFile(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "thresholds-file")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", strings.Repeat("x", 1000))
+	testRepo.Commit(t, "-m", "initial")
+
+	writeFile := func(name, contents string) string {
+		t.Helper()
+		path := filepath.Join(testRepo.Path, name)
+		require.NoError(t, os.WriteFile(path, []byte(contents), 0o644))
+		return path
+	}
+
+	type stat struct {
+		ReferenceValue float64
+		LevelOfConcern float64
+	}
+	type referenceValue struct {
+		Value  float64
+		Source string
+	}
+	type output struct {
+		MaxBlobSize     stat
+		ReferenceCount  stat
+		EffectiveConfig struct {
+			Profile         string
+			Threshold       float64
+			ReferenceValues map[string]referenceValue
+			Thresholds      *struct {
+				Name    string
+				Version int
+				Path    string
+				SHA256  string
+			}
+		}
+	}
+
+	scan := func(args ...string) output {
+		t.Helper()
+		cmd := exec.Command(
+			sizerExe(t), append([]string{"--no-progress", "--json", "--json-version=2"}, args...)...,
+		)
+		cmd.Dir = testRepo.Path
+		out, err := cmd.Output()
+		require.NoError(t, err)
+		var v output
+		require.NoError(t, json.Unmarshal(out, &v))
+		return v
+	}
+
+	// The file's settings replace those in gitconfig:
+	require.NoError(t, testRepo.GitCommand(
+		t, "config", "--add", "sizer.referenceValue", "maxBlobSize=250",
+	).Run())
+	require.NoError(t, testRepo.GitCommand(t, "config", "sizer.threshold", "5").Run())
+
+	yamlPath := writeFile("policy.yaml", `# The canonical policy.
+version: 1
+name: acme
+profile: forge
+threshold: 2
+reject_threshold: 10
+reference_values:
+  maxBlobSize: 500
+`)
+	v := scan("--thresholds-file=" + yamlPath)
+	assert.Equal(t, stat{500, 2}, v.MaxBlobSize)
+	assert.Equal(t, "forge", v.EffectiveConfig.Profile)
+	assert.Equal(t, 2.0, v.EffectiveConfig.Threshold)
+	assert.Equal(
+		t, referenceValue{500, "thresholds"}, v.EffectiveConfig.ReferenceValues["maxBlobSize"],
+	)
+	assert.Equal(
+		t, referenceValue{1e6, "profile"}, v.EffectiveConfig.ReferenceValues["referenceCount"],
+	)
+	if assert.NotNil(t, v.EffectiveConfig.Thresholds) {
+		assert.Equal(t, "acme", v.EffectiveConfig.Thresholds.Name)
+		assert.Equal(t, 1, v.EffectiveConfig.Thresholds.Version)
+		assert.Equal(t, yamlPath, v.EffectiveConfig.Thresholds.Path)
+		assert.Len(t, v.EffectiveConfig.Thresholds.SHA256, 64)
+	}
+
+	// Command-line options still take precedence:
+	v = scan("--thresholds-file="+yamlPath, "--reference-value=maxBlobSize=100", "--threshold=3")
+	assert.Equal(t, stat{100, 10}, v.MaxBlobSize)
+	assert.Equal(t, 3.0, v.EffectiveConfig.Threshold)
+	assert.Equal(
+		t, referenceValue{100, "override"}, v.EffectiveConfig.ReferenceValues["maxBlobSize"],
+	)
+
+	// The same policy as JSON, selected via gitconfig:
+	jsonPath := writeFile("policy.json", `{
+    "version": 1,
+    "profile": "forge",
+    "reference_values": {"maxBlobSize": 500}
+}
+`)
+	require.NoError(t, testRepo.GitCommand(t, "config", "sizer.thresholdsFile", jsonPath).Run())
+	v = scan()
+	assert.Equal(t, stat{500, 2}, v.MaxBlobSize)
+	assert.Equal(t, 1.0, v.EffectiveConfig.Threshold, "the default, not gitconfig's")
+	assert.Equal(t, "forge", v.EffectiveConfig.Profile)
+	require.NoError(t, testRepo.GitCommand(t, "config", "--unset", "sizer.thresholdsFile").Run())
+
+	for name, contents := range map[string]string{
+		"no-version.yaml":    "reference_values:\n  maxBlobSize: 500\n",
+		"bad-version.yaml":   "version: 2\n",
+		"unknown-field.yaml": "version: 1\nreference_value:\n  maxBlobSize: 500\n",
+		"unknown-stat.yaml":  "version: 1\nreference_values:\n  noSuchStat: 5\n",
+		"bad-value.yaml":     "version: 1\nreference_values:\n  maxBlobSize: 0\n",
+		"bad-profile.yaml":   "version: 1\nprofile: huge\n",
+		"bad-threshold.yaml": "version: 1\nthreshold: -1\n",
+		"bad-reject.yaml":    "version: 1\nreject_threshold: 0\n",
+		"empty.yaml":         "",
+		"missing-file.yaml":  "",
+		"not-a-mapping.yaml": "- version: 1\n",
+	} {
+		path := filepath.Join(testRepo.Path, name)
+		if name != "missing-file.yaml" {
+			path = writeFile(name, contents)
+		}
+		cmd := exec.Command(sizerExe(t), "--no-progress", "--thresholds-file="+path)
+		cmd.Dir = testRepo.Path
+		assert.Error(t, cmd.Run(), name)
+	}
+}
+
+func TestThresholdsPushOptions(t *testing.T) {
+	t.Parallel()
+
+	rejectThreshold := sizes.Threshold(10)
+	thresholds := &sizes.Thresholds{
+		Version:         1,
+		Profile:         sizes.ProfileSmall,
+		RejectThreshold: &rejectThreshold,
+		ReferenceValues: sizes.ReferenceValues{"maxBlobSize": 5e6},
+	}
+	require.NoError(t, thresholds.Validate())
+
+	opts := thresholds.PushOptions(sizes.ScanOptions{
+		ReferenceValues: sizes.ReferenceValues{"maxTreeEntries": 10},
+	})
+	assert.Equal(t, sizes.Threshold(10), opts.RejectThreshold)
+	assert.Equal(t, sizes.ProfileSmall, opts.Profile)
+	assert.Equal(t, sizes.ReferenceValues{"maxBlobSize": 5e6}, opts.ReferenceValues)
+	assert.Same(t, thresholds, opts.Thresholds)
+}
+
+func TestHealthScore(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "health-score")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "big.bin", strings.Repeat("x", 1000))
+	testRepo.Commit(t, "-m", "initial")
+
+	type category struct {
+		Category            string
+		Weight              float64
+		Score               float64
+		PointsLost          float64 `json:"points_lost"`
+		WorstStat           string  `json:"worst_stat"`
+		WorstLevelOfConcern float64 `json:"worst_level_of_concern"`
+	}
+	type healthScore struct {
+		Score      float64
+		Categories []category
+	}
+
+	scan := func(args ...string) healthScore {
+		t.Helper()
+		cmd := exec.Command(
+			sizerExe(t),
+			append(
+				[]string{
+					"--no-progress", "--json", "--json-version=2", "--health-score",
+					"--reference-value=maxBlobSize=100",
+				},
+				args...,
+			)...,
+		)
+		cmd.Dir = testRepo.Path
+		out, err := cmd.Output()
+		require.NoError(t, err)
+		var v struct {
+			HealthScore *healthScore
+		}
+		require.NoError(t, json.Unmarshal(out, &v))
+		require.NotNil(t, v.HealthScore)
+		return *v.HealthScore
+	}
+
+	// A blob ten times the reference value costs its category about
+	// 70% of its points:
+	h := scan()
+	var totalWeight, pointsLost float64
+	var biggestObjects *category
+	for i, c := range h.Categories {
+		totalWeight += c.Weight
+		pointsLost += c.PointsLost
+		if c.Category == "biggest-objects" {
+			biggestObjects = &h.Categories[i]
+		}
+	}
+	assert.InDelta(t, 1, totalWeight, 1e-9)
+	assert.InDelta(t, 100-pointsLost, h.Score, 0.05)
+	if assert.NotNil(t, biggestObjects) {
+		assert.Equal(t, "maxBlobSize", biggestObjects.WorstStat)
+		assert.Equal(t, 10.0, biggestObjects.WorstLevelOfConcern)
+		assert.InDelta(t, 30.2, biggestObjects.Score, 0.05)
+	}
+
+	// With only one statistic, its category has all of the weight:
+	h = scan("--stats=maxBlobSize")
+	if assert.Len(t, h.Categories, 1) {
+		assert.Equal(t, 1.0, h.Categories[0].Weight)
+		assert.InDelta(t, 30.2, h.Score, 0.05)
+	}
+
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--health-score", "--stats=maxBlobSize")
+	cmd.Dir = testRepo.Path
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "Repository health score: 100/100")
+}
+
+func TestReflogs(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "reflogs")
+	defer testRepo.Remove(t)
+
+	git := func(args ...string) {
+		t.Helper()
+		cmd := testRepo.AuthoredCommand(t, args...)
+		require.NoError(t, cmd.Run(), "running 'git %s'", strings.Join(args, " "))
+	}
+
+	testRepo.AddFile(t, "README", "Hello, world!\n")
+	git("commit", "-m", "initial")
+
+	// Commit a file on a branch, then delete the branch. The commit
+	// is then only remembered by the reflog of `HEAD`:
+	git("checkout", "-b", "topic")
+	testRepo.AddFile(t, "secret", "Password: hunter2\n")
+	git("commit", "-m", "oops")
+	git("checkout", "master")
+	git("branch", "-D", "topic")
+
+	type reflogOnly struct {
+		ObjectCount          uint64 `json:"object_count"`
+		ExpirableObjectCount uint64 `json:"expirable_object_count"`
+		ExpireAge            uint64 `json:"expire_age"`
+	}
+
+	scan := func(args ...string) reflogOnly {
+		t.Helper()
+		cmd := exec.Command(
+			sizerExe(t),
+			append([]string{"--no-progress", "--json", "--json-version=2", "--reflogs"}, args...)...,
+		)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err)
+		var v struct {
+			ReflogOnly reflogOnly
+		}
+		require.NoError(t, json.Unmarshal(output, &v))
+		return v.ReflogOnly
+	}
+
+	// The commit, its tree, and the new blob are reachable only from
+	// the reflog. The reflog entries were made in 2005, so they are
+	// all older than the expiry age:
+	assert.Equal(t, reflogOnly{3, 3, 30}, scan())
+
+	// Entries newer than the expiry age keep the objects:
+	assert.Equal(t, reflogOnly{3, 0, 100000}, scan("--reflog-expire=100000"))
+}
+
+func TestUnreachable(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "unreachable")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", "Hello, world!\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	// Write two loose objects that nothing refers to, and backdate
+	// one of them:
+	writeBlob := func(contents string) string {
+		t.Helper()
+		cmd := testRepo.GitCommand(t, "hash-object", "-w", "--stdin")
+		cmd.Stdin = strings.NewReader(contents)
+		out, err := cmd.Output()
+		require.NoError(t, err)
+		return strings.TrimSpace(string(out))
+	}
+	writeBlob("fresh\n")
+	old := writeBlob("stale\n")
+	oldTime := time.Date(2005, 4, 7, 22, 13, 13, 0, time.UTC)
+	require.NoError(t, os.Chtimes(
+		filepath.Join(testRepo.Path, ".git", "objects", old[:2], old[2:]),
+		oldTime, oldTime,
+	))
+
+	type simulation struct {
+		Setting     string `json:"setting"`
+		Current     bool   `json:"current"`
+		ObjectCount uint64 `json:"object_count"`
+	}
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2",
+		"--unreachable", "--prune-expire=now,1.year.ago",
+	)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+	var v struct {
+		UnreachableObjects struct {
+			ObjectCount      uint64       `json:"object_count"`
+			PruneExpire      string       `json:"prune_expire"`
+			PruneSimulations []simulation `json:"prune_simulations"`
+		}
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+
+	u := v.UnreachableObjects
+	assert.EqualValues(t, 2, u.ObjectCount)
+	assert.Equal(t, "2.weeks.ago", u.PruneExpire)
+	assert.Equal(
+		t,
+		[]simulation{
+			{"2.weeks.ago", true, 1},
+			{"now", false, 2},
+			{"1.year.ago", false, 1},
+		},
+		u.PruneSimulations,
+	)
+}
+
+func TestAgeBuckets(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "age-buckets")
+	defer testRepo.Remove(t)
+
+	testRepo.SetTimestamp(time.Date(2005, 4, 7, 22, 13, 13, 0, time.UTC))
+	testRepo.AddFile(t, "dir/a.txt", "old\n")
+	testRepo.Commit(t, "-m", "old")
+
+	// The new commit adds a blob, and a copy of the old blob, which
+	// still counts as old:
+	testRepo.SetTimestamp(time.Date(2015, 6, 1, 12, 0, 0, 0, time.UTC))
+	testRepo.AddFile(t, "dir/b.txt", "newer\n")
+	testRepo.AddFile(t, "c.txt", "old\n")
+	testRepo.Commit(t, "-m", "new")
+
+	// An annotated tag isn't contained in any commit:
+	cmd := testRepo.AuthoredCommand(t, "tag", "-a", "-m", "tag", "v1")
+	require.NoError(t, cmd.Run(), "creating tag")
+
+	type bucket struct {
+		Year        int    `json:"year"`
+		ObjectCount uint64 `json:"object_count"`
+		BlobCount   uint64 `json:"blob_count"`
+		BlobSize    uint64 `json:"blob_size"`
+	}
+	var output struct {
+		AgeBuckets []bucket `json:"ageBuckets"`
+	}
+
+	cmd = exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2", "--age-buckets",
+	)
+	cmd.Dir = testRepo.Path
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(out, &output))
+
+	assert.Equal(
+		t,
+		[]bucket{
+			// A commit, two trees, and a blob each:
+			{Year: 2005, ObjectCount: 4, BlobCount: 1, BlobSize: 4},
+			{Year: 2015, ObjectCount: 4, BlobCount: 1, BlobSize: 6},
+			{Year: 0, ObjectCount: 1},
+		},
+		output.AgeBuckets,
+	)
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--age-buckets")
+	cmd.Dir = testRepo.Path
+	out, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "Unique objects by the year of the earliest commit")
+	assert.Contains(t, string(out), "| undated |")
+}
+
+func TestRefGroupActivity(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "refgroup-activity")
+	defer testRepo.Remove(t)
+
+	commit := func(message string, timestamp time.Time) {
+		t.Helper()
+		testRepo.AddFile(t, message+".txt", message+"\n")
+		testRepo.SetTimestamp(timestamp)
+		testRepo.Commit(t, "-m", message)
+	}
+
+	commit("first", time.Date(2005, 4, 7, 22, 13, 13, 0, time.UTC))
+	commit("second", time.Date(2005, 11, 1, 12, 0, 0, 0, time.UTC))
+	require.NoError(t, testRepo.GitCommand(t, "tag", "v1").Run(), "creating tag")
+	commit("third", time.Date(2007, 2, 1, 12, 0, 0, 0, time.UTC))
+
+	type bucket struct {
+		Period      string `json:"period"`
+		CommitCount uint64 `json:"commit_count"`
+	}
+	type groupActivity struct {
+		RefGroup       string    `json:"refgroup"`
+		CommitCount    uint64    `json:"commit_count"`
+		LastCommitTime time.Time `json:"last_commit_time"`
+		Buckets        []bucket  `json:"buckets"`
+	}
+	run := func(period string) (string, []groupActivity) {
+		t.Helper()
+		var output struct {
+			RefGroupActivity struct {
+				Period    string          `json:"period"`
+				RefGroups []groupActivity `json:"ref_groups"`
+			} `json:"refgroupActivity"`
+		}
+
+		cmd := exec.Command(
+			sizerExe(t), "--no-progress", "--json", "--json-version=2",
+			"--refgroup-activity="+period,
+		)
+		cmd.Dir = testRepo.Path
+		out, err := cmd.Output()
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(out, &output))
+		return output.RefGroupActivity.Period, output.RefGroupActivity.RefGroups
+	}
+
+	period, groups := run("year")
+	assert.Equal(t, "year", period)
+	assert.Equal(
+		t,
+		[]groupActivity{
+			{
+				RefGroup:       "branches",
+				CommitCount:    3,
+				LastCommitTime: time.Date(2007, 2, 1, 12, 0, 0, 0, time.UTC),
+				Buckets: []bucket{
+					{Period: "2005", CommitCount: 2},
+					{Period: "2007", CommitCount: 1},
+				},
+			},
+			{
+				RefGroup:       "tags",
+				CommitCount:    2,
+				LastCommitTime: time.Date(2005, 11, 1, 12, 0, 0, 0, time.UTC),
+				Buckets: []bucket{
+					{Period: "2005", CommitCount: 2},
+				},
+			},
+		},
+		groups,
+	)
+
+	_, groups = run("month")
+	require.Len(t, groups, 2)
+	assert.Equal(
+		t,
+		[]bucket{
+			{Period: "2005-04", CommitCount: 1},
+			{Period: "2005-11", CommitCount: 1},
+			{Period: "2007-02", CommitCount: 1},
+		},
+		groups[0].Buckets,
+	)
+
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--refgroup-activity=year")
+	cmd.Dir = testRepo.Path
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "Commits per year, by refgroup:")
+	assert.Contains(t, string(out), "Activity (2005 to 2007)")
+	assert.Contains(t, string(out), "| branches         |     3     | 2007-02-01  | █.▄\n")
+	assert.Contains(t, string(out), "| tags             |     2     | 2005-11-01  | █..\n")
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--refgroup-activity=week")
+	cmd.Dir = testRepo.Path
+	assert.Error(t, cmd.Run())
+}
+
+func TestRefChurn(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "ref-churn")
+	defer testRepo.Remove(t)
+
+	// An update long ago, which is outside of the period:
+	testRepo.AddFile(t, "README", "Hello\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	testRepo.SetTimestamp(time.Now().Add(-24 * time.Hour))
+	testRepo.Commit(t, "--allow-empty", "-m", "recent")
+
+	require.NoError(t, testRepo.GitCommand(t, "checkout", "-b", "ci-status").Run(), "creating branch")
+	for i := 0; i < 3; i++ {
+		testRepo.Commit(t, "--allow-empty", "-m", "status")
+	}
+
+	type refUpdates struct {
+		Refname       string  `json:"refname"`
+		UpdateCount   uint64  `json:"update_count"`
+		UpdatesPerDay float64 `json:"updates_per_day"`
+	}
+	var output struct {
+		RefChurn struct {
+			Days        uint32       `json:"days"`
+			UpdateCount uint64       `json:"update_count"`
+			RefCount    uint32       `json:"ref_count"`
+			TopRefs     []refUpdates `json:"top_refs"`
+		} `json:"refChurn"`
+	}
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2", "--ref-churn=2",
+	)
+	cmd.Dir = testRepo.Path
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(out, &output))
+	assert.Equal(t, uint32(2), output.RefChurn.Days)
+	assert.Equal(t, uint64(5), output.RefChurn.UpdateCount)
+	assert.Equal(t, uint32(2), output.RefChurn.RefCount)
+	assert.Equal(
+		t,
+		[]refUpdates{
+			{Refname: "refs/heads/ci-status", UpdateCount: 4, UpdatesPerDay: 2},
+			{Refname: "refs/heads/master", UpdateCount: 1, UpdatesPerDay: 0.5},
+		},
+		output.RefChurn.TopRefs,
+	)
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--ref-churn=2")
+	cmd.Dir = testRepo.Path
+	out, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "\nReference updates in the last 2 days, according to the reflogs:\n")
+	assert.Contains(t, string(out), "| refs/heads/ci-status                     |         4 |       2.0 | ")
+	assert.Contains(t, string(out), "\n5 updates to 2 references in total.\n")
+}
+
+func TestRecentActivity(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "recent-activity")
+	defer testRepo.Remove(t)
+
+	for i := 1; i <= 3; i++ {
+		testRepo.AddFile(t, fmt.Sprintf("file-%d.txt", i), strings.Repeat("x", 100*i))
+		testRepo.Commit(t, "-m", fmt.Sprintf("commit %d", i))
+	}
+
+	type typeTotals struct {
+		Count uint32 `json:"count"`
+		Size  uint64 `json:"size"`
+	}
+	type recentActivity struct {
+		Branch      string     `json:"branch"`
+		CommitLimit uint32     `json:"commit_limit"`
+		Base        string     `json:"base"`
+		Commits     typeTotals `json:"commits"`
+		Trees       typeTotals `json:"trees"`
+		Blobs       typeTotals `json:"blobs"`
+	}
+	scan := func(n int) recentActivity {
+		var output struct {
+			RecentActivity recentActivity `json:"recentActivity"`
+		}
+		cmd := exec.Command(
+			sizerExe(t), "--no-progress", "--json", "--json-version=2",
+			fmt.Sprintf("--recent-commits=%d", n),
+		)
+		cmd.Dir = testRepo.Path
+		out, err := cmd.Output()
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(out, &output))
+		return output.RecentActivity
+	}
+
+	cmd := testRepo.GitCommand(t, "rev-parse", "HEAD~2")
+	out, err := cmd.Output()
+	require.NoError(t, err)
+
+	ra := scan(2)
+	assert.Equal(t, "refs/heads/master", ra.Branch)
+	assert.Equal(t, uint32(2), ra.CommitLimit)
+	assert.Equal(t, strings.TrimSpace(string(out)), ra.Base)
+	assert.Equal(t, uint32(2), ra.Commits.Count)
+	assert.Equal(t, uint32(2), ra.Trees.Count)
+	assert.Equal(t, typeTotals{Count: 2, Size: 500}, ra.Blobs)
+
+	// With fewer commits than requested, everything is counted:
+	ra = scan(5)
+	assert.Equal(t, "", ra.Base)
+	assert.Equal(t, uint32(3), ra.Commits.Count)
+	assert.Equal(t, typeTotals{Count: 3, Size: 600}, ra.Blobs)
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--recent-commits=2")
+	cmd.Dir = testRepo.Path
+	out, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(
+		t, string(out),
+		"\nRecent activity (objects introduced by the last 2 commits on refs/heads/master):\n",
+	)
+	assert.Contains(t, string(out), "| Blobs            |         2 |    500 B   |")
+}
+
+func TestExactCheckout(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "exact-checkout")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "a/file.txt", "Hello, world!\n")
+	testRepo.AddFile(t, "b/file.txt", "Hello, world!\n")
+	testRepo.AddFile(t, "other.txt", "Goodbye\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	type exactCheckout struct {
+		ExpandedBlobCount uint32 `json:"expanded_blob_count"`
+		ExpandedBlobSize  uint64 `json:"expanded_blob_size"`
+		ExpansionLimited  bool   `json:"expansion_limited"`
+		PathCount         uint64 `json:"path_count"`
+		PathSize          uint64 `json:"path_size"`
+		DistinctBlobCount uint64 `json:"distinct_blob_count"`
+		DistinctBlobSize  uint64 `json:"distinct_blob_size"`
+	}
+	scan := func(args ...string) exactCheckout {
+		var output struct {
+			ExactCheckout exactCheckout `json:"exactCheckout"`
+		}
+		cmd := exec.Command(
+			sizerExe(t),
+			append(
+				[]string{"--no-progress", "--json", "--json-version=2", "--exact-checkout"},
+				args...,
+			)...,
+		)
+		cmd.Dir = testRepo.Path
+		out, err := cmd.Output()
+		require.NoError(t, err)
+		require.NoError(t, json.Unmarshal(out, &output))
+		return output.ExactCheckout
+	}
+
+	assert.Equal(
+		t,
+		exactCheckout{
+			ExpandedBlobCount: 3,
+			ExpandedBlobSize:  36,
+			PathCount:         3,
+			PathSize:          36,
+			DistinctBlobCount: 2,
+			DistinctBlobSize:  22,
+		},
+		scan(),
+	)
+
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--exact-checkout")
+	cmd.Dir = testRepo.Path
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "\nFiles in the checkout with the most blobs (refs/heads/master^{tree}):\n")
+	assert.Contains(t, string(out), "| Distinct blobs             |     2     |    22 B  \n")
+
+	// The exact counts don't saturate if the expansion of a git bomb
+	// is limited. The cited tree is the one whose expansion was cut
+	// short, "d0", whose checkout has 100 files:
+	bombRepo := testutils.NewTestRepo(t, true, "exact-checkout-bomb")
+	defer bombRepo.Remove(t)
+	newGitBomb(t, bombRepo, 3, 10, "boom!\n")
+	testRepo = bombRepo
+
+	assert.Equal(
+		t,
+		exactCheckout{
+			ExpandedBlobCount: math.MaxUint32,
+			ExpandedBlobSize:  math.MaxUint64,
+			ExpansionLimited:  true,
+			PathCount:         100,
+			PathSize:          600,
+			DistinctBlobCount: 1,
+			DistinctBlobSize:  6,
+		},
+		scan("--max-expanded-entries=100"),
+	)
+}
+
+func TestHardLimits(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "hard-limits")
+	defer testRepo.Remove(t)
+
+	// A 200-byte name approaches NAME_MAX, and the 230-byte path to
+	// it approaches Windows' MAX_PATH:
+	dir := strings.Repeat("d", 29)
+	name := strings.Repeat("n", 200)
+	testRepo.AddFile(t, dir+"/"+name, "Hello, world!\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	type finding struct {
+		Limit    string `json:"limit"`
+		Value    uint64 `json:"value"`
+		Max      uint64 `json:"max"`
+		Exceeded bool   `json:"exceeded"`
+		Object   string `json:"object"`
+	}
+	var output struct {
+		HardLimits []finding `json:"hardLimits"`
+	}
+
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2")
+	cmd.Dir = testRepo.Path
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(out, &output))
+	require.Len(t, output.HardLimits, 2)
+	assert.Equal(t, "Entry name length (NAME_MAX)", output.HardLimits[0].Limit)
+	assert.Equal(t, uint64(200), output.HardLimits[0].Value)
+	assert.Equal(t, uint64(255), output.HardLimits[0].Max)
+	assert.False(t, output.HardLimits[0].Exceeded)
+	assert.Contains(t, output.HardLimits[0].Object, "(refs/heads/master:"+dir+"/"+name+")")
+	assert.Equal(t, "Path length (Windows MAX_PATH)", output.HardLimits[1].Limit)
+	assert.Equal(t, uint64(230), output.HardLimits[1].Value)
+	assert.False(t, output.HardLimits[1].Exceeded)
+
+	// A longer directory name makes the path exceed MAX_PATH:
+	cmd = testRepo.GitCommand(t, "mv", dir, strings.Repeat("d", 60))
+	require.NoError(t, cmd.Run(), "renaming directory")
+	testRepo.Commit(t, "-m", "rename")
+
+	cmd = exec.Command(sizerExe(t), "--no-progress")
+	cmd.Dir = testRepo.Path
+	out, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "\nHard limits:\n")
+	assert.Contains(t, string(out), "| Entry name length (NAME_MAX)             |   200     |   255     | approaches\n")
+	assert.Contains(t, string(out), "| Path length (Windows MAX_PATH)           |   261     |   260     | EXCEEDS\n")
+}
+
+func TestRefGroupEmptyCommits(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "refgroup-empty-commits")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", "Hello\n")
+	testRepo.Commit(t, "-m", "initial")
+	require.NoError(t, testRepo.GitCommand(t, "tag", "v1").Run(), "creating tag")
+
+	for i := 0; i < 2; i++ {
+		cmd := testRepo.AuthoredCommand(t, "commit", "--allow-empty", "-m", "bump")
+		require.NoError(t, cmd.Run(), "creating empty commit")
+	}
+
+	type groupEmptyCommits struct {
+		RefGroup         string `json:"refgroup"`
+		CommitCount      uint64 `json:"commit_count"`
+		EmptyCommitCount uint64 `json:"empty_commit_count"`
+		EmptyMergeCount  uint64 `json:"empty_merge_count"`
+	}
+	var output struct {
+		RefGroupEmptyCommits []groupEmptyCommits `json:"refgroupEmptyCommits"`
+	}
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2",
+		"--empty-commits-by-refgroup",
+	)
+	cmd.Dir = testRepo.Path
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(out, &output))
+	assert.Equal(
+		t,
+		[]groupEmptyCommits{
+			{RefGroup: "branches", CommitCount: 3, EmptyCommitCount: 2},
+			{RefGroup: "tags", CommitCount: 1},
+		},
+		output.RefGroupEmptyCommits,
+	)
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--empty-commits-by-refgroup")
+	cmd.Dir = testRepo.Path
+	out, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "\nEmpty commits, by refgroup:\n")
+	assert.Contains(
+		t, string(out),
+		"| branches         |     3     |     2     |     0       | 66.7%\n",
+	)
+}
+
+func TestRecentBlobs(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "recent-blobs")
+	defer testRepo.Remove(t)
+
+	commit := func(message string, timestamp time.Time) {
+		t.Helper()
+		testRepo.SetTimestamp(timestamp)
+		testRepo.Commit(t, "-m", message)
+	}
+
+	// The old blob is the largest, but it was added too long ago:
+	testRepo.AddFile(t, "old.bin", strings.Repeat("o", 5000))
+	commit("old", time.Date(2005, 4, 7, 22, 13, 13, 0, time.UTC))
+
+	recently := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, testRepo.GitCommand(t, "branch", "feature").Run())
+	testRepo.AddFile(t, "big dir/huge file.bin", strings.Repeat("h", 3000))
+	testRepo.AddFile(t, "small.txt", "small\n")
+	commit("recent on master", recently)
+	require.NoError(t, testRepo.GitCommand(t, "tag", "v1").Run())
+
+	require.NoError(t, testRepo.GitCommand(t, "checkout", "-q", "feature").Run())
+	testRepo.AddFile(t, "feature.bin", strings.Repeat("f", 8000))
+	commit("recent on feature", recently)
+
+	featureBlob, err := testRepo.GitCommand(t, "rev-parse", "feature:feature.bin").Output()
+	require.NoError(t, err)
+	featureCommit, err := testRepo.GitCommand(t, "rev-parse", "feature").Output()
+	require.NoError(t, err)
+
+	type recentBlob struct {
+		RefGroup    string `json:"refgroup"`
+		BlobCount   uint64 `json:"blob_count"`
+		BlobSize    uint64 `json:"blob_size"`
+		MaxBlob     string `json:"max_blob"`
+		MaxBlobSize uint64 `json:"max_blob_size"`
+		Commit      string `json:"commit"`
+		Path        string `json:"path"`
+	}
+	var output struct {
+		RecentBlobs struct {
+			Since     time.Time    `json:"since"`
+			RefGroups []recentBlob `json:"refgroups"`
+		} `json:"recentBlobs"`
+	}
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2", "--recent-blobs=7",
+	)
+	cmd.Dir = testRepo.Path
+	j, err := cmd.Output()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(j, &output))
+
+	assert.WithinDuration(t, time.Now().Add(-7*24*time.Hour), output.RecentBlobs.Since, time.Hour)
+	if assert.Len(t, output.RecentBlobs.RefGroups, 2) {
+		branches := output.RecentBlobs.RefGroups[0]
+		assert.Equal(t, "branches", branches.RefGroup)
+		assert.EqualValues(t, 3, branches.BlobCount)
+		assert.EqualValues(t, 8000+3000+6, branches.BlobSize)
+		assert.Equal(t, strings.TrimSpace(string(featureBlob)), branches.MaxBlob)
+		assert.EqualValues(t, 8000, branches.MaxBlobSize)
+		assert.Equal(t, strings.TrimSpace(string(featureCommit)), branches.Commit)
+		assert.Equal(t, "feature.bin", branches.Path)
+
+		tags := output.RecentBlobs.RefGroups[1]
+		assert.Equal(t, "tags", tags.RefGroup)
+		assert.EqualValues(t, 2, tags.BlobCount)
+		assert.EqualValues(t, 3000, tags.MaxBlobSize)
+		assert.Equal(t, "big dir/huge file.bin", tags.Path)
+	}
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--recent-blobs=7")
+	cmd.Dir = testRepo.Path
+	j, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(j), "Largest blobs added to each refgroup since")
+	assert.Contains(t, string(j), ":big dir/huge file.bin)")
+
+	// Nothing was added within the last day:
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--recent-blobs=1")
+	cmd.Dir = testRepo.Path
+	j, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(j), "No blobs were added to any refgroup.")
+}
+
+func TestScanPush(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "scan-push")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "old.txt", "old\n")
+	testRepo.Commit(t, "-m", "initial")
+	out, err := testRepo.GitCommand(t, "rev-parse", "HEAD").Output()
+	require.NoError(t, err)
+	master, err := git.NewOID(strings.TrimSpace(string(out)))
+	require.NoError(t, err)
+
+	// Write the pushed objects to a quarantine directory, as
+	// `receive-pack` does:
+	objects := filepath.Join(testRepo.Path, ".git", "objects")
+	quarantine := filepath.Join(objects, "incoming-test")
+	require.NoError(t, os.Mkdir(quarantine, 0o777))
+	quarantined := func(stdin string, args ...string) git.OID {
+		t.Helper()
+		cmd := testRepo.AuthoredCommand(t, args...)
+		cmd.Env = append(
+			cmd.Env,
+			"GIT_OBJECT_DIRECTORY="+quarantine,
+			"GIT_ALTERNATE_OBJECT_DIRECTORIES="+objects,
+		)
+		cmd.Stdin = strings.NewReader(stdin)
+		out, err := cmd.Output()
+		require.NoError(t, err, "running 'git %s'", strings.Join(args, " "))
+		oid, err := git.NewOID(strings.TrimSpace(string(out)))
+		require.NoError(t, err)
+		return oid
+	}
+
+	blob := quarantined("new\n", "hash-object", "-w", "--stdin")
+	tree := &bytes.Buffer{}
+	for i := 0; i < 3000; i++ {
+		fmt.Fprintf(tree, "100644 blob %s\tfile-%04d\n", blob, i)
+	}
+	wideTree := quarantined(tree.String(), "mktree")
+	wide := quarantined("", "commit-tree", "-p", master.String(), "-m", "wide", wideTree.String())
+	// This tree refers to a tree and a blob that were already on
+	// `master`, which are at the boundary of the scan:
+	out, err = testRepo.GitCommand(t, "rev-parse", "HEAD^{tree}", "HEAD:old.txt").Output()
+	require.NoError(t, err)
+	existing := strings.Fields(string(out))
+	require.Len(t, existing, 2)
+	smallTree := quarantined(
+		fmt.Sprintf(
+			"100644 blob %s\tnew.txt\n040000 tree %s\told\n100644 blob %s\told.txt\n",
+			blob, existing[0], existing[1],
+		),
+		"mktree",
+	)
+	small := quarantined("", "commit-tree", "-p", master.String(), "-m", "small", smallTree.String())
+
+	// The objects aren't visible outside of the quarantine:
+	require.Error(t, testRepo.GitCommand(t, "cat-file", "-e", wide.String()).Run())
+
+	updates, err := sizes.ParseRefUpdates(strings.NewReader(fmt.Sprintf(
+		"%s %s refs/heads/wide\n%s %s refs/heads/master\n%s %s refs/heads/gone\n",
+		git.NullOID, wide, master, small, master, git.NullOID,
+	)))
+	require.NoError(t, err)
+	require.Len(t, updates, 3)
+	assert.Equal(t, "refs/heads/master", updates[1].Refname)
+	assert.True(t, updates[2].Deletion())
+
+	_, err = sizes.ParseRefUpdates(strings.NewReader("bogus refs/heads/master\n"))
+	assert.Error(t, err)
+
+	ctx := context.Background()
+	result, err := sizes.ScanPush(
+		ctx, testRepo.Path, quarantine, updates,
+		sizes.PushOptions{NameStyle: sizes.NameStyleFull, RejectThreshold: 2},
+	)
+	require.NoError(t, err)
+
+	// The objects that were already on `master` aren't counted:
+	assert.Equal(t, counts.Count32(2), result.Size.UniqueCommitCount)
+	assert.Equal(t, counts.Count32(2), result.Size.UniqueTreeCount)
+	assert.Equal(t, counts.Count32(1), result.Size.UniqueBlobCount)
+	assert.True(t, result.Rejected())
+
+	require.Len(t, result.Verdicts, 3)
+
+	v := result.Verdicts[0]
+	assert.True(t, v.Rejected)
+	var concern *sizes.Concern
+	for i := range v.Concerns {
+		if v.Concerns[i].Symbol == "maxTreeEntries" {
+			concern = &v.Concerns[i]
+		}
+	}
+	if assert.NotNil(t, concern) {
+		assert.Equal(t, "Biggest objects > Trees > Maximum entries", concern.Name)
+		assert.EqualValues(t, 3000, concern.Value)
+		assert.InDelta(t, 3.0, concern.LevelOfConcern, 0.001)
+		if assert.NotNil(t, concern.Object) {
+			assert.Equal(t, wideTree, concern.Object.OID)
+			assert.Equal(t, "refs/heads/wide^{tree}", concern.Object.Path())
+		}
+	}
+
+	// The wide tree is only blamed on the update that adds it:
+	v = result.Verdicts[1]
+	assert.False(t, v.Rejected)
+	assert.Empty(t, v.Concerns)
+
+	v = result.Verdicts[2]
+	assert.False(t, v.Rejected)
+	assert.Empty(t, v.Concerns)
+
+	// A push that only adds `small` is scanned without walking
+	// `master`'s history or reading its trees:
+	result, err = sizes.ScanPush(
+		ctx, testRepo.Path, quarantine, updates[1:2],
+		sizes.PushOptions{NameStyle: sizes.NameStyleFull, RejectThreshold: 2},
+	)
+	require.NoError(t, err)
+	assert.False(t, result.Rejected())
+	assert.Equal(t, counts.Count32(1), result.Size.UniqueCommitCount)
+	assert.Equal(t, counts.Count32(1), result.Size.UniqueTreeCount)
+	assert.Equal(t, counts.Count32(1), result.Size.UniqueBlobCount)
+	assert.Equal(t, counts.Count32(1), result.Size.MaxHistoryDepth)
+	assert.Equal(t, counts.Count32(2), result.Size.MaxExpandedBlobCount)
+}
+
+func TestHead(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "head")
+	defer testRepo.Remove(t)
+
+	testRepo.Commit(t, "--allow-empty", "-m", "on master")
+
+	// Commit on a detached HEAD, so that no reference leads to the
+	// new commit:
+	require.NoError(t, testRepo.GitCommand(t, "checkout", "-q", "--detach").Run())
+	cmd := testRepo.AuthoredCommand(t, "commit", "--allow-empty", "-m", "detached")
+	require.NoError(t, cmd.Run(), "creating detached commit")
+
+	out, err := testRepo.GitCommand(t, "rev-parse", "HEAD").Output()
+	require.NoError(t, err)
+	headOID := strings.TrimSpace(string(out))
+
+	type output struct {
+		UniqueCommitCount struct {
+			Value int
+		}
+		ScanScope []struct {
+			Name string
+			OID  string
+		}
+		Head *struct {
+			Target string
+			OID    string
+		}
+	}
+
+	scan := func(args ...string) output {
+		t.Helper()
+		cmd := exec.Command(
+			sizerExe(t), append([]string{"--no-progress", "--json", "--json-version=2"}, args...)...,
+		)
+		cmd.Dir = testRepo.Path
+		out, err := cmd.Output()
+		require.NoError(t, err)
+		var v output
+		require.NoError(t, json.Unmarshal(out, &v))
+		return v
+	}
+
+	v := scan()
+	assert.Equal(t, 1, v.UniqueCommitCount.Value)
+	assert.Nil(t, v.Head)
+
+	v = scan("--head")
+	assert.Equal(t, 2, v.UniqueCommitCount.Value)
+	if assert.Len(t, v.ScanScope, 2) {
+		assert.Equal(t, "HEAD", v.ScanScope[1].Name)
+		assert.Equal(t, headOID, v.ScanScope[1].OID)
+	}
+	if assert.NotNil(t, v.Head) {
+		assert.Equal(t, "", v.Head.Target)
+		assert.Equal(t, headOID, v.Head.OID)
+	}
+
+	testRepo.ConfigAdd(t, "sizer.head", "true")
+	assert.Equal(t, 2, scan().UniqueCommitCount.Value)
+	assert.Equal(t, 1, scan("--no-head").UniqueCommitCount.Value)
+
+	// An unborn HEAD is reported, but not scanned:
+	require.NoError(t, testRepo.GitCommand(t, "checkout", "-q", "--orphan", "new").Run())
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--head")
+	cmd.Dir = testRepo.Path
+	out, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(
+		t, string(out),
+		"HEAD points at refs/heads/new, which doesn't exist yet, so it wasn't scanned.",
+	)
+}
+
+func TestEmptyRepository(t *testing.T) {
+	t.Parallel()
+
+	for _, bare := range []bool{false, true} {
+		bare := bare
+		t.Run(fmt.Sprintf("bare=%t", bare), func(t *testing.T) {
+			t.Parallel()
+
+			testRepo := testutils.NewTestRepo(t, bare, "empty")
+			defer testRepo.Remove(t)
+
+			cmd := exec.Command(sizerExe(t), "--no-progress", "--head")
+			cmd.Dir = testRepo.Path
+			out, err := cmd.Output()
+			require.NoError(t, err)
+			assert.Contains(t, string(out), "No problems above the current threshold were found")
+			assert.Contains(t, string(out), "The repository is empty")
+
+			cmd = exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=1")
+			cmd.Dir = testRepo.Path
+			out, err = cmd.Output()
+			require.NoError(t, err)
+			var v1 struct {
+				EmptyRepository   bool   `json:"empty_repository"`
+				UniqueCommitCount uint64 `json:"unique_commit_count"`
+				UniqueBlobCount   uint64 `json:"unique_blob_count"`
+			}
+			require.NoError(t, json.Unmarshal(out, &v1))
+			assert.True(t, v1.EmptyRepository)
+			assert.Equal(t, uint64(0), v1.UniqueCommitCount)
+			assert.Equal(t, uint64(0), v1.UniqueBlobCount)
+
+			cmd = exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2")
+			cmd.Dir = testRepo.Path
+			out, err = cmd.Output()
+			require.NoError(t, err)
+			var v2 struct {
+				EmptyRepository   bool
+				UniqueCommitCount struct {
+					Value uint64
+				}
+			}
+			require.NoError(t, json.Unmarshal(out, &v2))
+			assert.True(t, v2.EmptyRepository)
+			assert.Equal(t, uint64(0), v2.UniqueCommitCount.Value)
+		})
+	}
+
+	// A repository with an unborn `HEAD` but some references isn't
+	// empty:
+	testRepo := testutils.NewTestRepo(t, false, "not-empty")
+	defer testRepo.Remove(t)
+
+	testRepo.Commit(t, "--allow-empty", "-m", "initial")
+	require.NoError(t, testRepo.GitCommand(t, "checkout", "-q", "--orphan", "new").Run())
+
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2")
+	cmd.Dir = testRepo.Path
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	var v struct {
+		EmptyRepository   bool
+		UniqueCommitCount struct {
+			Value uint64
+		}
+	}
+	require.NoError(t, json.Unmarshal(out, &v))
+	assert.False(t, v.EmptyRepository)
+	assert.Equal(t, uint64(1), v.UniqueCommitCount.Value)
+}
+
+func TestGitHubRepo(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "github-repo")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", "Hello, world!\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/repos/octo/public":
+			fmt.Fprintln(w, `{"full_name": "octo/public", "size": 100}`)
+		case r.URL.Path == "/repos/octo/private" && r.Header.Get("Authorization") == "Bearer s3cret":
+			fmt.Fprintln(w, `{"full_name": "octo/private", "size": 2048}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	run := func(env []string, args ...string) ([]byte, error) {
+		cmd := exec.Command(
+			sizerExe(t),
+			append([]string{"--no-progress", "--github-api-url=" + server.URL}, args...)...,
+		)
+		cmd.Dir = testRepo.Path
+		cmd.Env = append(os.Environ(), "GITHUB_TOKEN=", "GH_TOKEN=")
+		cmd.Env = append(cmd.Env, env...)
+		return cmd.Output()
+	}
+
+	out, err := run(nil, "--github-repo=octo/public")
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "Comparison with the size of GitHub's copy of octo/public:")
+	assert.Contains(t, string(out), "| Disk usage reported by GitHub")
+	assert.Contains(t, string(out), "  100 KiB")
+
+	var v struct {
+		HostedSize struct {
+			Host       string
+			Repository string
+			DiskSize   uint64 `json:"disk_size"`
+		}
+	}
+	out, err = run(
+		[]string{"GH_TOKEN=s3cret"}, "--github-repo=octo/private", "--json", "--json-version=2",
+	)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(out, &v))
+	assert.Equal(t, "GitHub", v.HostedSize.Host)
+	assert.Equal(t, "octo/private", v.HostedSize.Repository)
+	assert.EqualValues(t, 2048*1024, v.HostedSize.DiskSize)
+
+	// Without the token, the private repository can't be found:
+	_, err = run(nil, "--github-repo=octo/private")
+	assert.Error(t, err)
+
+	_, err = run(nil, "--github-repo=octo")
+	assert.Error(t, err)
+}
+
+func TestODBTotals(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "odb-totals")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", "Hello, world!\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	// An unreachable blob is counted, too:
+	cmd := testRepo.GitCommand(t, "hash-object", "-w", "--stdin")
+	cmd.Stdin = strings.NewReader("unreachable\n")
+	require.NoError(t, cmd.Run(), "writing blob")
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--odb-totals", "--json")
+	cmd.Dir = testRepo.Path
+	out, err := cmd.Output()
+	require.NoError(t, err)
+
+	type typeTotals struct {
+		Count uint64 `json:"count"`
+		Size  uint64 `json:"size"`
+	}
+	var totals struct {
+		Blobs   typeTotals `json:"blobs"`
+		Trees   typeTotals `json:"trees"`
+		Commits typeTotals `json:"commits"`
+		Tags    typeTotals `json:"tags"`
+	}
+	require.NoError(t, json.Unmarshal(out, &totals))
+	assert.Equal(t, typeTotals{2, 26}, totals.Blobs)
+	assert.EqualValues(t, 1, totals.Trees.Count)
+	assert.EqualValues(t, 1, totals.Commits.Count)
+	assert.Equal(t, typeTotals{}, totals.Tags)
+}
+
+func TestExplainStats(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "explain-stats")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", "Hello, world!\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	run := func(args ...string) []byte {
+		t.Helper()
+		cmd := exec.Command(sizerExe(t), append([]string{"--no-progress"}, args...)...)
+		cmd.Dir = testRepo.Path
+		out, err := cmd.Output()
+		require.NoError(t, err)
+		return out
+	}
+
+	var definitions struct {
+		Definitions []struct {
+			Symbol         string
+			Name           string
+			Computation    string
+			ReferenceValue float64
+			CounterBits    int
+			Explicit       bool
+		}
+	}
+	require.NoError(t, json.Unmarshal(run("--explain-stats", "--json"), &definitions))
+
+	defined := make(map[string]bool)
+	for _, def := range definitions.Definitions {
+		assert.NotEmpty(t, def.Computation, def.Symbol)
+		defined[def.Symbol] = true
+	}
+	assert.True(t, defined["graphMemory"])
+	assert.True(t, defined["gitCapabilities"])
+
+	// Every statistic in the output of a scan is defined:
+	var output map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(run("--json", "--json-version=2", "-v"), &output))
+	var checked int
+	for symbol, raw := range output {
+		// Skip the other sections (e.g., "scanScope"):
+		var stat struct {
+			ReferenceValue *float64
+		}
+		if json.Unmarshal(raw, &stat) != nil || stat.ReferenceValue == nil {
+			continue
+		}
+		assert.True(t, defined[symbol], "statistic %s is not defined", symbol)
+		checked++
+	}
+	assert.Greater(t, checked, 50)
+
+	require.NoError(t, json.Unmarshal(
+		run("--explain-stats", "--json", "--stats=maxCheckoutBlobSize", "--profile=monorepo"),
+		&definitions,
+	))
+	if assert.Len(t, definitions.Definitions, 1) {
+		def := definitions.Definitions[0]
+		assert.Equal(t, "maxCheckoutBlobSize", def.Symbol)
+		assert.Equal(t, "Total size of files", def.Name)
+		assert.Contains(t, def.Computation, "not a total over the history")
+		assert.Equal(t, 10e9, def.ReferenceValue)
+		assert.Equal(t, 64, def.CounterBits)
+	}
+
+	text := string(run("--explain-stats", "--stats=uniqueBlobCount"))
+	assert.Contains(t, text, "uniqueBlobCount\n    Row:         Overall repository size > Blobs > Count\n")
+	assert.Contains(t, text, "32-bit counter")
+}
+
+func TestSHA256Repository(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "sha256-repository")
+	defer testRepo.Remove(t)
+
+	require.NoError(t, os.RemoveAll(filepath.Join(testRepo.Path, ".git")))
+	if err := testRepo.GitCommand(t, "init", "--object-format=sha256").Run(); err != nil {
+		t.Skip("this version of Git can't create SHA-256 repositories")
+	}
+	testRepo.AddFile(t, "README", "Hello\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	// Rather than failing somewhere in the middle of the scan, the
+	// repository is rejected up front, with an explanation:
+	cmd := exec.Command(sizerExe(t), "--no-progress")
+	cmd.Dir = testRepo.Path
+	output, err := cmd.CombinedOutput()
+	assert.Error(t, err)
+	assert.Contains(t, string(output), "uses the 'sha256' object format, but git-sizer only supports 'sha1'")
+}
+
+func TestGraphMemory(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "graph-memory")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", "Hello, world!\n")
+	testRepo.AddFile(t, "dir/file", "Hello, again!\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	run := func(args ...string) map[string]json.RawMessage {
+		t.Helper()
+		args = append([]string{"--no-progress", "--json", "--json-version=2"}, args...)
+		cmd := exec.Command(sizerExe(t), args...)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err)
+		var v map[string]json.RawMessage
+		require.NoError(t, json.Unmarshal(output, &v))
+		return v
+	}
+
+	// The memory statistics are only reported on request:
+	v := run()
+	assert.NotContains(t, v, "graphMemory")
+
+	v = run("--stats=graphMemory,graphBlobMemory,graphTreeMemory")
+	assert.Len(t, v, 5) // including "scanScope" and "effectiveConfig"
+
+	value := func(symbol string) uint64 {
+		t.Helper()
+		var stat struct {
+			Value uint64
+		}
+		require.NoError(t, json.Unmarshal(v[symbol], &stat))
+		return stat.Value
+	}
+	assert.NotZero(t, value("graphBlobMemory"))
+	assert.NotZero(t, value("graphTreeMemory"))
+	assert.GreaterOrEqual(t, value("graphMemory"), value("graphBlobMemory")+value("graphTreeMemory"))
+}
+
+func TestTUI(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "tui")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", "Hello, world!\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	run := func(args ...string) (string, string) {
+		t.Helper()
+		args = append([]string{"-v"}, args...)
+		cmd := exec.Command(sizerExe(t), args...)
+		cmd.Dir = testRepo.Path
+		cmd.Env = append(os.Environ(), "GIT_PAGER=cat")
+		var stdout, stderr bytes.Buffer
+		cmd.Stdout = &stdout
+		cmd.Stderr = &stderr
+		require.NoError(t, cmd.Run())
+		return stdout.String(), stderr.String()
+	}
+
+	// The results are the same, but the progress is shown in the
+	// dashboard:
+	expected, _ := run("--no-progress")
+	output, dashboard := run("--tui")
+	assert.Equal(t, expected, output)
+	assert.Contains(t, dashboard, "Processing commits")
+	assert.Contains(t, dashboard, "1 / 1 [##########]")
+	assert.Contains(t, dashboard, "Found so far: 1 commits, 1 trees, 1 blobs")
+}
+
+func TestObjectNotes(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "object-notes")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "sdk.bin", strings.Repeat("x", 100000))
+	testRepo.AddFile(t, "README", "Hello\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	cmd := testRepo.GitCommand(t, "rev-parse", "HEAD:sdk.bin")
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	blob := strings.TrimSpace(string(out))
+
+	cmd = testRepo.AuthoredCommand(
+		t, "notes", "--ref=size-exemptions", "add",
+		"-m", "Vendored SDK, approved in #123\n\nTo be removed in v2.", blob,
+	)
+	require.NoError(t, cmd.Run(), "adding note")
+
+	type note struct {
+		NotesRef string `json:"notesRef"`
+		Text     string `json:"text"`
+	}
+	var v struct {
+		MaxBlobSize struct {
+			ObjectName  string `json:"objectName"`
+			ObjectNotes []note `json:"objectNotes"`
+		} `json:"maxBlobSize"`
+		ObjectNotes map[string][]note `json:"objectNotes"`
+	}
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2")
+	cmd.Dir = testRepo.Path
+	out, err = cmd.Output()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(out, &v))
+	assert.Equal(t, blob, v.MaxBlobSize.ObjectName)
+	expected := []note{{
+		NotesRef: "refs/notes/size-exemptions",
+		Text:     "Vendored SDK, approved in #123\n\nTo be removed in v2.",
+	}}
+	assert.Equal(t, expected, v.MaxBlobSize.ObjectNotes)
+	assert.Equal(t, expected, v.ObjectNotes[blob])
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "-v", "--refgroup-attribution")
+	cmd.Dir = testRepo.Path
+	out, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "(refs/heads/master:sdk.bin) [refgroups: branches] [note: Vendored SDK, approved in #123]\n")
+
+	// Notes in other notes refs are only shown if requested:
+	cmd = exec.Command(sizerExe(t), "--no-progress", "-v", "--notes-ref=refs/notes/commits")
+	cmd.Dir = testRepo.Path
+	out, err = cmd.Output()
+	require.NoError(t, err)
+	assert.NotContains(t, string(out), "[note:")
+}
+
+func TestShowRefsJSON(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "show-refs-json")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", "Hello\n")
+	testRepo.Commit(t, "-m", "initial")
+	require.NoError(t, testRepo.GitCommand(t, "tag", "v1").Run(), "creating tag")
+
+	type showRef struct {
+		Refname  string   `json:"refname"`
+		Included bool     `json:"included"`
+		Groups   []string `json:"groups"`
+	}
+	expected := []showRef{
+		{Refname: "refs/heads/master", Included: true, Groups: []string{"branches"}},
+		{Refname: "refs/tags/v1", Included: false, Groups: []string{"ignored"}},
+	}
+
+	cmd := exec.Command(sizerExe(t), "--show-refs=json", "--exclude=refs/tags")
+	cmd.Dir = testRepo.Path
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	var refs []showRef
+	require.NoError(t, json.Unmarshal(out, &refs))
+	assert.Equal(t, expected, refs)
+
+	// With a file, the listing is written there and the scan goes on:
+	path := filepath.Join(testRepo.Path, "refs.json")
+	cmd = exec.Command(
+		sizerExe(t), "--no-progress", "--show-refs=json", "--show-refs-file="+path,
+		"--exclude=refs/tags",
+	)
+	cmd.Dir = testRepo.Path
+	out, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "Scan scope:")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	refs = nil
+	require.NoError(t, json.Unmarshal(data, &refs))
+	assert.Equal(t, expected, refs)
+
+	cmd = exec.Command(sizerExe(t), "--show-refs-file="+path)
+	cmd.Dir = testRepo.Path
+	assert.Error(t, cmd.Run(), "--show-refs-file requires --show-refs=json")
+}
+
+func TestInteroperabilityBoundaries(t *testing.T) {
+	t.Parallel()
+
+	// Creating blobs this big would make the test too slow, so
+	// register their sizes with a graph directly:
+	oid := func(s string) git.OID {
+		oid, err := git.NewOID(s)
+		require.NoError(t, err)
+		return oid
+	}
+	small := oid("1111111111111111111111111111111111111111")
+	big := oid("2222222222222222222222222222222222222222")
+	huge := oid("3333333333333333333333333333333333333333")
+
+	g := sizes.NewGraph(sizes.NameStyleHash, sizes.ScanOptions{})
+	g.RegisterBlob(small, counts.NewCount32(1<<31-1))
+	g.RegisterBlob(big, counts.NewCount32(3<<30))
+	g.RegisterBlob(huge, counts.NewCount32(5<<30))
+	h := g.HistorySize()
+
+	assert.Equal(t, counts.Count32(2), h.Over2GiBBlobCount)
+	require.NotNil(t, h.Over2GiBBlob)
+	assert.Contains(t, []git.OID{big, huge}, h.Over2GiBBlob.OID)
+	assert.Equal(t, counts.Count32(1), h.Over4GiBBlobCount)
+	require.NotNil(t, h.Over4GiBBlob)
+	assert.Equal(t, huge, h.Over4GiBBlob.OID)
+
+	table := h.TableString(nil, 0, sizes.NameStyleHash)
+	assert.Contains(t, table, "|   * Over 2 GiB           [")
+	assert.Contains(t, table, "|   * Over 4 GiB           [")
+}
+
+func TestCommitWorkers(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "commit-workers")
+	defer testRepo.Remove(t)
+
+	run := func(args ...string) {
+		t.Helper()
+		cmd := testRepo.AuthoredCommand(t, args...)
+		require.NoError(t, cmd.Run(), "running git %v", args)
+	}
+
+	// Several independent histories, some of which are merged, so
+	// that there are commits of the same generation to be processed
+	// concurrently:
+	for b := 0; b < 4; b++ {
+		run("checkout", "--orphan", fmt.Sprintf("branch-%d", b))
+		for i := 0; i < 5; i++ {
+			testRepo.AddFile(t, fmt.Sprintf("file-%d-%d.txt", b, i), strings.Repeat("x", b*100+i))
+			run("commit", "-m", fmt.Sprintf("commit %d on branch %d", i, b))
+		}
+	}
+	run("merge", "--allow-unrelated-histories", "-s", "ours", "-m", "merge", "branch-0", "branch-1")
+
+	scan := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command(
+			sizerExe(t), append([]string{"--no-progress", "--json", "--json-version=2"}, args...)...,
+		)
+		cmd.Dir = testRepo.Path
+		out, err := cmd.Output()
+		require.NoError(t, err)
+		return string(out)
+	}
+
+	serial := scan()
+	assert.Contains(t, serial, `"disconnectedHistoryCount"`)
+	assert.Equal(t, serial, scan("--commit-workers=4"))
+	assert.Equal(t, serial, scan("--commit-workers=0"))
+}
+
+func TestSizeOracle(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "size-oracle")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "small.txt", "small\n")
+	cmd := testRepo.AuthoredCommand(t, "commit", "-m", "initial")
+	require.NoError(t, cmd.Run(), "committing")
+
+	// An oracle that claims that every blob is a thousand times
+	// bigger than it really is:
+	oracle := `git cat-file --batch-check='%(objectname) %(objecttype) %(objectsize)' |` +
+		` awk '$2 == "blob" { $3 = $3 * 1000 } { print }'`
+
+	cmd = exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2", "--size-oracle="+oracle,
+	)
+	cmd.Dir = testRepo.Path
+	out, err := cmd.Output()
+	require.NoError(t, err)
+
+	var v map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(out, &v))
+	var maxBlobSize struct {
+		Value uint64
+	}
+	require.NoError(t, json.Unmarshal(v["maxBlobSize"], &maxBlobSize))
+	assert.Equal(t, uint64(6000), maxBlobSize.Value)
+
+	// An oracle that fails makes the scan fail:
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--size-oracle=exit 3")
+	cmd.Dir = testRepo.Path
+	assert.Error(t, cmd.Run())
+}
+
+func TestMergeBubbles(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "merge-bubbles")
+	defer testRepo.Remove(t)
+
+	run := func(args ...string) {
+		t.Helper()
+		cmd := testRepo.AuthoredCommand(t, args...)
+		require.NoError(t, cmd.Run(), "running git %v", args)
+	}
+	commit := func(name string) {
+		t.Helper()
+		testRepo.AddFile(t, name+".txt", name+"\n")
+		run("commit", "-m", name)
+	}
+
+	run("checkout", "-b", "main")
+	commit("a")
+	commit("b")
+	run("checkout", "-b", "side")
+	commit("x")
+	run("checkout", "main")
+	commit("c")
+	// Merges in x:
+	run("merge", "--no-ff", "-m", "m1", "side")
+	run("checkout", "side")
+	commit("y")
+	commit("y2")
+	// Merges in m1 and c:
+	run("merge", "--no-ff", "-m", "s1", "main")
+	run("checkout", "main")
+	commit("d")
+	// Merges in s1, y, and y2, but not x, which main already has:
+	run("merge", "--no-ff", "-m", "m2", "side")
+
+	// Merge bubbles are only measured if they are requested:
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2")
+	cmd.Dir = testRepo.Path
+	out, err := cmd.Output()
+	require.NoError(t, err)
+
+	var v map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(out, &v))
+	assert.NotContains(t, v, "maxMergeBubble")
+	assert.NotContains(t, v, "averageMergeBubble")
+
+	stats := "--stats=maxMergeBubble,averageMergeBubble"
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2", stats)
+	cmd.Dir = testRepo.Path
+	out, err = cmd.Output()
+	require.NoError(t, err)
+
+	require.NoError(t, json.Unmarshal(out, &v))
+	var stat struct {
+		Value             uint64
+		ObjectDescription string
+	}
+	require.NoError(t, json.Unmarshal(v["maxMergeBubble"], &stat))
+	assert.Equal(t, uint64(3), stat.Value)
+	assert.Equal(t, "refs/heads/main", stat.ObjectDescription)
+	require.NoError(t, json.Unmarshal(v["averageMergeBubble"], &stat))
+	assert.Equal(t, uint64(2), stat.Value)
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=1", stats)
+	cmd.Dir = testRepo.Path
+	out, err = cmd.Output()
+	require.NoError(t, err)
+	var h struct {
+		MergeCount       uint64 `json:"merge_count"`
+		MergeBubbleTotal uint64 `json:"merge_bubble_total"`
+	}
+	require.NoError(t, json.Unmarshal(out, &h))
+	assert.Equal(t, uint64(3), h.MergeCount)
+	assert.Equal(t, uint64(6), h.MergeBubbleTotal)
+}
+
+func TestDirectorySizes(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	testRepo := testutils.NewTestRepo(t, false, "directory-sizes")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "src/main.c", "int main;\n")
+	testRepo.AddFile(t, "src/vendor/big.c", strings.Repeat("x", 1000))
+	testRepo.AddFile(t, "README", "hi\n")
+	testRepo.Commit(t, "-m", "initial")
+	testRepo.AddFile(t, "src/vendor/big.c", strings.Repeat("y", 2000))
+	testRepo.Commit(t, "-m", "bigger")
+
+	repo := testRepo.Repository(t)
+	refRoots, err := sizes.CollectReferences(ctx, repo, refGrouper{})
+	require.NoError(t, err)
+	roots := make([]sizes.Root, 0, len(refRoots))
+	for _, refRoot := range refRoots {
+		roots = append(roots, refRoot)
+	}
+
+	h, err := sizes.ScanRepositoryUsingGraph(
+		ctx, repo, roots, sizes.NameStyleNone, meter.NoProgressMeter,
+		sizes.ScanOptions{DirectorySizes: true},
+	)
+	require.NoError(t, err)
+
+	vendor, ok := h.DirectorySizes.Lookup("src/vendor/")
+	require.True(t, ok)
+	// Two versions of big.c, in two versions of the directory:
+	assert.Equal(t, counts.Count32(4), vendor.ObjectCount)
+	assert.Equal(t, counts.Count64(3000), vendor.BlobSize)
+
+	top, ok := h.DirectorySizes.Lookup(".")
+	require.True(t, ok)
+	assert.Equal(t, h.UniqueBlobSize, top.BlobSize)
+
+	_, ok = h.DirectorySizes.Lookup("nonexistent")
+	assert.False(t, ok)
+
+	var children []string
+	for _, ds := range h.DirectorySizes.Children("") {
+		children = append(children, ds.Path)
+	}
+	assert.Equal(t, []string{"src", "README"}, children)
+
+	du := func(args ...string) []sizes.DirectorySize {
+		t.Helper()
+		cmd := exec.Command(
+			sizerExe(t), append([]string{"du", "--no-progress", "--json"}, args...)...,
+		)
+		cmd.Dir = testRepo.Path
+		out, err := cmd.Output()
+		require.NoError(t, err)
+		var entries []sizes.DirectorySize
+		require.NoError(t, json.Unmarshal(out, &entries))
+		return entries
+	}
+
+	paths := func(entries []sizes.DirectorySize) []string {
+		var ret []string
+		for _, e := range entries {
+			ret = append(ret, e.Path)
+		}
+		return ret
+	}
+	assert.Equal(t, []string{"src", "src/vendor", "src/main.c"}, paths(du("src")))
+	assert.Equal(
+		t, []string{"src", "src/vendor", "src/vendor/big.c", "src/main.c"},
+		paths(du("--deep", "src")),
+	)
+}
+
+func TestCheckoutManifest(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "checkout-manifest")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "big.bin", strings.Repeat("x", 5000))
+	testRepo.AddFile(t, "dir/small.txt", "small\n")
+	testRepo.AddFile(t, "dir/tab\tname.txt", "tab\n")
+	testRepo.Commit(t, "-m", "big")
+	// The newest checkout is smaller, so it isn't the one listed:
+	require.NoError(t, testRepo.GitCommand(t, "rm", "-q", "big.bin").Run())
+	testRepo.Commit(t, "-m", "smaller")
+
+	bigBlob := testRepo.GitCommand(t, "rev-parse", "HEAD~:big.bin")
+	out, err := bigBlob.Output()
+	require.NoError(t, err)
+
+	manifest := filepath.Join(testRepo.Path, "manifest.tsv")
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--names=none", "--dump-checkout-manifest="+manifest,
+	)
+	cmd.Dir = testRepo.Path
+	require.NoError(t, cmd.Run())
+
+	contents, err := os.ReadFile(manifest)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, "big.bin\t"+strings.TrimSpace(string(out))+"\t5000", lines[0])
+	assert.True(t, strings.HasPrefix(lines[1], "dir/small.txt\t"))
+	assert.True(t, strings.HasPrefix(lines[2], `"dir/tab\tname.txt"`+"\t"), lines[2])
+
+	cmd = exec.Command(
+		sizerExe(t), "--no-progress", "--stats=maxBlobSize", "--dump-checkout-manifest="+manifest,
+	)
+	cmd.Dir = testRepo.Path
+	assert.Error(t, cmd.Run())
+}
+
+func TestLineEndingDuplicates(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "line-ending-duplicates")
+	defer testRepo.Remove(t)
+
+	require.NoError(t, testRepo.GitCommand(t, "config", "core.autocrlf", "false").Run())
+
+	lf := strings.Repeat("all work and no play\n", 10000)
+	crlf := strings.ReplaceAll(lf, "\n", "\r\n")
+	testRepo.AddFile(t, "unix.txt", lf)
+	testRepo.AddFile(t, "windows.txt", crlf)
+	// Binary files are not considered, even if they contain CRLFs:
+	testRepo.AddFile(t, "unix.bin", "\x00"+lf)
+	testRepo.AddFile(t, "windows.bin", "\x00"+crlf)
+	// A lone CR is not a line ending:
+	testRepo.AddFile(t, "mac.txt", strings.ReplaceAll(lf, "\n", "\r"))
+	testRepo.Commit(t, "-m", "blobs")
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2", "--line-ending-duplicates=10",
+	)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	var v struct {
+		LineEndingDuplicates []struct {
+			Blobs []struct {
+				Name      string `json:"name"`
+				Size      uint64 `json:"size"`
+				CRLFCount uint64 `json:"crlf_count"`
+			} `json:"blobs"`
+			WastedSize uint64 `json:"wasted_size"`
+		} `json:"lineEndingDuplicates"`
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+	require.Len(t, v.LineEndingDuplicates, 1)
+
+	set := v.LineEndingDuplicates[0]
+	require.Len(t, set.Blobs, 2)
+	assert.Contains(t, set.Blobs[0].Name, "master:windows.txt")
+	assert.EqualValues(t, len(crlf), set.Blobs[0].Size)
+	assert.EqualValues(t, 10000, set.Blobs[0].CRLFCount)
+	assert.Contains(t, set.Blobs[1].Name, "master:unix.txt")
+	assert.EqualValues(t, 0, set.Blobs[1].CRLFCount)
+	assert.EqualValues(t, len(crlf), set.WastedSize)
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--line-ending-duplicates=10")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "only differ by their line endings")
+	assert.Contains(t, string(output), "'* text=auto'")
+}
+
+func TestConfigDrift(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "config-drift")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "big.bin", strings.Repeat("x", 1<<20))
+	testRepo.Commit(t, "-m", "big")
+
+	type drift struct {
+		Key         string `json:"key"`
+		Value       string `json:"value"`
+		Default     string `json:"default"`
+		Recommended string `json:"recommended"`
+	}
+	scan := func() ([]drift, string) {
+		t.Helper()
+
+		cmd := exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2")
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err)
+		var v struct {
+			ConfigDrift []drift `json:"configDrift"`
+		}
+		require.NoError(t, json.Unmarshal(output, &v))
+
+		cmd = exec.Command(sizerExe(t), "--no-progress")
+		cmd.Dir = testRepo.Path
+		output, err = cmd.Output()
+		require.NoError(t, err)
+		return v.ConfigDrift, string(output)
+	}
+
+	// The repository is too small for its settings to matter:
+	d, output := scan()
+	assert.Empty(t, d)
+	assert.NotContains(t, output, "gitconfig settings")
+
+	// With these settings, 'git pack-objects' would need a lot of
+	// memory for its delta-search windows:
+	require.NoError(t, testRepo.GitCommand(t, "config", "pack.threads", "64").Run())
+	require.NoError(t, testRepo.GitCommand(t, "config", "pack.window", "250").Run())
+
+	d, output = scan()
+	assert.Equal(
+		t,
+		[]drift{
+			{Key: "pack.window", Value: "250", Default: "10", Recommended: "10"},
+			{Key: "pack.windowMemory", Value: "", Default: "0", Recommended: "256m"},
+		},
+		d,
+	)
+	assert.Contains(t, output, "Some gitconfig settings don't suit a repository of this size")
+	assert.Contains(t, output, "'pack.window' is 250, but 10 is recommended")
+	assert.Contains(t, output, "git config pack.window 10\n")
+	assert.Contains(t, output, "'pack.windowMemory' is 0 (the default), but 256m is recommended")
+	assert.Contains(t, output, "git config pack.windowMemory 256m\n")
+
+	require.NoError(t, testRepo.GitCommand(t, "config", "pack.windowMemory", "256m").Run())
+	d, _ = scan()
+	assert.Equal(
+		t,
+		[]drift{{Key: "pack.window", Value: "250", Default: "10", Recommended: "10"}},
+		d,
+	)
+}
+
+func TestForcePushes(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "force-pushes")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", "hello\n")
+	testRepo.Commit(t, "-m", "initial")
+	testRepo.AddFile(t, "big.bin", strings.Repeat("x", 10000))
+	testRepo.Commit(t, "-m", "oops")
+	// Rewind the branch, as before a force push:
+	require.NoError(t, testRepo.GitCommand(t, "reset", "-q", "--hard", "HEAD~").Run())
+	testRepo.AddFile(t, "README", "hello, world\n")
+	testRepo.Commit(t, "-m", "fixed")
+	// A fast-forward is not a rewrite:
+	require.NoError(t, testRepo.GitCommand(t, "branch", "topic", "HEAD~").Run())
+	require.NoError(t, testRepo.GitCommand(t, "branch", "-f", "topic", "HEAD").Run())
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2", "--force-pushes",
+	)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	var v struct {
+		ForcePushes struct {
+			RewriteCount uint64 `json:"rewrite_count"`
+			RefCount     uint64 `json:"ref_count"`
+			ObjectCount  uint64 `json:"object_count"`
+			DiskSize     uint64 `json:"disk_size"`
+			Refs         []struct {
+				Refname      string `json:"refname"`
+				RewriteCount uint64 `json:"rewrite_count"`
+				ObjectCount  uint64 `json:"object_count"`
+			} `json:"refs"`
+			ExpireUnreachable string `json:"expire_unreachable"`
+		} `json:"forcePushes"`
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+
+	f := v.ForcePushes
+	assert.EqualValues(t, 1, f.RewriteCount)
+	assert.EqualValues(t, 1, f.RefCount)
+	// The "oops" commit, its tree, and the big blob:
+	assert.EqualValues(t, 3, f.ObjectCount)
+	assert.NotZero(t, f.DiskSize)
+	assert.Equal(t, "30.days.ago", f.ExpireUnreachable)
+	require.Len(t, f.Refs, 1)
+	assert.Equal(t, "refs/heads/master", f.Refs[0].Refname)
+	assert.EqualValues(t, 1, f.Refs[0].RewriteCount)
+	assert.EqualValues(t, 3, f.Refs[0].ObjectCount)
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--force-pushes")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "History replaced by force pushes and rebases")
+	assert.Contains(t, string(output), "| refs/heads/master ")
+}
+
+func TestProgressRecords(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "progress-records")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", "hello\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	type record struct {
+		Phase string `json:"phase"`
+		Count int64  `json:"count"`
+		Total int64  `json:"total"`
+		Done  bool   `json:"done"`
+	}
+	parse := func(contents []byte) []record {
+		t.Helper()
+		var records []record
+		for _, line := range strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n") {
+			var r record
+			require.NoError(t, json.Unmarshal([]byte(line), &r), "parsing %q", line)
+			records = append(records, r)
+		}
+		return records
+	}
+	checkRecords := func(records []record) {
+		t.Helper()
+		var phases []string
+		for _, r := range records {
+			if r.Done {
+				phases = append(phases, r.Phase)
+			}
+			if r.Phase == "Processing blobs" && r.Done {
+				assert.EqualValues(t, 1, r.Count)
+			}
+		}
+		assert.Contains(t, phases, "Processing blobs")
+		assert.Contains(t, phases, "Processing commits")
+	}
+
+	t.Run("file", func(t *testing.T) {
+		path := filepath.Join(testRepo.Path, "progress.ndjson")
+		cmd := exec.Command(sizerExe(t), "--no-progress", "--progress-file="+path)
+		cmd.Dir = testRepo.Path
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		require.NoError(t, cmd.Run())
+		assert.Empty(t, stderr.String(), "the human progress meter is still off")
 
-	submTestRepo := testutils.TestRepo{
-		Path: filepath.Join(tmp, "subm"),
+		contents, err := os.ReadFile(path)
+		require.NoError(t, err)
+		checkRecords(parse(contents))
+	})
+
+	t.Run("fd", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		require.NoError(t, err)
+		defer r.Close()
+
+		cmd := exec.Command(sizerExe(t), "--no-progress", "--progress-fd=3")
+		cmd.Dir = testRepo.Path
+		cmd.ExtraFiles = []*os.File{w}
+		require.NoError(t, cmd.Start())
+		w.Close()
+
+		contents, err := io.ReadAll(r)
+		require.NoError(t, err)
+		require.NoError(t, cmd.Wait())
+		checkRecords(parse(contents))
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		cmd := exec.Command(sizerExe(t), "--progress-fd=3", "--progress-file=x")
+		cmd.Dir = testRepo.Path
+		assert.Error(t, cmd.Run())
+
+		cmd = exec.Command(sizerExe(t), "--progress-fd=57")
+		cmd.Dir = testRepo.Path
+		out, err := cmd.CombinedOutput()
+		assert.Error(t, err)
+		assert.Contains(t, string(out), "file descriptor is not open")
+	})
+}
+
+func TestNormalizedDuplicates(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "normalized-duplicates")
+	defer testRepo.Remove(t)
+
+	require.NoError(t, testRepo.GitCommand(t, "config", "core.autocrlf", "false").Run())
+
+	unix := "hello\nworld\n"
+	messy := "\xef\xbb\xbfhello  \r\nworld\t\r\n\r\n"
+	unterminated := "hello\nworld"
+	testRepo.AddFile(t, "unix.txt", unix)
+	testRepo.AddFile(t, "messy.txt", messy)
+	testRepo.AddFile(t, "unterminated.txt", unterminated)
+	testRepo.AddFile(t, "other.txt", "hello\n\nworld\n")
+	// Binary blobs aren't normalized:
+	testRepo.AddFile(t, "binary.bin", "\x00"+unix)
+	testRepo.AddFile(t, "binary-messy.bin", "\x00"+messy)
+	// Blobs above the cutoff aren't examined:
+	testRepo.AddFile(t, "big.txt", unix+strings.Repeat("\n", 2000))
+	testRepo.Commit(t, "-m", "blobs")
+
+	oid := func(path string) string {
+		t.Helper()
+		out, err := testRepo.GitCommand(t, "rev-parse", "HEAD:"+path).Output()
+		require.NoError(t, err)
+		return strings.TrimSpace(string(out))
 	}
-	submTestRepo.Init(t, false)
-	submTestRepo.AddFile(t, "submfile1.txt", "Hello, submodule!\n")
-	submTestRepo.AddFile(t, "submfile2.txt", "Hello again, submodule!\n")
-	submTestRepo.AddFile(t, "submfile3.txt", "Hello again, submodule!\n")
 
-	cmd := submTestRepo.GitCommand(t, "commit", "-m", "subm initial")
-	testutils.AddAuthorInfo(cmd, &timestamp)
-	require.NoError(t, cmd.Run(), "creating subm commit")
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2", "--normalized-duplicates=1",
+	)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
 
-	mainTestRepo := testutils.TestRepo{
-		Path: filepath.Join(tmp, "main"),
+	type blob struct {
+		Blob string `json:"blob"`
+		Size uint64 `json:"size"`
 	}
-	mainTestRepo.Init(t, false)
+	var v struct {
+		NormalizedDuplicates struct {
+			Cutoff             uint64 `json:"cutoff"`
+			TextBlobCount      uint64 `json:"text_blob_count"`
+			DistinctCount      uint64 `json:"distinct_count"`
+			RedundantBlobCount uint64 `json:"redundant_blob_count"`
+			RedundantSize      uint64 `json:"redundant_size"`
+			Sets               []struct {
+				Blobs         []blob `json:"blobs"`
+				RedundantSize uint64 `json:"redundant_size"`
+			} `json:"sets"`
+		} `json:"normalizedDuplicates"`
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
 
-	mainTestRepo.AddFile(t, "mainfile.txt", "Hello, main!\n")
+	d := v.NormalizedDuplicates
+	assert.EqualValues(t, 1024, d.Cutoff)
+	assert.EqualValues(t, 4, d.TextBlobCount)
+	assert.EqualValues(t, 2, d.DistinctCount)
+	assert.EqualValues(t, 2, d.RedundantBlobCount)
+	assert.EqualValues(t, len(messy)+len(unix), d.RedundantSize)
+	require.Len(t, d.Sets, 1)
+	assert.Equal(
+		t,
+		[]blob{
+			{oid("messy.txt"), uint64(len(messy))},
+			{oid("unix.txt"), uint64(len(unix))},
+			{oid("unterminated.txt"), uint64(len(unterminated))},
+		},
+		d.Sets[0].Blobs,
+	)
+	assert.EqualValues(t, d.RedundantSize, d.Sets[0].RedundantSize)
 
-	cmd = mainTestRepo.GitCommand(t, "commit", "-m", "main initial")
-	testutils.AddAuthorInfo(cmd, &timestamp)
-	require.NoError(t, cmd.Run(), "creating main commit")
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--normalized-duplicates=1")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "only differ by whitespace or a byte order mark")
+	assert.Contains(t, string(output), oid("unterminated.txt"))
+}
 
-	// Make subm a submodule of main:
-	cmd = mainTestRepo.GitCommand(t, "-c", "protocol.file.allow=always", "submodule", "add", submTestRepo.Path, "sub")
-	cmd.Dir = mainTestRepo.Path
-	require.NoError(t, cmd.Run(), "adding submodule")
+func TestWriteCommitGraph(t *testing.T) {
+	t.Parallel()
 
-	cmd = mainTestRepo.GitCommand(t, "commit", "-m", "add submodule")
-	testutils.AddAuthorInfo(cmd, &timestamp)
-	require.NoError(t, cmd.Run(), "committing submodule to main")
+	testRepo := testutils.NewTestRepo(t, false, "write-commit-graph")
+	defer testRepo.Remove(t)
 
-	mainRepo := mainTestRepo.Repository(t)
+	for _, msg := range []string{"one", "two"} {
+		testRepo.AddFile(t, "README", msg+"\n")
+		cmd := testRepo.AuthoredCommand(t, "commit", "-m", msg)
+		require.NoError(t, cmd.Run(), "committing")
+	}
 
-	mainRefRoots, err := sizes.CollectReferences(ctx, mainRepo, refGrouper{})
+	type commitGraphWrite struct {
+		Written       bool    `json:"written"`
+		SampleCommand string  `json:"sample_command"`
+		CommitCount   uint64  `json:"commit_count"`
+		BeforeSeconds float64 `json:"before_seconds"`
+		AfterSeconds  float64 `json:"after_seconds"`
+	}
+	scan := func() commitGraphWrite {
+		t.Helper()
+		cmd := exec.Command(
+			sizerExe(t), "--no-progress", "--json", "--json-version=2", "--write-commit-graph",
+		)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err)
+		var v struct {
+			CommitGraphWrite *commitGraphWrite `json:"commitGraphWrite"`
+		}
+		require.NoError(t, json.Unmarshal(output, &v))
+		require.NotNil(t, v.CommitGraphWrite)
+		return *v.CommitGraphWrite
+	}
+
+	graphFile := filepath.Join(testRepo.Path, ".git", "objects", "info", "commit-graph")
+	require.NoFileExists(t, graphFile)
+
+	w := scan()
+	assert.True(t, w.Written)
+	assert.Equal(t, "git rev-list --count --all", w.SampleCommand)
+	assert.EqualValues(t, 2, w.CommitCount)
+	assert.Greater(t, w.BeforeSeconds, 0.0)
+	assert.Greater(t, w.AfterSeconds, 0.0)
+	assert.FileExists(t, graphFile)
+
+	// Now there is nothing left to do:
+	assert.Equal(t, commitGraphWrite{}, scan())
+}
+
+func TestObjectPath(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "object-path")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "src/lib/big.dat", strings.Repeat("b", 5000))
+	testRepo.AddFile(t, "README", "hello\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	revParse := func(rev string) string {
+		t.Helper()
+		out, err := testRepo.GitCommand(t, "rev-parse", rev).Output()
+		require.NoError(t, err)
+		return strings.TrimSpace(string(out))
+	}
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2", "--top-objects=2",
+	)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
 	require.NoError(t, err)
 
-	mainRoots := make([]sizes.Root, 0, len(mainRefRoots))
-	for _, refRoot := range mainRefRoots {
-		mainRoots = append(mainRoots, refRoot)
+	type step struct {
+		ObjectType string
+		ObjectName string
+		Ref        string
+		Name       string
+	}
+	type stat struct {
+		ObjectDescription string
+		ObjectPath        []step
+		TopObjects        []struct {
+			ObjectDescription string
+			ObjectPath        []step
+		}
+	}
+	var v struct {
+		MaxBlobSize          stat
+		MaxCheckoutBlobCount stat
 	}
+	require.NoError(t, json.Unmarshal(output, &v))
 
-	// Analyze the main repo:
-	h, err := sizes.ScanRepositoryUsingGraph(
-		context.Background(), mainTestRepo.Repository(t),
-		mainRoots, sizes.NameStyleNone, meter.NoProgressMeter,
+	commitStep := step{
+		ObjectType: "commit",
+		ObjectName: revParse("master"),
+		Ref:        "refs/heads/master",
+	}
+	rootStep := step{ObjectType: "tree", ObjectName: revParse("master^{tree}")}
+
+	assert.Equal(t, "refs/heads/master:src/lib/big.dat", v.MaxBlobSize.ObjectDescription)
+	assert.Equal(
+		t,
+		[]step{
+			commitStep,
+			rootStep,
+			{ObjectType: "tree", ObjectName: revParse("master:src"), Name: "src"},
+			{ObjectType: "tree", ObjectName: revParse("master:src/lib"), Name: "lib"},
+			{ObjectType: "blob", ObjectName: revParse("master:src/lib/big.dat"), Name: "big.dat"},
+		},
+		v.MaxBlobSize.ObjectPath,
 	)
-	require.NoError(t, err, "scanning repository")
-	assert.Equal(t, counts.Count32(2), h.UniqueBlobCount, "unique blob count")
-	assert.Equal(t, counts.Count32(2), h.MaxExpandedBlobCount, "max expanded blob count")
-	assert.Equal(t, counts.Count32(1), h.MaxExpandedSubmoduleCount, "max expanded submodule count")
 
-	// Analyze the submodule:
-	submTestRepo2 := testutils.TestRepo{
-		Path: filepath.Join(mainTestRepo.Path, "sub"),
+	// The runners-up get a chain, too:
+	require.Len(t, v.MaxBlobSize.TopObjects, 2)
+	assert.Equal(
+		t,
+		[]step{
+			commitStep,
+			rootStep,
+			{ObjectType: "blob", ObjectName: revParse("master:README"), Name: "README"},
+		},
+		v.MaxBlobSize.TopObjects[1].ObjectPath,
+	)
+
+	assert.Equal(t, "refs/heads/master^{tree}", v.MaxCheckoutBlobCount.ObjectDescription)
+	assert.Equal(t, []step{commitStep, rootStep}, v.MaxCheckoutBlobCount.ObjectPath)
+}
+
+func TestLockfiles(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "lockfiles")
+	defer testRepo.Remove(t)
+
+	// Three versions of "package-lock.json" (one of which is also
+	// used in a subdirectory, but counted only once) and two of
+	// "go.sum". "yarn.lock.txt" isn't a lockfile:
+	var npmSize, goSize uint64
+	for i := 1; i <= 3; i++ {
+		lock := strings.Repeat(fmt.Sprintf("dependency %d\n", i), 10*i)
+		testRepo.AddFile(t, "package-lock.json", lock)
+		testRepo.AddFile(t, "web/package-lock.json", lock)
+		npmSize += uint64(len(lock))
+		if i <= 2 {
+			sum := fmt.Sprintf("module v1.%d h1:xyz=\n", i)
+			testRepo.AddFile(t, "go.sum", sum)
+			goSize += uint64(len(sum))
+		}
+		testRepo.AddFile(t, "yarn.lock.txt", fmt.Sprintf("not a lockfile %d\n", i))
+		testRepo.Commit(t, "-m", fmt.Sprintf("update %d", i))
 	}
+	maxSize := uint64(len(strings.Repeat("dependency 3\n", 30)))
 
-	submRepo2 := submTestRepo2.Repository(t)
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--json", "--json-version=2")
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
 
-	submRefRoots2, err := sizes.CollectReferences(ctx, submRepo2, refGrouper{})
+	type stat struct {
+		Value             uint64
+		ObjectDescription string
+	}
+	var v struct {
+		LockfileVersionCount stat
+		UniqueLockfileSize   stat
+		MaxLockfileSize      stat
+		Lockfiles            []struct {
+			Name         string `json:"name"`
+			VersionCount uint64 `json:"version_count"`
+			UniqueSize   uint64 `json:"unique_size"`
+			MaxSize      uint64 `json:"max_size"`
+			MaxSizeBlob  string `json:"max_size_blob"`
+		}
+	}
+	require.NoError(t, json.Unmarshal(output, &v))
+
+	assert.EqualValues(t, 5, v.LockfileVersionCount.Value)
+	assert.Equal(t, npmSize+goSize, v.UniqueLockfileSize.Value)
+	assert.Equal(t, maxSize, v.MaxLockfileSize.Value)
+	assert.Contains(t, v.MaxLockfileSize.ObjectDescription, "package-lock.json")
+
+	require.Len(t, v.Lockfiles, 2)
+	assert.Equal(t, "package-lock.json", v.Lockfiles[0].Name)
+	assert.EqualValues(t, 3, v.Lockfiles[0].VersionCount)
+	assert.Equal(t, npmSize, v.Lockfiles[0].UniqueSize)
+	assert.Equal(t, maxSize, v.Lockfiles[0].MaxSize)
+	assert.Contains(t, v.Lockfiles[0].MaxSizeBlob, "package-lock.json")
+	assert.Equal(t, "go.sum", v.Lockfiles[1].Name)
+	assert.EqualValues(t, 2, v.Lockfiles[1].VersionCount)
+	assert.Equal(t, goSize, v.Lockfiles[1].UniqueSize)
+
+	// The breakdown is only printed if a lockfile statistic is
+	// reported:
+	cmd = exec.Command(sizerExe(t), "--no-progress")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
 	require.NoError(t, err)
+	assert.NotContains(t, string(output), "Lockfiles (distinct versions of each")
 
-	submRoots2 := make([]sizes.Root, 0, len(submRefRoots2))
-	for _, refRoot := range submRefRoots2 {
-		submRoots2 = append(submRoots2, refRoot)
+	cmd = exec.Command(sizerExe(t), "--no-progress", "-v")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(output), "Lockfiles (distinct versions of each")
+	assert.Regexp(t, `\|     3     \| +[0-9.]+ B +\| +[0-9.]+ B +\| package-lock\.json\n`, string(output))
+
+	// Lockfiles aren't tracked at all unless a lockfile statistic is
+	// selected:
+	cmd = exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2", "--stats=uniqueBlobCount",
+	)
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
+	require.NoError(t, err)
+	assert.NotContains(t, string(output), `"lockfiles"`)
+}
+
+func TestProtoOutput(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "proto-output")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "src/big.dat", strings.Repeat("b", 5000))
+	testRepo.AddFile(t, "README", "hello\n")
+	testRepo.Commit(t, "-m", "initial")
+	require.NoError(t, testRepo.GitCommand(t, "tag", "v1").Run())
+
+	args := []string{"--no-progress", "--stats=maxBlobSize,uniqueBlobCount,refgroup.tags.maxRefnameLength"}
+
+	cmd := exec.Command(sizerExe(t), append(args, "--output-format=proto", "--top-objects=2")...)
+	cmd.Dir = testRepo.Path
+	output, err := cmd.Output()
+	require.NoError(t, err)
+
+	var r scanpb.ScanResult
+	require.NoError(t, r.Unmarshal(output))
+
+	// The statistics are the same as in the JSON output:
+	cmd = exec.Command(sizerExe(t), append(args, "--output-format=json", "--json-version=2")...)
+	cmd.Dir = testRepo.Path
+	output, err = cmd.Output()
+	require.NoError(t, err)
+	var raw map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(output, &raw))
+	type stat struct {
+		Value             uint64
+		LevelOfConcern    float64
+		ObjectName        string
+		ObjectDescription string
 	}
+	v := make(map[string]stat)
 
-	h, err = sizes.ScanRepositoryUsingGraph(
-		context.Background(), submRepo2,
-		submRoots2, sizes.NameStyleNone, meter.NoProgressMeter,
+	var symbols []string
+	for _, s := range r.Statistics {
+		symbols = append(symbols, s.Symbol)
+		require.Contains(t, raw, s.Symbol)
+		var js stat
+		require.NoError(t, json.Unmarshal(raw[s.Symbol], &js))
+		v[s.Symbol] = js
+		assert.Equal(t, js.Value, s.Value, s.Symbol)
+		assert.Equal(t, js.LevelOfConcern, s.LevelOfConcern, s.Symbol)
+	}
+	assert.Equal(t, []string{"maxBlobSize", "refgroup.tags.maxRefnameLength", "uniqueBlobCount"}, symbols)
+
+	maxBlobSize := r.Statistics[0]
+	assert.Equal(t, uint64(5000), maxBlobSize.Value)
+	assert.Equal(t, "B", maxBlobSize.Unit)
+	assert.Equal(t, "binary", maxBlobSize.Prefixes)
+	require.NotNil(t, maxBlobSize.Object)
+	assert.Equal(t, v["maxBlobSize"].ObjectName, maxBlobSize.Object.Name)
+	assert.Equal(t, v["maxBlobSize"].ObjectDescription, maxBlobSize.Object.Description)
+	require.Len(t, maxBlobSize.Object.Path, 4)
+	assert.Equal(t, "commit", maxBlobSize.Object.Path[0].ObjectType)
+	assert.NotEmpty(t, maxBlobSize.Object.Path[0].Ref)
+	assert.Equal(t, "src", maxBlobSize.Object.Path[2].Name)
+	assert.Equal(t, "big.dat", maxBlobSize.Object.Path[3].Name)
+	require.Len(t, maxBlobSize.TopObjects, 2)
+	assert.Equal(t, uint64(len("hello\n")), maxBlobSize.TopObjects[1].Value)
+
+	groups := make(map[string]uint64)
+	for _, g := range r.RefGroups {
+		groups[g.Symbol] = g.ReferenceCount
+	}
+	assert.Equal(t, uint64(1), groups["tags"])
+	assert.Equal(t, uint64(1), groups["branches"])
+
+	cmd = exec.Command(sizerExe(t), "--json", "--output-format=proto")
+	cmd.Dir = testRepo.Path
+	output, err = cmd.CombinedOutput()
+	assert.Error(t, err)
+	assert.Contains(t, string(output), "'--json' can't be combined with '--output-format=proto'")
+}
+
+func TestDebugPipelines(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "debug-pipelines")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "README", "hello\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	cmd := exec.Command(sizerExe(t), "--no-progress", "--json", "--debug-pipelines")
+	cmd.Dir = testRepo.Path
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	require.NoError(t, cmd.Run())
+
+	// The log doesn't disturb the output:
+	var v map[string]interface{}
+	require.NoError(t, json.Unmarshal(stdout.Bytes(), &v))
+
+	for _, stage := range []string{"git-rev-list", "git-cat-file"} {
+		assert.Regexp(
+			t,
+			`(?m)^git-sizer: pipeline stage `+stage+`: GIT_DIR=\S+ GIT_GRAFT_FILE=\S+ \S*git `,
+			stderr.String(),
+		)
+	}
+	assert.Contains(t, stderr.String(), "git-sizer: command: GIT_DIR=")
+}
+
+func TestCorruptRepository(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "corrupt-repository")
+	defer testRepo.Remove(t)
+
+	require.NoError(t, os.WriteFile(filepath.Join(testRepo.Path, ".git", "HEAD"), []byte("garbage\n"), 0o644))
+
+	cmd := exec.Command(sizerExe(t), "--no-progress")
+	cmd.Dir = testRepo.Path
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	require.Error(t, cmd.Run())
+
+	assert.Empty(t, stdout.String())
+	assert.Contains(
+		t, stderr.String(),
+		`corrupt HEAD: HEAD contains "garbage", which is neither a reference nor an object name`,
 	)
-	require.NoError(t, err, "scanning repository")
-	assert.Equal(t, counts.Count32(2), h.UniqueBlobCount, "unique blob count")
-	assert.Equal(t, counts.Count32(3), h.MaxExpandedBlobCount, "max expanded blob count")
+	assert.Contains(t, stderr.String(), "\nhint: point HEAD at a branch")
+}
+
+func TestRedundantRefs(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "redundant-refs")
+	defer testRepo.Remove(t)
+
+	run := func(args ...string) {
+		t.Helper()
+		require.NoError(t, testRepo.GitCommand(t, args...).Run(), "running git %v", args)
+	}
+
+	testRepo.AddFile(t, "a.txt", "a\n")
+	testRepo.Commit(t, "-m", "commit")
+	run("branch", "old")
+	run("tag", "v1")
+	testRepo.AddFile(t, "b.txt", "b\n")
+	testRepo.Commit(t, "-m", "commit")
+	run("branch", "same")
+
+	// A branch whose tip is a different commit with the same tree as
+	// `master`, which makes `master` an ancestor of another branch:
+	run("checkout", "-q", "-b", "empty")
+	testRepo.Commit(t, "-m", "commit", "--allow-empty")
+
+	run("checkout", "-q", "-b", "feature", "old")
+	testRepo.AddFile(t, "c.txt", "c\n")
+	testRepo.Commit(t, "-m", "commit")
+
+	type redundantRef struct {
+		Refname    string `json:"refname"`
+		SameTreeAs string `json:"same_tree_as"`
+		Ancestor   bool   `json:"ancestor"`
+	}
+	var output struct {
+		RedundantRefs struct {
+			BranchCount    uint32         `json:"branch_count"`
+			RedundantCount uint32         `json:"redundant_count"`
+			SameTreeCount  uint32         `json:"same_tree_count"`
+			AncestorCount  uint32         `json:"ancestor_count"`
+			Refs           []redundantRef `json:"refs"`
+		} `json:"redundantRefs"`
+	}
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2", "--redundant-refs",
+	)
+	cmd.Dir = testRepo.Path
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(out, &output))
+	assert.Equal(t, uint32(5), output.RedundantRefs.BranchCount)
+	assert.Equal(t, uint32(3), output.RedundantRefs.RedundantCount)
+	assert.Equal(t, uint32(2), output.RedundantRefs.SameTreeCount)
+	assert.Equal(t, uint32(3), output.RedundantRefs.AncestorCount)
+	assert.Equal(
+		t,
+		[]redundantRef{
+			{Refname: "refs/heads/master", SameTreeAs: "refs/heads/empty", Ancestor: true},
+			{Refname: "refs/heads/old", Ancestor: true},
+			{Refname: "refs/heads/same", SameTreeAs: "refs/heads/empty", Ancestor: true},
+		},
+		output.RedundantRefs.Refs,
+	)
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--redundant-refs")
+	cmd.Dir = testRepo.Path
+	out, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "\nRedundant branches:\n")
+	assert.Contains(
+		t, string(out),
+		"| refs/heads/master                        | same tree as refs/heads/empty; ancestor of another branch\n",
+	)
+	assert.Contains(t, string(out), "| refs/heads/old                           | ancestor of another branch\n")
+	assert.Contains(t, string(out), "\n3 of 5 branches are redundant: 2 have the same tree as another branch,\n")
+}
+
+func TestCheckoutExtensions(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "checkout-extensions")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "assets/logo.png", strings.Repeat("x", 1000))
+	testRepo.AddFile(t, "assets/icon.PNG", strings.Repeat("y", 500))
+	testRepo.AddFile(t, "main.go", "package main\n")
+	testRepo.AddFile(t, "Makefile", "all:\n")
+	testRepo.AddFile(t, ".gitignore", "*.o\n")
+	// Many small files with other extensions, which don't make the
+	// top 20:
+	for i := 0; i < 21; i++ {
+		testRepo.AddFile(t, fmt.Sprintf("misc/file.x%02d", i), "z")
+	}
+	testRepo.Commit(t, "-m", "initial")
+
+	type extensionSize struct {
+		Extension string `json:"extension"`
+		BlobCount uint64 `json:"blob_count"`
+		BlobSize  uint64 `json:"blob_size"`
+	}
+	var output struct {
+		CheckoutExtensions struct {
+			Tree           string          `json:"tree"`
+			BlobCount      uint64          `json:"blob_count"`
+			BlobSize       uint64          `json:"blob_size"`
+			ExtensionCount uint32          `json:"extension_count"`
+			Extensions     []extensionSize `json:"extensions"`
+			OtherBlobCount uint64          `json:"other_blob_count"`
+			OtherBlobSize  uint64          `json:"other_blob_size"`
+		} `json:"checkoutExtensions"`
+	}
+
+	cmd := exec.Command(
+		sizerExe(t), "--no-progress", "--json", "--json-version=2", "--checkout-extensions",
+	)
+	cmd.Dir = testRepo.Path
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(out, &output))
+
+	ce := output.CheckoutExtensions
+	assert.Contains(t, ce.Tree, " (refs/heads/master^{tree})")
+	assert.Equal(t, uint64(26), ce.BlobCount)
+	assert.Equal(t, uint64(1000+500+13+5+4+21), ce.BlobSize)
+	assert.Equal(t, uint32(24), ce.ExtensionCount)
+	require.Len(t, ce.Extensions, 20)
+	assert.Equal(
+		t,
+		[]extensionSize{
+			{Extension: ".png", BlobCount: 2, BlobSize: 1500},
+			{Extension: ".go", BlobCount: 1, BlobSize: 13},
+			{Extension: "", BlobCount: 2, BlobSize: 9},
+			{Extension: ".x00", BlobCount: 1, BlobSize: 1},
+		},
+		ce.Extensions[:4],
+	)
+	assert.Equal(t, uint64(4), ce.OtherBlobCount)
+	assert.Equal(t, uint64(4), ce.OtherBlobSize)
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--checkout-extensions")
+	cmd.Dir = testRepo.Path
+	out, err = cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "\nFiles in the biggest checkout (refs/heads/master^{tree}), by extension:\n")
+	assert.Contains(t, string(out), "| .png                 |     2     |  1.46 KiB |  97.2%\n")
+	assert.Contains(t, string(out), "| (none)               |     2     |     9 B   |   0.6%\n")
+	assert.Contains(t, string(out), "| (4 others)           |     4     |     4 B   |   0.3%\n")
+}
+
+func TestNamesBudget(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "names-budget")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "dir1/dir2/big.bin", strings.Repeat("x", 10000))
+	testRepo.AddFile(t, "small.txt", "hello\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	type namesBudget struct {
+		MaxObjects     int    `json:"max_objects"`
+		MaxChainLength int    `json:"max_chain_length"`
+		LimitedCount   uint64 `json:"limited_count"`
+	}
+	type output struct {
+		MaxBlobSize struct {
+			ObjectDescription string
+		} `json:"maxBlobSize"`
+		NamesBudget *namesBudget `json:"namesBudget"`
+	}
+	scan := func(args ...string) output {
+		t.Helper()
+
+		cmd := exec.Command(
+			sizerExe(t),
+			append([]string{"--no-progress", "--json", "--json-version=2"}, args...)...,
+		)
+		cmd.Dir = testRepo.Path
+		out, err := cmd.Output()
+		require.NoError(t, err)
+		var o output
+		require.NoError(t, json.Unmarshal(out, &o))
+		return o
+	}
+
+	// The budget is big enough:
+	o := scan("--names-budget=100,5")
+	assert.Equal(t, "refs/heads/master:dir1/dir2/big.bin", o.MaxBlobSize.ObjectDescription)
+	assert.Nil(t, o.NamesBudget)
+
+	// The blob's path consists of the blob, three trees, and the
+	// commit, so the path is only followed up to `dir1`:
+	o = scan("--names-budget=0,3")
+	dir1, err := testRepo.GitCommand(t, "rev-parse", "HEAD:dir1").Output()
+	require.NoError(t, err)
+	assert.Equal(
+		t, strings.TrimSpace(string(dir1))+":dir2/big.bin", o.MaxBlobSize.ObjectDescription,
+	)
+	require.NotNil(t, o.NamesBudget)
+	assert.Equal(t, 3, o.NamesBudget.MaxChainLength)
+	assert.NotZero(t, o.NamesBudget.LimitedCount)
+
+	// The budget can also be set via gitconfig:
+	testRepo.ConfigAdd(t, "sizer.namesBudget", "1")
+	o = scan()
+	require.NotNil(t, o.NamesBudget)
+	assert.Equal(t, 1, o.NamesBudget.MaxObjects)
+	assert.NotZero(t, o.NamesBudget.LimitedCount)
+
+	cmd := exec.Command(sizerExe(t), "--no-progress", "-v", "--names-budget=1")
+	cmd.Dir = testRepo.Path
+	out, err := cmd.Output()
+	require.NoError(t, err)
+	assert.Contains(t, string(out), "exceeded the names budget (--names-budget=1)")
+
+	cmd = exec.Command(sizerExe(t), "--no-progress", "--names-budget=1,x")
+	cmd.Dir = testRepo.Path
+	out, err = cmd.CombinedOutput()
+	assert.Error(t, err)
+	assert.Contains(t, string(out), "not a valid names budget")
+}
+
+func TestImportArtifacts(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "import-artifacts")
+	defer testRepo.Remove(t)
+
+	// A clean history has no artifacts:
+	testRepo.AddFile(t, "README", "hello\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	run := func(args ...string) []byte {
+		t.Helper()
+		cmd := exec.Command(sizerExe(t), append([]string{"--no-progress"}, args...)...)
+		cmd.Dir = testRepo.Path
+		output, err := cmd.Output()
+		require.NoError(t, err)
+		return output
+	}
+
+	type artifact struct {
+		Pattern string `json:"pattern"`
+		VCS     string `json:"vcs"`
+		Count   int    `json:"count"`
+		Size    int    `json:"size"`
+		Example string `json:"example"`
+	}
+	type output struct {
+		ImportArtifacts *struct {
+			BlobCount   int        `json:"blob_count"`
+			BlobSize    int        `json:"blob_size"`
+			CommitCount int        `json:"commit_count"`
+			Artifacts   []artifact `json:"artifacts"`
+		} `json:"importArtifacts"`
+	}
+	scan := func(args ...string) output {
+		t.Helper()
+		var o output
+		require.NoError(t, json.Unmarshal(
+			run(append([]string{"--json", "--json-version=2"}, args...)...), &o,
+		))
+		return o
+	}
+
+	o := scan("--import-artifacts")
+	require.NotNil(t, o.ImportArtifacts)
+	assert.Zero(t, o.ImportArtifacts.BlobCount)
+	assert.Empty(t, o.ImportArtifacts.Artifacts)
+	assert.Contains(t, string(run("--import-artifacts")), "No artifacts of an import")
+
+	// Two Subversion working-copy files totaling 300 bytes, a CVS
+	// directory and a ".cvsignore", and two commits with "git-svn-id"
+	// trailers. A file that is merely called "CVS" doesn't count:
+	testRepo.AddFile(t, "trunk/.svn/entries", strings.Repeat("e", 100))
+	testRepo.AddFile(t, "trunk/src/.svn/wc.db", strings.Repeat("w", 200))
+	testRepo.AddFile(t, "trunk/src/main.c", "int main() { return 0; }\n")
+	testRepo.AddFile(t, "docs/CVS", "about CVS\n")
+	testRepo.Commit(t, "-m", "import r1\n\ngit-svn-id: https://svn.example.com/repo/trunk@1 0123-4567")
+	testRepo.AddFile(t, "old/CVS/Entries", strings.Repeat("c", 50))
+	testRepo.AddFile(t, "old/.cvsignore", "*.o\n")
+	testRepo.Commit(t, "-m", "import r2\n\ngit-svn-id: https://svn.example.com/repo/trunk@2 0123-4567")
+	testRepo.AddFile(t, "NEWS", "mentions git-svn-id: in passing\n")
+	testRepo.Commit(t, "-m", "after the import")
+
+	o = scan("--import-artifacts")
+	ia := o.ImportArtifacts
+	require.NotNil(t, ia)
+	assert.Equal(t, 4, ia.BlobCount)
+	assert.Equal(t, 354, ia.BlobSize)
+	assert.Equal(t, 2, ia.CommitCount)
+	assert.Equal(t, []artifact{
+		{".svn/", "Subversion", 2, 300, "trunk/.svn/"},
+		{"CVS/", "CVS", 1, 50, "old/CVS/"},
+		{".cvsignore", "CVS", 1, 4, "old/.cvsignore"},
+		{"git-svn-id", "Subversion", 2, 0, ""},
+	}, ia.Artifacts)
+
+	// It's off by default, and can be turned on via gitconfig:
+	assert.Nil(t, scan().ImportArtifacts)
+	testRepo.ConfigAdd(t, "sizer.importArtifacts", "true")
+	assert.NotNil(t, scan().ImportArtifacts)
+
+	table := string(run())
+	assert.Contains(t, table, "VCS import artifacts:")
+	assert.Contains(t, table, "| git-svn-id trailers  | Subversion |")
+	assert.Contains(t, table, "4 blobs (354 B) and the log messages of 2 commits")
+}
+
+func TestHTMLTreemap(t *testing.T) {
+	t.Parallel()
+
+	testRepo := testutils.NewTestRepo(t, false, "html-treemap")
+	defer testRepo.Remove(t)
+
+	testRepo.AddFile(t, "src/vendor/big.bin", strings.Repeat("x", 5000))
+	testRepo.AddFile(t, "docs/README", "hello\n")
+	testRepo.Commit(t, "-m", "initial")
+
+	treemap := func(args ...string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "treemap.html")
+		cmd := exec.Command(
+			sizerExe(t), append([]string{"--no-progress", "--html-treemap=" + path}, args...)...,
+		)
+		cmd.Dir = testRepo.Path
+		require.NoError(t, cmd.Run())
+		contents, err := os.ReadFile(path)
+		require.NoError(t, err)
+		return string(contents)
+	}
+
+	page := treemap()
+	assert.True(t, strings.HasPrefix(page, "<!DOCTYPE html>"))
+	assert.Contains(t, page, "4.89 KiB of unique blobs in 6 unique blobs and trees")
+	assert.Contains(t, page, `{"name":"vendor","size":5000,"count":2,"children":[{"name":"big.bin","size":5000,"count":1}]}`)
+	assert.Contains(t, page, `{"name":"README","size":6,"count":1}`)
+
+	page = treemap("--anonymize")
+	assert.NotContains(t, page, "vendor")
+	assert.NotContains(t, page, "big.bin")
 }
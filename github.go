@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/github/git-sizer/counts"
+)
+
+// defaultGitHubAPIURL is the URL of the API that `--github-repo`
+// queries, unless another one (e.g., that of a GitHub Enterprise
+// Server) is configured.
+const defaultGitHubAPIURL = "https://api.github.com"
+
+// githubTimeout bounds how long `--github-repo` waits for a
+// response.
+const githubTimeout = 10 * time.Second
+
+// githubToken returns the token to authenticate with to the GitHub
+// API, taken from the `GITHUB_TOKEN` or `GH_TOKEN` environment
+// variable, or the empty string if neither is set.
+func githubToken() string {
+	for _, name := range []string{"GITHUB_TOKEN", "GH_TOKEN"} {
+		if token := os.Getenv(name); token != "" {
+			return token
+		}
+	}
+	return ""
+}
+
+// fetchGitHubSize queries the GitHub API at `apiURL` for the disk
+// usage that it reports for `repo`, which must have the form
+// "OWNER/NAME". Private repositories require a token (see
+// `githubToken()`). Proxies are configured as for `--check-latest`.
+func fetchGitHubSize(ctx context.Context, apiURL, repo string) (counts.Count64, error) {
+	words := strings.Split(repo, "/")
+	if len(words) != 2 || words[0] == "" || words[1] == "" {
+		return 0, fmt.Errorf("GitHub repository %q is not of the form 'OWNER/NAME'", repo)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, githubTimeout)
+	defer cancel()
+
+	url := strings.TrimSuffix(apiURL, "/") + "/repos/" + repo
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	if token := githubToken(); token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return 0, fmt.Errorf("reading response from %s: %w", url, err)
+	}
+
+	// GitHub reports the size in KiB:
+	var metadata struct {
+		Size *uint64 `json:"size"`
+	}
+	if err := json.Unmarshal(body, &metadata); err != nil || metadata.Size == nil {
+		return 0, fmt.Errorf("%s: unexpected response %q", url, truncate(string(body), 40))
+	}
+	return counts.NewCount64(*metadata.Size * 1024), nil
+}
@@ -10,11 +10,16 @@ require (
 	golang.org/x/sync v0.1.0 // indirect
 )
 
-require github.com/github/go-pipe v1.0.2
+require (
+	github.com/github/go-pipe v1.0.2
+	github.com/jhump/protoreflect v1.14.1
+	golang.org/x/text v0.13.0
+	google.golang.org/protobuf v1.28.1
+	gopkg.in/yaml.v3 v3.0.1
+)
 
 require (
-	github.com/kr/pretty v0.1.0 // indirect
+	github.com/golang/protobuf v1.5.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	google.golang.org/genproto v0.0.0-20200526211855-cb27e3aa2013 // indirect
 )
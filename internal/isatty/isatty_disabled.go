@@ -7,3 +7,7 @@ package isatty
 func Isatty(fd uintptr) (bool, error) {
 	return true, nil
 }
+
+// Available is false, because this build can't tell whether a file
+// descriptor is a TTY.
+const Available = false
@@ -21,3 +21,7 @@ func Isatty(fd uintptr) (bool, error) {
 	}
 	return result != 0, nil
 }
+
+// Available is true, because this build can tell whether a file
+// descriptor is a TTY.
+const Available = true
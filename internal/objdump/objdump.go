@@ -0,0 +1,141 @@
+// Package objdump writes a record of each object that a scan finds
+// to a file (`git-sizer --dump-objects`), for analysis with other
+// tools.
+package objdump
+
+import (
+	"bufio"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/github/git-sizer/git"
+)
+
+// Writer writes the records of objects in one of the supported
+// formats. It implements `sizes.ObjectDumper`.
+type Writer interface {
+	// DumpObject writes a record of `obj`.
+	DumpObject(obj git.BatchHeader) error
+
+	// Close writes anything that is still buffered. It doesn't
+	// close the underlying `io.Writer`.
+	Close() error
+}
+
+// Record is the form in which the "ndjson" and "gob" formats write
+// each object.
+type Record struct {
+	// OID is the object's name, in hex.
+	OID string `json:"oid"`
+
+	// Type is "blob", "tree", "commit", or "tag".
+	Type string `json:"type"`
+
+	// Size is the size of the object's contents, and DiskSize is the
+	// number of bytes that it occupies in the object database.
+	Size     uint64 `json:"size"`
+	DiskSize uint64 `json:"disk_size"`
+}
+
+func newRecord(obj git.BatchHeader) Record {
+	return Record{
+		OID:      obj.OID.String(),
+		Type:     string(obj.ObjectType),
+		Size:     uint64(obj.ObjectSize),
+		DiskSize: uint64(obj.DiskSize),
+	}
+}
+
+// formats maps the name of each supported format to a function that
+// returns a `Writer` for it.
+var formats = map[string]func(w io.Writer) Writer{
+	"ndjson": newNDJSONWriter,
+	"gob":    newGobWriter,
+}
+
+// Formats returns the names of the formats that are supported by
+// this build of git-sizer, sorted.
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// CheckFormat returns an error if `format` isn't supported by this
+// build of git-sizer.
+func CheckFormat(format string) error {
+	switch _, ok := formats[format]; {
+	case ok:
+		return nil
+	case format == "parquet":
+		return fmt.Errorf(
+			"the 'parquet' format isn't supported by this build of git-sizer " +
+				"(build it with '-tags parquet' to enable it)",
+		)
+	default:
+		return fmt.Errorf(
+			"unknown object dump format %q (supported: %s)",
+			format, strings.Join(Formats(), ", "),
+		)
+	}
+}
+
+// New returns a `Writer` that writes records in `format` to `w`.
+func New(format string, w io.Writer) (Writer, error) {
+	if err := CheckFormat(format); err != nil {
+		return nil, err
+	}
+	return formats[format](w), nil
+}
+
+// ndjsonWriter writes one JSON object per line.
+type ndjsonWriter struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func newNDJSONWriter(w io.Writer) Writer {
+	bw := bufio.NewWriter(w)
+	return &ndjsonWriter{
+		w:   bw,
+		enc: json.NewEncoder(bw),
+	}
+}
+
+func (w *ndjsonWriter) DumpObject(obj git.BatchHeader) error {
+	return w.enc.Encode(newRecord(obj))
+}
+
+func (w *ndjsonWriter) Close() error {
+	return w.w.Flush()
+}
+
+// gobWriter writes a stream of `Record`s encoded with `encoding/gob`.
+type gobWriter struct {
+	w   *bufio.Writer
+	enc *gob.Encoder
+}
+
+func newGobWriter(w io.Writer) Writer {
+	bw := bufio.NewWriter(w)
+	return &gobWriter{
+		w:   bw,
+		enc: gob.NewEncoder(bw),
+	}
+}
+
+func (w *gobWriter) DumpObject(obj git.BatchHeader) error {
+	r := newRecord(obj)
+	return w.enc.Encode(&r)
+}
+
+func (w *gobWriter) Close() error {
+	return w.w.Flush()
+}
@@ -0,0 +1,344 @@
+//go:build parquet
+// +build parquet
+
+package objdump
+
+// This file implements just enough of the Parquet file format to
+// write the object records as uncompressed, plainly-encoded columns,
+// which every Parquet reader understands. It is only compiled with
+// `-tags parquet`, to keep it out of the default builds, which don't
+// need it.
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+
+	"github.com/github/git-sizer/git"
+)
+
+func init() {
+	formats["parquet"] = newParquetWriter
+}
+
+// parquetRowGroupSize is the number of rows that are buffered before
+// they are written as a row group.
+const parquetRowGroupSize = 1 << 18
+
+// Parquet's physical types, repetition types, converted types,
+// encodings, page types, and compression codecs, as used here.
+const (
+	parquetTypeInt64     = 2
+	parquetTypeByteArray = 6
+
+	parquetRequired = 0
+
+	parquetConvertedUTF8 = 0
+
+	parquetEncodingPlain = 0
+	parquetEncodingRLE   = 3
+
+	parquetPageData = 0
+
+	parquetUncompressed = 0
+)
+
+var parquetMagic = []byte("PAR1")
+
+// parquetColumn buffers the plainly-encoded values of one column of
+// the current row group.
+type parquetColumn struct {
+	name          string
+	physicalType  int32
+	convertedUTF8 bool
+	values        bytes.Buffer
+}
+
+func (c *parquetColumn) appendString(s string) {
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(len(s)))
+	c.values.Write(n[:])
+	c.values.WriteString(s)
+}
+
+func (c *parquetColumn) appendInt64(v uint64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], v)
+	c.values.Write(b[:])
+}
+
+// parquetColumnChunk records where a column of a row group was
+// written, for the file's metadata.
+type parquetColumnChunk struct {
+	offset int64
+	size   int64
+}
+
+type parquetRowGroup struct {
+	rowCount int64
+	size     int64
+	chunks   []parquetColumnChunk
+}
+
+// parquetWriter writes the object records to a Parquet file with one
+// column for each field of `Record`.
+type parquetWriter struct {
+	w      *bufio.Writer
+	offset int64
+	err    error
+
+	columns   []*parquetColumn
+	rowCount  int64
+	rowGroups []parquetRowGroup
+	totalRows int64
+}
+
+func newParquetWriter(w io.Writer) Writer {
+	pw := &parquetWriter{
+		w: bufio.NewWriter(w),
+		columns: []*parquetColumn{
+			{name: "oid", physicalType: parquetTypeByteArray, convertedUTF8: true},
+			{name: "type", physicalType: parquetTypeByteArray, convertedUTF8: true},
+			{name: "size", physicalType: parquetTypeInt64},
+			{name: "disk_size", physicalType: parquetTypeInt64},
+		},
+	}
+	pw.write(parquetMagic)
+	return pw
+}
+
+// write writes `p` to the file, keeping track of the offset and of
+// the first error.
+func (w *parquetWriter) write(p []byte) {
+	if w.err != nil {
+		return
+	}
+	n, err := w.w.Write(p)
+	w.offset += int64(n)
+	w.err = err
+}
+
+func (w *parquetWriter) DumpObject(obj git.BatchHeader) error {
+	r := newRecord(obj)
+	w.columns[0].appendString(r.OID)
+	w.columns[1].appendString(r.Type)
+	w.columns[2].appendInt64(r.Size)
+	w.columns[3].appendInt64(r.DiskSize)
+	w.rowCount++
+	if w.rowCount == parquetRowGroupSize {
+		w.flushRowGroup()
+	}
+	return w.err
+}
+
+// flushRowGroup writes the buffered rows as a row group, with one
+// data page per column.
+func (w *parquetWriter) flushRowGroup() {
+	if w.rowCount == 0 {
+		return
+	}
+
+	rg := parquetRowGroup{rowCount: w.rowCount}
+	for _, c := range w.columns {
+		// The columns are required and not nested, so the pages
+		// contain neither repetition nor definition levels; just the
+		// values.
+		var header thriftWriter
+		header.i32(1, parquetPageData)
+		header.i32(2, int32(c.values.Len()))
+		header.i32(3, int32(c.values.Len()))
+		header.structBegin(5)
+		header.i32(1, int32(w.rowCount))
+		header.i32(2, parquetEncodingPlain)
+		header.i32(3, parquetEncodingRLE)
+		header.i32(4, parquetEncodingRLE)
+		header.structEnd()
+		header.stop()
+
+		chunk := parquetColumnChunk{
+			offset: w.offset,
+			size:   int64(header.buf.Len() + c.values.Len()),
+		}
+		w.write(header.buf.Bytes())
+		w.write(c.values.Bytes())
+		c.values.Reset()
+
+		rg.chunks = append(rg.chunks, chunk)
+		rg.size += chunk.size
+	}
+
+	w.rowGroups = append(w.rowGroups, rg)
+	w.totalRows += w.rowCount
+	w.rowCount = 0
+}
+
+func (w *parquetWriter) Close() error {
+	w.flushRowGroup()
+
+	var meta thriftWriter
+	meta.i32(1, 1) // version
+
+	meta.listBegin(2, thriftStruct, 1+len(w.columns))
+	meta.elemBegin()
+	meta.binary(4, []byte("schema"))
+	meta.i32(5, int32(len(w.columns)))
+	meta.elemEnd()
+	for _, c := range w.columns {
+		meta.elemBegin()
+		meta.i32(1, c.physicalType)
+		meta.i32(3, parquetRequired)
+		meta.binary(4, []byte(c.name))
+		if c.convertedUTF8 {
+			meta.i32(6, parquetConvertedUTF8)
+		}
+		meta.elemEnd()
+	}
+
+	meta.i64(3, w.totalRows)
+
+	meta.listBegin(4, thriftStruct, len(w.rowGroups))
+	for _, rg := range w.rowGroups {
+		meta.elemBegin()
+		meta.listBegin(1, thriftStruct, len(rg.chunks))
+		for i, chunk := range rg.chunks {
+			c := w.columns[i]
+			meta.elemBegin()
+			meta.i64(2, chunk.offset)
+			meta.structBegin(3)
+			meta.i32(1, c.physicalType)
+			meta.listBegin(2, thriftI32, 2)
+			meta.varint(zigzag(parquetEncodingPlain))
+			meta.varint(zigzag(parquetEncodingRLE))
+			meta.listBegin(3, thriftBinary, 1)
+			meta.rawBinary([]byte(c.name))
+			meta.i32(4, parquetUncompressed)
+			meta.i64(5, rg.rowCount)
+			meta.i64(6, chunk.size)
+			meta.i64(7, chunk.size)
+			meta.i64(9, chunk.offset)
+			meta.structEnd()
+			meta.elemEnd()
+		}
+		meta.i64(2, rg.size)
+		meta.i64(3, rg.rowCount)
+		meta.elemEnd()
+	}
+
+	meta.binary(6, []byte("git-sizer"))
+	meta.stop()
+
+	w.write(meta.buf.Bytes())
+	var n [4]byte
+	binary.LittleEndian.PutUint32(n[:], uint32(meta.buf.Len()))
+	w.write(n[:])
+	w.write(parquetMagic)
+	if w.err != nil {
+		return w.err
+	}
+	return w.w.Flush()
+}
+
+// The types of the Thrift compact protocol that are used here.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes a struct using the Thrift compact protocol,
+// which Parquet uses for its metadata. The caller is responsible for
+// writing the fields of each struct in increasing order.
+type thriftWriter struct {
+	buf bytes.Buffer
+
+	// lastField is the ID of the field that was written last in
+	// the current struct, and outer holds those of the enclosing
+	// structs.
+	lastField int16
+	outer     []int16
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+func (t *thriftWriter) varint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	t.buf.Write(b[:binary.PutUvarint(b[:], v)])
+}
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - t.lastField; delta > 0 && delta <= 15 {
+		t.buf.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.buf.WriteByte(typ)
+		t.varint(zigzag(int64(id)))
+	}
+	t.lastField = id
+}
+
+func (t *thriftWriter) i32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.varint(zigzag(int64(v)))
+}
+
+func (t *thriftWriter) i64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.varint(zigzag(v))
+}
+
+func (t *thriftWriter) binary(id int16, v []byte) {
+	t.fieldHeader(id, thriftBinary)
+	t.rawBinary(v)
+}
+
+// rawBinary writes `v` without a field header, as an element of a
+// list.
+func (t *thriftWriter) rawBinary(v []byte) {
+	t.varint(uint64(len(v)))
+	t.buf.Write(v)
+}
+
+// listBegin starts a list field with `n` elements of type `elemType`.
+// The elements follow, without field headers.
+func (t *thriftWriter) listBegin(id int16, elemType byte, n int) {
+	t.fieldHeader(id, thriftList)
+	if n < 15 {
+		t.buf.WriteByte(byte(n)<<4 | elemType)
+	} else {
+		t.buf.WriteByte(0xf0 | elemType)
+		t.varint(uint64(n))
+	}
+}
+
+// structBegin starts a struct field, whose fields follow.
+func (t *thriftWriter) structBegin(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.elemBegin()
+}
+
+// structEnd ends a struct that was started by `structBegin()`.
+func (t *thriftWriter) structEnd() {
+	t.elemEnd()
+}
+
+// elemBegin starts a struct that is an element of a list.
+func (t *thriftWriter) elemBegin() {
+	t.outer = append(t.outer, t.lastField)
+	t.lastField = 0
+}
+
+// elemEnd ends a struct that was started by `elemBegin()`.
+func (t *thriftWriter) elemEnd() {
+	t.stop()
+	t.lastField = t.outer[len(t.outer)-1]
+	t.outer = t.outer[:len(t.outer)-1]
+}
+
+// stop ends the outermost struct.
+func (t *thriftWriter) stop() {
+	t.buf.WriteByte(0)
+}
@@ -0,0 +1,256 @@
+//go:build parquet
+// +build parquet
+
+package objdump
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// thriftReader decodes structs encoded with the Thrift compact
+// protocol into generic values, independently of `thriftWriter`, so
+// that the files can be checked against the format rather than
+// against the code that wrote them. Integers are decoded to `int64`,
+// binaries to `[]byte`, lists to `[]interface{}`, and structs to
+// `thriftFields`, keyed by field ID.
+type thriftReader struct {
+	buf *bytes.Reader
+}
+
+type thriftFields map[int16]interface{}
+
+func (r *thriftReader) varint() (uint64, error) {
+	return binary.ReadUvarint(r.buf)
+}
+
+func (r *thriftReader) zigzag() (int64, error) {
+	v, err := r.varint()
+	return int64(v>>1) ^ -int64(v&1), err
+}
+
+func (r *thriftReader) value(typ byte) (interface{}, error) {
+	switch typ {
+	case thriftI32, thriftI64:
+		return r.zigzag()
+	case thriftBinary:
+		n, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		b := make([]byte, n)
+		if _, err := io.ReadFull(r.buf, b); err != nil {
+			return nil, err
+		}
+		return b, nil
+	case thriftList:
+		header, err := r.buf.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		n := uint64(header >> 4)
+		if n == 15 {
+			if n, err = r.varint(); err != nil {
+				return nil, err
+			}
+		}
+		elems := make([]interface{}, n)
+		for i := range elems {
+			if elems[i], err = r.value(header & 0x0f); err != nil {
+				return nil, err
+			}
+		}
+		return elems, nil
+	case thriftStruct:
+		return r.structValue()
+	default:
+		return nil, fmt.Errorf("unexpected Thrift type %d", typ)
+	}
+}
+
+func (r *thriftReader) structValue() (thriftFields, error) {
+	fields := make(thriftFields)
+	var id int16
+	for {
+		header, err := r.buf.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if header == 0 {
+			return fields, nil
+		}
+		if delta := int16(header >> 4); delta != 0 {
+			id += delta
+		} else {
+			v, err := r.zigzag()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(v)
+		}
+		if fields[id], err = r.value(header & 0x0f); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func TestParquet(t *testing.T) {
+	t.Parallel()
+
+	oid := func(s string) git.OID {
+		t.Helper()
+		oid, err := git.NewOID(s)
+		require.NoError(t, err)
+		return oid
+	}
+	headers := []git.BatchHeader{
+		{
+			OID:        oid("af5626b4a114abcb82d63db7c8082c3c4756e51b"),
+			ObjectType: "blob",
+			ObjectSize: 14,
+			DiskSize:   32,
+		},
+		{
+			OID:        oid("4b825dc642cb6eb9a060e54bf8d69288fbee4904"),
+			ObjectType: "tree",
+			ObjectSize: 0,
+			DiskSize:   counts.Count64(1) << 40,
+		},
+	}
+
+	var buf bytes.Buffer
+	w := newParquetWriter(&buf)
+	for _, header := range headers {
+		require.NoError(t, w.DumpObject(header))
+	}
+	require.NoError(t, w.Close())
+	file := buf.Bytes()
+
+	// The file starts and ends with the magic bytes, and the footer's
+	// length precedes the trailing ones:
+	require.Greater(t, len(file), 12)
+	assert.Equal(t, "PAR1", string(file[:4]))
+	assert.Equal(t, "PAR1", string(file[len(file)-4:]))
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	footerStart := len(file) - 8 - footerLen
+	require.GreaterOrEqual(t, footerStart, 4)
+
+	footer := &thriftReader{bytes.NewReader(file[footerStart : len(file)-8])}
+	meta, err := footer.structValue()
+	require.NoError(t, err)
+	assert.Zero(t, footer.buf.Len(), "trailing bytes after the metadata")
+
+	assert.Equal(t, int64(1), meta[1], "version")
+	assert.Equal(t, int64(len(headers)), meta[3], "num_rows")
+	assert.Equal(t, []byte("git-sizer"), meta[6], "created_by")
+
+	// The schema is a root element with four required columns:
+	type column struct {
+		name          string
+		physicalType  int64
+		convertedType interface{}
+	}
+	expectedColumns := []column{
+		{"oid", parquetTypeByteArray, int64(parquetConvertedUTF8)},
+		{"type", parquetTypeByteArray, int64(parquetConvertedUTF8)},
+		{"size", parquetTypeInt64, nil},
+		{"disk_size", parquetTypeInt64, nil},
+	}
+	schema := meta[2].([]interface{})
+	require.Len(t, schema, 1+len(expectedColumns))
+	root := schema[0].(thriftFields)
+	assert.Equal(t, []byte("schema"), root[4])
+	assert.Equal(t, int64(len(expectedColumns)), root[5], "num_children")
+	for i, expected := range expectedColumns {
+		element := schema[1+i].(thriftFields)
+		assert.Equal(t, []byte(expected.name), element[4], "name")
+		assert.Equal(t, expected.physicalType, element[1], "type of %s", expected.name)
+		assert.Equal(t, int64(parquetRequired), element[3], "repetition of %s", expected.name)
+		assert.Equal(t, expected.convertedType, element[6], "converted type of %s", expected.name)
+	}
+
+	// Read the values back through the column chunks of the only row
+	// group:
+	rowGroups := meta[4].([]interface{})
+	require.Len(t, rowGroups, 1)
+	rowGroup := rowGroups[0].(thriftFields)
+	assert.Equal(t, int64(len(headers)), rowGroup[3], "row group num_rows")
+
+	chunks := rowGroup[1].([]interface{})
+	require.Len(t, chunks, len(expectedColumns))
+	var totalSize int64
+	values := make([][]interface{}, len(expectedColumns))
+	for i, c := range chunks {
+		expected := expectedColumns[i]
+		chunkMeta := c.(thriftFields)[3].(thriftFields)
+		assert.Equal(t, expected.physicalType, chunkMeta[1], "chunk type of %s", expected.name)
+		assert.Equal(t, []interface{}{[]byte(expected.name)}, chunkMeta[3], "path of %s", expected.name)
+		assert.Equal(t, int64(parquetUncompressed), chunkMeta[4], "codec of %s", expected.name)
+		assert.Equal(t, int64(len(headers)), chunkMeta[5], "num_values of %s", expected.name)
+		assert.Equal(t, chunkMeta[6], chunkMeta[7], "sizes of %s", expected.name)
+
+		offset := chunkMeta[9].(int64)
+		size := chunkMeta[7].(int64)
+		totalSize += size
+		require.LessOrEqual(t, offset+size, int64(footerStart))
+
+		page := &thriftReader{bytes.NewReader(file[offset : offset+size])}
+		pageHeader, err := page.structValue()
+		require.NoError(t, err)
+		assert.Equal(t, int64(parquetPageData), pageHeader[1], "page type of %s", expected.name)
+		assert.Equal(t, int64(page.buf.Len()), pageHeader[2], "uncompressed page size of %s", expected.name)
+		assert.Equal(t, pageHeader[2], pageHeader[3], "page sizes of %s", expected.name)
+		dataPageHeader := pageHeader[5].(thriftFields)
+		assert.Equal(t, int64(len(headers)), dataPageHeader[1], "page num_values of %s", expected.name)
+		assert.Equal(t, int64(parquetEncodingPlain), dataPageHeader[2], "encoding of %s", expected.name)
+
+		for range headers {
+			switch expected.physicalType {
+			case parquetTypeByteArray:
+				var n uint32
+				require.NoError(t, binary.Read(page.buf, binary.LittleEndian, &n))
+				v := make([]byte, n)
+				_, err := io.ReadFull(page.buf, v)
+				require.NoError(t, err)
+				values[i] = append(values[i], string(v))
+			case parquetTypeInt64:
+				var v uint64
+				require.NoError(t, binary.Read(page.buf, binary.LittleEndian, &v))
+				values[i] = append(values[i], v)
+			}
+		}
+		assert.Zero(t, page.buf.Len(), "trailing bytes in the page of %s", expected.name)
+	}
+	assert.Equal(t, totalSize, rowGroup[2], "row group total_byte_size")
+
+	for j, header := range headers {
+		r := newRecord(header)
+		assert.Equal(t, []interface{}{r.OID, r.Type, r.Size, r.DiskSize}, []interface{}{
+			values[0][j], values[1][j], values[2][j], values[3][j],
+		})
+	}
+}
+
+func TestParquetEmpty(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	require.NoError(t, newParquetWriter(&buf).Close())
+	file := buf.Bytes()
+
+	footerLen := int(binary.LittleEndian.Uint32(file[len(file)-8:]))
+	require.Equal(t, len(file), 4+footerLen+8)
+	meta, err := (&thriftReader{bytes.NewReader(file[4 : 4+footerLen])}).structValue()
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), meta[3], "num_rows")
+	assert.Empty(t, meta[4], "row groups")
+}
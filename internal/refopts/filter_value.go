@@ -1,10 +1,8 @@
 package refopts
 
 import (
-	"errors"
 	"fmt"
 	"strconv"
-	"strings"
 
 	"github.com/github/git-sizer/git"
 	"github.com/github/git-sizer/sizes"
@@ -61,49 +59,17 @@ func (v *filterValue) Set(s string) error {
 		}
 	} else {
 		var err error
-		filter, err = v.interpretFlexibly(pattern)
+		filter, err = v.rgb.ParseFilter(pattern)
 		if err != nil {
 			return err
 		}
 	}
 
-	v.rgb.topLevelGroup.filter = combiner.Combine(v.rgb.topLevelGroup.filter, filter)
+	v.rgb.AddFilter(combiner, filter)
 
 	return nil
 }
 
-// Interpret an option argument flexibly:
-//
-// * If it is bracketed with `/` characters, treat it as a regexp.
-//
-// * If it starts with `@`, then consider it a refgroup name. That
-//   refgroup must already be defined. Use its filter. This construct
-//   is only allowed at the top level.
-//
-// * Otherwise treat it as a prefix.
-func (v *filterValue) interpretFlexibly(s string) (git.ReferenceFilter, error) {
-	if len(s) >= 2 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/") {
-		pattern := s[1 : len(s)-1]
-		return git.RegexpFilter(pattern)
-	}
-
-	if len(s) >= 1 && s[0] == '@' {
-		name := sizes.RefGroupSymbol(s[1:])
-		if name == "" {
-			return nil, errors.New("missing refgroup name")
-		}
-
-		refGroup := v.rgb.groups[name]
-		if refGroup == nil {
-			return nil, fmt.Errorf("undefined refgroup '%s'", name)
-		}
-
-		return refGroupFilter{refGroup}, nil
-	}
-
-	return git.PrefixFilter(s), nil
-}
-
 func (v *filterValue) Get() interface{} {
 	return nil
 }
@@ -122,3 +88,33 @@ func (v *filterValue) Type() string {
 		return "prefix"
 	}
 }
+
+// filterGroupValue handles `--refgroup=REFGROUP` options, which
+// include the references that the refgroup would match (see
+// `refgroups.Builder.GroupFilter()`) in the top-level filter.
+type filterGroupValue struct {
+	rgb *RefGroupBuilder
+}
+
+func (v *filterGroupValue) Set(symbolString string) error {
+	filter, err := v.rgb.GroupFilter(sizes.RefGroupSymbol(symbolString))
+	if err != nil {
+		return err
+	}
+
+	v.rgb.AddFilter(git.Include, filter)
+
+	return nil
+}
+
+func (v *filterGroupValue) Get() interface{} {
+	return nil
+}
+
+func (v *filterGroupValue) String() string {
+	return ""
+}
+
+func (v *filterGroupValue) Type() string {
+	return "name"
+}
@@ -32,3 +32,31 @@ func (rg showRefGrouper) Categorize(refname string) (bool, []sizes.RefGroupSymbo
 	}
 	return walk, symbols
 }
+
+// ShowRef is how a reference is listed by `--show-refs=json`.
+type ShowRef struct {
+	Refname  string                 `json:"refname"`
+	Included bool                   `json:"included"`
+	Groups   []sizes.RefGroupSymbol `json:"groups"`
+}
+
+// ShowRefs returns a `ShowRef` for each of `refRoots`, in the same
+// order. The top-level refgroup, whose symbol is empty, is omitted
+// from the groups.
+func ShowRefs(refRoots []sizes.RefRoot) []ShowRef {
+	refs := make([]ShowRef, len(refRoots))
+	for i, refRoot := range refRoots {
+		groups := []sizes.RefGroupSymbol{}
+		for _, group := range refRoot.Groups() {
+			if group != "" {
+				groups = append(groups, group)
+			}
+		}
+		refs[i] = ShowRef{
+			Refname:  refRoot.Name(),
+			Included: refRoot.Walk(),
+			Groups:   groups,
+		}
+	}
+	return refs
+}
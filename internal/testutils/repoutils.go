@@ -17,16 +17,25 @@ import (
 	"github.com/github/git-sizer/git"
 )
 
+// DefaultTimestamp is the date of the first commit or tag that is
+// created in a `TestRepo` using `AuthoredCommand()` or `Commit()`.
+var DefaultTimestamp = time.Unix(1112911993, 0)
+
 // TestRepo represents a git repository used for tests.
 type TestRepo struct {
 	Path string
 	bare bool
+
+	// timestamp, if set, is the date of the next commit or tag that
+	// is created using `AuthoredCommand()`. If it is zero,
+	// `DefaultTimestamp` is used.
+	timestamp time.Time
 }
 
 // NewTestRepo creates and initializes a test repository in a
 // temporary directory constructed using `pattern`. The caller must
 // delete the repository by calling `repo.Remove()`.
-func NewTestRepo(t *testing.T, bare bool, pattern string) *TestRepo {
+func NewTestRepo(t testing.TB, bare bool, pattern string) *TestRepo {
 	t.Helper()
 
 	path, err := os.MkdirTemp("", pattern)
@@ -43,7 +52,7 @@ func NewTestRepo(t *testing.T, bare bool, pattern string) *TestRepo {
 }
 
 // Init initializes a git repository at `repo.Path`.
-func (repo *TestRepo) Init(t *testing.T, bare bool) {
+func (repo *TestRepo) Init(t testing.TB, bare bool) {
 	t.Helper()
 
 	// Don't use `GitCommand()` because the directory might not
@@ -62,7 +71,7 @@ func (repo *TestRepo) Init(t *testing.T, bare bool) {
 }
 
 // Remove deletes the test repository at `repo.Path`.
-func (repo *TestRepo) Remove(t *testing.T) {
+func (repo *TestRepo) Remove(t testing.TB) {
 	t.Helper()
 
 	_ = os.RemoveAll(repo.Path)
@@ -71,7 +80,7 @@ func (repo *TestRepo) Remove(t *testing.T) {
 // Clone creates a clone of `repo` at a temporary path constructued
 // using `pattern`. The caller is responsible for removing it when
 // done by calling `Remove()`.
-func (repo *TestRepo) Clone(t *testing.T, pattern string) *TestRepo {
+func (repo *TestRepo) Clone(t testing.TB, pattern string) *TestRepo {
 	t.Helper()
 
 	path, err := os.MkdirTemp("", pattern)
@@ -88,7 +97,7 @@ func (repo *TestRepo) Clone(t *testing.T, pattern string) *TestRepo {
 }
 
 // Repository returns a `*git.Repository` for `repo`.
-func (repo *TestRepo) Repository(t *testing.T) *git.Repository {
+func (repo *TestRepo) Repository(t testing.TB) *git.Repository {
 	t.Helper()
 
 	if repo.bare {
@@ -147,7 +156,7 @@ func CleanGitEnv() []string {
 
 // GitCommand creates an `*exec.Cmd` for running `git` in `repo` with
 // the specified arguments.
-func (repo *TestRepo) GitCommand(t *testing.T, args ...string) *exec.Cmd {
+func (repo *TestRepo) GitCommand(t testing.TB, args ...string) *exec.Cmd {
 	t.Helper()
 
 	gitArgs := []string{"-C", repo.Path}
@@ -159,8 +168,40 @@ func (repo *TestRepo) GitCommand(t *testing.T, args ...string) *exec.Cmd {
 	return cmd
 }
 
+// AuthoredCommand is like `GitCommand()`, but for commands that create
+// commits or annotated tags. It sets their author, committer, and
+// tagger to known values (see `AddAuthorInfo()`), and dates them a
+// minute after the previous command that was created this way for
+// `repo` (see `SetTimestamp()`), so that their object names are
+// reproducible.
+func (repo *TestRepo) AuthoredCommand(t testing.TB, args ...string) *exec.Cmd {
+	t.Helper()
+
+	if repo.timestamp.IsZero() {
+		repo.timestamp = DefaultTimestamp
+	}
+	cmd := repo.GitCommand(t, args...)
+	AddAuthorInfo(cmd, &repo.timestamp)
+	return cmd
+}
+
+// Commit runs `git commit` with the specified arguments in `repo`,
+// using `AuthoredCommand()`.
+func (repo *TestRepo) Commit(t testing.TB, args ...string) {
+	t.Helper()
+
+	cmd := repo.AuthoredCommand(t, append([]string{"commit"}, args...)...)
+	require.NoError(t, cmd.Run(), "creating commit")
+}
+
+// SetTimestamp sets the date of the next commit or tag that is
+// created using `AuthoredCommand()`.
+func (repo *TestRepo) SetTimestamp(timestamp time.Time) {
+	repo.timestamp = timestamp
+}
+
 // UpdateRef updates the reference named `refname` to the value `oid`.
-func (repo *TestRepo) UpdateRef(t *testing.T, refname string, oid git.OID) {
+func (repo *TestRepo) UpdateRef(t testing.TB, refname string, oid git.OID) {
 	t.Helper()
 
 	var cmd *exec.Cmd
@@ -177,7 +218,7 @@ func (repo *TestRepo) UpdateRef(t *testing.T, refname string, oid git.OID) {
 // the repository at `repoPath`. `writer` is a function that generates
 // the object contents in `git hash-object` input format.
 func (repo *TestRepo) CreateObject(
-	t *testing.T, otype git.ObjectType, writer func(io.Writer) error,
+	t testing.TB, otype git.ObjectType, writer func(io.Writer) error,
 ) git.OID {
 	t.Helper()
 
@@ -216,7 +257,7 @@ func (repo *TestRepo) CreateObject(
 // AddFile adds and stages a file in `repo` at path `relativePath`
 // with the specified `contents`. This must be run in a non-bare
 // repository.
-func (repo *TestRepo) AddFile(t *testing.T, relativePath, contents string) {
+func (repo *TestRepo) AddFile(t testing.TB, relativePath, contents string) {
 	t.Helper()
 
 	dirPath := filepath.Dir(relativePath)
@@ -242,7 +283,7 @@ func (repo *TestRepo) AddFile(t *testing.T, relativePath, contents string) {
 // CreateReferencedOrphan creates a simple new orphan commit and
 // points the reference with name `refname` at it. This can be run in
 // a bare or non-bare repository.
-func (repo *TestRepo) CreateReferencedOrphan(t *testing.T, refname string) {
+func (repo *TestRepo) CreateReferencedOrphan(t testing.TB, refname string) {
 	t.Helper()
 
 	oid := repo.CreateObject(t, "blob", func(w io.Writer) error {
@@ -288,7 +329,7 @@ func AddAuthorInfo(cmd *exec.Cmd, timestamp *time.Time) {
 }
 
 // ConfigAdd adds a key-value pair to the gitconfig in `repo`.
-func (repo *TestRepo) ConfigAdd(t *testing.T, key, value string) {
+func (repo *TestRepo) ConfigAdd(t testing.TB, key, value string) {
 	t.Helper()
 
 	err := repo.GitCommand(t, "config", "--add", key, value).Run()
@@ -0,0 +1,52 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/cli/safeexec"
+
+	"github.com/github/git-sizer/git"
+)
+
+// Page shows `text` to the user using the pager that Git is
+// configured to use (see `git var GIT_PAGER`), so that long results
+// can be scrolled. The pager writes to `w`. If no pager is
+// configured, or it can't be run, `text` is written to `w` directly.
+func Page(ctx context.Context, repo *git.Repository, w io.Writer, text string) error {
+	out, err := repo.GitCommandContext(ctx, "var", "GIT_PAGER").Output()
+	pager := strings.TrimSpace(string(out))
+	if err != nil || pager == "" || pager == "cat" {
+		_, err := io.WriteString(w, text)
+		return err
+	}
+
+	// Like Git, run the pager using the shell:
+	sh, err := safeexec.LookPath("sh")
+	if err != nil {
+		_, err := io.WriteString(w, text)
+		return err
+	}
+
+	cmd := exec.CommandContext(ctx, sh, "-c", pager)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = w
+	cmd.Stderr = os.Stderr
+	// Use the same defaults as Git, so that `less` exits right away
+	// if the text fits on one screen and passes colors through:
+	cmd.Env = os.Environ()
+	if _, ok := os.LookupEnv("LESS"); !ok {
+		cmd.Env = append(cmd.Env, "LESS=FRX")
+	}
+	if _, ok := os.LookupEnv("LV"); !ok {
+		cmd.Env = append(cmd.Env, "LV=-c")
+	}
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("running pager '%s': %w", pager, err)
+	}
+	return nil
+}
@@ -0,0 +1,243 @@
+// Package tui implements the live display of `git-sizer --tui`: a
+// dashboard that is redrawn in place on a terminal while a scan is
+// running, and a pager for the results.
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+	"github.com/github/git-sizer/sizes"
+)
+
+const (
+	// progressBarWidth is the number of characters in the progress
+	// bar of a phase whose total is known.
+	progressBarWidth = 10
+
+	// maxShownPhases is the number of finished phases that are
+	// shown, in addition to the current one.
+	maxShownPhases = 8
+
+	// maxPhaseNameWidth is the number of characters of the name of
+	// a phase that are shown. Lines have to fit in the width of the
+	// terminal, because otherwise they wrap and can't be redrawn in
+	// place.
+	maxPhaseNameWidth = 40
+)
+
+// phase records the progress of one phase of the scan.
+type phase struct {
+	meter.Update
+	start time.Time
+	end   time.Time
+}
+
+// Dashboard shows the progress of a scan and the statistics collected
+// so far, redrawing itself in place on a terminal.
+type Dashboard struct {
+	lock  sync.Mutex
+	w     io.Writer
+	title string
+	live  *sizes.LiveStats
+	start time.Time
+
+	// phases lists the phases of the scan, the last one being the
+	// current one.
+	phases []phase
+
+	// lineCount is the number of lines drawn last time, which have
+	// to be overwritten when the dashboard is redrawn.
+	lineCount int
+
+	stop    chan struct{}
+	stopped chan struct{}
+}
+
+// New returns a `Dashboard` that draws itself on `w`, which should be
+// a terminal that understands ANSI escape sequences. `title` is shown
+// at the top. If `live` is non-nil, it is used to show the statistics
+// collected so far.
+func New(w io.Writer, title string, live *sizes.LiveStats) *Dashboard {
+	return &Dashboard{
+		w:     w,
+		title: title,
+		live:  live,
+	}
+}
+
+// Progress returns a `meter.Progress` that reports to `d`.
+func (d *Dashboard) Progress() meter.Progress {
+	return meter.NewCallbackProgress(d.update, 100*time.Millisecond)
+}
+
+// update records the progress reported by the scan.
+func (d *Dashboard) update(u meter.Update) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	now := time.Now()
+	n := len(d.phases)
+	if n == 0 || !d.phases[n-1].end.IsZero() {
+		d.phases = append(d.phases, phase{Update: u, start: now})
+		n++
+	}
+	p := &d.phases[n-1]
+	p.Update = u
+	if u.Done {
+		p.end = now
+	}
+}
+
+// Start starts redrawing the dashboard every `period`.
+func (d *Dashboard) Start(period time.Duration) {
+	d.start = time.Now()
+	d.stop = make(chan struct{})
+	d.stopped = make(chan struct{})
+
+	go func() {
+		defer close(d.stopped)
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			d.redraw()
+			select {
+			case <-ticker.C:
+			case <-d.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops redrawing the dashboard, after drawing it one last time.
+// The final state is left on the terminal.
+func (d *Dashboard) Stop() {
+	close(d.stop)
+	<-d.stopped
+	d.redraw()
+}
+
+// redraw overwrites the previous rendering of the dashboard with the
+// current one.
+func (d *Dashboard) redraw() {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	buf := &bytes.Buffer{}
+	if d.lineCount > 0 {
+		// Move to the start of the first line drawn last time, and
+		// clear everything below it:
+		fmt.Fprintf(buf, "\x1b[%dF\x1b[J", d.lineCount)
+	}
+	lines := d.render(time.Now())
+	for _, line := range lines {
+		fmt.Fprintf(buf, "%s\n", line)
+	}
+	d.lineCount = len(lines)
+	_, _ = d.w.Write(buf.Bytes())
+}
+
+// render returns the lines of the dashboard as of `now`. `d.lock`
+// must be held.
+func (d *Dashboard) render(now time.Time) []string {
+	lines := []string{
+		d.title,
+		fmt.Sprintf("Elapsed: %s", now.Sub(d.start).Round(100*time.Millisecond)),
+		"",
+	}
+
+	phases := d.phases
+	if len(phases) > maxShownPhases+1 {
+		lines = append(lines, fmt.Sprintf("  ... %d earlier phases", len(phases)-maxShownPhases-1))
+		phases = phases[len(phases)-maxShownPhases-1:]
+	}
+	for _, p := range phases {
+		lines = append(lines, renderPhase(p, now))
+	}
+
+	if d.live == nil {
+		return lines
+	}
+	snapshot, ok := d.live.Snapshot()
+	if !ok {
+		return lines
+	}
+	lines = append(lines,
+		"",
+		fmt.Sprintf(
+			"Found so far: %s commits, %s trees, %s blobs (%s), %s tags",
+			formatCount(snapshot.UniqueCommitCount), formatCount(snapshot.UniqueTreeCount),
+			formatCount(snapshot.UniqueBlobCount), formatBytes(snapshot.UniqueBlobSize),
+			formatCount(snapshot.UniqueTagCount),
+		),
+	)
+	for _, o := range []struct {
+		name, value string
+		oid         git.OID
+	}{
+		{"Biggest blob", formatBytes(snapshot.MaxBlobSize), snapshot.MaxBlobSizeBlob},
+		{"Biggest tree", formatCount(snapshot.MaxTreeEntries) + " entries", snapshot.MaxTreeEntriesTree},
+		{"Biggest commit", formatBytes(snapshot.MaxCommitSize), snapshot.MaxCommitSizeCommit},
+	} {
+		if o.oid != git.NullOID {
+			lines = append(lines, fmt.Sprintf("  %-16s %-16s %s", o.name+":", o.value, o.oid))
+		}
+	}
+	return lines
+}
+
+// renderPhase returns a line describing the progress of `p`.
+func renderPhase(p phase, now time.Time) string {
+	marker := ">"
+	end := now
+	if !p.end.IsZero() {
+		marker = "✓"
+		end = p.end
+	}
+
+	progress := formatCount(counts.NewCount64(uint64(p.Count)))
+	if p.Total >= 0 {
+		progress = fmt.Sprintf(
+			"%s / %s %s",
+			progress, formatCount(counts.NewCount64(uint64(p.Total))), progressBar(p.Count, p.Total),
+		)
+	}
+	name := p.Phase
+	if len(name) > maxPhaseNameWidth {
+		name = name[:maxPhaseNameWidth-3] + "..."
+	}
+	return fmt.Sprintf(
+		"%s %-*s %-26s %s",
+		marker, maxPhaseNameWidth, name, progress, end.Sub(p.start).Round(100*time.Millisecond),
+	)
+}
+
+// progressBar returns a bar showing the fraction `count / total`.
+func progressBar(count, total int64) string {
+	filled := progressBarWidth
+	if total > 0 && count < total {
+		filled = int(count * progressBarWidth / total)
+	}
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", progressBarWidth-filled) + "]"
+}
+
+func formatCount(n counts.Humanable) string {
+	value, unit := counts.Metric.Format(n, "")
+	if unit == "" {
+		return value
+	}
+	return value + " " + unit
+}
+
+func formatBytes(n counts.Humanable) string {
+	value, unit := counts.Binary.Format(n, "B")
+	return value + " " + unit
+}
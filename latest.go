@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultLatestReleaseURL is the URL that `--check-latest` queries
+// for the latest release, unless another one is configured.
+const defaultLatestReleaseURL = "https://api.github.com/repos/github/git-sizer/releases/latest"
+
+// latestReleaseTimeout bounds how long `--check-latest` waits for a
+// response.
+const latestReleaseTimeout = 10 * time.Second
+
+// fetchLatestRelease queries `url` for the version number of the
+// latest release. The response can either be a JSON object with a
+// "tag_name" field (like that returned by the GitHub releases API) or
+// a plain-text version number. Proxies are configured via the usual
+// `HTTPS_PROXY`, `HTTP_PROXY`, and `NO_PROXY` environment variables.
+func fetchLatestRelease(ctx context.Context, url string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, latestReleaseTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json, text/plain")
+
+	client := &http.Client{
+		Transport: &http.Transport{Proxy: http.ProxyFromEnvironment},
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", fmt.Errorf("reading response from %s: %w", url, err)
+	}
+
+	var release struct {
+		TagName string `json:"tag_name"`
+	}
+	latest := strings.TrimSpace(string(body))
+	if err := json.Unmarshal(body, &release); err == nil {
+		latest = release.TagName
+	}
+	if _, ok := parseVersion(latest); !ok {
+		return "", fmt.Errorf("%s: unexpected response %q", url, truncate(latest, 40))
+	}
+	return latest, nil
+}
+
+// parseVersion parses a version number like "1.5.0" or "v1.5.0" into
+// its numeric components. Anything following the numeric components,
+// like the "-3-gabcdef" that `git describe` appends, is ignored. The
+// second return value is false if `s` doesn't start with a version
+// number.
+func parseVersion(s string) ([]int, bool) {
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(s, "-+ "); i != -1 {
+		s = s[:i]
+	}
+	if s == "" {
+		return nil, false
+	}
+
+	var parts []int
+	for _, word := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(word)
+		if err != nil || n < 0 {
+			return nil, false
+		}
+		parts = append(parts, n)
+	}
+	return parts, true
+}
+
+// compareVersions returns a negative number, zero, or a positive
+// number if `a` is older than, the same as, or newer than `b`.
+// Missing trailing components count as zero.
+func compareVersions(a, b []int) int {
+	for i := 0; i < len(a) || i < len(b); i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			return x - y
+		}
+	}
+	return 0
+}
+
+// checkLatest reports to `w` whether `localVersion` is older than the
+// latest release, as reported by `url`. `localVersion` can be empty
+// if the version of this build is unknown.
+func checkLatest(ctx context.Context, w io.Writer, url, localVersion string) error {
+	latest, err := fetchLatestRelease(ctx, url)
+	if err != nil {
+		return fmt.Errorf("checking for the latest release: %w", err)
+	}
+
+	latestParts, _ := parseVersion(latest)
+	localParts, ok := parseVersion(localVersion)
+	switch {
+	case !ok:
+		fmt.Fprintf(
+			w, "the latest release of git-sizer is %s; the version of this build is unknown\n",
+			latest,
+		)
+	case compareVersions(localParts, latestParts) < 0:
+		fmt.Fprintf(
+			w, "git-sizer %s is outdated; the latest release is %s\n",
+			localVersion, latest,
+		)
+	default:
+		fmt.Fprintf(
+			w, "git-sizer %s is up to date (the latest release is %s)\n",
+			localVersion, latest,
+		)
+	}
+	return nil
+}
+
+// truncate returns `s`, shortened to at most `n` bytes.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
@@ -0,0 +1,170 @@
+package meter
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Update describes the progress of an operation, as reported to a
+// `Callback`.
+type Update struct {
+	// Phase describes what is being done (e.g., "Processing
+	// trees").
+	Phase string
+
+	// Count is the number of items processed so far in this phase.
+	Count int64
+
+	// Total is the number of items that this phase will process in
+	// all, or -1 if that is not known.
+	Total int64
+
+	// Done is true for the last update of each phase.
+	Done bool
+
+	// Rate is the number of items per second that the phase has been
+	// processing over the last few seconds (for the last update of a
+	// phase, on average over the whole phase), or 0 if it isn't known
+	// yet. ByteRate is the same for the bytes of data that the phase
+	// has processed, if it reports them via `AddBytes()`.
+	Rate     float64
+	ByteRate float64
+}
+
+// Callback is a function that is told about the progress of an
+// operation by the `Progress` returned by `NewCallbackProgress()`.
+type Callback func(Update)
+
+// Totaler is implemented by `Progress`es that can make use of the
+// number of items that the current phase will process; e.g., to show
+// a progress bar.
+type Totaler interface {
+	SetTotal(total int64)
+}
+
+// SetTotal tells `p` that the phase that was just started will
+// process `total` items, if `p` can make use of that information.
+func SetTotal(p Progress, total int64) {
+	if t, ok := p.(Totaler); ok {
+		t.SetTotal(total)
+	}
+}
+
+// callbackProgress is a `Progress` that reports the current state
+// every `period` to a `Callback`.
+type callbackProgress struct {
+	// `lock` is held while `callback` is called, so that it is never
+	// called concurrently.
+	lock       sync.Mutex
+	callback   Callback
+	period     time.Duration
+	phase      string
+	lastCount  int64
+	lastRate   float64
+	throughput throughput
+	// When `ticker` is changed, that tells the old goroutine that
+	// it's time to shut down.
+	ticker *time.Ticker
+
+	// `count`, `bytes`, and `total` are updated atomically:
+	count int64
+	bytes int64
+	total int64
+}
+
+// NewCallbackProgress returns a progress meter that calls `callback`
+// when each phase starts and ends and, in between, every `period` if
+// the count has changed (or, if it has stopped changing, until the
+// rate has dropped to zero). This can be used to show progress in a
+// graphical or other user interface. `callback` is called from
+// another goroutine, but never concurrently with itself.
+func NewCallbackProgress(callback Callback, period time.Duration) Progress {
+	return &callbackProgress{
+		callback: callback,
+		period:   period,
+	}
+}
+
+// phaseName returns the description of the phase that `format` (the
+// argument of `Progress.Start()`) reports on.
+func phaseName(format string) string {
+	phase := strings.TrimSpace(strings.Replace(format, "%d", "", 1))
+	return strings.TrimSpace(strings.TrimSuffix(phase, ":"))
+}
+
+func (p *callbackProgress) Start(format string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.phase = phaseName(format)
+	atomic.StoreInt64(&p.count, 0)
+	atomic.StoreInt64(&p.bytes, 0)
+	atomic.StoreInt64(&p.total, -1)
+	p.lastCount = 0
+	p.lastRate = 0
+	p.throughput.reset(time.Now())
+	p.callback(Update{Phase: p.phase, Total: -1})
+
+	ticker := time.NewTicker(p.period)
+	p.ticker = ticker
+	go func() {
+		for {
+			<-ticker.C
+			p.lock.Lock()
+			if p.ticker != ticker {
+				// We're done.
+				ticker.Stop()
+				p.lock.Unlock()
+				return
+			}
+			c := atomic.LoadInt64(&p.count)
+			p.throughput.add(time.Now(), c, atomic.LoadInt64(&p.bytes))
+			if c != p.lastCount || p.lastRate != 0 {
+				r := p.throughput.currentRates()
+				p.lastCount = c
+				p.lastRate = r.count
+				p.callback(Update{
+					Phase:    p.phase,
+					Count:    c,
+					Total:    atomic.LoadInt64(&p.total),
+					Rate:     r.count,
+					ByteRate: r.bytes,
+				})
+			}
+			p.lock.Unlock()
+		}
+	}()
+}
+
+func (p *callbackProgress) SetTotal(total int64) {
+	atomic.StoreInt64(&p.total, total)
+}
+
+func (p *callbackProgress) Inc() {
+	atomic.AddInt64(&p.count, 1)
+}
+
+func (p *callbackProgress) Add(delta int64) {
+	atomic.AddInt64(&p.count, delta)
+}
+
+func (p *callbackProgress) AddBytes(n int64) {
+	atomic.AddInt64(&p.bytes, n)
+}
+
+func (p *callbackProgress) Done() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.ticker = nil
+	c := atomic.LoadInt64(&p.count)
+	r := p.throughput.overallRates(time.Now(), c, atomic.LoadInt64(&p.bytes))
+	p.callback(Update{
+		Phase:    p.phase,
+		Count:    c,
+		Total:    atomic.LoadInt64(&p.total),
+		Done:     true,
+		Rate:     r.count,
+		ByteRate: r.bytes,
+	})
+}
@@ -0,0 +1,44 @@
+package meter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/github/git-sizer/meter"
+)
+
+func TestCallbackProgress(t *testing.T) {
+	t.Parallel()
+
+	var updates []meter.Update
+	p := meter.NewCallbackProgress(
+		func(u meter.Update) { updates = append(updates, u) },
+		time.Hour,
+	)
+
+	p.Start("Processing trees: %d")
+	meter.SetTotal(p, 3)
+	p.Inc()
+	p.Add(2)
+	p.Done()
+
+	p.Start("Sampling objects in refgroup tags: %d")
+	p.Inc()
+	p.Done()
+
+	assert.Equal(
+		t,
+		[]meter.Update{
+			{Phase: "Processing trees", Count: 0, Total: -1},
+			{Phase: "Processing trees", Count: 3, Total: 3, Done: true},
+			{Phase: "Sampling objects in refgroup tags", Count: 0, Total: -1},
+			{Phase: "Sampling objects in refgroup tags", Count: 1, Total: -1, Done: true},
+		},
+		updates,
+	)
+
+	// `SetTotal()` is harmless for meters that don't use totals:
+	meter.SetTotal(meter.NoProgressMeter, 3)
+}
@@ -1,3 +1,6 @@
+// Package meter provides progress meters for long-running
+// operations. See "Using git-sizer as a library" in README.md for the
+// compatibility guarantees.
 package meter
 
 import (
@@ -38,17 +41,25 @@ type progressMeter struct {
 	period         time.Duration
 	lastShownCount int64
 	spinnerIndex   int
+	throughput     throughput
 	// When `ticker` is changed, that tells the old goroutine that
 	// it's time to shut down.
 	ticker *time.Ticker
 
-	// `count` is updated atomically:
+	// `count` and `bytes` are updated atomically:
 	count int64
+	bytes int64
 }
 
 // NewProgressMeter returns a progress meter that can be used to show
 // progress to a TTY periodically, including an increasing int64
-// value.
+// value. Once the current phase has been running for a while, the
+// rate at which the value is increasing (and, if the phase reports
+// them via `AddBytes()`, the rate at which bytes are being
+// processed) is shown, too, so that a stalled phase can be told
+// apart from a slow one. The rates are computed over the last few
+// seconds, and the final line of each phase shows the average rates
+// over the whole phase.
 func NewProgressMeter(w io.Writer, period time.Duration) Progress {
 	return &progressMeter{
 		w:      w,
@@ -59,10 +70,12 @@ func NewProgressMeter(w io.Writer, period time.Duration) Progress {
 func (p *progressMeter) Start(format string) {
 	p.lock.Lock()
 	defer p.lock.Unlock()
-	p.format = format + "   %s                    %s"
+	p.format = format + "%s   %s                    %s"
 	atomic.StoreInt64(&p.count, 0)
+	atomic.StoreInt64(&p.bytes, 0)
 	p.lastShownCount = -1
 	p.spinnerIndex = 0
+	p.throughput.reset(time.Now())
 	ticker := time.NewTicker(p.period)
 	p.ticker = ticker
 	go func() {
@@ -76,6 +89,7 @@ func (p *progressMeter) Start(format string) {
 				return
 			}
 			c := atomic.LoadInt64(&p.count)
+			p.throughput.add(time.Now(), c, atomic.LoadInt64(&p.bytes))
 			var s string
 			if c == 0 {
 				p.spinnerIndex = (p.spinnerIndex + 1) % len(Spinners)
@@ -83,7 +97,7 @@ func (p *progressMeter) Start(format string) {
 			} else {
 				s = ""
 			}
-			fmt.Fprintf(p.w, p.format, c, s, "\r")
+			fmt.Fprintf(p.w, p.format, c, p.throughput.current(), s, "\r")
 			p.lock.Unlock()
 		}
 	}()
@@ -97,12 +111,17 @@ func (p *progressMeter) Add(delta int64) {
 	atomic.AddInt64(&p.count, delta)
 }
 
+func (p *progressMeter) AddBytes(n int64) {
+	atomic.AddInt64(&p.bytes, n)
+}
+
 func (p *progressMeter) Done() {
 	p.lock.Lock()
 	defer p.lock.Unlock()
 	p.ticker = nil
 	c := atomic.LoadInt64(&p.count)
-	fmt.Fprintf(p.w, p.format, c, " ", "\n")
+	rates := p.throughput.overall(time.Now(), c, atomic.LoadInt64(&p.bytes))
+	fmt.Fprintf(p.w, p.format, c, rates, " ", "\n")
 }
 
 // NoProgressMeter is a `Progress` that doesn't actually report
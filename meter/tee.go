@@ -0,0 +1,47 @@
+package meter
+
+// teeProgress is a `Progress` that passes everything on to several
+// other `Progress`es.
+type teeProgress []Progress
+
+// Tee returns a `Progress` that reports to each of `meters`; e.g., to
+// show progress to a user while also recording it elsewhere.
+func Tee(meters ...Progress) Progress {
+	return teeProgress(meters)
+}
+
+func (t teeProgress) Start(format string) {
+	for _, p := range t {
+		p.Start(format)
+	}
+}
+
+func (t teeProgress) SetTotal(total int64) {
+	for _, p := range t {
+		SetTotal(p, total)
+	}
+}
+
+func (t teeProgress) Inc() {
+	for _, p := range t {
+		p.Inc()
+	}
+}
+
+func (t teeProgress) Add(delta int64) {
+	for _, p := range t {
+		p.Add(delta)
+	}
+}
+
+func (t teeProgress) AddBytes(n int64) {
+	for _, p := range t {
+		AddBytes(p, n)
+	}
+}
+
+func (t teeProgress) Done() {
+	for _, p := range t {
+		p.Done()
+	}
+}
@@ -0,0 +1,149 @@
+package meter
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/github/git-sizer/counts"
+)
+
+// throughputWindow is the period of time over which the throughput
+// shown by the progress meter is computed. It is long enough to
+// smooth out the bumps caused by individual objects, but short enough
+// that a phase that has stalled is soon shown as such.
+const throughputWindow = 10 * time.Second
+
+// ByteCounter is implemented by `Progress`es that can make use of the
+// number of bytes of data that the current phase has processed; e.g.,
+// to show a data rate.
+type ByteCounter interface {
+	AddBytes(n int64)
+}
+
+// AddBytes tells `p` that the current phase has processed `n` more
+// bytes of data, if `p` can make use of that information.
+func AddBytes(p Progress, n int64) {
+	if bc, ok := p.(ByteCounter); ok {
+		bc.AddBytes(n)
+	}
+}
+
+// throughputSample is the state of a phase at a particular time.
+type throughputSample struct {
+	t     time.Time
+	count int64
+	bytes int64
+}
+
+// throughput computes the rates at which items and bytes are being
+// processed, over a sliding window of `throughputWindow`.
+type throughput struct {
+	// start is the state of the phase when it started.
+	start throughputSample
+
+	// samples are the samples within the window, oldest first. The
+	// oldest one may predate the window slightly, so that the rates
+	// are computed over the whole window.
+	samples []throughputSample
+
+	// sawBytes is set if any bytes have been reported in this phase.
+	// If not, the data rate is not shown.
+	sawBytes bool
+}
+
+// reset forgets about all of the samples, for the start of a new
+// phase at time `t`.
+func (tp *throughput) reset(t time.Time) {
+	tp.start = throughputSample{t: t}
+	tp.samples = append(tp.samples[:0], tp.start)
+	tp.sawBytes = false
+}
+
+// add records the state of the phase at time `t` and discards the
+// samples that are no longer needed.
+func (tp *throughput) add(t time.Time, count, bytes int64) {
+	tp.samples = append(tp.samples, throughputSample{t, count, bytes})
+	if bytes != 0 {
+		tp.sawBytes = true
+	}
+
+	start := t.Add(-throughputWindow)
+	i := 0
+	for i < len(tp.samples)-1 && !tp.samples[i+1].t.After(start) {
+		i++
+	}
+	if i > 0 {
+		tp.samples = append(tp.samples[:0], tp.samples[i:]...)
+	}
+}
+
+// rates are the rates at which a phase processes items and bytes,
+// per second. `valid` is false if there wasn't enough data to compute
+// them.
+type rates struct {
+	count float64
+	bytes float64
+	valid bool
+}
+
+// currentRates returns the rates over the sliding window.
+func (tp *throughput) currentRates() rates {
+	return between(tp.samples[0], tp.samples[len(tp.samples)-1])
+}
+
+// overallRates returns the average rates over the whole phase, which
+// ends at time `t` with `count` items and `bytes` bytes processed.
+// Phases that were over before any rates could be shown have no
+// valid rates.
+func (tp *throughput) overallRates(t time.Time, count, bytes int64) rates {
+	if len(tp.samples) < 2 {
+		return rates{}
+	}
+	if bytes != 0 {
+		tp.sawBytes = true
+	}
+	return between(tp.start, throughputSample{t, count, bytes})
+}
+
+// between returns the rates between `first` and `last`, which are
+// invalid if no time passed between them.
+func between(first, last throughputSample) rates {
+	elapsed := last.t.Sub(first.t).Seconds()
+	if elapsed <= 0 {
+		return rates{}
+	}
+	return rates{
+		count: float64(last.count-first.count) / elapsed,
+		bytes: float64(last.bytes-first.bytes) / elapsed,
+		valid: true,
+	}
+}
+
+// current returns a description of the rates over the sliding
+// window, like " (1.23k/s, 4.56 MB/s)", or the empty string if there
+// isn't enough data yet to compute them.
+func (tp *throughput) current() string {
+	return tp.describe(tp.currentRates())
+}
+
+// overall returns a description of the average rates over the whole
+// phase, like `current()`. See `overallRates()`.
+func (tp *throughput) overall(t time.Time, count, bytes int64) string {
+	return tp.describe(tp.overallRates(t, count, bytes))
+}
+
+// describe formats `r` for the progress meter. The data rate is
+// only included if the phase reported any bytes.
+func (tp *throughput) describe(r rates) string {
+	if !r.valid {
+		return ""
+	}
+
+	numeral, unit := counts.Metric.FormatNumber(uint64(r.count), "")
+	s := fmt.Sprintf(" (%s%s/s", numeral, unit)
+	if tp.sawBytes {
+		numeral, unit := counts.Metric.FormatNumber(uint64(r.bytes), "B")
+		s += fmt.Sprintf(", %s %s/s", numeral, unit)
+	}
+	return s + ")"
+}
@@ -0,0 +1,54 @@
+package meter
+
+import (
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThroughput(t *testing.T) {
+	t.Parallel()
+
+	t0 := time.Unix(1112911993, 0)
+	at := func(seconds int) time.Time {
+		return t0.Add(time.Duration(seconds) * time.Second)
+	}
+
+	var tp throughput
+	tp.reset(t0)
+	assert.Equal(t, "", tp.overall(at(0), 0, 0), "too early for rates")
+
+	tp.add(at(1), 500, 0)
+	assert.Equal(t, " (500/s)", tp.current(), "no bytes were reported")
+
+	tp.add(at(2), 3000, 2000000)
+	assert.Equal(t, " (1.50k/s, 1.00 MB/s)", tp.current())
+
+	// After a stall, the window eventually only covers the stall:
+	tp.add(at(10), 3000, 2000000)
+	assert.Equal(t, " (300/s, 200 kB/s)", tp.current())
+	tp.add(at(20), 3000, 2000000)
+	assert.Equal(t, " (0/s, 0 B/s)", tp.current())
+	assert.Len(t, tp.samples, 2, "old samples are discarded")
+
+	assert.Equal(t, " (150/s, 100 kB/s)", tp.overall(at(20), 3000, 2000000))
+
+	tp.reset(at(20))
+	assert.Equal(t, "", tp.overall(at(30), 3000, 0), "the new phase was too short")
+}
+
+func TestAddBytes(t *testing.T) {
+	t.Parallel()
+
+	p := NewProgressMeter(io.Discard, time.Hour).(*progressMeter)
+	p.Start("Processing blobs: %d")
+	AddBytes(p, 100)
+	AddBytes(p, 23)
+	assert.EqualValues(t, 123, p.bytes)
+	p.Done()
+
+	// `AddBytes()` is harmless for meters that don't use byte counts:
+	AddBytes(NoProgressMeter, 100)
+}
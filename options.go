@@ -0,0 +1,1348 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/internal/objdump"
+	"github.com/github/git-sizer/sizes"
+)
+
+// options holds the settings of a run of git-sizer, as given on its
+// command line or, failing that, in gitconfig. The `add*Flags()`
+// methods register the command-line options for each feature, and
+// the `read*Config()` methods fill in the ones that weren't given
+// from gitconfig and check them.
+type options struct {
+	// What to run instead of a scan:
+	version            bool
+	checkLatestRelease bool
+	latestReleaseURL   string
+	serveStdioMode     bool
+	explainStats       bool
+	odbTotals          bool
+
+	// Which repository to scan, and how:
+	remoteURL     string
+	remoteFilter  string
+	allowShallow  bool
+	shallowRemote *git.Repository
+	resume        bool
+	maxDuration   time.Duration
+
+	// How the statistics are judged:
+	threshold       sizes.Threshold
+	profile         sizes.Profile
+	referenceValues sizes.ReferenceValues
+	docLinks        sizes.DocLinks
+	concernWeights  sizes.ConcernWeights
+	thresholdsPath  string
+	thresholds      *sizes.Thresholds
+	nameStyle       sizes.NameStyle
+	namesBudget     sizes.NamesBudget
+	anonymize       bool
+
+	// How the results are output:
+	outputFormat string
+	jsonOutput   bool
+	protoOutput  bool
+	jsonVersion  int
+	jsonIndent   int
+	jsonCompact  bool
+	teeJSON      string
+	digest       bool
+	signKey      string
+	dumpObjects  string
+	dumpFormat   string
+	objectsFrom  string
+	progress     bool
+	progressFD   int
+	progressFile string
+	tuiMode      bool
+	colorMode    string
+	color        bool
+
+	// Which references and statistics are reported:
+	showRefs           string
+	showRefsFile       string
+	listIgnoredRefs    bool
+	strictAttribution  bool
+	objectsSinceString string
+	objectsSince       time.Time
+	head               bool
+	showScope          bool
+	statsList          string
+	sectionsList       string
+	skipSectionsList   string
+	stats              sizes.StatSet
+	exactCounts        bool
+	maxExpandedEntries uint64
+	staleRefAge        int
+	topObjects         int
+	anomalyExamples    int
+	notesRefList       string
+	notesRefs          []string
+
+	// Analyses of the trees:
+	exactCheckout      bool
+	checkoutExtensions bool
+	checkoutManifest   string
+	htmlTreemap        string
+	sharingMatrix      int
+	sharedTrees        int
+	sizeBudget         int
+	importArtifacts    bool
+	ageBuckets         bool
+	healthScore        bool
+
+	// Analyses of the blobs:
+	compressibility      int
+	lineEndingDuplicates int
+	normalizedDuplicates int
+	recentBlobs          int
+
+	// Analyses of the history and the references:
+	recentCommits       int
+	refgroupActivity    string
+	activityPeriod      sizes.ActivityPeriod
+	emptyCommitGroups   bool
+	packfiles           bool
+	indexReflogNames    bool
+	reflogs             bool
+	reflogExpire        int
+	forcePushes         bool
+	refChurn            int
+	redundantRefs       bool
+	refgroupAttribution bool
+	unreachable         bool
+	pruneExpireList     string
+	pruneExpire         []string
+	topCommitters       int
+	fileLineage         int
+	bloomFilters        bool
+	writeCommitGraph    bool
+
+	// Comparisons with hosting services and with earlier scans:
+	githubRepo        string
+	githubAPIURL      string
+	lfsCutoff         int
+	hostingLimitsList string
+	hostingPresets    []sizes.HostingPreset
+	cloneBandwidth    int
+	cloneLatency      int
+	baselinePath      string
+	saveBaselinePath  string
+	saveStatePath     string
+	sinceStatePath    string
+
+	// Tuning of the scan:
+	batchBufferSize int
+	revListWindow   int
+	commitWorkers   int
+	sizeOracle      string
+	refBackend      string
+	nice            int
+	idleIO          bool
+	threads         int
+	debugPipelines  bool
+	prof            profiler
+}
+
+// addModeFlags registers the options that select what git-sizer does
+// and which repository it does it in.
+func (o *options) addModeFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&o.version, "version", false, "report the git-sizer version number")
+	flags.BoolVar(
+		&o.serveStdioMode, "serve-stdio", false,
+		"serve scan requests on stdin and stdout until stdin is closed",
+	)
+	flags.BoolVar(
+		&o.explainStats, "explain-stats", false,
+		"only describe how each statistic is computed",
+	)
+	flags.BoolVar(
+		&o.odbTotals, "odb-totals", false,
+		"only total all of the objects in the object database by type",
+	)
+	flags.BoolVar(
+		&o.checkLatestRelease, "check-latest", false,
+		"check whether a newer release of git-sizer is available",
+	)
+	flags.StringVar(
+		&o.latestReleaseURL, "latest-release-url", defaultLatestReleaseURL,
+		"URL to query for the latest release version",
+	)
+	flags.BoolVar(&o.resume, "resume", false, "resume an interrupted scan")
+	flags.DurationVar(
+		&o.maxDuration, "max-duration", 0,
+		"give up if the scan takes longer than this (0 means no limit)",
+	)
+	flags.BoolVar(
+		&o.allowShallow, "allow-shallow", false,
+		"scan a shallow clone, reporting where its history is cut off",
+	)
+	flags.StringVar(
+		&o.remoteURL, "remote", "",
+		"scan the repository at this URL using a temporary mirror clone",
+	)
+	flags.StringVar(
+		&o.remoteFilter, "remote-filter", "",
+		"make the temporary clone of '--remote' a partial clone with this filter",
+	)
+}
+
+// addJudgingFlags registers the options that determine how the
+// statistics are judged and how the objects are named.
+func (o *options) addJudgingFlags(flags *pflag.FlagSet) {
+	o.threshold = 1
+	o.profile = sizes.ProfileDefault
+	o.nameStyle = sizes.NameStyleFull
+
+	flags.VarP(
+		sizes.NewThresholdFlagValue(&o.threshold, 0),
+		"verbose", "v", "report all statistics, whether concerning or not",
+	)
+	flags.Lookup("verbose").NoOptDefVal = "true"
+
+	flags.Var(
+		sizes.NewThresholdFlagValue(&o.threshold, 1),
+		"no-verbose", "report statistics that are at all concerning",
+	)
+	flags.Lookup("no-verbose").NoOptDefVal = "true"
+
+	flags.Var(
+		&o.threshold, "threshold",
+		"minimum level of concern (i.e., number of stars) that should be\n"+
+			"                              reported",
+	)
+
+	flags.Var(
+		sizes.NewThresholdFlagValue(&o.threshold, 30),
+		"critical", "only report critical statistics",
+	)
+	flags.Lookup("critical").NoOptDefVal = "true"
+
+	flags.Var(
+		&o.profile, "profile",
+		"judge the statistics by the reference values of the specified `profile`\n"+
+			"(one of "+strings.Join(sizes.Profiles(), ", ")+")",
+	)
+
+	flags.Var(
+		&o.referenceValues, "reference-value",
+		"judge the statistic `symbol=value` by the specified reference value\n"+
+			"(can be repeated)",
+	)
+
+	flags.Var(
+		&o.docLinks, "doc-link",
+		"link the statistic `symbol=url` to the document at the specified URL\n"+
+			"(can be repeated)",
+	)
+
+	flags.Var(
+		&o.concernWeights, "concern-weight",
+		"compute the level of concern of the statistic `symbol=function[:weight]`\n"+
+			"using the specified function and weight (can be repeated)",
+	)
+
+	flags.StringVar(
+		&o.thresholdsPath, "thresholds-file", "",
+		"judge the statistics by the policy in the specified YAML or JSON `file`",
+	)
+
+	flags.Var(
+		&o.nameStyle, "names",
+		"display names of large objects in the specified `style`:\n"+
+			"        --names=none            omit footnotes entirely\n"+
+			"        --names=hash            show only the SHA-1s of objects\n"+
+			"        --names=full            show full names",
+	)
+	flags.Var(
+		&o.namesBudget, "names-budget",
+		"seek the names of at most `objects[,length]` objects at a time, along paths of at most length objects",
+	)
+	flags.BoolVar(&o.anonymize, "anonymize", false, "anonymize paths and refnames in the output")
+}
+
+// addOutputFlags registers the options that determine how the
+// results and the progress of the scan are output.
+func (o *options) addOutputFlags(flags *pflag.FlagSet, stderrIsTerminal bool) {
+	flags.BoolVarP(&o.jsonOutput, "json", "j", false, "output results in JSON format")
+	flags.IntVar(&o.jsonVersion, "json-version", 1, "JSON format version to output (1 or 2)")
+	flags.IntVar(&o.jsonIndent, "json-indent", 4, "number of spaces to indent JSON output by")
+	flags.BoolVar(&o.jsonCompact, "json-compact", false, "output JSON on a single line")
+	flags.StringVar(&o.outputFormat, "output-format", "table", "output format ('table', 'json', or 'proto')")
+	flags.StringVar(&o.teeJSON, "tee-json", "", "also write the report in JSON format to this file")
+	flags.BoolVar(&o.digest, "digest", false, "add a digest of the JSON report to it")
+	flags.StringVar(&o.signKey, "sign-key", "", "sign the JSON report with this SSH private key")
+	flags.StringVar(
+		&o.dumpObjects, "dump-objects", "", "also write a record of each scanned object to this file",
+	)
+	flags.StringVar(&o.dumpFormat, "dump-format", "ndjson", "the format of the object records")
+	flags.StringVar(
+		&o.objectsFrom, "objects-from", "", "scan the objects listed in this file instead of walking the history",
+	)
+
+	flags.BoolVar(&o.progress, "progress", stderrIsTerminal, "report progress to stderr")
+	flags.Var(&NegatedBoolValue{&o.progress}, "no-progress", "suppress progress output")
+	flags.Lookup("no-progress").NoOptDefVal = "true"
+	flags.IntVar(
+		&o.progressFD, "progress-fd", -1,
+		"write machine-readable progress records to file descriptor `N`",
+	)
+	flags.StringVar(
+		&o.progressFile, "progress-file", "",
+		"write machine-readable progress records to the file or named pipe `PATH`",
+	)
+	flags.BoolVar(&o.tuiMode, "tui", false, "show a live dashboard while scanning")
+	flags.StringVar(
+		&o.colorMode, "color", "auto", "color the table by level of concern (auto, always, or never)",
+	)
+}
+
+// addReportFlags registers the options that select which statistics
+// are computed and reported.
+func (o *options) addReportFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&o.head, "head", false, "also process the object that HEAD resolves to")
+	flags.Var(&NegatedBoolValue{&o.head}, "no-head", "don't process HEAD unless a reference leads to it")
+	flags.Lookup("no-head").NoOptDefVal = "true"
+
+	flags.StringVar(
+		&o.statsList, "stats", "",
+		"compute and report only the specified comma-separated statistics",
+	)
+	flags.StringVar(
+		&o.sectionsList, "sections", "",
+		"report only the specified comma-separated sections",
+	)
+	flags.StringVar(
+		&o.skipSectionsList, "skip-sections", "",
+		"omit the specified comma-separated sections",
+	)
+
+	flags.BoolVar(
+		&o.exactCounts, "exact-counts", false,
+		"fail with an error if any counter overflows",
+	)
+	flags.Uint64Var(
+		&o.maxExpandedEntries, "max-expanded-entries", 0,
+		"stop expanding trees with more than this many entries (0 means no limit)",
+	)
+	flags.IntVar(
+		&o.staleRefAge, "stale-ref-age", 365,
+		"count references whose tips are older than this many days as stale",
+	)
+
+	flags.IntVar(
+		&o.topObjects, "top-objects", 1,
+		"list the `N` biggest objects for each statistic that cites an object",
+	)
+	flags.IntVar(
+		&o.anomalyExamples, "anomaly-examples", 5,
+		"include `N` examples of the objects counted by each anomaly statistic in JSON output",
+	)
+	flags.StringVar(
+		&o.notesRefList, "notes-ref", sizes.DefaultNotesRef,
+		"show notes from the comma-separated `REFS` next to the objects that they annotate",
+	)
+}
+
+// addRefFlags registers the options that determine how the
+// references that are scanned are listed and attributed. They follow
+// the options that select the references (see
+// `refopts.RefGroupBuilder.AddRefopts()`).
+func (o *options) addRefFlags(flags *pflag.FlagSet) {
+	flags.StringVar(
+		&o.showRefs, "show-refs", "",
+		"list the references being processed, in `FORMAT` 'text' (to stderr) or 'json'",
+	)
+	flags.Lookup("show-refs").NoOptDefVal = "text"
+	flags.StringVar(
+		&o.showRefsFile, "show-refs-file", "",
+		"write the '--show-refs=json' listing to `FILE` and scan the repository",
+	)
+	flags.BoolVar(
+		&o.strictAttribution, "strict-attribution", false,
+		"compute maxima only over objects not reachable from excluded references",
+	)
+	flags.StringVar(
+		&o.objectsSinceString, "objects-since", "",
+		"count only objects introduced by commits made on or after `DATE`",
+	)
+	flags.BoolVar(
+		&o.listIgnoredRefs, "list-ignored-refs", false,
+		"list the references that were not walked in the JSON output",
+	)
+}
+
+// addTreeAnalysisFlags registers the options of the optional analyses
+// of the trees and of the repository as a whole.
+func (o *options) addTreeAnalysisFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(
+		&o.exactCheckout, "exact-checkout", false,
+		"count the distinct paths and blobs in the checkout with the most blobs",
+	)
+	flags.BoolVar(
+		&o.checkoutExtensions, "checkout-extensions", false,
+		"break down the biggest checkout by file extension",
+	)
+	flags.StringVar(
+		&o.checkoutManifest, "dump-checkout-manifest", "",
+		"write the list of the files in the biggest checkout to this file",
+	)
+	flags.StringVar(
+		&o.htmlTreemap, "html-treemap", "",
+		"write a treemap of the historical size of the paths to this HTML file",
+	)
+	flags.IntVar(
+		&o.sharingMatrix, "sharing-matrix", 0,
+		"estimate the sharing of objects between the top K refgroups (0 means off)",
+	)
+	flags.IntVar(
+		&o.sharedTrees, "shared-trees", 0,
+		"list the N heaviest trees shared across directories or refgroups (0 means off)",
+	)
+	flags.IntVar(
+		&o.sizeBudget, "size-budget-report", 0,
+		"list the paths whose blobs account for `P` percent of the unique blob size (0 means off)",
+	)
+	flags.BoolVar(
+		&o.importArtifacts, "import-artifacts", false,
+		"count the leftovers of an import from Subversion, CVS, or TFS",
+	)
+	flags.BoolVar(
+		&o.healthScore, "health-score", false,
+		"summarize the levels of concern as a health score from 0 to 100",
+	)
+	flags.BoolVar(
+		&o.ageBuckets, "age-buckets", false,
+		"group the unique objects by the year in which they first appeared",
+	)
+}
+
+// addBlobAnalysisFlags registers the options of the optional analyses
+// of the blobs.
+func (o *options) addBlobAnalysisFlags(flags *pflag.FlagSet) {
+	flags.IntVar(
+		&o.compressibility, "compressibility", 0,
+		"estimate the compressibility of the N largest blobs (0 means off)",
+	)
+	flags.IntVar(
+		&o.lineEndingDuplicates, "line-ending-duplicates", 0,
+		"find line-ending duplicates among the N largest blobs (0 means off)",
+	)
+	flags.IntVar(
+		&o.normalizedDuplicates, "normalized-duplicates", 0,
+		"count the text blobs of at most `KiB` KiB that only differ trivially from others (0 means off)",
+	)
+	flags.IntVar(
+		&o.recentBlobs, "recent-blobs", 0,
+		"report the largest blob added to each refgroup in the last `days` days (0 means off)",
+	)
+}
+
+// addHistoryAnalysisFlags registers the options of the optional
+// analyses of the history, the references, and the object database.
+func (o *options) addHistoryAnalysisFlags(flags *pflag.FlagSet) {
+	flags.IntVar(
+		&o.recentCommits, "recent-commits", 0,
+		"total the objects introduced by the last N commits on the default branch (0 means off)",
+	)
+	flags.StringVar(
+		&o.refgroupActivity, "refgroup-activity", "",
+		"count the commits in each refgroup per `period` ('year' or 'month')",
+	)
+	flags.BoolVar(
+		&o.emptyCommitGroups, "empty-commits-by-refgroup", false,
+		"count the empty commits in each refgroup",
+	)
+	flags.BoolVar(&o.packfiles, "packfiles", false, "list the packfiles in the object database")
+	flags.BoolVar(
+		&o.indexReflogNames, "index-reflog-names", false,
+		"also name objects after the index and reflog entries that refer to them",
+	)
+	flags.BoolVar(
+		&o.reflogs, "reflogs", false,
+		"measure the objects that are reachable only from reflogs",
+	)
+	flags.IntVar(
+		&o.reflogExpire, "reflog-expire", 30,
+		"the age in `days` beyond which reflog entries are considered for expiry",
+	)
+	flags.BoolVar(
+		&o.forcePushes, "force-pushes", false,
+		"measure the history that was replaced by non-fast-forward updates of branches",
+	)
+	flags.IntVar(
+		&o.refChurn, "ref-churn", 0,
+		"list the references that were updated most often in the last `days` days",
+	)
+	flags.BoolVar(
+		&o.redundantRefs, "redundant-refs", false,
+		"list the branches that duplicate or are contained in other branches",
+	)
+	flags.BoolVar(
+		&o.refgroupAttribution, "refgroup-attribution", false,
+		"list the refgroups from which each cited object is reachable",
+	)
+	flags.BoolVar(
+		&o.unreachable, "unreachable", false,
+		"measure the unreachable objects and simulate pruning them",
+	)
+	flags.StringVar(
+		&o.pruneExpireList, "prune-expire", "",
+		"the settings of 'gc.pruneExpire' whose effects are simulated",
+	)
+	flags.IntVar(
+		&o.topCommitters, "top-committers", 0,
+		"list the N committers whose commits are biggest in total (0 means off)",
+	)
+	flags.IntVar(
+		&o.fileLineage, "file-lineage", 0,
+		"follow the histories of the files holding the N largest blobs (0 means off)",
+	)
+	flags.BoolVar(
+		&o.bloomFilters, "bloom-filters", false,
+		"use changed-path Bloom filters to follow the histories of files",
+	)
+	flags.BoolVar(
+		&o.writeCommitGraph, "write-commit-graph", false,
+		"write a commit-graph after the scan if there is none, and time a history walk before and after",
+	)
+}
+
+// addComparisonFlags registers the options that compare the
+// repository with the limits of hosting services and with earlier
+// scans.
+func (o *options) addComparisonFlags(flags *pflag.FlagSet) {
+	flags.StringVar(
+		&o.githubRepo, "github-repo", "",
+		"compare with the size that GitHub reports for OWNER/NAME",
+	)
+	flags.StringVar(
+		&o.githubAPIURL, "github-api-url", defaultGitHubAPIURL,
+		"URL of the GitHub API to query for '--github-repo'",
+	)
+	flags.IntVar(
+		&o.lfsCutoff, "lfs-cutoff", 0,
+		"estimate the savings of moving blobs larger than `MiB` MiB to Git LFS (0 means off)",
+	)
+	flags.StringVar(
+		&o.hostingLimitsList, "hosting-limits", "",
+		"count the blobs that exceed the limits of these hosting presets",
+	)
+	flags.IntVar(
+		&o.cloneBandwidth, "clone-bandwidth", 0,
+		"estimate the clone time over a connection with this bandwidth in Mbit/s (0 means off)",
+	)
+	flags.IntVar(
+		&o.cloneLatency, "clone-latency", 50,
+		"assumed latency in milliseconds for the clone time estimate",
+	)
+	flags.StringVar(
+		&o.saveBaselinePath, "save-baseline", "",
+		"save the totals of each refgroup to this file",
+	)
+	flags.StringVar(
+		&o.baselinePath, "baseline", "",
+		"report the growth of each refgroup since the baseline in this file",
+	)
+	flags.StringVar(
+		&o.saveStatePath, "save-state", "",
+		"save the state of the object database to this file",
+	)
+	flags.StringVar(
+		&o.sinceStatePath, "since-state", "",
+		"report the objects added to the object database since the state in this file",
+	)
+}
+
+// addTuningFlags registers the options that tune how the scan is
+// run, without affecting its results.
+func (o *options) addTuningFlags(flags *pflag.FlagSet) error {
+	flags.IntVar(
+		&o.batchBufferSize, "batch-buffer-size", 4096,
+		"size in bytes of the buffers for the pipes to and from git",
+	)
+	flags.IntVar(
+		&o.revListWindow, "rev-list-window", 0,
+		"number of object requests that can be in flight",
+	)
+	flags.IntVar(
+		&o.commitWorkers, "commit-workers", 1,
+		"number of goroutines used to register commits (0 means one per CPU)",
+	)
+	flags.StringVar(
+		&o.sizeOracle, "size-oracle", "",
+		"shell command that supplies the types and sizes of objects",
+	)
+	flags.StringVar(
+		&o.refBackend, "ref-backend", string(git.RefBackendGit),
+		"how to read references ('native' or 'git')",
+	)
+	flags.IntVar(
+		&o.nice, "nice", 0,
+		"lower the scheduling priority of the git subprocesses by this much",
+	)
+	flags.BoolVar(
+		&o.idleIO, "idle-io", false,
+		"run the git subprocesses in the idle I/O scheduling class",
+	)
+	flags.IntVar(
+		&o.threads, "threads", 0,
+		"maximum number of threads to use in git-sizer and in each git subprocess",
+	)
+	flags.BoolVar(
+		&o.debugPipelines, "debug-pipelines", false,
+		"log the git commands that are run, and their environment, to stderr",
+	)
+
+	flags.StringVar(&o.prof.cpuprofile, "cpuprofile", "", "write cpu profile to file")
+	flags.StringVar(&o.prof.memprofile, "memprofile", "", "write memory profile to file")
+	flags.StringVar(&o.prof.blockprofile, "blockprofile", "", "write block profile to file")
+	for _, name := range []string{"cpuprofile", "memprofile", "blockprofile"} {
+		if err := flags.MarkHidden(name); err != nil {
+			return fmt.Errorf("marking option hidden: %w", err)
+		}
+	}
+	return nil
+}
+
+// configReader fills in the options that weren't given on the command
+// line from the corresponding gitconfig settings.
+type configReader struct {
+	ctx   context.Context
+	repo  *git.Repository
+	flags *pflag.FlagSet
+}
+
+// changed returns true iff the option `name`, or its negation
+// `--no-<name>`, was given on the command line.
+func (r configReader) changed(name string) bool {
+	return r.flags.Changed(name) || r.flags.Changed("no-"+name)
+}
+
+// boolVar sets `*p` from the gitconfig setting `key`, unless the
+// option `name` was given on the command line.
+func (r configReader) boolVar(p *bool, name, key string) error {
+	if r.changed(name) {
+		return nil
+	}
+	v, err := r.repo.ConfigBoolDefaultContext(r.ctx, key, *p)
+	if err != nil {
+		return fmt.Errorf("parsing gitconfig value for '%s': %w", key, err)
+	}
+	*p = v
+	return nil
+}
+
+// intVar is like `boolVar()`, but for an integer option.
+func (r configReader) intVar(p *int, name, key string) error {
+	if r.changed(name) {
+		return nil
+	}
+	v, err := r.repo.ConfigIntDefaultContext(r.ctx, key, *p)
+	if err != nil {
+		return fmt.Errorf("parsing gitconfig value for '%s': %w", key, err)
+	}
+	*p = v
+	return nil
+}
+
+// stringVar is like `boolVar()`, but for a string option.
+func (r configReader) stringVar(p *string, name, key string) error {
+	if r.changed(name) {
+		return nil
+	}
+	v, err := r.repo.ConfigStringDefaultContext(r.ctx, key, *p)
+	if err != nil {
+		return fmt.Errorf("parsing gitconfig value for '%s': %w", key, err)
+	}
+	*p = v
+	return nil
+}
+
+// value is like `boolVar()`, but for an option that parses its own
+// value.
+func (r configReader) value(v pflag.Value, name, key string) error {
+	if r.changed(name) {
+		return nil
+	}
+	s, err := r.repo.ConfigStringDefaultContext(r.ctx, key, v.String())
+	if err == nil {
+		err = v.Set(s)
+	}
+	if err != nil {
+		return fmt.Errorf("parsing gitconfig value for '%s': %w", key, err)
+	}
+	return nil
+}
+
+// readShallowConfig determines whether a shallow clone may be
+// scanned. If so, it looks for the repository that it was cloned
+// from, which can tell where its history is cut off.
+func (o *options) readShallowConfig(r configReader) error {
+	if err := r.boolVar(&o.allowShallow, "allow-shallow", "sizer.allowShallow"); err != nil {
+		return err
+	}
+	if o.allowShallow {
+		o.shallowRemote = localRemote(r.ctx, r.repo, "origin")
+		return nil
+	}
+
+	full, err := r.repo.IsFull()
+	if err != nil {
+		return fmt.Errorf("determining whether the repository is a full clone: %w", err)
+	}
+	if !full {
+		return fmt.Errorf(
+			"couldn't open Git repository: %w (use '--allow-shallow' to scan it anyway)",
+			git.ErrShallowClone,
+		)
+	}
+	return nil
+}
+
+// readOutputConfig reads and checks the options that determine how
+// the results and the progress of the scan are output.
+func (o *options) readOutputConfig(r configReader) error {
+	if err := r.stringVar(&o.colorMode, "color", "sizer.color"); err != nil {
+		return err
+	}
+
+	switch o.outputFormat {
+	case "table":
+	case "json":
+		o.jsonOutput = true
+	case "proto":
+		if o.jsonOutput {
+			return errors.New("'--json' can't be combined with '--output-format=proto'")
+		}
+		o.protoOutput = true
+	default:
+		return fmt.Errorf("invalid --output-format %q; use 'table', 'json', or 'proto'", o.outputFormat)
+	}
+
+	if o.jsonOutput || o.teeJSON != "" {
+		if !r.changed("json-version") {
+			v, err := r.repo.ConfigIntDefaultContext(r.ctx, "sizer.jsonVersion", o.jsonVersion)
+			if err != nil {
+				return err
+			}
+			o.jsonVersion = v
+			if !(o.jsonVersion == 1 || o.jsonVersion == 2) {
+				return fmt.Errorf("JSON version (read from gitconfig) must be 1 or 2")
+			}
+		} else if !(o.jsonVersion == 1 || o.jsonVersion == 2) {
+			return fmt.Errorf("JSON version must be 1 or 2")
+		}
+
+		if !r.changed("json-indent") {
+			v, err := r.repo.ConfigIntDefaultContext(r.ctx, "sizer.jsonIndent", o.jsonIndent)
+			if err != nil {
+				return err
+			}
+			o.jsonIndent = v
+			if o.jsonIndent < 0 {
+				return errors.New("JSON indent (read from gitconfig) must not be negative")
+			}
+		} else if o.jsonIndent < 0 {
+			return errors.New("JSON indent must not be negative")
+		}
+		if o.jsonCompact {
+			o.jsonIndent = 0
+		}
+	}
+
+	if o.signKey != "" {
+		o.digest = true
+	}
+	if o.digest && !o.jsonOutput && o.teeJSON == "" {
+		return errors.New("'--digest' and '--sign-key' require '--json' or '--tee-json'")
+	}
+
+	if o.dumpObjects != "" {
+		if o.resume {
+			return errors.New("'--dump-objects' can't be combined with '--resume'")
+		}
+		if err := objdump.CheckFormat(o.dumpFormat); err != nil {
+			return err
+		}
+	}
+
+	return r.boolVar(&o.progress, "progress", "sizer.progress")
+}
+
+// readJudgingConfig reads the options that determine how the
+// statistics are judged and how the objects are named. A thresholds
+// file replaces the corresponding gitconfig settings, but not the
+// command-line options.
+func (o *options) readJudgingConfig(r configReader) error {
+	if err := r.stringVar(&o.thresholdsPath, "thresholds-file", "sizer.thresholdsFile"); err != nil {
+		return err
+	}
+	if o.thresholdsPath != "" {
+		var err error
+		o.thresholds, err = readThresholds(o.thresholdsPath)
+		if err != nil {
+			return err
+		}
+	}
+
+	switch {
+	case r.changed("threshold") || r.changed("verbose") || r.flags.Changed("critical"):
+		// The command-line option takes precedence.
+	case o.thresholds != nil:
+		if o.thresholds.Threshold != nil {
+			o.threshold = *o.thresholds.Threshold
+		}
+	default:
+		s, err := r.repo.ConfigStringDefaultContext(r.ctx, "sizer.threshold", fmt.Sprintf("%g", o.threshold))
+		if err != nil {
+			return err
+		}
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("parsing gitconfig value for 'sizer.threshold': %w", err)
+		}
+		o.threshold = sizes.Threshold(v)
+	}
+
+	switch {
+	case r.changed("profile"):
+		// The command-line option takes precedence.
+	case o.thresholds != nil:
+		if o.thresholds.Profile != "" {
+			o.profile = o.thresholds.Profile
+		}
+	default:
+		if err := r.value(&o.profile, "profile", "sizer.profile"); err != nil {
+			return err
+		}
+	}
+
+	var err error
+	if o.thresholds != nil {
+		o.referenceValues = o.thresholds.ReferenceValuesWith(o.referenceValues)
+	} else {
+		o.referenceValues, err = configReferenceValues(r.ctx, r.repo, o.referenceValues)
+		if err != nil {
+			return err
+		}
+	}
+
+	o.docLinks, err = sizes.ReadDocLinks(r.ctx, r.repo, o.docLinks)
+	if err != nil {
+		return err
+	}
+
+	o.concernWeights, err = sizes.ReadConcernWeights(r.ctx, r.repo, o.concernWeights)
+	if err != nil {
+		return err
+	}
+
+	if err := r.value(&o.nameStyle, "names", "sizer.names"); err != nil {
+		return err
+	}
+	if err := r.value(&o.namesBudget, "names-budget", "sizer.namesBudget"); err != nil {
+		return err
+	}
+	return r.boolVar(&o.anonymize, "anonymize", "sizer.anonymize")
+}
+
+// readReportConfig reads and checks the options that select which
+// statistics are computed and reported.
+func (o *options) readReportConfig(r configReader) error {
+	if err := r.boolVar(&o.head, "head", "sizer.head"); err != nil {
+		return err
+	}
+
+	if err := r.intVar(&o.staleRefAge, "stale-ref-age", "sizer.staleRefAge"); err != nil {
+		return err
+	}
+	if o.staleRefAge < 0 {
+		return errors.New("stale reference age must not be negative")
+	}
+
+	if err := r.stringVar(&o.statsList, "stats", "sizer.stats"); err != nil {
+		return err
+	}
+	if err := r.stringVar(&o.sectionsList, "sections", "sizer.sections"); err != nil {
+		return err
+	}
+	if err := r.stringVar(&o.skipSectionsList, "skip-sections", "sizer.skipSections"); err != nil {
+		return err
+	}
+
+	if err := r.boolVar(&o.strictAttribution, "strict-attribution", "sizer.strictAttribution"); err != nil {
+		return err
+	}
+
+	if err := r.stringVar(&o.objectsSinceString, "objects-since", "sizer.objectsSince"); err != nil {
+		return err
+	}
+	if o.objectsSinceString != "" {
+		var err error
+		o.objectsSince, err = parseDate(o.objectsSinceString)
+		if err != nil {
+			return fmt.Errorf("invalid '--objects-since' value: %w", err)
+		}
+	}
+
+	if !r.changed("max-expanded-entries") {
+		v, err := r.repo.ConfigIntDefaultContext(r.ctx, "sizer.maxExpandedEntries", int(o.maxExpandedEntries))
+		if err != nil {
+			return fmt.Errorf("parsing gitconfig value for 'sizer.maxExpandedEntries': %w", err)
+		}
+		if v < 0 {
+			return errors.New("gitconfig value for 'sizer.maxExpandedEntries' must not be negative")
+		}
+		o.maxExpandedEntries = uint64(v)
+	}
+
+	if err := r.intVar(&o.topObjects, "top-objects", "sizer.topObjects"); err != nil {
+		return err
+	}
+	if o.topObjects < 1 || o.topObjects > 1000 {
+		return errors.New("the number for '--top-objects' must be between 1 and 1000")
+	}
+
+	if err := r.intVar(&o.anomalyExamples, "anomaly-examples", "sizer.anomalyExamples"); err != nil {
+		return err
+	}
+	if o.anomalyExamples < 0 || o.anomalyExamples > 1000 {
+		return errors.New("the number for '--anomaly-examples' must be between 0 and 1000")
+	}
+
+	if err := r.stringVar(&o.notesRefList, "notes-ref", "sizer.notesRef"); err != nil {
+		return err
+	}
+	o.notesRefs = splitList(o.notesRefList)
+
+	return nil
+}
+
+// readTreeAnalysisConfig reads and checks the options of the optional
+// analyses of the trees and of the repository as a whole.
+func (o *options) readTreeAnalysisConfig(r configReader) error {
+	if err := r.boolVar(&o.exactCheckout, "exact-checkout", "sizer.exactCheckout"); err != nil {
+		return err
+	}
+	if err := r.boolVar(&o.checkoutExtensions, "checkout-extensions", "sizer.checkoutExtensions"); err != nil {
+		return err
+	}
+
+	if err := r.intVar(&o.sharingMatrix, "sharing-matrix", "sizer.sharingMatrix"); err != nil {
+		return err
+	}
+	if o.sharingMatrix < 0 {
+		return errors.New("the number of refgroups in the sharing matrix must not be negative")
+	}
+
+	if err := r.intVar(&o.sharedTrees, "shared-trees", "sizer.sharedTrees"); err != nil {
+		return err
+	}
+	if o.sharedTrees < 0 {
+		return errors.New("the number of shared trees to list must not be negative")
+	}
+
+	if err := r.intVar(&o.sizeBudget, "size-budget-report", "sizer.sizeBudgetReport"); err != nil {
+		return err
+	}
+	if o.sizeBudget < 0 || o.sizeBudget > 100 {
+		return errors.New("the percentage for '--size-budget-report' must be between 0 and 100")
+	}
+
+	if err := r.boolVar(&o.importArtifacts, "import-artifacts", "sizer.importArtifacts"); err != nil {
+		return err
+	}
+	if err := r.boolVar(&o.healthScore, "health-score", "sizer.healthScore"); err != nil {
+		return err
+	}
+	return r.boolVar(&o.ageBuckets, "age-buckets", "sizer.ageBuckets")
+}
+
+// readBlobAnalysisConfig reads and checks the options of the optional
+// analyses of the blobs.
+func (o *options) readBlobAnalysisConfig(r configReader) error {
+	if err := r.intVar(&o.compressibility, "compressibility", "sizer.compressibility"); err != nil {
+		return err
+	}
+	if o.compressibility < 0 {
+		return errors.New("the number of blobs whose compressibility is estimated must not be negative")
+	}
+
+	err := r.intVar(&o.lineEndingDuplicates, "line-ending-duplicates", "sizer.lineEndingDuplicates")
+	if err != nil {
+		return err
+	}
+	if o.lineEndingDuplicates < 0 {
+		return errors.New("the number of blobs checked for line-ending duplicates must not be negative")
+	}
+
+	err = r.intVar(&o.normalizedDuplicates, "normalized-duplicates", "sizer.normalizedDuplicates")
+	if err != nil {
+		return err
+	}
+	if o.normalizedDuplicates < 0 || o.normalizedDuplicates >= 4<<20 {
+		return errors.New("the cutoff for '--normalized-duplicates' must be between 0 and 4194303 KiB")
+	}
+
+	if err := r.intVar(&o.recentBlobs, "recent-blobs", "sizer.recentBlobs"); err != nil {
+		return err
+	}
+	if o.recentBlobs < 0 {
+		return errors.New("the number of days for '--recent-blobs' must not be negative")
+	}
+	return nil
+}
+
+// readHistoryAnalysisConfig reads and checks the options of the
+// optional analyses of the history, the references, and the object
+// database. It must be called after `readJudgingConfig()`, because
+// `--file-lineage` depends on how the objects are named.
+func (o *options) readHistoryAnalysisConfig(r configReader, stderr io.Writer) error {
+	if err := r.intVar(&o.recentCommits, "recent-commits", "sizer.recentCommits"); err != nil {
+		return err
+	}
+	if o.recentCommits < 0 {
+		return errors.New("the number of commits for '--recent-commits' must not be negative")
+	}
+
+	if err := r.stringVar(&o.refgroupActivity, "refgroup-activity", "sizer.refgroupActivity"); err != nil {
+		return err
+	}
+	var err error
+	o.activityPeriod, err = sizes.ParseActivityPeriod(o.refgroupActivity)
+	if err != nil {
+		return err
+	}
+
+	for _, b := range []struct {
+		p         *bool
+		name, key string
+	}{
+		{&o.emptyCommitGroups, "empty-commits-by-refgroup", "sizer.emptyCommitsByRefgroup"},
+		{&o.packfiles, "packfiles", "sizer.packfiles"},
+		{&o.indexReflogNames, "index-reflog-names", "sizer.indexReflogNames"},
+		{&o.reflogs, "reflogs", "sizer.reflogs"},
+		{&o.forcePushes, "force-pushes", "sizer.forcePushes"},
+		{&o.redundantRefs, "redundant-refs", "sizer.redundantRefs"},
+		{&o.refgroupAttribution, "refgroup-attribution", "sizer.refgroupAttribution"},
+		{&o.unreachable, "unreachable", "sizer.unreachable"},
+	} {
+		if err := r.boolVar(b.p, b.name, b.key); err != nil {
+			return err
+		}
+	}
+
+	if err := r.intVar(&o.reflogExpire, "reflog-expire", "sizer.reflogExpire"); err != nil {
+		return err
+	}
+	if o.reflogExpire < 0 {
+		return errors.New("reflog expiry age must not be negative")
+	}
+
+	if err := r.intVar(&o.refChurn, "ref-churn", "sizer.refChurn"); err != nil {
+		return err
+	}
+	if o.refChurn < 0 {
+		return errors.New("the number of days for '--ref-churn' must not be negative")
+	}
+
+	if err := r.stringVar(&o.pruneExpireList, "prune-expire", "sizer.pruneExpire"); err != nil {
+		return err
+	}
+	o.pruneExpire = splitList(o.pruneExpireList)
+
+	if err := r.intVar(&o.topCommitters, "top-committers", "sizer.topCommitters"); err != nil {
+		return err
+	}
+	if o.topCommitters < 0 {
+		return errors.New("the number of top committers must not be negative")
+	}
+
+	if err := r.intVar(&o.fileLineage, "file-lineage", "sizer.fileLineage"); err != nil {
+		return err
+	}
+	if o.fileLineage < 0 {
+		return errors.New("the number of files whose histories are followed must not be negative")
+	}
+	if o.fileLineage > 0 {
+		// The histories are followed using the files' real names:
+		if o.nameStyle != sizes.NameStyleFull {
+			return errors.New("--file-lineage requires --names=full")
+		}
+		if o.anonymize {
+			return errors.New("--file-lineage can't be combined with --anonymize")
+		}
+	}
+
+	if err := r.boolVar(&o.bloomFilters, "bloom-filters", "sizer.bloomFilters"); err != nil {
+		return err
+	}
+	if o.bloomFilters && o.fileLineage > 0 {
+		ok, err := r.repo.HasChangedPathFilters(r.ctx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			fmt.Fprintln(
+				stderr,
+				"warning: the commit-graph has no changed-path Bloom filters; "+
+					"run 'git commit-graph write --reachable --changed-paths' to write them",
+			)
+		}
+	}
+	return nil
+}
+
+// readComparisonConfig reads and checks the options that compare the
+// repository with the limits of hosting services.
+func (o *options) readComparisonConfig(r configReader) error {
+	if err := r.stringVar(&o.githubRepo, "github-repo", "sizer.githubRepo"); err != nil {
+		return err
+	}
+	if err := r.stringVar(&o.githubAPIURL, "github-api-url", "sizer.githubAPIURL"); err != nil {
+		return err
+	}
+
+	if err := r.intVar(&o.lfsCutoff, "lfs-cutoff", "sizer.lfsCutoff"); err != nil {
+		return err
+	}
+	if o.lfsCutoff < 0 || o.lfsCutoff >= 4096 {
+		return errors.New("the cutoff for '--lfs-cutoff' must be between 0 and 4095 MiB")
+	}
+
+	if err := r.stringVar(&o.hostingLimitsList, "hosting-limits", "sizer.hostingLimits"); err != nil {
+		return err
+	}
+	var err error
+	o.hostingPresets, err = sizes.ParseHostingPresets(o.hostingLimitsList)
+	if err != nil {
+		return err
+	}
+
+	if err := r.intVar(&o.cloneBandwidth, "clone-bandwidth", "sizer.cloneBandwidth"); err != nil {
+		return err
+	}
+	if o.cloneBandwidth < 0 {
+		return errors.New("the clone bandwidth must not be negative")
+	}
+
+	if err := r.intVar(&o.cloneLatency, "clone-latency", "sizer.cloneLatency"); err != nil {
+		return err
+	}
+	if o.cloneLatency < 0 {
+		return errors.New("the clone latency must not be negative")
+	}
+	return nil
+}
+
+// readTuningConfig reads and checks the options that tune how the
+// scan is run, and applies them to `r.repo` and to the process.
+func (o *options) readTuningConfig(r configReader) error {
+	if err := r.intVar(&o.batchBufferSize, "batch-buffer-size", "sizer.batchBufferSize"); err != nil {
+		return err
+	}
+	if o.batchBufferSize <= 0 {
+		return errors.New("batch buffer size must be positive")
+	}
+
+	if err := r.intVar(&o.revListWindow, "rev-list-window", "sizer.revListWindow"); err != nil {
+		return err
+	}
+	if o.revListWindow < 0 {
+		return errors.New("rev-list window must not be negative")
+	}
+
+	if err := r.intVar(&o.commitWorkers, "commit-workers", "sizer.commitWorkers"); err != nil {
+		return err
+	}
+	if o.commitWorkers < 0 {
+		return errors.New("the number of commit workers must not be negative")
+	}
+	if o.commitWorkers == 0 {
+		o.commitWorkers = runtime.NumCPU()
+	}
+
+	if err := r.stringVar(&o.sizeOracle, "size-oracle", "sizer.sizeOracle"); err != nil {
+		return err
+	}
+	if o.sizeOracle != "" {
+		r.repo.SetSizeOracle(git.NewCommandSizeOracle(r.repo, o.sizeOracle))
+	}
+
+	r.repo.SetBatchOptions(git.BatchOptions{
+		BufferSize: o.batchBufferSize,
+		Window:     o.revListWindow,
+	})
+
+	if err := r.stringVar(&o.refBackend, "ref-backend", "sizer.refBackend"); err != nil {
+		return err
+	}
+	backend, err := git.ParseRefBackend(o.refBackend)
+	if err != nil {
+		return err
+	}
+	r.repo.SetRefBackend(backend)
+
+	if err := r.intVar(&o.nice, "nice", "sizer.nice"); err != nil {
+		return err
+	}
+	if o.nice < 0 {
+		return errors.New("nice value must not be negative")
+	}
+
+	if err := r.boolVar(&o.idleIO, "idle-io", "sizer.idleIO"); err != nil {
+		return err
+	}
+
+	if err := r.intVar(&o.threads, "threads", "sizer.threads"); err != nil {
+		return err
+	}
+	if o.threads < 0 {
+		return errors.New("number of threads must not be negative")
+	}
+	if o.threads > 0 {
+		runtime.GOMAXPROCS(o.threads)
+	}
+
+	if err := r.repo.SetProcessOptions(git.ProcessOptions{
+		Nice:    o.nice,
+		IdleIO:  o.idleIO,
+		Threads: o.threads,
+	}); err != nil {
+		return err
+	}
+
+	if !r.changed("max-duration") {
+		s, err := r.repo.ConfigStringDefaultContext(r.ctx, "sizer.maxDuration", o.maxDuration.String())
+		if err != nil {
+			return err
+		}
+		o.maxDuration, err = time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("parsing gitconfig value for 'sizer.maxDuration': %w", err)
+		}
+	}
+	if o.maxDuration < 0 {
+		return errors.New("the maximum duration must not be negative")
+	}
+	return nil
+}
+
+// scanOptions returns the options for scanning the repository,
+// except for those that depend on the state of the scan (`Head`,
+// `Live`, `Checkpoint`, `CustomStats`, `ObjectList`, and
+// `ObjectDumper`), which the caller fills in.
+func (o *options) scanOptions(rootMaxima bool) (sizes.ScanOptions, error) {
+	overflowPolicy := sizes.OverflowSaturate
+	if o.exactCounts {
+		overflowPolicy = sizes.OverflowError
+	}
+
+	scanOpts := sizes.ScanOptions{
+		StaleRefAge:                time.Duration(o.staleRefAge) * 24 * time.Hour,
+		MaxExpandedEntries:         o.maxExpandedEntries,
+		OverflowPolicy:             overflowPolicy,
+		ExactCheckout:              o.exactCheckout,
+		CheckoutExtensions:         o.checkoutExtensions,
+		StrictAttribution:          o.strictAttribution,
+		ObjectsSince:               o.objectsSince,
+		SharingMatrix:              o.sharingMatrix,
+		SharedTrees:                o.sharedTrees,
+		Compressibility:            o.compressibility,
+		LineEndingDuplicates:       o.lineEndingDuplicates,
+		RecentBlobs:                time.Duration(o.recentBlobs) * 24 * time.Hour,
+		RecentCommits:              o.recentCommits,
+		SizeBudget:                 o.sizeBudget,
+		ImportArtifacts:            o.importArtifacts,
+		DirectorySizes:             o.htmlTreemap != "",
+		LFSCutoff:                  counts.Count32(o.lfsCutoff) << 20,
+		NormalizedDuplicatesCutoff: counts.Count32(o.normalizedDuplicates) << 10,
+		HostingPresets:             o.hostingPresets,
+		TopObjects:                 o.topObjects,
+		AnomalyExamples:            o.anomalyExamples,
+		NotesRefs:                  o.notesRefs,
+		NamesBudget:                o.namesBudget,
+		CommitWorkers:              o.commitWorkers,
+		AgeBuckets:                 o.ageBuckets,
+		RefGroupActivity:           o.activityPeriod,
+		EmptyCommitGroups:          o.emptyCommitGroups,
+		RootMaxima:                 rootMaxima,
+		Packfiles:                  o.packfiles,
+		Reflogs:                    o.reflogs,
+		ReflogExpireAge:            time.Duration(o.reflogExpire) * 24 * time.Hour,
+		ForcePushes:                o.forcePushes,
+		RefChurn:                   time.Duration(o.refChurn) * 24 * time.Hour,
+		RedundantRefs:              o.redundantRefs,
+		RefGroupAttribution:        o.refgroupAttribution,
+		Unreachable:                o.unreachable,
+		IndexReflogNames:           o.indexReflogNames,
+		PruneExpire:                o.pruneExpire,
+		TopCommitters:              o.topCommitters,
+		FileLineage:                o.fileLineage,
+		BloomFilters:               o.bloomFilters,
+		WriteCommitGraph:           o.writeCommitGraph,
+		ShallowRemote:              o.shallowRemote,
+		CloneBandwidth:             float64(o.cloneBandwidth),
+		CloneLatency:               time.Duration(o.cloneLatency) * time.Millisecond,
+		Stats:                      o.stats,
+		Profile:                    o.profile,
+		ReferenceValues:            o.referenceValues,
+		Thresholds:                 o.thresholds,
+		DocLinks:                   o.docLinks,
+		ConcernWeights:             o.concernWeights,
+	}
+	if o.jsonOutput && (o.showRefs != "" || o.listIgnoredRefs) {
+		scanOpts.ListIgnoredRefs = maxListedIgnoredRefs
+	}
+
+	var err error
+	if o.baselinePath != "" {
+		scanOpts.Baseline, err = sizes.ReadBaseline(o.baselinePath)
+		if err != nil {
+			return sizes.ScanOptions{}, err
+		}
+	}
+	if o.saveBaselinePath != "" {
+		scanOpts.RefGroupTotals = true
+	}
+	if o.sinceStatePath != "" {
+		scanOpts.SinceState, err = sizes.ReadODBState(o.sinceStatePath)
+		if err != nil {
+			return sizes.ScanOptions{}, err
+		}
+	}
+	if o.anonymize {
+		scanOpts.Anonymizer, err = sizes.NewAnonymizer()
+		if err != nil {
+			return sizes.ScanOptions{}, err
+		}
+	}
+	return scanOpts, nil
+}
+
+// splitList splits the comma-separated list `s` into its non-empty
+// items, with any surrounding whitespace removed.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
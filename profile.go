@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+)
+
+// profiler collects the profiles requested via the hidden
+// `--cpuprofile`, `--memprofile`, and `--blockprofile` options. The
+// profiles cover the span between `start()` and `stop()`, which
+// bracket the scan of the repository.
+type profiler struct {
+	cpuprofile   string
+	memprofile   string
+	blockprofile string
+
+	cpuFile *os.File
+	running bool
+}
+
+// start begins collecting the CPU and block profiles, if they were
+// requested.
+func (p *profiler) start() error {
+	if p.cpuprofile != "" {
+		f, err := os.Create(p.cpuprofile)
+		if err != nil {
+			return fmt.Errorf("couldn't set up cpuprofile file: %w", err)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("starting CPU profiling: %w", err)
+		}
+		p.cpuFile = f
+	}
+
+	if p.blockprofile != "" {
+		runtime.SetBlockProfileRate(1)
+	}
+
+	p.running = true
+	return nil
+}
+
+// stop stops profiling and writes out any requested profiles. It is
+// safe to call more than once; only the first call after `start()`
+// has any effect.
+func (p *profiler) stop() error {
+	if !p.running {
+		return nil
+	}
+	p.running = false
+
+	if p.cpuFile != nil {
+		pprof.StopCPUProfile()
+		err := p.cpuFile.Close()
+		p.cpuFile = nil
+		if err != nil {
+			return fmt.Errorf("writing cpuprofile file: %w", err)
+		}
+	}
+
+	if p.blockprofile != "" {
+		runtime.SetBlockProfileRate(0)
+		if err := writeProfile("block", p.blockprofile); err != nil {
+			return err
+		}
+	}
+
+	if p.memprofile != "" {
+		// Make sure that the in-use statistics are up to date:
+		runtime.GC()
+		if err := writeProfile("heap", p.memprofile); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeProfile writes the named runtime profile to `filename`.
+func writeProfile(name, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return fmt.Errorf("couldn't set up %s profile file: %w", name, err)
+	}
+	if err := pprof.Lookup(name).WriteTo(f, 0); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("writing %s profile: %w", name, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("writing %s profile: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/github/git-sizer/meter"
+)
+
+// progressRecordPeriod is how often progress records are written by
+// `--progress-fd` and `--progress-file` while a phase is running.
+const progressRecordPeriod = 250 * time.Millisecond
+
+// progressRecord is one line written by `--progress-fd` and
+// `--progress-file`. See `meter.Update` for the meanings of the
+// fields.
+type progressRecord struct {
+	Phase    string  `json:"phase"`
+	Count    int64   `json:"count"`
+	Total    int64   `json:"total"`
+	Rate     float64 `json:"rate"`
+	ByteRate float64 `json:"byteRate"`
+	Done     bool    `json:"done"`
+}
+
+// newProgressRecorder returns a `meter.Progress` that writes
+// progress records to `w` as JSON, one per line.
+func newProgressRecorder(w io.Writer) meter.Progress {
+	return meter.NewCallbackProgress(
+		func(u meter.Update) {
+			j, err := json.Marshal(progressRecord{
+				Phase:    u.Phase,
+				Count:    u.Count,
+				Total:    u.Total,
+				Rate:     u.Rate,
+				ByteRate: u.ByteRate,
+				Done:     u.Done,
+			})
+			if err != nil {
+				return
+			}
+			// If the reader has gone away, that's no reason to
+			// abandon the scan.
+			_, _ = w.Write(append(j, '\n'))
+		},
+		progressRecordPeriod,
+	)
+}
+
+// openProgressRecords opens the destination of the progress records
+// selected by `--progress-fd` (if `fd` is not negative) or
+// `--progress-file` (if `path` is not empty). It returns nil if
+// neither was used. The returned function must be called when the
+// records are done; it closes the file, but not a file descriptor
+// that was passed in.
+func openProgressRecords(fd int, path string) (io.Writer, func(), error) {
+	switch {
+	case fd >= 0 && path != "":
+		return nil, nil, errors.New("--progress-fd and --progress-file can't be used together")
+	case fd >= 0:
+		f := os.NewFile(uintptr(fd), fmt.Sprintf("file descriptor %d", fd))
+		if _, err := f.Stat(); err != nil {
+			return nil, nil, fmt.Errorf("--progress-fd=%d: file descriptor is not open", fd)
+		}
+		return f, func() {}, nil
+	case path != "":
+		// This also works for named pipes, which aren't truncated:
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o666)
+		if err != nil {
+			return nil, nil, fmt.Errorf("opening progress file: %w", err)
+		}
+		return f, func() { _ = f.Close() }, nil
+	default:
+		return nil, func() {}, nil
+	}
+}
@@ -0,0 +1,370 @@
+// Package refgroups categorizes reference names into reference
+// groups ("refgroups") exactly the way that git-sizer does: the
+// built-in refgroups (branches, tags, etc.), plus any that are
+// defined in the gitconfig under `refgroup.<symbol>`, nested by the
+// dots in their symbols. Other tools can use it to report on
+// references consistently with git-sizer's output.
+package refgroups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/sizes"
+)
+
+// Configger is an abstraction for a thing that can read gitconfig.
+// `*git.Repository` is a `Configger`.
+type Configger interface {
+	GetConfigContext(ctx context.Context, prefix string) (*git.Config, error)
+}
+
+// New returns a `sizes.RefGrouper` that categorizes references the
+// way that git-sizer does when no reference-selection options are
+// used: all references are walked, and they are collated into the
+// built-in refgroups and those defined in the gitconfig read from
+// `configger`.
+func New(ctx context.Context, configger Configger) (sizes.RefGrouper, error) {
+	b, err := NewBuilder(ctx, configger)
+	if err != nil {
+		return nil, err
+	}
+	return b.Finish(true)
+}
+
+// Builder puts together a `sizes.RefGrouper`. Its top-level filter,
+// which decides which references are walked at all, can be adjusted
+// (e.g., according to command-line options) before calling
+// `Finish()`.
+type Builder struct {
+	topLevelGroup *refGroup
+	groups        map[sizes.RefGroupSymbol]*refGroup
+
+	// filtered is set if `AddFilter()` has been called.
+	filtered bool
+}
+
+// NewBuilder creates and returns a `Builder` instance, with the
+// built-in refgroups and any that are defined in the gitconfig read
+// from `configger`. If `configger` is nil, only the built-in
+// refgroups are defined.
+func NewBuilder(ctx context.Context, configger Configger) (*Builder, error) {
+	tlg := refGroup{
+		RefGroup: sizes.RefGroup{
+			Symbol: "",
+			Name:   "Refs to walk",
+		},
+	}
+
+	rgb := Builder{
+		topLevelGroup: &tlg,
+		groups: map[sizes.RefGroupSymbol]*refGroup{
+			"": &tlg,
+		},
+	}
+
+	rgb.initializeStandardRefgroups()
+	if err := rgb.readRefgroupsFromGitconfig(ctx, configger); err != nil {
+		return nil, err
+	}
+
+	return &rgb, nil
+}
+
+// getGroup returns the `refGroup` for the symbol with the specified
+// name, first creating it (and any missing parents) if needed.
+func (rgb *Builder) getGroup(symbol sizes.RefGroupSymbol) *refGroup {
+	if rg, ok := rgb.groups[symbol]; ok {
+		return rg
+	}
+
+	parentSymbol := parentName(symbol)
+	parent := rgb.getGroup(parentSymbol)
+
+	rg := refGroup{
+		RefGroup: sizes.RefGroup{
+			Symbol: symbol,
+		},
+		parent: parent,
+	}
+
+	rgb.groups[symbol] = &rg
+	parent.subgroups = append(parent.subgroups, &rg)
+	return &rg
+}
+
+// parentName returns the symbol of the refgroup that is the parent of
+// `symbol`, or "" if `symbol` is the top-level group.
+func parentName(symbol sizes.RefGroupSymbol) sizes.RefGroupSymbol {
+	i := strings.LastIndexByte(string(symbol), '.')
+	if i == -1 {
+		return ""
+	}
+	return symbol[:i]
+}
+
+// initializeStandardRefgroups initializes the built-in refgroups
+// ("branches", "tags", etc).
+func (rgb *Builder) initializeStandardRefgroups() {
+	initializeGroup := func(
+		symbol sizes.RefGroupSymbol, name string, filter git.ReferenceFilter,
+	) {
+		rg := rgb.getGroup(symbol)
+		rg.Name = name
+		rg.filter = filter
+	}
+
+	initializeGroup("branches", "Branches", git.PrefixFilter("refs/heads/"))
+	initializeGroup("tags", "Tags", git.PrefixFilter("refs/tags/"))
+	initializeGroup("remotes", "Remote-tracking refs", git.PrefixFilter("refs/remotes/"))
+	initializeGroup("pulls", "Pull request refs", git.PrefixFilter("refs/pull/"))
+
+	filter, err := git.RegexpFilter(`refs/changes/\d{2}/\d+/\d+`)
+	if err != nil {
+		panic("internal error")
+	}
+	initializeGroup("changes", "Changeset refs", filter)
+
+	// GitLab's merge requests, the commits that it keeps around so
+	// that they aren't pruned (e.g., because a discussion refers to
+	// them), and its deployments. (Gitea's pull request refs are in
+	// "pulls", like GitHub's.)
+	initializeGroup("merge-requests", "Merge request refs", git.PrefixFilter("refs/merge-requests/"))
+	initializeGroup("keep-around", "Keep-around refs", git.PrefixFilter("refs/keep-around/"))
+	initializeGroup("environments", "Environment refs", git.PrefixFilter("refs/environments/"))
+
+	initializeGroup("notes", "Git notes", git.PrefixFilter("refs/notes/"))
+
+	filter, err = git.RegexpFilter(`refs/stash`)
+	if err != nil {
+		panic("internal error")
+	}
+	initializeGroup("stash", "Git stash", filter)
+}
+
+// readRefgroupsFromGitconfig reads any refgroups defined in the
+// gitconfig into `rgb`. Any configuration settings for the built-in
+// groups are added to the pre-existing definitions of those groups.
+// The settings of each group are applied in the order that they
+// appear in the gitconfig.
+func (rgb *Builder) readRefgroupsFromGitconfig(
+	ctx context.Context, configger Configger,
+) error {
+	if configger == nil {
+		// At this point, it is not yet certain that the command was
+		// run inside a Git repository. If not, ignore this option
+		// (the command will error out anyway).
+		return nil
+	}
+
+	config, err := configger.GetConfigContext(ctx, "refgroup")
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range config.Entries {
+		symbol, field := splitKey(entry.Key)
+		if symbol == "" {
+			// E.g., `refgroup.include`, which doesn't belong to any
+			// refgroup.
+			continue
+		}
+		if err := checkSymbol(symbol); err != nil {
+			return fmt.Errorf("invalid gitconfig key '%s': %w", config.FullKey(entry.Key), err)
+		}
+
+		rg := rgb.getGroup(symbol)
+		if err := rg.applyConfig(field, entry.Value, config.FullKey(entry.Key)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// checkSymbol returns an error if `symbol` can't be the symbol of a
+// refgroup; i.e., if any of its dot-separated components is empty.
+func checkSymbol(symbol sizes.RefGroupSymbol) error {
+	for _, component := range strings.Split(string(symbol), ".") {
+		if component == "" {
+			return fmt.Errorf("refgroup name '%s' has an empty component", symbol)
+		}
+	}
+	return nil
+}
+
+// splitKey splits `key`, which is part of a gitconfig key, into the
+// refgroup symbol to which it applies and the field name within that
+// section.
+func splitKey(key string) (sizes.RefGroupSymbol, string) {
+	i := strings.LastIndexByte(key, '.')
+	if i == -1 {
+		return "", key
+	}
+	return sizes.RefGroupSymbol(key[:i]), key[i+1:]
+}
+
+// AddFilter combines `filter` into the top-level filter using
+// `combiner`; i.e., it causes the references that `filter` matches to
+// be walked (`git.Include`) or not walked (`git.Exclude`). The filters
+// are applied in the order that they are added.
+func (rgb *Builder) AddFilter(combiner git.Combiner, filter git.ReferenceFilter) {
+	rgb.topLevelGroup.filter = combiner.Combine(rgb.topLevelGroup.filter, filter)
+	rgb.filtered = true
+}
+
+// Filtered returns true iff any filters have been added to the
+// top-level filter (see `AddFilter()`); i.e., if the references to be
+// walked were chosen explicitly rather than by default.
+func (rgb *Builder) Filtered() bool {
+	return rgb.filtered
+}
+
+// ParseFilter interprets `s`, the argument of an option like
+// `--include` or `--exclude`, as a filter:
+//
+//   - If it is bracketed with `/` characters, it is a regexp, which
+//     must match the whole reference name.
+//
+//   - If it starts with `@`, it is the symbol of a refgroup, which
+//     must already be defined. The filter matches the references
+//     that the refgroup would match (see `GroupFilter()`).
+//
+//   - Otherwise, it is a prefix, which must match at a component
+//     boundary (see `git.PrefixFilter()`).
+func (rgb *Builder) ParseFilter(s string) (git.ReferenceFilter, error) {
+	if len(s) >= 2 && strings.HasPrefix(s, "/") && strings.HasSuffix(s, "/") {
+		pattern := s[1 : len(s)-1]
+		f, err := git.RegexpFilter(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regexp: %q", s)
+		}
+		return f, nil
+	}
+
+	if len(s) >= 1 && s[0] == '@' {
+		symbol := sizes.RefGroupSymbol(s[1:])
+		if symbol == "" {
+			return nil, errors.New("missing refgroup name")
+		}
+
+		refGroup := rgb.groups[symbol]
+		if refGroup == nil {
+			return nil, fmt.Errorf("undefined refgroup '%s'", symbol)
+		}
+
+		return refGroupFilter{refGroup}, nil
+	}
+
+	return git.PrefixFilter(s), nil
+}
+
+// GroupFilter returns a filter that matches the references that the
+// refgroup `symbol` would match. The refgroup must already be
+// defined, and can't be the top-level group.
+func (rgb *Builder) GroupFilter(symbol sizes.RefGroupSymbol) (git.ReferenceFilter, error) {
+	refGroup, ok := rgb.groups[symbol]
+	if !ok || symbol == "" {
+		return nil, fmt.Errorf("refgroup '%s' is not defined", symbol)
+	}
+	return refGroupFilter{refGroup}, nil
+}
+
+// Finish collects the information gained from processing the options
+// and returns a `sizes.RefGrouper`.
+func (rgb *Builder) Finish(defaultAll bool) (sizes.RefGrouper, error) {
+	if rgb.topLevelGroup.filter == nil {
+		// User didn't specify any reference options.
+		if defaultAll {
+			rgb.topLevelGroup.filter = git.AllReferencesFilter
+		} else {
+			rgb.topLevelGroup.filter = git.NoReferencesFilter
+		}
+	}
+
+	refGrouper := refGrouper{
+		topLevelGroup: rgb.topLevelGroup,
+	}
+
+	if err := refGrouper.fillInTree(refGrouper.topLevelGroup); err != nil {
+		return nil, err
+	}
+
+	if refGrouper.topLevelGroup.filter != nil {
+		refGrouper.ignoredRefGroup = &sizes.RefGroup{
+			Symbol: "ignored",
+			Name:   "Ignored",
+		}
+		refGrouper.refGroups = append(refGrouper.refGroups, *refGrouper.ignoredRefGroup)
+	}
+
+	return &refGrouper, nil
+}
+
+// refGrouper is a `sizes.RefGrouper` based on a hierarchy of nested
+// refgroups.
+type refGrouper struct {
+	topLevelGroup *refGroup
+	refGroups     []sizes.RefGroup
+
+	// ignoredRefGroup, if set, is the reference group for
+	// tallying references that don't match at all.
+	ignoredRefGroup *sizes.RefGroup
+}
+
+// fillInTree processes the refgroups in the tree rooted at `rg`,
+// setting default names where they are missing, verifying that they
+// are all defined, adding "Other" groups where needed, and adding the
+// refgroups in depth-first-traversal order to `refGrouper.refGroups`.
+func (refGrouper *refGrouper) fillInTree(rg *refGroup) error {
+	if rg.Name == "" {
+		_, rg.Name = splitKey(string(rg.Symbol))
+	}
+
+	if rg.filter == nil && len(rg.subgroups) == 0 {
+		return fmt.Errorf("refgroup '%s' is not defined", rg.Symbol)
+	}
+
+	refGrouper.refGroups = append(refGrouper.refGroups, rg.RefGroup)
+
+	for _, rg := range rg.subgroups {
+		if err := refGrouper.fillInTree(rg); err != nil {
+			return err
+		}
+	}
+
+	if len(rg.subgroups) != 0 {
+		var otherSymbol sizes.RefGroupSymbol
+		if rg.Symbol == "" {
+			otherSymbol = "other"
+		} else {
+			otherSymbol = sizes.RefGroupSymbol(fmt.Sprintf("%s.other", rg.Symbol))
+		}
+		rg.otherRefGroup = &sizes.RefGroup{
+			Symbol: otherSymbol,
+			Name:   "Other",
+		}
+		refGrouper.refGroups = append(refGrouper.refGroups, *rg.otherRefGroup)
+	}
+
+	return nil
+}
+
+// Categorize decides whether to walk the reference named `refname`
+// and which refgroup(s) it should be counted in.
+func (refGrouper *refGrouper) Categorize(refname string) (bool, []sizes.RefGroupSymbol) {
+	walk, symbols := refGrouper.topLevelGroup.collectSymbols(refname)
+	if !walk && refGrouper.ignoredRefGroup != nil {
+		symbols = append(symbols, refGrouper.ignoredRefGroup.Symbol)
+	}
+	return walk, symbols
+}
+
+// Groups returns a list of all defined refgroups, in the order that
+// they should be output.
+func (refGrouper *refGrouper) Groups() []sizes.RefGroup {
+	return refGrouper.refGroups
+}
@@ -1,4 +1,4 @@
-package refopts
+package refgroups
 
 import (
 	"fmt"
@@ -74,48 +74,31 @@ func (rg *refGroup) collectSymbols(refname string) (bool, []sizes.RefGroupSymbol
 	return walk, symbols
 }
 
-// augmentFromConfig augments `rg` based on configuration in the
-// gitconfig and returns the result. It is not considered an error if
-// there are no usable config entries for the filter.
-func (rg *refGroup) augmentFromConfig(configger Configger) error {
-	config, err := configger.GetConfig(fmt.Sprintf("refgroup.%s", rg.Symbol))
-	if err != nil {
-		return err
-	}
-
-	for _, entry := range config.Entries {
-		switch entry.Key {
-		case "name":
-			rg.Name = entry.Value
-		case "include":
-			rg.filter = git.Include.Combine(
-				rg.filter, git.PrefixFilter(entry.Value),
-			)
-		case "includeregexp":
-			f, err := git.RegexpFilter(entry.Value)
-			if err != nil {
-				return fmt.Errorf(
-					"invalid regular expression for '%s': %w",
-					config.FullKey(entry.Key), err,
-				)
-			}
-			rg.filter = git.Include.Combine(rg.filter, f)
-		case "exclude":
-			rg.filter = git.Exclude.Combine(
-				rg.filter, git.PrefixFilter(entry.Value),
-			)
-		case "excluderegexp":
-			f, err := git.RegexpFilter(entry.Value)
-			if err != nil {
-				return fmt.Errorf(
-					"invalid regular expression for '%s': %w",
-					config.FullKey(entry.Key), err,
-				)
-			}
-			rg.filter = git.Exclude.Combine(rg.filter, f)
-		default:
-			// Ignore unrecognized keys.
+// applyConfig applies the gitconfig setting `field` (e.g.,
+// "include"), whose full key (for error messages) is `fullKey`, to
+// `rg`. Unrecognized fields are ignored.
+func (rg *refGroup) applyConfig(field, value, fullKey string) error {
+	switch field {
+	case "name":
+		rg.Name = value
+	case "include":
+		rg.filter = git.Include.Combine(rg.filter, git.PrefixFilter(value))
+	case "includeregexp":
+		f, err := git.RegexpFilter(value)
+		if err != nil {
+			return fmt.Errorf("invalid regular expression for '%s': %w", fullKey, err)
+		}
+		rg.filter = git.Include.Combine(rg.filter, f)
+	case "exclude":
+		rg.filter = git.Exclude.Combine(rg.filter, git.PrefixFilter(value))
+	case "excluderegexp":
+		f, err := git.RegexpFilter(value)
+		if err != nil {
+			return fmt.Errorf("invalid regular expression for '%s': %w", fullKey, err)
 		}
+		rg.filter = git.Exclude.Combine(rg.filter, f)
+	default:
+		// Ignore unrecognized keys.
 	}
 
 	return nil
@@ -0,0 +1,338 @@
+package refgroups_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/refgroups"
+	"github.com/github/git-sizer/sizes"
+)
+
+// configger is a `refgroups.Configger` that holds gitconfig entries
+// (with full keys) in memory.
+type configger []git.ConfigEntry
+
+func (c configger) GetConfigContext(_ context.Context, prefix string) (*git.Config, error) {
+	config := git.Config{Prefix: prefix}
+	for _, entry := range c {
+		if strings.HasPrefix(entry.Key, prefix+".") {
+			config.Entries = append(config.Entries, git.ConfigEntry{
+				Key:   entry.Key[len(prefix)+1:],
+				Value: entry.Value,
+			})
+		}
+	}
+	return &config, nil
+}
+
+// failingConfigger is a `refgroups.Configger` that can't read the
+// gitconfig.
+type failingConfigger struct{}
+
+func (failingConfigger) GetConfigContext(_ context.Context, _ string) (*git.Config, error) {
+	return nil, errors.New("no config for you")
+}
+
+func symbols(ss ...string) []sizes.RefGroupSymbol {
+	ret := make([]sizes.RefGroupSymbol, 0, len(ss))
+	for _, s := range ss {
+		ret = append(ret, sizes.RefGroupSymbol(s))
+	}
+	return ret
+}
+
+func groupSymbols(rg sizes.RefGrouper) []sizes.RefGroupSymbol {
+	var ret []sizes.RefGroupSymbol
+	for _, g := range rg.Groups() {
+		ret = append(ret, g.Symbol)
+	}
+	return ret
+}
+
+func TestStandardRefgroups(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	rg, err := refgroups.New(ctx, nil)
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		symbols(
+			"", "branches", "tags", "remotes", "pulls", "changes",
+			"merge-requests", "keep-around", "environments", "notes", "stash",
+			"other", "ignored",
+		),
+		groupSymbols(rg),
+	)
+	assert.Equal(t, "Refs to walk", rg.Groups()[0].Name)
+	assert.Equal(t, "Branches", rg.Groups()[1].Name)
+
+	for _, p := range []struct {
+		refname  string
+		expected []sizes.RefGroupSymbol
+	}{
+		{"refs/heads/main", symbols("", "branches")},
+		{"refs/heads/feature/x", symbols("", "branches")},
+		{"refs/headsup", symbols("", "other")},
+		{"refs/tags/v1.0", symbols("", "tags")},
+		{"refs/remotes/origin/main", symbols("", "remotes")},
+		{"refs/pull/17/head", symbols("", "pulls")},
+		{"refs/changes/34/1234/2", symbols("", "changes")},
+		{"refs/changes/34/1234/meta", symbols("", "other")},
+		{"refs/merge-requests/5/head", symbols("", "merge-requests")},
+		{"refs/keep-around/0123", symbols("", "keep-around")},
+		{"refs/environments/prod/deployments/1", symbols("", "environments")},
+		{"refs/notes/commits", symbols("", "notes")},
+		{"refs/stash", symbols("", "stash")},
+		{"refs/stash/foo", symbols("", "other")},
+		{"refs/misc/foo", symbols("", "other")},
+		{"HEAD", symbols("", "other")},
+	} {
+		p := p
+		t.Run(p.refname, func(t *testing.T) {
+			walk, ss := rg.Categorize(p.refname)
+			assert.True(t, walk)
+			assert.Equal(t, p.expected, ss)
+		})
+	}
+}
+
+func TestGitconfigRefgroups(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	rg, err := refgroups.New(ctx, configger{
+		{Key: "refgroup.mine.name", Value: "My refs"},
+		{Key: "refgroup.mine.include", Value: "refs/heads/me"},
+		{Key: "refgroup.mine.includeregexp", Value: "refs/tags/me-.*"},
+		{Key: "refgroup.mine.exclude", Value: "refs/heads/me/tmp"},
+		{Key: "refgroup.mine.wip.include", Value: "refs/heads/me/wip"},
+		{Key: "refgroup.mine.wip.excluderegexp", Value: ".*/old"},
+		{Key: "refgroup.tags.exclude", Value: "refs/tags/nightly"},
+		{Key: "refgroup.ci.ephemeral.include", Value: "refs/ci/"},
+		{Key: "refgroup.mine.unknownfield", Value: "ignored"},
+		{Key: "refgroup.include", Value: "ignored, too"},
+	})
+	require.NoError(t, err)
+
+	assert.Equal(
+		t,
+		symbols(
+			"", "branches", "tags", "remotes", "pulls", "changes",
+			"merge-requests", "keep-around", "environments", "notes", "stash",
+			"mine", "mine.wip", "mine.other",
+			"ci", "ci.ephemeral", "ci.other",
+			"other", "ignored",
+		),
+		groupSymbols(rg),
+	)
+
+	names := make(map[sizes.RefGroupSymbol]string)
+	for _, g := range rg.Groups() {
+		names[g.Symbol] = g.Name
+	}
+	assert.Equal(t, "My refs", names["mine"])
+	// Groups without a name are named after their last component:
+	assert.Equal(t, "wip", names["mine.wip"])
+	assert.Equal(t, "ephemeral", names["ci.ephemeral"])
+	assert.Equal(t, "Other", names["mine.other"])
+
+	for _, p := range []struct {
+		refname  string
+		expected []sizes.RefGroupSymbol
+	}{
+		{"refs/heads/main", symbols("", "branches")},
+		{"refs/heads/me", symbols("", "branches", "mine", "mine.other")},
+		{"refs/heads/me/topic", symbols("", "branches", "mine", "mine.other")},
+		{"refs/heads/me/tmp/x", symbols("", "branches")},
+		{"refs/heads/me/wip/x", symbols("", "branches", "mine", "mine.wip")},
+		{"refs/heads/me/wip/old", symbols("", "branches", "mine", "mine.other")},
+		{"refs/tags/me-1.0", symbols("", "tags", "mine", "mine.other")},
+		{"refs/tags/nightly", symbols("", "other")},
+		{"refs/ci/1", symbols("", "ci", "ci.ephemeral")},
+		{"refs/cix", symbols("", "other")},
+	} {
+		p := p
+		t.Run(p.refname, func(t *testing.T) {
+			walk, ss := rg.Categorize(p.refname)
+			assert.True(t, walk)
+			assert.Equal(t, p.expected, ss)
+		})
+	}
+}
+
+func TestGitconfigErrors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	for _, p := range []struct {
+		name     string
+		config   refgroups.Configger
+		expected string
+	}{
+		{
+			name:     "unreadable",
+			config:   failingConfigger{},
+			expected: "no config for you",
+		},
+		{
+			name: "bad-include-regexp",
+			config: configger{
+				{Key: "refgroup.bad.includeregexp", Value: "refs/("},
+			},
+			expected: "invalid regular expression for 'refgroup.bad.includeregexp'",
+		},
+		{
+			name: "bad-exclude-regexp",
+			config: configger{
+				{Key: "refgroup.bad.include", Value: "refs/heads"},
+				{Key: "refgroup.bad.excluderegexp", Value: "[z-a]"},
+			},
+			expected: "invalid regular expression for 'refgroup.bad.excluderegexp'",
+		},
+		{
+			name: "empty-component",
+			config: configger{
+				{Key: "refgroup.a..b.include", Value: "refs/heads"},
+			},
+			expected: "refgroup name 'a..b' has an empty component",
+		},
+	} {
+		p := p
+		t.Run(p.name, func(t *testing.T) {
+			_, err := refgroups.NewBuilder(ctx, p.config)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), p.expected)
+		})
+	}
+
+	// A refgroup that has a name but no filter or subgroups is
+	// only detected when the refgrouper is finished:
+	b, err := refgroups.NewBuilder(ctx, configger{
+		{Key: "refgroup.empty.name", Value: "Nothing"},
+	})
+	require.NoError(t, err)
+	_, err = b.Finish(true)
+	assert.EqualError(t, err, "refgroup 'empty' is not defined")
+}
+
+func TestTopLevelFilter(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	config := configger{
+		{Key: "refgroup.release.include", Value: "refs/tags/release"},
+		{Key: "refgroup.release.include", Value: "refs/heads/release"},
+	}
+
+	for _, p := range []struct {
+		name       string
+		defaultAll bool
+		filters    []string // "+PATTERN" to include, "-PATTERN" to exclude
+		walked     []string
+	}{
+		{
+			name:       "default-all",
+			defaultAll: true,
+			walked:     []string{"refs/heads/main", "refs/heads/release/1", "refs/tags/v1", "refs/misc"},
+		},
+		{
+			name:       "default-none",
+			defaultAll: false,
+			walked:     nil,
+		},
+		{
+			name:    "prefix",
+			filters: []string{"+refs/heads"},
+			walked:  []string{"refs/heads/main", "refs/heads/release/1"},
+		},
+		{
+			name:    "prefix-then-exclude",
+			filters: []string{"+refs/heads", "-refs/heads/release"},
+			walked:  []string{"refs/heads/main"},
+		},
+		{
+			name:    "exclude-then-include",
+			filters: []string{"-refs/heads", "+refs/heads/main"},
+			walked:  []string{"refs/heads/main", "refs/tags/v1", "refs/misc"},
+		},
+		{
+			name:    "regexp",
+			filters: []string{"+/refs/.*/v[0-9]+/"},
+			walked:  []string{"refs/tags/v1"},
+		},
+		{
+			name:    "refgroup",
+			filters: []string{"+@release"},
+			walked:  []string{"refs/heads/release/1"},
+		},
+		{
+			name:    "nested-refgroup",
+			filters: []string{"+@tags", "+@branches", "-@release"},
+			walked:  []string{"refs/heads/main", "refs/tags/v1"},
+		},
+	} {
+		p := p
+		t.Run(p.name, func(t *testing.T) {
+			b, err := refgroups.NewBuilder(ctx, config)
+			require.NoError(t, err)
+			for _, f := range p.filters {
+				filter, err := b.ParseFilter(f[1:])
+				require.NoError(t, err)
+				if f[0] == '+' {
+					b.AddFilter(git.Include, filter)
+				} else {
+					b.AddFilter(git.Exclude, filter)
+				}
+			}
+			rg, err := b.Finish(p.defaultAll)
+			require.NoError(t, err)
+
+			var walked []string
+			for _, refname := range []string{
+				"refs/heads/main", "refs/heads/release/1", "refs/tags/v1", "refs/misc",
+			} {
+				walk, ss := rg.Categorize(refname)
+				if walk {
+					walked = append(walked, refname)
+				} else {
+					assert.Equal(t, symbols("ignored"), ss, "symbols of %s", refname)
+				}
+			}
+			assert.Equal(t, p.walked, walked)
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	t.Parallel()
+
+	b, err := refgroups.NewBuilder(context.Background(), nil)
+	require.NoError(t, err)
+
+	for _, s := range []string{"/refs/(/", "@", "@nonexistent"} {
+		_, err := b.ParseFilter(s)
+		assert.Error(t, err, "parsing %q", s)
+	}
+
+	for _, symbol := range []string{"", "nonexistent"} {
+		_, err := b.GroupFilter(sizes.RefGroupSymbol(symbol))
+		assert.EqualError(t, err, fmt.Sprintf("refgroup '%s' is not defined", symbol))
+	}
+
+	f, err := b.GroupFilter("tags")
+	require.NoError(t, err)
+	assert.True(t, f.Filter("refs/tags/v1"))
+	assert.False(t, f.Filter("refs/heads/main"))
+}
@@ -0,0 +1,215 @@
+package scanpb_test
+
+import (
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+
+	"github.com/github/git-sizer/scanpb"
+)
+
+// referenceFile compiles `scan_result.proto`, so that the types in
+// this package can be checked against the schema itself, and their
+// encoding against that of the official protobuf runtime.
+func referenceFile(t *testing.T) protoreflect.FileDescriptor {
+	t.Helper()
+
+	fds, err := protoparse.Parser{}.ParseFiles("scan_result.proto")
+	require.NoError(t, err)
+	require.Len(t, fds, 1)
+	fd, err := protodesc.NewFile(fds[0].AsFileDescriptorProto(), nil)
+	require.NoError(t, err)
+	return fd
+}
+
+// goFieldName returns the name of the Go field that corresponds to
+// the protobuf field `fd` (e.g., "RefGroups" for "ref_groups").
+func goFieldName(fd protoreflect.FieldDescriptor) string {
+	jsonName := fd.JSONName()
+	return strings.ToUpper(jsonName[:1]) + jsonName[1:]
+}
+
+// goFieldType returns the type that the Go field that corresponds to
+// `fd` must have.
+func goFieldType(t *testing.T, fd protoreflect.FieldDescriptor) reflect.Type {
+	t.Helper()
+
+	var typ reflect.Type
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		typ = reflect.TypeOf(false)
+	case protoreflect.Int64Kind:
+		typ = reflect.TypeOf(int64(0))
+	case protoreflect.Uint64Kind:
+		typ = reflect.TypeOf(uint64(0))
+	case protoreflect.DoubleKind:
+		typ = reflect.TypeOf(float64(0))
+	case protoreflect.StringKind:
+		typ = reflect.TypeOf("")
+	case protoreflect.MessageKind:
+		typ = goMessageTypes[fd.Message().Name()]
+		require.NotNil(t, typ, "no Go type for message %s", fd.Message().Name())
+		typ = reflect.PtrTo(typ)
+	default:
+		require.Failf(t, "unsupported field kind", "field %s has kind %s", fd.FullName(), fd.Kind())
+	}
+	if fd.IsList() {
+		typ = reflect.SliceOf(typ)
+	}
+	return typ
+}
+
+// goMessageTypes maps the name of each message in
+// `scan_result.proto` to the Go type that represents it.
+var goMessageTypes = map[protoreflect.Name]reflect.Type{
+	"ScanResult":  reflect.TypeOf(scanpb.ScanResult{}),
+	"Statistic":   reflect.TypeOf(scanpb.Statistic{}),
+	"CitedObject": reflect.TypeOf(scanpb.CitedObject{}),
+	"PathStep":    reflect.TypeOf(scanpb.PathStep{}),
+	"TopObject":   reflect.TypeOf(scanpb.TopObject{}),
+	"RefGroup":    reflect.TypeOf(scanpb.RefGroup{}),
+}
+
+func TestMatchesSchema(t *testing.T) {
+	t.Parallel()
+
+	messages := referenceFile(t).Messages()
+	assert.Equal(t, len(goMessageTypes), messages.Len(), "number of messages")
+	for i := 0; i < messages.Len(); i++ {
+		md := messages.Get(i)
+		goType, ok := goMessageTypes[md.Name()]
+		if !assert.True(t, ok, "no Go type for message %s", md.Name()) {
+			continue
+		}
+
+		fields := md.Fields()
+		assert.Equal(t, fields.Len(), goType.NumField(), "number of fields of %s", md.Name())
+		for j := 0; j < fields.Len(); j++ {
+			fd := fields.Get(j)
+			f, ok := goType.FieldByName(goFieldName(fd))
+			if assert.True(t, ok, "no Go field for %s", fd.FullName()) {
+				assert.Equal(t, goFieldType(t, fd), f.Type, "type of Go field for %s", fd.FullName())
+			}
+		}
+	}
+}
+
+// toDynamic copies the Go message `v` (a pointer to one of the types
+// in `goMessageTypes`) to a dynamic message described by `md`.
+func toDynamic(md protoreflect.MessageDescriptor, v reflect.Value) *dynamicpb.Message {
+	m := dynamicpb.NewMessage(md)
+	v = v.Elem()
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		f := v.FieldByName(goFieldName(fd))
+		scalar := func(f reflect.Value) protoreflect.Value {
+			if fd.Kind() == protoreflect.MessageKind {
+				return protoreflect.ValueOfMessage(toDynamic(fd.Message(), f))
+			}
+			return protoreflect.ValueOf(f.Interface())
+		}
+		switch {
+		case fd.IsList():
+			list := m.Mutable(fd).List()
+			for j := 0; j < f.Len(); j++ {
+				list.Append(scalar(f.Index(j)))
+			}
+		case fd.Kind() == protoreflect.MessageKind:
+			if !f.IsNil() {
+				m.Set(fd, scalar(f))
+			}
+		default:
+			m.Set(fd, scalar(f))
+		}
+	}
+	return m
+}
+
+// fromDynamic copies the dynamic message `m` to the Go message `v`
+// (a pointer to one of the types in `goMessageTypes`).
+func fromDynamic(m protoreflect.Message, v reflect.Value) {
+	v = v.Elem()
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		f := v.FieldByName(goFieldName(fd))
+		scalar := func(pv protoreflect.Value, typ reflect.Type) reflect.Value {
+			if fd.Kind() == protoreflect.MessageKind {
+				elem := reflect.New(typ.Elem())
+				fromDynamic(pv.Message(), elem)
+				return elem
+			}
+			return reflect.ValueOf(pv.Interface())
+		}
+		switch {
+		case fd.IsList():
+			list := m.Get(fd).List()
+			if list.Len() == 0 {
+				continue
+			}
+			s := reflect.MakeSlice(f.Type(), list.Len(), list.Len())
+			for j := 0; j < list.Len(); j++ {
+				s.Index(j).Set(scalar(list.Get(j), f.Type().Elem()))
+			}
+			f.Set(s)
+		case fd.Kind() == protoreflect.MessageKind:
+			if m.Has(fd) {
+				f.Set(scalar(m.Get(fd), f.Type()))
+			}
+		default:
+			f.Set(scalar(m.Get(fd), f.Type()))
+		}
+	}
+}
+
+func TestMatchesReferenceEncoding(t *testing.T) {
+	t.Parallel()
+
+	md := referenceFile(t).Messages().ByName("ScanResult")
+	require.NotNil(t, md)
+
+	for _, p := range []struct {
+		name string
+		r    scanpb.ScanResult
+	}{
+		{"full", exampleResult()},
+		{"empty", scanpb.ScanResult{}},
+		{"negative", scanpb.ScanResult{ScanTime: -1, EmptyRepository: true}},
+		{
+			"negative-zero",
+			scanpb.ScanResult{Statistics: []*scanpb.Statistic{{LevelOfConcern: math.Copysign(0, -1)}}},
+		},
+	} {
+		p := p
+		t.Run(p.name, func(t *testing.T) {
+			t.Parallel()
+
+			reference, err := proto.MarshalOptions{Deterministic: true}.Marshal(
+				toDynamic(md, reflect.ValueOf(&p.r)),
+			)
+			require.NoError(t, err)
+			// (Compare them as strings, so that nil and empty agree.)
+			assert.Equal(t, string(reference), string(p.r.Marshal()), "encoding")
+
+			var decoded scanpb.ScanResult
+			require.NoError(t, decoded.Unmarshal(reference))
+			assert.Equal(t, p.r, decoded, "decoding the reference encoding")
+
+			m := dynamicpb.NewMessage(md)
+			require.NoError(t, proto.Unmarshal(p.r.Marshal(), m))
+			decoded = scanpb.ScanResult{}
+			fromDynamic(m, reflect.ValueOf(&decoded))
+			assert.Equal(t, p.r, decoded, "decoding with the reference decoder")
+		})
+	}
+}
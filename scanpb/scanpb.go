@@ -0,0 +1,327 @@
+// Package scanpb holds the Go types of the messages defined in
+// `scan_result.proto`, the protobuf schema of the results of a
+// git-sizer scan, and encodes and decodes them in the protobuf wire
+// format. The types are written by hand so that the library doesn't
+// depend on a protobuf runtime; programs in other languages (or Go
+// programs that already use one) can generate their own types from
+// `scan_result.proto` instead. The tests check the types against
+// `scan_result.proto`, and their encoding against that of the
+// official protobuf runtime. See "Using git-sizer as a library" in
+// README.md for the compatibility guarantees.
+package scanpb
+
+// ScanResult is the `ScanResult` message: the results of one scan.
+type ScanResult struct {
+	ScanTime        int64
+	Statistics      []*Statistic
+	RefGroups       []*RefGroup
+	EmptyRepository bool
+}
+
+// Statistic is the `Statistic` message: one statistic, along with
+// the object that it cites.
+type Statistic struct {
+	Symbol         string
+	Description    string
+	Value          uint64
+	Unit           string
+	Prefixes       string
+	ReferenceValue float64
+	LevelOfConcern float64
+	Saturated      bool
+	Object         *CitedObject
+	RefGroups      []string
+	TopObjects     []*TopObject
+}
+
+// CitedObject is the `CitedObject` message: an object that a
+// statistic cites, and how it is reachable.
+type CitedObject struct {
+	Name        string
+	Description string
+	Path        []*PathStep
+}
+
+// PathStep is the `PathStep` message: one object along the path by
+// which a cited object is reachable.
+type PathStep struct {
+	ObjectType string
+	ObjectName string
+	Ref        string
+	Name       string
+}
+
+// TopObject is the `TopObject` message: one of the objects with the
+// biggest values of a statistic.
+type TopObject struct {
+	Value          uint64
+	LevelOfConcern float64
+	Object         *CitedObject
+}
+
+// RefGroup is the `RefGroup` message: a refgroup and the number of
+// references in it.
+type RefGroup struct {
+	Symbol         string
+	Name           string
+	ReferenceCount uint64
+}
+
+// Marshal returns `r` encoded in the protobuf wire format.
+func (r *ScanResult) Marshal() []byte {
+	return r.appendTo(nil)
+}
+
+func (r *ScanResult) appendTo(b []byte) []byte {
+	b = appendInt64Field(b, 1, r.ScanTime)
+	for _, s := range r.Statistics {
+		b = appendMessageField(b, 2, s)
+	}
+	for _, g := range r.RefGroups {
+		b = appendMessageField(b, 3, g)
+	}
+	b = appendBoolField(b, 4, r.EmptyRepository)
+	return b
+}
+
+// Unmarshal decodes `b`, a `ScanResult` message in the protobuf wire
+// format, into `r`. Fields that aren't known are skipped.
+func (r *ScanResult) Unmarshal(b []byte) error {
+	*r = ScanResult{}
+	d := decoder{b}
+	for {
+		field, wireType, ok, err := d.next()
+		if !ok || err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			r.ScanTime, err = d.int64Field(wireType)
+		case 2:
+			s := &Statistic{}
+			err = d.messageField(wireType, s)
+			r.Statistics = append(r.Statistics, s)
+		case 3:
+			g := &RefGroup{}
+			err = d.messageField(wireType, g)
+			r.RefGroups = append(r.RefGroups, g)
+		case 4:
+			r.EmptyRepository, err = d.boolField(wireType)
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Statistic) appendTo(b []byte) []byte {
+	b = appendStringField(b, 1, s.Symbol)
+	b = appendStringField(b, 2, s.Description)
+	b = appendUint64Field(b, 3, s.Value)
+	b = appendStringField(b, 4, s.Unit)
+	b = appendStringField(b, 5, s.Prefixes)
+	b = appendDoubleField(b, 6, s.ReferenceValue)
+	b = appendDoubleField(b, 7, s.LevelOfConcern)
+	b = appendBoolField(b, 8, s.Saturated)
+	if s.Object != nil {
+		b = appendMessageField(b, 9, s.Object)
+	}
+	for _, g := range s.RefGroups {
+		b = appendStringElement(b, 10, g)
+	}
+	for _, t := range s.TopObjects {
+		b = appendMessageField(b, 11, t)
+	}
+	return b
+}
+
+// Unmarshal decodes `b`, a `Statistic` message in the protobuf wire
+// format, into `s`.
+func (s *Statistic) Unmarshal(b []byte) error {
+	*s = Statistic{}
+	d := decoder{b}
+	for {
+		field, wireType, ok, err := d.next()
+		if !ok || err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			s.Symbol, err = d.stringField(wireType)
+		case 2:
+			s.Description, err = d.stringField(wireType)
+		case 3:
+			s.Value, err = d.uint64Field(wireType)
+		case 4:
+			s.Unit, err = d.stringField(wireType)
+		case 5:
+			s.Prefixes, err = d.stringField(wireType)
+		case 6:
+			s.ReferenceValue, err = d.doubleField(wireType)
+		case 7:
+			s.LevelOfConcern, err = d.doubleField(wireType)
+		case 8:
+			s.Saturated, err = d.boolField(wireType)
+		case 9:
+			s.Object = &CitedObject{}
+			err = d.messageField(wireType, s.Object)
+		case 10:
+			var g string
+			g, err = d.stringField(wireType)
+			s.RefGroups = append(s.RefGroups, g)
+		case 11:
+			t := &TopObject{}
+			err = d.messageField(wireType, t)
+			s.TopObjects = append(s.TopObjects, t)
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (o *CitedObject) appendTo(b []byte) []byte {
+	b = appendStringField(b, 1, o.Name)
+	b = appendStringField(b, 2, o.Description)
+	for _, step := range o.Path {
+		b = appendMessageField(b, 3, step)
+	}
+	return b
+}
+
+// Unmarshal decodes `b`, a `CitedObject` message in the protobuf
+// wire format, into `o`.
+func (o *CitedObject) Unmarshal(b []byte) error {
+	*o = CitedObject{}
+	d := decoder{b}
+	for {
+		field, wireType, ok, err := d.next()
+		if !ok || err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			o.Name, err = d.stringField(wireType)
+		case 2:
+			o.Description, err = d.stringField(wireType)
+		case 3:
+			step := &PathStep{}
+			err = d.messageField(wireType, step)
+			o.Path = append(o.Path, step)
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (p *PathStep) appendTo(b []byte) []byte {
+	b = appendStringField(b, 1, p.ObjectType)
+	b = appendStringField(b, 2, p.ObjectName)
+	b = appendStringField(b, 3, p.Ref)
+	b = appendStringField(b, 4, p.Name)
+	return b
+}
+
+// Unmarshal decodes `b`, a `PathStep` message in the protobuf wire
+// format, into `p`.
+func (p *PathStep) Unmarshal(b []byte) error {
+	*p = PathStep{}
+	d := decoder{b}
+	for {
+		field, wireType, ok, err := d.next()
+		if !ok || err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			p.ObjectType, err = d.stringField(wireType)
+		case 2:
+			p.ObjectName, err = d.stringField(wireType)
+		case 3:
+			p.Ref, err = d.stringField(wireType)
+		case 4:
+			p.Name, err = d.stringField(wireType)
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (t *TopObject) appendTo(b []byte) []byte {
+	b = appendUint64Field(b, 1, t.Value)
+	b = appendDoubleField(b, 2, t.LevelOfConcern)
+	if t.Object != nil {
+		b = appendMessageField(b, 3, t.Object)
+	}
+	return b
+}
+
+// Unmarshal decodes `b`, a `TopObject` message in the protobuf wire
+// format, into `t`.
+func (t *TopObject) Unmarshal(b []byte) error {
+	*t = TopObject{}
+	d := decoder{b}
+	for {
+		field, wireType, ok, err := d.next()
+		if !ok || err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			t.Value, err = d.uint64Field(wireType)
+		case 2:
+			t.LevelOfConcern, err = d.doubleField(wireType)
+		case 3:
+			t.Object = &CitedObject{}
+			err = d.messageField(wireType, t.Object)
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (g *RefGroup) appendTo(b []byte) []byte {
+	b = appendStringField(b, 1, g.Symbol)
+	b = appendStringField(b, 2, g.Name)
+	b = appendUint64Field(b, 3, g.ReferenceCount)
+	return b
+}
+
+// Unmarshal decodes `b`, a `RefGroup` message in the protobuf wire
+// format, into `g`.
+func (g *RefGroup) Unmarshal(b []byte) error {
+	*g = RefGroup{}
+	d := decoder{b}
+	for {
+		field, wireType, ok, err := d.next()
+		if !ok || err != nil {
+			return err
+		}
+		switch field {
+		case 1:
+			g.Symbol, err = d.stringField(wireType)
+		case 2:
+			g.Name, err = d.stringField(wireType)
+		case 3:
+			g.ReferenceCount, err = d.uint64Field(wireType)
+		default:
+			err = d.skip(wireType)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
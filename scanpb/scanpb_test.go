@@ -0,0 +1,135 @@
+package scanpb_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/github/git-sizer/scanpb"
+)
+
+// exampleResult returns a `ScanResult` that sets every field, some
+// of them to edge cases.
+func exampleResult() scanpb.ScanResult {
+	object := &scanpb.CitedObject{
+		Name:        "0123456789abcdef0123456789abcdef01234567",
+		Description: "refs/heads/main:big.dat",
+		Path: []*scanpb.PathStep{
+			{ObjectType: "commit", ObjectName: "89abcdef0123456789abcdef0123456789abcdef", Ref: "refs/heads/main"},
+			{ObjectType: "tree", ObjectName: "fedcba9876543210fedcba9876543210fedcba98"},
+			{ObjectType: "blob", ObjectName: "0123456789abcdef0123456789abcdef01234567", Name: "big.dat"},
+		},
+	}
+	return scanpb.ScanResult{
+		ScanTime: 1112911993,
+		Statistics: []*scanpb.Statistic{
+			{
+				Symbol:         "maxBlobSize",
+				Description:    "The size of the largest blob",
+				Value:          1 << 40,
+				Unit:           "B",
+				Prefixes:       "binary",
+				ReferenceValue: 10e6,
+				LevelOfConcern: 109951.1627776,
+				Object:         object,
+				RefGroups:      []string{"branches", ""},
+				TopObjects: []*scanpb.TopObject{
+					{Value: 1 << 40, LevelOfConcern: 109951.1627776, Object: object},
+					{Value: 1},
+				},
+			},
+			{
+				Symbol:    "uniqueBlobCount",
+				Value:     17,
+				Saturated: true,
+			},
+			// All of the fields have their default values:
+			{},
+		},
+		RefGroups: []*scanpb.RefGroup{
+			{Symbol: "branches", Name: "Branches", ReferenceCount: 3},
+			{Symbol: "tags", Name: "Tags"},
+		},
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	r := exampleResult()
+	var decoded scanpb.ScanResult
+	require.NoError(t, decoded.Unmarshal(r.Marshal()))
+	assert.Equal(t, r, decoded)
+
+	negative := scanpb.ScanResult{ScanTime: -1, EmptyRepository: true}
+	require.NoError(t, decoded.Unmarshal(negative.Marshal()))
+	assert.Equal(t, negative, decoded)
+}
+
+func TestWireFormat(t *testing.T) {
+	t.Parallel()
+
+	// The encoding of a message must not change, since other
+	// programs decode it using code generated from
+	// `scan_result.proto`:
+	g := scanpb.RefGroup{Symbol: "tags", Name: "Tags", ReferenceCount: 300}
+	assert.Equal(
+		t,
+		[]byte{
+			0x0a, 4, 't', 'a', 'g', 's',
+			0x12, 4, 'T', 'a', 'g', 's',
+			0x18, 0xac, 0x02,
+		},
+		(&scanpb.ScanResult{RefGroups: []*scanpb.RefGroup{&g}}).Marshal()[2:],
+	)
+
+	s := scanpb.Statistic{LevelOfConcern: 1.5}
+	assert.Equal(
+		t,
+		[]byte{0x39, 0, 0, 0, 0, 0, 0, 0xf8, 0x3f},
+		(&scanpb.ScanResult{Statistics: []*scanpb.Statistic{&s}}).Marshal()[2:],
+	)
+}
+
+func TestUnknownFields(t *testing.T) {
+	t.Parallel()
+
+	// A `RefGroup` written by a newer version, with a varint field
+	// 15, a fixed64 field 16, a string field 17, and a fixed32 field
+	// 18, none of which this version knows about:
+	b := []byte{
+		0x0a, 4, 't', 'a', 'g', 's',
+		0x78, 0x96, 0x01,
+		0x81, 0x01, 1, 2, 3, 4, 5, 6, 7, 8,
+		0x8a, 0x01, 2, 'h', 'i',
+		0x95, 0x01, 1, 2, 3, 4,
+		0x18, 7,
+	}
+	var g scanpb.RefGroup
+	require.NoError(t, g.Unmarshal(b))
+	assert.Equal(t, scanpb.RefGroup{Symbol: "tags", ReferenceCount: 7}, g)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	t.Parallel()
+
+	for _, p := range []struct {
+		name     string
+		b        []byte
+		expected string
+	}{
+		{"truncated-varint", []byte{0x18, 0x80}, "protobuf message is truncated"},
+		{"truncated-string", []byte{0x0a, 5, 'a'}, "protobuf message is truncated"},
+		{"truncated-double", []byte{0x39, 0, 0}, "protobuf message is truncated"},
+		{"field-zero", []byte{0x00, 0x01}, "invalid protobuf field number 0"},
+		{"wrong-wire-type", []byte{0x0d, 0, 0, 0, 0}, "unexpected protobuf wire type 5 for a string or message"},
+		{"bad-wire-type", []byte{0x7b}, "unsupported protobuf wire type 3"},
+	} {
+		p := p
+		t.Run(p.name, func(t *testing.T) {
+			var s scanpb.Statistic
+			assert.EqualError(t, s.Unmarshal(p.b), p.expected)
+		})
+	}
+}
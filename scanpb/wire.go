@@ -0,0 +1,207 @@
+package scanpb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// The protobuf wire types that are used by the messages in this
+// package (see https://protobuf.dev/programming-guides/encoding/).
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errTruncated = errors.New("protobuf message is truncated")
+
+func appendVarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func appendTag(b []byte, field int, wireType int) []byte {
+	return appendVarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+// The following functions append a field to `b`, unless its value is
+// the default, which proto3 doesn't encode.
+
+func appendUint64Field(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	return appendVarint(appendTag(b, field, wireVarint), v)
+}
+
+func appendInt64Field(b []byte, field int, v int64) []byte {
+	return appendUint64Field(b, field, uint64(v))
+}
+
+func appendBoolField(b []byte, field int, v bool) []byte {
+	if !v {
+		return b
+	}
+	return appendVarint(appendTag(b, field, wireVarint), 1)
+}
+
+func appendDoubleField(b []byte, field int, v float64) []byte {
+	if v == 0 && !math.Signbit(v) {
+		return b
+	}
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+	return append(appendTag(b, field, wireFixed64), buf[:]...)
+}
+
+func appendStringField(b []byte, field int, v string) []byte {
+	if v == "" {
+		return b
+	}
+	return appendStringElement(b, field, v)
+}
+
+// appendStringElement appends an element of a repeated string field
+// to `b`. Such elements are encoded even if they are empty.
+func appendStringElement(b []byte, field int, v string) []byte {
+	b = appendVarint(appendTag(b, field, wireBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+// appendMessageField appends the embedded message `m` to `b`. Unlike
+// scalars, an embedded message is encoded even if it is empty, so
+// that repeated fields keep all of their elements.
+func appendMessageField(b []byte, field int, m interface{ appendTo([]byte) []byte }) []byte {
+	body := m.appendTo(nil)
+	b = appendVarint(appendTag(b, field, wireBytes), uint64(len(body)))
+	return append(b, body...)
+}
+
+// decoder reads the fields of one message.
+type decoder struct {
+	b []byte
+}
+
+// next returns the number and wire type of the next field, or false
+// if there are no more fields.
+func (d *decoder) next() (int, int, bool, error) {
+	if len(d.b) == 0 {
+		return 0, 0, false, nil
+	}
+	tag, err := d.varint()
+	if err != nil {
+		return 0, 0, false, err
+	}
+	field := tag >> 3
+	if field == 0 || field > math.MaxInt32 {
+		return 0, 0, false, fmt.Errorf("invalid protobuf field number %d", field)
+	}
+	return int(field), int(tag & 7), true, nil
+}
+
+func (d *decoder) varint() (uint64, error) {
+	v, n := binary.Uvarint(d.b)
+	if n <= 0 {
+		return 0, errTruncated
+	}
+	d.b = d.b[n:]
+	return v, nil
+}
+
+func (d *decoder) fixed64() (uint64, error) {
+	if len(d.b) < 8 {
+		return 0, errTruncated
+	}
+	v := binary.LittleEndian.Uint64(d.b)
+	d.b = d.b[8:]
+	return v, nil
+}
+
+func (d *decoder) bytes() ([]byte, error) {
+	n, err := d.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(d.b)) {
+		return nil, errTruncated
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v, nil
+}
+
+// The following functions read a field of the specified type, after
+// checking that it was encoded with the corresponding wire type.
+
+func (d *decoder) uint64Field(wireType int) (uint64, error) {
+	if wireType != wireVarint {
+		return 0, fmt.Errorf("unexpected protobuf wire type %d for an integer", wireType)
+	}
+	return d.varint()
+}
+
+func (d *decoder) int64Field(wireType int) (int64, error) {
+	v, err := d.uint64Field(wireType)
+	return int64(v), err
+}
+
+func (d *decoder) boolField(wireType int) (bool, error) {
+	v, err := d.uint64Field(wireType)
+	return v != 0, err
+}
+
+func (d *decoder) doubleField(wireType int) (float64, error) {
+	if wireType != wireFixed64 {
+		return 0, fmt.Errorf("unexpected protobuf wire type %d for a double", wireType)
+	}
+	v, err := d.fixed64()
+	return math.Float64frombits(v), err
+}
+
+func (d *decoder) bytesField(wireType int) ([]byte, error) {
+	if wireType != wireBytes {
+		return nil, fmt.Errorf("unexpected protobuf wire type %d for a string or message", wireType)
+	}
+	return d.bytes()
+}
+
+func (d *decoder) stringField(wireType int) (string, error) {
+	v, err := d.bytesField(wireType)
+	return string(v), err
+}
+
+// messageField decodes an embedded message into `m`.
+func (d *decoder) messageField(wireType int, m interface{ Unmarshal([]byte) error }) error {
+	v, err := d.bytesField(wireType)
+	if err != nil {
+		return err
+	}
+	return m.Unmarshal(v)
+}
+
+// skip skips a field that the reader doesn't know about.
+func (d *decoder) skip(wireType int) error {
+	switch wireType {
+	case wireVarint:
+		_, err := d.varint()
+		return err
+	case wireFixed64:
+		_, err := d.fixed64()
+		return err
+	case wireBytes:
+		_, err := d.bytes()
+		return err
+	case wireFixed32:
+		if len(d.b) < 4 {
+			return errTruncated
+		}
+		d.b = d.b[4:]
+		return nil
+	default:
+		return fmt.Errorf("unsupported protobuf wire type %d", wireType)
+	}
+}
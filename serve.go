@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/github/git-sizer/meter"
+)
+
+// serveProtocolVersion is the version of the protocol spoken by
+// `--serve-stdio`. It is announced in the "ready" message, and is
+// only incremented for changes that existing clients can't ignore.
+const serveProtocolVersion = 1
+
+// maxServeRequestSize is the largest request that `--serve-stdio`
+// accepts, in bytes.
+const maxServeRequestSize = 1 << 20
+
+// serveProgressPeriod is how often progress events are sent while a
+// phase of a scan is running.
+const serveProgressPeriod = 250 * time.Millisecond
+
+// serveOptions are the long options that a `--serve-stdio` request
+// may use. They only affect what is scanned and how it is reported.
+// Options that write files, run commands, read files named by the
+// client, access the network, or change the output format aren't
+// listed, so that a client can't make the worker do any of those
+// things.
+var serveOptions = map[string]bool{
+	// Judging and naming:
+	"verbose":         true,
+	"no-verbose":      true,
+	"threshold":       true,
+	"critical":        true,
+	"profile":         true,
+	"reference-value": true,
+	"concern-weight":  true,
+	"doc-link":        true,
+	"names":           true,
+	"names-budget":    true,
+	"anonymize":       true,
+	"json-version":    true,
+
+	// Reference selection:
+	"head":           true,
+	"no-head":        true,
+	"include":        true,
+	"include-regexp": true,
+	"exclude":        true,
+	"exclude-regexp": true,
+	"branches":       true,
+	"no-branches":    true,
+	"tags":           true,
+	"no-tags":        true,
+	"remotes":        true,
+	"no-remotes":     true,
+	"notes":          true,
+	"no-notes":       true,
+	"stash":          true,
+	"no-stash":       true,
+	"refgroup":       true,
+	"allow-shallow":  true,
+
+	// Statistics and analyses:
+	"stats":                     true,
+	"sections":                  true,
+	"skip-sections":             true,
+	"exact-counts":              true,
+	"max-expanded-entries":      true,
+	"stale-ref-age":             true,
+	"top-objects":               true,
+	"anomaly-examples":          true,
+	"notes-ref":                 true,
+	"strict-attribution":        true,
+	"objects-since":             true,
+	"list-ignored-refs":         true,
+	"exact-checkout":            true,
+	"checkout-extensions":       true,
+	"sharing-matrix":            true,
+	"shared-trees":              true,
+	"size-budget-report":        true,
+	"import-artifacts":          true,
+	"health-score":              true,
+	"age-buckets":               true,
+	"compressibility":           true,
+	"line-ending-duplicates":    true,
+	"normalized-duplicates":     true,
+	"recent-blobs":              true,
+	"recent-commits":            true,
+	"refgroup-activity":         true,
+	"empty-commits-by-refgroup": true,
+	"packfiles":                 true,
+	"index-reflog-names":        true,
+	"reflogs":                   true,
+	"reflog-expire":             true,
+	"force-pushes":              true,
+	"ref-churn":                 true,
+	"redundant-refs":            true,
+	"refgroup-attribution":      true,
+	"unreachable":               true,
+	"prune-expire":              true,
+	"top-committers":            true,
+	"file-lineage":              true,
+	"bloom-filters":             true,
+	"lfs-cutoff":                true,
+	"hosting-limits":            true,
+	"clone-bandwidth":           true,
+	"clone-latency":             true,
+	"max-duration":              true,
+}
+
+// serveShortOptions are the short options that a `--serve-stdio`
+// request may use (see `serveOptions`).
+const serveShortOptions = "v"
+
+// checkServeArgs returns an error if `args`, the arguments of a
+// `--serve-stdio` request, use any option that isn't listed in
+// `serveOptions` or `serveShortOptions`. Arguments that aren't
+// options are ROOTs or the values of the preceding options. An
+// option's value could be mistaken for an option here, but that only
+// causes the request to be rejected.
+func checkServeArgs(args []string) error {
+	for _, arg := range args {
+		switch {
+		case arg == "--":
+			// The remaining arguments are ROOTs.
+			return nil
+		case strings.HasPrefix(arg, "--"):
+			name := arg[2:]
+			if i := strings.IndexByte(name, '='); i != -1 {
+				name = name[:i]
+			}
+			if !serveOptions[name] {
+				return fmt.Errorf("option '--%s' can't be used in a request", name)
+			}
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			for _, c := range arg[1:] {
+				if !strings.ContainsRune(serveShortOptions, c) {
+					return fmt.Errorf("option '-%c' can't be used in a request", c)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// serveRequest is a request read by `--serve-stdio`.
+type serveRequest struct {
+	// ID is chosen by the client, and is copied to the messages that
+	// answer the request.
+	ID string `json:"id"`
+
+	// Type is the kind of request. Only "scan" is supported.
+	Type string `json:"type"`
+
+	// Dir is the directory of the repository to scan. If it is
+	// empty, the repository in the worker's working directory is
+	// scanned.
+	Dir string `json:"dir,omitempty"`
+
+	// Args are the command-line arguments of the scan. They default
+	// to compact JSON output, version 2. Only the options in
+	// `serveOptions` are allowed.
+	Args []string `json:"args,omitempty"`
+}
+
+// serveMessage is a message written by `--serve-stdio`. Its type is
+// "ready" (once, on startup), "progress" (any number of times while
+// a scan is running), or "result" or "error" (once per request).
+type serveMessage struct {
+	ID   string `json:"id,omitempty"`
+	Type string `json:"type"`
+
+	// The following are set for "ready" messages:
+	ProtocolVersion int    `json:"protocolVersion,omitempty"`
+	Version         string `json:"version,omitempty"`
+
+	// The following are set for "progress" messages:
+	Phase string `json:"phase,omitempty"`
+	Count int64  `json:"count,omitempty"`
+	Total int64  `json:"total,omitempty"`
+	Done  bool   `json:"done,omitempty"`
+
+	// Rate and ByteRate are the recent numbers of items and bytes
+	// processed per second, if known. See `meter.Update`.
+	Rate     float64 `json:"rate,omitempty"`
+	ByteRate float64 `json:"byteRate,omitempty"`
+
+	// Result is the JSON output of a successful scan.
+	Result json.RawMessage `json:"result,omitempty"`
+
+	// Error describes why a request failed.
+	Error string `json:"error,omitempty"`
+
+	// Stderr holds anything else that the scan reported (e.g.,
+	// warnings).
+	Stderr string `json:"stderr,omitempty"`
+}
+
+// serveWriter writes the messages of `--serve-stdio`. It can be used
+// from several goroutines at once.
+type serveWriter struct {
+	lock sync.Mutex
+	w    io.Writer
+}
+
+// write writes `msg` to `sw`, preceded by its length as a 32-bit
+// big-endian number.
+func (sw *serveWriter) write(msg serveMessage) error {
+	j, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4+len(j))
+	binary.BigEndian.PutUint32(buf, uint32(len(j)))
+	copy(buf[4:], j)
+
+	sw.lock.Lock()
+	defer sw.lock.Unlock()
+	_, err = sw.w.Write(buf)
+	return err
+}
+
+// readServeRequest reads the next request from `r`, which is
+// preceded by its length as a 32-bit big-endian number. It returns
+// `io.EOF` if `r` ends before the next request. The error is wrapped
+// in a `badRequestError` if the request could be read but not parsed,
+// in which case the next request can still be read.
+func readServeRequest(r io.Reader) (serveRequest, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return serveRequest{}, errors.New("truncated request length")
+		}
+		return serveRequest{}, err
+	}
+
+	size := binary.BigEndian.Uint32(header[:])
+	if size > maxServeRequestSize {
+		return serveRequest{}, fmt.Errorf(
+			"request is too long (%d bytes; the maximum is %d)", size, maxServeRequestSize,
+		)
+	}
+
+	body := make([]byte, size)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return serveRequest{}, errors.New("truncated request")
+	}
+
+	var req serveRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return serveRequest{}, badRequestError{fmt.Errorf("parsing request: %w", err)}
+	}
+	return req, nil
+}
+
+// badRequestError is returned by `readServeRequest()` for a request
+// that has the right framing, but can't be parsed.
+type badRequestError struct {
+	err error
+}
+
+func (e badRequestError) Error() string {
+	return e.err.Error()
+}
+
+func (e badRequestError) Unwrap() error {
+	return e.err
+}
+
+// serveStdio reads scan requests from `r` and handles them one at a
+// time, writing the progress events and the results to `w`, until
+// `r` is closed. It only returns an error if the messages can't be
+// read or written.
+func serveStdio(ctx context.Context, r io.Reader, w io.Writer) error {
+	sw := &serveWriter{w: w}
+
+	version := ReleaseVersion
+	if version == "" {
+		version = BuildVersion
+	}
+	if err := sw.write(serveMessage{
+		Type:            "ready",
+		ProtocolVersion: serveProtocolVersion,
+		Version:         version,
+	}); err != nil {
+		return err
+	}
+
+	for {
+		req, err := readServeRequest(r)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			var bre badRequestError
+			if errors.As(err, &bre) {
+				if err := sw.write(serveMessage{Type: "error", Error: err.Error()}); err != nil {
+					return err
+				}
+				continue
+			}
+			return fmt.Errorf("reading request: %w", err)
+		}
+
+		if err := sw.write(handleServeRequest(ctx, sw, req)); err != nil {
+			return err
+		}
+	}
+}
+
+// handleServeRequest carries out `req`, writing progress events to
+// `sw`, and returns the message that answers it.
+func handleServeRequest(ctx context.Context, sw *serveWriter, req serveRequest) serveMessage {
+	fail := func(err error, stderr string) serveMessage {
+		return serveMessage{
+			ID:     req.ID,
+			Type:   "error",
+			Error:  err.Error(),
+			Stderr: strings.TrimSpace(stderr),
+		}
+	}
+
+	if req.Type != "scan" {
+		return fail(fmt.Errorf("unknown request type %q", req.Type), "")
+	}
+
+	if err := checkServeArgs(req.Args); err != nil {
+		return fail(err, "")
+	}
+
+	env := runEnv{
+		dir: req.Dir,
+		progress: meter.NewCallbackProgress(
+			func(u meter.Update) {
+				// If the client has gone away, the next result
+				// can't be written either, so the error is
+				// reported then.
+				_ = sw.write(serveMessage{
+					ID:       req.ID,
+					Type:     "progress",
+					Phase:    u.Phase,
+					Count:    u.Count,
+					Total:    u.Total,
+					Done:     u.Done,
+					Rate:     u.Rate,
+					ByteRate: u.ByteRate,
+				})
+			},
+			serveProgressPeriod,
+		),
+	}
+	if env.dir == "" {
+		env.dir = "."
+	}
+
+	// The client's arguments come last, so that any ROOTs can
+	// follow '--':
+	args := make([]string, 0, len(req.Args)+3)
+	args = append(args, "--json", "--json-version=2", "--json-compact")
+	args = append(args, req.Args...)
+
+	var stdout, stderr bytes.Buffer
+	if err := runSizer(ctx, env, &stdout, &stderr, args); err != nil {
+		return fail(err, stderr.String())
+	}
+
+	result := bytes.TrimSpace(stdout.Bytes())
+	if !json.Valid(result) {
+		return fail(
+			errors.New("the request didn't produce a JSON report (was '--help' requested?)"),
+			stderr.String(),
+		)
+	}
+
+	return serveMessage{
+		ID:     req.ID,
+		Type:   "result",
+		Result: result,
+		Stderr: strings.TrimSpace(stderr.String()),
+	}
+}
@@ -0,0 +1,162 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// AgeBucket holds the number and total size of the unique objects
+// that first appeared in the history in one year; i.e., whose
+// earliest containing commit was committed in that year (in UTC).
+type AgeBucket struct {
+	// Year is the year of the bucket, or 0 for the objects that
+	// aren't contained in any commit (e.g., annotated tags, and
+	// trees and blobs that references point at directly).
+	Year int `json:"year"`
+
+	ObjectCount counts.Count64 `json:"object_count"`
+	ObjectSize  counts.Count64 `json:"object_size"`
+
+	// BlobCount and BlobSize are the number and total size of the
+	// blobs among the objects, which usually dominate the size.
+	BlobCount counts.Count64 `json:"blob_count"`
+	BlobSize  counts.Count64 `json:"blob_size"`
+}
+
+// computeAgeBuckets groups the unique objects that are reachable from
+// the walked `roots` by the year of the earliest commit that contains
+// them, and stores the results in `s.AgeBuckets`, oldest first.
+func (s *HistorySize) computeAgeBuckets(
+	ctx context.Context, repo *git.Repository, roots []Root,
+	progressMeter meter.Progress,
+) error {
+	var tips []git.OID
+	for _, root := range roots {
+		if root.Walk() {
+			tips = append(tips, root.OID())
+		}
+	}
+
+	progressMeter.Start("Dating objects: %d")
+	times, err := repo.FirstSeenTimes(ctx, tips)
+	progressMeter.Done()
+	if err != nil {
+		return err
+	}
+
+	objIter, err := repo.NewObjectIter(ctx)
+	if err != nil {
+		return err
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		defer objIter.Close()
+
+		errChan <- func() error {
+			for _, oid := range tips {
+				if err := objIter.AddRoot(oid); err != nil {
+					return err
+				}
+			}
+			return nil
+		}()
+	}()
+
+	buckets := make(map[int]*AgeBucket)
+
+	progressMeter.Start("Grouping objects by age: %d")
+	for {
+		obj, ok, err := objIter.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		progressMeter.Inc()
+
+		var year int
+		if t, ok := times[obj.OID]; ok {
+			year = time.Unix(t, 0).UTC().Year()
+		}
+		bucket, ok := buckets[year]
+		if !ok {
+			bucket = &AgeBucket{Year: year}
+			buckets[year] = bucket
+		}
+		bucket.ObjectCount.Increment(1)
+		bucket.ObjectSize.Increment(counts.Count64(obj.ObjectSize))
+		if obj.ObjectType == "blob" {
+			bucket.BlobCount.Increment(1)
+			bucket.BlobSize.Increment(counts.Count64(obj.ObjectSize))
+		}
+	}
+	progressMeter.Done()
+
+	if err := <-errChan; err != nil {
+		return err
+	}
+
+	s.AgeBuckets = make([]AgeBucket, 0, len(buckets))
+	for _, bucket := range buckets {
+		s.AgeBuckets = append(s.AgeBuckets, *bucket)
+	}
+	// Sort oldest first, with the undated objects last:
+	sort.Slice(s.AgeBuckets, func(i, j int) bool {
+		yi, yj := s.AgeBuckets[i].Year, s.AgeBuckets[j].Year
+		if (yi == 0) != (yj == 0) {
+			return yj == 0
+		}
+		return yi < yj
+	})
+
+	return nil
+}
+
+// AgeBucketsTableString returns a table showing the unique objects
+// grouped by the year in which they first appeared, or the empty
+// string if they weren't requested.
+func (s *HistorySize) AgeBucketsTableString() string {
+	if s.AgeBuckets == nil {
+		return ""
+	}
+
+	var totalSize counts.Count64
+	for _, b := range s.AgeBuckets {
+		totalSize.Increment(b.ObjectSize)
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nUnique objects by the year of the earliest commit containing them:\n\n")
+	fmt.Fprintln(buf, "| Year    | Objects   | Size      | Share  | Blobs     | Blob size |")
+	fmt.Fprintln(buf, "| ------- | --------- | --------- | ------ | --------- | --------- |")
+	for _, b := range s.AgeBuckets {
+		year := strconv.Itoa(b.Year)
+		if b.Year == 0 {
+			year = "undated"
+		}
+		var share float64
+		if totalSize != 0 {
+			share = 100 * float64(b.ObjectSize) / float64(totalSize)
+		}
+		fmt.Fprintf(
+			buf, "| %-7s | %s | %s | %5.1f%% | %s | %s |\n",
+			year,
+			formatGrowthValue(b.ObjectCount, &counts.Metric, ""),
+			formatGrowthValue(b.ObjectSize, &counts.Binary, "B"),
+			share,
+			formatGrowthValue(b.BlobCount, &counts.Metric, ""),
+			formatGrowthValue(b.BlobSize, &counts.Binary, "B"),
+		)
+	}
+	return buf.String()
+}
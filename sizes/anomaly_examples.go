@@ -0,0 +1,121 @@
+package sizes
+
+import (
+	"bytes"
+	"container/heap"
+	"sort"
+
+	"github.com/github/git-sizer/git"
+)
+
+// AnomalyExample is one of the objects that were counted by an
+// anomaly statistic (e.g., "windowsUnsafeEntryCount"). See
+// `ScanOptions.AnomalyExamples`.
+type AnomalyExample struct {
+	// OID is the object, or, for statistics that count tree entries,
+	// the tree containing the entry.
+	OID        git.OID `json:"oid"`
+	ObjectType string  `json:"object_type"`
+
+	// EntryName is the name of the entry, for statistics that count
+	// tree entries.
+	EntryName string `json:"entry_name,omitempty"`
+}
+
+// less orders examples by OID, then by entry name.
+func (e AnomalyExample) less(other AnomalyExample) bool {
+	if c := bytes.Compare(e.OID.Bytes(), other.OID.Bytes()); c != 0 {
+		return c < 0
+	}
+	return e.EntryName < other.EntryName
+}
+
+// anomalyExampleHeap is a max-heap of `AnomalyExample`s, so that the
+// one with the highest OID can be evicted when a lower one is found.
+type anomalyExampleHeap []AnomalyExample
+
+func (h anomalyExampleHeap) Len() int            { return len(h) }
+func (h anomalyExampleHeap) Less(i, j int) bool  { return h[j].less(h[i]) }
+func (h anomalyExampleHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *anomalyExampleHeap) Push(x interface{}) { *h = append(*h, x.(AnomalyExample)) }
+
+func (h *anomalyExampleHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// anomalyExampleCollector keeps track of the examples with the lowest
+// OIDs for one statistic. Since the set of objects that are counted
+// doesn't depend on the order in which they are processed, neither do
+// the examples.
+type anomalyExampleCollector struct {
+	heap anomalyExampleHeap
+
+	// seen is the set of examples in `heap`, so that an object that
+	// is recorded more than once is only listed once.
+	seen map[AnomalyExample]struct{}
+}
+
+// recordAnomalyExample considers the object `oid`, of the specified
+// type, as an example for the anomaly statistic `symbol`. The caller
+// must hold `g.historyLock`.
+func (g *Graph) recordAnomalyExample(symbol string, oid git.OID, objectType string) {
+	g.addAnomalyExample(symbol, AnomalyExample{OID: oid, ObjectType: objectType})
+}
+
+// recordAnomalyEntryExample is like `recordAnomalyExample()`, but for
+// the entry `name` of the tree `oid`.
+func (g *Graph) recordAnomalyEntryExample(symbol string, oid git.OID, name string) {
+	g.addAnomalyExample(symbol, AnomalyExample{
+		OID:        oid,
+		ObjectType: "tree",
+		EntryName:  g.historySize.anonymizer.Path(name),
+	})
+}
+
+func (g *Graph) addAnomalyExample(symbol string, e AnomalyExample) {
+	if g.anomalyExamples <= 0 || !g.historySize.stats.Contains(symbol) {
+		return
+	}
+
+	c, ok := g.anomalyExampleCollectors[symbol]
+	if !ok {
+		c = &anomalyExampleCollector{seen: make(map[AnomalyExample]struct{})}
+		g.anomalyExampleCollectors[symbol] = c
+	}
+	if _, ok := c.seen[e]; ok {
+		return
+	}
+
+	if len(c.heap) == g.anomalyExamples {
+		if !e.less(c.heap[0]) {
+			return
+		}
+		evicted := heap.Pop(&c.heap).(AnomalyExample)
+		delete(c.seen, evicted)
+	}
+
+	heap.Push(&c.heap, e)
+	c.seen[e] = struct{}{}
+}
+
+// findAnomalyExamples fills in `s.AnomalyExamples` from the examples
+// recorded by `recordAnomalyExample()` and
+// `recordAnomalyEntryExample()`, lowest OID first.
+func (s *HistorySize) findAnomalyExamples(g *Graph) {
+	g.historyLock.Lock()
+	defer g.historyLock.Unlock()
+
+	if len(g.anomalyExampleCollectors) == 0 {
+		return
+	}
+
+	s.AnomalyExamples = make(map[string][]AnomalyExample, len(g.anomalyExampleCollectors))
+	for symbol, c := range g.anomalyExampleCollectors {
+		examples := append([]AnomalyExample(nil), c.heap...)
+		sort.Slice(examples, func(i, j int) bool { return examples[i].less(examples[j]) })
+		s.AnomalyExamples[symbol] = examples
+	}
+}
@@ -0,0 +1,172 @@
+package sizes
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/github/git-sizer/git"
+)
+
+// anonymizedRefPrefixes are the reference namespaces whose names are
+// kept when a refname is anonymized, because they say what kind of
+// reference it is without revealing anything about the project.
+var anonymizedRefPrefixes = []string{
+	"refs/heads/",
+	"refs/tags/",
+	"refs/remotes/",
+	"refs/notes/",
+	"refs/pull/",
+	"refs/merge-requests/",
+	"refs/keep-around/",
+	"refs/environments/",
+	"refs/replace/",
+}
+
+// Anonymizer replaces the components of paths and refnames with
+// opaque names, so that the output can be shared without revealing
+// anything about the project, in the spirit of `git fast-export
+// --anonymize`. The same component is always replaced by the same
+// name, so the structure of the names is preserved. The names are
+// derived using a random key that is chosen anew for each
+// `Anonymizer`, so they can't be reversed by guessing.
+//
+// A nil `*Anonymizer` is valid, and leaves names unchanged.
+type Anonymizer struct {
+	key [32]byte
+}
+
+// NewAnonymizer creates an `Anonymizer` with a new random key.
+func NewAnonymizer() (*Anonymizer, error) {
+	var a Anonymizer
+	if _, err := rand.Read(a.key[:]); err != nil {
+		return nil, fmt.Errorf("generating anonymization key: %w", err)
+	}
+	return &a, nil
+}
+
+// Path returns an anonymized version of `path`, a slash-separated
+// path within a tree.
+func (a *Anonymizer) Path(path string) string {
+	if a == nil {
+		return path
+	}
+	return a.components("path", path)
+}
+
+// Refname returns an anonymized version of `refname`. The "refs/"
+// prefix and well-known namespaces like "refs/heads/" are retained,
+// as is "HEAD". This is also used for the names of explicit roots,
+// which needn't be refnames.
+func (a *Anonymizer) Refname(refname string) string {
+	if a == nil || refname == "HEAD" {
+		return refname
+	}
+
+	prefix := ""
+	for _, p := range anonymizedRefPrefixes {
+		if strings.HasPrefix(refname, p) {
+			prefix = p
+			break
+		}
+	}
+	if prefix == "" && strings.HasPrefix(refname, "refs/") {
+		prefix = "refs/"
+	}
+
+	return prefix + a.components("ref", refname[len(prefix):])
+}
+
+// Extension returns an anonymized version of the file extension
+// `ext`, which starts with ".". The "." is kept.
+func (a *Anonymizer) Extension(ext string) string {
+	if a == nil {
+		return ext
+	}
+	return "." + a.name("ext", strings.TrimPrefix(ext, "."))
+}
+
+// Identity returns an anonymized version of `identity`, the name and
+// email address of an author or committer.
+func (a *Anonymizer) Identity(identity string) string {
+	if a == nil {
+		return identity
+	}
+	return a.name("ident", identity)
+}
+
+// components anonymizes each of the slash-separated components of
+// `s`, using `kind` as the prefix of the new names.
+func (a *Anonymizer) components(kind, s string) string {
+	components := strings.Split(s, "/")
+	for i, c := range components {
+		if c != "" {
+			components[i] = a.name(kind, c)
+		}
+	}
+	return strings.Join(components, "/")
+}
+
+// name returns the anonymized name for `s`.
+func (a *Anonymizer) name(kind, s string) string {
+	mac := hmac.New(sha256.New, a.key[:])
+	mac.Write([]byte(s))
+	return kind + "-" + hex.EncodeToString(mac.Sum(nil)[:5])
+}
+
+// anonymizingPathResolver is a `PathResolver` that anonymizes the
+// names that it is told about.
+type anonymizingPathResolver struct {
+	PathResolver
+	anonymizer *Anonymizer
+}
+
+// newAnonymizingPathResolver wraps `pr` so that the names that it
+// uses are anonymized by `a`. If `a` is nil, `pr` is returned
+// unchanged.
+func newAnonymizingPathResolver(pr PathResolver, a *Anonymizer) PathResolver {
+	if a == nil {
+		return pr
+	}
+	return anonymizingPathResolver{PathResolver: pr, anonymizer: a}
+}
+
+func (pr anonymizingPathResolver) RequestEntryPath(
+	oid git.OID, name string, childOID git.OID, objectType string,
+) *Path {
+	return pr.PathResolver.RequestEntryPath(oid, pr.anonymizer.Path(name), childOID, objectType)
+}
+
+func (pr anonymizingPathResolver) maxSoughtPathCount() int {
+	if c, ok := pr.PathResolver.(soughtPathCounter); ok {
+		return c.maxSoughtPathCount()
+	}
+	return 0
+}
+
+func (pr anonymizingPathResolver) namesBudgetLimitedCount() int {
+	if l, ok := pr.PathResolver.(namesBudgetLimiter); ok {
+		return l.namesBudgetLimitedCount()
+	}
+	return 0
+}
+
+func (pr anonymizingPathResolver) RecordName(name string, oid git.OID) {
+	pr.PathResolver.RecordName(pr.anonymizer.Refname(name), oid)
+}
+
+func (pr anonymizingPathResolver) RecordIndexEntry(entry git.IndexEntry) {
+	entry.Path = pr.anonymizer.Path(entry.Path)
+	pr.PathResolver.RecordIndexEntry(entry)
+}
+
+func (pr anonymizingPathResolver) RecordReflogEntry(refname string, n int, oid git.OID) {
+	pr.PathResolver.RecordReflogEntry(pr.anonymizer.Refname(refname), n, oid)
+}
+
+func (pr anonymizingPathResolver) RecordTreeEntry(oid git.OID, name string, childOID git.OID) {
+	pr.PathResolver.RecordTreeEntry(oid, pr.anonymizer.Path(name), childOID)
+}
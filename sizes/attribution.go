@@ -0,0 +1,214 @@
+package sizes
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// findAttributedObjects fills in `g.attributedObjects` with the
+// objects that are reachable from the walked `roots` but not from any
+// of the excluded commits. If `strict` is set, the references that
+// are excluded from the scan are excluded. If `since` is nonzero, the
+// commits that are older than `since` are excluded, so that only
+// objects that were introduced by newer commits remain. The objects
+// in `exclude` are excluded, too. If nothing is excluded, it leaves
+// `g.attributedObjects` nil.
+func (g *Graph) findAttributedObjects(
+	ctx context.Context, repo *git.Repository, roots []Root, strict bool, since time.Time,
+	exclude []git.OID, progressMeter meter.Progress,
+) error {
+	var tips []git.OID
+	for _, root := range roots {
+		if root.Walk() {
+			tips = append(tips, root.OID())
+		}
+	}
+
+	excluded := append([]git.OID(nil), exclude...)
+	if strict {
+		for _, root := range roots {
+			if _, ok := root.(ReferenceRoot); ok && !root.Walk() {
+				excluded = append(excluded, root.OID())
+			}
+		}
+	}
+	if !since.IsZero() {
+		oldCommits, err := repo.CommitsBefore(ctx, tips, since)
+		if err != nil {
+			return err
+		}
+		excluded = append(excluded, oldCommits...)
+	}
+	if len(excluded) == 0 {
+		return nil
+	}
+
+	progressMeter.Start("Finding objects not reachable from excluded commits: %d")
+	attributedObjects, err := reachableObjects(ctx, repo, tips, excluded, progressMeter)
+	if err != nil {
+		return err
+	}
+	progressMeter.Done()
+
+	g.attributedObjects = attributedObjects
+	return nil
+}
+
+// reachableObjects returns the set of objects that are reachable from
+// any of `tips` but not from any of `excluded`. `progressMeter` is
+// incremented for each of them.
+func reachableObjects(
+	ctx context.Context, repo *git.Repository, tips, excluded []git.OID,
+	progressMeter meter.Progress,
+) (map[git.OID]struct{}, error) {
+	objIter, err := repo.NewObjectIter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		defer objIter.Close()
+
+		errChan <- func() error {
+			for _, oid := range tips {
+				if err := objIter.AddRoot(oid); err != nil {
+					return err
+				}
+			}
+			for _, oid := range excluded {
+				if err := objIter.ExcludeRoot(oid); err != nil {
+					return err
+				}
+			}
+			return nil
+		}()
+	}()
+
+	objects := make(map[git.OID]struct{})
+	for {
+		obj, ok, err := objIter.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		progressMeter.Inc()
+		objects[obj.OID] = struct{}{}
+	}
+
+	if err := <-errChan; err != nil {
+		return nil, err
+	}
+
+	return objects, nil
+}
+
+// countsTowardMaxima returns true iff the object `oid` should be
+// considered when computing maxima.
+func (g *Graph) countsTowardMaxima(oid git.OID) bool {
+	if g.attributedObjects == nil {
+		return true
+	}
+	_, ok := g.attributedObjects[oid]
+	return ok
+}
+
+// countsTowardTotals returns true iff the object `oid` should be
+// included in the counts and total sizes of unique objects. Only
+// `ScanOptions.ObjectsSince` and `ScanOptions.Exclude` restrict
+// those; the strict attribution of objects only affects the maxima.
+func (g *Graph) countsTowardTotals(oid git.OID) bool {
+	return !g.restrictTotals || g.countsTowardMaxima(oid)
+}
+
+// attributeRefGroups determines, for each object cited by one of the
+// selected statistics in `s`, the refgroups of the walked references
+// from which it is reachable, and stores them in
+// `s.objectRefGroups`.
+//
+// If the object's path leads to a commit, the references that
+// contain that commit are used. (Other references might reach the
+// same object via other commits, so this is not necessarily a
+// complete list.) If the path leads directly to a reference, that
+// reference is used.
+func (s *HistorySize) attributeRefGroups(
+	ctx context.Context, repo *git.Repository, roots []Root,
+) error {
+	refGroups := make(map[string][]RefGroupSymbol)
+	for _, root := range roots {
+		refRoot, ok := root.(ReferenceRoot)
+		if !ok || !root.Walk() {
+			continue
+		}
+		// The refnames in paths are anonymized, if requested, so
+		// look them up that way:
+		refGroups[s.anonymizer.Refname(refRoot.Reference().Refname)] = refRoot.Groups()
+	}
+	if len(refGroups) == 0 {
+		return nil
+	}
+
+	items := make(map[string]*item)
+	s.contents(nil).CollectItems(items)
+
+	// A cache of the references containing each commit:
+	containing := make(map[git.OID][]string)
+
+	s.objectRefGroups = make(map[git.OID][]RefGroupSymbol)
+	for symbol, i := range items {
+		if i.path == nil || i.path.OID == git.NullOID ||
+			strings.HasPrefix(symbol, "refgroup.") || !s.stats.Contains(symbol) {
+			continue
+		}
+		if _, ok := s.objectRefGroups[i.path.OID]; ok {
+			continue
+		}
+
+		top := i.path
+		for top.parent != nil {
+			top = top.parent
+		}
+
+		var refnames []string
+		switch {
+		case top.objectType == "commit":
+			var ok bool
+			refnames, ok = containing[top.OID]
+			if !ok {
+				var err error
+				refnames, err = repo.RefsContaining(ctx, top.OID)
+				if err != nil {
+					return err
+				}
+				for i, refname := range refnames {
+					refnames[i] = s.anonymizer.Refname(refname)
+				}
+				containing[top.OID] = refnames
+			}
+		case top.relativePath != "":
+			refnames = []string{top.relativePath}
+		}
+
+		seen := make(map[RefGroupSymbol]bool)
+		var groups []RefGroupSymbol
+		for _, refname := range refnames {
+			for _, group := range refGroups[refname] {
+				if group != "" && !seen[group] {
+					seen[group] = true
+					groups = append(groups, group)
+				}
+			}
+		}
+		sort.Slice(groups, func(i, j int) bool { return groups[i] < groups[j] })
+		s.objectRefGroups[i.path.OID] = groups
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package sizes
+
+import (
+	"github.com/github/git-sizer/git"
+)
+
+// The following functions handle the boundary of a scan that doesn't
+// walk the history behind `ScanOptions.Exclude`. The trees, commits,
+// and tags that the walked objects refer to but that weren't walked
+// are treated like objects that can't be read: trees as if they were
+// empty, and commits as if they weren't there (like the parents of a
+// commit at the boundary of a shallow clone). Blobs that weren't
+// walked are counted as empty files.
+
+// setWalked records the trees, commits, and tags that are walked, so
+// that the objects that they refer to can be recognized as being at
+// the boundary. It must be called before any of them are registered.
+func (g *Graph) setWalked(trees []objectHeader, commits []commitHeader, tags []objectHeader) {
+	g.walked = make(map[git.OID]struct{}, len(trees)+len(commits)+len(tags))
+	for _, header := range trees {
+		g.walked[header.oid] = struct{}{}
+	}
+	for _, header := range commits {
+		g.walked[header.oid] = struct{}{}
+	}
+	for _, header := range tags {
+		g.walked[header.oid] = struct{}{}
+	}
+}
+
+// atBoundary returns true iff the tree, commit, or tag `oid` isn't
+// walked, because it is reachable from `ScanOptions.Exclude`.
+func (g *Graph) atBoundary(oid git.OID) bool {
+	if g.walked == nil {
+		return false
+	}
+	_, ok := g.walked[oid]
+	return !ok
+}
+
+// boundaryTreeSize is the size of a tree at the boundary; i.e., that
+// of an empty tree.
+var boundaryTreeSize = newTreeRecord(git.NullOID).size
+
+// walkedParents returns those of `parents` that aren't at the
+// boundary. It returns `parents` itself if none of them are.
+func (g *Graph) walkedParents(parents []git.OID) []git.OID {
+	if g.walked == nil {
+		return parents
+	}
+	for i, parent := range parents {
+		if g.atBoundary(parent) {
+			walked := append([]git.OID(nil), parents[:i]...)
+			for _, parent := range parents[i+1:] {
+				if !g.atBoundary(parent) {
+					walked = append(walked, parent)
+				}
+			}
+			return walked
+		}
+	}
+	return parents
+}
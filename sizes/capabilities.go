@@ -0,0 +1,57 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/github/git-sizer/git"
+)
+
+// recordGitCapabilities records the capabilities of the `git`
+// executable that was used to scan `repo` in `s.GitCapabilities`, if
+// they were requested (via the "gitCapabilities" statistic). They
+// can explain why scans of the same repository behave differently
+// on different machines.
+func (s *HistorySize) recordGitCapabilities(ctx context.Context, repo *git.Repository) {
+	if !s.stats.Contains("gitCapabilities") {
+		return
+	}
+	capabilities := repo.Capabilities(ctx)
+	s.GitCapabilities = &capabilities
+}
+
+// capabilitiesString describes the capabilities of the `git`
+// executable, or returns the empty string if they weren't requested.
+func (s *HistorySize) capabilitiesString() string {
+	c := s.GitCapabilities
+	if c == nil {
+		return ""
+	}
+
+	version := c.Version
+	if version == "" {
+		version = "unknown"
+	}
+	yesNo := func(b bool) string {
+		if b {
+			return "yes"
+		}
+		return "no"
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nGit version %s supports:\n\n", version)
+	for _, feature := range []struct {
+		name      string
+		supported bool
+	}{
+		{"git cat-file --batch-command", c.BatchCommand},
+		{"git cat-file -Z", c.CatFileNUL},
+		{"git rev-list --disk-usage", c.RevListDiskUsage},
+		{"git rev-list --no-object-names", c.RevListNoObjectNames},
+	} {
+		fmt.Fprintf(buf, "     %-31s %s\n", feature.name+":", yesNo(feature.supported))
+	}
+	return buf.String()
+}
@@ -0,0 +1,101 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/github/git-sizer/git"
+)
+
+// Caveats describes features of the repository that make the history
+// that git-sizer measures differ from the one that other Git
+// commands (or other clones) show. git-sizer ignores replace
+// references and grafts, and can only see the part of a shallow
+// clone's history that was fetched.
+type Caveats struct {
+	// ReplaceRefs lists the replace references, which are ignored.
+	ReplaceRefs []string `json:"replace_refs,omitempty"`
+
+	// GraftsFile is the path of the grafts file, which is ignored,
+	// and GraftCount is the number of grafts that it contains.
+	GraftsFile string `json:"grafts_file,omitempty"`
+	GraftCount int    `json:"graft_count,omitempty"`
+
+	// ShallowCommitCount is the number of commits at which the
+	// history of a shallow clone is cut off.
+	ShallowCommitCount int `json:"shallow_commit_count,omitempty"`
+}
+
+// recordCaveats looks for replace references and grafts in `repo`
+// and, along with `shallowCommits`, records them in `s.Caveats`. If
+// there are none, `s.Caveats` is left nil.
+func (s *HistorySize) recordCaveats(
+	ctx context.Context, repo *git.Repository, shallowCommits []git.OID,
+) error {
+	replaceRefs, err := repo.ReplaceRefs(ctx)
+	if err != nil {
+		return err
+	}
+	graftsFile, graftCount, err := repo.Grafts(ctx)
+	if err != nil {
+		return err
+	}
+	if len(replaceRefs) == 0 && graftCount == 0 && len(shallowCommits) == 0 {
+		return nil
+	}
+
+	c := Caveats{
+		GraftsFile:         s.anonymizer.Path(graftsFile),
+		GraftCount:         graftCount,
+		ShallowCommitCount: len(shallowCommits),
+	}
+	for _, refname := range replaceRefs {
+		c.ReplaceRefs = append(c.ReplaceRefs, s.anonymizer.Refname(refname))
+	}
+	s.Caveats = &c
+	return nil
+}
+
+// caveatsString returns a section of the table output listing the
+// caveats, or the empty string if there are none.
+func (s *HistorySize) caveatsString() string {
+	c := s.Caveats
+	if c == nil {
+		return ""
+	}
+
+	plural := func(n int, noun string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s", noun)
+		}
+		return fmt.Sprintf("%d %ss", n, noun)
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nCaveats:\n\n")
+	if len(c.ReplaceRefs) != 0 {
+		fmt.Fprintf(
+			buf, "  * Replace references are ignored, so the original objects are\n"+
+				"    measured, not the ones that replace them. The repository has\n"+
+				"    %s (e.g., %s).\n",
+			plural(len(c.ReplaceRefs), "replace reference"), c.ReplaceRefs[0],
+		)
+	}
+	if c.GraftCount != 0 {
+		fmt.Fprintf(
+			buf, "  * Grafts are ignored, so the commits' recorded parents are\n"+
+				"    followed, not the grafted ones. The repository has %s\n"+
+				"    in %s.\n",
+			plural(c.GraftCount, "graft"), c.GraftsFile,
+		)
+	}
+	if c.ShallowCommitCount != 0 {
+		fmt.Fprintf(
+			buf, "  * This is a shallow clone, whose history is cut off at %s,\n"+
+				"    so the statistics don't cover the history that wasn't fetched.\n",
+			plural(c.ShallowCommitCount, "commit"),
+		)
+	}
+	return buf.String()
+}
@@ -0,0 +1,179 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// maxCheckoutExtensions is the most extensions that are listed in
+// `CheckoutExtensions.Extensions`. The files with other extensions
+// are summed up in `OtherBlobCount` and `OtherBlobSize`.
+const maxCheckoutExtensions = 20
+
+// CheckoutExtensions breaks down the checkout with the largest total
+// size of files (`HistorySize.MaxExpandedBlobSizeTree`) by the
+// extensions of the files' names, so that one can see at a glance
+// whether a huge checkout consists of media assets, generated code,
+// or test fixtures. Like the expanded counts, it counts a blob once
+// for each path at which it appears, and it skips symlinks and
+// submodules.
+type CheckoutExtensions struct {
+	// Tree is the tree whose checkout was broken down.
+	Tree *Path `json:"tree"`
+
+	// BlobCount and BlobSize are the number and total size of the
+	// files in the checkout.
+	BlobCount counts.Count64 `json:"blob_count"`
+	BlobSize  counts.Count64 `json:"blob_size"`
+
+	// ExtensionCount is the number of distinct extensions (counting
+	// "no extension" as one).
+	ExtensionCount counts.Count32 `json:"extension_count"`
+
+	// Extensions lists the extensions whose files have the largest
+	// total size, largest first.
+	Extensions []ExtensionSize `json:"extensions"`
+
+	// OtherBlobCount and OtherBlobSize are the number and total
+	// size of the files whose extensions aren't listed.
+	OtherBlobCount counts.Count64 `json:"other_blob_count"`
+	OtherBlobSize  counts.Count64 `json:"other_blob_size"`
+}
+
+// ExtensionSize is the number and total size of the files in a
+// checkout whose names have one extension. The extension includes
+// the leading ".", and is lowercased so that, e.g., "foo.PNG" and
+// "bar.png" are counted together. It is empty for files whose names
+// have no extension, including names like ".gitignore" that consist
+// of a "." and an extension.
+type ExtensionSize struct {
+	Extension string         `json:"extension"`
+	BlobCount counts.Count64 `json:"blob_count"`
+	BlobSize  counts.Count64 `json:"blob_size"`
+}
+
+// fileExtension returns the extension of the file at `p`, in the
+// form used by `ExtensionSize`.
+func fileExtension(p string) string {
+	base := path.Base(p)
+	ext := path.Ext(base)
+	if ext == base {
+		return ""
+	}
+	return strings.ToLower(ext)
+}
+
+// measureCheckoutExtensions lists the files in the checkout of
+// `s.MaxExpandedBlobSizeTree`, if any, and stores their breakdown by
+// extension in `s.CheckoutExtensions`.
+func (s *HistorySize) measureCheckoutExtensions(ctx context.Context, repo *git.Repository) error {
+	tree := s.maxExpandedBlobSizeTreeOID
+	if tree == git.NullOID && s.MaxExpandedBlobSizeTree != nil {
+		tree = s.MaxExpandedBlobSizeTree.OID
+	}
+	if tree == git.NullOID {
+		return nil
+	}
+
+	ce := CheckoutExtensions{
+		Tree:       s.MaxExpandedBlobSizeTree,
+		Extensions: []ExtensionSize{},
+	}
+	byExtension := make(map[string]*ExtensionSize)
+	if err := repo.TreeFiles(
+		ctx, tree,
+		func(f git.TreeFile) error {
+			ext := fileExtension(f.Path)
+			es, ok := byExtension[ext]
+			if !ok {
+				es = &ExtensionSize{Extension: ext}
+				byExtension[ext] = es
+			}
+			es.BlobCount.Increment(1)
+			es.BlobSize.Increment(f.Size)
+			ce.BlobCount.Increment(1)
+			ce.BlobSize.Increment(f.Size)
+			return nil
+		},
+	); err != nil {
+		return fmt.Errorf("listing the files in the biggest checkout: %w", err)
+	}
+
+	extensions := make([]*ExtensionSize, 0, len(byExtension))
+	for _, es := range byExtension {
+		extensions = append(extensions, es)
+	}
+	sort.Slice(extensions, func(i, j int) bool {
+		switch {
+		case extensions[i].BlobSize != extensions[j].BlobSize:
+			return extensions[i].BlobSize > extensions[j].BlobSize
+		case extensions[i].BlobCount != extensions[j].BlobCount:
+			return extensions[i].BlobCount > extensions[j].BlobCount
+		default:
+			return extensions[i].Extension < extensions[j].Extension
+		}
+	})
+
+	ce.ExtensionCount = counts.NewCount32(uint64(len(extensions)))
+	for _, es := range extensions {
+		if len(ce.Extensions) == maxCheckoutExtensions {
+			ce.OtherBlobCount.Increment(es.BlobCount)
+			ce.OtherBlobSize.Increment(es.BlobSize)
+			continue
+		}
+		if es.Extension != "" {
+			es.Extension = s.anonymizer.Extension(es.Extension)
+		}
+		ce.Extensions = append(ce.Extensions, *es)
+	}
+
+	s.CheckoutExtensions = &ce
+	return nil
+}
+
+// CheckoutExtensionsTableString breaks down the biggest checkout by
+// extension, or returns the empty string if that wasn't requested.
+func (s *HistorySize) CheckoutExtensionsTableString() string {
+	ce := s.CheckoutExtensions
+	if ce == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nFiles in the biggest checkout (%s), by extension:\n\n", ce.Tree.Path())
+	fmt.Fprintln(buf, "| Extension            | Files     | Size      | Share")
+	fmt.Fprintln(buf, "| -------------------- | --------- | --------- | ------")
+	row := func(name string, n, size counts.Count64) {
+		share := 0.0
+		if ce.BlobSize != 0 {
+			share = 100 * float64(size) / float64(ce.BlobSize)
+		}
+		fmt.Fprintf(
+			buf, "| %-20s | %s | %s | %5.1f%%\n", name,
+			formatGrowthValue(n, &counts.Metric, ""),
+			formatGrowthValue(size, &counts.Binary, "B"),
+			share,
+		)
+	}
+	for _, es := range ce.Extensions {
+		name := es.Extension
+		if name == "" {
+			name = "(none)"
+		}
+		row(name, es.BlobCount, es.BlobSize)
+	}
+	if ce.OtherBlobCount != 0 {
+		row(
+			fmt.Sprintf("(%d others)", int(ce.ExtensionCount)-len(ce.Extensions)),
+			ce.OtherBlobCount, ce.OtherBlobSize,
+		)
+	}
+	return buf.String()
+}
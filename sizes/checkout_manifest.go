@@ -0,0 +1,50 @@
+package sizes
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/github/git-sizer/git"
+)
+
+// WriteCheckoutManifest writes the list of the regular files in the
+// checkout with the largest total size of files (the tree cited by
+// `s.MaxExpandedBlobSizeTree`) to `w`, one per line, in the order
+// listed by `git ls-tree -r`, in the form
+//
+//	<path> TAB <blob OID> TAB <size>
+//
+// Paths that contain a tab, a newline, a double quote, or a
+// backslash are written quoted, in the style of Go string literals.
+// If the history has no checkout, nothing is written.
+func (s *HistorySize) WriteCheckoutManifest(
+	ctx context.Context, repo *git.Repository, w io.Writer,
+) error {
+	tree := s.maxExpandedBlobSizeTreeOID
+	if tree == git.NullOID && s.MaxExpandedBlobSizeTree != nil {
+		tree = s.MaxExpandedBlobSizeTree.OID
+	}
+	if tree == git.NullOID {
+		return nil
+	}
+
+	bw := bufio.NewWriter(w)
+	if err := repo.TreeFiles(
+		ctx, tree,
+		func(f git.TreeFile) error {
+			path := s.anonymizer.Path(f.Path)
+			if strings.ContainsAny(path, "\t\n\"\\") {
+				path = strconv.Quote(path)
+			}
+			_, err := fmt.Fprintf(bw, "%s\t%s\t%d\n", path, f.OID, f.Size)
+			return err
+		},
+	); err != nil {
+		return fmt.Errorf("listing the files in the biggest checkout: %w", err)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,313 @@
+package sizes
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// checkpointVersion is the version of the checkpoint file format. A
+// checkpoint written with a different version is ignored.
+const checkpointVersion = 2
+
+// checkpointPhase identifies the last phase of a scan whose results
+// were saved in a checkpoint.
+type checkpointPhase int
+
+const (
+	// Nothing has been saved yet.
+	checkpointPhaseNone checkpointPhase = iota
+
+	// The roots (references and explicit roots) have been saved.
+	checkpointPhaseRoots
+
+	// The headers of the objects reachable from the roots have also
+	// been saved.
+	checkpointPhaseHeaders
+)
+
+// Checkpoint records the intermediate results of a scan in a file,
+// after each phase of the scan is completed, so that a scan that was
+// interrupted can be resumed from the last completed phase rather
+// than starting over. A nil `*Checkpoint` is valid, and doesn't
+// record anything.
+type Checkpoint struct {
+	path string
+	data checkpointData
+}
+
+// checkpointData is the content of a checkpoint file. OIDs are stored
+// as byte slices because `git.OID` can't be gob-encoded.
+type checkpointData struct {
+	Version int
+
+	// Key identifies the settings of the scan that wrote the
+	// checkpoint. A checkpoint is only used by a scan with the same
+	// key.
+	Key string
+
+	Phase checkpointPhase
+
+	Roots []checkpointRoot
+
+	Blobs   []checkpointObject
+	Trees   []checkpointObject
+	Commits []checkpointObject
+	Tags    []checkpointObject
+
+	// DiskSize is the total on-disk size of the reachable objects.
+	DiskSize counts.Count64
+}
+
+type checkpointRoot struct {
+	Name     string
+	OID      []byte
+	Walk     bool
+	Explicit bool
+
+	// The following are only set for references:
+	ObjectType    git.ObjectType
+	ObjectSize    counts.Count32
+	CommitterDate time.Time
+	Groups        []RefGroupSymbol
+}
+
+type checkpointObject struct {
+	OID  []byte
+	Size counts.Count32
+}
+
+// OpenCheckpoint opens the checkpoint stored at `path`, which is
+// created when the first phase is saved if it doesn't exist yet.
+// `key` should describe the settings that affect the scan. If the
+// existing checkpoint was written with a different key, or can't be
+// read, it is discarded (and `discarded` is true), and the scan will
+// start over.
+func OpenCheckpoint(path, key string) (cp *Checkpoint, discarded bool, err error) {
+	cp = &Checkpoint{
+		path: path,
+		data: checkpointData{
+			Version: checkpointVersion,
+			Key:     key,
+		},
+	}
+
+	buf, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cp, false, nil
+	} else if err != nil {
+		return nil, false, fmt.Errorf("reading checkpoint: %w", err)
+	}
+
+	var data checkpointData
+	if err := gob.NewDecoder(bytes.NewReader(buf)).Decode(&data); err != nil ||
+		data.Version != checkpointVersion || data.Key != key {
+		return cp, true, nil
+	}
+
+	cp.data = data
+	return cp, false, nil
+}
+
+// Roots returns the roots that were saved in the checkpoint, and true
+// if there were any.
+func (cp *Checkpoint) Roots() ([]Root, bool, error) {
+	if cp == nil || cp.data.Phase < checkpointPhaseRoots {
+		return nil, false, nil
+	}
+
+	roots := make([]Root, 0, len(cp.data.Roots))
+	for _, r := range cp.data.Roots {
+		oid, err := git.OIDFromBytes(r.OID)
+		if err != nil {
+			return nil, false, fmt.Errorf("reading checkpoint: %w", err)
+		}
+		if r.Explicit {
+			roots = append(roots, NewExplicitRoot(r.Name, oid))
+			continue
+		}
+		roots = append(roots, RefRoot{
+			ref: git.Reference{
+				Refname:       r.Name,
+				ObjectType:    r.ObjectType,
+				ObjectSize:    r.ObjectSize,
+				OID:           oid,
+				CommitterDate: r.CommitterDate,
+			},
+			walk:   r.Walk,
+			groups: r.Groups,
+		})
+	}
+	return roots, true, nil
+}
+
+// SaveRoots records `roots`, which completes the first phase of the
+// scan.
+func (cp *Checkpoint) SaveRoots(roots []Root) error {
+	if cp == nil {
+		return nil
+	}
+
+	cp.data.Roots = make([]checkpointRoot, 0, len(roots))
+	for _, root := range roots {
+		r := checkpointRoot{
+			Name: root.Name(),
+			OID:  root.OID().Bytes(),
+			Walk: root.Walk(),
+		}
+		if refRoot, ok := root.(ReferenceRoot); ok {
+			ref := refRoot.Reference()
+			r.ObjectType = ref.ObjectType
+			r.ObjectSize = ref.ObjectSize
+			r.CommitterDate = ref.CommitterDate
+			r.Groups = refRoot.Groups()
+		} else {
+			r.Explicit = true
+		}
+		cp.data.Roots = append(cp.data.Roots, r)
+	}
+	cp.data.Phase = checkpointPhaseRoots
+	return cp.write()
+}
+
+// hasHeaders returns true if the checkpoint holds the headers of the
+// objects reachable from the roots.
+func (cp *Checkpoint) hasHeaders() bool {
+	return cp != nil && cp.data.Phase >= checkpointPhaseHeaders
+}
+
+// saveHeaders records the headers of the objects reachable from the
+// roots, which completes the second phase of the scan. The trees of
+// the commits aren't known yet, so they aren't recorded.
+func (cp *Checkpoint) saveHeaders(
+	blobs, trees []objectHeader, commits []commitHeader, tags []objectHeader,
+	diskSize counts.Count64,
+) error {
+	if cp == nil {
+		return nil
+	}
+
+	commitHeaders := make([]objectHeader, len(commits))
+	for i, commit := range commits {
+		commitHeaders[i] = commit.objectHeader
+	}
+
+	cp.data.Blobs = checkpointObjects(blobs)
+	cp.data.Trees = checkpointObjects(trees)
+	cp.data.Commits = checkpointObjects(commitHeaders)
+	cp.data.Tags = checkpointObjects(tags)
+	cp.data.DiskSize = diskSize
+	cp.data.Phase = checkpointPhaseHeaders
+	return cp.write()
+}
+
+// replayHeaders registers the blobs saved in `cp` with `g`, and
+// returns the headers of the other objects, just as
+// `enumerateObjects()` would have.
+func (g *Graph) replayHeaders(
+	cp *Checkpoint, progressMeter meter.Progress,
+) (trees []objectHeader, commits []commitHeader, tags []objectHeader, err error) {
+	blobs, err := objectHeaders(cp.data.Blobs)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	progressMeter.Start("Processing blobs: %d")
+	meter.SetTotal(progressMeter, int64(len(blobs)))
+	for _, blob := range blobs {
+		progressMeter.Inc()
+		g.RegisterBlob(blob.oid, blob.objectSize)
+	}
+	progressMeter.Done()
+	g.recordDiskSize(cp.data.DiskSize)
+
+	trees, err = objectHeaders(cp.data.Trees)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	commitHeaders, err := objectHeaders(cp.data.Commits)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	commits = make([]commitHeader, len(commitHeaders))
+	for i, header := range commitHeaders {
+		commits[i] = commitHeader{header, git.NullOID}
+	}
+	tags, err = objectHeaders(cp.data.Tags)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	return trees, commits, tags, nil
+}
+
+func checkpointObjects(headers []objectHeader) []checkpointObject {
+	objs := make([]checkpointObject, len(headers))
+	for i, header := range headers {
+		objs[i] = checkpointObject{
+			OID:  header.oid.Bytes(),
+			Size: header.objectSize,
+		}
+	}
+	return objs
+}
+
+func objectHeaders(objs []checkpointObject) ([]objectHeader, error) {
+	headers := make([]objectHeader, len(objs))
+	for i, obj := range objs {
+		oid, err := git.OIDFromBytes(obj.OID)
+		if err != nil {
+			return nil, fmt.Errorf("reading checkpoint: %w", err)
+		}
+		headers[i] = objectHeader{oid, obj.Size}
+	}
+	return headers, nil
+}
+
+// write writes the checkpoint to its file. The file is replaced
+// atomically, so an interruption can't leave a partial checkpoint
+// behind.
+func (cp *Checkpoint) write() error {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&cp.data); err != nil {
+		return fmt.Errorf("encoding checkpoint: %w", err)
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(cp.path), filepath.Base(cp.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		_ = f.Close()
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	if err := os.Rename(f.Name(), cp.path); err != nil {
+		_ = os.Remove(f.Name())
+		return fmt.Errorf("writing checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Remove deletes the checkpoint file. It should be called once the
+// scan has completed.
+func (cp *Checkpoint) Remove() error {
+	if cp == nil {
+		return nil
+	}
+	if err := os.Remove(cp.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("removing checkpoint: %w", err)
+	}
+	return nil
+}
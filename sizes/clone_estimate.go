@@ -0,0 +1,173 @@
+package sizes
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/github/git-sizer/counts"
+)
+
+// The following are rough assumptions about the performance of a
+// typical client, used to estimate how long a clone takes. They are
+// deliberately round numbers; the estimate is meant to give an order
+// of magnitude, not a prediction.
+const (
+	// cloneRoundTrips is the number of network round trips needed to
+	// negotiate a clone (listing references, requesting the pack,
+	// etc.) before the data start flowing.
+	cloneRoundTrips = 3
+
+	// indexObjectRate is the number of objects per second that `git
+	// index-pack` processes, not counting the time needed to hash
+	// their contents.
+	indexObjectRate = 100e3
+
+	// indexByteRate is the number of bytes of (uncompressed) object
+	// contents per second that `git index-pack` inflates and hashes.
+	indexByteRate = 200e6
+
+	// checkoutFileRate is the number of files per second that `git
+	// checkout` creates, not counting the time needed to write their
+	// contents.
+	checkoutFileRate = 10e3
+
+	// checkoutByteRate is the number of bytes per second that `git
+	// checkout` writes to the working copy.
+	checkoutByteRate = 200e6
+)
+
+// CloneEstimate is a rough estimate of how long it takes to clone the
+// repository and check out its biggest checkout, given assumptions
+// about the network connection.
+type CloneEstimate struct {
+	// The assumed bandwidth, in megabits per second, and latency of
+	// the network connection.
+	BandwidthMbps float64 `json:"bandwidth_mbps"`
+	LatencyMillis int64   `json:"latency_ms"`
+
+	// The number of bytes and objects that have to be transferred.
+	// The former is the on-disk size of the reachable objects, which
+	// is roughly what the server sends.
+	TransferSize counts.Count64 `json:"transfer_size"`
+	ObjectCount  counts.Count64 `json:"object_count"`
+
+	// The number of files and bytes in the biggest checkout.
+	CheckoutFileCount counts.Count32 `json:"checkout_file_count"`
+	CheckoutSize      counts.Count64 `json:"checkout_size"`
+
+	// The estimated durations, in seconds, of transferring the
+	// objects, indexing them, and checking out the biggest checkout,
+	// and their sum.
+	TransferSeconds float64 `json:"transfer_seconds"`
+	IndexSeconds    float64 `json:"index_seconds"`
+	CheckoutSeconds float64 `json:"checkout_seconds"`
+	TotalSeconds    float64 `json:"total_seconds"`
+}
+
+// estimateClone estimates how long it takes to clone the repository
+// over a connection with the specified bandwidth (in megabits per
+// second) and latency, and stores the result in `s.CloneEstimate`. If
+// any of the counters that the estimate is based on has overflowed
+// (e.g., the number of files in the checkout of a git bomb), no
+// estimate is made, since it would be meaningless.
+func (s *HistorySize) estimateClone(bandwidthMbps float64, latency time.Duration) {
+	for _, c := range []counts.Humanable{
+		s.ReachableDiskSize,
+		s.UniqueCommitCount, s.UniqueTreeCount, s.UniqueBlobCount, s.UniqueTagCount,
+		s.UniqueCommitSize, s.UniqueTreeSize, s.UniqueBlobSize,
+		s.MaxExpandedBlobCount, s.MaxExpandedBlobSize,
+	} {
+		if _, overflow := c.ToUint64(); overflow {
+			return
+		}
+	}
+
+	e := CloneEstimate{
+		BandwidthMbps: bandwidthMbps,
+		LatencyMillis: latency.Milliseconds(),
+		TransferSize:  s.ReachableDiskSize,
+		ObjectCount: counts.Count64(s.UniqueCommitCount) +
+			counts.Count64(s.UniqueTreeCount) +
+			counts.Count64(s.UniqueBlobCount) +
+			counts.Count64(s.UniqueTagCount),
+		CheckoutFileCount: s.MaxExpandedBlobCount,
+		CheckoutSize:      s.MaxExpandedBlobSize,
+	}
+
+	contentSize := s.UniqueCommitSize + s.UniqueTreeSize + s.UniqueBlobSize
+
+	e.TransferSeconds = cloneRoundTrips*latency.Seconds() +
+		float64(e.TransferSize)*8/(bandwidthMbps*1e6)
+	e.IndexSeconds = float64(e.ObjectCount)/indexObjectRate +
+		float64(contentSize)/indexByteRate
+	e.CheckoutSeconds = float64(e.CheckoutFileCount)/checkoutFileRate +
+		float64(e.CheckoutSize)/checkoutByteRate
+	e.TotalSeconds = e.TransferSeconds + e.IndexSeconds + e.CheckoutSeconds
+
+	s.CloneEstimate = &e
+}
+
+// RecommendationsString returns the "Recommendations" section of the
+// table output, or the empty string if there is nothing to put in
+// it.
+func (s *HistorySize) RecommendationsString() string {
+	buf := &bytes.Buffer{}
+	s.CloneEstimate.writeRecommendations(buf)
+	s.LFSMigration.writeRecommendations(buf, s.ReachableDiskSize)
+	s.IndexEstimate.writeRecommendations(buf)
+	s.PackObjectsEstimate.writeRecommendations(buf)
+	writeWideTreeRecommendations(buf, s.WideTrees)
+	writeConfigDriftRecommendations(buf, s.ConfigDrift)
+	if buf.Len() == 0 {
+		return ""
+	}
+	return "\nRecommendations:\n\n" + buf.String()
+}
+
+// writeRecommendations writes the estimated clone time to `w`.
+func (e *CloneEstimate) writeRecommendations(w io.Writer) {
+	if e == nil {
+		return
+	}
+
+	fmt.Fprintf(
+		w, "* Estimated time to clone over a %g Mbit/s connection with %d ms latency: %s\n",
+		e.BandwidthMbps, e.LatencyMillis, formatSeconds(e.TotalSeconds),
+	)
+	fmt.Fprintf(
+		w, "    * transferring %s: %s\n",
+		formatBytes(e.TransferSize), formatSeconds(e.TransferSeconds),
+	)
+	objectCount, objectUnit := counts.Metric.Format(e.ObjectCount, "")
+	fmt.Fprintf(
+		w, "    * indexing %s%s objects: %s\n",
+		objectCount, objectUnit, formatSeconds(e.IndexSeconds),
+	)
+	fileCount, fileUnit := counts.Metric.Format(e.CheckoutFileCount, "")
+	fmt.Fprintf(
+		w, "    * checking out %s%s files (%s): %s\n",
+		fileCount, fileUnit, formatBytes(e.CheckoutSize), formatSeconds(e.CheckoutSeconds),
+	)
+}
+
+// formatBytes formats `n` as a number of bytes with a binary prefix.
+func formatBytes(n counts.Count64) string {
+	value, unit := counts.Binary.Format(n, "B")
+	return value + " " + unit
+}
+
+// formatSeconds formats a duration given in seconds, rounded to a
+// precision that suits its magnitude.
+func formatSeconds(seconds float64) string {
+	d := time.Duration(seconds * float64(time.Second))
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(100 * time.Millisecond).String()
+	default:
+		return d.Round(time.Second).String()
+	}
+}
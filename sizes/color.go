@@ -0,0 +1,54 @@
+package sizes
+
+import "strings"
+
+// The ANSI escape sequences that are used to color the table output
+// (see `HistorySize.SetColor()`).
+const (
+	ansiBold         = "\x1b[1m"
+	ansiNormal       = "\x1b[22m"
+	ansiRed          = "\x1b[31m"
+	ansiGreen        = "\x1b[32m"
+	ansiYellow       = "\x1b[33m"
+	ansiDefaultColor = "\x1b[39m"
+)
+
+// SetColor chooses whether `TableString()` colors the level of
+// concern of each row by severity, using ANSI escape sequences, and
+// highlights the rows whose level of concern is off the scale.
+func (s *HistorySize) SetColor(color bool) {
+	s.color = color
+}
+
+// levelColor returns the ANSI escape sequence of the color in which
+// `levelOfConcern` (as returned by `item.levelOfConcern()`) is
+// displayed, or "" if it is displayed in the default color.
+func levelColor(levelOfConcern string) string {
+	switch n := len(levelOfConcern); {
+	case n == 0:
+		return ""
+	case strings.HasPrefix(levelOfConcern, "!"), n >= 10:
+		return ansiRed
+	case n >= 3:
+		return ansiYellow
+	default:
+		return ansiGreen
+	}
+}
+
+// colorRow colors `levelOfConcern` within `row`, a line of the table
+// whose level-of-concern column has already been padded to its full
+// width. If the level of concern is off the scale, the whole row is
+// highlighted, too.
+func colorRow(row, levelOfConcern string) string {
+	color := levelColor(levelOfConcern)
+	if color == "" {
+		return row
+	}
+	i := strings.LastIndex(row, levelOfConcern)
+	row = row[:i] + color + levelOfConcern + ansiDefaultColor + row[i+len(levelOfConcern):]
+	if strings.HasPrefix(levelOfConcern, "!") {
+		row = ansiBold + row + ansiNormal
+	}
+	return row
+}
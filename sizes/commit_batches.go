@@ -0,0 +1,161 @@
+package sizes
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// commitBatchSize is the number of commits that
+// `registerCommitBatch()` is given at a time. Commits can only be
+// processed concurrently if they are in the same batch, but all of
+// the commits in a batch have to be held in memory at once.
+const commitBatchSize = 4096
+
+// pendingCommit is a commit that has been read, but not yet
+// registered, by `registerCommitBatch()`.
+type pendingCommit struct {
+	header *commitHeader
+	obj    git.ObjectRecord
+
+	// commit is the parsed commit, or nil if it couldn't be read.
+	commit *git.Commit
+	err    error
+
+	// generation is the number of the commit's ancestors that are
+	// also in the batch, along the longest chain. Commits of the same
+	// generation don't depend on each other.
+	generation int
+
+	reg commitRegistration
+}
+
+// registerCommitBatch registers the commits in `batch`, which are
+// ordered so that parents precede their children, using up to
+// `workers` goroutines. The commits are parsed concurrently. Then,
+// one generation at a time, the sizes of the commits of the
+// generation are computed concurrently, since each of them only
+// depends on commits of earlier generations (or earlier batches).
+// Finally, the commits are recorded in the statistics in the order of
+// `batch`, so that the results are the same as if the commits had
+// been registered one at a time using `RegisterCommit()`.
+//
+// If `checkDependencies` is set, it is checked that each commit's
+// tree and parents have been registered before it is (see
+// `ScanOptions.ObjectList`).
+func (g *Graph) registerCommitBatch(
+	batch []pendingCommit, workers int, checkDependencies bool, progressMeter meter.Progress,
+) error {
+	forEachConcurrently(len(batch), workers, func(i int) {
+		p := &batch[i]
+		if p.obj.ObjectType == "commit" {
+			p.commit, p.err = git.ParseCommit(p.obj.OID, p.obj.Data)
+		}
+	})
+
+	// The indexes in `batch` of its commits:
+	indexes := make(map[git.OID]int, len(batch))
+	var generations [][]int
+	for i := range batch {
+		p := &batch[i]
+		if p.err != nil {
+			return p.err
+		}
+		p.obj.Data = nil
+		progressMeter.Inc()
+
+		if p.commit != nil {
+			p.header.tree = p.commit.Tree
+			if _, shallow := g.shallowCommits[p.obj.OID]; !shallow {
+				for _, parent := range p.commit.Parents {
+					if j, ok := indexes[parent]; ok && batch[j].generation >= p.generation {
+						p.generation = batch[j].generation + 1
+					}
+				}
+			}
+		}
+		indexes[p.obj.OID] = i
+
+		if p.generation == len(generations) {
+			generations = append(generations, nil)
+		}
+		generations[p.generation] = append(generations[p.generation], i)
+	}
+
+	for _, generation := range generations {
+		if checkDependencies {
+			for _, i := range generation {
+				p := &batch[i]
+				if p.commit == nil {
+					continue
+				}
+				if err := g.checkCommitDependencies(p.obj.OID, p.commit); err != nil {
+					return err
+				}
+			}
+		}
+
+		forEachConcurrently(len(generation), workers, func(j int) {
+			p := &batch[generation[j]]
+			if p.commit != nil {
+				p.reg = g.computeCommitSize(p.obj.OID, p.commit)
+			}
+		})
+
+		for _, i := range generation {
+			p := &batch[i]
+			if p.commit == nil {
+				g.registerUnreadCommit(p.obj.OID)
+				continue
+			}
+			g.storeCommitSize(p.obj.OID, &p.reg)
+		}
+	}
+
+	g.commitLock.Lock()
+	componentCount := counts.NewCount32(uint64(g.historyComponentCount))
+	g.commitLock.Unlock()
+
+	for i := range batch {
+		p := &batch[i]
+		if p.commit != nil {
+			g.recordCommitStats(p.obj.OID, p.commit, &p.reg, componentCount)
+		}
+	}
+
+	return nil
+}
+
+// forEachConcurrently calls `f(i)` for each `i` in `[0, n)`, using up
+// to `workers` goroutines, and waits for all of the calls to return.
+func forEachConcurrently(n, workers int, f func(i int)) {
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			f(i)
+		}
+		return
+	}
+
+	next := int64(-1)
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= n {
+					return
+				}
+				f(i)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,122 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// commitGraphSampleCommand is the command whose run time is measured
+// before and after a commit-graph is written.
+const commitGraphSampleCommand = "git rev-list --count --all"
+
+// CommitGraphWrite describes the commit-graph that was written for
+// the repository after the scan, and how much faster it made a walk
+// of the whole history.
+type CommitGraphWrite struct {
+	// Written is false if the repository already had a
+	// commit-graph, in which case no other fields are set.
+	Written bool `json:"written"`
+
+	// SampleCommand is the command that was timed before and after
+	// writing the commit-graph, and CommitCount the number of
+	// commits that it walked.
+	SampleCommand string         `json:"sample_command,omitempty"`
+	CommitCount   counts.Count64 `json:"commit_count,omitempty"`
+
+	// The time that the sample command took before and after the
+	// commit-graph was written, and the time that writing it took, in
+	// seconds.
+	BeforeSeconds float64 `json:"before_seconds,omitempty"`
+	AfterSeconds  float64 `json:"after_seconds,omitempty"`
+	WriteSeconds  float64 `json:"write_seconds,omitempty"`
+}
+
+// writeCommitGraph writes a commit-graph for `repo` if it doesn't have
+// one yet, timing a walk of the whole history before and after, and
+// stores the results in `s.CommitGraphWrite`.
+func (s *HistorySize) writeCommitGraph(
+	ctx context.Context, repo *git.Repository, progressMeter meter.Progress,
+) error {
+	exists, err := repo.HasCommitGraph(ctx)
+	if err != nil {
+		return err
+	}
+	if exists {
+		s.CommitGraphWrite = &CommitGraphWrite{}
+		return nil
+	}
+
+	w := CommitGraphWrite{
+		Written:       true,
+		SampleCommand: commitGraphSampleCommand,
+	}
+
+	progressMeter.Start("Writing commit-graph: %d")
+	defer progressMeter.Done()
+	meter.SetTotal(progressMeter, 3)
+
+	start := time.Now()
+	w.CommitCount, err = repo.CountAllCommits(ctx)
+	if err != nil {
+		return err
+	}
+	w.BeforeSeconds = time.Since(start).Seconds()
+	progressMeter.Inc()
+
+	start = time.Now()
+	if err := repo.WriteCommitGraph(ctx); err != nil {
+		return err
+	}
+	w.WriteSeconds = time.Since(start).Seconds()
+	progressMeter.Inc()
+
+	start = time.Now()
+	if _, err := repo.CountAllCommits(ctx); err != nil {
+		return err
+	}
+	w.AfterSeconds = time.Since(start).Seconds()
+	progressMeter.Inc()
+
+	s.CommitGraphWrite = &w
+	return nil
+}
+
+// CommitGraphWriteString describes the commit-graph that was written
+// after the scan, or returns the empty string if none was requested.
+func (s *HistorySize) CommitGraphWriteString() string {
+	w := s.CommitGraphWrite
+	if w == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	if !w.Written {
+		fmt.Fprintf(buf, "\nThe repository already has a commit-graph, so none was written.\n")
+		return buf.String()
+	}
+
+	commitCount, commitUnit := counts.Metric.Format(w.CommitCount, "")
+	fmt.Fprintf(
+		buf, "\nWrote a commit-graph for %s%s commits in %s.\n",
+		commitCount, commitUnit, formatSeconds(w.WriteSeconds),
+	)
+	heading := fmt.Sprintf("Walking the history (%s)", w.SampleCommand)
+	fmt.Fprintf(buf, "\n| %s | Time\n", heading)
+	fmt.Fprintf(buf, "| %s | ----\n", strings.Repeat("-", len(heading)))
+	fmt.Fprintf(buf, "| %-*s | %s\n", len(heading), "Without a commit-graph", formatSeconds(w.BeforeSeconds))
+	fmt.Fprintf(buf, "| %-*s | %s\n", len(heading), "With the commit-graph", formatSeconds(w.AfterSeconds))
+	if w.AfterSeconds > 0 {
+		fmt.Fprintf(
+			buf, "\nThe history walk was %.1fx as fast with the commit-graph.\n",
+			w.BeforeSeconds/w.AfterSeconds,
+		)
+	}
+	return buf.String()
+}
@@ -0,0 +1,96 @@
+package sizes
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// CommitterTotal is the number and total size of the analyzed
+// commits that were committed by one identity. The size is that of
+// the commit objects themselves, not of the trees and blobs that they
+// refer to. An identity that commits much more data than the others
+// is often an automated process.
+type CommitterTotal struct {
+	Committer   string         `json:"committer"`
+	CommitCount counts.Count32 `json:"commit_count"`
+	CommitSize  counts.Count64 `json:"commit_size"`
+}
+
+// recordCommitIdentities records the author and committer of
+// `commit`. `g.historyLock` must be held.
+func (g *Graph) recordCommitIdentities(commit *git.Commit) {
+	if _, ok := g.authors[commit.Author]; !ok {
+		g.authors[commit.Author] = struct{}{}
+		g.historySize.UniqueAuthorCount.Increment(1)
+	}
+
+	total, ok := g.committers[commit.Committer]
+	if !ok {
+		total = &CommitterTotal{Committer: commit.Committer}
+		g.committers[commit.Committer] = total
+		g.historySize.UniqueCommitterCount.Increment(1)
+	}
+	total.CommitCount.Increment(1)
+	total.CommitSize.Increment(counts.Count64(commit.Size))
+}
+
+// topCommitters returns the `n` committers whose commits have the
+// largest total size, largest first.
+func (g *Graph) topCommitters(n int) []CommitterTotal {
+	g.historyLock.Lock()
+	defer g.historyLock.Unlock()
+
+	totals := make([]CommitterTotal, 0, len(g.committers))
+	for _, total := range g.committers {
+		totals = append(totals, *total)
+	}
+	sort.Slice(totals, func(i, j int) bool {
+		switch {
+		case totals[i].CommitSize != totals[j].CommitSize:
+			return totals[i].CommitSize > totals[j].CommitSize
+		case totals[i].CommitCount != totals[j].CommitCount:
+			return totals[i].CommitCount > totals[j].CommitCount
+		default:
+			return totals[i].Committer < totals[j].Committer
+		}
+	})
+	if len(totals) > n {
+		totals = totals[:n]
+	}
+
+	for i := range totals {
+		totals[i].Committer = g.historySize.anonymizer.Identity(totals[i].Committer)
+	}
+	return totals
+}
+
+// TopCommittersTableString returns a table listing the committers
+// whose commits have the largest total size, or the empty string if
+// they weren't requested.
+func (s *HistorySize) TopCommittersTableString() string {
+	if s.TopCommitters == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nTop committers (by total size of their commit objects):\n\n")
+	if len(s.TopCommitters) == 0 {
+		fmt.Fprintf(buf, "No commits were analyzed.\n")
+		return buf.String()
+	}
+
+	fmt.Fprintln(buf, "| Size      | Commits   | Committer")
+	fmt.Fprintln(buf, "| --------- | --------- | ---------")
+	for _, total := range s.TopCommitters {
+		commitCount, commitUnit := counts.Metric.Format(total.CommitCount, "")
+		fmt.Fprintf(
+			buf, "| %s | %5s %-3s | %s\n",
+			formatSharedBytes(total.CommitSize), commitCount, commitUnit, total.Committer,
+		)
+	}
+	return buf.String()
+}
@@ -0,0 +1,112 @@
+package sizes
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// The files that git-sizer saves (baselines, object database states,
+// object dumps, and JSON reports) are compressed with gzip if their
+// names end in `compressedSuffix`. Gzipped files are decompressed
+// when they are read, whatever their names.
+const compressedSuffix = ".gz"
+
+// gzipMagic is the first two bytes of every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// gzipFile closes a gzip writer, then the file beneath it.
+type gzipFile struct {
+	*gzip.Writer
+	f *os.File
+}
+
+func (w gzipFile) Close() error {
+	if err := w.Writer.Close(); err != nil {
+		_ = w.f.Close()
+		return err
+	}
+	return w.f.Close()
+}
+
+// CreateCompressible creates (or truncates) the file at `path` for
+// writing, compressing what is written to it with gzip if `path`
+// ends in ".gz". The caller must close the returned writer to flush
+// the compressed stream.
+func CreateCompressible(path string) (io.WriteCloser, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, compressedSuffix) {
+		return f, nil
+	}
+	return gzipFile{Writer: gzip.NewWriter(f), f: f}, nil
+}
+
+// gunzipFile closes a gzip reader, then the file beneath it.
+type gunzipFile struct {
+	io.ReadCloser
+	f *os.File
+}
+
+func (r gunzipFile) Close() error {
+	if err := r.ReadCloser.Close(); err != nil {
+		_ = r.f.Close()
+		return err
+	}
+	return r.f.Close()
+}
+
+// OpenDecompressing opens the file at `path` for reading,
+// decompressing it if it is gzipped.
+func OpenDecompressing(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReader(f)
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		_ = f.Close()
+		return nil, err
+	}
+	if !bytes.Equal(magic, gzipMagic) {
+		return gunzipFile{ReadCloser: io.NopCloser(br), f: f}, nil
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("decompressing %s: %w", path, err)
+	}
+	return gunzipFile{ReadCloser: zr, f: f}, nil
+}
+
+// WriteCompressible writes `data` to the file at `path`, like
+// `os.WriteFile()`, but compressed with gzip if `path` ends in ".gz".
+func WriteCompressible(path string, data []byte) error {
+	w, err := CreateCompressible(path)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		_ = w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// readDecompressing reads the file at `path`, like `os.ReadFile()`,
+// decompressing it if it is gzipped.
+func readDecompressing(path string) ([]byte, error) {
+	r, err := OpenDecompressing(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
@@ -0,0 +1,198 @@
+package sizes
+
+import (
+	"bytes"
+	"compress/zlib"
+	"container/heap"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// compressibilitySampleSize is the maximum number of bytes at the
+// start of each blob that are compressed to estimate its
+// compressibility.
+const compressibilitySampleSize = 1 << 20
+
+// BlobCompressibility holds an estimate of how well a blob
+// compresses. The estimate is made by compressing (at most) the
+// first `compressibilitySampleSize` bytes of the blob with zlib (as
+// Git does when storing objects), so it doesn't take into account
+// any delta compression that packfiles might use.
+type BlobCompressibility struct {
+	Blob git.OID `json:"blob"`
+
+	// Name is the name of the blob, if names were requested.
+	Name *Path `json:"name,omitempty"`
+
+	// The size of the blob.
+	Size counts.Count32 `json:"size"`
+
+	// The number of bytes that were compressed, and the size of the
+	// result.
+	SampleSize           counts.Count32 `json:"sample_size"`
+	CompressedSampleSize counts.Count32 `json:"compressed_sample_size"`
+
+	// Ratio is `CompressedSampleSize / SampleSize`. Values near 1
+	// mean that the blob is essentially incompressible.
+	Ratio float64 `json:"ratio"`
+
+	// EstimatedCompressedSize is the size of the whole blob
+	// multiplied by `Ratio`.
+	EstimatedCompressedSize counts.Count64 `json:"estimated_compressed_size"`
+}
+
+// largeBlob is a candidate for the compressibility estimate or for
+// the tracking of file histories.
+type largeBlob struct {
+	oid  git.OID
+	size counts.Count32
+	path *Path
+}
+
+// largeBlobHeap is a min-heap of `largeBlob`s, ordered by size, so
+// that the smallest can be evicted when a larger one is found.
+type largeBlobHeap []largeBlob
+
+func (h largeBlobHeap) Len() int            { return len(h) }
+func (h largeBlobHeap) Less(i, j int) bool  { return h[i].size < h[j].size }
+func (h largeBlobHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *largeBlobHeap) Push(x interface{}) { *h = append(*h, x.(largeBlob)) }
+
+func (h *largeBlobHeap) Pop() interface{} {
+	old := *h
+	b := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return b
+}
+
+// recordLargeBlob considers the blob `oid` as a candidate for the
+// compressibility estimate and the file histories, keeping track of
+// the `g.largeBlobLimit` largest blobs. The caller must hold
+// `g.historyLock`.
+func (g *Graph) recordLargeBlob(oid git.OID, size counts.Count32) {
+	if g.largeBlobLimit == 0 || !g.countsTowardMaxima(oid) {
+		return
+	}
+
+	if len(g.largeBlobs) == g.largeBlobLimit {
+		if size <= g.largeBlobs[0].size {
+			return
+		}
+		evicted := heap.Pop(&g.largeBlobs).(largeBlob)
+		if evicted.path != nil {
+			g.pathResolver.ForgetPath(evicted.path)
+		}
+	}
+
+	heap.Push(&g.largeBlobs, largeBlob{
+		oid:  oid,
+		size: size,
+		path: g.pathResolver.RequestPath(oid, "blob"),
+	})
+}
+
+// largestBlobs returns at most `n` of the largest blobs that were
+// recorded by `recordLargeBlob()`, largest first.
+func (g *Graph) largestBlobs(n int) []largeBlob {
+	g.historyLock.Lock()
+	blobs := append([]largeBlob(nil), g.largeBlobs...)
+	g.historyLock.Unlock()
+
+	sort.Slice(blobs, func(i, j int) bool {
+		if blobs[i].size != blobs[j].size {
+			return blobs[i].size > blobs[j].size
+		}
+		return bytes.Compare(blobs[i].oid.Bytes(), blobs[j].oid.Bytes()) < 0
+	})
+	if len(blobs) > n {
+		blobs = blobs[:n]
+	}
+	return blobs
+}
+
+// estimateCompressibility estimates the compressibility of `blobs`,
+// which must be sorted largest first, and stores the results in
+// `s.BlobCompressibility`.
+func (s *HistorySize) estimateCompressibility(
+	ctx context.Context, repo *git.Repository, blobs []largeBlob, progressMeter meter.Progress,
+) error {
+	s.BlobCompressibility = []BlobCompressibility{}
+
+	progressMeter.Start("Compressing samples of large blobs: %d")
+	meter.SetTotal(progressMeter, int64(len(blobs)))
+	defer progressMeter.Done()
+	for _, blob := range blobs {
+		progressMeter.Inc()
+		sample, err := repo.ReadBlobPrefix(ctx, blob.oid, compressibilitySampleSize)
+		if err != nil {
+			return err
+		}
+
+		compressed, err := zlibSize(sample)
+		if err != nil {
+			return fmt.Errorf("compressing blob '%s': %w", blob.oid, err)
+		}
+
+		ratio := 1.0
+		if len(sample) > 0 {
+			ratio = float64(compressed) / float64(len(sample))
+		}
+
+		s.BlobCompressibility = append(s.BlobCompressibility, BlobCompressibility{
+			Blob:                    blob.oid,
+			Name:                    blob.path,
+			Size:                    blob.size,
+			SampleSize:              counts.NewCount32(uint64(len(sample))),
+			CompressedSampleSize:    counts.NewCount32(uint64(compressed)),
+			Ratio:                   ratio,
+			EstimatedCompressedSize: counts.NewCount64(uint64(ratio * float64(blob.size))),
+		})
+	}
+
+	return nil
+}
+
+// zlibSize returns the size of `data` after compression with zlib at
+// the default compression level.
+func zlibSize(data []byte) (int, error) {
+	var buf bytes.Buffer
+	w := zlib.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return 0, err
+	}
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	return buf.Len(), nil
+}
+
+// CompressibilityTableString returns a table showing the estimated
+// compressibility of the largest blobs, or the empty string if it
+// wasn't computed.
+func (s *HistorySize) CompressibilityTableString() string {
+	if len(s.BlobCompressibility) == 0 {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nEstimated zlib compressibility of the largest blobs:\n\n")
+	fmt.Fprintln(buf, "| Size      | Ratio | Compressed | Blob")
+	fmt.Fprintln(buf, "| --------- | ----- | ---------- | ----")
+	for _, c := range s.BlobCompressibility {
+		name := c.Blob.String()
+		if c.Name != nil {
+			name = c.Name.BestPath()
+		}
+		fmt.Fprintf(
+			buf, "| %s | %5.2f | %s  | %s\n",
+			formatSharedBytes(counts.Count64(c.Size)), c.Ratio,
+			formatSharedBytes(c.EstimatedCompressedSize), name,
+		)
+	}
+	return buf.String()
+}
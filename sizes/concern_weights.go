@@ -0,0 +1,195 @@
+package sizes
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/github/git-sizer/git"
+)
+
+// ConcernFunction is how the ratio of a statistic's value to its
+// reference value is turned into a level of concern.
+type ConcernFunction string
+
+const (
+	// ConcernLinear makes the level of concern proportional to the
+	// ratio, so that twice the reference value earns two stars. This
+	// is the default.
+	ConcernLinear ConcernFunction = "linear"
+
+	// ConcernLog makes the level of concern grow with the logarithm
+	// of the ratio: one star at the reference value, and one more
+	// each time the ratio, plus one, doubles (e.g., two stars at three
+	// times the reference value and three at seven times). It suits
+	// statistics that are bothersome when they are large, but not
+	// much more so when they are huge.
+	ConcernLog ConcernFunction = "log"
+)
+
+// ConcernWeight is how the level of concern of a statistic is
+// computed from the ratio of its value to its reference value: the
+// result of `Function` is multiplied by `Weight`. The zero value is
+// the same as `{ConcernLinear, 1}`, which yields the ratio itself.
+type ConcernWeight struct {
+	Function ConcernFunction `json:"function"`
+	Weight   float64         `json:"weight"`
+}
+
+// ParseConcernWeight parses `s`, which has the form
+// "<function>[:<weight>]" (e.g., "log" or "linear:2"). The weight
+// defaults to 1, and must be positive.
+func ParseConcernWeight(s string) (ConcernWeight, error) {
+	function, weight := s, ""
+	if i := strings.IndexByte(s, ':'); i >= 0 {
+		function, weight = s[:i], s[i+1:]
+	}
+
+	w := ConcernWeight{Function: ConcernFunction(function), Weight: 1}
+	switch w.Function {
+	case ConcernLinear, ConcernLog:
+	default:
+		return ConcernWeight{}, fmt.Errorf(
+			"unknown concern function %q (expected '%s' or '%s')", function, ConcernLinear, ConcernLog,
+		)
+	}
+	if weight != "" {
+		v, err := strconv.ParseFloat(weight, 64)
+		if err != nil || !(v > 0) || math.IsInf(v, 1) {
+			return ConcernWeight{}, fmt.Errorf("concern weight %q must be a positive number", weight)
+		}
+		w.Weight = v
+	}
+	return w, nil
+}
+
+// String returns `w` in the form accepted by `ParseConcernWeight()`.
+func (w ConcernWeight) String() string {
+	function := w.Function
+	if function == "" {
+		function = ConcernLinear
+	}
+	if w.Weight == 0 || w.Weight == 1 {
+		return string(function)
+	}
+	return fmt.Sprintf("%s:%g", function, w.Weight)
+}
+
+// level returns the level of concern for a statistic whose value is
+// `ratio` times its reference value.
+func (w ConcernWeight) level(ratio float64) float64 {
+	if w.Function == ConcernLog {
+		ratio = math.Log2(1 + ratio)
+	}
+	if w.Weight == 0 {
+		return ratio
+	}
+	return w.Weight * ratio
+}
+
+// ratioAt returns the ratio of a statistic's value to its reference
+// value at which its level of concern is `level`; i.e., the inverse
+// of `level()`.
+func (w ConcernWeight) ratioAt(level float64) float64 {
+	if w.Weight != 0 {
+		level /= w.Weight
+	}
+	if w.Function == ConcernLog {
+		return math.Exp2(level) - 1
+	}
+	return level
+}
+
+// ConcernWeights maps the symbols of statistics to the way that their
+// levels of concern are computed, for the statistics that shouldn't
+// use the default (see `ConcernWeight`). This lets an organization
+// tune which statistics earn stars (e.g., to care more about the
+// number of references than about the size of blobs) without changing
+// the reference values.
+type ConcernWeights map[string]ConcernWeight
+
+// Add sets the weight of the statistic with the specified symbol to
+// `weight`, which is parsed by `ParseConcernWeight()`.
+func (cw *ConcernWeights) Add(symbol, weight string) error {
+	if err := checkStatSymbol(symbol); err != nil {
+		return err
+	}
+	w, err := ParseConcernWeight(weight)
+	if err != nil {
+		return fmt.Errorf("error parsing concern weight for '%s': %w", symbol, err)
+	}
+	if *cw == nil {
+		*cw = make(ConcernWeights)
+	}
+	(*cw)[symbol] = w
+	return nil
+}
+
+// ReadConcernWeights reads the weights that are set via the
+// `sizer.concernWeight.<symbol>` settings in `repo`'s gitconfig, with
+// those in `overrides` (from the command line) taking precedence.
+// Since Git folds the last component of a key to lower case, the
+// symbols are matched case-insensitively.
+func ReadConcernWeights(
+	ctx context.Context, repo *git.Repository, overrides ConcernWeights,
+) (ConcernWeights, error) {
+	config, err := repo.GetConfigContext(ctx, "sizer.concernWeight")
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := make(map[string]string, len(statNeeds))
+	for symbol := range statNeeds {
+		symbols[strings.ToLower(symbol)] = symbol
+	}
+
+	var weights ConcernWeights
+	for _, entry := range config.Entries {
+		symbol, ok := symbols[strings.ToLower(entry.Key)]
+		if !ok {
+			symbol = entry.Key
+		}
+		if err := weights.Add(symbol, entry.Value); err != nil {
+			return nil, fmt.Errorf(
+				"parsing gitconfig value for '%s': %w", config.FullKey(entry.Key), err,
+			)
+		}
+	}
+	for symbol, w := range overrides {
+		if weights == nil {
+			weights = make(ConcernWeights)
+		}
+		weights[symbol] = w
+	}
+	return weights, nil
+}
+
+// Methods to implement FlagValue:
+
+func (cw *ConcernWeights) String() string {
+	symbols := make([]string, 0, len(*cw))
+	for symbol := range *cw {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	weights := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		weights[i] = fmt.Sprintf("%s=%s", symbol, (*cw)[symbol])
+	}
+	return strings.Join(weights, ",")
+}
+
+func (cw *ConcernWeights) Set(s string) error {
+	eq := strings.IndexByte(s, '=')
+	if eq == -1 {
+		return fmt.Errorf("concern weight %q is not of the form SYMBOL=FUNCTION[:WEIGHT]", s)
+	}
+	return cw.Add(strings.TrimSpace(s[:eq]), strings.TrimSpace(s[eq+1:]))
+}
+
+func (cw *ConcernWeights) Type() string {
+	return "symbol=weight"
+}
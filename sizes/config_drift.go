@@ -0,0 +1,234 @@
+package sizes
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// The following are the sizes at which the gitconfig settings
+// checked by `checkConfigDrift()` start to matter.
+const (
+	// driftBigBlobSize is the blob size above which delta
+	// compression is recommended to be skipped, by setting
+	// `core.bigFileThreshold`. Blobs this big are usually binary
+	// files that don't delta well, but searching for deltas for them
+	// takes a lot of memory and time.
+	driftBigBlobSize = 100 << 20
+
+	// driftBigPackSize is the reachable size on disk above which
+	// `gc.bigPackThreshold` is recommended, so that `git gc` doesn't
+	// rewrite the bulk of the history every time.
+	driftBigPackSize = 4 << 30
+
+	// driftManyCommits is the number of commits above which the
+	// commit-graph is essential for history walks to be fast.
+	driftManyCommits = 100e3
+)
+
+// ConfigDrift is a gitconfig setting of the repository whose value
+// doesn't suit the repository's size, as measured by the scan.
+type ConfigDrift struct {
+	// Key is the name of the setting (e.g., "core.bigFileThreshold").
+	Key string `json:"key"`
+
+	// Value is the setting's value, or the empty string if it is
+	// not set, in which case Git uses `Default`.
+	Value   string `json:"value"`
+	Default string `json:"default"`
+
+	// Recommended is the value that the setting should have.
+	Recommended string `json:"recommended"`
+
+	// Reason explains why `Recommended` suits the repository better.
+	Reason string `json:"reason"`
+}
+
+// Command returns the command that changes the setting to its
+// recommended value.
+func (d ConfigDrift) Command() string {
+	return fmt.Sprintf("git config %s %s", d.Key, d.Recommended)
+}
+
+// checkConfigDrift compares the gitconfig settings of `repo` that
+// affect how well Git copes with big repositories to the values that
+// the statistics in `s` call for, and stores the mismatches in
+// `s.ConfigDrift`. Statistics that weren't computed are zero, so they
+// don't call for any changes.
+func (s *HistorySize) checkConfigDrift(ctx context.Context, repo *git.Repository) error {
+	var drift []ConfigDrift
+
+	// lookup returns the value of `key` as written in the gitconfig,
+	// or the empty string if it isn't set.
+	lookup := func(key string) (string, error) {
+		v, err := repo.ConfigStringDefaultContext(ctx, key, "")
+		if err != nil {
+			return "", fmt.Errorf("reading '%s': %w", key, err)
+		}
+		return v, nil
+	}
+	lookupInt := func(key string, defaultValue int) (string, int, error) {
+		raw, err := lookup(key)
+		if err != nil || raw == "" {
+			return raw, defaultValue, err
+		}
+		v, err := repo.ConfigIntDefaultContext(ctx, key, defaultValue)
+		if err != nil {
+			return "", 0, fmt.Errorf("reading '%s': %w", key, err)
+		}
+		return raw, v, nil
+	}
+	lookupBool := func(key string, defaultValue bool) (string, bool, error) {
+		raw, err := lookup(key)
+		if err != nil || raw == "" {
+			return raw, defaultValue, err
+		}
+		v, err := repo.ConfigBoolDefaultContext(ctx, key, defaultValue)
+		if err != nil {
+			return "", false, fmt.Errorf("reading '%s': %w", key, err)
+		}
+		return raw, v, nil
+	}
+
+	if s.MaxBlobSize >= driftBigBlobSize {
+		raw, v, err := lookupInt("core.bigFileThreshold", defaultBigFileThreshold)
+		if err != nil {
+			return err
+		}
+		if v > driftBigBlobSize {
+			drift = append(drift, ConfigDrift{
+				Key:         "core.bigFileThreshold",
+				Value:       raw,
+				Default:     "512m",
+				Recommended: "100m",
+				Reason: fmt.Sprintf(
+					"the largest blob is %s; searching for deltas for blobs that big rarely pays off",
+					formatBytes(counts.Count64(s.MaxBlobSize)),
+				),
+			})
+		}
+	}
+
+	if e := s.PackObjectsEstimate; e != nil && e.TotalMemory >= packObjectsAdviceMemory {
+		raw, v, err := lookupInt("pack.window", defaultPackWindow)
+		if err != nil {
+			return err
+		}
+		if v > defaultPackWindow {
+			drift = append(drift, ConfigDrift{
+				Key:         "pack.window",
+				Value:       raw,
+				Default:     strconv.Itoa(defaultPackWindow),
+				Recommended: strconv.Itoa(defaultPackWindow),
+				Reason: fmt.Sprintf(
+					"'git pack-objects' could need %s of memory, mostly for its delta-search windows",
+					formatBytes(e.TotalMemory),
+				),
+			})
+		}
+		if e.WindowMemoryLimit == 0 {
+			raw, err := lookup("pack.windowMemory")
+			if err != nil {
+				return err
+			}
+			drift = append(drift, ConfigDrift{
+				Key:         "pack.windowMemory",
+				Value:       raw,
+				Default:     "0",
+				Recommended: "256m",
+				Reason: fmt.Sprintf(
+					"'git pack-objects' could need %s of memory, and the delta-search windows aren't limited",
+					formatBytes(e.TotalMemory),
+				),
+			})
+		}
+	}
+
+	if s.ReachableDiskSize >= driftBigPackSize {
+		raw, err := lookup("gc.bigPackThreshold")
+		if err != nil {
+			return err
+		}
+		if raw == "" {
+			drift = append(drift, ConfigDrift{
+				Key:         "gc.bigPackThreshold",
+				Default:     "0",
+				Recommended: "1g",
+				Reason: fmt.Sprintf(
+					"the history takes %s on disk, which 'git gc' would rewrite every time",
+					formatBytes(s.ReachableDiskSize),
+				),
+			})
+		}
+	}
+
+	if s.UniqueCommitCount >= driftManyCommits {
+		commitCount, commitUnit := counts.Metric.Format(s.UniqueCommitCount, "")
+		for _, key := range []string{"core.commitGraph", "gc.writeCommitGraph"} {
+			raw, v, err := lookupBool(key, true)
+			if err != nil {
+				return err
+			}
+			if !v {
+				drift = append(drift, ConfigDrift{
+					Key:         key,
+					Value:       raw,
+					Default:     "true",
+					Recommended: "true",
+					Reason: fmt.Sprintf(
+						"walking the history of %s%s commits is much faster with the commit-graph",
+						commitCount, commitUnit,
+					),
+				})
+			}
+		}
+	}
+
+	if e := s.IndexEstimate; e != nil && e.ManyFilesAdvised {
+		raw, v, err := lookupBool("feature.manyFiles", false)
+		if err != nil {
+			return err
+		}
+		if !v {
+			entryCount, entryUnit := counts.Metric.Format(e.EntryCount, "")
+			drift = append(drift, ConfigDrift{
+				Key:         "feature.manyFiles",
+				Value:       raw,
+				Default:     "false",
+				Recommended: "true",
+				Reason: fmt.Sprintf(
+					"the index of the biggest checkout would hold %s%s entries",
+					entryCount, entryUnit,
+				),
+			})
+		}
+	}
+
+	s.ConfigDrift = drift
+	return nil
+}
+
+// writeConfigDriftRecommendations writes the gitconfig settings that
+// don't suit the repository, and the commands that fix them, to `w`.
+func writeConfigDriftRecommendations(w io.Writer, drift []ConfigDrift) {
+	if len(drift) == 0 {
+		return
+	}
+
+	fmt.Fprintf(w, "* Some gitconfig settings don't suit a repository of this size:\n")
+	for _, d := range drift {
+		value := d.Value
+		if value == "" {
+			value = d.Default + " (the default)"
+		}
+		fmt.Fprintf(
+			w, "    * '%s' is %s, but %s is recommended, because %s:\n",
+			d.Key, value, d.Recommended, d.Reason,
+		)
+		fmt.Fprintf(w, "          %s\n", d.Command())
+	}
+}
@@ -0,0 +1,309 @@
+package sizes
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// CustomMetric is the quantity that a custom statistic measures.
+type CustomMetric string
+
+const (
+	// CustomMetricCount counts the distinct blobs (or the
+	// references) that match.
+	CustomMetricCount CustomMetric = "count"
+
+	// CustomMetricTotalSize sums the sizes of the distinct blobs
+	// that match.
+	CustomMetricTotalSize CustomMetric = "totalSize"
+
+	// CustomMetricMaxSize is the size of the largest blob that
+	// matches.
+	CustomMetricMaxSize CustomMetric = "maxSize"
+)
+
+// CustomStat defines a statistic that is configured in gitconfig
+// rather than built into git-sizer, e.g.:
+//
+//	[sizer "custom.dataFiles"]
+//	        pathRegexp = \\.parquet$
+//	        metric = totalSize
+//
+// Its symbol is "custom." followed by its name.
+type CustomStat struct {
+	Name string
+
+	// Title is the name of the statistic in the table output. It
+	// defaults to `Name`.
+	Title string
+
+	// PathRegexp, if set, selects the blobs for which a tree entry
+	// has a matching filename. The regexp is matched against the
+	// names of the entries, not their full paths, because the same
+	// tree can appear at many paths.
+	PathRegexp *regexp.Regexp
+
+	// RefRegexp, if set, selects the references whose full names
+	// match. Only `CustomMetricCount` can be used with it.
+	RefRegexp *regexp.Regexp
+
+	Metric CustomMetric
+
+	// ReferenceValue is the value at which the level of concern is
+	// 1. If it is zero, a default for `Metric` is used.
+	ReferenceValue float64
+}
+
+// Symbol returns the key of the statistic in the JSON output and in
+// `--stats`.
+func (cs *CustomStat) Symbol() string {
+	return "custom." + cs.Name
+}
+
+// description describes the statistic for the JSON output.
+func (cs *CustomStat) description() string {
+	if cs.RefRegexp != nil {
+		return fmt.Sprintf("The number of references matching '%s'", cs.RefRegexp)
+	}
+	switch cs.Metric {
+	case CustomMetricTotalSize:
+		return fmt.Sprintf("The total size of the distinct blobs whose filenames match '%s'", cs.PathRegexp)
+	case CustomMetricMaxSize:
+		return fmt.Sprintf("The size of the largest blob whose filename matches '%s'", cs.PathRegexp)
+	default:
+		return fmt.Sprintf("The number of distinct blobs whose filenames match '%s'", cs.PathRegexp)
+	}
+}
+
+// referenceValue returns the reference value of the statistic.
+func (cs *CustomStat) referenceValue() float64 {
+	if cs.ReferenceValue > 0 {
+		return cs.ReferenceValue
+	}
+	switch cs.Metric {
+	case CustomMetricTotalSize:
+		return 1e9
+	case CustomMetricMaxSize:
+		return 10e6
+	default:
+		return 10e3
+	}
+}
+
+// ReadCustomStats reads the definitions of the custom statistics
+// from the `sizer.custom.<name>.*` settings in `repo`'s gitconfig, in
+// the order in which they are first mentioned. Unrecognized keys are
+// ignored.
+func ReadCustomStats(ctx context.Context, repo *git.Repository) ([]CustomStat, error) {
+	config, err := repo.GetConfigContext(ctx, "sizer.custom")
+	if err != nil {
+		return nil, err
+	}
+
+	var stats []*CustomStat
+	byName := make(map[string]*CustomStat)
+	for _, entry := range config.Entries {
+		i := strings.LastIndexByte(entry.Key, '.')
+		if i <= 0 {
+			continue
+		}
+		name, key := entry.Key[:i], entry.Key[i+1:]
+
+		cs, ok := byName[name]
+		if !ok {
+			cs = &CustomStat{Name: name, Metric: CustomMetricCount}
+			byName[name] = cs
+			stats = append(stats, cs)
+		}
+
+		switch key {
+		case "name":
+			cs.Title = entry.Value
+		case "pathregexp", "refregexp":
+			re, err := regexp.Compile(entry.Value)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"invalid regular expression for '%s': %w",
+					config.FullKey(entry.Key), err,
+				)
+			}
+			if key == "pathregexp" {
+				cs.PathRegexp = re
+			} else {
+				cs.RefRegexp = re
+			}
+		case "metric":
+			switch m := CustomMetric(entry.Value); m {
+			case CustomMetricCount, CustomMetricTotalSize, CustomMetricMaxSize:
+				cs.Metric = m
+			default:
+				return nil, fmt.Errorf(
+					"invalid value for '%s': %q (must be 'count', 'totalSize', or 'maxSize')",
+					config.FullKey(entry.Key), entry.Value,
+				)
+			}
+		case "referencevalue":
+			v, err := strconv.ParseFloat(entry.Value, 64)
+			if err != nil || !(v > 0) || math.IsInf(v, 1) {
+				return nil, fmt.Errorf(
+					"invalid value for '%s': %q (must be a positive number)",
+					config.FullKey(entry.Key), entry.Value,
+				)
+			}
+			cs.ReferenceValue = v
+		default:
+			// Ignore unrecognized keys.
+		}
+	}
+
+	result := make([]CustomStat, 0, len(stats))
+	for _, cs := range stats {
+		prefix := config.FullKey(cs.Name)
+		switch {
+		case cs.PathRegexp == nil && cs.RefRegexp == nil:
+			return nil, fmt.Errorf(
+				"custom statistic '%s' needs either '%s.pathRegexp' or '%s.refRegexp'",
+				cs.Name, prefix, prefix,
+			)
+		case cs.PathRegexp != nil && cs.RefRegexp != nil:
+			return nil, fmt.Errorf(
+				"custom statistic '%s' can't have both '%s.pathRegexp' and '%s.refRegexp'",
+				cs.Name, prefix, prefix,
+			)
+		case cs.RefRegexp != nil && cs.Metric != CustomMetricCount:
+			return nil, fmt.Errorf(
+				"custom statistic '%s' matches references, so its metric must be 'count'",
+				cs.Name,
+			)
+		}
+		if cs.Title == "" {
+			cs.Title = cs.Name
+		}
+		result = append(result, *cs)
+	}
+	return result, nil
+}
+
+// CustomStatValue is the value of a custom statistic.
+type CustomStatValue struct {
+	Name   string         `json:"name"`
+	Metric CustomMetric   `json:"metric"`
+	Value  counts.Count64 `json:"value"`
+
+	// Blob is the largest matching blob, for `CustomMetricMaxSize`.
+	Blob *Path `json:"blob,omitempty"`
+
+	def CustomStat
+}
+
+// customStatCounter accumulates the value of a custom statistic that
+// matches paths while the trees are registered.
+type customStatCounter struct {
+	value *CustomStatValue
+
+	// seen holds the blobs that have already been counted, so that
+	// each is counted once, however many tree entries refer to it.
+	seen map[git.OID]struct{}
+}
+
+// newCustomStatValues returns the (zero) values of the custom
+// statistics `defs`, along with the counters for those that match
+// paths.
+func newCustomStatValues(defs []CustomStat) ([]*CustomStatValue, []customStatCounter) {
+	if len(defs) == 0 {
+		return nil, nil
+	}
+
+	values := make([]*CustomStatValue, 0, len(defs))
+	var counters []customStatCounter
+	for _, def := range defs {
+		v := &CustomStatValue{
+			Name:   def.Name,
+			Metric: def.Metric,
+			def:    def,
+		}
+		values = append(values, v)
+		if def.PathRegexp != nil {
+			counters = append(counters, customStatCounter{
+				value: v,
+				seen:  make(map[git.OID]struct{}),
+			})
+		}
+	}
+	return values, counters
+}
+
+// recordCustomEntry records the tree entry called `name` in the tree
+// `oid`, which refers to the blob `blobOID` of the specified `size`,
+// in the custom statistics whose path matchers match it. It has to be
+// called before the tree entry is recorded in the path resolver, so
+// that the blob's path can be resolved.
+func (g *Graph) recordCustomEntry(oid git.OID, name string, blobOID git.OID, size BlobSize) {
+	g.historyLock.Lock()
+	defer g.historyLock.Unlock()
+
+	for _, c := range g.customStats {
+		if !c.value.def.PathRegexp.MatchString(name) {
+			continue
+		}
+
+		if c.value.Metric == CustomMetricMaxSize {
+			if !g.countsTowardMaxima(blobOID) {
+				continue
+			}
+			if c.value.Value.AdjustMaxIfNecessary(counts.Count64(size.Size)) {
+				if c.value.Blob != nil {
+					g.pathResolver.ForgetPath(c.value.Blob)
+				}
+				c.value.Blob = g.pathResolver.RequestEntryPath(oid, name, blobOID, "blob")
+			}
+			continue
+		}
+
+		if _, ok := c.seen[blobOID]; ok || !g.countsTowardTotals(blobOID) {
+			continue
+		}
+		c.seen[blobOID] = struct{}{}
+		if c.value.Metric == CustomMetricTotalSize {
+			c.value.Value.Increment(counts.Count64(size.Size))
+		} else {
+			c.value.Value.Increment(1)
+		}
+	}
+}
+
+// recordCustomReference counts `ref` in the custom statistics whose
+// reference matchers match it. The caller must hold `g.historyLock`.
+func (s *HistorySize) recordCustomReference(ref git.Reference) {
+	for _, v := range s.CustomStats {
+		if v.def.RefRegexp != nil && v.def.RefRegexp.MatchString(ref.Refname) {
+			v.Value.Increment(1)
+		}
+	}
+}
+
+// customStatItems returns the table items for the custom statistics
+// in `s`.
+func (s *HistorySize) customStatItems(
+	I func(string, string, string, *Path, counts.Humanable, counts.Humaner, string, float64) *item,
+) []tableContents {
+	items := make([]tableContents, 0, len(s.CustomStats))
+	for _, v := range s.CustomStats {
+		humaner, unit := counts.Binary, "B"
+		if v.Metric == CustomMetricCount {
+			humaner, unit = counts.Metric, ""
+		}
+		items = append(items, I(
+			v.def.Symbol(), v.def.Title, v.def.description(),
+			v.Blob, v.Value, humaner, unit, v.def.referenceValue(),
+		))
+	}
+	return items
+}
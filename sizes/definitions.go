@@ -0,0 +1,414 @@
+package sizes
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+)
+
+// statKind describes how a statistic is computed from the objects
+// (or other things) that it covers.
+type statKind int
+
+const (
+	// kindUniqueCount statistics count distinct objects.
+	kindUniqueCount statKind = iota
+
+	// kindUniqueTotal statistics sum a quantity over distinct
+	// objects.
+	kindUniqueTotal
+
+	// kindObjectCount statistics count the distinct objects (or tree
+	// entries) that have some property.
+	kindObjectCount
+
+	// kindObjectMax statistics are the maximum of a quantity over
+	// individual objects.
+	kindObjectMax
+
+	// kindCheckoutMax statistics are the maximum of a quantity over
+	// the expanded checkouts of individual commits.
+	kindCheckoutMax
+
+	// kindMainCheckouts statistics are computed from the checkout of
+	// `HEAD` and the checkout with the largest total size of files
+	// only.
+	kindMainCheckouts
+
+	// kindHistory statistics describe the shape of the commit graph.
+	kindHistory
+
+	// kindReferences statistics are computed from the references
+	// alone.
+	kindReferences
+
+	// kindObjectDatabase statistics describe the files in the object
+	// database, whether or not the objects are reachable.
+	kindObjectDatabase
+
+	// kindEstimate statistics are estimates that are derived from
+	// other statistics and from the repository's configuration.
+	kindEstimate
+
+	// kindScan statistics describe the scan, or the tools used for
+	// it, rather than the repository.
+	kindScan
+)
+
+// statKinds maps the symbol of each statistic listed in `statNeeds`
+// to its kind. The kinds of reference-group statistics are derived
+// from their symbols; see `kindOf()`.
+var statKinds = map[string]statKind{
+	"uniqueCommitCount":         kindUniqueCount,
+	"uniqueCommitSize":          kindUniqueTotal,
+	"emptyCommitCount":          kindObjectCount,
+	"emptyMergeCount":           kindObjectCount,
+	"nonUTF8EncodingCount":      kindObjectCount,
+	"invalidUTF8MessageCount":   kindObjectCount,
+	"uniqueAuthorCount":         kindUniqueCount,
+	"uniqueCommitterCount":      kindUniqueCount,
+	"uniqueTreeCount":           kindUniqueCount,
+	"uniqueTreeSize":            kindUniqueTotal,
+	"uniqueTreeEntries":         kindUniqueTotal,
+	"duplicateSubtreeTreeCount": kindObjectCount,
+	"uniqueBlobCount":           kindUniqueCount,
+	"uniqueBlobSize":            kindUniqueTotal,
+	"uniqueTagCount":            kindUniqueCount,
+	"uniqueTagSize":             kindUniqueTotal,
+	"signedTagCount":            kindObjectCount,
+	"referenceCount":            kindReferences,
+	"nonCommitBranchCount":      kindReferences,
+	"nonCommitTagCount":         kindReferences,
+
+	"looseObjectCount":         kindObjectDatabase,
+	"maxLooseObjectShardCount": kindObjectDatabase,
+	"oldestLooseObjectAge":     kindObjectDatabase,
+
+	"maxCommitSize":              kindObjectMax,
+	"maxCommitParentCount":       kindObjectMax,
+	"maxCommitHeaderSize":        kindObjectMax,
+	"nonstandardHeaderCount":     kindObjectCount,
+	"maxNonUTF8CommitSize":       kindObjectMax,
+	"maxTreeEntries":             kindObjectMax,
+	"maxRootTreeEntries":         kindObjectMax,
+	"minRootTreeEntries":         kindObjectMax,
+	"currentRootTreeEntries":     kindObjectMax,
+	"maxDuplicateSubtreeEntries": kindObjectMax,
+	"maxTagOnlyTreeSize":         kindObjectMax,
+	"maxBlobSize":                kindObjectMax,
+	"maxExecutableBlobSize":      kindObjectMax,
+	"maxTagOnlyBlobSize":         kindObjectMax,
+	"over2GiBBlobCount":          kindObjectCount,
+	"over4GiBBlobCount":          kindObjectCount,
+	"maxGitmodulesSize":          kindObjectMax,
+	"uniqueGitmodulesSize":       kindUniqueTotal,
+	"maxGitattributesSize":       kindObjectMax,
+	"uniqueGitattributesSize":    kindUniqueTotal,
+	"maxHookFileSize":            kindObjectMax,
+	"uniqueHookFileSize":         kindUniqueTotal,
+	"lockfileVersionCount":       kindObjectCount,
+	"uniqueLockfileSize":         kindUniqueTotal,
+	"maxLockfileSize":            kindObjectMax,
+	"maxTagSize":                 kindObjectMax,
+
+	"maxHistoryDepth":          kindHistory,
+	"rootCommitCount":          kindHistory,
+	"disconnectedHistoryCount": kindHistory,
+	"maxMergeBubble":           kindHistory,
+	"averageMergeBubble":       kindHistory,
+	"maxTagDepth":              kindObjectMax,
+
+	"maxCheckoutTreeCount":       kindCheckoutMax,
+	"maxCheckoutPathDepth":       kindCheckoutMax,
+	"maxCheckoutPathLength":      kindCheckoutMax,
+	"maxCheckoutBlobCount":       kindCheckoutMax,
+	"maxCheckoutBlobSize":        kindCheckoutMax,
+	"maxCheckoutIndexSize":       kindCheckoutMax,
+	"maxCheckoutExecutableCount": kindCheckoutMax,
+	"maxCheckoutLinkCount":       kindCheckoutMax,
+	"maxCheckoutSubmoduleCount":  kindCheckoutMax,
+
+	"potentialGitBombCount": kindObjectCount,
+
+	"maxCheckoutWindowsUnsafeCount": kindMainCheckouts,
+	"windowsUnsafeEntryCount":       kindMainCheckouts,
+
+	"normalizationCollisionTreeCount": kindObjectCount,
+	"unusualUnicodeEntryCount":        kindObjectCount,
+
+	"nestedRepositoryTreeCount": kindObjectCount,
+
+	"absoluteSymlinkCount":   kindObjectCount,
+	"symlinkCycleTreeCount":  kindObjectCount,
+	"symlinkTargetCount":     kindObjectCount,
+	"maxSymlinkTargetLength": kindObjectMax,
+	"escapingSymlinkCount":   kindObjectCount,
+
+	"packObjectsMemory": kindEstimate,
+
+	"graphBlobMemory":         kindScan,
+	"graphTreeMemory":         kindScan,
+	"graphPendingTreeMemory":  kindScan,
+	"graphCommitMemory":       kindScan,
+	"graphTagMemory":          kindScan,
+	"graphPathResolverMemory": kindScan,
+	"graphMemory":             kindScan,
+
+	"gitCapabilities": kindScan,
+}
+
+// kindOf returns the kind of the statistic with the specified
+// symbol, and false if it is unknown.
+func kindOf(symbol string) (statKind, bool) {
+	if strings.HasPrefix(symbol, "refgroup.") {
+		if strings.HasSuffix(symbol, ".maxCheckoutBlobCount") ||
+			strings.HasSuffix(symbol, ".maxCheckoutBlobSize") {
+			return kindCheckoutMax, true
+		}
+		return kindReferences, true
+	}
+	kind, ok := statKinds[symbol]
+	return kind, ok
+}
+
+// computation explains how statistics of kind `k` are computed.
+func (k statKind) computation() string {
+	switch k {
+	case kindUniqueCount:
+		return "Counts distinct objects. An object that is reachable in " +
+			"several ways (e.g., from many commits) is counted once."
+	case kindUniqueTotal:
+		return "Sums over distinct objects. Each object contributes once, " +
+			"however many commits or paths contain it. Sizes are the " +
+			"uncompressed sizes of the objects, not their sizes on disk."
+	case kindObjectCount:
+		return "Counts the distinct objects (or distinct tree entries) that " +
+			"have the property. Each is counted once, however many commits " +
+			"contain it."
+	case kindObjectMax:
+		return "The maximum over individual objects, each considered on its " +
+			"own. The object that attains it is cited in a footnote."
+	case kindCheckoutMax:
+		return "The maximum over the checkouts of individual commits (and " +
+			"of trees that references point at directly), not a total " +
+			"over the history. Within a checkout, every occurrence of a " +
+			"file or directory is counted, even if several have the same " +
+			"contents (the checkout is \"expanded\"). Submodules are " +
+			"counted but not followed. The tree that attains the maximum " +
+			"is cited in a footnote."
+	case kindMainCheckouts:
+		return "Computed from the checkout of HEAD (if it was scanned) " +
+			"and from the checkout with the largest total size of files, " +
+			"which are the ones that are most likely to be checked out, " +
+			"rather than from every tree in the history. The checkout " +
+			"that attains the maximum, or an entry that was counted, is " +
+			"cited in a footnote."
+	case kindHistory:
+		return "Computed from the graph of the distinct commits and their " +
+			"parents."
+	case kindReferences:
+		return "Computed from the references (and, for reference tips, the " +
+			"objects that they point at)."
+	case kindObjectDatabase:
+		return "Computed from the files in the object database, whether or " +
+			"not the objects are reachable."
+	case kindEstimate:
+		return "A rough estimate, computed from other statistics and from " +
+			"the repository's gitconfig settings (or Git's defaults). It " +
+			"is meant to flag repositories whose shape makes them " +
+			"expensive, not to predict exact figures."
+	case kindScan:
+		return "Describes the scan, not the repository. It is only reported " +
+			"if requested via '--stats'."
+	default:
+		panic("unknown statKind")
+	}
+}
+
+// objectsNote explains which objects the statistics that cover
+// objects include.
+const objectsNote = "Only the objects reachable from the selected references " +
+	"and ROOTs are included (see '--objects-since' for a further " +
+	"restriction)."
+
+// StatDefinition describes precisely what a statistic means and how
+// it is computed.
+type StatDefinition struct {
+	// Symbol is the key of the statistic in the JSON output and in
+	// '--stats'.
+	Symbol string `json:"symbol"`
+
+	// Section and Name are where the statistic appears in the table
+	// output, and Description is its description in the JSON
+	// output. They are empty for statistics that aren't in the
+	// table.
+	Section     string `json:"section,omitempty"`
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// Computation explains how the statistic is aggregated, and
+	// which objects it covers.
+	Computation string `json:"computation"`
+
+	// Unit is the unit of the statistic, Prefixes the kind of
+	// prefixes ("metric", "binary", or "plain") that are used to
+	// format it, and ReferenceValue the value at which the level of
+	// concern is 1 (see `--profile`).
+	Unit           string  `json:"unit,omitempty"`
+	Prefixes       string  `json:"prefixes,omitempty"`
+	ReferenceValue float64 `json:"referenceValue,omitempty"`
+
+	humaner *counts.Humaner
+
+	// CounterBits is the width of the counter that holds the value,
+	// and SaturatesAt is the largest value that it can hold. Larger
+	// values are reported as SaturatesAt (shown as "∞", and marked
+	// as saturated in the JSON output), or as an error with
+	// `--exact-counts`.
+	CounterBits int    `json:"counterBits,omitempty"`
+	SaturatesAt uint64 `json:"saturatesAt,omitempty"`
+
+	// Explicit is true iff the statistic is only reported if
+	// requested via `--stats`.
+	Explicit bool `json:"explicit,omitempty"`
+}
+
+// Definitions returns the definitions of the statistics that are
+// selected by `stats`, in the order in which they appear in the table
+// output, followed by those that don't appear there. `refGroups` and
+// `profile` determine the reference-group statistics and the
+// reference values.
+func Definitions(refGroups []RefGroup, profile Profile, stats StatSet) []StatDefinition {
+	return DefinitionsWithReferenceValues(refGroups, profile, nil, stats)
+}
+
+// DefinitionsWithReferenceValues is like `Definitions()`, but the
+// reference values in `referenceValues` take precedence over those of
+// `profile`.
+func DefinitionsWithReferenceValues(
+	refGroups []RefGroup, profile Profile, referenceValues ReferenceValues, stats StatSet,
+) []StatDefinition {
+	// With no data, `contents()` only lists the reference groups
+	// that exist, so pretend that they all have members:
+	s := HistorySize{
+		profile:            profile,
+		referenceValues:    referenceValues,
+		ReferenceGroups:    make(map[RefGroupSymbol]*counts.Count32),
+		ReferenceGroupTips: make(map[RefGroupSymbol]*RefGroupTipSize),
+	}
+	for _, rg := range refGroups {
+		s.ReferenceGroups[rg.Symbol] = new(counts.Count32)
+		s.ReferenceGroupTips[rg.Symbol] = &RefGroupTipSize{}
+	}
+
+	var defs []StatDefinition
+	seen := make(map[string]bool)
+	s.contents(refGroups).collectDefinitions(nil, func(path []string, i *item) {
+		seen[i.symbol] = true
+		if stats != nil && !stats[i.symbol] {
+			return
+		}
+		def := newStatDefinition(i.symbol)
+		def.Section = strings.Join(path, " > ")
+		def.Name = i.name
+		def.Description = i.description
+		def.Unit = i.unit
+		def.Prefixes = i.humaner.Name()
+		def.humaner = &i.humaner
+		def.ReferenceValue = i.scale
+		switch i.value.(type) {
+		case counts.Count32:
+			def.CounterBits, def.SaturatesAt = 32, math.MaxUint32
+		case counts.Count64:
+			def.CounterBits, def.SaturatesAt = 64, math.MaxUint64
+		}
+		defs = append(defs, def)
+	})
+	for _, symbol := range knownStats() {
+		if seen[symbol] || (stats != nil && !stats[symbol]) {
+			continue
+		}
+		defs = append(defs, newStatDefinition(symbol))
+	}
+	return defs
+}
+
+// newStatDefinition returns a definition of the statistic with the
+// specified symbol, filled in as far as its kind allows.
+func newStatDefinition(symbol string) StatDefinition {
+	kind, ok := kindOf(symbol)
+	if !ok {
+		panic(fmt.Sprintf("statistic '%s' is missing from statKinds", symbol))
+	}
+	computation := kind.computation()
+	switch kind {
+	case kindUniqueCount, kindUniqueTotal, kindObjectCount, kindObjectMax,
+		kindCheckoutMax, kindMainCheckouts, kindHistory:
+		computation += " " + objectsNote
+	}
+	return StatDefinition{
+		Symbol:      symbol,
+		Computation: computation,
+		Explicit:    explicitStats[symbol],
+	}
+}
+
+// DefinitionsString formats `defs` for `--explain-stats`.
+func DefinitionsString(defs []StatDefinition) string {
+	buf := &bytes.Buffer{}
+	for i, def := range defs {
+		if i > 0 {
+			fmt.Fprintln(buf)
+		}
+		fmt.Fprintf(buf, "%s\n", def.Symbol)
+		if def.Name != "" {
+			fmt.Fprintf(buf, "    Row:         %s > %s\n", def.Section, def.Name)
+		}
+		if def.Description != "" {
+			fmt.Fprintf(buf, "    Meaning:     %s\n", def.Description)
+		}
+		fmt.Fprintf(buf, "    Computation: %s\n", wrap(def.Computation, 62, "                 "))
+		if def.ReferenceValue != 0 {
+			exact := strings.TrimSpace(
+				strconv.FormatFloat(def.ReferenceValue, 'f', -1, 64) + " " + def.Unit,
+			)
+			value, unit := def.humaner.FormatNumber(uint64(def.ReferenceValue), def.Unit)
+			if human := strings.TrimSpace(value + " " + unit); human != exact &&
+				def.ReferenceValue >= 1000 {
+				exact += " (" + human + ")"
+			}
+			fmt.Fprintf(buf, "    Reference:   %s\n", exact)
+		}
+		if def.CounterBits != 0 {
+			fmt.Fprintf(
+				buf, "    Saturation:  %d-bit counter; values above %d are shown as ∞\n",
+				def.CounterBits, def.SaturatesAt,
+			)
+		}
+	}
+	return buf.String()
+}
+
+// wrap breaks `s` into lines of at most `width` characters (except
+// for long words), indenting all but the first line with `indent`.
+func wrap(s string, width int, indent string) string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(s) {
+		switch {
+		case line == "":
+			line = word
+		case len(line)+1+len(word) > width:
+			lines = append(lines, line)
+			line = word
+		default:
+			line += " " + word
+		}
+	}
+	lines = append(lines, line)
+	return strings.Join(lines, "\n"+indent)
+}
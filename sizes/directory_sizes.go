@@ -0,0 +1,155 @@
+package sizes
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// DirectorySize is the cumulative historical size of one path: the
+// number of unique blobs and trees that were found at it or beneath
+// it, and the total size of the blobs among them. Each object is
+// attributed to only one path (the first one at which `git rev-list
+// --objects` encounters it), so the sizes of the entries of a
+// directory add up to the size of the directory (not counting the
+// directory's own trees).
+type DirectorySize struct {
+	Path        string         `json:"path"`
+	ObjectCount counts.Count32 `json:"object_count"`
+	BlobSize    counts.Count64 `json:"blob_size"`
+}
+
+// DirectorySizes holds the `DirectorySize` of every path in the
+// history, so that the sizes of any part of the repository's tree can
+// be looked up without scanning again; e.g., to draw a "heat map" of
+// the tree in a user interface. It is only computed if requested via
+// `ScanOptions.DirectorySizes`.
+type DirectorySizes struct {
+	sizes map[string]*DirectorySize
+
+	// children maps each directory to its entries, sorted by path.
+	children map[string][]*DirectorySize
+}
+
+// normalizeDirectoryPath returns `path` in the form used as a key of
+// `DirectorySizes`, without leading or trailing slashes. The
+// top-level directory is "".
+func normalizeDirectoryPath(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "." {
+		return ""
+	}
+	return path
+}
+
+// parentDirectory returns the directory containing `path`, or "" if
+// it is at the top level.
+func parentDirectory(path string) string {
+	if i := strings.LastIndexByte(path, '/'); i >= 0 {
+		return path[:i]
+	}
+	return ""
+}
+
+// Lookup returns the cumulative historical size of the file or
+// directory `path` (e.g., "src/vendor"; "" or "." for the whole
+// tree). It returns false if nothing was ever found at `path`.
+func (d *DirectorySizes) Lookup(path string) (DirectorySize, bool) {
+	if d == nil {
+		return DirectorySize{}, false
+	}
+	ds, ok := d.sizes[normalizeDirectoryPath(path)]
+	if !ok {
+		return DirectorySize{}, false
+	}
+	return *ds, true
+}
+
+// Children returns the cumulative historical sizes of the entries
+// that were ever found in the directory `path`, biggest first.
+func (d *DirectorySizes) Children(path string) []DirectorySize {
+	if d == nil {
+		return nil
+	}
+	children := d.children[normalizeDirectoryPath(path)]
+	ret := make([]DirectorySize, 0, len(children))
+	for _, ds := range children {
+		ret = append(ret, *ds)
+	}
+	sort.SliceStable(ret, func(i, j int) bool {
+		return ret[i].BlobSize > ret[j].BlobSize
+	})
+	return ret
+}
+
+// add attributes an object, of size `blobSize` if it is a blob, to
+// `path` and to each of the directories containing it.
+func (d *DirectorySizes) add(path string, blobSize counts.Count64) {
+	for {
+		ds, ok := d.sizes[path]
+		if !ok {
+			ds = &DirectorySize{Path: path}
+			d.sizes[path] = ds
+			if path != "" {
+				parent := parentDirectory(path)
+				d.children[parent] = append(d.children[parent], ds)
+			}
+		}
+		ds.ObjectCount.Increment(1)
+		ds.BlobSize.Increment(blobSize)
+		if path == "" {
+			return
+		}
+		path = parentDirectory(path)
+	}
+}
+
+// computeDirectorySizes attributes the unique blobs and trees that are
+// reachable from the walked `roots` to paths, and stores the
+// cumulative size of each path in `s.DirectorySizes`. The objects'
+// sizes are looked up in `g`.
+func (s *HistorySize) computeDirectorySizes(
+	ctx context.Context, repo *git.Repository, g *Graph, roots []Root,
+	progressMeter meter.Progress,
+) error {
+	var tips []git.OID
+	for _, root := range roots {
+		if root.Walk() {
+			tips = append(tips, root.OID())
+		}
+	}
+
+	d := &DirectorySizes{
+		sizes:    make(map[string]*DirectorySize),
+		children: make(map[string][]*DirectorySize),
+	}
+	progressMeter.Start("Totaling sizes by directory: %d")
+	err := repo.ObjectPaths(ctx, tips, func(oid git.OID, path string) {
+		progressMeter.Inc()
+		if !g.countsTowardTotals(oid) {
+			return
+		}
+		if size, ok := g.blobSizes[oid]; ok {
+			d.add(path, counts.Count64(size.Size))
+		} else if _, ok := g.treeSizes[oid]; ok {
+			d.add(path, 0)
+		}
+	})
+	progressMeter.Done()
+	if err != nil {
+		return err
+	}
+
+	for _, children := range d.children {
+		sort.Slice(children, func(i, j int) bool {
+			return children[i].Path < children[j].Path
+		})
+	}
+
+	s.DirectorySizes = d
+	return nil
+}
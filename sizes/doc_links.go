@@ -0,0 +1,97 @@
+package sizes
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/github/git-sizer/git"
+)
+
+// DocLinks maps the symbols of statistics to the URLs of documents
+// (e.g., remediation runbooks) that are linked from their rows in the
+// output.
+type DocLinks map[string]string
+
+// Add links the statistic with the specified symbol to `link`, which
+// must be an absolute URL.
+func (dl *DocLinks) Add(symbol, link string) error {
+	if err := checkStatSymbol(symbol); err != nil {
+		return err
+	}
+	u, err := url.Parse(link)
+	if err != nil || !u.IsAbs() {
+		return fmt.Errorf("link %q for '%s' is not an absolute URL", link, symbol)
+	}
+	if *dl == nil {
+		*dl = make(DocLinks)
+	}
+	(*dl)[symbol] = link
+	return nil
+}
+
+// ReadDocLinks reads the links that are set via the
+// `sizer.link.<symbol>` settings in `repo`'s gitconfig, with those in
+// `overrides` (from the command line) taking precedence. Since Git
+// folds the last component of a key to lower case, the symbols are
+// matched case-insensitively.
+func ReadDocLinks(ctx context.Context, repo *git.Repository, overrides DocLinks) (DocLinks, error) {
+	config, err := repo.GetConfigContext(ctx, "sizer.link")
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := make(map[string]string, len(statNeeds))
+	for symbol := range statNeeds {
+		symbols[strings.ToLower(symbol)] = symbol
+	}
+
+	var links DocLinks
+	for _, entry := range config.Entries {
+		symbol, ok := symbols[strings.ToLower(entry.Key)]
+		if !ok {
+			symbol = entry.Key
+		}
+		if err := links.Add(symbol, entry.Value); err != nil {
+			return nil, fmt.Errorf(
+				"parsing gitconfig value for '%s': %w", config.FullKey(entry.Key), err,
+			)
+		}
+	}
+	for symbol, link := range overrides {
+		if links == nil {
+			links = make(DocLinks)
+		}
+		links[symbol] = link
+	}
+	return links, nil
+}
+
+// Methods to implement FlagValue:
+
+func (dl *DocLinks) String() string {
+	symbols := make([]string, 0, len(*dl))
+	for symbol := range *dl {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	links := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		links[i] = fmt.Sprintf("%s=%s", symbol, (*dl)[symbol])
+	}
+	return strings.Join(links, ",")
+}
+
+func (dl *DocLinks) Set(s string) error {
+	eq := strings.IndexByte(s, '=')
+	if eq == -1 {
+		return fmt.Errorf("link %q is not of the form SYMBOL=URL", s)
+	}
+	return dl.Add(strings.TrimSpace(s[:eq]), strings.TrimSpace(s[eq+1:]))
+}
+
+func (dl *DocLinks) Type() string {
+	return "symbol=url"
+}
@@ -0,0 +1,89 @@
+package sizes
+
+// EffectiveReferenceValue is the reference value that was used for a
+// statistic, and where it came from.
+type EffectiveReferenceValue struct {
+	Value float64 `json:"value"`
+
+	// Source is "default" if the statistic's default reference value
+	// was used, "profile" if the profile chose it, "thresholds" if the
+	// thresholds file set it, or "override" if it was set explicitly
+	// (e.g., via `--reference-value`).
+	Source string `json:"source"`
+}
+
+// EffectiveConfig records the settings that determined the levels of
+// concern in a report, so that consumers of the JSON output can
+// reproduce them. The level of concern of a statistic is its value
+// divided by its reference value (transformed as specified in
+// `ConcernWeights`, if it is listed there), and only statistics whose
+// level of concern is at least `Threshold` are reported in the table
+// output.
+type EffectiveConfig struct {
+	Profile   Profile `json:"profile"`
+	Threshold float64 `json:"threshold"`
+	NameStyle string  `json:"nameStyle"`
+
+	// ReferenceValues holds the reference value of each statistic in
+	// the report, keyed by its symbol.
+	ReferenceValues map[string]EffectiveReferenceValue `json:"referenceValues"`
+
+	// ConcernWeights holds the weights of the statistics in the
+	// report whose levels of concern aren't computed the default
+	// way, keyed by their symbols.
+	ConcernWeights map[string]ConcernWeight `json:"concernWeights,omitempty"`
+
+	// Thresholds identifies the thresholds file that was applied, if
+	// any.
+	Thresholds *ThresholdsInfo `json:"thresholds,omitempty"`
+}
+
+// effectiveConfig returns the settings that were used to compute the
+// levels of concern of `items`.
+func (s *HistorySize) effectiveConfig(
+	items map[string]*item, threshold Threshold, nameStyle NameStyle,
+) EffectiveConfig {
+	profile := s.profile
+	if profile == "" {
+		profile = ProfileDefault
+	}
+
+	config := EffectiveConfig{
+		Profile:         profile,
+		Threshold:       float64(threshold),
+		NameStyle:       nameStyle.String(),
+		ReferenceValues: make(map[string]EffectiveReferenceValue, len(items)),
+	}
+	var fromFile ReferenceValues
+	if t := s.thresholds; t != nil {
+		config.Thresholds = &ThresholdsInfo{
+			Name:    t.Name,
+			Version: t.Version,
+			Path:    t.Path,
+			SHA256:  t.SHA256,
+		}
+		fromFile = t.ReferenceValues
+	}
+	for symbol, i := range items {
+		source := "default"
+		if v, ok := s.referenceValues[symbol]; ok {
+			source = "override"
+			if fv, ok := fromFile[symbol]; ok && fv == v {
+				source = "thresholds"
+			}
+		} else if _, ok := profileScales[profile][symbol]; ok {
+			source = "profile"
+		}
+		config.ReferenceValues[symbol] = EffectiveReferenceValue{
+			Value:  i.scale,
+			Source: source,
+		}
+		if w, ok := s.concernWeights[symbol]; ok {
+			if config.ConcernWeights == nil {
+				config.ConcernWeights = make(map[string]ConcernWeight)
+			}
+			config.ConcernWeights[symbol] = w
+		}
+	}
+	return config
+}
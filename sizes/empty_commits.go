@@ -0,0 +1,123 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// RefGroupEmptyCommits counts the commits that are reachable from the
+// walked references in one refgroup, and how many of them are empty
+// (i.e., have the same tree as one of their parents). Tools that
+// create empty commits, like CI systems that push "bump" commits to
+// particular references, can inflate the number of commits
+// considerably.
+type RefGroupEmptyCommits struct {
+	RefGroup         RefGroupSymbol `json:"refgroup"`
+	CommitCount      counts.Count64 `json:"commit_count"`
+	EmptyCommitCount counts.Count64 `json:"empty_commit_count"`
+	EmptyMergeCount  counts.Count64 `json:"empty_merge_count"`
+}
+
+// computeRefGroupEmptyCommits counts the commits and the empty
+// commits that are reachable from the walked references in each
+// refgroup (other than the top-level group), and stores the results
+// in `s.RefGroupEmptyCommits`. `emptyCommits` holds the empty commits
+// that were found during the scan, mapped to true for merges. This
+// takes one walk of the commit history per refgroup.
+func (s *HistorySize) computeRefGroupEmptyCommits(
+	ctx context.Context, repo *git.Repository, roots []Root, emptyCommits map[git.OID]bool,
+	progressMeter meter.Progress,
+) error {
+	groupRoots := make(map[RefGroupSymbol][]git.OID)
+	for _, root := range roots {
+		refRoot, ok := root.(ReferenceRoot)
+		if !ok || !root.Walk() {
+			continue
+		}
+		for _, group := range refRoot.Groups() {
+			if group != "" {
+				groupRoots[group] = append(groupRoots[group], root.OID())
+			}
+		}
+	}
+
+	groups := make([]RefGroupSymbol, 0, len(groupRoots))
+	for group := range groupRoots {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i] < groups[j] })
+
+	s.RefGroupEmptyCommits = make([]RefGroupEmptyCommits, 0, len(groups))
+
+	progressMeter.Start("Counting empty commits by refgroup: %d")
+	for _, group := range groups {
+		commits, err := repo.ReachableCommits(ctx, groupRoots[group])
+		if err != nil {
+			progressMeter.Done()
+			return err
+		}
+		progressMeter.Inc()
+
+		ge := RefGroupEmptyCommits{
+			RefGroup:    group,
+			CommitCount: counts.Count64(len(commits)),
+		}
+		for _, oid := range commits {
+			merge, ok := emptyCommits[oid]
+			switch {
+			case !ok:
+			case merge:
+				ge.EmptyMergeCount.Increment(1)
+			default:
+				ge.EmptyCommitCount.Increment(1)
+			}
+		}
+		s.RefGroupEmptyCommits = append(s.RefGroupEmptyCommits, ge)
+	}
+	progressMeter.Done()
+
+	return nil
+}
+
+// RefGroupEmptyCommitsTableString returns a table showing the number
+// of empty commits in each refgroup, or the empty string if they
+// weren't counted.
+func (s *HistorySize) RefGroupEmptyCommitsTableString() string {
+	if s.RefGroupEmptyCommits == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nEmpty commits, by refgroup:\n\n")
+	if len(s.RefGroupEmptyCommits) == 0 {
+		fmt.Fprintln(buf, "No refgroups have walked references.")
+		return buf.String()
+	}
+
+	fmt.Fprintln(buf, "| Refgroup         | Commits   | Empty     | Empty merges | Empty share")
+	fmt.Fprintln(buf, "| ---------------- | --------- | --------- | ------------ | -----------")
+	for _, ge := range s.RefGroupEmptyCommits {
+		share := "-"
+		if ge.CommitCount != 0 {
+			share = fmt.Sprintf(
+				"%.1f%%",
+				100*float64(ge.EmptyCommitCount+ge.EmptyMergeCount)/float64(ge.CommitCount),
+			)
+		}
+		fmt.Fprintf(
+			buf, "| %-16s | %s | %s | %s   | %s\n",
+			ge.RefGroup,
+			formatGrowthValue(ge.CommitCount, &counts.Metric, ""),
+			formatGrowthValue(ge.EmptyCommitCount, &counts.Metric, ""),
+			formatGrowthValue(ge.EmptyMergeCount, &counts.Metric, ""),
+			share,
+		)
+	}
+	return buf.String()
+}
@@ -0,0 +1,108 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// ExactCheckout compares the expanded counts of the checkout with the
+// most blobs (`HistorySize.MaxExpandedBlobCountTree`) with exact
+// counts that are found by listing its files. The expanded counts
+// count a blob once for each path at which it appears, and they
+// saturate if the expansion was limited; the exact counts are the
+// number of distinct paths, which is what most people mean by "the
+// number of files in the checkout", and the number of distinct blobs
+// among them.
+type ExactCheckout struct {
+	// Tree is the tree whose checkout was listed.
+	Tree *Path `json:"tree"`
+
+	// ExpandedBlobCount and ExpandedBlobSize are the expanded
+	// counts of the tree. ExpansionLimited is set if they are
+	// saturated.
+	ExpandedBlobCount counts.Count32 `json:"expanded_blob_count"`
+	ExpandedBlobSize  counts.Count64 `json:"expanded_blob_size"`
+	ExpansionLimited  bool           `json:"expansion_limited,omitempty"`
+
+	// PathCount is the number of distinct file paths in the
+	// checkout, and PathSize is the total size of the files.
+	PathCount counts.Count64 `json:"path_count"`
+	PathSize  counts.Count64 `json:"path_size"`
+
+	// DistinctBlobCount and DistinctBlobSize count each of the
+	// blobs in the checkout only once, however many paths it
+	// appears at.
+	DistinctBlobCount counts.Count64 `json:"distinct_blob_count"`
+	DistinctBlobSize  counts.Count64 `json:"distinct_blob_size"`
+}
+
+// measureExactCheckout lists the files in the checkout of
+// `s.MaxExpandedBlobCountTree`, if any, and stores the exact counts
+// in `s.ExactCheckout`. Symlinks and submodules aren't counted, as
+// they aren't in `ExpandedBlobCount` either.
+func (s *HistorySize) measureExactCheckout(ctx context.Context, repo *git.Repository) error {
+	if s.MaxExpandedBlobCountTree == nil {
+		return nil
+	}
+	ts := s.maxExpandedBlobCountTreeSize
+	ec := ExactCheckout{
+		Tree:              s.MaxExpandedBlobCountTree,
+		ExpandedBlobCount: ts.ExpandedBlobCount,
+		ExpandedBlobSize:  ts.ExpandedBlobSize,
+		ExpansionLimited:  ts.ExpansionLimited,
+	}
+
+	seen := make(map[git.OID]struct{})
+	if err := repo.TreeFiles(
+		ctx, s.MaxExpandedBlobCountTree.OID,
+		func(f git.TreeFile) error {
+			ec.PathCount.Increment(1)
+			ec.PathSize.Increment(f.Size)
+			if _, ok := seen[f.OID]; !ok {
+				seen[f.OID] = struct{}{}
+				ec.DistinctBlobCount.Increment(1)
+				ec.DistinctBlobSize.Increment(f.Size)
+			}
+			return nil
+		},
+	); err != nil {
+		return fmt.Errorf("counting the files in the biggest checkout: %w", err)
+	}
+
+	s.ExactCheckout = &ec
+	return nil
+}
+
+// ExactCheckoutTableString compares the expanded and exact counts of
+// the biggest checkout, or returns the empty string if the exact
+// counts weren't measured.
+func (s *HistorySize) ExactCheckoutTableString() string {
+	ec := s.ExactCheckout
+	if ec == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nFiles in the checkout with the most blobs (%s):\n\n", ec.Tree.Path())
+	fmt.Fprintln(buf, "| Count                      | Files     | Size")
+	fmt.Fprintln(buf, "| -------------------------- | --------- | ---------")
+	row := func(name string, n counts.Count64, size counts.Count64) {
+		fmt.Fprintf(
+			buf, "| %-26s | %s | %s\n", name,
+			formatGrowthValue(n, &counts.Metric, ""),
+			formatGrowthValue(size, &counts.Binary, "B"),
+		)
+	}
+	if ec.ExpansionLimited {
+		fmt.Fprintf(buf, "| %-26s | %9s | %9s\n", "Expanded (limited)", "-", "-")
+	} else {
+		row("Expanded", counts.Count64(ec.ExpandedBlobCount), ec.ExpandedBlobSize)
+	}
+	row("Distinct paths", ec.PathCount, ec.PathSize)
+	row("Distinct blobs", ec.DistinctBlobCount, ec.DistinctBlobSize)
+	return buf.String()
+}
@@ -0,0 +1,189 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// maxForcePushedRefs is the maximum number of references listed in
+// the table output of `ForcePushes`.
+const maxForcePushedRefs = 10
+
+// ForcePushes describes the history that was replaced by force pushes,
+// rebases, and other non-fast-forward updates of branches, according
+// to their reflogs. The replaced tips are remembered by the reflogs,
+// so the objects that are only reachable from them are kept until the
+// reflog entries expire (see `gc.reflogExpireUnreachable`), even
+// though no branch leads to them anymore.
+type ForcePushes struct {
+	// RewriteCount is the number of reflog entries of branches and
+	// remote-tracking branches that replaced the reference's tip
+	// with a commit that doesn't descend from it, and RefCount is
+	// the number of references that have such entries.
+	RewriteCount counts.Count32 `json:"rewrite_count"`
+	RefCount     counts.Count32 `json:"ref_count"`
+
+	// ObjectCount and DiskSize are the number of objects that are
+	// reachable from the replaced tips but not from any reference,
+	// and the bytes that those objects occupy on disk.
+	ObjectCount counts.Count64 `json:"object_count"`
+	DiskSize    counts.Count64 `json:"disk_size"`
+
+	// Refs describes the rewritten references, those holding the
+	// most disk space first.
+	Refs []ForcePushedRef `json:"refs"`
+
+	// ExpireUnreachable is the repository's current setting of
+	// `gc.reflogExpireUnreachable`, which determines how long the
+	// replaced tips are remembered.
+	ExpireUnreachable string `json:"expire_unreachable"`
+}
+
+// ForcePushedRef describes the replaced history of one reference.
+// Objects that the replaced history of several references has in
+// common are counted for each of them.
+type ForcePushedRef struct {
+	Refname      string         `json:"refname"`
+	RewriteCount counts.Count32 `json:"rewrite_count"`
+	ObjectCount  counts.Count64 `json:"object_count"`
+	DiskSize     counts.Count64 `json:"disk_size"`
+
+	// LastRewrite is the time of the most recent rewrite. The
+	// history that it replaced is kept at least until its reflog
+	// entry expires.
+	LastRewrite time.Time `json:"last_rewrite"`
+}
+
+// isRewritableRef returns true iff `refname` is a branch or a
+// remote-tracking branch, whose non-fast-forward updates are
+// measured by `measureForcePushes()`.
+func isRewritableRef(refname string) bool {
+	return strings.HasPrefix(refname, "refs/heads/") ||
+		strings.HasPrefix(refname, "refs/remotes/")
+}
+
+// measureForcePushes finds the reflog entries of branches that
+// replaced the branch's tip with a commit that doesn't descend from
+// it, measures the objects that are only kept by the replaced tips,
+// and stores the results in `s.ForcePushes`.
+func (s *HistorySize) measureForcePushes(ctx context.Context, repo *git.Repository) error {
+	entries, err := repo.ReflogEntries(ctx)
+	if err != nil {
+		return err
+	}
+
+	expireUnreachable, err := repo.ConfigStringDefaultContext(
+		ctx, "gc.reflogExpireUnreachable", defaultReflogExpireUnreachable,
+	)
+	if err != nil {
+		return err
+	}
+
+	excluded, err := referenceTips(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	f := ForcePushes{
+		Refs:              []ForcePushedRef{},
+		ExpireUnreachable: expireUnreachable,
+	}
+	var all []git.OID
+	tips := make(map[string][]git.OID)
+	refs := make(map[string]*ForcePushedRef)
+	for _, entry := range entries {
+		if !isRewritableRef(entry.Refname) ||
+			entry.Old == git.NullOID || entry.New == git.NullOID || entry.Old == entry.New {
+			// Creations and deletions aren't rewrites.
+			continue
+		}
+		fastForward, err := repo.IsAncestor(ctx, entry.Old, entry.New)
+		if err != nil {
+			return err
+		}
+		if fastForward {
+			continue
+		}
+
+		f.RewriteCount.Increment(1)
+		r, ok := refs[entry.Refname]
+		if !ok {
+			r = &ForcePushedRef{Refname: s.anonymizer.Refname(entry.Refname)}
+			refs[entry.Refname] = r
+		}
+		r.RewriteCount.Increment(1)
+		if entry.Time.After(r.LastRewrite) {
+			r.LastRewrite = entry.Time.UTC()
+		}
+		tips[entry.Refname] = append(tips[entry.Refname], entry.Old)
+		all = append(all, entry.Old)
+	}
+	f.RefCount = counts.NewCount32(uint64(len(refs)))
+
+	f.ObjectCount, f.DiskSize, err = repo.CountReachable(ctx, all, excluded)
+	if err != nil {
+		return err
+	}
+	for refname, r := range refs {
+		r.ObjectCount, r.DiskSize, err = repo.CountReachable(ctx, tips[refname], excluded)
+		if err != nil {
+			return err
+		}
+		f.Refs = append(f.Refs, *r)
+	}
+	sort.Slice(f.Refs, func(i, j int) bool {
+		if f.Refs[i].DiskSize != f.Refs[j].DiskSize {
+			return f.Refs[i].DiskSize > f.Refs[j].DiskSize
+		}
+		return f.Refs[i].Refname < f.Refs[j].Refname
+	})
+
+	s.ForcePushes = &f
+	return nil
+}
+
+// ForcePushesTableString lists the references whose replaced history
+// holds the most space, or returns the empty string if it wasn't
+// measured.
+func (s *HistorySize) ForcePushesTableString() string {
+	f := s.ForcePushes
+	if f == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nHistory replaced by force pushes and rebases, according to the reflogs:\n\n")
+	if f.RewriteCount == 0 {
+		fmt.Fprintln(buf, "No branch was rewritten while the reflogs remember it.")
+		return buf.String()
+	}
+
+	fmt.Fprintln(buf, "| Reference                                | Rewrites  | Objects   | Size on disk | Last rewrite")
+	fmt.Fprintln(buf, "| ---------------------------------------- | --------- | --------- | ------------ | ------------")
+	for i, r := range f.Refs {
+		if i == maxForcePushedRefs {
+			break
+		}
+		fmt.Fprintf(
+			buf, "| %-40s | %9d | %9d |  %s   | %s\n",
+			r.Refname, r.RewriteCount, r.ObjectCount, formatSharedBytes(r.DiskSize),
+			r.LastRewrite.Format("2006-01-02"),
+		)
+	}
+	fmt.Fprintf(
+		buf,
+		"\n%d rewrites of %d references replaced history whose %d objects take %s,\n"+
+			"which are kept until the reflog entries expire ('gc.reflogExpireUnreachable'\n"+
+			"is currently '%s').\n",
+		f.RewriteCount, f.RefCount, f.ObjectCount,
+		strings.TrimSpace(formatSharedBytes(f.DiskSize)), f.ExpireUnreachable,
+	)
+	return buf.String()
+}
@@ -2,9 +2,11 @@ package sizes
 
 import (
 	"context"
-	"errors"
 	"fmt"
+	"io"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/github/git-sizer/counts"
 	"github.com/github/git-sizer/git"
@@ -23,11 +25,367 @@ type ReferenceRoot interface {
 	Groups() []RefGroupSymbol
 }
 
+// ScanOptions holds optional settings that affect how a repository
+// is scanned. The zero value selects the default behavior.
+type ScanOptions struct {
+	// Now is the time relative to which ages are computed. If it is
+	// zero, the current time is used.
+	Now time.Time
+
+	// StaleRefAge is the age beyond which the tip of a reference is
+	// considered stale. If it is zero, stale references are not
+	// counted.
+	StaleRefAge time.Duration
+
+	// MaxExpandedEntries, if nonzero, is the maximum number of
+	// entries (including duplicates) that a tree's checkout may have
+	// before its expansion is cut short and it is reported as a
+	// potential git bomb. Trees above the limit are only reported
+	// if their checkout also has many times more entries than the
+	// distinct objects that it is made of; a tree that is merely big
+	// is counted in full.
+	MaxExpandedEntries uint64
+
+	// OverflowPolicy determines what happens if the value of one of
+	// the selected statistics overflows its counter.
+	OverflowPolicy OverflowPolicy
+
+	// ListIgnoredRefs is the maximum number of references that were
+	// not walked to list by name in `HistorySize.IgnoredRefs`. If it
+	// is zero, they are not listed.
+	ListIgnoredRefs int
+
+	// StrictAttribution, if set, causes the maxima to be computed
+	// only over objects that are not reachable from any reference
+	// that is excluded from the scan, even if they are also
+	// reachable from an included reference or root.
+	StrictAttribution bool
+
+	// ObjectsSince, if nonzero, causes only the objects that were
+	// introduced by commits newer than it to be counted; i.e., those
+	// that are not reachable from any older commit. The counts and
+	// total sizes of unique objects, and the maxima, are restricted
+	// to those objects. See also `HistorySize.ObjectsSince`.
+	ObjectsSince time.Time
+
+	// Exclude lists objects (typically commits) whose reachable
+	// objects are neither walked nor counted. For example, when
+	// scanning a push, these are the existing references, so that
+	// only the objects that the push adds are read. The excluded
+	// trees and blobs that the walked trees refer to are counted in
+	// their checkouts as if they were empty, and the excluded parents
+	// of the walked commits as if they weren't there, so the
+	// statistics about checkouts and the history describe only what
+	// was walked.
+	Exclude []git.OID
+
+	// SharingMatrix, if nonzero, is the number of refgroups (those
+	// with the most walked references) for which to estimate the
+	// pairwise sharing of objects. See `HistorySize.RefGroupSharing`.
+	SharingMatrix int
+
+	// SharedTrees, if nonzero, is the number of the heaviest trees
+	// that appear at several paths in the checkouts of the walked
+	// roots, under different top-level directories or in different
+	// refgroups, to list. See `HistorySize.SharedTrees`.
+	SharedTrees int
+
+	// Compressibility, if nonzero, is the number of blobs (the
+	// largest ones) whose compressibility should be estimated. See
+	// `HistorySize.BlobCompressibility`.
+	Compressibility int
+
+	// FileLineage, if nonzero, is the number of the largest blobs
+	// whose files' histories should be followed, across renames, to
+	// find the total size of all of their versions. It requires full
+	// names. See `HistorySize.FileLineage`.
+	FileLineage int
+
+	// LineEndingDuplicates, if nonzero, is the number of the largest
+	// blobs among which to look for text files that only differ by
+	// their line endings. See `HistorySize.LineEndingDuplicates`.
+	LineEndingDuplicates int
+
+	// WriteCommitGraph, if set, causes a commit-graph to be written
+	// for the repository after the scan, if it doesn't have one yet,
+	// and a walk of the whole history to be timed before and after.
+	// See `HistorySize.CommitGraphWrite`.
+	WriteCommitGraph bool
+
+	// BloomFilters, if set, causes the histories of files (see
+	// `FileLineage`) to be followed using path-limited history walks,
+	// which Git can speed up using the changed-path Bloom filters in
+	// the commit-graph, rather than `git log --follow`, which can't.
+	BloomFilters bool
+
+	// CloneBandwidth, if nonzero, is the bandwidth (in megabits per
+	// second) of the network connection that is assumed when
+	// estimating how long a clone takes, and CloneLatency is its
+	// latency. See `HistorySize.CloneEstimate`.
+	CloneBandwidth float64
+	CloneLatency   time.Duration
+
+	// RefGroupTotals, if set, causes the number and total size of
+	// the unique objects reachable from each refgroup to be counted.
+	// See `HistorySize.RefGroupTotals`.
+	RefGroupTotals bool
+
+	// Baseline, if non-nil, holds the refgroup totals of an earlier
+	// scan, which are compared with the current ones (implying
+	// `RefGroupTotals`). See `HistorySize.Growth`.
+	Baseline *Baseline
+
+	// EmptyCommitGroups, if set, causes the empty commits that are
+	// reachable from each refgroup to be counted. See
+	// `HistorySize.RefGroupEmptyCommits`.
+	EmptyCommitGroups bool
+
+	// RefGroupActivity, if non-empty, is the length of the periods
+	// by which the commits of each refgroup should be counted. See
+	// `HistorySize.RefGroupActivity`.
+	RefGroupActivity ActivityPeriod
+
+	// RecentBlobs, if nonzero, causes the blobs that were introduced
+	// into each refgroup by commits newer than this long before the
+	// scan to be found, and the largest of them to be reported. See
+	// `HistorySize.RecentBlobs`.
+	RecentBlobs time.Duration
+
+	// RecentCommits, if nonzero, causes the objects that were
+	// introduced by that many of the latest first-parent commits on
+	// the branch that `HEAD` points at to be totaled by type. See
+	// `HistorySize.RecentActivity`.
+	RecentCommits int
+
+	// RootMaxima, if set, causes the largest blob and the biggest
+	// checkout reachable from each explicit root to be found
+	// separately. See `HistorySize.RootMaxima`.
+	RootMaxima bool
+
+	// SinceState, if non-nil, is a saved state of the object
+	// database. The objects that were added to the object database
+	// since then are summarized, whether or not they are reachable.
+	// See `HistorySize.ODBDelta`.
+	SinceState *ODBState
+
+	// AgeBuckets, if set, causes the unique objects to be grouped
+	// by the year of the earliest commit that contains them. See
+	// `HistorySize.AgeBuckets`.
+	AgeBuckets bool
+
+	// Packfiles, if set, causes the packfiles in the repository's
+	// object database to be listed. See `HistorySize.Packfiles`.
+	Packfiles bool
+
+	// TopCommitters, if nonzero, is the number of committers (those
+	// whose commits are biggest in total) to list. See
+	// `HistorySize.TopCommitters`.
+	TopCommitters int
+
+	// ShallowRemote, if non-nil, is a repository that has the
+	// history that is missing from `repo` if `repo` is a shallow
+	// clone (e.g., the repository that it was cloned from). It is
+	// used to count the commits and objects beyond the shallow
+	// boundary. See `HistorySize.ShallowBoundary`.
+	ShallowRemote *git.Repository
+
+	// Head, if non-nil, describes what `HEAD` referred to when it was
+	// added to the roots, so that it can be reported along with the
+	// scan scope. See `HistorySize.Head`.
+	Head *git.Head
+
+	// SizeBudget, if nonzero, is the percentage of the total size of
+	// the unique blobs for which the smallest set of paths that
+	// account for it should be listed. See `HistorySize.SizeBudget`.
+	SizeBudget int
+
+	// DirectorySizes, if set, causes the cumulative historical size
+	// of every path to be computed. See `HistorySize.DirectorySizes`.
+	DirectorySizes bool
+
+	// ImportArtifacts, if set, causes the history to be searched for
+	// the leftovers of an import from another version control
+	// system. See `HistorySize.ImportArtifacts`.
+	ImportArtifacts bool
+
+	// ObjectList, if non-nil, lists the objects to scan, one per
+	// line, in the format of the output of `git rev-list --objects`
+	// (an object name, optionally followed by a space and a path).
+	// It replaces the objects that are reachable from the walked
+	// roots, so it can come from custom traversal tooling. It must
+	// include every object that the listed commits, trees, and tags
+	// refer to (except for the parents of shallow commits), and list
+	// commits before their parents, as `git rev-list --objects
+	// --date-order` does. The roots are still needed for the
+	// references' statistics, and for any other phases of the scan
+	// that walk the history themselves (e.g., `RefGroupTotals`).
+	ObjectList io.Reader
+
+	// LFSCutoff, if nonzero, causes the savings of migrating the
+	// files whose blobs are larger than this many bytes to Git LFS to
+	// be estimated. See `HistorySize.LFSMigration`.
+	LFSCutoff counts.Count32
+
+	// NormalizedDuplicatesCutoff, if nonzero, causes the text blobs
+	// that are no larger than this many bytes to be normalized, to
+	// find the ones that only differ from others by whitespace or a
+	// byte order mark. See `HistorySize.NormalizedDuplicates`.
+	NormalizedDuplicatesCutoff counts.Count32
+
+	// HostingPresets, if non-empty, are the hosting limits for which
+	// the blobs that exceed them should be counted. See
+	// `HistorySize.HostingLimits`.
+	HostingPresets []HostingPreset
+
+	// CustomStats are the statistics defined in gitconfig (see
+	// `ReadCustomStats()`), which are computed along with the
+	// built-in ones. See `HistorySize.CustomStats`.
+	CustomStats []CustomStat
+
+	// ObjectDumper, if non-nil, is told about each object that the
+	// scan finds. It can't be combined with `Checkpoint`, because a
+	// resumed scan doesn't look at the objects again.
+	ObjectDumper ObjectDumper
+
+	// Live, if non-nil, is updated to give access to the statistics
+	// while the scan is running.
+	Live *LiveStats
+
+	// Anonymizer, if non-nil, is used to anonymize the paths and
+	// refnames that appear in the results.
+	Anonymizer *Anonymizer
+
+	// Checkpoint, if non-nil, is used to save the intermediate
+	// results of the scan after each phase, and to resume from the
+	// results of an earlier, interrupted scan.
+	Checkpoint *Checkpoint
+
+	// Stats is the set of statistics that should be computed. Data
+	// that aren't needed for any of these statistics are not
+	// collected. If it is nil, all statistics are computed.
+	Stats StatSet
+
+	// Reflogs, if set, causes the objects that are reachable only
+	// from reflogs to be measured, along with those that would
+	// become unreachable if the reflog entries older than
+	// `ReflogExpireAge` were expired.
+	Reflogs         bool
+	ReflogExpireAge time.Duration
+
+	// ForcePushes, if set, causes the history that was replaced by
+	// non-fast-forward updates of branches (e.g., force pushes and
+	// rebases), and that only the reflogs still remember, to be
+	// measured. See `HistorySize.ForcePushes`.
+	ForcePushes bool
+
+	// RefChurn, if non-zero, causes the updates to each reference
+	// in that period before the scan to be counted, going by the
+	// reflogs. See `HistorySize.RefChurn`.
+	RefChurn time.Duration
+
+	// RefGroupAttribution, if set, causes the refgroups from which
+	// each object cited by a statistic is reachable to be listed
+	// along with its name. It requires full names, and costs a walk
+	// of the history per cited commit.
+	RefGroupAttribution bool
+
+	// RedundantRefs, if set, causes the walked branches whose tips
+	// have the same tree as, or are strict ancestors of, another
+	// branch's tip to be listed. See `HistorySize.RedundantRefs`.
+	RedundantRefs bool
+
+	// ExactCheckout, if set, causes the files in the checkout with
+	// the most blobs to be listed, to count its distinct paths and
+	// blobs exactly. See `HistorySize.ExactCheckout`.
+	ExactCheckout bool
+
+	// CheckoutExtensions, if set, causes the files in the checkout
+	// with the largest total size to be broken down by extension.
+	// See `HistorySize.CheckoutExtensions`.
+	CheckoutExtensions bool
+
+	// IndexReflogNames, if set, causes the entries of the index
+	// and of the reflogs to be used to name objects that no
+	// reference leads to (e.g., ":README" or "HEAD@{3}"). This is
+	// useful if such objects are scanned, e.g., via an `ObjectList`
+	// generated by `git rev-list --objects --all --reflog
+	// --indexed-objects`.
+	IndexReflogNames bool
+
+	// Unreachable, if set, causes the objects that aren't reachable
+	// from any reference, reflog, or the index to be measured, and
+	// the effect of each of the `PruneExpire` settings of
+	// `gc.pruneExpire` (or of `DefaultPruneExpireSettings`, if it is
+	// empty) to be simulated.
+	Unreachable bool
+	PruneExpire []string
+
+	// Profile selects the reference values that are used to compute
+	// the levels of concern. The zero value is `ProfileDefault`.
+	Profile Profile
+
+	// ReferenceValues, if non-nil, overrides the reference values of
+	// individual statistics, taking precedence over `Profile`.
+	ReferenceValues ReferenceValues
+
+	// DocLinks, if non-nil, links the rows of some statistics in
+	// the output to documentation.
+	DocLinks DocLinks
+
+	// ConcernWeights, if non-nil, overrides how the levels of
+	// concern of individual statistics are computed from their
+	// values and reference values.
+	ConcernWeights ConcernWeights
+
+	// Thresholds, if non-nil, is the thresholds file that the
+	// profile and reference values came from. It is only used to
+	// identify the policy in the report's effective configuration.
+	Thresholds *Thresholds
+
+	// TopObjects is the number of objects that are listed (in
+	// `HistorySize.TopObjects`) for each statistic that cites an
+	// object, such as "maxBlobSize". If it is 0 or 1, only the object
+	// with the biggest value is cited, as usual.
+	TopObjects int
+
+	// AnomalyExamples is the number of objects that are listed (in
+	// `HistorySize.AnomalyExamples`) as examples for each statistic
+	// that counts anomalies, such as "windowsUnsafeEntryCount". The
+	// objects with the lowest OIDs are chosen, so that the examples
+	// are the same from one scan to the next. If it is 0, no examples
+	// are listed.
+	AnomalyExamples int
+
+	// CommitWorkers is the number of goroutines that are used to
+	// register commits. If it is more than 1, commits that don't
+	// depend on each other (because neither is an ancestor of the
+	// other) are processed concurrently, in batches of commits of
+	// the same generation. The results are the same either way.
+	CommitWorkers int
+
+	// NamesBudget limits the work that is done to find the names of
+	// the objects that the output cites. See `HistorySize.NamesBudget`.
+	NamesBudget NamesBudget
+
+	// NotesRefs are notes references (e.g., `DefaultNotesRef`) whose
+	// notes, if attached to objects that the report cites, are shown
+	// next to those objects, e.g., to acknowledge known-large
+	// objects. Notes refs that don't exist are ignored.
+	NotesRefs []string
+}
+
+// ObjectDumper is told about each of the objects that a scan finds,
+// e.g., to write an inventory of them. See `ScanOptions.ObjectDumper`.
+type ObjectDumper interface {
+	DumpObject(obj git.BatchHeader) error
+}
+
 // ScanRepositoryUsingGraph scans `repo`, using `rg` to decide which
 // references to scan and how to group them. `nameStyle` specifies
 // whether the output should include full names, hashes only, or
 // nothing in the footnotes. `progress` tells whether a progress meter
-// should be displayed while it works.
+// should be displayed while it works. `opts` holds any other
+// settings.
 //
 // It returns the size data for the repository.
 func ScanRepositoryUsingGraph(
@@ -36,119 +394,58 @@ func ScanRepositoryUsingGraph(
 	roots []Root,
 	nameStyle NameStyle,
 	progressMeter meter.Progress,
+	opts ScanOptions,
 ) (HistorySize, error) {
-	graph := NewGraph(nameStyle)
+	if opts.Stats.needs()&needPaths == 0 && opts.FileLineage == 0 {
+		// None of the selected statistics cite objects, so there is
+		// no point in naming any.
+		nameStyle = NameStyleNone
+	}
+
+	graph := NewGraph(nameStyle, opts)
+	opts.Live.setGraph(graph)
 
-	objIter, err := repo.NewObjectIter(ctx)
+	shallowCommits, err := repo.ShallowCommits(ctx)
 	if err != nil {
 		return HistorySize{}, err
 	}
-
-	errChan := make(chan error, 1)
-	// Feed the references that we want to walk into the stdin of the
-	// object iterator:
-	go func() {
-		defer objIter.Close()
-
-		errChan <- func() error {
-			for _, root := range roots {
-				if !root.Walk() {
-					continue
-				}
-
-				if err := objIter.AddRoot(root.OID()); err != nil {
-					return err
-				}
-			}
-			return nil
-		}()
-	}()
-
-	type ObjectHeader struct {
-		oid        git.OID
-		objectSize counts.Count32
+	for _, oid := range shallowCommits {
+		graph.shallowCommits[oid] = struct{}{}
 	}
 
-	type CommitHeader struct {
-		ObjectHeader
-		tree git.OID
+	if opts.StrictAttribution || !opts.ObjectsSince.IsZero() {
+		if err := graph.findAttributedObjects(
+			ctx, repo, roots, opts.StrictAttribution, opts.ObjectsSince, opts.Exclude,
+			progressMeter,
+		); err != nil {
+			return HistorySize{}, err
+		}
 	}
 
-	// We process the blobs right away, but record these other types
-	// of objects for later processing. The order of processing
-	// strongly affects performance, which prefers object locality and
-	// prefers having as few "dangling pointers" as possible. It also
-	// affects which of multiple equally-sized objects are chosen and
-	// which references the `PathResolver` chooses to refer to
-	// commits. Note that we process different types of objects in
-	// different orders:
-	//
-	// * Blobs are processed in roughly reverse-chronological order
-	//   This is relatively inconsequential because blobs can't point
-	//   at any other objects.
-	//
-	// * Trees are processed in roughly reverse-chronological order
-	//   (the order that they come out of `git rev-parse --date-order
-	//   --objects`). This is more efficient than the reverse because
-	//   the Git command outputs the whole tree corresponding to a
-	//   commit before moving onto the next commit. So when we process
-	//   them in this order, we have at most one "treeful" of trees
-	//   pending at any given moment (and usually much less); there
-	//   are no "dangling pointers" carried over from one commit to
-	//   the next. Plus, this allows us to use
-	//   `AdjustMaxIfNecessary()`, which leads to less churn in the
-	//   `PathResolver`.
-	//
-	// * Commits are processed in roughly chronological order when
-	//   computing sizes and looking for the "biggest" commits. This
-	//   is preferable because the opposite order would leave most
-	//   commits pending until we worked all the way to the start of
-	//   history. But by using `AdjustMaxIfPossible()`, we still
-	//   preferentially choose the newest commits.
-	//
-	//   But when feeding commits to the `PathResolver`, we process
-	//   the commits in reverse chronological order. This helps prefer
-	//   new commits when naming blobs and trees.
-	//
-	// * References are processed in alphabetical order. (It might be
-	//   a tiny improvement to pick the order more intentionally, to
-	//   favor certain references when naming commits that are pointed
-	//   to by multiple references, but it doesn't seem worth the
-	//   effort.)
-	var trees, tags []ObjectHeader
-	var commits []CommitHeader
-
-	progressMeter.Start("Processing blobs: %d")
-	for {
-		obj, ok, err := objIter.Next()
-		if err != nil {
+	if opts.Stats.Contains("maxTagOnlyBlobSize") || opts.Stats.Contains("maxTagOnlyTreeSize") {
+		if err := graph.findTagOnlyObjects(ctx, repo, roots, progressMeter); err != nil {
 			return HistorySize{}, err
 		}
-		if !ok {
-			break
-		}
-		switch obj.ObjectType {
-		case "blob":
-			progressMeter.Inc()
-			graph.RegisterBlob(obj.OID, obj.ObjectSize)
-		case "tree":
-			trees = append(trees, ObjectHeader{obj.OID, obj.ObjectSize})
-		case "commit":
-			commits = append(commits, CommitHeader{ObjectHeader{obj.OID, obj.ObjectSize}, git.NullOID})
-		case "tag":
-			tags = append(tags, ObjectHeader{obj.OID, obj.ObjectSize})
-		default:
-			return HistorySize{}, fmt.Errorf("unexpected object type: %s", obj.ObjectType)
-		}
 	}
-	progressMeter.Done()
 
-	err = <-errChan
+	trees, commits, tags, err := graph.enumerateObjects(
+		ctx, repo, roots, opts.Exclude, opts.ObjectList, opts.Checkpoint, progressMeter,
+	)
 	if err != nil {
 		return HistorySize{}, err
 	}
+	if len(opts.Exclude) != 0 {
+		graph.setWalked(trees, commits, tags)
+	}
+
+	// The headers have been saved, so if the scan has been canceled,
+	// this is a good place to stop:
+	if err := ctx.Err(); err != nil {
+		return HistorySize{}, err
+	}
 
-	objectIter, err := repo.NewBatchObjectIter(ctx)
+	errChan := make(chan error, 1)
+	objectIter, err := repo.NewBatchObjectIterAllowingMissing(ctx)
 	if err != nil {
 		return HistorySize{}, err
 	}
@@ -180,100 +477,197 @@ func ScanRepositoryUsingGraph(
 		}()
 	}()
 
-	progressMeter.Start("Processing trees: %d")
-	for range trees {
+	// Objects that were enumerated but can't be read (e.g., because
+	// they were pruned in the meantime) are recorded in
+	// `integrity`, and are registered as if they were empty, so
+	// that the objects that refer to them can still be processed.
+	var integrity ScanIntegrity
+
+	// readNext reads the next object, which should be the object of
+	// type `expected` named `oid`. If it can't be read, the returned
+	// record's `ObjectType` is "missing" or whatever type was found
+	// instead, and the discrepancy is recorded in `c`.
+	readNext := func(
+		oid git.OID, expected git.ObjectType, c *ScanIntegrityCounts,
+	) (git.ObjectRecord, error) {
 		obj, ok, err := objectIter.Next()
 		if err != nil {
-			return HistorySize{}, err
+			return git.ObjectRecord{}, err
 		}
 		if !ok {
-			return HistorySize{}, errors.New("fewer trees read than expected")
+			// The output ended early; treat the rest of the objects
+			// as missing:
+			obj = git.ObjectRecord{
+				BatchHeader: git.BatchHeader{OID: oid, ObjectType: "missing"},
+			}
 		}
-		if obj.ObjectType != "tree" {
-			return HistorySize{}, fmt.Errorf("expected tree; read %#v", obj.ObjectType)
+		if obj.OID != oid {
+			panic(fmt.Sprintf("%ss not read in same order as requested", expected))
 		}
-		progressMeter.Inc()
-		tree, err := git.ParseTree(obj.OID, obj.Data)
+		if obj.ObjectType != expected {
+			c.recordUnread(oid, obj.ObjectType)
+		} else {
+			c.recordRead()
+		}
+		return obj, nil
+	}
+
+	if graph.needs&needSymlinks != 0 {
+		graph.symlinkReader = newSymlinkReader(ctx, repo)
+		// In case the scan fails before the reader is closed below:
+		defer func() { _ = graph.symlinkReader.close() }()
+	}
+
+	progressMeter.Start("Processing trees: %d")
+	meter.SetTotal(progressMeter, int64(len(trees)))
+	for _, header := range trees {
+		obj, err := readNext(header.oid, "tree", &integrity.Trees)
 		if err != nil {
 			return HistorySize{}, err
 		}
+		progressMeter.Inc()
+		meter.AddBytes(progressMeter, int64(len(obj.Data)))
+		tree := &git.Tree{}
+		if obj.ObjectType == "tree" {
+			tree, err = git.ParseTree(obj.OID, obj.Data)
+			if err != nil {
+				return HistorySize{}, err
+			}
+		}
 		err = graph.RegisterTree(obj.OID, tree)
 		if err != nil {
 			return HistorySize{}, err
 		}
+		if err := graph.readSymlinkTargets(false); err != nil {
+			return HistorySize{}, err
+		}
+	}
+	// The trees that contain symlinks can't be finalized until the
+	// symlinks' targets have been read:
+	if err := graph.readSymlinkTargets(true); err != nil {
+		return HistorySize{}, err
+	}
+	if graph.symlinkReader != nil {
+		if err := graph.symlinkReader.close(); err != nil {
+			return HistorySize{}, err
+		}
 	}
 	progressMeter.Done()
+	if opts.ObjectList != nil {
+		if err := graph.checkTreesComplete(); err != nil {
+			return HistorySize{}, err
+		}
+	}
 
 	// Process the commits in (roughly) chronological order, to
 	// minimize the number of commits that are pending at any one
 	// time:
 	progressMeter.Start("Processing commits: %d")
-	for i := len(commits); i > 0; i-- {
-		obj, ok, err := objectIter.Next()
+	meter.SetTotal(progressMeter, int64(len(commits)))
+	var batch []pendingCommit
+	for i := len(commits); opts.CommitWorkers > 1 && i > 0; i-- {
+		obj, err := readNext(commits[i-1].oid, "commit", &integrity.Commits)
 		if err != nil {
 			return HistorySize{}, err
 		}
-		if !ok {
-			return HistorySize{}, errors.New("fewer commits read than expected")
+		meter.AddBytes(progressMeter, int64(len(obj.Data)))
+		batch = append(batch, pendingCommit{header: &commits[i-1], obj: obj})
+		if len(batch) == commitBatchSize || i == 1 {
+			if err := graph.registerCommitBatch(
+				batch, opts.CommitWorkers, opts.ObjectList != nil, progressMeter,
+			); err != nil {
+				return HistorySize{}, err
+			}
+			batch = batch[:0]
+		}
+	}
+	for i := len(commits); opts.CommitWorkers <= 1 && i > 0; i-- {
+		obj, err := readNext(commits[i-1].oid, "commit", &integrity.Commits)
+		if err != nil {
+			return HistorySize{}, err
 		}
+		meter.AddBytes(progressMeter, int64(len(obj.Data)))
 		if obj.ObjectType != "commit" {
-			return HistorySize{}, fmt.Errorf("expected commit; read %#v", obj.ObjectType)
+			progressMeter.Inc()
+			graph.registerUnreadCommit(obj.OID)
+			continue
 		}
 		commit, err := git.ParseCommit(obj.OID, obj.Data)
 		if err != nil {
 			return HistorySize{}, err
 		}
-		if obj.OID != commits[i-1].oid {
-			panic("commits not read in same order as requested")
-		}
 		commits[i-1].tree = commit.Tree
 		progressMeter.Inc()
+		if opts.ObjectList != nil {
+			if err := graph.checkCommitDependencies(obj.OID, commit); err != nil {
+				return HistorySize{}, err
+			}
+		}
 		graph.RegisterCommit(obj.OID, commit)
 	}
 	progressMeter.Done()
+	if len(commits) != 0 && commits[0].tree != git.NullOID && graph.needs&needTrees != 0 {
+		graph.recordCurrentRootTree(commits[0].oid, commits[0].tree)
+	}
 
 	// Tell PathResolver about the commits in (roughly) reverse
 	// chronological order, to favor new ones in the paths of trees:
 	if nameStyle != NameStyleNone {
 		progressMeter.Start("Matching commits to trees: %d")
+		meter.SetTotal(progressMeter, int64(len(commits)))
 		for _, commit := range commits {
 			progressMeter.Inc()
+			if commit.tree == git.NullOID {
+				// The commit couldn't be read.
+				continue
+			}
 			graph.pathResolver.RecordCommit(commit.oid, commit.tree)
 		}
 		progressMeter.Done()
 	}
 
 	progressMeter.Start("Processing annotated tags: %d")
-	for range tags {
-		obj, ok, err := objectIter.Next()
+	meter.SetTotal(progressMeter, int64(len(tags)))
+	for _, header := range tags {
+		obj, err := readNext(header.oid, "tag", &integrity.Tags)
 		if err != nil {
 			return HistorySize{}, err
 		}
-		if !ok {
-			return HistorySize{}, errors.New("fewer tags read than expected")
-		}
-		if obj.ObjectType != "tag" {
-			return HistorySize{}, fmt.Errorf("expected tag; read %#v", obj.ObjectType)
-		}
-		tag, err := git.ParseTag(obj.OID, obj.Data)
-		if err != nil {
-			return HistorySize{}, err
+		tag := &git.Tag{ReferentType: "missing"}
+		if obj.ObjectType == "tag" {
+			tag, err = git.ParseTag(obj.OID, obj.Data)
+			if err != nil {
+				return HistorySize{}, err
+			}
 		}
 		progressMeter.Inc()
 		graph.RegisterTag(obj.OID, tag)
 	}
 	progressMeter.Done()
+	if opts.ObjectList != nil {
+		if err := graph.checkTagsComplete(); err != nil {
+			return HistorySize{}, err
+		}
+	}
 
 	err = <-errChan
 	if err != nil {
 		return HistorySize{}, err
 	}
 
+	if err := graph.finishSymlinks(ctx, repo, roots, progressMeter); err != nil {
+		return HistorySize{}, err
+	}
+
 	progressMeter.Start("Processing references: %d")
+	meter.SetTotal(progressMeter, int64(len(roots)))
 	for _, root := range roots {
 		progressMeter.Inc()
 		if refRoot, ok := root.(ReferenceRoot); ok {
 			graph.RegisterReference(refRoot.Reference(), refRoot.Groups())
+			if !refRoot.Walk() {
+				graph.RegisterIgnoredReference(refRoot.Reference())
+			}
 		}
 
 		if root.Walk() {
@@ -282,34 +676,768 @@ func ScanRepositoryUsingGraph(
 	}
 	progressMeter.Done()
 
-	return graph.HistorySize(), nil
+	if opts.IndexReflogNames && nameStyle == NameStyleFull {
+		if err := graph.registerIndexReflogNames(ctx, repo); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	historySize := graph.HistorySize()
+	historySize.recordScanScope(roots)
+	if !integrity.ok() {
+		historySize.ScanIntegrity = &integrity
+	}
+	if len(roots) == 0 && opts.ObjectList == nil {
+		empty, err := repo.IsEmpty(ctx)
+		if err != nil {
+			return HistorySize{}, err
+		}
+		historySize.EmptyRepository = empty
+	}
+	historySize.recordHead(opts.Head)
+	if !opts.ObjectsSince.IsZero() {
+		since := opts.ObjectsSince
+		historySize.ObjectsSince = &since
+	}
+	if err := historySize.recordShallowBoundary(ctx, shallowCommits, opts.ShallowRemote); err != nil {
+		return HistorySize{}, err
+	}
+	if err := historySize.recordCaveats(ctx, repo, shallowCommits); err != nil {
+		return HistorySize{}, err
+	}
+	graph.recordMemoryUsage(&historySize)
+	graph.recordNamesBudget(&historySize, opts.NamesBudget)
+	historySize.recordGitCapabilities(ctx, repo)
+	if opts.TopCommitters > 0 {
+		historySize.TopCommitters = graph.topCommitters(opts.TopCommitters)
+	}
+	historySize.Lockfiles = graph.lockfileChurn()
+
+	if opts.RefGroupAttribution && nameStyle == NameStyleFull {
+		if err := historySize.attributeRefGroups(ctx, repo, roots); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.SharingMatrix > 0 {
+		if err := historySize.computeRefGroupSharing(
+			ctx, repo, roots, opts.SharingMatrix, progressMeter,
+		); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.SharedTrees > 0 {
+		if err := historySize.findSharedTrees(
+			ctx, repo, graph, roots, opts.SharedTrees, progressMeter,
+		); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.RefGroupTotals || opts.Baseline != nil {
+		if err := historySize.computeRefGroupTotals(
+			ctx, repo, roots, progressMeter,
+		); err != nil {
+			return HistorySize{}, err
+		}
+		if opts.Baseline != nil {
+			historySize.computeGrowth(opts.Baseline)
+		}
+	}
+
+	if opts.EmptyCommitGroups {
+		if err := historySize.computeRefGroupEmptyCommits(
+			ctx, repo, roots, graph.emptyCommits, progressMeter,
+		); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.RefGroupActivity != "" {
+		if err := historySize.computeRefGroupActivity(
+			ctx, repo, roots, opts.RefGroupActivity, progressMeter,
+		); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.RecentBlobs > 0 {
+		if err := historySize.findRecentBlobs(
+			ctx, repo, roots, opts.RecentBlobs, progressMeter,
+		); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.RecentCommits > 0 {
+		if err := historySize.measureRecentActivity(
+			ctx, repo, opts.RecentCommits, progressMeter,
+		); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.RootMaxima {
+		if err := historySize.findRootMaxima(
+			ctx, repo, graph, roots, progressMeter,
+		); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.AgeBuckets {
+		if err := historySize.computeAgeBuckets(
+			ctx, repo, roots, progressMeter,
+		); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.Stats.Contains("looseObjectCount") ||
+		opts.Stats.Contains("maxLooseObjectShardCount") ||
+		opts.Stats.Contains("oldestLooseObjectAge") {
+		if err := historySize.countLooseObjects(ctx, repo); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.SinceState != nil {
+		if err := historySize.computeODBDelta(
+			ctx, repo, opts.SinceState, progressMeter,
+		); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.Packfiles {
+		if err := historySize.collectPackfiles(ctx, repo); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.Reflogs {
+		if err := historySize.measureReflogOnly(ctx, repo, opts.ReflogExpireAge); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.ForcePushes {
+		if err := historySize.measureForcePushes(ctx, repo); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.RefChurn > 0 {
+		if err := historySize.measureRefChurn(ctx, repo, opts.RefChurn); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.RedundantRefs {
+		if err := historySize.findRedundantRefs(ctx, repo, roots); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.ExactCheckout {
+		if err := historySize.measureExactCheckout(ctx, repo); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.CheckoutExtensions {
+		if err := historySize.measureCheckoutExtensions(ctx, repo); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if graph.needs&needTrees != 0 &&
+		(opts.Stats.Contains("maxCheckoutWindowsUnsafeCount") ||
+			opts.Stats.Contains("windowsUnsafeEntryCount")) {
+		if err := historySize.measureWindowsUnsafeNames(ctx, repo, graph); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.Unreachable {
+		if err := historySize.measureUnreachable(ctx, repo, opts.PruneExpire); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.Compressibility > 0 {
+		if err := historySize.estimateCompressibility(
+			ctx, repo, graph.largestBlobs(opts.Compressibility), progressMeter,
+		); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.FileLineage > 0 {
+		if err := historySize.traceFileLineage(
+			ctx, repo, graph, graph.largestBlobs(opts.FileLineage), opts.BloomFilters,
+			progressMeter,
+		); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.LineEndingDuplicates > 0 {
+		if err := historySize.findLineEndingDuplicates(
+			ctx, repo, graph.largestBlobs(opts.LineEndingDuplicates), progressMeter,
+		); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.SizeBudget > 0 {
+		if err := historySize.computeSizeBudget(
+			ctx, repo, graph, roots, opts.SizeBudget, progressMeter,
+		); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.DirectorySizes {
+		if err := historySize.computeDirectorySizes(
+			ctx, repo, graph, roots, progressMeter,
+		); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.ImportArtifacts {
+		if err := historySize.findImportArtifacts(
+			ctx, repo, graph, roots, progressMeter,
+		); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.LFSCutoff > 0 {
+		if err := historySize.estimateLFSMigration(ctx, repo, graph); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.NormalizedDuplicatesCutoff > 0 {
+		if err := historySize.findNormalizedDuplicates(
+			ctx, repo, graph, progressMeter,
+		); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.Stats.Contains("maxCheckoutIndexSize") {
+		historySize.estimateIndex()
+	}
+
+	historySize.checkHardLimits()
+
+	if opts.Stats.Contains("packObjectsMemory") {
+		if err := historySize.estimatePackObjects(ctx, repo); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if !historySize.EmptyRepository {
+		if err := historySize.checkConfigDrift(ctx, repo); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if graph.hostingCutoff != 0 {
+		historySize.listHostingLimitBlobs(graph)
+	}
+
+	if graph.wideTreeEntries != 0 {
+		historySize.findWideTrees(graph)
+	}
+
+	if opts.TopObjects > 1 {
+		historySize.findTopObjects(graph)
+	}
+
+	if opts.AnomalyExamples > 0 {
+		historySize.findAnomalyExamples(graph)
+	}
+
+	if err := historySize.collectObjectNotes(ctx, repo, opts.NotesRefs); err != nil {
+		return HistorySize{}, err
+	}
+
+	if opts.CloneBandwidth > 0 && opts.Stats == nil && !historySize.EmptyRepository {
+		// The estimate depends on most of the other statistics, so
+		// it is only made if they are all computed.
+		historySize.estimateClone(opts.CloneBandwidth, opts.CloneLatency)
+	}
+
+	if opts.OverflowPolicy == OverflowError {
+		if err := historySize.checkOverflow(opts.MaxExpandedEntries != 0); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	if opts.WriteCommitGraph {
+		// This comes last, because it changes the repository:
+		if err := historySize.writeCommitGraph(ctx, repo, progressMeter); err != nil {
+			return HistorySize{}, err
+		}
+	}
+
+	return historySize, nil
+}
+
+type objectHeader struct {
+	oid        git.OID
+	objectSize counts.Count32
+}
+
+type commitHeader struct {
+	objectHeader
+	tree git.OID
+}
+
+// enumerateObjects lists the objects that are reachable from the
+// walked `roots` (or, if `list` is non-nil, the objects listed in
+// it; see `ScanOptions.ObjectList`). It registers the blobs with `g` right away, and
+// returns the headers of the other objects that are needed, for
+// later processing. If `cp` holds the headers saved by an
+// interrupted scan, they are used instead of listing the objects
+// again; otherwise, the headers are saved to `cp`.
+func (g *Graph) enumerateObjects(
+	ctx context.Context, repo *git.Repository, roots []Root, exclude []git.OID, list io.Reader,
+	cp *Checkpoint, progressMeter meter.Progress,
+) (trees []objectHeader, commits []commitHeader, tags []objectHeader, err error) {
+	if cp.hasHeaders() {
+		return g.replayHeaders(cp, progressMeter)
+	}
+
+	var objIter *git.ObjectIter
+	if list != nil {
+		objIter, err = repo.NewObjectIterFromList(ctx, list)
+	} else {
+		objIter, err = repo.NewObjectIter(ctx)
+	}
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	errChan := make(chan error, 1)
+	// Feed the references that we want to walk into the stdin of the
+	// object iterator:
+	go func() {
+		defer objIter.Close()
+
+		errChan <- func() error {
+			if list != nil {
+				return nil
+			}
+			for _, root := range roots {
+				if !root.Walk() {
+					continue
+				}
+
+				if err := objIter.AddRoot(root.OID()); err != nil {
+					return err
+				}
+			}
+			for _, oid := range exclude {
+				if err := objIter.ExcludeRoot(oid); err != nil {
+					return err
+				}
+			}
+			return nil
+		}()
+	}()
+
+	// We process the blobs right away, but record these other types
+	// of objects for later processing. The order of processing
+	// strongly affects performance, which prefers object locality and
+	// prefers having as few "dangling pointers" as possible. It also
+	// affects which of multiple equally-sized objects are chosen and
+	// which references the `PathResolver` chooses to refer to
+	// commits. Note that we process different types of objects in
+	// different orders:
+	//
+	// * Blobs are processed in roughly reverse-chronological order
+	//   This is relatively inconsequential because blobs can't point
+	//   at any other objects.
+	//
+	// * Trees are processed in roughly reverse-chronological order
+	//   (the order that they come out of `git rev-parse --date-order
+	//   --objects`). This is more efficient than the reverse because
+	//   the Git command outputs the whole tree corresponding to a
+	//   commit before moving onto the next commit. So when we process
+	//   them in this order, we have at most one "treeful" of trees
+	//   pending at any given moment (and usually much less); there
+	//   are no "dangling pointers" carried over from one commit to
+	//   the next. Plus, this allows us to use
+	//   `AdjustMaxIfNecessary()`, which leads to less churn in the
+	//   `PathResolver`.
+	//
+	// * Commits are processed in roughly chronological order when
+	//   computing sizes and looking for the "biggest" commits. This
+	//   is preferable because the opposite order would leave most
+	//   commits pending until we worked all the way to the start of
+	//   history. But by using `AdjustMaxIfPossible()`, we still
+	//   preferentially choose the newest commits.
+	//
+	//   But when feeding commits to the `PathResolver`, we process
+	//   the commits in reverse chronological order. This helps prefer
+	//   new commits when naming blobs and trees.
+	//
+	// * References are processed in alphabetical order. (It might be
+	//   a tiny improvement to pick the order more intentionally, to
+	//   favor certain references when naming commits that are pointed
+	//   to by multiple references, but it doesn't seem worth the
+	//   effort.)
+	var blobs []objectHeader
+	var diskSize counts.Count64
+
+	progressMeter.Start("Processing blobs: %d")
+	for {
+		obj, ok, err := objIter.Next()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if !ok {
+			break
+		}
+		diskSize.Increment(obj.DiskSize)
+		if g.objectDumper != nil {
+			if err := g.objectDumper.DumpObject(obj); err != nil {
+				return nil, nil, nil, fmt.Errorf("dumping object %s: %w", obj.OID, err)
+			}
+		}
+		switch obj.ObjectType {
+		case "blob":
+			progressMeter.Inc()
+			meter.AddBytes(progressMeter, int64(obj.ObjectSize))
+			g.RegisterBlob(obj.OID, obj.ObjectSize)
+			if cp != nil {
+				blobs = append(blobs, objectHeader{obj.OID, obj.ObjectSize})
+			}
+		case "tree":
+			if g.needs&needTrees != 0 {
+				trees = append(trees, objectHeader{obj.OID, obj.ObjectSize})
+			}
+		case "commit":
+			if g.needs&needCommits != 0 {
+				commits = append(commits, commitHeader{objectHeader{obj.OID, obj.ObjectSize}, git.NullOID})
+			}
+		case "tag":
+			if g.needs&needTags != 0 {
+				tags = append(tags, objectHeader{obj.OID, obj.ObjectSize})
+			}
+		default:
+			return nil, nil, nil, fmt.Errorf("unexpected object type: %s", obj.ObjectType)
+		}
+	}
+	progressMeter.Done()
+
+	if err := <-errChan; err != nil {
+		return nil, nil, nil, err
+	}
+	g.recordDiskSize(diskSize)
+
+	if err := cp.saveHeaders(blobs, trees, commits, tags, diskSize); err != nil {
+		return nil, nil, nil, err
+	}
+
+	return trees, commits, tags, nil
+
+}
+
+// recordDiskSize records the total on-disk size of the reachable
+// objects.
+func (g *Graph) recordDiskSize(diskSize counts.Count64) {
+	g.historyLock.Lock()
+	g.historySize.ReachableDiskSize = diskSize
+	g.historyLock.Unlock()
+}
+
+// Graph is an object graph that is being built up.
+type Graph struct {
+	blobLock  sync.Mutex
+	blobSizes map[git.OID]BlobSize
+
+	treeLock    sync.Mutex
+	treeRecords map[git.OID]*treeRecord
+	treeSizes   map[git.OID]TreeSize
+
+	// The largest number of entries that `treeRecords` has held at
+	// any one time. Protected by `treeLock`.
+	maxTreeRecords int
+
+	commitLock  sync.Mutex
+	commitSizes map[git.OID]CommitSize
+
+	// historyComponents is a union-find structure over the connected
+	// components of the commit graph. Each root commit starts a new
+	// component; `historyComponents[i]` is the index of the
+	// component that component `i` has been merged into, or `i`
+	// itself if it hasn't been merged. Protected by `commitLock`.
+	historyComponents []int
+
+	// The number of components in `historyComponents` that haven't
+	// been merged into another one. Protected by `commitLock`.
+	historyComponentCount int
+
+	tagLock    sync.Mutex
+	tagRecords map[git.OID]*tagRecord
+	tagSizes   map[git.OID]TagSize
+
+	// The largest number of entries that `tagRecords` has held at
+	// any one time. Protected by `tagLock`.
+	maxTagRecords int
+
+	// Statistics about the overall history size:
+	historyLock sync.Mutex
+	historySize HistorySize
+
+	// emptyCommits, if non-nil, records the empty commits (those
+	// whose tree is identical to that of one of their parents),
+	// mapping each one to true iff it is a merge. See
+	// `ScanOptions.EmptyCommitGroups`.
+	emptyCommits map[git.OID]bool
+
+	// rootTreeSeen is true once the root tree of a commit has been
+	// recorded, so that `historySize.MinRootTreeEntries` is valid.
+	// Protected by `historyLock`.
+	rootTreeSeen bool
+
+	pathResolver PathResolver
+
+	// See `ScanOptions.StaleRefAge`.
+	staleRefAge time.Duration
+
+	// needs describes which data have to be collected for the
+	// selected statistics.
+	needs scanNeeds
+
+	// mergeBubbles is true iff the merge bubbles have to be measured,
+	// in which case the parents of each commit are kept in
+	// `commitSizes`.
+	mergeBubbles bool
+
+	// trackLockfiles is true iff any of the lockfile statistics is
+	// selected, in which case lockfiles are recorded in `lockfiles`.
+	trackLockfiles bool
+
+	// See `ScanOptions.MaxExpandedEntries`.
+	maxExpandedEntries uint64
+
+	// See `ScanOptions.ListIgnoredRefs`.
+	listIgnoredRefs int
+
+	// largeBlobLimit is the number of the largest blobs that are
+	// kept in `largeBlobs`; the largest of
+	// `ScanOptions.Compressibility`, `ScanOptions.FileLineage`, and
+	// `ScanOptions.LineEndingDuplicates`.
+	largeBlobLimit int
+
+	// authors is the set of distinct author identities, and
+	// committers tallies the commits of each distinct committer
+	// identity. Protected by `historyLock`.
+	authors    map[string]struct{}
+	committers map[string]*CommitterTotal
+
+	// largeBlobs holds the largest blobs seen so far, as candidates
+	// for the compressibility estimate and the file histories.
+	// Protected by `historyLock`.
+	largeBlobs largeBlobHeap
+
+	// lfsBlobs holds the blobs that are larger than `lfsCutoff` bytes
+	// (see `ScanOptions.LFSCutoff`), and lfsBlobSize their total
+	// size. Protected by `historyLock`.
+	lfsCutoff   counts.Count32
+	lfsBlobs    []git.OID
+	lfsBlobSize counts.Count64
+
+	// normalizationBlobs holds the blobs that are no larger than
+	// `normalizationCutoff` bytes (see
+	// `ScanOptions.NormalizedDuplicatesCutoff`), with their sizes.
+	// Protected by `historyLock`.
+	normalizationCutoff counts.Count32
+	normalizationBlobs  []objectHeader
+
+	// hostingCutoff is the smallest blob size above which a blob
+	// exceeds one of the limits of `ScanOptions.HostingPresets`, and
+	// hostingBlobs holds the largest blobs that exceed it. Protected
+	// by `historyLock`.
+	hostingCutoff counts.Count32
+	hostingBlobs  largeBlobHeap
+
+	// metadataBlobs holds the kinds of metadata file (see
+	// `metadataFileKind()`) as which each blob has already been
+	// counted. Protected by `historyLock`.
+	metadataBlobs map[git.OID]metadataKind
+
+	// lockfiles holds the churn of each lockfile name that has been
+	// seen, and lockfileBlobs the blobs that have been counted
+	// toward the lockfile totals. Protected by `historyLock`.
+	lockfiles     map[string]*LockfileChurn
+	lockfileBlobs map[git.OID]struct{}
+
+	// customStats accumulates the custom statistics that match
+	// paths. Protected by `historyLock`.
+	customStats []customStatCounter
+
+	// wideTrees holds the widest trees seen so far that have more
+	// than `wideTreeEntries` entries, most of them with
+	// machine-generated names. Protected by `historyLock`.
+	wideTreeEntries counts.Count32
+	wideTrees       wideTreeHeap
+
+	// topObjectCollectors keeps track of the `topObjects` objects
+	// with the biggest values of each statistic that cites an
+	// object, keyed by the statistic's symbol (see
+	// `ScanOptions.TopObjects`). Protected by `historyLock`.
+	topObjects          int
+	topObjectCollectors map[string]*topObjectCollector
+
+	// anomalyExampleCollectors keeps track of the `anomalyExamples`
+	// examples with the lowest OIDs for each statistic that counts
+	// anomalies, keyed by the statistic's symbol (see
+	// `ScanOptions.AnomalyExamples`). Protected by `historyLock`.
+	anomalyExamples          int
+	anomalyExampleCollectors map[string]*anomalyExampleCollector
+
+	// shallowCommits is the set of commits at the boundary of a
+	// shallow clone, whose parents are missing.
+	shallowCommits map[git.OID]struct{}
+
+	// walked, if not nil, is the set of trees, commits, and tags
+	// that are walked when `ScanOptions.Exclude` is set. The others
+	// are at the boundary of the scan (see `atBoundary()`). It isn't
+	// modified once the objects are being registered.
+	walked map[git.OID]struct{}
+
+	// tagOnlyObjects, if non-nil, is the set of blobs and trees that
+	// are reachable from tags but not from any branch. See
+	// `findTagOnlyObjects()`.
+	tagOnlyObjects map[git.OID]struct{}
+
+	// attributedObjects, if non-nil, is the set of objects that
+	// count toward the maxima (and, if `restrictTotals` is set,
+	// toward the totals). See `findAttributedObjects()`.
+	attributedObjects map[git.OID]struct{}
+
+	// restrictTotals is set if `ScanOptions.ObjectsSince` or
+	// `ScanOptions.Exclude` is, which restrict the totals, too.
+	restrictTotals bool
+
+	// See `ScanOptions.ObjectDumper`.
+	objectDumper ObjectDumper
+
+	// The symlinks seen while processing trees, whose targets are
+	// read by `symlinkReader` while the trees are being processed.
+	// Only collected if `needs&needSymlinks != 0`. The targets of the
+	// first `symlinkRead` entries of `symlinkBlobs` have been read,
+	// and are held in `symlinkTargets`. `symlinkWaiters` holds the
+	// functions to call when the targets of the others arrive.
+	symlinkReader  *symlinkReader
+	symlinkLock    sync.Mutex
+	symlinkBlobs   []symlinkBlob
+	symlinkRead    int
+	symlinkTargets map[git.OID]string
+	symlinkWaiters map[git.OID][]func()
+	symlinkTrees   []symlinkTree
 }
 
-// Graph is an object graph that is being built up.
-type Graph struct {
-	blobLock  sync.Mutex
-	blobSizes map[git.OID]BlobSize
+// symlinkBlob is a blob that is used as a symlink, plus its requested
+// path if the symlink might escape the checkout.
+type symlinkBlob struct {
+	oid  git.OID
+	path *Path
+}
+
+// symlinkEntry is a symlink entry in a tree.
+type symlinkEntry struct {
+	name string
+	oid  git.OID
+}
+
+// symlinkTree is a tree containing symlinks.
+type symlinkTree struct {
+	oid     git.OID
+	entries []symlinkEntry
+}
+
+// NewGraph creates and returns a new `*Graph` instance.
+func NewGraph(nameStyle NameStyle, opts ScanOptions) *Graph {
+	now := opts.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+
+	var ignoredRefs *IgnoredRefs
+	if opts.ListIgnoredRefs > 0 {
+		ignoredRefs = &IgnoredRefs{Refnames: []string{}}
+	}
+
+	needs := opts.Stats.needs()
+	if needs&needPaths != 0 && nameStyle == NameStyleFull {
+		// Blobs and trees are named by way of the trees and commits
+		// that contain them.
+		needs |= needTrees | needCommits
+	}
+	if opts.TopCommitters > 0 {
+		needs |= needCommits
+	}
+	if opts.FileLineage > 0 {
+		// The histories are followed from the commits in which the
+		// largest blobs were found, and the sizes of the blobs'
+		// other versions are looked up in `blobSizes`.
+		needs |= needTrees | needCommits
+	}
+	if opts.SizeBudget > 0 || opts.DirectorySizes || opts.ImportArtifacts {
+		// The sizes of the blobs are looked up in `blobSizes`.
+		needs |= needTrees
+	}
+
+	// Trees are examined for machine-generated names if they would
+	// raise the level of concern of "maxTreeEntries" (whose default
+	// reference value is 1000):
+	var wideTreeEntries counts.Count32
+	if needs&needTrees != 0 && opts.Stats.Contains("maxTreeEntries") {
+		wideTreeEntries = counts.NewCount32(uint64(
+			opts.ReferenceValues.scale(opts.Profile, "maxTreeEntries", 1000) *
+				opts.ConcernWeights["maxTreeEntries"].ratioAt(1),
+		))
+	}
 
-	treeLock    sync.Mutex
-	treeRecords map[git.OID]*treeRecord
-	treeSizes   map[git.OID]TreeSize
+	// Measuring merge bubbles walks part of the history for each
+	// merge, so it is only done if their statistics are selected
+	// explicitly:
+	mergeBubbles := needs&needCommits != 0 &&
+		(opts.Stats.Contains("maxMergeBubble") || opts.Stats.Contains("averageMergeBubble"))
 
-	commitLock  sync.Mutex
-	commitSizes map[git.OID]CommitSize
+	trackLockfiles := false
+	if needs&needTrees != 0 {
+		for _, symbol := range lockfileStats {
+			trackLockfiles = trackLockfiles || opts.Stats.Contains(symbol)
+		}
+	}
 
-	tagLock    sync.Mutex
-	tagRecords map[git.OID]*tagRecord
-	tagSizes   map[git.OID]TagSize
+	largeBlobLimit := opts.Compressibility
+	if opts.FileLineage > largeBlobLimit {
+		largeBlobLimit = opts.FileLineage
+	}
+	if opts.LineEndingDuplicates > largeBlobLimit {
+		largeBlobLimit = opts.LineEndingDuplicates
+	}
 
-	// Statistics about the overall history size:
-	historyLock sync.Mutex
-	historySize HistorySize
+	var emptyCommits map[git.OID]bool
+	if opts.EmptyCommitGroups {
+		emptyCommits = make(map[git.OID]bool)
+	}
 
-	pathResolver PathResolver
-}
+	customStatValues, customStats := newCustomStatValues(opts.CustomStats)
+	hostingLimits, hostingCutoff := newHostingLimitViolations(opts.HostingPresets)
 
-// NewGraph creates and returns a new `*Graph` instance.
-func NewGraph(nameStyle NameStyle) *Graph {
 	return &Graph{
 		blobSizes: make(map[git.OID]BlobSize),
 
@@ -321,21 +1449,141 @@ func NewGraph(nameStyle NameStyle) *Graph {
 		tagRecords: make(map[git.OID]*tagRecord),
 		tagSizes:   make(map[git.OID]TagSize),
 
+		authors:    make(map[string]struct{}),
+		committers: make(map[string]*CommitterTotal),
+
 		historySize: HistorySize{
-			ReferenceGroups: make(map[RefGroupSymbol]*counts.Count32),
+			stats:              opts.Stats,
+			profile:            opts.Profile,
+			referenceValues:    opts.ReferenceValues,
+			thresholds:         opts.Thresholds,
+			docLinks:           opts.DocLinks,
+			concernWeights:     opts.ConcernWeights,
+			anonymizer:         opts.Anonymizer,
+			ScanTime:           now,
+			ReferenceGroups:    make(map[RefGroupSymbol]*counts.Count32),
+			ReferenceGroupTips: make(map[RefGroupSymbol]*RefGroupTipSize),
+			IgnoredRefs:        ignoredRefs,
+			CustomStats:        customStatValues,
+			HostingLimits:      hostingLimits,
 		},
 
-		pathResolver: NewPathResolver(nameStyle),
+		pathResolver: newAnonymizingPathResolver(
+			NewPathResolverWithBudget(nameStyle, opts.NamesBudget), opts.Anonymizer,
+		),
+
+		staleRefAge:         opts.StaleRefAge,
+		needs:               needs,
+		mergeBubbles:        mergeBubbles,
+		trackLockfiles:      trackLockfiles,
+		maxExpandedEntries:  opts.MaxExpandedEntries,
+		listIgnoredRefs:     opts.ListIgnoredRefs,
+		largeBlobLimit:      largeBlobLimit,
+		lfsCutoff:           opts.LFSCutoff,
+		normalizationCutoff: opts.NormalizedDuplicatesCutoff,
+		hostingCutoff:       hostingCutoff,
+		customStats:         customStats,
+		wideTreeEntries:     wideTreeEntries,
+		restrictTotals:      !opts.ObjectsSince.IsZero(),
+		objectDumper:        opts.ObjectDumper,
+		emptyCommits:        emptyCommits,
+
+		topObjects:          opts.TopObjects,
+		topObjectCollectors: make(map[string]*topObjectCollector),
+
+		anomalyExamples:          opts.AnomalyExamples,
+		anomalyExampleCollectors: make(map[string]*anomalyExampleCollector),
+
+		symlinkTargets: make(map[git.OID]string),
+		symlinkWaiters: make(map[git.OID][]func()),
+		shallowCommits: make(map[git.OID]struct{}),
 	}
 }
 
 // RegisterReference records the specified reference in `g`.
 func (g *Graph) RegisterReference(ref git.Reference, groups []RefGroupSymbol) {
+	var checkout *TreeSize
+	if len(groups) != 0 {
+		if size, ok := g.checkoutSize(ref.OID); ok {
+			checkout = &size
+		}
+	}
+
+	peeledType, _ := g.peeledType(ref)
+
 	g.historyLock.Lock()
-	g.historySize.recordReference(g, ref)
+	g.historySize.recordReference(g, ref, peeledType)
+	g.historySize.recordCustomReference(ref)
 	for _, group := range groups {
-		g.historySize.recordReferenceGroup(g, group)
+		g.historySize.recordReferenceGroup(g, group, ref, checkout)
+	}
+	g.historyLock.Unlock()
+}
+
+// peeledType returns the type of the object that `ref` leads to
+// after peeling any annotated tags. The second return value is false
+// if that isn't known (e.g., because the tags weren't walked).
+func (g *Graph) peeledType(ref git.Reference) (git.ObjectType, bool) {
+	oid, objectType := ref.OID, ref.ObjectType
+
+	g.tagLock.Lock()
+	defer g.tagLock.Unlock()
+	for objectType == "tag" {
+		tagSize, ok := g.tagSizes[oid]
+		if !ok {
+			return "", false
+		}
+		oid, objectType = tagSize.referent, tagSize.referentType
+	}
+	return objectType, true
+}
+
+// checkoutSize returns the size of the tree that would be checked out
+// for `oid`, peeling any tags and commits. The second return value is
+// false if `oid` doesn't lead to a tree or if the sizes of the
+// objects involved weren't collected (e.g., because they weren't
+// walked).
+func (g *Graph) checkoutSize(oid git.OID) (TreeSize, bool) {
+	if g.needs&needTrees == 0 {
+		return TreeSize{}, false
+	}
+
+	oid = g.checkoutTree(oid)
+
+	g.treeLock.Lock()
+	defer g.treeLock.Unlock()
+	size, ok := g.treeSizes[oid]
+	return size, ok
+}
+
+// checkoutTree returns the tree that would be checked out for the
+// object `oid`, by peeling any tags and commits. If `oid` isn't a
+// known tag or commit, it is returned unchanged.
+func (g *Graph) checkoutTree(oid git.OID) git.OID {
+	for {
+		g.tagLock.Lock()
+		tagSize, ok := g.tagSizes[oid]
+		g.tagLock.Unlock()
+		if !ok {
+			break
+		}
+		oid = tagSize.referent
 	}
+
+	g.commitLock.Lock()
+	commitSize, ok := g.commitSizes[oid]
+	g.commitLock.Unlock()
+	if ok {
+		oid = commitSize.tree
+	}
+	return oid
+}
+
+// RegisterIgnoredReference records that the specified reference was
+// not walked.
+func (g *Graph) RegisterIgnoredReference(ref git.Reference) {
+	g.historyLock.Lock()
+	g.historySize.recordIgnoredReference(g, ref)
 	g.historyLock.Unlock()
 }
 
@@ -344,6 +1592,42 @@ func (g *Graph) RegisterName(name string, oid git.OID) {
 	g.pathResolver.RecordName(name, oid)
 }
 
+// registerIndexReflogNames tells the `PathResolver` about the
+// entries of `repo`'s index and reflogs, so that objects that are
+// reachable only from them can be named.
+func (g *Graph) registerIndexReflogNames(ctx context.Context, repo *git.Repository) error {
+	indexEntries, err := repo.IndexEntries(ctx)
+	if err != nil {
+		return err
+	}
+	for _, entry := range indexEntries {
+		g.pathResolver.RecordIndexEntry(entry)
+	}
+
+	reflogEntries, err := repo.ReflogEntries(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The entries of each reflog are listed oldest first, but Git
+	// numbers them starting with the newest:
+	entryCounts := make(map[string]int)
+	for _, entry := range reflogEntries {
+		entryCounts[entry.Refname]++
+	}
+	seen := make(map[string]int)
+	for _, entry := range reflogEntries {
+		seen[entry.Refname]++
+		if entry.New == git.NullOID {
+			// The reference was deleted.
+			continue
+		}
+		n := entryCounts[entry.Refname] - seen[entry.Refname]
+		g.pathResolver.RecordReflogEntry(entry.Refname, n, entry.New)
+	}
+	return nil
+}
+
 // HistorySize returns the size data that have been collected.
 func (g *Graph) HistorySize() HistorySize {
 	g.treeLock.Lock()
@@ -367,13 +1651,20 @@ func (g *Graph) RegisterBlob(oid git.OID, objectSize counts.Count32) {
 	size := BlobSize{Size: objectSize}
 	// There are no listeners. Since this is a blob, we know all that
 	// we need to know about it. So skip the record and just fill in
-	// the size.
-	g.blobLock.Lock()
-	g.blobSizes[oid] = size
-	g.blobLock.Unlock()
+	// the size. The size is only needed later if trees are being
+	// processed.
+	if g.needs&needTrees != 0 {
+		g.blobLock.Lock()
+		g.blobSizes[oid] = size
+		g.blobLock.Unlock()
+	}
 
 	g.historyLock.Lock()
 	g.historySize.recordBlob(g, oid, size)
+	g.recordLargeBlob(oid, objectSize)
+	g.recordLFSCandidate(oid, objectSize)
+	g.recordNormalizationCandidate(oid, objectSize)
+	g.recordHostingLimitCandidate(oid, objectSize)
 	g.historyLock.Unlock()
 }
 
@@ -396,6 +1687,10 @@ func (g *Graph) GetBlobSize(oid git.OID) BlobSize {
 }
 
 func (g *Graph) RequireTreeSize(oid git.OID, listener func(TreeSize)) (TreeSize, bool) {
+	if g.atBoundary(oid) {
+		return boundaryTreeSize, true
+	}
+
 	g.treeLock.Lock()
 
 	size, ok := g.treeSizes[oid]
@@ -409,6 +1704,9 @@ func (g *Graph) RequireTreeSize(oid git.OID, listener func(TreeSize)) (TreeSize,
 	if !ok {
 		record = newTreeRecord(oid)
 		g.treeRecords[oid] = record
+		if len(g.treeRecords) > g.maxTreeRecords {
+			g.maxTreeRecords = len(g.treeRecords)
+		}
 	}
 	record.addListener(listener)
 
@@ -418,6 +1716,10 @@ func (g *Graph) RequireTreeSize(oid git.OID, listener func(TreeSize)) (TreeSize,
 }
 
 func (g *Graph) GetTreeSize(oid git.OID) TreeSize {
+	if g.atBoundary(oid) {
+		return boundaryTreeSize
+	}
+
 	g.treeLock.Lock()
 
 	size, ok := g.treeSizes[oid]
@@ -441,6 +1743,9 @@ func (g *Graph) RegisterTree(oid git.OID, tree *git.Tree) error {
 	if !ok {
 		record = newTreeRecord(oid)
 		g.treeRecords[oid] = record
+		if len(g.treeRecords) > g.maxTreeRecords {
+			g.maxTreeRecords = len(g.treeRecords)
+		}
 	}
 
 	g.treeLock.Unlock()
@@ -451,14 +1756,17 @@ func (g *Graph) RegisterTree(oid git.OID, tree *git.Tree) error {
 
 func (g *Graph) finalizeTreeSize(
 	oid git.OID, size TreeSize, objectSize counts.Count32, treeEntries counts.Count32,
+	duplicateSubtrees counts.Count32,
 ) {
+	size.entryCount = treeEntries
+
 	g.treeLock.Lock()
 	g.treeSizes[oid] = size
 	delete(g.treeRecords, oid)
 	g.treeLock.Unlock()
 
 	g.historyLock.Lock()
-	g.historySize.recordTree(g, oid, size, objectSize, treeEntries)
+	g.historySize.recordTree(g, oid, size, objectSize, treeEntries, duplicateSubtrees)
 	g.historyLock.Unlock()
 }
 
@@ -476,6 +1784,11 @@ type treeRecord struct {
 	// pending != -1.
 	entryCount counts.Count32
 
+	// The largest number of entries in this tree that refer to the
+	// same subtree, or zero if no subtree is referred to more than
+	// once. Initialized iff pending != -1.
+	duplicateSubtrees counts.Count32
+
 	// The size of the items we know so far:
 	size TreeSize
 
@@ -488,12 +1801,16 @@ type treeRecord struct {
 
 	// The listeners waiting to learn our size.
 	listeners []func(TreeSize)
+
+	// The symlink entries in this tree, if their targets are needed.
+	// Their targets count among the dependencies in `pending`.
+	symlinks []symlinkEntry
 }
 
 func newTreeRecord(oid git.OID) *treeRecord {
 	return &treeRecord{
 		oid:     oid,
-		size:    TreeSize{ExpandedTreeCount: 1},
+		size:    TreeSize{ExpandedTreeCount: 1, uniqueEntryBound: 1},
 		pending: -1,
 	}
 }
@@ -506,6 +1823,17 @@ func (r *treeRecord) initialize(g *Graph, oid git.OID, tree *git.Tree) error {
 	r.objectSize = tree.Size()
 	r.pending = 0
 
+	// The number of entries referring to each subtree. This is only
+	// allocated once a second subtree entry is seen, since most
+	// trees have few subdirectories.
+	var firstSubtree git.OID
+	var subtreeCounts map[git.OID]counts.Count32
+
+	var collisions normalizationCollisions
+	hasCollision := false
+
+	var nested nestedRepository
+
 	iter := tree.Iter()
 	for {
 		entry, ok, err := iter.NextEntry()
@@ -517,9 +1845,44 @@ func (r *treeRecord) initialize(g *Graph, oid git.OID, tree *git.Tree) error {
 		}
 		name := entry.Name
 
+		if isUnusualUnicodeName(name) {
+			g.historyLock.Lock()
+			g.historySize.recordUnusualUnicodeEntry(
+				g, oid, name, entry.OID, entryObjectType(entry.Filemode),
+			)
+			g.historyLock.Unlock()
+		}
+		if len(name) >= minRecordedEntryNameLength {
+			g.historyLock.Lock()
+			g.historySize.recordLongEntryName(
+				g, oid, name, entry.OID, entryObjectType(entry.Filemode),
+			)
+			g.historyLock.Unlock()
+		}
+		if collisions.add(name) {
+			hasCollision = true
+		}
+		nested.add(name, entry.Filemode)
+
 		switch {
 		case entry.Filemode&0o170000 == 0o40000:
 			// Tree
+			duplicate := false
+			switch {
+			case firstSubtree == git.NullOID:
+				firstSubtree = entry.OID
+			case subtreeCounts == nil:
+				subtreeCounts = map[git.OID]counts.Count32{firstSubtree: 1}
+				fallthrough
+			default:
+				n := subtreeCounts[entry.OID] + 1
+				subtreeCounts[entry.OID] = n
+				if n > 1 {
+					duplicate = true
+					r.duplicateSubtrees.AdjustMaxIfNecessary(n)
+				}
+			}
+
 			listener := func(size TreeSize) {
 				// This listener is called when the tree pointed to by
 				// `entry` has been fully processed.
@@ -528,7 +1891,7 @@ func (r *treeRecord) initialize(g *Graph, oid git.OID, tree *git.Tree) error {
 
 				g.pathResolver.RecordTreeEntry(oid, name, entry.OID)
 
-				r.size.addDescendent(name, size)
+				r.addSubtree(g, name, size, duplicate)
 				r.pending--
 				// This might inform *our* listeners that we are now
 				// fully processed:
@@ -536,7 +1899,7 @@ func (r *treeRecord) initialize(g *Graph, oid git.OID, tree *git.Tree) error {
 			}
 			treeSize, ok := g.RequireTreeSize(entry.OID, listener)
 			if ok {
-				r.size.addDescendent(name, treeSize)
+				r.addSubtree(g, name, treeSize, duplicate)
 			} else {
 				r.pending++
 			}
@@ -549,29 +1912,191 @@ func (r *treeRecord) initialize(g *Graph, oid git.OID, tree *git.Tree) error {
 
 		case entry.Filemode&0o170000 == 0o120000:
 			// Symlink
-			g.pathResolver.RecordTreeEntry(oid, name, entry.OID)
+			if g.symlinkReader != nil {
+				// The tree entry can't be recorded until the
+				// symlink's target is known, because the target
+				// determines whether the symlink's path is needed:
+				known, err := g.requireSymlinkTarget(entry.OID, func() {
+					// This is called when the symlink's target has
+					// been read (and its path requested, if it is
+					// needed).
+					r.lock.Lock()
+					defer r.lock.Unlock()
+
+					g.pathResolver.RecordTreeEntry(oid, name, entry.OID)
+
+					r.pending--
+					r.maybeFinalize(g)
+				})
+				if err != nil {
+					return err
+				}
+				if known {
+					g.pathResolver.RecordTreeEntry(oid, name, entry.OID)
+				} else {
+					r.pending++
+				}
+				r.symlinks = append(r.symlinks, symlinkEntry{name, entry.OID})
+			} else {
+				g.pathResolver.RecordTreeEntry(oid, name, entry.OID)
+			}
 
 			r.size.addLink(name)
 			r.entryCount.Increment(1)
 
 		default:
 			// Blob
+			blobSize, ok := g.blobSizes[entry.OID]
+			if !ok && g.walked != nil {
+				// The blob is at the boundary of the scan.
+				g.pathResolver.RecordTreeEntry(oid, name, entry.OID)
+				r.size.addBlob(name, BlobSize{}, entry.Filemode == 0o100755)
+				r.entryCount.Increment(1)
+				continue
+			}
+			if !ok {
+				return fmt.Errorf(
+					"tree %s refers to blob %s, which wasn't among the objects scanned",
+					oid, entry.OID,
+				)
+			}
+			executable := entry.Filemode == 0o100755
+			if executable {
+				// This has to happen before the tree entry is
+				// recorded, so that the blob's path can be resolved:
+				g.historyLock.Lock()
+				g.historySize.recordExecutableBlob(g, oid, name, entry.OID, blobSize)
+				g.historyLock.Unlock()
+			}
+			if kind := metadataFileKind(name); kind != 0 {
+				// This, too, has to happen before the tree entry is
+				// recorded:
+				g.historyLock.Lock()
+				g.recordMetadataFile(oid, name, entry.OID, blobSize, kind)
+				g.historyLock.Unlock()
+			}
+			if g.trackLockfiles && isLockfile(name) {
+				// So does this:
+				g.historyLock.Lock()
+				g.recordLockfile(oid, name, entry.OID, blobSize)
+				g.historyLock.Unlock()
+			}
+			if len(g.customStats) != 0 {
+				g.recordCustomEntry(oid, name, entry.OID, blobSize)
+			}
+
 			g.pathResolver.RecordTreeEntry(oid, name, entry.OID)
 
-			blobSize := g.GetBlobSize(entry.OID)
-			r.size.addBlob(name, blobSize)
+			r.size.addBlob(name, blobSize, executable)
 			r.entryCount.Increment(1)
 		}
 	}
 
+	if hasCollision {
+		g.historyLock.Lock()
+		g.historySize.recordNormalizationCollisionTree(g, oid)
+		g.historyLock.Unlock()
+	}
+
+	if nested.found() {
+		g.historyLock.Lock()
+		g.historySize.recordNestedRepositoryTree(g, oid)
+		g.historyLock.Unlock()
+	}
+
+	if err := g.recordWideTree(oid, tree, r.entryCount); err != nil {
+		return err
+	}
+
 	r.maybeFinalize(g)
 
 	return nil
 }
 
+// entryObjectType returns the type of the object that a tree entry
+// with the specified `filemode` refers to.
+func entryObjectType(filemode uint) string {
+	switch filemode & 0o170000 {
+	case 0o40000:
+		return "tree"
+	case 0o160000:
+		return "commit"
+	default:
+		return "blob"
+	}
+}
+
+// addSubtree adds `size`, the size of the subtree called `name`, to
+// `r`. `duplicate` tells whether an earlier entry of the tree refers
+// to the same subtree. If adding the subtree would make the checkout
+// of `r` a potential git bomb, its expansion is cut short before the
+// subtree's counts are added. Must be called while `r` is locked.
+func (r *treeRecord) addSubtree(g *Graph, name string, size TreeSize, duplicate bool) {
+	if !duplicate {
+		r.size.uniqueEntryBound.Increment(size.uniqueEntryBound)
+	}
+	if !r.size.ExpansionLimited && !size.ExpansionLimited &&
+		g.isPotentialGitBomb(
+			r.size.expandedEntryCount()+size.expandedEntryCount(), r.size.uniqueEntryBound,
+		) {
+		r.limitExpansion(g)
+	}
+	r.size.addDescendent(name, size)
+}
+
+// limitExpansion cuts the expansion of `r` short and records it as a
+// potential git bomb. Must be called while `r` is locked.
+func (r *treeRecord) limitExpansion(g *Graph) {
+	r.size.limitExpansion()
+	g.historyLock.Lock()
+	g.historySize.recordPotentialGitBomb(g, r.oid)
+	g.historyLock.Unlock()
+}
+
+// gitBombExpansionRatio is how many times bigger than the number of
+// distinct objects that it could contain the checkout of a tree has
+// to be for the tree to be considered a potential git bomb. A big
+// tree that merely has a few copies of some directories stays below
+// it.
+const gitBombExpansionRatio = 10
+
+// isPotentialGitBomb returns true iff a tree whose checkout has
+// `expanded` entries, at most `unique` of which are distinct, is a
+// potential git bomb: its checkout has more entries than
+// `ScanOptions.MaxExpandedEntries`, and many times more than both
+// `unique` and the number of distinct blobs and trees that have been
+// scanned so far (which include all of the tree's descendants).
+func (g *Graph) isPotentialGitBomb(expanded uint64, unique counts.Count64) bool {
+	if g.maxExpandedEntries == 0 || expanded <= g.maxExpandedEntries {
+		return false
+	}
+
+	g.treeLock.Lock()
+	scanned := uint64(len(g.blobSizes)) + uint64(len(g.treeSizes))
+	g.treeLock.Unlock()
+
+	bound := uint64(unique)
+	if scanned+1 < bound {
+		// The tree itself hasn't been scanned yet:
+		bound = scanned + 1
+	}
+	return expanded/gitBombExpansionRatio > bound
+}
+
 func (r *treeRecord) maybeFinalize(g *Graph) {
 	if r.pending == 0 {
-		g.finalizeTreeSize(r.oid, r.size, r.objectSize, r.entryCount)
+		if len(r.symlinks) != 0 {
+			// This has to happen before the tree is finalized, so
+			// that the tree's path can be resolved if it is cited:
+			g.registerSymlinkTree(r.oid, r.symlinks)
+		}
+		if !r.size.ExpansionLimited &&
+			g.isPotentialGitBomb(r.size.expandedEntryCount(), r.size.uniqueEntryBound) {
+			r.limitExpansion(g)
+		}
+		g.finalizeTreeSize(
+			r.oid, r.size, r.objectSize, r.entryCount, r.duplicateSubtrees,
+		)
 		for _, listener := range r.listeners {
 			listener(r.size)
 		}
@@ -584,6 +2109,179 @@ func (r *treeRecord) addListener(listener func(TreeSize)) {
 	r.listeners = append(r.listeners, listener)
 }
 
+// requireSymlinkTarget records that the blob `oid` is used as a
+// symlink, and requests its target if it hasn't been requested
+// already. If the target is already known, it returns true.
+// Otherwise, it returns false, and `listener` is called once the
+// target has been read.
+func (g *Graph) requireSymlinkTarget(oid git.OID, listener func()) (bool, error) {
+	g.symlinkLock.Lock()
+	defer g.symlinkLock.Unlock()
+
+	if _, ok := g.symlinkTargets[oid]; ok {
+		return true, nil
+	}
+	if waiters, ok := g.symlinkWaiters[oid]; ok {
+		g.symlinkWaiters[oid] = append(waiters, listener)
+		return false, nil
+	}
+
+	if err := g.symlinkReader.request(oid); err != nil {
+		return false, fmt.Errorf("requesting symlink '%s': %w", oid, err)
+	}
+	g.symlinkWaiters[oid] = []func(){listener}
+	g.symlinkBlobs = append(g.symlinkBlobs, symlinkBlob{oid: oid})
+	return false, nil
+}
+
+// readSymlinkTargets records the symlink targets that have been read
+// since the last call, and informs the trees that are waiting for
+// them. If `wait` is true, it doesn't return until all of the
+// requested targets have been read.
+func (g *Graph) readSymlinkTargets(wait bool) error {
+	if g.symlinkReader == nil {
+		return nil
+	}
+
+	for {
+		objs, err := g.symlinkReader.take(wait)
+		if err != nil {
+			return err
+		}
+		if len(objs) == 0 {
+			return nil
+		}
+
+		for _, obj := range objs {
+			target := string(obj.Data)
+
+			g.symlinkLock.Lock()
+			blob := &g.symlinkBlobs[g.symlinkRead]
+			if obj.OID != blob.oid {
+				panic("symlinks not read in same order as requested")
+			}
+			g.symlinkRead++
+			g.symlinkTargets[obj.OID] = target
+			if symlinkClimb(target) > 0 &&
+				g.historySize.stats.Contains("escapingSymlinkCount") {
+				// Whether the symlink escapes the checkout is only
+				// known after the scan, so its path is needed just in
+				// case:
+				blob.path = g.pathResolver.RequestPath(obj.OID, "blob")
+			}
+			waiters := g.symlinkWaiters[obj.OID]
+			delete(g.symlinkWaiters, obj.OID)
+			g.symlinkLock.Unlock()
+
+			g.historyLock.Lock()
+			g.historySize.recordSymlink(g, obj.OID, target)
+			g.historyLock.Unlock()
+
+			for _, waiter := range waiters {
+				waiter()
+			}
+		}
+	}
+}
+
+// registerSymlinkTree records that the tree `oid` contains the
+// specified symlink `entries`, whose targets have all been read, and
+// whether they form a cycle.
+func (g *Graph) registerSymlinkTree(oid git.OID, entries []symlinkEntry) {
+	g.symlinkLock.Lock()
+	g.symlinkTrees = append(g.symlinkTrees, symlinkTree{
+		oid:     oid,
+		entries: entries,
+	})
+	hasCycle := hasSymlinkCycle(entries, g.symlinkTargets)
+	g.symlinkLock.Unlock()
+
+	if hasCycle {
+		g.historyLock.Lock()
+		g.historySize.recordSymlinkCycleTree(g, oid)
+		g.historyLock.Unlock()
+	}
+}
+
+// finishSymlinks records the symlinks whose targets escape the
+// checkout, which are sought among the objects reachable from the
+// walked `roots`, and forgets the paths of the symlinks that might
+// have, but don't. It must be called after all of the targets have
+// been read.
+func (g *Graph) finishSymlinks(
+	ctx context.Context, repo *git.Repository, roots []Root, progressMeter meter.Progress,
+) error {
+	if len(g.symlinkBlobs) == 0 {
+		return nil
+	}
+
+	if g.historySize.stats.Contains("escapingSymlinkCount") {
+		if err := g.findEscapingSymlinks(
+			ctx, repo, roots, g.symlinkTargets, progressMeter,
+		); err != nil {
+			return err
+		}
+	}
+
+	for _, blob := range g.symlinkBlobs {
+		if blob.path != nil && blob.path != g.historySize.EscapingSymlink {
+			g.pathResolver.ForgetPath(blob.path)
+		}
+	}
+
+	return nil
+}
+
+// hasSymlinkCycle returns true iff some of the symlink `entries` in a
+// single tree, whose targets are given by `targets`, refer to each
+// other in a cycle (e.g., `a -> a`, or `a -> b` and `b -> ./a`).
+// Only targets that refer to entries in the same tree are
+// considered.
+func hasSymlinkCycle(entries []symlinkEntry, targets map[git.OID]string) bool {
+	links := make(map[string]string, len(entries))
+	for _, entry := range entries {
+		if target, ok := siblingName(targets[entry.oid]); ok {
+			links[entry.name] = target
+		}
+	}
+
+	// The state of each name: 1 while it is on the chain that is
+	// being followed, and 2 once it is known not to lead to a cycle.
+	state := make(map[string]int, len(links))
+	for name := range links {
+		var chain []string
+		for cur := name; state[cur] == 0; {
+			next, ok := links[cur]
+			if !ok {
+				break
+			}
+			state[cur] = 1
+			chain = append(chain, cur)
+			cur = next
+			if state[cur] == 1 {
+				return true
+			}
+		}
+		for _, cur := range chain {
+			state[cur] = 2
+		}
+	}
+	return false
+}
+
+// siblingName returns the name of the entry in the same directory
+// that the symlink `target` refers to, if it refers to one.
+func siblingName(target string) (string, bool) {
+	for strings.HasPrefix(target, "./") {
+		target = strings.TrimLeft(target[2:], "/")
+	}
+	target = strings.TrimRight(target, "/")
+	if target == "" || target == "." || target == ".." || strings.Contains(target, "/") {
+		return "", false
+	}
+	return target, true
+}
+
 func (g *Graph) GetCommitSize(oid git.OID) CommitSize {
 	g.commitLock.Lock()
 
@@ -598,40 +2296,191 @@ func (g *Graph) GetCommitSize(oid git.OID) CommitSize {
 
 // Record that the specified `oid` is the specified `commit`.
 func (g *Graph) RegisterCommit(oid git.OID, commit *git.Commit) {
+	reg := g.computeCommitSize(oid, commit)
+	componentCount := g.storeCommitSize(oid, &reg)
+	g.recordCommitStats(oid, commit, &reg, componentCount)
+}
+
+// commitRegistration holds what is computed about a commit while it
+// is being registered.
+type commitRegistration struct {
+	size CommitSize
+
+	// parentComponents are the history components of the commit's
+	// parents.
+	parentComponents []int
+
+	// rootEntries is the number of entries in the commit's tree.
+	rootEntries counts.Count32
+
+	// sameTree is true iff the tree is identical to that of one of
+	// the parents.
+	sameTree bool
+}
+
+// computeCommitSize computes the size of `commit` from those of its
+// tree and parents, which must already have been registered. It
+// doesn't modify `g`, so it can be called for several commits
+// concurrently.
+func (g *Graph) computeCommitSize(oid git.OID, commit *git.Commit) commitRegistration {
 	g.commitLock.Lock()
 	if _, ok := g.commitSizes[oid]; ok {
 		panic(fmt.Sprintf("commit %s registered twice!", oid))
 	}
 	g.commitLock.Unlock()
 
-	// The number of direct parents of this commit.
-	parentCount := counts.NewCount32(uint64(len(commit.Parents)))
-
-	// The size of the items we know so far:
-	size := CommitSize{}
+	var reg commitRegistration
 
 	// The tree:
-	treeSize := g.GetTreeSize(commit.Tree)
-	size.addTree(treeSize)
+	if g.needs&needTrees != 0 {
+		treeSize := g.GetTreeSize(commit.Tree)
+		reg.size.addTree(treeSize)
+		reg.rootEntries = treeSize.entryCount
+	}
 
-	for _, parent := range commit.Parents {
+	parents := commit.Parents
+	if _, ok := g.shallowCommits[oid]; ok {
+		// This commit is at the boundary of a shallow clone, so its
+		// parents are missing. Treat it like a root commit (except
+		// for counting its parents).
+		parents = nil
+	}
+	parents = g.walkedParents(parents)
+
+	for _, parent := range parents {
 		parentSize := g.GetCommitSize(parent)
-		size.addParent(parentSize)
+		reg.size.addParent(parentSize)
+		reg.parentComponents = append(reg.parentComponents, parentSize.component)
+		if parentSize.tree == commit.Tree {
+			reg.sameTree = true
+		}
+	}
+	reg.size.tree = commit.Tree
+	if g.mergeBubbles {
+		reg.size.parents = parents
 	}
 
 	// Add 1 for this commit itself:
+	reg.size.MaxAncestorDepth.Increment(1)
+
+	return reg
+}
+
+// storeCommitSize stores the size computed in `reg`, so that the
+// commit's children can be registered, and returns the number of
+// disconnected histories so far.
+func (g *Graph) storeCommitSize(oid git.OID, reg *commitRegistration) counts.Count32 {
+	g.commitLock.Lock()
+	defer g.commitLock.Unlock()
+
+	reg.size.component = g.joinHistoryComponents(reg.parentComponents)
+	g.commitSizes[oid] = reg.size
+	return counts.NewCount32(uint64(g.historyComponentCount))
+}
+
+// recordCommitStats records the commit `oid` in the statistics.
+// `componentCount` is the number of disconnected histories.
+func (g *Graph) recordCommitStats(
+	oid git.OID, commit *git.Commit, reg *commitRegistration, componentCount counts.Count32,
+) {
+	// The number of direct parents of this commit.
+	parentCount := counts.NewCount32(uint64(len(commit.Parents)))
+
+	var bubble counts.Count32
+	isMerge := len(reg.size.parents) > 1
+	if isMerge {
+		bubble = g.mergeBubbleSize(reg.size.parents)
+	}
+
+	g.historyLock.Lock()
+	g.historySize.recordCommit(g, oid, reg.size, commit.Size, parentCount)
+	g.historySize.recordCommitHeaders(g, oid, commit.HeaderSize, commit.NonstandardHeaderCount)
+	g.historySize.recordCommitEncoding(g, oid, commit)
+	if isMerge {
+		g.historySize.recordMergeBubble(g, oid, bubble)
+	}
+	if g.needs&needTrees != 0 {
+		g.historySize.recordRootTree(g, oid, reg.rootEntries)
+	}
+	g.recordCommitIdentities(commit)
+	g.historySize.DisconnectedHistoryCount = componentCount
+	if reg.sameTree {
+		g.historySize.recordEmptyCommit(parentCount)
+		if g.emptyCommits != nil {
+			g.emptyCommits[oid] = parentCount > 1
+		}
+	}
+	g.historyLock.Unlock()
+}
+
+// registerUnreadCommit records the commit `oid`, which couldn't be
+// read, as a root commit with no tree, so that its children can still
+// be registered. It doesn't count towards any of the statistics.
+func (g *Graph) registerUnreadCommit(oid git.OID) {
+	size := CommitSize{}
 	size.MaxAncestorDepth.Increment(1)
 
 	g.commitLock.Lock()
+	size.component = g.joinHistoryComponents(nil)
 	g.commitSizes[oid] = size
+	componentCount := counts.NewCount32(uint64(g.historyComponentCount))
 	g.commitLock.Unlock()
 
 	g.historyLock.Lock()
-	g.historySize.recordCommit(g, oid, size, commit.Size, parentCount)
+	g.historySize.DisconnectedHistoryCount = componentCount
+	g.historyLock.Unlock()
+}
+
+// recordCurrentRootTree records the size of the root tree, `tree`, of
+// the newest commit, `oid`. It must be called before the commits are
+// passed to the `PathResolver`.
+func (g *Graph) recordCurrentRootTree(oid, tree git.OID) {
+	entryCount := g.GetTreeSize(tree).entryCount
+
+	g.historyLock.Lock()
+	g.historySize.recordCurrentRootTree(g, oid, entryCount)
 	g.historyLock.Unlock()
 }
 
+// joinHistoryComponents merges the history components with the
+// specified indexes and returns the index of the result. If
+// `components` is empty (i.e., for a root commit), it starts a new
+// component. `g.commitLock` must be held.
+func (g *Graph) joinHistoryComponents(components []int) int {
+	if len(components) == 0 {
+		c := len(g.historyComponents)
+		g.historyComponents = append(g.historyComponents, c)
+		g.historyComponentCount++
+		return c
+	}
+
+	c := g.findHistoryComponent(components[0])
+	for _, other := range components[1:] {
+		other = g.findHistoryComponent(other)
+		if other != c {
+			g.historyComponents[other] = c
+			g.historyComponentCount--
+		}
+	}
+	return c
+}
+
+// findHistoryComponent returns the index of the component that
+// component `i` has been merged into. `g.commitLock` must be held.
+func (g *Graph) findHistoryComponent(i int) int {
+	for g.historyComponents[i] != i {
+		// Path halving:
+		g.historyComponents[i] = g.historyComponents[g.historyComponents[i]]
+		i = g.historyComponents[i]
+	}
+	return i
+}
+
 func (g *Graph) RequireTagSize(oid git.OID, listener func(TagSize)) (TagSize, bool) {
+	if g.atBoundary(oid) {
+		return TagSize{}, true
+	}
+
 	g.tagLock.Lock()
 
 	size, ok := g.tagSizes[oid]
@@ -645,6 +2494,9 @@ func (g *Graph) RequireTagSize(oid git.OID, listener func(TagSize)) (TagSize, bo
 	if !ok {
 		record = newTagRecord(oid)
 		g.tagRecords[oid] = record
+		if len(g.tagRecords) > g.maxTagRecords {
+			g.maxTagRecords = len(g.tagRecords)
+		}
 	}
 	record.addListener(listener)
 
@@ -666,22 +2518,33 @@ func (g *Graph) RegisterTag(oid git.OID, tag *git.Tag) {
 	if !ok {
 		record = newTagRecord(oid)
 		g.tagRecords[oid] = record
+		if len(g.tagRecords) > g.maxTagRecords {
+			g.maxTagRecords = len(g.tagRecords)
+		}
 	}
 
 	g.tagLock.Unlock()
 
+	if tag.NonstandardHeaderCount != 0 {
+		g.historyLock.Lock()
+		g.historySize.recordNonstandardHeaders(g, oid, "tag", tag.NonstandardHeaderCount)
+		g.historyLock.Unlock()
+	}
+
 	// Let the record take care of the rest:
 	record.initialize(g, oid, tag)
 }
 
-func (g *Graph) finalizeTagSize(oid git.OID, size TagSize, objectSize counts.Count32) {
+func (g *Graph) finalizeTagSize(
+	oid git.OID, size TagSize, objectSize counts.Count32, signed bool,
+) {
 	g.tagLock.Lock()
 	g.tagSizes[oid] = size
 	delete(g.tagRecords, oid)
 	g.tagLock.Unlock()
 
 	g.historyLock.Lock()
-	g.historySize.recordTag(g, oid, size, objectSize)
+	g.historySize.recordTag(g, oid, size, objectSize, signed)
 	g.historyLock.Unlock()
 }
 
@@ -694,6 +2557,9 @@ type tagRecord struct {
 	// The size of this commit object in bytes.
 	objectSize counts.Count32
 
+	// Whether the tag is signed.
+	signed bool
+
 	// The size of the items we know so far:
 	size TagSize
 
@@ -717,8 +2583,11 @@ func (r *tagRecord) initialize(g *Graph, oid git.OID, tag *git.Tag) {
 	defer r.lock.Unlock()
 
 	r.objectSize = tag.Size
+	r.signed = tag.Signed
 	r.pending = 0
 	r.size.TagDepth = 1
+	r.size.referent = tag.Referent
+	r.size.referentType = tag.ReferentType
 
 	// The only thing that a tag cares about its ancestors is how many
 	// tags have to be traversed to get to a real object. So we only
@@ -750,7 +2619,7 @@ func (r *tagRecord) initialize(g *Graph, oid git.OID, tag *git.Tag) {
 
 func (r *tagRecord) maybeFinalize(g *Graph) {
 	if r.pending == 0 {
-		g.finalizeTagSize(r.oid, r.size, r.objectSize)
+		g.finalizeTagSize(r.oid, r.size, r.objectSize, r.signed)
 		for _, listener := range r.listeners {
 			listener(r.size)
 		}
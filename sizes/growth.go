@@ -0,0 +1,289 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// baselineVersion is the version of the baseline file format.
+const baselineVersion = 1
+
+// RefGroupTotal holds the number and total size of the unique
+// objects that are reachable from the walked references in a
+// refgroup.
+type RefGroupTotal struct {
+	ObjectCount counts.Count64 `json:"object_count"`
+	ObjectSize  counts.Count64 `json:"object_size"`
+}
+
+// Baseline records the totals of each refgroup at the time of a
+// scan, so that a later scan can report how much each refgroup has
+// grown since then. See `HistorySize.Growth`.
+type Baseline struct {
+	Version   int                              `json:"version"`
+	ScanTime  time.Time                        `json:"scan_time"`
+	RefGroups map[RefGroupSymbol]RefGroupTotal `json:"refgroups"`
+}
+
+// ReadBaseline reads a baseline that was written by
+// `Baseline.Write()`.
+func ReadBaseline(path string) (*Baseline, error) {
+	buf, err := readDecompressing(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading baseline: %w", err)
+	}
+
+	var b Baseline
+	if err := json.Unmarshal(buf, &b); err != nil {
+		return nil, fmt.Errorf("reading baseline %s: %w", path, err)
+	}
+	if b.Version != baselineVersion {
+		return nil, fmt.Errorf(
+			"baseline %s has unsupported version %d", path, b.Version,
+		)
+	}
+	return &b, nil
+}
+
+// Baseline returns a baseline holding the refgroup totals of `s`, or
+// nil if they weren't computed (see `ScanOptions.RefGroupTotals`).
+func (s *HistorySize) Baseline() *Baseline {
+	if s.RefGroupTotals == nil {
+		return nil
+	}
+	return &Baseline{
+		Version:   baselineVersion,
+		ScanTime:  s.ScanTime,
+		RefGroups: s.RefGroupTotals,
+	}
+}
+
+// Write writes `b` to a file at `path` as JSON, compressed with gzip
+// if `path` ends in ".gz".
+func (b *Baseline) Write(path string) error {
+	buf, err := json.MarshalIndent(b, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := WriteCompressible(path, append(buf, '\n')); err != nil {
+		return fmt.Errorf("writing baseline: %w", err)
+	}
+	return nil
+}
+
+// RefGroupGrowth describes how much the unique objects reachable
+// from a refgroup have grown since a baseline. The growth can be
+// negative, if references were deleted or rewritten.
+type RefGroupGrowth struct {
+	RefGroup RefGroupSymbol `json:"refgroup"`
+
+	// The totals now.
+	ObjectCount counts.Count64 `json:"object_count"`
+	ObjectSize  counts.Count64 `json:"object_size"`
+
+	// The change in the totals since the baseline.
+	ObjectCountGrowth int64 `json:"object_count_growth"`
+	ObjectSizeGrowth  int64 `json:"object_size_growth"`
+}
+
+// Growth holds the growth of each refgroup since a baseline.
+type Growth struct {
+	// Since is the time of the baseline's scan.
+	Since time.Time `json:"since"`
+
+	// RefGroups lists the refgroups that exist now or in the
+	// baseline, ordered by decreasing growth in object size.
+	RefGroups []RefGroupGrowth `json:"refgroups"`
+}
+
+// computeRefGroupTotals counts the unique objects reachable from the
+// walked references in each refgroup, and stores the results in
+// `s.RefGroupTotals`. This takes one walk of the history per
+// refgroup.
+func (s *HistorySize) computeRefGroupTotals(
+	ctx context.Context, repo *git.Repository, roots []Root,
+	progressMeter meter.Progress,
+) error {
+	groupRoots := make(map[RefGroupSymbol][]git.OID)
+	for _, root := range roots {
+		refRoot, ok := root.(ReferenceRoot)
+		if !ok || !root.Walk() {
+			continue
+		}
+		for _, group := range refRoot.Groups() {
+			if group == "" {
+				// Skip the top-level group, whose totals are the
+				// unique object counts and sizes.
+				continue
+			}
+			groupRoots[group] = append(groupRoots[group], root.OID())
+		}
+	}
+
+	groups := make([]RefGroupSymbol, 0, len(groupRoots))
+	for group := range groupRoots {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i] < groups[j] })
+
+	s.RefGroupTotals = make(map[RefGroupSymbol]RefGroupTotal, len(groups))
+	for _, group := range groups {
+		total, err := totalObjects(ctx, repo, group, groupRoots[group], progressMeter)
+		if err != nil {
+			return err
+		}
+		s.RefGroupTotals[group] = total
+	}
+
+	return nil
+}
+
+// totalObjects returns the number and total size of the objects
+// that are reachable from `oids`.
+func totalObjects(
+	ctx context.Context, repo *git.Repository, group RefGroupSymbol, oids []git.OID,
+	progressMeter meter.Progress,
+) (RefGroupTotal, error) {
+	objIter, err := repo.NewObjectIter(ctx)
+	if err != nil {
+		return RefGroupTotal{}, err
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		defer objIter.Close()
+
+		errChan <- func() error {
+			for _, oid := range oids {
+				if err := objIter.AddRoot(oid); err != nil {
+					return err
+				}
+			}
+			return nil
+		}()
+	}()
+
+	var total RefGroupTotal
+
+	progressMeter.Start(fmt.Sprintf("Counting objects in refgroup %s: %%d", group))
+	for {
+		obj, ok, err := objIter.Next()
+		if err != nil {
+			return RefGroupTotal{}, err
+		}
+		if !ok {
+			break
+		}
+		progressMeter.Inc()
+		total.ObjectCount.Increment(1)
+		total.ObjectSize.Increment(counts.Count64(obj.ObjectSize))
+	}
+	progressMeter.Done()
+
+	if err := <-errChan; err != nil {
+		return RefGroupTotal{}, err
+	}
+
+	return total, nil
+}
+
+// computeGrowth compares `s.RefGroupTotals` with `baseline`, and
+// stores the result in `s.Growth`.
+func (s *HistorySize) computeGrowth(baseline *Baseline) {
+	growth := Growth{
+		Since:     baseline.ScanTime,
+		RefGroups: []RefGroupGrowth{},
+	}
+
+	add := func(group RefGroupSymbol) {
+		now := s.RefGroupTotals[group]
+		then := baseline.RefGroups[group]
+		growth.RefGroups = append(growth.RefGroups, RefGroupGrowth{
+			RefGroup:          group,
+			ObjectCount:       now.ObjectCount,
+			ObjectSize:        now.ObjectSize,
+			ObjectCountGrowth: int64(now.ObjectCount) - int64(then.ObjectCount),
+			ObjectSizeGrowth:  int64(now.ObjectSize) - int64(then.ObjectSize),
+		})
+	}
+	for group := range s.RefGroupTotals {
+		add(group)
+	}
+	for group := range baseline.RefGroups {
+		if _, ok := s.RefGroupTotals[group]; !ok {
+			// The refgroup no longer has any walked references.
+			add(group)
+		}
+	}
+
+	sort.Slice(growth.RefGroups, func(i, j int) bool {
+		gi, gj := growth.RefGroups[i], growth.RefGroups[j]
+		if gi.ObjectSizeGrowth != gj.ObjectSizeGrowth {
+			return gi.ObjectSizeGrowth > gj.ObjectSizeGrowth
+		}
+		if gi.ObjectCountGrowth != gj.ObjectCountGrowth {
+			return gi.ObjectCountGrowth > gj.ObjectCountGrowth
+		}
+		return gi.RefGroup < gj.RefGroup
+	})
+
+	s.Growth = &growth
+}
+
+// GrowthTableString returns a table showing how much each refgroup
+// has grown since the baseline, or the empty string if no baseline
+// was used.
+func (s *HistorySize) GrowthTableString() string {
+	if s.Growth == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(
+		buf, "\nGrowth sources since %s:\n\n",
+		s.Growth.Since.UTC().Format("2006-01-02 15:04:05 MST"),
+	)
+	s.Growth.writeTable(buf)
+	return buf.String()
+}
+
+// writeTable writes the growth of each refgroup to `w` as a table.
+func (growth *Growth) writeTable(w io.Writer) {
+	fmt.Fprintln(w, "| Refgroup         | Objects   | Growth     | Size      | Growth     |")
+	fmt.Fprintln(w, "| ---------------- | --------- | ---------- | --------- | ---------- |")
+	for _, g := range growth.RefGroups {
+		fmt.Fprintf(
+			w, "| %-16s | %s | %s | %s | %s |\n",
+			g.RefGroup,
+			formatGrowthValue(g.ObjectCount, &counts.Metric, ""),
+			formatGrowth(g.ObjectCountGrowth, &counts.Metric, ""),
+			formatGrowthValue(g.ObjectSize, &counts.Binary, "B"),
+			formatGrowth(g.ObjectSizeGrowth, &counts.Binary, "B"),
+		)
+	}
+}
+
+func formatGrowthValue(n counts.Count64, humaner *counts.Humaner, unit string) string {
+	valueString, unitString := humaner.Format(n, unit)
+	return fmt.Sprintf("%5s %-3s", valueString, unitString)
+}
+
+// formatGrowth formats a change in a value, with an explicit sign.
+func formatGrowth(delta int64, humaner *counts.Humaner, unit string) string {
+	sign := "+"
+	if delta < 0 {
+		sign = "-"
+		delta = -delta
+	}
+	valueString, unitString := humaner.FormatNumber(uint64(delta), unit)
+	return fmt.Sprintf("%6s %-3s", sign+valueString, unitString)
+}
@@ -0,0 +1,201 @@
+package sizes
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// A measured value is reported as approaching a hard limit if it is
+// at least `hardLimitApproachNumerator / hardLimitApproachDenominator`
+// of the limit.
+const (
+	hardLimitApproachNumerator   = 3
+	hardLimitApproachDenominator = 4
+)
+
+// nameMax is the maximum length, in bytes, of a file name on most
+// filesystems (e.g., ext4, XFS, APFS, and NTFS).
+const nameMax = 255
+
+// minRecordedEntryNameLength is the length of the shortest tree entry
+// names that are considered for `HistorySize.maxEntryNameLength`.
+// Shorter names can't approach `nameMax`, so there is no need to
+// lock the history to record them.
+const minRecordedEntryNameLength = nameMax * hardLimitApproachNumerator / hardLimitApproachDenominator
+
+// hardLimit is a limit that Git or common filesystems enforce. Unlike
+// the level of concern of a statistic, which only indicates how
+// unusual a value is, exceeding a hard limit makes some operations
+// fail (or, for the path length limits, fail on some platforms).
+type hardLimit struct {
+	name        string
+	description string
+	max         uint64
+
+	// value returns the measured value to compare with `max`, and
+	// the object that it was measured for, if any. It returns false
+	// if the value wasn't measured.
+	value func(s *HistorySize) (uint64, *Path, bool)
+}
+
+var hardLimits = []hardLimit{
+	{
+		name:        "Entry name length (NAME_MAX)",
+		description: "the longest file name allowed by most filesystems",
+		max:         nameMax,
+		value: func(s *HistorySize) (uint64, *Path, bool) {
+			return uint64(s.maxEntryNameLength), s.maxEntryNameLengthEntry, true
+		},
+	},
+	{
+		name:        "Path length (Windows MAX_PATH)",
+		description: "the longest path allowed on Windows unless 'core.longpaths' is set",
+		max:         260,
+		value: func(s *HistorySize) (uint64, *Path, bool) {
+			return uint64(s.MaxPathLength), s.MaxPathLengthTree, true
+		},
+	},
+	{
+		name:        "Path length (macOS PATH_MAX)",
+		description: "the longest path allowed on macOS",
+		max:         1024,
+		value: func(s *HistorySize) (uint64, *Path, bool) {
+			return uint64(s.MaxPathLength), s.MaxPathLengthTree, true
+		},
+	},
+	{
+		name:        "Path length (Linux PATH_MAX)",
+		description: "the longest path allowed on Linux",
+		max:         4096,
+		value: func(s *HistorySize) (uint64, *Path, bool) {
+			return uint64(s.MaxPathLength), s.MaxPathLengthTree, true
+		},
+	},
+	{
+		name:        "Tree depth (core.maxTreeDepth)",
+		description: "the deepest tree that Git traverses by default",
+		max:         2048,
+		value: func(s *HistorySize) (uint64, *Path, bool) {
+			return uint64(s.MaxPathDepth), s.MaxPathDepthTree, true
+		},
+	},
+	{
+		name:        "Objects in a packfile (2^32)",
+		description: "the number of objects that a packfile can hold",
+		max:         math.MaxUint32,
+		value: func(s *HistorySize) (uint64, *Path, bool) {
+			return uint64(s.UniqueCommitCount) + uint64(s.UniqueTreeCount) +
+				uint64(s.UniqueBlobCount) + uint64(s.UniqueTagCount), nil, true
+		},
+	},
+	{
+		name:        "Entries in the index (2^32)",
+		description: "the number of entries that the index can hold",
+		max:         math.MaxUint32,
+		value: func(s *HistorySize) (uint64, *Path, bool) {
+			ts := s.maxExpandedBlobCountTreeSize
+			if s.MaxExpandedBlobCountTree == nil || ts.ExpansionLimited {
+				return 0, nil, false
+			}
+			return ts.expandedIndexEntryCount(), s.MaxExpandedBlobCountTree, true
+		},
+	},
+}
+
+// HardLimitFinding is a measured value that exceeds or approaches a
+// hard limit.
+type HardLimitFinding struct {
+	Limit       string         `json:"limit"`
+	Description string         `json:"description"`
+	Value       counts.Count64 `json:"value"`
+	Max         counts.Count64 `json:"max"`
+
+	// Exceeded is true if `Value` exceeds `Max`, and false if it
+	// only approaches it.
+	Exceeded bool `json:"exceeded"`
+
+	// Object is the object for which `Value` was measured, if any.
+	Object *Path `json:"object,omitempty"`
+}
+
+// recordLongEntryName records that the tree with the specified `oid`
+// has an entry called `name`, referring to `childOID`, if its name is
+// the longest seen so far. The caller must hold `g.historyLock`.
+func (s *HistorySize) recordLongEntryName(
+	g *Graph, oid git.OID, name string, childOID git.OID, objectType string,
+) {
+	if !g.countsTowardMaxima(oid) {
+		return
+	}
+	if s.maxEntryNameLength.AdjustMaxIfNecessary(counts.NewCount32(uint64(len(name)))) {
+		if s.maxEntryNameLengthEntry != nil {
+			g.pathResolver.ForgetPath(s.maxEntryNameLengthEntry)
+		}
+		s.maxEntryNameLengthEntry = g.pathResolver.RequestEntryPath(oid, name, childOID, objectType)
+	}
+}
+
+// checkHardLimits compares the measured maxima with `hardLimits`, and
+// stores those that they exceed or approach in `s.HardLimits`.
+func (s *HistorySize) checkHardLimits() {
+	for _, limit := range hardLimits {
+		value, object, ok := limit.value(s)
+		if !ok || value*hardLimitApproachDenominator < limit.max*hardLimitApproachNumerator {
+			continue
+		}
+		s.HardLimits = append(s.HardLimits, HardLimitFinding{
+			Limit:       limit.name,
+			Description: limit.description,
+			Value:       counts.Count64(value),
+			Max:         counts.Count64(limit.max),
+			Exceeded:    value > limit.max,
+			Object:      object,
+		})
+	}
+}
+
+// HardLimitsString lists the hard limits that are exceeded or
+// approached, or returns the empty string if there are none.
+func (s *HistorySize) HardLimitsString() string {
+	if len(s.HardLimits) == 0 {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nHard limits:\n\n")
+	fmt.Fprintln(buf, "| Limit                                    | Value     | Max       | Status")
+	fmt.Fprintln(buf, "| ---------------------------------------- | --------- | --------- | ----------")
+	for _, f := range s.HardLimits {
+		status := "approaches"
+		if f.Exceeded {
+			status = "EXCEEDS"
+		}
+		fmt.Fprintf(
+			buf, "| %-40s | %s | %s | %s\n", f.Limit,
+			formatGrowthValue(f.Value, &counts.Metric, ""),
+			formatGrowthValue(f.Max, &counts.Metric, ""),
+			status,
+		)
+	}
+
+	fmt.Fprintln(buf)
+	for _, f := range s.HardLimits {
+		if f.Object != nil {
+			fmt.Fprintf(
+				buf, "  %s: %s%s\n", f.Limit, f.Object,
+				objectNoteSuffix(notesFor(s.ObjectNotes, f.Object)),
+			)
+		}
+	}
+	fmt.Fprintln(
+		buf,
+		"\nUnlike the level of concern, which only shows how unusual a value is,\n"+
+			"these are limits that Git or common filesystems enforce. Exceeding one\n"+
+			"of them makes some operations fail, at least on some platforms.",
+	)
+	return buf.String()
+}
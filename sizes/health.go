@@ -0,0 +1,166 @@
+package sizes
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+)
+
+// healthWeights are the weights of the categories of the health
+// score, which are the sections of the main table (see
+// `ReportSections`). The size of the history and of its biggest
+// objects hurt the most, because they affect every clone.
+var healthWeights = map[string]float64{
+	"overall":           30,
+	"reference-tips":    10,
+	"biggest-objects":   25,
+	"history-structure": 15,
+	"biggest-checkouts": 20,
+}
+
+// healthMaxConcern is the level of concern at which a statistic
+// costs its category all of its points. It is the most that the table
+// displays ("!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!").
+const healthMaxConcern = 30
+
+// HealthScore is a single number from 0 (worst) to 100 (best) that
+// summarizes the levels of concern of the statistics, for tracking a
+// repository over time. Each category is scored by its most
+// concerning statistic, and the score is the weighted average of the
+// categories' scores.
+type HealthScore struct {
+	Score      float64          `json:"score"`
+	Categories []HealthCategory `json:"categories"`
+}
+
+// HealthCategory is the contribution of one section of the table to
+// the health score.
+type HealthCategory struct {
+	Category string `json:"category"`
+	Title    string `json:"title"`
+
+	// Weight is the category's share of the score (the weights of the
+	// categories that have statistics add up to 1).
+	Weight float64 `json:"weight"`
+
+	// Score is the category's own score, from 0 to 100, and
+	// PointsLost is how many points of the total score it costs.
+	Score      float64 `json:"score"`
+	PointsLost float64 `json:"points_lost"`
+
+	// WorstStat is the symbol of the category's most concerning
+	// statistic, and WorstLevelOfConcern its level of concern.
+	WorstStat           string  `json:"worst_stat,omitempty"`
+	WorstLevelOfConcern float64 `json:"worst_level_of_concern"`
+}
+
+// healthPenalty maps a level of concern to the fraction of its
+// category's points that it costs: none for a level of 0, a fifth for
+// 1 (one star), and all of them for `healthMaxConcern` or more. The
+// scale is logarithmic, like the way the levels of concern grow with
+// the size of a repository.
+func healthPenalty(levelOfConcern float64) float64 {
+	if !(levelOfConcern > 0) {
+		return 0
+	}
+	return math.Min(1, math.Log1p(levelOfConcern)/math.Log1p(healthMaxConcern))
+}
+
+// ComputeHealthScore computes the health score from the statistics
+// that were collected (including those about `refGroups`), and stores
+// it in `s.HealthScore`.
+func (s *HistorySize) ComputeHealthScore(refGroups []RefGroup) {
+	top, ok := s.contents(refGroups).(*section)
+	if !ok {
+		panic("the table contents aren't a section")
+	}
+
+	var categories []HealthCategory
+	var totalWeight float64
+	for _, name := range ReportSections {
+		title := sectionTitles[name]
+		for _, c := range top.contents {
+			sec, ok := c.(*section)
+			if !ok || sec.name != title {
+				continue
+			}
+			items := make(map[string]*item)
+			sec.CollectItems(items)
+
+			category := HealthCategory{
+				Category: name,
+				Title:    title,
+			}
+			found := false
+			for symbol, i := range items {
+				if !s.stats.Contains(symbol) {
+					continue
+				}
+				found = true
+				value, overflow := i.value.ToUint64()
+				concern := i.concern(value)
+				if overflow {
+					concern = math.Inf(1)
+				}
+				if concern > category.WorstLevelOfConcern ||
+					(concern == category.WorstLevelOfConcern && symbol < category.WorstStat) {
+					category.WorstStat = symbol
+					category.WorstLevelOfConcern = concern
+				}
+			}
+			if !found {
+				continue
+			}
+			if math.IsInf(category.WorstLevelOfConcern, 1) {
+				category.WorstLevelOfConcern = math.MaxFloat64
+			}
+			category.Score = 100 * (1 - healthPenalty(category.WorstLevelOfConcern))
+			category.Weight = healthWeights[name]
+			totalWeight += category.Weight
+			categories = append(categories, category)
+		}
+	}
+
+	health := HealthScore{
+		Score:      100,
+		Categories: []HealthCategory{},
+	}
+	for _, category := range categories {
+		category.Weight /= totalWeight
+		category.PointsLost = category.Weight * (100 - category.Score)
+		health.Score -= category.PointsLost
+		health.Categories = append(health.Categories, category)
+	}
+	health.Score = math.Max(0, math.Round(10*health.Score)/10)
+	s.HealthScore = &health
+}
+
+// HealthScoreString returns a summary of the health score and the
+// contribution of each category, or the empty string if it wasn't
+// computed.
+func (s *HistorySize) HealthScoreString() string {
+	h := s.HealthScore
+	if h == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nRepository health score: %.0f/100\n\n", h.Score)
+	if len(h.Categories) == 0 {
+		fmt.Fprintln(buf, "No statistics were collected.")
+		return buf.String()
+	}
+	fmt.Fprintln(buf, "| Category                | Weight | Score | Points lost | Worst statistic")
+	fmt.Fprintln(buf, "| ----------------------- | ------ | ----- | ----------- | ---------------")
+	for _, c := range h.Categories {
+		worst := ""
+		if c.WorstStat != "" {
+			worst = fmt.Sprintf("%s (level of concern %.1f)", c.WorstStat, c.WorstLevelOfConcern)
+		}
+		fmt.Fprintf(
+			buf, "| %-23s | %5.0f%% | %5.0f | %11.1f | %s\n",
+			c.Title, 100*c.Weight, c.Score, c.PointsLost, worst,
+		)
+	}
+	return buf.String()
+}
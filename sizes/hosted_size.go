@@ -0,0 +1,70 @@
+package sizes
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/github/git-sizer/counts"
+)
+
+// HostedSize is the size that a hosting service reports for its copy
+// of the repository, for comparison with the sizes measured locally.
+type HostedSize struct {
+	// Host is the name of the hosting service (e.g., "GitHub"), and
+	// Repository is the name of the repository there. Repository is
+	// empty if the results are anonymized.
+	Host       string `json:"host"`
+	Repository string `json:"repository,omitempty"`
+
+	// DiskSize is the disk usage, in bytes, that the host reports.
+	DiskSize counts.Count64 `json:"disk_size"`
+}
+
+// SetHostedSize records the size that a hosting service reports for
+// its copy of the repository, so that it is output along with the
+// local measurements.
+func (s *HistorySize) SetHostedSize(h HostedSize) {
+	if s.anonymizer != nil {
+		h.Repository = ""
+	}
+	s.HostedSize = &h
+}
+
+// HostedSizeString compares the size reported by the hosting service
+// with the local measurements, or returns the empty string if there
+// is nothing to compare with.
+func (s *HistorySize) HostedSizeString() string {
+	h := s.HostedSize
+	if h == nil {
+		return ""
+	}
+
+	name := h.Host + "'s copy"
+	if h.Repository != "" {
+		name += " of " + h.Repository
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nComparison with the size of %s:\n\n", name)
+	fmt.Fprintln(buf, "| Size                                                 | Value")
+	fmt.Fprintln(buf, "| ---------------------------------------------------- | ---------")
+	row := func(name string, n counts.Count64) {
+		fmt.Fprintf(buf, "| %-52s | %s\n", name, formatSharedBytes(n))
+	}
+	row(fmt.Sprintf("Disk usage reported by %s", h.Host), h.DiskSize)
+	row("Reachable objects on disk here (compressed)", s.ReachableDiskSize)
+	row(
+		"Unique commits, trees, and blobs here (uncompressed)",
+		s.UniqueCommitSize+s.UniqueTreeSize+s.UniqueBlobSize,
+	)
+	fmt.Fprintf(
+		buf,
+		"\n%s's figure is the disk usage of its own copy, which is packed differently,\n"+
+			"can include objects that aren't reachable from the references scanned here\n"+
+			"(e.g., those of pull requests, or garbage that hasn't been collected yet),\n"+
+			"and is only updated from time to time. The sizes in the main table are\n"+
+			"uncompressed, so they are usually much larger than the sizes on disk.\n",
+		h.Host,
+	)
+	return buf.String()
+}
@@ -0,0 +1,270 @@
+package sizes
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// hostingLimitBlobLimit is the maximum number of the blobs that
+// exceed a hosting limit that are listed for each preset.
+const hostingLimitBlobLimit = 10
+
+// HostingPreset describes the limits on the sizes of files that a
+// hosting service enforces when objects are pushed to it.
+type HostingPreset struct {
+	Name        string
+	Description string
+
+	// WarnBlobSize, if nonzero, is the blob size above which a push
+	// succeeds, but with a warning.
+	WarnBlobSize counts.Count32
+
+	// RejectBlobSize, if nonzero, is the blob size above which a
+	// push is rejected.
+	RejectBlobSize counts.Count32
+}
+
+// HostingPresets are the built-in hosting limits, as documented by
+// the services.
+var HostingPresets = []HostingPreset{
+	{
+		Name:           "github",
+		Description:    "GitHub, pushing via Git",
+		WarnBlobSize:   50 << 20,
+		RejectBlobSize: 100 << 20,
+	},
+	{
+		Name:           "github-web",
+		Description:    "GitHub, uploading via the web interface",
+		RejectBlobSize: 25 << 20,
+	},
+	{
+		Name:           "huggingface",
+		Description:    "Hugging Face Hub, files not stored with LFS or Xet",
+		RejectBlobSize: 10 << 20,
+	},
+}
+
+// ParseHostingPresets parses a comma-separated list of the names of
+// hosting presets, or "all" for all of them.
+func ParseHostingPresets(s string) ([]HostingPreset, error) {
+	var presets []HostingPreset
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+			continue
+		case "all":
+			return HostingPresets, nil
+		}
+
+		found := false
+		for _, preset := range HostingPresets {
+			if preset.Name == name {
+				presets = append(presets, preset)
+				found = true
+				break
+			}
+		}
+		if !found {
+			names := make([]string, len(HostingPresets))
+			for i, preset := range HostingPresets {
+				names[i] = preset.Name
+			}
+			return nil, fmt.Errorf(
+				"unknown hosting preset '%s' (known presets: %s, or 'all')",
+				name, strings.Join(names, ", "),
+			)
+		}
+	}
+	return presets, nil
+}
+
+// cutoff returns the smallest blob size above which `preset` warns or
+// rejects, or 0 if it has no limits.
+func (preset HostingPreset) cutoff() counts.Count32 {
+	if preset.WarnBlobSize != 0 && preset.WarnBlobSize < preset.RejectBlobSize {
+		return preset.WarnBlobSize
+	}
+	if preset.RejectBlobSize != 0 {
+		return preset.RejectBlobSize
+	}
+	return preset.WarnBlobSize
+}
+
+// HostingLimitViolations counts the unique blobs that exceed the
+// limits of a hosting preset; i.e., that would make pushing the
+// history to the service fail, or cause warnings.
+type HostingLimitViolations struct {
+	Preset         string         `json:"preset"`
+	Description    string         `json:"description"`
+	WarnBlobSize   counts.Count32 `json:"warn_blob_size,omitempty"`
+	RejectBlobSize counts.Count32 `json:"reject_blob_size,omitempty"`
+
+	// RejectedBlobCount is the number of blobs that are larger than
+	// `RejectBlobSize`, and WarnedBlobCount the number of the other
+	// blobs that are larger than `WarnBlobSize`.
+	RejectedBlobCount counts.Count32 `json:"rejected_blob_count"`
+	WarnedBlobCount   counts.Count32 `json:"warned_blob_count"`
+
+	// Blobs lists (at most `hostingLimitBlobLimit` of) the largest
+	// blobs that exceed one of the limits, largest first.
+	Blobs []HostingLimitBlob `json:"blobs"`
+}
+
+// HostingLimitBlob is a blob that exceeds a hosting limit.
+type HostingLimitBlob struct {
+	Blob git.OID `json:"blob"`
+
+	// Name is the name of the blob, if names were requested.
+	Name *Path `json:"name,omitempty"`
+
+	Size     counts.Count32 `json:"size"`
+	Rejected bool           `json:"rejected"`
+}
+
+// recordHostingLimitCandidate counts the blob `oid` in the hosting
+// presets whose limits it exceeds, and remembers it if it is among the
+// largest such blobs. The caller must hold `g.historyLock`.
+func (g *Graph) recordHostingLimitCandidate(oid git.OID, size counts.Count32) {
+	if g.hostingCutoff == 0 || size <= g.hostingCutoff || !g.countsTowardTotals(oid) {
+		return
+	}
+
+	for i := range g.historySize.HostingLimits {
+		v := &g.historySize.HostingLimits[i]
+		switch {
+		case v.RejectBlobSize != 0 && size > v.RejectBlobSize:
+			v.RejectedBlobCount.Increment(1)
+		case v.WarnBlobSize != 0 && size > v.WarnBlobSize:
+			v.WarnedBlobCount.Increment(1)
+		}
+	}
+
+	if len(g.hostingBlobs) == hostingLimitBlobLimit {
+		if size <= g.hostingBlobs[0].size {
+			return
+		}
+		evicted := heap.Pop(&g.hostingBlobs).(largeBlob)
+		if evicted.path != nil {
+			g.pathResolver.ForgetPath(evicted.path)
+		}
+	}
+
+	heap.Push(&g.hostingBlobs, largeBlob{
+		oid:  oid,
+		size: size,
+		path: g.pathResolver.RequestPath(oid, "blob"),
+	})
+}
+
+// newHostingLimitViolations returns the (zero) violations of
+// `presets`, and the smallest size above which a blob violates any of
+// them.
+func newHostingLimitViolations(presets []HostingPreset) ([]HostingLimitViolations, counts.Count32) {
+	if len(presets) == 0 {
+		return nil, 0
+	}
+
+	violations := make([]HostingLimitViolations, len(presets))
+	var cutoff counts.Count32
+	for i, preset := range presets {
+		violations[i] = HostingLimitViolations{
+			Preset:         preset.Name,
+			Description:    preset.Description,
+			WarnBlobSize:   preset.WarnBlobSize,
+			RejectBlobSize: preset.RejectBlobSize,
+		}
+		if c := preset.cutoff(); c != 0 && (cutoff == 0 || c < cutoff) {
+			cutoff = c
+		}
+	}
+	return violations, cutoff
+}
+
+// listHostingLimitBlobs fills in the blobs that exceed the limits of
+// each preset in `s.HostingLimits`, from those recorded by
+// `recordHostingLimitCandidate()`. Since the blobs that exceed a limit
+// are all larger than those that don't, the largest blobs that violate
+// a preset are among the largest blobs that violate any of them.
+func (s *HistorySize) listHostingLimitBlobs(g *Graph) {
+	g.historyLock.Lock()
+	blobs := append([]largeBlob(nil), g.hostingBlobs...)
+	g.historyLock.Unlock()
+
+	sort.Slice(blobs, func(i, j int) bool {
+		if blobs[i].size != blobs[j].size {
+			return blobs[i].size > blobs[j].size
+		}
+		return bytes.Compare(blobs[i].oid.Bytes(), blobs[j].oid.Bytes()) < 0
+	})
+
+	for i := range s.HostingLimits {
+		v := &s.HostingLimits[i]
+		v.Blobs = []HostingLimitBlob{}
+		for _, blob := range blobs {
+			rejected := v.RejectBlobSize != 0 && blob.size > v.RejectBlobSize
+			if !rejected && (v.WarnBlobSize == 0 || blob.size <= v.WarnBlobSize) {
+				continue
+			}
+			v.Blobs = append(v.Blobs, HostingLimitBlob{
+				Blob:     blob.oid,
+				Name:     blob.path,
+				Size:     blob.size,
+				Rejected: rejected,
+			})
+		}
+	}
+}
+
+// HostingLimitsString returns a summary of the blobs that exceed the
+// limits of the hosting presets, or the empty string if none were
+// selected.
+func (s *HistorySize) HostingLimitsString() string {
+	if len(s.HostingLimits) == 0 {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nHosting limits:\n\n")
+	for _, v := range s.HostingLimits {
+		var problems []string
+		if v.RejectBlobSize != 0 {
+			problems = append(problems, fmt.Sprintf(
+				"blobs over %s that would be rejected: %d",
+				formatBytes(counts.Count64(v.RejectBlobSize)), v.RejectedBlobCount,
+			))
+		}
+		if v.WarnBlobSize != 0 {
+			problems = append(problems, fmt.Sprintf(
+				"blobs over %s that would cause warnings: %d",
+				formatBytes(counts.Count64(v.WarnBlobSize)), v.WarnedBlobCount,
+			))
+		}
+		fmt.Fprintf(
+			buf, "* %s (%s): %s\n",
+			v.Preset, v.Description, strings.Join(problems, "; "),
+		)
+		for _, blob := range v.Blobs {
+			verdict := "warning"
+			if blob.Rejected {
+				verdict = "rejected"
+			}
+			name := blob.Blob.String()
+			if blob.Name != nil {
+				name = blob.Name.BestPath()
+			}
+			fmt.Fprintf(
+				buf, "    * %s: %s (%s)\n",
+				name, formatBytes(counts.Count64(blob.Size)), verdict,
+			)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,243 @@
+package sizes
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"path"
+
+	"github.com/github/git-sizer/counts"
+)
+
+// htmlTreemapMinFraction is the fraction of the total size of the
+// unique blobs below which the entries of a directory are lumped
+// together in the treemap, so that the file stays small enough for a
+// browser to handle even for histories with millions of paths.
+const htmlTreemapMinFraction = 1e-4
+
+// treemapNode is one rectangle of the treemap: a file or directory
+// (or a lump of small entries), with its cumulative historical size.
+type treemapNode struct {
+	Name     string         `json:"name"`
+	Size     counts.Count64 `json:"size"`
+	Count    counts.Count32 `json:"count"`
+	Children []*treemapNode `json:"children,omitempty"`
+}
+
+// buildTreemap returns the treemap node for the directory `dir`,
+// named `name`, including those of its entries (recursively) that are
+// at least `minSize` bytes.
+func (s *HistorySize) buildTreemap(dir, name string, minSize float64) *treemapNode {
+	ds, _ := s.DirectorySizes.Lookup(dir)
+	node := &treemapNode{
+		Name:  name,
+		Size:  ds.BlobSize,
+		Count: ds.ObjectCount,
+	}
+
+	other := treemapNode{}
+	var otherEntries int
+	for _, child := range s.DirectorySizes.Children(dir) {
+		if child.BlobSize == 0 || float64(child.BlobSize) < minSize {
+			otherEntries++
+			other.Size.Increment(child.BlobSize)
+			other.Count.Increment(child.ObjectCount)
+			continue
+		}
+		node.Children = append(
+			node.Children,
+			s.buildTreemap(child.Path, s.anonymizer.Path(path.Base(child.Path)), minSize),
+		)
+	}
+	if otherEntries != 0 {
+		other.Name = fmt.Sprintf("(%d smaller entries)", otherEntries)
+		node.Children = append(node.Children, &other)
+	}
+	return node
+}
+
+// WriteHTMLTreemap writes a self-contained HTML page to `w` that shows
+// the cumulative historical size of the paths in the history (see
+// `DirectorySizes`) as a zoomable treemap, for people who would rather
+// not read tables. It requires the directory sizes to have been
+// computed (see `ScanOptions.DirectorySizes`).
+func (s *HistorySize) WriteHTMLTreemap(w io.Writer) error {
+	if s.DirectorySizes == nil {
+		return errors.New("the sizes of the directories were not computed")
+	}
+
+	top, _ := s.DirectorySizes.Lookup("")
+	sizeValue, sizeUnit := counts.Binary.Format(top.BlobSize, "B")
+	countValue, countUnit := counts.Metric.Format(top.ObjectCount, "")
+	return htmlTreemapTemplate.Execute(w, struct {
+		Total string
+		Root  *treemapNode
+	}{
+		Total: fmt.Sprintf(
+			"%s %s of unique blobs in %s%s unique blobs and trees",
+			sizeValue, sizeUnit, countValue, countUnit,
+		),
+		Root: s.buildTreemap("", ".", float64(top.BlobSize)*htmlTreemapMinFraction),
+	})
+}
+
+var htmlTreemapTemplate = template.Must(template.New("treemap").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>git-sizer: historical size by path</title>
+<style>
+body { margin: 0; font: 13px sans-serif; display: flex; flex-direction: column; height: 100vh; }
+header { padding: 8px 12px; border-bottom: 1px solid #ccc; }
+h1 { font-size: 16px; margin: 0 0 4px; }
+#crumbs a { color: #06c; cursor: pointer; }
+#map { position: relative; flex: 1; margin: 8px; overflow: hidden; }
+.cell { position: absolute; box-sizing: border-box; border: 1px solid #fff; overflow: hidden; white-space: nowrap; }
+.cell span { padding: 1px 3px; display: block; overflow: hidden; text-overflow: ellipsis; }
+.dir { cursor: zoom-in; }
+</style>
+</head>
+<body>
+<header>
+<h1>Historical size by path</h1>
+<div>{{.Total}}. Each object is counted at the first path at which <code>git rev-list --objects</code> finds it. Click a directory to zoom in.</div>
+<div id="crumbs"></div>
+</header>
+<div id="map"></div>
+<script>
+"use strict";
+const root = {{.Root}};
+const map = document.getElementById("map");
+const crumbs = document.getElementById("crumbs");
+let stack = [{node: root, path: ""}];
+
+function formatBytes(n) {
+	const units = ["B", "KiB", "MiB", "GiB", "TiB", "PiB"];
+	let i = 0;
+	while (n >= 1024 && i < units.length - 1) {
+		n /= 1024;
+		i++;
+	}
+	return (i ? n.toFixed(2) : n) + " " + units[i];
+}
+
+// worst returns the worst aspect ratio of the rectangles in a row
+// of total area "sum" laid along a side of length "side".
+function worst(row, sum, side) {
+	let max = 0, min = Infinity;
+	for (const r of row) {
+		max = Math.max(max, r.area);
+		min = Math.min(min, r.area);
+	}
+	const s2 = sum * sum, side2 = side * side;
+	return Math.max(side2 * max / s2, s2 / (side2 * min));
+}
+
+// layout lays out "items" (sorted biggest first) in the rectangle
+// using the squarified treemap algorithm, appending to "out".
+function layout(items, x, y, w, h, out) {
+	let i = 0;
+	while (i < items.length) {
+		const side = Math.min(w, h);
+		let row = [items[i]], sum = items[i].area;
+		for (i++; i < items.length; i++) {
+			const next = row.concat(items[i]), s = sum + items[i].area;
+			if (worst(next, s, side) > worst(row, sum, side)) {
+				break;
+			}
+			row = next;
+			sum = s;
+		}
+		const thickness = sum / side;
+		let offset = 0;
+		for (const r of row) {
+			const length = r.area / thickness;
+			if (w >= h) {
+				out.push({node: r.node, x: x, y: y + offset, w: thickness, h: length});
+			} else {
+				out.push({node: r.node, x: x + offset, y: y, w: length, h: thickness});
+			}
+			offset += length;
+		}
+		if (w >= h) {
+			x += thickness;
+			w -= thickness;
+		} else {
+			y += thickness;
+			h -= thickness;
+		}
+	}
+}
+
+function zoom(node, path) {
+	stack.push({node: node, path: path});
+	render();
+}
+
+// draw draws the entries of "node" in the rectangle, and "depth" - 1
+// more levels beneath them. Clicking a cell zooms into "target" if it
+// is set, or otherwise into the cell itself if it is a directory.
+function draw(node, path, x, y, w, h, depth, hue, target) {
+	const kids = (node.children || []).filter(c => c.size > 0);
+	const total = kids.reduce((sum, c) => sum + c.size, 0);
+	if (!total || w <= 0 || h <= 0) {
+		return;
+	}
+	const rects = [];
+	layout(kids.map(c => ({node: c, area: c.size / total * w * h})), x, y, w, h, rects);
+	rects.forEach((r, i) => {
+		const c = r.node;
+		const p = path ? path + "/" + c.name : c.name;
+		const cellHue = hue === undefined ? (i * 47) % 360 : hue;
+		const el = document.createElement("div");
+		el.className = "cell";
+		el.style.left = r.x + "px";
+		el.style.top = r.y + "px";
+		el.style.width = r.w + "px";
+		el.style.height = r.h + "px";
+		el.style.background = "hsl(" + cellHue + ", 55%, " + (target ? 85 : 72) + "%)";
+		el.title = p + "\n" + formatBytes(c.size) + " in " + c.count + " objects";
+		if (r.w > 40 && r.h > 14) {
+			const label = document.createElement("span");
+			label.textContent = c.name + " (" + formatBytes(c.size) + ")";
+			el.appendChild(label);
+		}
+		map.appendChild(el);
+		const zoomTo = target || (c.children ? {node: c, path: p} : null);
+		if (zoomTo) {
+			el.classList.add("dir");
+			el.onclick = () => zoom(zoomTo.node, zoomTo.path);
+		}
+		if (c.children && depth > 1 && r.w > 30 && r.h > 40) {
+			draw(c, p, r.x + 3, r.y + 18, r.w - 6, r.h - 21, depth - 1, cellHue, zoomTo);
+		}
+	});
+}
+
+function render() {
+	map.textContent = "";
+	crumbs.textContent = "";
+	stack.forEach((entry, i) => {
+		if (i) {
+			crumbs.appendChild(document.createTextNode(" / "));
+		}
+		const a = document.createElement("a");
+		a.textContent = i ? entry.node.name : "(top)";
+		a.onclick = () => {
+			stack = stack.slice(0, i + 1);
+			render();
+		};
+		crumbs.appendChild(a);
+	});
+	const entry = stack[stack.length - 1];
+	crumbs.appendChild(document.createTextNode(" — " + formatBytes(entry.node.size)));
+	draw(entry.node, entry.path, 0, 0, map.clientWidth, map.clientHeight, 2);
+}
+
+window.addEventListener("resize", render);
+render();
+</script>
+</body>
+</html>
+`))
@@ -0,0 +1,230 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// importArtifactKind describes one kind of file that tools for
+// importing history from another version control system tend to leave
+// behind.
+type importArtifactKind struct {
+	// pattern is how the artifact is shown: a directory name
+	// followed by "/", a file name, or a trailer's key.
+	pattern string
+
+	// vcs is the name of the version control system that the
+	// artifact comes from.
+	vcs string
+}
+
+// importArtifactDirectories are the names of the directories in
+// which other version control systems keep their metadata, and which
+// careless imports (or commits of whole working copies) add to the
+// history.
+var importArtifactDirectories = map[string]importArtifactKind{
+	".svn": {".svn/", "Subversion"},
+	"CVS":  {"CVS/", "CVS"},
+	"$tf":  {"$tf/", "TFS"},
+}
+
+// importArtifactFiles are the names of files that only matter to
+// other version control systems.
+var importArtifactFiles = map[string]importArtifactKind{
+	".cvsignore": {".cvsignore", "CVS"},
+}
+
+// importTrailers are the trailers that bridges to other version
+// control systems add to the log messages of the commits that they
+// import.
+var importTrailers = []importArtifactKind{
+	{"git-svn-id", "Subversion"},
+	{"git-tfs-id", "TFS"},
+}
+
+// ImportArtifacts describes the files and commit trailers in the
+// history that were probably left behind by an import from another
+// version control system (Subversion, CVS, or TFS). If there are
+// many, the import deserves a cleanup pass.
+type ImportArtifacts struct {
+	// BlobCount and BlobSize are the number and total size of the
+	// unique blobs that are artifacts. Like `DirectorySizes`, each
+	// blob is attributed to only one path (the first one at which
+	// `git rev-list --objects` encounters it).
+	BlobCount counts.Count32 `json:"blob_count"`
+	BlobSize  counts.Count64 `json:"blob_size"`
+
+	// CommitCount is the number of commits whose log messages have
+	// an import trailer (e.g., "git-svn-id:").
+	CommitCount counts.Count32 `json:"commit_count"`
+
+	// Artifacts lists the kinds of artifacts that were found: first
+	// the files, largest first, then the trailers.
+	Artifacts []ImportArtifact `json:"artifacts"`
+}
+
+// ImportArtifact is the number and total size of the artifacts of
+// one kind. For a trailer (e.g., "git-svn-id"), `Count` is the
+// number of commits that have it, and `Size` is zero.
+type ImportArtifact struct {
+	Pattern string         `json:"pattern"`
+	VCS     string         `json:"vcs"`
+	Count   counts.Count32 `json:"count"`
+	Size    counts.Count64 `json:"size"`
+
+	// Example is the first path at which an artifact of this kind
+	// was found (for a directory, the path of the directory). It is
+	// empty for trailers.
+	Example string `json:"example,omitempty"`
+}
+
+// importArtifactKindOf returns the kind of artifact that the blob at
+// `path` is, and the path of the artifact (i.e., of the metadata
+// directory containing the blob, if any), or false if it isn't one.
+func importArtifactKindOf(path string) (importArtifactKind, string, bool) {
+	components := strings.Split(path, "/")
+	for i, c := range components[:len(components)-1] {
+		if kind, ok := importArtifactDirectories[c]; ok {
+			return kind, strings.Join(components[:i+1], "/") + "/", true
+		}
+	}
+	if kind, ok := importArtifactFiles[components[len(components)-1]]; ok {
+		return kind, path, true
+	}
+	return importArtifactKind{}, "", false
+}
+
+// findImportArtifacts looks for import artifacts among the unique
+// blobs and commits that are reachable from the walked `roots`, and
+// stores the results in `s.ImportArtifacts`. The blobs' sizes are
+// looked up in `g`.
+func (s *HistorySize) findImportArtifacts(
+	ctx context.Context, repo *git.Repository, g *Graph, roots []Root,
+	progressMeter meter.Progress,
+) error {
+	var tips []git.OID
+	for _, root := range roots {
+		if root.Walk() {
+			tips = append(tips, root.OID())
+		}
+	}
+
+	ia := ImportArtifacts{
+		Artifacts: []ImportArtifact{},
+	}
+	byPattern := make(map[string]*ImportArtifact)
+
+	progressMeter.Start("Looking for import artifacts: %d")
+	err := repo.ObjectPaths(ctx, tips, func(oid git.OID, path string) {
+		progressMeter.Inc()
+		size, ok := g.blobSizes[oid]
+		if !ok || !g.countsTowardTotals(oid) {
+			return
+		}
+		kind, artifactPath, ok := importArtifactKindOf(path)
+		if !ok {
+			return
+		}
+		a, ok := byPattern[kind.pattern]
+		if !ok {
+			a = &ImportArtifact{
+				Pattern: kind.pattern,
+				VCS:     kind.vcs,
+				Example: s.anonymizer.Path(artifactPath),
+			}
+			byPattern[kind.pattern] = a
+		}
+		a.Count.Increment(1)
+		a.Size.Increment(counts.Count64(size.Size))
+		ia.BlobCount.Increment(1)
+		ia.BlobSize.Increment(counts.Count64(size.Size))
+	})
+	progressMeter.Done()
+	if err != nil {
+		return err
+	}
+
+	for _, a := range byPattern {
+		ia.Artifacts = append(ia.Artifacts, *a)
+	}
+	sort.Slice(ia.Artifacts, func(i, j int) bool {
+		a, b := ia.Artifacts[i], ia.Artifacts[j]
+		switch {
+		case a.Size != b.Size:
+			return a.Size > b.Size
+		case a.Count != b.Count:
+			return a.Count > b.Count
+		default:
+			return a.Pattern < b.Pattern
+		}
+	})
+
+	commits := make(map[git.OID]struct{})
+	for _, trailer := range importTrailers {
+		oids, err := repo.CommitsMatching(ctx, tips, "^"+trailer.pattern+": ")
+		if err != nil {
+			return err
+		}
+		a := ImportArtifact{Pattern: trailer.pattern, VCS: trailer.vcs}
+		for _, oid := range oids {
+			if !g.countsTowardTotals(oid) {
+				continue
+			}
+			a.Count.Increment(1)
+			commits[oid] = struct{}{}
+		}
+		if a.Count != 0 {
+			ia.Artifacts = append(ia.Artifacts, a)
+		}
+	}
+	ia.CommitCount = counts.NewCount32(uint64(len(commits)))
+
+	s.ImportArtifacts = &ia
+	return nil
+}
+
+// ImportArtifactsTableString lists the import artifacts that were
+// found, or returns the empty string if they weren't sought.
+func (s *HistorySize) ImportArtifactsTableString() string {
+	ia := s.ImportArtifacts
+	if ia == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nVCS import artifacts:\n\n")
+	if len(ia.Artifacts) == 0 {
+		fmt.Fprintln(buf, "No artifacts of an import from Subversion, CVS, or TFS were found.")
+		return buf.String()
+	}
+
+	fmt.Fprintln(buf, "| Artifact             | VCS        | Count     | Size      | Example")
+	fmt.Fprintln(buf, "| -------------------- | ---------- | --------- | --------- | -------")
+	for _, a := range ia.Artifacts {
+		pattern, size := a.Pattern, formatSharedBytes(a.Size)
+		if a.Example == "" {
+			pattern, size = a.Pattern+" trailers", ""
+		}
+		row := fmt.Sprintf(
+			"| %-20s | %-10s | %s | %9s | %s",
+			pattern, a.VCS, formatGrowthValue(counts.Count64(a.Count), &counts.Metric, ""),
+			size, a.Example,
+		)
+		fmt.Fprintln(buf, strings.TrimRight(row, " "))
+	}
+	sizeValue, sizeUnit := counts.Binary.Format(ia.BlobSize, "B")
+	fmt.Fprintf(
+		buf, "\n%d blobs (%s %s) and the log messages of %d commits were probably left\n"+
+			"behind by an import from another version control system. Consider an import\n"+
+			"cleanup pass (e.g., with 'git filter-repo') to remove them.\n",
+		ia.BlobCount, sizeValue, sizeUnit, ia.CommitCount,
+	)
+	return buf.String()
+}
@@ -0,0 +1,116 @@
+package sizes
+
+import (
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/github/git-sizer/counts"
+)
+
+// The following are approximations of how much space Git's index
+// (version 2 or 3) takes, on disk and in memory. They don't account
+// for extensions like the cache tree or for the prefix compression
+// of index version 4.
+const (
+	// indexFixedSize is the size of the index header and trailer.
+	indexFixedSize = 12 + 20
+
+	// indexEntryOverhead is the size of an index entry not counting
+	// its path: 62 bytes of fixed-size fields, plus the path's NUL
+	// terminator and an average of 4 bytes of padding.
+	indexEntryOverhead = 67
+
+	// indexMemoryEntryOverhead is the size of Git's in-memory
+	// representation of an index entry, not counting its path,
+	// including the entry's share of the name hash table.
+	indexMemoryEntryOverhead = 112
+)
+
+// The following are the numbers of index entries at which Git's
+// features for large indexes are recommended.
+const (
+	// manyFilesEntryCount is the number of entries at which
+	// `feature.manyFiles` (index version 4 and the untracked cache)
+	// starts to pay off.
+	manyFilesEntryCount = 100e3
+
+	// sparseIndexEntryCount is the number of entries at which even
+	// an optimized index is slow enough that users should consider
+	// checking out only part of the tree, using a sparse index.
+	sparseIndexEntryCount = 1e6
+)
+
+// IndexEstimate is an estimate of the size of the index for a
+// checkout.
+type IndexEstimate struct {
+	// The number of entries (files, symlinks, and submodules) and
+	// the total length of their paths.
+	EntryCount counts.Count64 `json:"entry_count"`
+	PathLength counts.Count64 `json:"path_length"`
+
+	// The estimated size of the index file, and of the index in
+	// memory.
+	DiskSize   counts.Count64 `json:"disk_size"`
+	MemorySize counts.Count64 `json:"memory_size"`
+
+	// ManyFilesAdvised is set if the checkout is big enough that
+	// `feature.manyFiles` is advisable, and SparseIndexAdvised if it
+	// is big enough that a sparse checkout with a sparse index is
+	// advisable.
+	ManyFilesAdvised   bool `json:"many_files_advised"`
+	SparseIndexAdvised bool `json:"sparse_index_advised"`
+}
+
+// estimateIndexSize returns the estimated size of the index file for
+// a checkout of the tree whose size is `s`.
+func estimateIndexSize(s TreeSize) counts.Count64 {
+	if s.ExpansionLimited {
+		return math.MaxUint64
+	}
+	return counts.Count64(indexFixedSize).
+		Plus(counts.NewCount64(s.expandedIndexEntryCount() * indexEntryOverhead)).
+		Plus(s.ExpandedPathLength)
+}
+
+// estimateIndex fills in `s.IndexEstimate` for the checkout with the
+// largest index, if any.
+func (s *HistorySize) estimateIndex() {
+	ts := s.maxCheckoutIndexTreeSize
+	if s.MaxCheckoutIndexSize == 0 || ts.ExpansionLimited {
+		return
+	}
+
+	entryCount := ts.expandedIndexEntryCount()
+	s.IndexEstimate = &IndexEstimate{
+		EntryCount: counts.NewCount64(entryCount),
+		PathLength: ts.ExpandedPathLength,
+		DiskSize:   s.MaxCheckoutIndexSize,
+		MemorySize: counts.NewCount64(entryCount * indexMemoryEntryOverhead).
+			Plus(ts.ExpandedPathLength),
+		ManyFilesAdvised:   entryCount >= manyFilesEntryCount,
+		SparseIndexAdvised: entryCount >= sparseIndexEntryCount,
+	}
+}
+
+// writeRecommendations writes advice about the index to `w`, if the
+// largest checkout calls for any.
+func (e *IndexEstimate) writeRecommendations(w io.Writer) {
+	if e == nil || !e.ManyFilesAdvised {
+		return
+	}
+
+	entryCount, entryUnit := counts.Metric.Format(e.EntryCount, "")
+	fmt.Fprintf(
+		w, "* The index of the biggest checkout would hold %s%s entries, taking about %s on disk and %s in memory:\n",
+		entryCount, entryUnit, formatBytes(e.DiskSize), formatBytes(e.MemorySize),
+	)
+	fmt.Fprintf(
+		w, "    * set 'feature.manyFiles=true' to use a smaller index format and the untracked cache\n",
+	)
+	if e.SparseIndexAdvised {
+		fmt.Fprintf(
+			w, "    * consider 'git sparse-checkout set --cone' with 'index.sparse=true', or 'core.splitIndex=true'\n",
+		)
+	}
+}
@@ -0,0 +1,96 @@
+package sizes
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// lfsPointerSize is the approximate number of bytes that a Git LFS
+// pointer file (which replaces each blob that is migrated to LFS)
+// occupies in the object database.
+const lfsPointerSize = 130
+
+// LFSMigration estimates how much smaller the object database would
+// become if the files whose blobs are larger than `Cutoff` bytes were
+// migrated to Git LFS throughout the history. Like `git lfs migrate
+// import --above`, this considers each blob separately, so the smaller
+// versions of the same files are not counted.
+type LFSMigration struct {
+	Cutoff counts.Count32 `json:"cutoff"`
+
+	// BlobCount and BlobSize are the number and total (uncompressed)
+	// size of the unique blobs that qualify, and DiskSize is the
+	// number of bytes that they occupy in the object database.
+	BlobCount counts.Count32 `json:"blob_count"`
+	BlobSize  counts.Count64 `json:"blob_size"`
+	DiskSize  counts.Count64 `json:"disk_size"`
+
+	// Savings is the estimated number of bytes that the migration
+	// would remove from the object database; i.e., `DiskSize` minus
+	// the size of the pointer files that would replace the blobs.
+	Savings counts.Count64 `json:"savings"`
+}
+
+// recordLFSCandidate remembers the blob `oid` if it is big enough to
+// be counted in the LFS migration estimate. The caller must hold
+// `g.historyLock`.
+func (g *Graph) recordLFSCandidate(oid git.OID, size counts.Count32) {
+	if g.lfsCutoff == 0 || size <= g.lfsCutoff || !g.countsTowardTotals(oid) {
+		return
+	}
+	g.lfsBlobs = append(g.lfsBlobs, oid)
+	g.lfsBlobSize.Increment(counts.Count64(size))
+}
+
+// estimateLFSMigration fills in `s.LFSMigration` using the blobs
+// recorded by `recordLFSCandidate()`, looking up how much space they
+// occupy in `repo`'s object database.
+func (s *HistorySize) estimateLFSMigration(ctx context.Context, repo *git.Repository, g *Graph) error {
+	diskSize, err := repo.ObjectsDiskSize(ctx, g.lfsBlobs)
+	if err != nil {
+		return err
+	}
+
+	m := LFSMigration{
+		Cutoff:    g.lfsCutoff,
+		BlobCount: counts.NewCount32(uint64(len(g.lfsBlobs))),
+		BlobSize:  g.lfsBlobSize,
+		DiskSize:  diskSize,
+	}
+	if pointers := uint64(len(g.lfsBlobs)) * lfsPointerSize; uint64(diskSize) > pointers {
+		m.Savings = counts.NewCount64(uint64(diskSize) - pointers)
+	}
+	s.LFSMigration = &m
+	return nil
+}
+
+// writeRecommendations writes the estimated savings of migrating the
+// big blobs to Git LFS to `w`, if there are any.
+func (m *LFSMigration) writeRecommendations(w io.Writer, reachableDiskSize counts.Count64) {
+	if m == nil || m.BlobCount == 0 {
+		return
+	}
+
+	cutoff, cutoffUnit := counts.Binary.Format(m.Cutoff, "B")
+	blobCount, blobUnit := counts.Metric.Format(m.BlobCount, "")
+	share := ""
+	if reachableDiskSize != 0 {
+		share = fmt.Sprintf(" (%.0f%%)", 100*float64(m.Savings)/float64(reachableDiskSize))
+	}
+	fmt.Fprintf(
+		w, "* Migrating the files whose blobs are larger than %s %s to Git LFS throughout the history would save about %s%s:\n",
+		cutoff, cutoffUnit, formatBytes(m.Savings), share,
+	)
+	fmt.Fprintf(
+		w, "    * removing %s%s blobs (%s, %s on disk), replaced by small pointer files\n",
+		blobCount, blobUnit, formatBytes(m.BlobSize), formatBytes(m.DiskSize),
+	)
+	fmt.Fprintf(
+		w, "    * e.g., 'git lfs migrate import --everything --above=%d' (this rewrites the history)\n",
+		m.Cutoff,
+	)
+}
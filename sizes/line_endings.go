@@ -0,0 +1,229 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"sort"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// binaryCheckSize is the number of bytes at the start of a blob that
+// are checked for NUL bytes to decide whether it is binary. This is
+// the same heuristic that Git uses.
+const binaryCheckSize = 8000
+
+// LineEndingDuplicate is one version of a text file whose contents
+// only differ from those of other versions by their line endings.
+type LineEndingDuplicate struct {
+	Blob git.OID `json:"blob"`
+
+	// Name is the name of the blob, if names were requested.
+	Name *Path `json:"name,omitempty"`
+
+	// The size of the blob.
+	Size counts.Count32 `json:"size"`
+
+	// CRLFCount is the number of lines in the blob that end in CRLF.
+	CRLFCount counts.Count64 `json:"crlf_count"`
+}
+
+// LineEndingDuplicates is a set of large text blobs that are
+// identical once CRLF line endings are converted to LF. Such blobs
+// are usually committed from different platforms to a repository
+// that doesn't say, in `.gitattributes`, how line endings should be
+// normalized.
+type LineEndingDuplicates struct {
+	// Blobs are the versions, largest first.
+	Blobs []LineEndingDuplicate `json:"blobs"`
+
+	// WastedSize is the total size of the versions, other than the
+	// smallest one, which would not have been stored if the line
+	// endings had been normalized.
+	WastedSize counts.Count64 `json:"wasted_size"`
+}
+
+// errBinaryBlob is returned by `lineEndingHasher` to stop reading a
+// blob once it is known to be binary.
+var errBinaryBlob = errors.New("blob is binary")
+
+// lineEndingHasher is an `io.Writer` that computes the hash of the
+// data written to it after CRLF line endings are converted to LF.
+type lineEndingHasher struct {
+	hash hash.Hash
+
+	// checked is the number of bytes that have been checked for NUL
+	// bytes.
+	checked int
+
+	// pendingCR is set if the last byte written was a CR, which
+	// hasn't been hashed yet because it might be followed by a LF.
+	pendingCR bool
+
+	crlfCount counts.Count64
+}
+
+func newLineEndingHasher() *lineEndingHasher {
+	return &lineEndingHasher{hash: sha256.New()}
+}
+
+func (h *lineEndingHasher) Write(p []byte) (int, error) {
+	n := len(p)
+
+	if h.checked < binaryCheckSize {
+		check := p
+		if len(check) > binaryCheckSize-h.checked {
+			check = check[:binaryCheckSize-h.checked]
+		}
+		if bytes.IndexByte(check, 0) != -1 {
+			return 0, errBinaryBlob
+		}
+		h.checked += len(check)
+	}
+
+	if h.pendingCR {
+		h.pendingCR = false
+		if len(p) > 0 && p[0] == '\n' {
+			h.crlfCount.Increment(1)
+		} else {
+			h.hash.Write([]byte{'\r'})
+		}
+	}
+
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\r')
+		if i == -1 {
+			h.hash.Write(p)
+			break
+		}
+		h.hash.Write(p[:i])
+		switch {
+		case i+1 == len(p):
+			h.pendingCR = true
+		case p[i+1] == '\n':
+			h.crlfCount.Increment(1)
+		default:
+			h.hash.Write(p[i : i+1])
+		}
+		p = p[i+1:]
+	}
+
+	return n, nil
+}
+
+// sum returns the hash of the normalized data.
+func (h *lineEndingHasher) sum() [sha256.Size]byte {
+	if h.pendingCR {
+		h.hash.Write([]byte{'\r'})
+		h.pendingCR = false
+	}
+	var sum [sha256.Size]byte
+	copy(sum[:], h.hash.Sum(nil))
+	return sum
+}
+
+// findLineEndingDuplicates looks for text blobs among `blobs` that
+// only differ by their line endings, and stores the sets that it
+// finds in `s.LineEndingDuplicates`, most wasteful first.
+func (s *HistorySize) findLineEndingDuplicates(
+	ctx context.Context, repo *git.Repository, blobs []largeBlob, progressMeter meter.Progress,
+) error {
+	s.LineEndingDuplicates = []LineEndingDuplicates{}
+
+	sets := make(map[[sha256.Size]byte]*LineEndingDuplicates)
+	var order [][sha256.Size]byte
+
+	progressMeter.Start("Normalizing line endings of large blobs: %d")
+	meter.SetTotal(progressMeter, int64(len(blobs)))
+	for _, blob := range blobs {
+		progressMeter.Inc()
+		h := newLineEndingHasher()
+		if err := repo.CopyBlob(ctx, blob.oid, h); err != nil {
+			if errors.Is(err, errBinaryBlob) {
+				continue
+			}
+			progressMeter.Done()
+			return err
+		}
+
+		sum := h.sum()
+		set, ok := sets[sum]
+		if !ok {
+			set = &LineEndingDuplicates{}
+			sets[sum] = set
+			order = append(order, sum)
+		}
+		set.Blobs = append(set.Blobs, LineEndingDuplicate{
+			Blob:      blob.oid,
+			Name:      blob.path,
+			Size:      blob.size,
+			CRLFCount: h.crlfCount,
+		})
+	}
+	progressMeter.Done()
+
+	for _, sum := range order {
+		set := sets[sum]
+		if len(set.Blobs) < 2 {
+			continue
+		}
+		// `blobs` is sorted largest first, so the last version is
+		// the smallest:
+		for _, b := range set.Blobs[:len(set.Blobs)-1] {
+			set.WastedSize.Increment(counts.Count64(b.Size))
+		}
+		s.LineEndingDuplicates = append(s.LineEndingDuplicates, *set)
+	}
+	sort.SliceStable(s.LineEndingDuplicates, func(i, j int) bool {
+		return s.LineEndingDuplicates[i].WastedSize > s.LineEndingDuplicates[j].WastedSize
+	})
+
+	return nil
+}
+
+// LineEndingDuplicatesTableString returns a table listing the large
+// text blobs that only differ by their line endings, or the empty
+// string if none were found or the search wasn't requested.
+func (s *HistorySize) LineEndingDuplicatesTableString() string {
+	if len(s.LineEndingDuplicates) == 0 {
+		return ""
+	}
+
+	var total counts.Count64
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nLarge text blobs that only differ by their line endings:\n\n")
+	fmt.Fprintln(buf, "| Size      | CRLFs     | Blob")
+	fmt.Fprintln(buf, "| --------- | --------- | ----")
+	for i, set := range s.LineEndingDuplicates {
+		if i > 0 {
+			fmt.Fprintln(buf, "|           |           |")
+		}
+		for _, b := range set.Blobs {
+			name := b.Blob.String()
+			if b.Name != nil {
+				name = b.Name.BestPath()
+			}
+			crlfs, unit := counts.Metric.Format(b.CRLFCount, "")
+			fmt.Fprintf(
+				buf, "| %s | %5s %-3s | %s\n",
+				formatSharedBytes(counts.Count64(b.Size)), crlfs, unit, name,
+			)
+		}
+		total.Increment(set.WastedSize)
+	}
+	fmt.Fprintf(
+		buf,
+		"\nThese duplicates waste %s. Set the 'text' and 'eol' attributes\n"+
+			"in '.gitattributes' (e.g., '* text=auto') so that line endings\n"+
+			"are normalized when files are committed.\n",
+		strings.TrimSpace(formatSharedBytes(total)),
+	)
+	return buf.String()
+}
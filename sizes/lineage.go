@@ -0,0 +1,379 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// FileLineage is the history of the file that holds one of the
+// largest blobs, followed across renames, with the total size of all
+// of the versions of the file. A large file that has been moved to
+// another directory is thereby reported once, rather than as several
+// files each with part of the cost.
+type FileLineage struct {
+	// Blob is the large blob whose file's history was followed, and
+	// Name is where it was found.
+	Blob git.OID `json:"blob"`
+	Name *Path   `json:"name,omitempty"`
+
+	// Paths are the names that the file has had, most recent first.
+	Paths []string `json:"paths"`
+
+	// VersionCount is the number of distinct blobs that the file
+	// has held, and TotalSize is their total size.
+	VersionCount counts.Count32 `json:"version_count"`
+	TotalSize    counts.Count64 `json:"total_size"`
+}
+
+// fileName returns the commit in which `p` was found, and the
+// filename of `p` in that commit. It returns false if `p` isn't a
+// blob or tree that was found in a commit's tree.
+func (p *Path) fileName() (git.OID, string, bool) {
+	var components []string
+	for p.objectType == "blob" || p.objectType == "tree" {
+		switch {
+		case p.parent == nil:
+			return git.NullOID, "", false
+		case p.relativePath == "":
+			// `p` is a top-level tree.
+			if p.parent.objectType != "commit" || len(components) == 0 {
+				return git.NullOID, "", false
+			}
+			for i, j := 0, len(components)-1; i < j; i, j = i+1, j-1 {
+				components[i], components[j] = components[j], components[i]
+			}
+			return p.parent.OID, strings.Join(components, "/"), true
+		default:
+			components = append(components, p.relativePath)
+			p = p.parent
+		}
+	}
+	return git.NullOID, "", false
+}
+
+// traceFileLineage follows the history of the files that hold
+// `blobs` (which must be sorted largest first) across renames, and
+// stores the results in `s.FileLineage`. Blobs that are versions of a
+// file whose history has already been followed are skipped. The sizes
+// of the versions are looked up in `g`, so only versions that were
+// scanned are counted. If `bloomFilters` is set, the histories are
+// followed by `followFilePathLimited()`, which lets Git use the
+// changed-path Bloom filters in the commit-graph.
+func (s *HistorySize) traceFileLineage(
+	ctx context.Context, repo *git.Repository, g *Graph, blobs []largeBlob,
+	bloomFilters bool, progressMeter meter.Progress,
+) error {
+	follow := followFile
+	if bloomFilters {
+		follow = followFilePathLimited
+	}
+
+	s.FileLineage = []FileLineage{}
+
+	// seen is the set of blobs that are versions of a file whose
+	// history has already been followed.
+	seen := make(map[git.OID]struct{})
+
+	progressMeter.Start("Following the histories of large files: %d")
+	meter.SetTotal(progressMeter, int64(len(blobs)))
+	defer progressMeter.Done()
+	for _, blob := range blobs {
+		progressMeter.Inc()
+		if _, ok := seen[blob.oid]; ok {
+			continue
+		}
+		if blob.path == nil {
+			continue
+		}
+		commit, name, ok := blob.path.fileName()
+		if !ok {
+			continue
+		}
+
+		versions, paths, err := follow(ctx, repo, commit, name)
+		if err != nil {
+			return err
+		}
+		// `git log --follow` doesn't list the blob if the file wasn't
+		// changed in `commit`:
+		versions = append(versions, blob.oid)
+
+		lineage := FileLineage{
+			Blob:  blob.oid,
+			Name:  blob.path,
+			Paths: paths,
+		}
+		g.blobLock.Lock()
+		for _, oid := range versions {
+			if _, ok := seen[oid]; ok {
+				continue
+			}
+			seen[oid] = struct{}{}
+			size, ok := g.blobSizes[oid]
+			if !ok {
+				continue
+			}
+			lineage.VersionCount.Increment(1)
+			lineage.TotalSize.Increment(counts.Count64(size.Size))
+		}
+		g.blobLock.Unlock()
+
+		s.FileLineage = append(s.FileLineage, lineage)
+	}
+
+	return nil
+}
+
+// followFile runs `git log --follow` to list the blobs that the file
+// `name` held in `commit` and its ancestors, and the names that it
+// had, most recent first.
+func followFile(
+	ctx context.Context, repo *git.Repository, commit git.OID, name string,
+) ([]git.OID, []string, error) {
+	cmd := repo.GitCommandContext(
+		ctx, "--literal-pathspecs", "log", "--follow", "--no-abbrev", "--raw", "-z",
+		"--pretty=format:", commit.String(), "--", name,
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, nil, fmt.Errorf("following the history of '%s': %w", name, err)
+	}
+
+	var versions []git.OID
+	paths := []string{name}
+	seenPaths := map[string]bool{name: true}
+
+	// The output consists of NUL-terminated fields. Each change
+	// consists of a header like ":100644 100644 <old> <new> R100",
+	// followed by one path, or two for renames and copies:
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i < len(fields); i++ {
+		header := strings.TrimLeft(fields[i], "\n")
+		if !strings.HasPrefix(header, ":") {
+			continue
+		}
+		words := strings.Fields(header[1:])
+		if len(words) != 5 {
+			return nil, nil, fmt.Errorf("unexpected output from 'git log': %q", header)
+		}
+		for _, word := range words[2:4] {
+			oid, err := git.NewOID(word)
+			if err != nil {
+				return nil, nil, fmt.Errorf("unexpected output from 'git log': %q", header)
+			}
+			if oid != git.NullOID {
+				versions = append(versions, oid)
+			}
+		}
+
+		pathCount := 1
+		if words[4][0] == 'R' || words[4][0] == 'C' {
+			pathCount = 2
+		}
+		for ; pathCount > 0 && i+1 < len(fields); pathCount-- {
+			i++
+			if !seenPaths[fields[i]] {
+				seenPaths[fields[i]] = true
+				paths = append(paths, fields[i])
+			}
+		}
+	}
+
+	return versions, paths, nil
+}
+
+// followFilePathLimited lists the same blobs and names as
+// `followFile()`, but without `git log --follow`, which can't use
+// changed-path Bloom filters. Instead, it runs a path-limited `git
+// log` for each name that the file has had, which can skip the
+// commits whose Bloom filters show that they didn't touch the name,
+// and only looks for renames (with `git diff-tree -M`) in the oldest
+// commit that added the file under that name.
+func followFilePathLimited(
+	ctx context.Context, repo *git.Repository, commit git.OID, name string,
+) ([]git.OID, []string, error) {
+	var versions []git.OID
+	paths := []string{name}
+	seenPaths := map[string]bool{name: true}
+
+	rev := commit.String()
+	for {
+		changes, err := pathChanges(ctx, repo, rev, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(changes) == 0 {
+			break
+		}
+		for _, change := range changes {
+			for _, oid := range []git.OID{change.oldOID, change.newOID} {
+				if oid != git.NullOID {
+					versions = append(versions, oid)
+				}
+			}
+		}
+
+		// The changes are listed newest first. If the oldest one
+		// added the file, it might have been renamed from another
+		// name:
+		oldest := changes[len(changes)-1]
+		if oldest.status != 'A' {
+			break
+		}
+		oldName, ok, err := renameSource(ctx, repo, oldest.commit, name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !ok || seenPaths[oldName] {
+			break
+		}
+		seenPaths[oldName] = true
+		paths = append(paths, oldName)
+		name = oldName
+		rev = oldest.commit.String() + "^"
+	}
+
+	return versions, paths, nil
+}
+
+// pathChange is a change that a commit made to a file.
+type pathChange struct {
+	commit         git.OID
+	oldOID, newOID git.OID
+	status         byte
+}
+
+// pathChanges runs a path-limited `git log` to list the changes to
+// the file `name` made by `rev` and its ancestors, newest first.
+func pathChanges(
+	ctx context.Context, repo *git.Repository, rev, name string,
+) ([]pathChange, error) {
+	cmd := repo.GitCommandContext(
+		ctx, "--literal-pathspecs", "log", "--no-renames", "--no-abbrev", "--raw", "-z",
+		"--root", "--pretty=format:%H", rev, "--", name,
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("listing the changes to '%s': %w", name, err)
+	}
+
+	// The output consists of NUL-terminated fields. Each commit
+	// starts with its name, followed by an LF and the header of its
+	// first change, like ":000000 100644 <old> <new> A". Each header
+	// is followed by the path that it applies to:
+	var changes []pathChange
+	var commit git.OID
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i < len(fields); i++ {
+		header := fields[i]
+		if j := strings.LastIndexByte(header, '\n'); j >= 0 {
+			if name := strings.Trim(header[:j], "\n"); name != "" {
+				oid, err := git.NewOID(name)
+				if err != nil {
+					return nil, fmt.Errorf("unexpected output from 'git log': %q", header)
+				}
+				commit = oid
+			}
+			header = header[j+1:]
+		}
+		if !strings.HasPrefix(header, ":") {
+			continue
+		}
+		words := strings.Fields(header[1:])
+		if len(words) != 5 || i+1 >= len(fields) {
+			return nil, fmt.Errorf("unexpected output from 'git log': %q", header)
+		}
+		i++
+		oldOID, err := git.NewOID(words[2])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected output from 'git log': %q", header)
+		}
+		newOID, err := git.NewOID(words[3])
+		if err != nil {
+			return nil, fmt.Errorf("unexpected output from 'git log': %q", header)
+		}
+		changes = append(changes, pathChange{
+			commit: commit,
+			oldOID: oldOID,
+			newOID: newOID,
+			status: words[4][0],
+		})
+	}
+
+	return changes, nil
+}
+
+// renameSource returns the name of the file that `commit` renamed to
+// `name` (compared with its first parent), or false if it didn't
+// rename any file to `name`.
+func renameSource(
+	ctx context.Context, repo *git.Repository, commit git.OID, name string,
+) (string, bool, error) {
+	cmd := repo.GitCommandContext(
+		ctx, "diff-tree", "-r", "-M", "--no-abbrev", "-z", "--no-commit-id", "--root",
+		commit.String(),
+	)
+	out, err := cmd.Output()
+	if err != nil {
+		return "", false, fmt.Errorf("looking for renames in %s: %w", commit, err)
+	}
+
+	// Each change is a header like ":100644 100644 <old> <new> R100",
+	// followed by one path, or two for renames and copies:
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i < len(fields); i++ {
+		header := fields[i]
+		if !strings.HasPrefix(header, ":") {
+			continue
+		}
+		words := strings.Fields(header[1:])
+		if len(words) != 5 {
+			return "", false, fmt.Errorf("unexpected output from 'git diff-tree': %q", header)
+		}
+		switch words[4][0] {
+		case 'R', 'C':
+			if i+2 >= len(fields) {
+				return "", false, fmt.Errorf("unexpected output from 'git diff-tree': %q", header)
+			}
+			if words[4][0] == 'R' && fields[i+2] == name {
+				return fields[i+1], true, nil
+			}
+			i += 2
+		default:
+			i++
+		}
+	}
+
+	return "", false, nil
+}
+
+// FileLineageTableString returns a table showing the histories of the
+// files that hold the largest blobs, or the empty string if they
+// weren't requested.
+func (s *HistorySize) FileLineageTableString() string {
+	if len(s.FileLineage) == 0 {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nHistories of the files holding the largest blobs (following renames):\n\n")
+	fmt.Fprintln(buf, "| Total size | Versions | File")
+	fmt.Fprintln(buf, "| ---------- | -------- | ----")
+	for _, l := range s.FileLineage {
+		name := l.Paths[0]
+		if len(l.Paths) > 1 {
+			name += " (formerly " + strings.Join(l.Paths[1:], ", ") + ")"
+		}
+		fmt.Fprintf(
+			buf, "| %s  | %8d | %s\n",
+			formatSharedBytes(l.TotalSize), l.VersionCount, name,
+		)
+	}
+	return buf.String()
+}
@@ -0,0 +1,84 @@
+package sizes
+
+import (
+	"sync"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// LiveStats gives access to some of the statistics of a scan while
+// it is still running; e.g., to display them in a user interface.
+// Pass a `*LiveStats` to the scan via `ScanOptions.Live`, and call
+// its `Snapshot()` method from another goroutine. The zero value is
+// ready to use.
+type LiveStats struct {
+	lock  sync.Mutex
+	graph *Graph
+}
+
+// LiveSnapshot holds the values of some statistics at one moment
+// during a scan. The values are those of the objects that have been
+// processed so far. The biggest objects are identified only by their
+// OIDs, because their paths aren't known until later.
+type LiveSnapshot struct {
+	UniqueCommitCount counts.Count32
+	UniqueTreeCount   counts.Count32
+	UniqueBlobCount   counts.Count32
+	UniqueBlobSize    counts.Count64
+	UniqueTagCount    counts.Count32
+
+	MaxBlobSize         counts.Count32
+	MaxBlobSizeBlob     git.OID
+	MaxTreeEntries      counts.Count32
+	MaxTreeEntriesTree  git.OID
+	MaxCommitSize       counts.Count32
+	MaxCommitSizeCommit git.OID
+}
+
+// setGraph makes `l` report on the scan that is using `g`.
+func (l *LiveStats) setGraph(g *Graph) {
+	if l == nil {
+		return
+	}
+	l.lock.Lock()
+	l.graph = g
+	l.lock.Unlock()
+}
+
+// Snapshot returns the current values of the statistics. The second
+// return value is false if the scan hasn't started yet.
+func (l *LiveStats) Snapshot() (LiveSnapshot, bool) {
+	l.lock.Lock()
+	g := l.graph
+	l.lock.Unlock()
+	if g == nil {
+		return LiveSnapshot{}, false
+	}
+
+	g.historyLock.Lock()
+	defer g.historyLock.Unlock()
+	s := &g.historySize
+	return LiveSnapshot{
+		UniqueCommitCount:   s.UniqueCommitCount,
+		UniqueTreeCount:     s.UniqueTreeCount,
+		UniqueBlobCount:     s.UniqueBlobCount,
+		UniqueBlobSize:      s.UniqueBlobSize,
+		UniqueTagCount:      s.UniqueTagCount,
+		MaxBlobSize:         s.MaxBlobSize,
+		MaxBlobSizeBlob:     pathOID(s.MaxBlobSizeBlob),
+		MaxTreeEntries:      s.MaxTreeEntries,
+		MaxTreeEntriesTree:  pathOID(s.MaxTreeEntriesTree),
+		MaxCommitSize:       s.MaxCommitSize,
+		MaxCommitSizeCommit: pathOID(s.MaxCommitSizeCommit),
+	}, true
+}
+
+// pathOID returns the OID of the object whose path is `p`, or the
+// null OID if `p` is nil.
+func pathOID(p *Path) git.OID {
+	if p == nil {
+		return git.NullOID
+	}
+	return p.OID
+}
@@ -0,0 +1,191 @@
+package sizes
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// lockfileNames are the names of the lockfiles that package managers
+// generate and rewrite whenever a dependency changes. Committing them
+// is usually right, but each rewrite adds a new, often large, version
+// of the file (and of every tree above it) to the history.
+var lockfileNames = map[string]struct{}{
+	"package-lock.json":   {},
+	"npm-shrinkwrap.json": {},
+	"yarn.lock":           {},
+	"pnpm-lock.yaml":      {},
+	"bun.lockb":           {},
+	"go.sum":              {},
+	"Cargo.lock":          {},
+	"Gemfile.lock":        {},
+	"composer.lock":       {},
+	"poetry.lock":         {},
+	"Pipfile.lock":        {},
+	"uv.lock":             {},
+	"Podfile.lock":        {},
+	"packages.lock.json":  {},
+	"pubspec.lock":        {},
+	"mix.lock":            {},
+	"flake.lock":          {},
+	"gradle.lockfile":     {},
+}
+
+// lockfileStats are the symbols of the lockfile statistics. Lockfiles
+// are only tracked if at least one of them is selected.
+var lockfileStats = []string{
+	"lockfileVersionCount",
+	"uniqueLockfileSize",
+	"maxLockfileSize",
+}
+
+// isLockfile reports whether a tree entry called `name` is a
+// generated lockfile.
+func isLockfile(name string) bool {
+	_, ok := lockfileNames[name]
+	return ok
+}
+
+// LockfileChurn describes the distinct versions of the lockfiles
+// with one name (e.g., "package-lock.json"), wherever they are in the
+// tree.
+type LockfileChurn struct {
+	Name string `json:"name"`
+
+	// The number of distinct versions, and their total size. A blob
+	// that is used as lockfiles with different names counts only
+	// toward the first of them that is seen.
+	VersionCount counts.Count32 `json:"version_count"`
+	UniqueSize   counts.Count64 `json:"unique_size"`
+
+	// The size of the largest version, and that version.
+	MaxSize     counts.Count32 `json:"max_size"`
+	MaxSizeBlob *Path          `json:"max_size_blob,omitempty"`
+}
+
+// recordLockfile records that the tree with the specified `oid` has
+// an entry called `name`, referring to the blob `blobOID` of the
+// specified `size`, which is a lockfile. Each blob counts toward the
+// totals only once. The caller must hold `g.historyLock`.
+func (g *Graph) recordLockfile(oid git.OID, name string, blobOID git.OID, size BlobSize) {
+	s := &g.historySize
+
+	lockfile, ok := g.lockfiles[name]
+	if !ok {
+		if g.lockfiles == nil {
+			g.lockfiles = make(map[string]*LockfileChurn)
+		}
+		lockfile = &LockfileChurn{Name: name}
+		g.lockfiles[name] = lockfile
+	}
+
+	if _, ok := g.lockfileBlobs[blobOID]; !ok && g.countsTowardTotals(blobOID) {
+		if g.lockfileBlobs == nil {
+			g.lockfileBlobs = make(map[git.OID]struct{})
+		}
+		g.lockfileBlobs[blobOID] = struct{}{}
+		lockfile.VersionCount.Increment(1)
+		lockfile.UniqueSize.Increment(counts.Count64(size.Size))
+		s.LockfileVersionCount.Increment(1)
+		s.UniqueLockfileSize.Increment(counts.Count64(size.Size))
+	}
+
+	if !g.countsTowardMaxima(blobOID) {
+		return
+	}
+	if lockfile.MaxSize.AdjustMaxIfNecessary(size.Size) {
+		if lockfile.MaxSizeBlob != nil {
+			g.pathResolver.ForgetPath(lockfile.MaxSizeBlob)
+		}
+		lockfile.MaxSizeBlob = g.pathResolver.RequestEntryPath(oid, name, blobOID, "blob")
+	}
+	if s.MaxLockfileSize.AdjustMaxIfNecessary(size.Size) {
+		if s.MaxLockfileSizeBlob != nil {
+			g.pathResolver.ForgetPath(s.MaxLockfileSizeBlob)
+		}
+		s.MaxLockfileSizeBlob = g.pathResolver.RequestEntryPath(oid, name, blobOID, "blob")
+	}
+	g.recordTopEntry("maxLockfileSize", uint64(size.Size), oid, name, blobOID, "blob")
+}
+
+// lockfileChurn returns the churn of each lockfile name that was
+// seen, with the most total bytes first, or nil if there were none.
+func (g *Graph) lockfileChurn() []LockfileChurn {
+	g.historyLock.Lock()
+	defer g.historyLock.Unlock()
+
+	if len(g.lockfiles) == 0 {
+		return nil
+	}
+
+	churn := make([]LockfileChurn, 0, len(g.lockfiles))
+	for _, lockfile := range g.lockfiles {
+		c := *lockfile
+		c.Name = g.historySize.anonymizer.Path(c.Name)
+		churn = append(churn, c)
+	}
+	sort.Slice(churn, func(i, j int) bool {
+		switch {
+		case churn[i].UniqueSize != churn[j].UniqueSize:
+			return churn[i].UniqueSize > churn[j].UniqueSize
+		case churn[i].VersionCount != churn[j].VersionCount:
+			return churn[i].VersionCount > churn[j].VersionCount
+		default:
+			return churn[i].Name < churn[j].Name
+		}
+	})
+	return churn
+}
+
+// LockfilesTableString returns a table breaking the lockfile
+// statistics down by lockfile name. It returns the empty string if
+// the history contains no lockfiles, or if none of the selected
+// lockfile statistics is interesting at `threshold`.
+func (s *HistorySize) LockfilesTableString(threshold Threshold) string {
+	if len(s.Lockfiles) == 0 {
+		return ""
+	}
+
+	interesting := false
+	s.contents(nil).collectDefinitions(nil, func(_ []string, i *item) {
+		for _, symbol := range lockfileStats {
+			if i.symbol != symbol || !s.stats.Contains(symbol) {
+				continue
+			}
+			if _, ok := i.levelOfConcern(threshold); ok {
+				interesting = true
+			}
+		}
+	})
+	if !interesting {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nLockfiles (distinct versions of each, wherever they are in the tree):\n\n")
+	fmt.Fprintln(buf, "| Versions  | Total     | Largest   | Lockfile")
+	fmt.Fprintln(buf, "| --------- | --------- | --------- | --------")
+	for _, lockfile := range s.Lockfiles {
+		versionCount, versionUnit := counts.Metric.Format(lockfile.VersionCount, "")
+		fmt.Fprintf(
+			buf, "| %5s %-3s | %s | %s | %s\n",
+			versionCount, versionUnit,
+			formatSharedBytes(lockfile.UniqueSize),
+			formatSharedBytes(counts.Count64(lockfile.MaxSize)),
+			lockfile.Name,
+		)
+	}
+	fmt.Fprintln(buf)
+	fmt.Fprintln(
+		buf,
+		"Every dependency update adds a new version of a lockfile to the history. If a lockfile",
+	)
+	fmt.Fprintln(
+		buf,
+		"changes much more often than its dependencies do, look for tools that rewrite it needlessly.",
+	)
+	return buf.String()
+}
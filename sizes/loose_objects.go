@@ -0,0 +1,22 @@
+package sizes
+
+import (
+	"context"
+
+	"github.com/github/git-sizer/git"
+)
+
+// countLooseObjects fills in the statistics about the loose objects
+// in `repo`.
+func (s *HistorySize) countLooseObjects(ctx context.Context, repo *git.Repository) error {
+	loose, err := repo.LooseObjects(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.LooseObjectCount = loose.Count
+	s.MaxLooseObjectShardCount = loose.MaxShardCount
+	s.MaxLooseObjectShard = loose.MaxShard
+	s.OldestLooseObjectAge = tipAge(s.ScanTime, loose.OldestModTime)
+	return nil
+}
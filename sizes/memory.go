@@ -0,0 +1,100 @@
+package sizes
+
+import (
+	"unsafe"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// mapEntryBytes returns a rough estimate of the memory taken by an
+// entry in a Go map whose keys and values together take
+// `keyValueSize` bytes, including the map's per-entry overhead (one
+// byte of hash metadata, with the map's buckets about 13/16 full).
+func mapEntryBytes(keyValueSize uintptr) uint64 {
+	return uint64(keyValueSize+1) * 16 / 13
+}
+
+// The following are rough estimates of the memory taken by an entry
+// in each of the maps that a `Graph` maintains during a scan. For
+// entries that point to records, they include the record itself, but
+// not the memory that it refers to, like the listeners of a pending
+// tree or the name of a path.
+var (
+	blobSizeEntryBytes = mapEntryBytes(
+		unsafe.Sizeof(git.OID{}) + unsafe.Sizeof(BlobSize{}),
+	)
+	treeSizeEntryBytes = mapEntryBytes(
+		unsafe.Sizeof(git.OID{}) + unsafe.Sizeof(TreeSize{}),
+	)
+	treeRecordEntryBytes = mapEntryBytes(
+		unsafe.Sizeof(git.OID{})+unsafe.Sizeof(&treeRecord{}),
+	) + uint64(unsafe.Sizeof(treeRecord{}))
+	commitSizeEntryBytes = mapEntryBytes(
+		unsafe.Sizeof(git.OID{}) + unsafe.Sizeof(CommitSize{}),
+	)
+	tagSizeEntryBytes = mapEntryBytes(
+		unsafe.Sizeof(git.OID{}) + unsafe.Sizeof(TagSize{}),
+	)
+	tagRecordEntryBytes = mapEntryBytes(
+		unsafe.Sizeof(git.OID{})+unsafe.Sizeof(&tagRecord{}),
+	) + uint64(unsafe.Sizeof(tagRecord{}))
+	pathEntryBytes = mapEntryBytes(
+		unsafe.Sizeof(git.OID{})+unsafe.Sizeof(&Path{}),
+	) + uint64(unsafe.Sizeof(Path{}))
+)
+
+// soughtPathCounter is implemented by `PathResolver`s that can tell
+// the largest number of objects whose paths they were seeking at any
+// one time.
+type soughtPathCounter interface {
+	maxSoughtPathCount() int
+}
+
+// recordMemoryUsage stores estimates of the high-water marks of the
+// memory taken by `g`'s maps in `s`. The maps of sizes only ever
+// grow, so their high-water marks are their final sizes; the maps of
+// pending records and of sought paths shrink again as the objects
+// are resolved, so their largest sizes are tracked as they change.
+func (g *Graph) recordMemoryUsage(s *HistorySize) {
+	g.blobLock.Lock()
+	blobCount := len(g.blobSizes)
+	g.blobLock.Unlock()
+
+	g.treeLock.Lock()
+	treeCount := len(g.treeSizes)
+	maxTreeRecords := g.maxTreeRecords
+	g.treeLock.Unlock()
+
+	g.commitLock.Lock()
+	commitCount := len(g.commitSizes)
+	g.commitLock.Unlock()
+
+	g.tagLock.Lock()
+	tagCount := len(g.tagSizes)
+	maxTagRecords := g.maxTagRecords
+	g.tagLock.Unlock()
+
+	var maxSoughtPaths int
+	if c, ok := g.pathResolver.(soughtPathCounter); ok {
+		maxSoughtPaths = c.maxSoughtPathCount()
+	}
+
+	s.GraphBlobMemory = counts.NewCount64(uint64(blobCount) * blobSizeEntryBytes)
+	s.GraphTreeMemory = counts.NewCount64(uint64(treeCount) * treeSizeEntryBytes)
+	s.GraphPendingTreeMemory = counts.NewCount64(uint64(maxTreeRecords) * treeRecordEntryBytes)
+	s.GraphCommitMemory = counts.NewCount64(uint64(commitCount) * commitSizeEntryBytes)
+	s.GraphTagMemory = counts.NewCount64(
+		uint64(tagCount)*tagSizeEntryBytes + uint64(maxTagRecords)*tagRecordEntryBytes,
+	)
+	s.GraphPathResolverMemory = counts.NewCount64(uint64(maxSoughtPaths) * pathEntryBytes)
+
+	// The maps don't all peak at the same time, so this is an upper
+	// bound on their combined high-water mark:
+	s.GraphMemory = s.GraphBlobMemory.
+		Plus(s.GraphTreeMemory).
+		Plus(s.GraphPendingTreeMemory).
+		Plus(s.GraphCommitMemory).
+		Plus(s.GraphTagMemory).
+		Plus(s.GraphPathResolverMemory)
+}
@@ -0,0 +1,139 @@
+package sizes
+
+import (
+	"container/heap"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// The flags that `mergeBubbleSize()` paints commits with, according
+// to which parents of the merge they are reachable from.
+const (
+	fromFirstParent uint8 = 1 << iota
+	fromOtherParents
+)
+
+// bubbleCommit is a commit in the queue of `mergeBubbleSize()`.
+type bubbleCommit struct {
+	oid     git.OID
+	depth   counts.Count32
+	parents []git.OID
+}
+
+// bubbleQueue is a max-heap of commits, deepest first. Since each
+// commit is deeper than all of its parents, a commit is only popped
+// after all of its children that are in the queue.
+type bubbleQueue []bubbleCommit
+
+func (q bubbleQueue) Len() int           { return len(q) }
+func (q bubbleQueue) Less(i, j int) bool { return q[i].depth > q[j].depth }
+func (q bubbleQueue) Swap(i, j int)      { q[i], q[j] = q[j], q[i] }
+
+func (q *bubbleQueue) Push(x interface{}) {
+	*q = append(*q, x.(bubbleCommit))
+}
+
+func (q *bubbleQueue) Pop() interface{} {
+	old := *q
+	c := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return c
+}
+
+// mergeBubbleSize returns the number of commits that a merge commit
+// with the specified `parents` merges in; i.e., the commits that are
+// reachable from any of its other parents, but not from its first
+// parent. These are the commits on the merged branches since they
+// forked from the first parent's history. The parents must already
+// have been registered.
+//
+// The history is walked from the parents, deepest commits first,
+// painting each commit with the parents that it is reachable from,
+// until no commit that is reachable only from the other parents
+// remains in the queue. The walk therefore also covers the commits
+// of the first parent's history back to the merge base.
+//
+// `g.commitLock` is only held while each commit is looked up, so that
+// other commits can be registered concurrently. Commits that are
+// registered during the walk can't be ancestors of the merge, so they
+// don't affect the result.
+func (g *Graph) mergeBubbleSize(parents []git.OID) counts.Count32 {
+	lookup := func(oid git.OID) (CommitSize, bool) {
+		g.commitLock.Lock()
+		defer g.commitLock.Unlock()
+		size, ok := g.commitSizes[oid]
+		return size, ok
+	}
+
+	flags := make(map[git.OID]uint8)
+	var queue bubbleQueue
+
+	// The number of commits in `queue` that are painted only with
+	// `fromOtherParents`:
+	var pending int
+
+	paint := func(oid git.OID, flag uint8) {
+		old := flags[oid]
+		if old|flag == old {
+			return
+		}
+		switch {
+		case old == 0:
+			size, ok := lookup(oid)
+			if !ok {
+				return
+			}
+			heap.Push(&queue, bubbleCommit{
+				oid: oid, depth: size.MaxAncestorDepth, parents: size.parents,
+			})
+			if flag == fromOtherParents {
+				pending++
+			}
+		case old == fromOtherParents:
+			// It is reachable from the first parent after all.
+			pending--
+		}
+		flags[oid] = old | flag
+	}
+
+	paint(parents[0], fromFirstParent)
+	for _, parent := range parents[1:] {
+		paint(parent, fromOtherParents)
+	}
+
+	var bubble counts.Count32
+	for pending > 0 {
+		c := heap.Pop(&queue).(bubbleCommit)
+		flag := flags[c.oid]
+		if flag == fromOtherParents {
+			pending--
+			bubble.Increment(1)
+		}
+		for _, parent := range c.parents {
+			paint(parent, flag)
+		}
+	}
+
+	return bubble
+}
+
+// recordMergeBubble records that the merge commit `oid` merged in
+// `bubble` commits (see `Graph.mergeBubbleSize()`).
+func (s *HistorySize) recordMergeBubble(g *Graph, oid git.OID, bubble counts.Count32) {
+	if !g.countsTowardTotals(oid) {
+		return
+	}
+	s.MergeCount.Increment(1)
+	s.MergeBubbleTotal.Increment(counts.Count64(bubble))
+	s.AverageMergeBubble = counts.NewCount32(
+		(uint64(s.MergeBubbleTotal) + uint64(s.MergeCount)/2) / uint64(s.MergeCount),
+	)
+	if !g.countsTowardMaxima(oid) {
+		return
+	}
+	if s.MaxMergeBubble.AdjustMaxIfPossible(bubble) {
+		setPath(g.pathResolver, &s.MaxMergeBubbleCommit, oid, "commit")
+	}
+	g.recordTopObject("maxMergeBubble", uint64(bubble), oid, "commit")
+}
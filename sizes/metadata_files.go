@@ -0,0 +1,114 @@
+package sizes
+
+import (
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// metadataKind is a kind of file that has a special meaning to Git
+// (or to the tools around it), and whose history is tracked
+// separately from that of other blobs.
+type metadataKind uint8
+
+const (
+	metadataGitmodules metadataKind = 1 << iota
+	metadataGitattributes
+	metadataHook
+)
+
+// hookNames are the names of the hooks that Git runs (see
+// githooks(5)). "update" is omitted because it is too common a name
+// for files that have nothing to do with hooks.
+var hookNames = map[string]struct{}{
+	"applypatch-msg":        {},
+	"pre-applypatch":        {},
+	"post-applypatch":       {},
+	"pre-commit":            {},
+	"pre-merge-commit":      {},
+	"prepare-commit-msg":    {},
+	"commit-msg":            {},
+	"post-commit":           {},
+	"pre-rebase":            {},
+	"post-checkout":         {},
+	"post-merge":            {},
+	"pre-push":              {},
+	"pre-receive":           {},
+	"proc-receive":          {},
+	"post-receive":          {},
+	"post-update":           {},
+	"reference-transaction": {},
+	"push-to-checkout":      {},
+	"pre-auto-gc":           {},
+	"post-rewrite":          {},
+	"sendemail-validate":    {},
+	"fsmonitor-watchman":    {},
+	"post-index-change":     {},
+}
+
+// metadataFileKind returns the kind of metadata file that a tree entry
+// called `name` is, or 0 if it isn't one. Hook-like files are those
+// named after a Git hook, optionally with a ".sample" or ".sh"
+// suffix, wherever they are in the tree (e.g., in a ".githooks"
+// directory).
+func metadataFileKind(name string) metadataKind {
+	switch name {
+	case ".gitmodules":
+		return metadataGitmodules
+	case ".gitattributes":
+		return metadataGitattributes
+	}
+
+	base := strings.TrimSuffix(strings.TrimSuffix(name, ".sample"), ".sh")
+	if _, ok := hookNames[base]; ok {
+		return metadataHook
+	}
+	return 0
+}
+
+// recordMetadataFile records that the tree with the specified `oid`
+// has an entry called `name`, referring to the blob `blobOID` of the
+// specified `size`, which is a metadata file of the specified `kind`.
+// Each blob counts toward the total size of each kind only once. The
+// caller must hold `g.historyLock`.
+func (g *Graph) recordMetadataFile(
+	oid git.OID, name string, blobOID git.OID, size BlobSize, kind metadataKind,
+) {
+	s := &g.historySize
+
+	var maxSize *counts.Count32
+	var maxBlob **Path
+	var totalSize *counts.Count64
+	var symbol string
+	switch kind {
+	case metadataGitmodules:
+		maxSize, maxBlob, totalSize = &s.MaxGitmodulesSize, &s.MaxGitmodulesSizeBlob, &s.UniqueGitmodulesSize
+		symbol = "maxGitmodulesSize"
+	case metadataGitattributes:
+		maxSize, maxBlob, totalSize = &s.MaxGitattributesSize, &s.MaxGitattributesSizeBlob, &s.UniqueGitattributesSize
+		symbol = "maxGitattributesSize"
+	default:
+		maxSize, maxBlob, totalSize = &s.MaxHookFileSize, &s.MaxHookFileSizeBlob, &s.UniqueHookFileSize
+		symbol = "maxHookFileSize"
+	}
+
+	if g.countsTowardTotals(blobOID) && g.metadataBlobs[blobOID]&kind == 0 {
+		if g.metadataBlobs == nil {
+			g.metadataBlobs = make(map[git.OID]metadataKind)
+		}
+		g.metadataBlobs[blobOID] |= kind
+		totalSize.Increment(counts.Count64(size.Size))
+	}
+
+	if !g.countsTowardMaxima(blobOID) {
+		return
+	}
+	if maxSize.AdjustMaxIfNecessary(size.Size) {
+		if *maxBlob != nil {
+			g.pathResolver.ForgetPath(*maxBlob)
+		}
+		*maxBlob = g.pathResolver.RequestEntryPath(oid, name, blobOID, "blob")
+	}
+	g.recordTopEntry(symbol, uint64(size.Size), oid, name, blobOID, "blob")
+}
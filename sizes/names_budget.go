@@ -0,0 +1,138 @@
+package sizes
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+)
+
+// NamesBudget limits the work that an `InOrderPathResolver` does to
+// name the objects that the output cites. Naming an object means
+// remembering it until a tree, commit, or reference that refers to it
+// is found, then doing the same for that referrer, and so on; in a
+// giant repository, that can take noticeable time and memory. When
+// the budget is exceeded, objects are named by their OIDs instead
+// (or, if part of the path was already found, relative to a tree's
+// OID, like "<tree>:dir/file"). The zero value sets no limits.
+type NamesBudget struct {
+	// MaxObjects, if nonzero, is the most objects whose referrers
+	// are sought at any one time.
+	MaxObjects int `json:"max_objects,omitempty"`
+
+	// MaxChainLength, if nonzero, is the most objects along the
+	// path of an object, including the object itself (e.g., 3 for a
+	// blob in the top-level tree of a commit).
+	MaxChainLength int `json:"max_chain_length,omitempty"`
+}
+
+// String returns `b` in the form accepted by `Set()`.
+func (b *NamesBudget) String() string {
+	if b == nil || *b == (NamesBudget{}) {
+		return "0"
+	}
+	if b.MaxChainLength == 0 {
+		return strconv.Itoa(b.MaxObjects)
+	}
+	return fmt.Sprintf("%d,%d", b.MaxObjects, b.MaxChainLength)
+}
+
+// Set parses `s`, which has the form "<objects>[,<chain length>]",
+// into `b`. Either number can be 0, meaning no limit.
+func (b *NamesBudget) Set(s string) error {
+	objects, chain := s, ""
+	if i := strings.IndexByte(s, ','); i >= 0 {
+		objects, chain = s[:i], s[i+1:]
+	}
+
+	var budget NamesBudget
+	n, err := strconv.ParseUint(objects, 10, 31)
+	if err != nil {
+		return fmt.Errorf("not a valid names budget (expected '<objects>[,<chain length>]'): %q", s)
+	}
+	budget.MaxObjects = int(n)
+	if chain != "" {
+		n, err := strconv.ParseUint(chain, 10, 16)
+		if err != nil {
+			return fmt.Errorf("not a valid names budget (expected '<objects>[,<chain length>]'): %q", s)
+		}
+		budget.MaxChainLength = int(n)
+	}
+
+	*b = budget
+	return nil
+}
+
+func (b *NamesBudget) Type() string {
+	return "namesBudget"
+}
+
+// allowsChain reports whether a chain of `length` objects fits in
+// `b`.
+func (b NamesBudget) allowsChain(length int) bool {
+	return b.MaxChainLength == 0 || length <= b.MaxChainLength
+}
+
+// chainLengthOf returns `n` in the form stored in `Path.chainLength`.
+func chainLengthOf(n int) uint16 {
+	if n > math.MaxUint16 {
+		return math.MaxUint16
+	}
+	return uint16(n)
+}
+
+// NamesBudgetUsage records that the `NamesBudget` limited the naming
+// of the objects that the output cites.
+type NamesBudgetUsage struct {
+	NamesBudget
+
+	// LimitedCount is the number of times that the search for an
+	// object's path was cut short because the budget was exceeded.
+	LimitedCount counts.Count64 `json:"limited_count"`
+}
+
+// namesBudgetLimiter is implemented by `PathResolver`s that can tell
+// how often their `NamesBudget` cut the search for a path short.
+type namesBudgetLimiter interface {
+	namesBudgetLimitedCount() int
+}
+
+// recordNamesBudget stores in `s` whether (and how often) `budget`
+// limited the naming of objects. `s.NamesBudget` is left nil if it
+// didn't.
+func (g *Graph) recordNamesBudget(s *HistorySize, budget NamesBudget) {
+	l, ok := g.pathResolver.(namesBudgetLimiter)
+	if !ok {
+		return
+	}
+	n := l.namesBudgetLimitedCount()
+	if n == 0 {
+		return
+	}
+	s.NamesBudget = &NamesBudgetUsage{
+		NamesBudget:  budget,
+		LimitedCount: counts.NewCount64(uint64(n)),
+	}
+}
+
+// namesBudgetString returns a note explaining that some objects in
+// the table output aren't fully named because the budget was
+// exceeded, or the empty string if it wasn't.
+func (s *HistorySize) namesBudgetString() string {
+	u := s.NamesBudget
+	if u == nil {
+		return ""
+	}
+	times := "once"
+	if u.LimitedCount != 1 {
+		times = fmt.Sprintf("%d times", u.LimitedCount)
+	}
+	return fmt.Sprintf(
+		"\nThe search for the names of the objects above was cut short %s, because\n"+
+			"it exceeded the names budget (--names-budget=%s), so some objects may be named\n"+
+			"only by their OIDs. Raise the budget to see their full names.\n",
+		times, u.NamesBudget.String(),
+	)
+}
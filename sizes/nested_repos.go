@@ -0,0 +1,48 @@
+package sizes
+
+import (
+	"strings"
+)
+
+// nestedRepository keeps track of the entries in a tree that suggest
+// that a Git repository was committed into it as a regular directory,
+// rather than as a submodule. The zero value is ready to use.
+type nestedRepository struct {
+	// dotGit is set if the tree has an entry called `.git` (in any
+	// case), which Git refuses to check out.
+	dotGit bool
+
+	// The following are set if the tree has the corresponding parts
+	// of the layout of a (bare) Git repository.
+	head    bool
+	objects bool
+	refs    bool
+}
+
+// add records the entry called `name` whose mode is `filemode`.
+func (n *nestedRepository) add(name string, filemode uint) {
+	if strings.EqualFold(name, ".git") {
+		n.dotGit = true
+		return
+	}
+
+	switch entryObjectType(filemode) {
+	case "blob":
+		if name == "HEAD" {
+			n.head = true
+		}
+	case "tree":
+		switch name {
+		case "objects":
+			n.objects = true
+		case "refs":
+			n.refs = true
+		}
+	}
+}
+
+// found returns true iff the entries added so far suggest that the
+// tree contains a nested repository.
+func (n *nestedRepository) found() bool {
+	return n.dotGit || (n.head && n.objects && n.refs)
+}
@@ -0,0 +1,259 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// maxNormalizedDuplicateSets is the maximum number of sets of
+// duplicates that are listed in `NormalizedDuplicates.Sets`.
+const maxNormalizedDuplicateSets = 10
+
+// utf8BOM is the byte order mark that some editors put at the start
+// of UTF-8 files.
+var utf8BOM = []byte{0xef, 0xbb, 0xbf}
+
+// NormalizedDuplicates describes the text blobs that are "logically
+// identical" to other blobs; i.e., that only differ from them by
+// trivial details: a byte order mark, whitespace at the ends of
+// lines (including the CR of CRLF line endings), or empty lines at
+// the end of the file. Repositories that were imported from other
+// version control systems often contain many such blobs.
+type NormalizedDuplicates struct {
+	// Cutoff is the size of the biggest blobs that were examined.
+	Cutoff counts.Count32 `json:"cutoff"`
+
+	// TextBlobCount is the number of text blobs that were examined,
+	// and DistinctCount the number of distinct contents that they
+	// have once they are normalized.
+	TextBlobCount counts.Count64 `json:"text_blob_count"`
+	DistinctCount counts.Count64 `json:"distinct_count"`
+
+	// RedundantBlobCount is the number of text blobs that are
+	// logically identical to another, smaller one, and RedundantSize
+	// their total size.
+	RedundantBlobCount counts.Count64 `json:"redundant_blob_count"`
+	RedundantSize      counts.Count64 `json:"redundant_size"`
+
+	// Sets lists the sets of logically identical blobs whose
+	// redundant blobs are the biggest, biggest first.
+	Sets []NormalizedDuplicateSet `json:"sets"`
+}
+
+// NormalizedDuplicateSet is a set of text blobs that are identical
+// once they are normalized.
+type NormalizedDuplicateSet struct {
+	// Blobs are the blobs in the set, largest first.
+	Blobs []NormalizedDuplicate `json:"blobs"`
+
+	// RedundantSize is the total size of the blobs other than the
+	// smallest.
+	RedundantSize counts.Count64 `json:"redundant_size"`
+}
+
+// NormalizedDuplicate is one of the blobs in a
+// `NormalizedDuplicateSet`.
+type NormalizedDuplicate struct {
+	Blob git.OID        `json:"blob"`
+	Size counts.Count32 `json:"size"`
+}
+
+// recordNormalizationCandidate remembers the blob `oid` if it is
+// small enough to be examined for `NormalizedDuplicates`. The caller
+// must hold `g.historyLock`.
+func (g *Graph) recordNormalizationCandidate(oid git.OID, size counts.Count32) {
+	if g.normalizationCutoff == 0 || size > g.normalizationCutoff || !g.countsTowardTotals(oid) {
+		return
+	}
+	g.normalizationBlobs = append(g.normalizationBlobs, objectHeader{oid, size})
+}
+
+// normalizedHash returns the SHA-256 of `data` after normalization,
+// and false if `data` looks binary, in which case it is not
+// normalized.
+func normalizedHash(data []byte) ([sha256.Size]byte, bool) {
+	check := data
+	if len(check) > binaryCheckSize {
+		check = check[:binaryCheckSize]
+	}
+	if bytes.IndexByte(check, 0) != -1 {
+		return [sha256.Size]byte{}, false
+	}
+
+	data = bytes.TrimPrefix(data, utf8BOM)
+	h := sha256.New()
+	// Empty lines are only written once a non-empty line follows
+	// them, so that empty lines at the end are dropped:
+	var emptyLines int
+	for len(data) > 0 {
+		var line []byte
+		if i := bytes.IndexByte(data, '\n'); i != -1 {
+			line, data = data[:i], data[i+1:]
+		} else {
+			line, data = data, nil
+		}
+		line = bytes.TrimRight(line, " \t\r\f\v")
+		if len(line) == 0 {
+			emptyLines++
+			continue
+		}
+		for ; emptyLines > 0; emptyLines-- {
+			h.Write([]byte{'\n'})
+		}
+		h.Write(line)
+		h.Write([]byte{'\n'})
+	}
+
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum, true
+}
+
+// findNormalizedDuplicates reads the blobs that were recorded by
+// `recordNormalizationCandidate()`, groups the text blobs among them
+// by their normalized contents, and stores the results in
+// `s.NormalizedDuplicates`.
+func (s *HistorySize) findNormalizedDuplicates(
+	ctx context.Context, repo *git.Repository, g *Graph, progressMeter meter.Progress,
+) error {
+	blobs := g.normalizationBlobs
+
+	objectIter, err := repo.NewBatchObjectIter(ctx)
+	if err != nil {
+		return err
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		defer objectIter.Close()
+
+		errChan <- func() error {
+			for _, blob := range blobs {
+				if err := objectIter.RequestObject(blob.oid); err != nil {
+					return fmt.Errorf("requesting blob '%s': %w", blob.oid, err)
+				}
+			}
+			return nil
+		}()
+	}()
+
+	d := NormalizedDuplicates{
+		Cutoff: g.normalizationCutoff,
+		Sets:   []NormalizedDuplicateSet{},
+	}
+	sets := make(map[[sha256.Size]byte][]NormalizedDuplicate)
+
+	progressMeter.Start("Normalizing small blobs: %d")
+	meter.SetTotal(progressMeter, int64(len(blobs)))
+	for _, blob := range blobs {
+		obj, ok, err := objectIter.Next()
+		if err != nil {
+			progressMeter.Done()
+			return err
+		}
+		if !ok {
+			progressMeter.Done()
+			return errors.New("fewer blobs read than expected")
+		}
+		if obj.OID != blob.oid {
+			panic("blobs not read in same order as requested")
+		}
+		progressMeter.Inc()
+		meter.AddBytes(progressMeter, int64(len(obj.Data)))
+
+		sum, ok := normalizedHash(obj.Data)
+		if !ok {
+			continue
+		}
+		d.TextBlobCount.Increment(1)
+		sets[sum] = append(sets[sum], NormalizedDuplicate{Blob: blob.oid, Size: blob.objectSize})
+	}
+	progressMeter.Done()
+
+	if err := <-errChan; err != nil {
+		return err
+	}
+
+	d.DistinctCount = counts.NewCount64(uint64(len(sets)))
+	var duplicates []NormalizedDuplicateSet
+	for _, blobs := range sets {
+		if len(blobs) < 2 {
+			continue
+		}
+		sort.Slice(blobs, func(i, j int) bool {
+			if blobs[i].Size != blobs[j].Size {
+				return blobs[i].Size > blobs[j].Size
+			}
+			return bytes.Compare(blobs[i].Blob.Bytes(), blobs[j].Blob.Bytes()) < 0
+		})
+		set := NormalizedDuplicateSet{Blobs: blobs}
+		for _, b := range blobs[:len(blobs)-1] {
+			set.RedundantSize.Increment(counts.Count64(b.Size))
+		}
+		d.RedundantBlobCount.Increment(counts.Count64(len(blobs) - 1))
+		d.RedundantSize.Increment(set.RedundantSize)
+		duplicates = append(duplicates, set)
+	}
+	sort.Slice(duplicates, func(i, j int) bool {
+		if duplicates[i].RedundantSize != duplicates[j].RedundantSize {
+			return duplicates[i].RedundantSize > duplicates[j].RedundantSize
+		}
+		return bytes.Compare(
+			duplicates[i].Blobs[0].Blob.Bytes(), duplicates[j].Blobs[0].Blob.Bytes(),
+		) < 0
+	})
+	if len(duplicates) > maxNormalizedDuplicateSets {
+		duplicates = duplicates[:maxNormalizedDuplicateSets]
+	}
+	d.Sets = append(d.Sets, duplicates...)
+
+	s.NormalizedDuplicates = &d
+	return nil
+}
+
+// NormalizedDuplicatesTableString describes the text blobs that only
+// differ from others by trivial details, or returns the empty string
+// if they weren't sought.
+func (s *HistorySize) NormalizedDuplicatesTableString() string {
+	d := s.NormalizedDuplicates
+	if d == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(
+		buf,
+		"\nText blobs of up to %s that only differ by whitespace or a byte order mark:\n\n",
+		formatBytes(counts.Count64(d.Cutoff)),
+	)
+	fmt.Fprintln(buf, "| Text blobs | Distinct after normalizing | Redundant | Redundant size")
+	fmt.Fprintln(buf, "| ---------- | -------------------------- | --------- | --------------")
+	fmt.Fprintf(
+		buf, "| %10d | %26d | %9d | %s\n",
+		d.TextBlobCount, d.DistinctCount, d.RedundantBlobCount, formatSharedBytes(d.RedundantSize),
+	)
+	if len(d.Sets) == 0 {
+		return buf.String()
+	}
+
+	fmt.Fprintf(buf, "\nThe sets of such blobs with the most redundant data:\n\n")
+	fmt.Fprintln(buf, "| Size      | Blob")
+	fmt.Fprintln(buf, "| --------- | ----")
+	for i, set := range d.Sets {
+		if i > 0 {
+			fmt.Fprintln(buf, "|           |")
+		}
+		for _, b := range set.Blobs {
+			fmt.Fprintf(buf, "| %s | %s\n", formatSharedBytes(counts.Count64(b.Size)), b.Blob)
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,83 @@
+package sizes
+
+import (
+	"fmt"
+
+	"github.com/github/git-sizer/git"
+)
+
+// The following functions check that an object list (see
+// `ScanOptions.ObjectList`) is complete and in the right order, so
+// that an incomplete list yields an error rather than inconsistent
+// sizes. Lists generated by `git rev-list` always pass.
+
+// checkTreesComplete returns an error if any of the trees that were
+// scanned refer to subtrees that weren't.
+func (g *Graph) checkTreesComplete() error {
+	g.treeLock.Lock()
+	defer g.treeLock.Unlock()
+
+	for oid, r := range g.treeRecords {
+		r.lock.Lock()
+		unlisted := r.pending == -1
+		r.lock.Unlock()
+		if unlisted {
+			return fmt.Errorf(
+				"the object list is incomplete: tree %s is referred to but not listed", oid,
+			)
+		}
+	}
+	return nil
+}
+
+// checkCommitDependencies returns an error if the tree or any of the
+// parents of `commit` (except for the parents of a shallow commit and
+// those at the boundary of the scan) haven't been registered yet.
+func (g *Graph) checkCommitDependencies(oid git.OID, commit *git.Commit) error {
+	if g.needs&needTrees != 0 {
+		g.treeLock.Lock()
+		_, ok := g.treeSizes[commit.Tree]
+		g.treeLock.Unlock()
+		if !ok && !g.atBoundary(commit.Tree) {
+			return fmt.Errorf(
+				"the object list is incomplete: tree %s of commit %s is not listed",
+				commit.Tree, oid,
+			)
+		}
+	}
+
+	if _, ok := g.shallowCommits[oid]; ok {
+		return nil
+	}
+
+	g.commitLock.Lock()
+	defer g.commitLock.Unlock()
+	for _, parent := range g.walkedParents(commit.Parents) {
+		if _, ok := g.commitSizes[parent]; !ok {
+			return fmt.Errorf(
+				"parent %s of commit %s is not listed, or is listed before the commit",
+				parent, oid,
+			)
+		}
+	}
+	return nil
+}
+
+// checkTagsComplete returns an error if any of the tags that were
+// scanned point at objects that weren't.
+func (g *Graph) checkTagsComplete() error {
+	g.tagLock.Lock()
+	defer g.tagLock.Unlock()
+
+	for oid, r := range g.tagRecords {
+		r.lock.Lock()
+		unlisted := r.pending == -1
+		r.lock.Unlock()
+		if unlisted {
+			return fmt.Errorf(
+				"the object list is incomplete: tag %s is referred to but not listed", oid,
+			)
+		}
+	}
+	return nil
+}
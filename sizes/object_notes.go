@@ -0,0 +1,126 @@
+package sizes
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/github/git-sizer/git"
+)
+
+// DefaultNotesRef is the notes reference from which object notes are
+// read by default (see `ScanOptions.NotesRefs`).
+const DefaultNotesRef = "refs/notes/size-exemptions"
+
+// ObjectNote is a `git notes` note that is attached to an object that
+// the report cites, e.g., to record why a known-large object is
+// acceptable.
+type ObjectNote struct {
+	NotesRef string `json:"notes_ref"`
+	Text     string `json:"text"`
+}
+
+// Summary returns the first line of the note.
+func (n ObjectNote) Summary() string {
+	summary := n.Text
+	if i := strings.IndexByte(summary, '\n'); i >= 0 {
+		summary = summary[:i]
+	}
+	return strings.TrimSpace(summary)
+}
+
+// collectObjectNotes reads the notes in `notesRefs` that are attached
+// to the objects cited by `s`, and stores them in `s.ObjectNotes`.
+// Notes refs that don't exist are skipped. The free-form text of
+// notes can't be anonymized, so none are read if `s` is anonymized.
+func (s *HistorySize) collectObjectNotes(
+	ctx context.Context, repo *git.Repository, notesRefs []string,
+) error {
+	if len(notesRefs) == 0 || s.anonymizer != nil {
+		return nil
+	}
+
+	cited := make(map[git.OID]struct{})
+	cite := func(p *Path) {
+		if p != nil && p.OID != git.NullOID {
+			cited[p.OID] = struct{}{}
+		}
+	}
+
+	items := make(map[string]*item)
+	s.contents(nil).CollectItems(items)
+	for symbol, i := range items {
+		if s.stats.Contains(symbol) {
+			cite(i.path)
+		}
+	}
+	for _, top := range s.TopObjects {
+		for _, o := range top {
+			cite(o.Object)
+		}
+	}
+	for _, f := range s.HardLimits {
+		cite(f.Object)
+	}
+	if len(cited) == 0 {
+		return nil
+	}
+
+	oids := make([]git.OID, 0, len(cited))
+	for oid := range cited {
+		oids = append(oids, oid)
+	}
+	sort.Slice(oids, func(i, j int) bool { return oids[i].String() < oids[j].String() })
+
+	for _, notesRef := range notesRefs {
+		notes, err := repo.Notes(ctx, notesRef, oids)
+		if err != nil {
+			return err
+		}
+		for _, oid := range oids {
+			text, ok := notes[oid]
+			if !ok {
+				continue
+			}
+			if s.ObjectNotes == nil {
+				s.ObjectNotes = make(map[string][]ObjectNote)
+			}
+			key := oid.String()
+			s.ObjectNotes[key] = append(s.ObjectNotes[key], ObjectNote{
+				NotesRef: notesRef,
+				Text:     text,
+			})
+		}
+	}
+
+	return nil
+}
+
+// notesFor returns the notes in `notes` (see
+// `HistorySize.ObjectNotes`) that are attached to the object at `p`,
+// if any.
+func notesFor(notes map[string][]ObjectNote, p *Path) []ObjectNote {
+	if p == nil || p.OID == git.NullOID {
+		return nil
+	}
+	return notes[p.OID.String()]
+}
+
+// objectNoteSuffix returns the first lines of `notes`, formatted to
+// be appended to an object's name in the text output (e.g., " [note:
+// vendored SDK]"), or "" if there are none.
+func objectNoteSuffix(notes []ObjectNote) string {
+	if len(notes) == 0 {
+		return ""
+	}
+	summaries := make([]string, 0, len(notes))
+	for _, n := range notes {
+		if summary := n.Summary(); summary != "" {
+			summaries = append(summaries, summary)
+		}
+	}
+	if len(summaries) == 0 {
+		return ""
+	}
+	return " [note: " + strings.Join(summaries, "; ") + "]"
+}
@@ -0,0 +1,305 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// odbStateVersion is the version of the object database state file
+// format.
+const odbStateVersion = 1
+
+// ODBState records which packfiles and loose objects a repository's
+// object database held at some time, so that a later scan can report
+// the objects that were added since then. See `HistorySize.ODBDelta`.
+type ODBState struct {
+	Version  int       `json:"version"`
+	SaveTime time.Time `json:"save_time"`
+
+	// Packfiles holds the names of the packfiles, like
+	// "pack-<hash>.pack".
+	Packfiles []string `json:"packfiles"`
+
+	// LooseObjects holds the names of the loose objects.
+	LooseObjects []string `json:"loose_objects"`
+}
+
+// CurrentODBState returns the current state of `repo`'s object
+// database, not including any alternates.
+func CurrentODBState(ctx context.Context, repo *git.Repository) (*ODBState, error) {
+	packs, err := repo.Packfiles(ctx)
+	if err != nil {
+		return nil, err
+	}
+	loose, err := repo.LooseObjectIDs(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	st := ODBState{
+		Version:      odbStateVersion,
+		SaveTime:     time.Now(),
+		Packfiles:    make([]string, 0, len(packs)),
+		LooseObjects: make([]string, 0, len(loose)),
+	}
+	for _, pack := range packs {
+		st.Packfiles = append(st.Packfiles, pack.Name)
+	}
+	for _, oid := range loose {
+		st.LooseObjects = append(st.LooseObjects, oid.String())
+	}
+	return &st, nil
+}
+
+// ReadODBState reads an object database state that was written by
+// `ODBState.Write()`.
+func ReadODBState(path string) (*ODBState, error) {
+	buf, err := readDecompressing(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading object database state: %w", err)
+	}
+
+	var st ODBState
+	if err := json.Unmarshal(buf, &st); err != nil {
+		return nil, fmt.Errorf("reading object database state %s: %w", path, err)
+	}
+	if st.Version != odbStateVersion {
+		return nil, fmt.Errorf(
+			"object database state %s has unsupported version %d", path, st.Version,
+		)
+	}
+	return &st, nil
+}
+
+// Write writes `st` to a file at `path` as JSON, compressed with gzip
+// if `path` ends in ".gz".
+func (st *ODBState) Write(path string) error {
+	buf, err := json.MarshalIndent(st, "", "    ")
+	if err != nil {
+		return err
+	}
+	if err := WriteCompressible(path, append(buf, '\n')); err != nil {
+		return fmt.Errorf("writing object database state: %w", err)
+	}
+	return nil
+}
+
+// ODBDeltaTotal holds the number and total size of the added objects
+// of one type.
+type ODBDeltaTotal struct {
+	Count counts.Count32 `json:"count"`
+	Size  counts.Count64 `json:"size"`
+}
+
+// ODBDelta describes the objects that were added to the object
+// database since a saved state, whether or not they are reachable:
+// those in packfiles that didn't exist then, plus the new loose
+// objects.
+type ODBDelta struct {
+	// Since is the time when the state was saved.
+	Since time.Time `json:"since"`
+
+	// NewPackfiles lists the packfiles that didn't exist then.
+	NewPackfiles []string `json:"new_packfiles"`
+
+	// NewLooseObjectCount is the number of loose objects that didn't
+	// exist then.
+	NewLooseObjectCount counts.Count32 `json:"new_loose_object_count"`
+
+	// RemovedPackfileCount is the number of packfiles that existed
+	// then but don't anymore. If it is nonzero, the repository has
+	// been repacked, and objects that were moved into the new
+	// packfiles are counted as added.
+	RemovedPackfileCount counts.Count32 `json:"removed_packfile_count"`
+
+	// The number and total size of the added objects of each type.
+	Commits ODBDeltaTotal `json:"commits"`
+	Trees   ODBDeltaTotal `json:"trees"`
+	Blobs   ODBDeltaTotal `json:"blobs"`
+	Tags    ODBDeltaTotal `json:"tags"`
+
+	// DiskSize is the total number of bytes that the added objects
+	// occupy in the object database.
+	DiskSize counts.Count64 `json:"disk_size"`
+
+	// MaxBlob is the largest added blob, and MaxBlobSize is its
+	// size. MaxBlob is omitted if no blobs were added.
+	MaxBlob     *git.OID       `json:"max_blob,omitempty"`
+	MaxBlobSize counts.Count32 `json:"max_blob_size"`
+}
+
+// computeODBDelta finds the objects that were added to `repo`'s
+// object database since `state` was saved, and stores a summary of
+// them in `s.ODBDelta`. Only the new packfiles and loose objects are
+// looked at, so this is fast if few objects were added.
+func (s *HistorySize) computeODBDelta(
+	ctx context.Context, repo *git.Repository, state *ODBState,
+	progressMeter meter.Progress,
+) error {
+	oldPacks := make(map[string]bool, len(state.Packfiles))
+	for _, name := range state.Packfiles {
+		oldPacks[name] = true
+	}
+	oldLoose := make(map[string]bool, len(state.LooseObjects))
+	for _, name := range state.LooseObjects {
+		oldLoose[name] = true
+	}
+
+	delta := ODBDelta{
+		Since:        state.SaveTime,
+		NewPackfiles: []string{},
+	}
+
+	packs, err := repo.Packfiles(ctx)
+	if err != nil {
+		return err
+	}
+	// Objects that were loose then, and have been packed since, are
+	// not new:
+	seen := make(map[string]bool, len(oldLoose))
+	for name := range oldLoose {
+		seen[name] = true
+	}
+	var names strings.Builder
+	add := func(oid git.OID) {
+		name := oid.String()
+		if seen[name] {
+			return
+		}
+		seen[name] = true
+		names.WriteString(name)
+		names.WriteByte('\n')
+	}
+
+	present := 0
+	for _, pack := range packs {
+		if oldPacks[pack.Name] {
+			present++
+			continue
+		}
+		delta.NewPackfiles = append(delta.NewPackfiles, pack.Name)
+		oids, err := repo.PackfileObjects(ctx, pack.Name)
+		if err != nil {
+			return err
+		}
+		for _, oid := range oids {
+			add(oid)
+		}
+	}
+	delta.RemovedPackfileCount = counts.NewCount32(uint64(len(oldPacks) - present))
+
+	loose, err := repo.LooseObjectIDs(ctx)
+	if err != nil {
+		return err
+	}
+	for _, oid := range loose {
+		if !oldLoose[oid.String()] {
+			delta.NewLooseObjectCount.Increment(1)
+		}
+		add(oid)
+	}
+
+	objIter, err := repo.NewObjectIterFromList(ctx, strings.NewReader(names.String()))
+	if err != nil {
+		return err
+	}
+	defer objIter.Close()
+
+	progressMeter.Start("Measuring added objects: %d")
+	for {
+		obj, ok, err := objIter.Next()
+		if err != nil {
+			return fmt.Errorf("measuring added objects: %w", err)
+		}
+		if !ok {
+			break
+		}
+		progressMeter.Inc()
+
+		var total *ODBDeltaTotal
+		switch obj.ObjectType {
+		case "commit":
+			total = &delta.Commits
+		case "tree":
+			total = &delta.Trees
+		case "blob":
+			total = &delta.Blobs
+			if delta.MaxBlob == nil || obj.ObjectSize > delta.MaxBlobSize {
+				oid := obj.OID
+				delta.MaxBlob = &oid
+				delta.MaxBlobSize = obj.ObjectSize
+			}
+		case "tag":
+			total = &delta.Tags
+		default:
+			continue
+		}
+		total.Count.Increment(1)
+		total.Size.Increment(counts.Count64(obj.ObjectSize))
+		delta.DiskSize.Increment(obj.DiskSize)
+	}
+	progressMeter.Done()
+
+	s.ODBDelta = &delta
+	return nil
+}
+
+// ODBDeltaTableString returns a table summarizing the objects that
+// were added to the object database since a saved state, or the
+// empty string if they weren't looked for.
+func (s *HistorySize) ODBDeltaTableString() string {
+	d := s.ODBDelta
+	if d == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(
+		buf, "\nObjects added to the object database since %s:\n\n",
+		d.Since.UTC().Format("2006-01-02 15:04:05 MST"),
+	)
+	fmt.Fprintln(buf, "| Type             | Count     | Total size |")
+	fmt.Fprintln(buf, "| ---------------- | --------- | ---------- |")
+	for _, row := range []struct {
+		name  string
+		total ODBDeltaTotal
+	}{
+		{"Commits", d.Commits},
+		{"Trees", d.Trees},
+		{"Blobs", d.Blobs},
+		{"Annotated tags", d.Tags},
+	} {
+		fmt.Fprintf(
+			buf, "| %-16s | %9d |  %s |\n",
+			row.name, row.total.Count, formatSharedBytes(row.total.Size),
+		)
+	}
+	fmt.Fprintf(
+		buf, "\nThey occupy %s on disk, in %d new packfile(s) and %d new loose object(s).\n",
+		strings.TrimSpace(formatSharedBytes(d.DiskSize)),
+		len(d.NewPackfiles), d.NewLooseObjectCount,
+	)
+	if d.MaxBlob != nil {
+		fmt.Fprintf(
+			buf, "The largest added blob is %s (%s).\n",
+			d.MaxBlob, strings.TrimSpace(formatSharedBytes(counts.Count64(d.MaxBlobSize))),
+		)
+	}
+	if d.RemovedPackfileCount != 0 {
+		fmt.Fprintf(
+			buf,
+			"%d packfile(s) have been removed since then (e.g., by a repack), so\n"+
+				"objects that were moved into new packfiles are counted as added.\n",
+			d.RemovedPackfileCount,
+		)
+	}
+	return buf.String()
+}
@@ -0,0 +1,82 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// ODBTotals totals all of the objects in the object database by
+// type, whether or not they are reachable, without walking any
+// history. It is what `--odb-totals` reports.
+type ODBTotals struct {
+	Blobs   ODBTypeTotals `json:"blobs"`
+	Trees   ODBTypeTotals `json:"trees"`
+	Commits ODBTypeTotals `json:"commits"`
+	Tags    ODBTypeTotals `json:"tags"`
+}
+
+// ODBTypeTotals is the number of objects of one type, their total
+// (uncompressed) size, and the number of bytes that they occupy on
+// disk.
+type ODBTypeTotals struct {
+	Count    counts.Count32 `json:"count"`
+	Size     counts.Count64 `json:"size"`
+	DiskSize counts.Count64 `json:"disk_size"`
+}
+
+// MeasureODBTotals totals the objects in `repo`'s object database by
+// type. See `git.Repository.AllObjectTotals()` for which objects are
+// included.
+func MeasureODBTotals(ctx context.Context, repo *git.Repository) (ODBTotals, error) {
+	totals, err := repo.AllObjectTotals(ctx)
+	if err != nil {
+		return ODBTotals{}, err
+	}
+
+	convert := func(t git.ObjectTypeTotals) ODBTypeTotals {
+		return ODBTypeTotals{
+			Count:    t.Count,
+			Size:     t.Size,
+			DiskSize: t.DiskSize,
+		}
+	}
+	return ODBTotals{
+		Blobs:   convert(totals["blob"]),
+		Trees:   convert(totals["tree"]),
+		Commits: convert(totals["commit"]),
+		Tags:    convert(totals["tag"]),
+	}, nil
+}
+
+// String formats `t` as a table for `--odb-totals`.
+func (t ODBTotals) String() string {
+	buf := &bytes.Buffer{}
+	fmt.Fprintln(buf, "All objects in the object database, by type:")
+	fmt.Fprintln(buf)
+	fmt.Fprintln(buf, "| Type    | Objects   | Total size | Size on disk")
+	fmt.Fprintln(buf, "| ------- | --------- | ---------- | ------------")
+
+	var total ODBTypeTotals
+	row := func(name string, tt ODBTypeTotals) {
+		fmt.Fprintf(
+			buf, "| %-7s | %9d | %s  | %s\n",
+			name, tt.Count, formatSharedBytes(tt.Size), formatSharedBytes(tt.DiskSize),
+		)
+		total.Count.Increment(tt.Count)
+		total.Size.Increment(tt.Size)
+		total.DiskSize.Increment(tt.DiskSize)
+	}
+	row("Commits", t.Commits)
+	row("Trees", t.Trees)
+	row("Blobs", t.Blobs)
+	row("Tags", t.Tags)
+	fmt.Fprintf(
+		buf, "| %-7s | %9d | %s  | %s\n",
+		"Total", total.Count, formatSharedBytes(total.Size), formatSharedBytes(total.DiskSize),
+	)
+	return buf.String()
+}
@@ -9,8 +9,6 @@ import (
 
 	"github.com/github/git-sizer/counts"
 	"github.com/github/git-sizer/git"
-
-	"github.com/spf13/pflag"
 )
 
 func (s BlobSize) String() string {
@@ -75,6 +73,10 @@ const (
 type tableContents interface {
 	Emit(t *table)
 	CollectItems(items map[string]*item)
+
+	// collectDefinitions calls `f` for each item, in order, with the
+	// names of the sections (starting with `path`) that contain it.
+	collectDefinitions(path []string, f func(path []string, i *item))
 }
 
 // A section of lines in the tabular output, consisting of a header
@@ -107,6 +109,15 @@ func (s *section) CollectItems(items map[string]*item) {
 	}
 }
 
+func (s *section) collectDefinitions(path []string, f func(path []string, i *item)) {
+	if s.name != "" {
+		path = append(path[:len(path):len(path)], s.name)
+	}
+	for _, c := range s.contents {
+		c.collectDefinitions(path, f)
+	}
+}
+
 // A line containing data in the tabular output.
 type item struct {
 	symbol      string
@@ -117,6 +128,31 @@ type item struct {
 	humaner     counts.Humaner
 	unit        string
 	scale       float64
+
+	// weight determines how the level of concern is computed from
+	// the ratio of the value to `scale` (see `ScanOptions.ConcernWeights`).
+	weight ConcernWeight
+
+	// refGroups are the refgroups from which the object at `path`
+	// is reachable, if known.
+	refGroups []RefGroupSymbol
+
+	// top lists the objects with the biggest values, if they were
+	// collected (see `HistorySize.TopObjects`).
+	top []TopObject
+
+	// examples lists some of the objects counted by an anomaly
+	// statistic, if they were collected (see
+	// `HistorySize.AnomalyExamples`).
+	examples []AnomalyExample
+
+	// docLink is the URL of the documentation of the statistic, if
+	// one was configured (see `ScanOptions.DocLinks`).
+	docLink string
+
+	// objectNotes are the notes attached to the objects cited by the
+	// report, if any (see `HistorySize.ObjectNotes`).
+	objectNotes map[string][]ObjectNote
 }
 
 func newItem(
@@ -142,18 +178,48 @@ func newItem(
 }
 
 func (i *item) Emit(t *table) {
+	if !t.stats.Contains(i.symbol) {
+		return
+	}
 	levelOfConcern, interesting := i.levelOfConcern(t.threshold)
 	if !interesting {
 		return
 	}
 	valueString, unitString := i.humaner.Format(i.value, i.unit)
+	citation := t.footnotes.CreateCitation(i.Footnote(t.nameStyle)) +
+		t.footnotes.CreateCitation(saturationNote(i.value)) +
+		t.footnotes.CreateCitation(i.docLinkNote())
 	t.formatRow(
-		i.name, t.footnotes.CreateCitation(i.Footnote(t.nameStyle)),
+		i.name, citation,
 		valueString, unitString,
 		levelOfConcern,
 	)
 }
 
+// docLinkNote returns a note linking to the documentation of the
+// statistic, or "" if there is none.
+func (i *item) docLinkNote() string {
+	if i.docLink == "" {
+		return ""
+	}
+	return "See " + i.docLink
+}
+
+// saturationNote returns a note explaining that `value` has saturated
+// (and is therefore displayed as "∞"), or "" if it hasn't.
+func saturationNote(value counts.Humanable) string {
+	if _, overflow := value.ToUint64(); !overflow {
+		return ""
+	}
+	bits := 64
+	if _, ok := value.(counts.Count32); ok {
+		bits = 32
+	}
+	return fmt.Sprintf(
+		"∞ means that the true value exceeds the capacity of a %d-bit counter", bits,
+	)
+}
+
 func (i *item) Footnote(nameStyle NameStyle) string {
 	if i.path == nil || i.path.OID == git.NullOID {
 		return ""
@@ -164,12 +230,25 @@ func (i *item) Footnote(nameStyle NameStyle) string {
 	case NameStyleHash:
 		return i.path.OID.String()
 	case NameStyleFull:
-		return i.path.String()
+		note := objectNoteSuffix(notesFor(i.objectNotes, i.path))
+		if len(i.refGroups) == 0 {
+			return i.path.String() + note
+		}
+		return fmt.Sprintf("%s [refgroups: %s]%s", i.path, i.refGroupList(), note)
 	default:
 		panic("unexpected NameStyle")
 	}
 }
 
+// refGroupList returns `i.refGroups` as a comma-separated string.
+func (i *item) refGroupList() string {
+	groups := make([]string, len(i.refGroups))
+	for j, group := range i.refGroups {
+		groups[j] = string(group)
+	}
+	return strings.Join(groups, ", ")
+}
+
 // If this item's alert level is at least as high as the threshold,
 // return the string that should be used as its "level of concern" and
 // `true`; otherwise, return `"", false`.
@@ -178,7 +257,7 @@ func (i *item) levelOfConcern(threshold Threshold) (string, bool) {
 	if overflow {
 		return "!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!", true
 	}
-	alert := Threshold(float64(value) / i.scale)
+	alert := Threshold(i.concern(value))
 	if alert < threshold {
 		return "", false
 	}
@@ -188,35 +267,116 @@ func (i *item) levelOfConcern(threshold Threshold) (string, bool) {
 	return stars[:int(alert)], true
 }
 
+// concern returns the level of concern of the statistic if its value
+// were `value`.
+func (i *item) concern(value uint64) float64 {
+	return i.weight.level(float64(value) / i.scale)
+}
+
 func (i *item) CollectItems(items map[string]*item) {
 	items[i.symbol] = i
 }
 
+func (i *item) collectDefinitions(path []string, f func(path []string, i *item)) {
+	f(path, i)
+}
+
+// topObjectJSON is how one of `item.top` is emitted as JSON.
+type topObjectJSON struct {
+	Value             uint64           `json:"value"`
+	LevelOfConcern    float64          `json:"levelOfConcern"`
+	ObjectName        string           `json:"objectName,omitempty"`
+	ObjectDescription string           `json:"objectDescription,omitempty"`
+	ObjectPath        []PathStep       `json:"objectPath,omitempty"`
+	ObjectNotes       []objectNoteJSON `json:"objectNotes,omitempty"`
+}
+
+// objectNoteJSON is how an `ObjectNote` is emitted as JSON.
+type objectNoteJSON struct {
+	NotesRef string `json:"notesRef"`
+	Text     string `json:"text"`
+}
+
+// objectNotesJSON converts `notes` to their JSON form.
+func objectNotesJSON(notes []ObjectNote) []objectNoteJSON {
+	if len(notes) == 0 {
+		return nil
+	}
+	ns := make([]objectNoteJSON, len(notes))
+	for i, n := range notes {
+		ns[i] = objectNoteJSON{NotesRef: n.NotesRef, Text: n.Text}
+	}
+	return ns
+}
+
+// anomalyExampleJSON is how one of `item.examples` is emitted as JSON.
+type anomalyExampleJSON struct {
+	ObjectName string `json:"objectName"`
+	ObjectType string `json:"objectType"`
+	EntryName  string `json:"entryName,omitempty"`
+}
+
 func (i *item) MarshalJSON() ([]byte, error) {
 	// How we want to emit an item as JSON.
-	value, _ := i.value.ToUint64()
+	value, overflow := i.value.ToUint64()
 
 	stat := struct {
-		Description       string  `json:"description"`
-		Value             uint64  `json:"value"`
-		Unit              string  `json:"unit"`
-		Prefixes          string  `json:"prefixes"`
-		ReferenceValue    float64 `json:"referenceValue"`
-		LevelOfConcern    float64 `json:"levelOfConcern"`
-		ObjectName        string  `json:"objectName,omitempty"`
-		ObjectDescription string  `json:"objectDescription,omitempty"`
+		Description       string               `json:"description"`
+		Value             uint64               `json:"value"`
+		Unit              string               `json:"unit"`
+		Prefixes          string               `json:"prefixes"`
+		ReferenceValue    float64              `json:"referenceValue"`
+		LevelOfConcern    float64              `json:"levelOfConcern"`
+		ObjectName        string               `json:"objectName,omitempty"`
+		ObjectDescription string               `json:"objectDescription,omitempty"`
+		ObjectPath        []PathStep           `json:"objectPath,omitempty"`
+		RefGroups         []RefGroupSymbol     `json:"refGroups,omitempty"`
+		ObjectNotes       []objectNoteJSON     `json:"objectNotes,omitempty"`
+		Saturated         bool                 `json:"saturated,omitempty"`
+		SaturationNote    string               `json:"saturationNote,omitempty"`
+		TopObjects        []topObjectJSON      `json:"topObjects,omitempty"`
+		AnomalyExamples   []anomalyExampleJSON `json:"anomalyExamples,omitempty"`
+		DocLink           string               `json:"docLink,omitempty"`
 	}{
 		Description:    i.description,
 		Value:          value,
 		Unit:           i.unit,
 		Prefixes:       i.humaner.Name(),
 		ReferenceValue: i.scale,
-		LevelOfConcern: float64(value) / i.scale,
+		LevelOfConcern: i.concern(value),
+		Saturated:      overflow,
+		SaturationNote: saturationNote(i.value),
+		DocLink:        i.docLink,
 	}
 
 	if i.path != nil && i.path.OID != git.NullOID {
 		stat.ObjectName = i.path.OID.String()
 		stat.ObjectDescription = i.path.Path()
+		stat.ObjectPath = i.path.Chain()
+		stat.RefGroups = i.refGroups
+		stat.ObjectNotes = objectNotesJSON(notesFor(i.objectNotes, i.path))
+	}
+
+	for _, o := range i.top {
+		t := topObjectJSON{
+			Value:          o.Value,
+			LevelOfConcern: i.concern(o.Value),
+		}
+		if o.Object != nil && o.Object.OID != git.NullOID {
+			t.ObjectName = o.Object.OID.String()
+			t.ObjectDescription = o.Object.Path()
+			t.ObjectPath = o.Object.Chain()
+			t.ObjectNotes = objectNotesJSON(notesFor(i.objectNotes, o.Object))
+		}
+		stat.TopObjects = append(stat.TopObjects, t)
+	}
+
+	for _, e := range i.examples {
+		stat.AnomalyExamples = append(stat.AnomalyExamples, anomalyExampleJSON{
+			ObjectName: e.OID.String(),
+			ObjectType: e.ObjectType,
+			EntryName:  e.EntryName,
+		})
 	}
 
 	return json.Marshal(stat)
@@ -244,7 +404,17 @@ func (i *indentedItem) Emit(t *table) {
 
 type Threshold float64
 
-// Methods to implement pflag.Value:
+// FlagValue is the interface of values that can be used for
+// command-line options. It has the same methods as `pflag.Value`, but
+// is defined here so that this package doesn't depend on any
+// command-line parsing library.
+type FlagValue interface {
+	String() string
+	Set(string) error
+	Type() string
+}
+
+// Methods to implement FlagValue:
 
 func (t *Threshold) String() string {
 	if t == nil {
@@ -276,7 +446,7 @@ func (t *Threshold) Type() string {
 	return "threshold"
 }
 
-// A `pflag.Value` that can be used as a boolean option that sets a
+// A `FlagValue` that can be used as a boolean option that sets a
 // `Threshold` variable to a fixed value. For example,
 //
 //		pflag.Var(
@@ -291,7 +461,7 @@ type thresholdFlagValue struct {
 	value     Threshold
 }
 
-func NewThresholdFlagValue(threshold *Threshold, value Threshold) pflag.Value {
+func NewThresholdFlagValue(threshold *Threshold, value Threshold) FlagValue {
 	return &thresholdFlagValue{false, threshold, value}
 }
 
@@ -325,7 +495,7 @@ const (
 	NameStyleFull
 )
 
-// Methods to implement pflag.Value:
+// Methods to implement FlagValue:
 
 func (n *NameStyle) String() string {
 	if n == nil {
@@ -365,9 +535,11 @@ func (n *NameStyle) Type() string {
 type table struct {
 	threshold     Threshold
 	nameStyle     NameStyle
+	stats         StatSet
 	sectionHeader string
 	footnotes     *Footnotes
 	indent        int
+	color         bool
 	buf           bytes.Buffer
 }
 
@@ -378,26 +550,32 @@ func (s *HistorySize) TableString(
 	t := table{
 		threshold: threshold,
 		nameStyle: nameStyle,
+		stats:     s.stats,
 		footnotes: NewFootnotes(),
 		indent:    -1,
+		color:     s.color,
 	}
 
 	contents.Emit(&t)
 
 	if t.buf.Len() == 0 {
-		return "No problems above the current threshold were found\n"
+		return "No problems above the current threshold were found\n" +
+			s.emptyString() + s.scopeString() + s.shallowString() + s.caveatsString() + s.capabilitiesString()
 	}
 
-	return t.generateHeader() + t.buf.String() + t.footnotes.String()
+	return t.generateHeader() + t.buf.String() + t.footnotes.String() + s.namesBudgetString() +
+		s.emptyString() + s.scopeString() + s.shallowString() + s.caveatsString() + s.capabilitiesString()
 }
 
 func (t *table) indented(sectionHeader string, depth int) *table {
 	return &table{
 		threshold:     t.threshold,
 		nameStyle:     t.nameStyle,
+		stats:         t.stats,
 		sectionHeader: sectionHeader,
 		footnotes:     t.footnotes,
 		indent:        t.indent + depth,
+		color:         t.color,
 	}
 }
 
@@ -448,27 +626,220 @@ func (t *table) formatRow(
 	if l < 28 {
 		spacer = spaces[:28-l]
 	}
-	fmt.Fprintf(
-		&t.buf, "| %s%s%s%s | %5s %-3s | %-30s |\n",
+	row := fmt.Sprintf(
+		"| %s%s%s%s | %5s %-3s | %-30s |",
 		prefix, name, spacer, citation, valueString, unitString, levelOfConcern,
 	)
+	if t.color {
+		row = colorRow(row, levelOfConcern)
+	}
+	fmt.Fprintln(&t.buf, row)
 }
 
+// JSON returns the version 2 JSON representation of `s`, indented by
+// `indent` per level, or on a single line if `indent` is empty.
 func (s *HistorySize) JSON(
-	refGroups []RefGroup, threshold Threshold, nameStyle NameStyle,
+	refGroups []RefGroup, threshold Threshold, nameStyle NameStyle, indent string,
 ) ([]byte, error) {
 	contents := s.contents(refGroups)
 	items := make(map[string]*item)
 	contents.CollectItems(items)
-	j, err := json.MarshalIndent(items, "", "    ")
-	return j, err
+	for symbol := range items {
+		if !s.stats.Contains(symbol) {
+			delete(items, symbol)
+		}
+	}
+
+	m := make(map[string]interface{}, len(items)+29)
+	for symbol, i := range items {
+		m[symbol] = i
+	}
+	m["effectiveConfig"] = s.effectiveConfig(items, threshold, nameStyle)
+	if s.HealthScore != nil {
+		m["healthScore"] = s.HealthScore
+	}
+	if s.EmptyRepository {
+		m["emptyRepository"] = true
+	}
+	if s.ScanScope != nil {
+		m["scanScope"] = s.ScanScope
+	}
+	if s.Head != nil {
+		m["head"] = s.Head
+	}
+	if s.ObjectsSince != nil {
+		m["objectsSince"] = s.ObjectsSince
+	}
+	if s.ShallowBoundary != nil {
+		m["shallowBoundary"] = s.ShallowBoundary
+	}
+	if s.Caveats != nil {
+		m["caveats"] = s.Caveats
+	}
+	if s.IgnoredRefs != nil {
+		m["ignoredRefs"] = s.IgnoredRefs
+	}
+	if s.RefGroupSharing != nil {
+		m["refgroupSharing"] = s.RefGroupSharing
+	}
+	if s.SharedTrees != nil {
+		m["sharedTrees"] = s.SharedTrees
+	}
+	if s.RefGroupTotals != nil {
+		m["refgroupTotals"] = s.RefGroupTotals
+	}
+	if s.Growth != nil {
+		m["growth"] = s.Growth
+	}
+	if s.RefGroupActivity != nil {
+		m["refgroupActivity"] = s.RefGroupActivity
+	}
+	if s.RefGroupEmptyCommits != nil {
+		m["refgroupEmptyCommits"] = s.RefGroupEmptyCommits
+	}
+	if s.PackObjectsEstimate != nil {
+		m["packObjectsEstimate"] = s.PackObjectsEstimate
+	}
+	if s.RootMaxima != nil {
+		m["rootMaxima"] = s.RootMaxima
+	}
+	if s.ODBDelta != nil {
+		m["odbDelta"] = s.ODBDelta
+	}
+	if s.RecentBlobs != nil {
+		m["recentBlobs"] = s.RecentBlobs
+	}
+	if s.RecentActivity != nil {
+		m["recentActivity"] = s.RecentActivity
+	}
+	if s.AgeBuckets != nil {
+		m["ageBuckets"] = s.AgeBuckets
+	}
+	if s.Packfiles != nil {
+		m["packfiles"] = s.Packfiles
+	}
+	if s.PackfileDuplicates != nil {
+		m["packfileDuplicates"] = s.PackfileDuplicates
+	}
+	if s.PackfileFixups != nil {
+		m["packfileFixups"] = s.PackfileFixups
+	}
+	if s.TopCommitters != nil {
+		m["topCommitters"] = s.TopCommitters
+	}
+	if s.BlobCompressibility != nil {
+		m["blobCompressibility"] = s.BlobCompressibility
+	}
+	if s.FileLineage != nil {
+		m["fileLineage"] = s.FileLineage
+	}
+	if s.LineEndingDuplicates != nil {
+		m["lineEndingDuplicates"] = s.LineEndingDuplicates
+	}
+	if s.NormalizedDuplicates != nil {
+		m["normalizedDuplicates"] = s.NormalizedDuplicates
+	}
+	if s.HostedSize != nil {
+		m["hostedSize"] = s.HostedSize
+	}
+	if s.CloneEstimate != nil {
+		m["cloneEstimate"] = s.CloneEstimate
+	}
+	if s.IndexEstimate != nil {
+		m["indexEstimate"] = s.IndexEstimate
+	}
+	if s.ConfigDrift != nil {
+		m["configDrift"] = s.ConfigDrift
+	}
+	if s.CommitGraphWrite != nil {
+		m["commitGraphWrite"] = s.CommitGraphWrite
+	}
+	if s.WideTrees != nil {
+		m["wideTrees"] = s.WideTrees
+	}
+	if s.ReflogOnly != nil {
+		m["reflogOnly"] = s.ReflogOnly
+	}
+	if s.ForcePushes != nil {
+		m["forcePushes"] = s.ForcePushes
+	}
+	if s.Lockfiles != nil {
+		m["lockfiles"] = s.Lockfiles
+	}
+	if s.RefChurn != nil {
+		m["refChurn"] = s.RefChurn
+	}
+	if s.RedundantRefs != nil {
+		m["redundantRefs"] = s.RedundantRefs
+	}
+	if s.ExactCheckout != nil {
+		m["exactCheckout"] = s.ExactCheckout
+	}
+	if s.CheckoutExtensions != nil {
+		m["checkoutExtensions"] = s.CheckoutExtensions
+	}
+	if s.HardLimits != nil {
+		m["hardLimits"] = s.HardLimits
+	}
+	if s.UnreachableObjects != nil {
+		m["unreachableObjects"] = s.UnreachableObjects
+	}
+	if s.ScanIntegrity != nil {
+		m["scanIntegrity"] = s.ScanIntegrity
+	}
+	if s.NamesBudget != nil {
+		m["namesBudget"] = s.NamesBudget
+	}
+	if s.GitCapabilities != nil {
+		m["gitCapabilities"] = s.GitCapabilities
+	}
+	if s.LFSMigration != nil {
+		m["lfsMigration"] = s.LFSMigration
+	}
+	if s.HostingLimits != nil {
+		m["hostingLimits"] = s.HostingLimits
+	}
+	if s.SizeBudget != nil {
+		m["sizeBudget"] = s.SizeBudget
+	}
+	if s.ImportArtifacts != nil {
+		m["importArtifacts"] = s.ImportArtifacts
+	}
+	if len(s.ObjectNotes) != 0 {
+		notes := make(map[string][]objectNoteJSON, len(s.ObjectNotes))
+		for oid, ns := range s.ObjectNotes {
+			notes[oid] = objectNotesJSON(ns)
+		}
+		m["objectNotes"] = notes
+	}
+
+	if indent == "" {
+		return json.Marshal(m)
+	}
+	return json.MarshalIndent(m, "", indent)
 }
 
 func (s *HistorySize) contents(refGroups []RefGroup) tableContents {
 	S := newSection
-	I := newItem
+	I := func(
+		symbol, name, description string, path *Path,
+		value counts.Humanable, humaner counts.Humaner, unit string, scale float64,
+	) *item {
+		scale = s.referenceValues.scale(s.profile, symbol, scale)
+		i := newItem(symbol, name, description, path, value, humaner, unit, scale)
+		if path != nil {
+			i.refGroups = s.objectRefGroups[path.OID]
+		}
+		i.top = s.TopObjects[symbol]
+		i.examples = s.AnomalyExamples[symbol]
+		i.docLink = s.docLinks[symbol]
+		i.weight = s.concernWeights[symbol]
+		i.objectNotes = s.ObjectNotes
+		return i
+	}
 	metric := counts.Metric
 	binary := counts.Binary
+	plain := counts.Plain
 
 	//nolint:prealloc // The length is not known in advance.
 	var rgis []tableContents
@@ -489,6 +860,44 @@ func (s *HistorySize) contents(refGroups []RefGroup) tableContents {
 		rgis = append(rgis, rgi.Indented(indent))
 	}
 
+	//nolint:prealloc // The length is not known in advance.
+	var rgts []tableContents
+	for _, rg := range refGroups {
+		if rg.Symbol == "" {
+			continue
+		}
+		tips, ok := s.ReferenceGroupTips[rg.Symbol]
+		if !ok {
+			continue
+		}
+		rgt := S(
+			rg.Name,
+			I(fmt.Sprintf("refgroup.%s.maxRefnameLength", rg.Symbol), "Longest refname",
+				fmt.Sprintf("The length of the longest refname in group '%s'", rg.Symbol),
+				tips.MaxRefnameLengthRef, tips.MaxRefnameLength, binary, "B", 200),
+			I(fmt.Sprintf("refgroup.%s.maxRefnameDepth", rg.Symbol), "Deepest refname",
+				fmt.Sprintf("The most slash-separated components of any refname in group '%s'", rg.Symbol),
+				tips.MaxRefnameDepthRef, tips.MaxRefnameDepth, metric, "", 10),
+			I(fmt.Sprintf("refgroup.%s.oldestTipAge", rg.Symbol), "Oldest tip age",
+				fmt.Sprintf("The age, in days, of the oldest tip commit in group '%s'", rg.Symbol),
+				tips.OldestTipRef, tipAge(s.ScanTime, tips.OldestTipDate), plain, "d", 3650),
+			I(fmt.Sprintf("refgroup.%s.newestTipAge", rg.Symbol), "Newest tip age",
+				fmt.Sprintf("The age, in days, of the newest tip commit in group '%s'", rg.Symbol),
+				tips.NewestTipRef, tipAge(s.ScanTime, tips.NewestTipDate), plain, "d", 3650),
+			I(fmt.Sprintf("refgroup.%s.staleRefCount", rg.Symbol), "Stale refs",
+				fmt.Sprintf("The number of references in group '%s' whose tips are stale", rg.Symbol),
+				nil, tips.StaleRefCount, metric, "", 2500),
+			I(fmt.Sprintf("refgroup.%s.maxCheckoutBlobCount", rg.Symbol), "Checkout files",
+				fmt.Sprintf("The maximum number of files in the checkout of any tip in group '%s'", rg.Symbol),
+				tips.MaxCheckoutBlobCountRef, tips.MaxCheckoutBlobCount, metric, "", 50e3),
+			I(fmt.Sprintf("refgroup.%s.maxCheckoutBlobSize", rg.Symbol), "Checkout size",
+				fmt.Sprintf("The maximum total size of the files in the checkout of any tip in group '%s'", rg.Symbol),
+				tips.MaxCheckoutBlobSizeRef, tips.MaxCheckoutBlobSize, binary, "B", 1e9),
+		)
+		indent := strings.Count(string(rg.Symbol), ".")
+		rgts = append(rgts, &indentedItem{tableContents: rgt, depth: indent})
+	}
+
 	return S(
 		"",
 		S(
@@ -501,6 +910,24 @@ func (s *HistorySize) contents(refGroups []RefGroup) tableContents {
 				I("uniqueCommitSize", "Total size",
 					"The total size of all commit objects",
 					nil, s.UniqueCommitSize, binary, "B", 250e6),
+				I("emptyCommitCount", "Empty commits",
+					"The number of non-merge commits that don't change the tree",
+					nil, s.EmptyCommitCount, metric, "", 100e3),
+				I("emptyMergeCount", "Empty merges",
+					"The number of merge commits whose tree is identical to a parent's",
+					nil, s.EmptyMergeCount, metric, "", 100e3),
+				I("nonUTF8EncodingCount", "Non-UTF-8 encodings",
+					"The number of commits that declare an encoding other than UTF-8",
+					nil, s.NonUTF8EncodingCount, metric, "", 10e3),
+				I("invalidUTF8MessageCount", "Invalid UTF-8 messages",
+					"The number of commits whose log messages are not valid UTF-8",
+					nil, s.InvalidUTF8MessageCount, metric, "", 1000),
+				I("uniqueAuthorCount", "Distinct authors",
+					"The number of distinct author identities (name and email)",
+					nil, s.UniqueAuthorCount, metric, "", 100e3),
+				I("uniqueCommitterCount", "Distinct committers",
+					"The number of distinct committer identities (name and email)",
+					nil, s.UniqueCommitterCount, metric, "", 100e3),
 			),
 
 			S(
@@ -514,6 +941,9 @@ func (s *HistorySize) contents(refGroups []RefGroup) tableContents {
 				I("uniqueTreeEntries", "Total tree entries",
 					"The total number of entries in all distinct tree objects",
 					nil, s.UniqueTreeEntries, metric, "", 50e6),
+				I("duplicateSubtreeTreeCount", "With duplicate subtrees",
+					"The number of distinct trees with multiple entries referring to the same subtree",
+					nil, s.DuplicateSubtreeTreeCount, metric, "", 10e3),
 			),
 
 			S(
@@ -531,6 +961,25 @@ func (s *HistorySize) contents(refGroups []RefGroup) tableContents {
 				I("uniqueTagCount", "Count",
 					"The total number of annotated tags",
 					nil, s.UniqueTagCount, metric, "", 25e3),
+				I("uniqueTagSize", "Total size",
+					"The total size of all annotated tag objects",
+					nil, s.UniqueTagSize, binary, "B", 50e6),
+				I("signedTagCount", "Signed",
+					"The number of annotated tags that carry a signature",
+					nil, s.SignedTagCount, metric, "", 25e3),
+			),
+
+			S(
+				"Loose objects",
+				I("looseObjectCount", "Count",
+					"The number of loose objects in the object database",
+					nil, s.LooseObjectCount, metric, "", 50e3),
+				I("maxLooseObjectShardCount", "Most in one directory",
+					"The largest number of loose objects in any one fan-out directory",
+					nil, s.MaxLooseObjectShardCount, metric, "", 500),
+				I("oldestLooseObjectAge", "Oldest age",
+					"The age, in days, of the oldest loose object",
+					nil, s.OldestLooseObjectAge, plain, "d", 180),
 			),
 
 			S(
@@ -538,6 +987,12 @@ func (s *HistorySize) contents(refGroups []RefGroup) tableContents {
 				I("referenceCount", "Count",
 					"The total number of references",
 					nil, s.ReferenceCount, metric, "", 25e3),
+				I("nonCommitBranchCount", "Non-commit branches",
+					"The number of branches that point at something other than a commit",
+					s.NonCommitBranch, s.NonCommitBranchCount, metric, "", 1),
+				I("nonCommitTagCount", "Non-commit tags",
+					"The number of tags that don't lead to a commit",
+					s.NonCommitTag, s.NonCommitTagCount, metric, "", 10),
 				S(
 					"",
 					rgis...,
@@ -545,6 +1000,10 @@ func (s *HistorySize) contents(refGroups []RefGroup) tableContents {
 			),
 		),
 
+		S("Reference tips",
+			rgts...,
+		),
+
 		S("Biggest objects",
 			S("Commits",
 				I("maxCommitSize", "Maximum size",
@@ -553,18 +1012,93 @@ func (s *HistorySize) contents(refGroups []RefGroup) tableContents {
 				I("maxCommitParentCount", "Maximum parents",
 					"The most parents of any single commit",
 					s.MaxParentCountCommit, s.MaxParentCount, metric, "", 10),
+				I("maxCommitHeaderSize", "Maximum header size",
+					"The size of the largest header block of any single commit",
+					s.MaxCommitHeaderSizeCommit, s.MaxCommitHeaderSize, binary, "B", 10e3),
+				I("nonstandardHeaderCount", "Nonstandard headers",
+					"The number of nonstandard or duplicated headers in commits and tags",
+					s.NonstandardHeaderObject, s.NonstandardHeaderCount, metric, "", 100),
+				I("maxNonUTF8CommitSize", "Largest non-UTF-8",
+					"The size of the largest commit with a non-UTF-8 encoding or log message",
+					s.MaxNonUTF8CommitSizeCommit, s.MaxNonUTF8CommitSize, binary, "B", 50e3),
 			),
 
 			S("Trees",
 				I("maxTreeEntries", "Maximum entries",
 					"The most entries in any single tree",
 					s.MaxTreeEntriesTree, s.MaxTreeEntries, metric, "", 1000),
+				I("maxRootTreeEntries", "Maximum root entries",
+					"The most entries in the root directory of any single commit",
+					s.MaxRootTreeEntriesCommit, s.MaxRootTreeEntries, metric, "", 250),
+				I("minRootTreeEntries", "Minimum root entries",
+					"The fewest entries in the root directory of any single commit",
+					s.MinRootTreeEntriesCommit, s.MinRootTreeEntries, metric, "", 250),
+				I("currentRootTreeEntries", "Current root entries",
+					"The number of entries in the root directory of the newest commit",
+					s.CurrentRootTreeEntriesCommit, s.CurrentRootTreeEntries, metric, "", 250),
+				I("maxTagOnlyTreeSize", "Largest tag-only",
+					"The size of the largest tree reachable from tags but not from any branch",
+					s.MaxTagOnlyTreeSizeTree, s.MaxTagOnlyTreeSize, binary, "B", 50e3),
+				I("maxDuplicateSubtreeEntries", "Duplicate subtrees",
+					"The most entries in any single tree that refer to the same subtree",
+					s.MaxDuplicateSubtreeEntriesTree, s.MaxDuplicateSubtreeEntries, metric, "", 10),
 			),
 
 			S("Blobs",
 				I("maxBlobSize", "Maximum size",
 					"The size of the largest blob object",
 					s.MaxBlobSizeBlob, s.MaxBlobSize, binary, "B", 10e6),
+				I("maxExecutableBlobSize", "Largest executable",
+					"The size of the largest blob that is marked executable",
+					s.MaxExecutableBlobSizeBlob, s.MaxExecutableBlobSize, binary, "B", 1e6),
+				I("maxTagOnlyBlobSize", "Largest tag-only",
+					"The size of the largest blob reachable from tags but not from any branch",
+					s.MaxTagOnlyBlobSizeBlob, s.MaxTagOnlyBlobSize, binary, "B", 10e6),
+				I("over2GiBBlobCount", "Over 2 GiB",
+					"The number of blobs of at least 2 GiB, which break 32-bit builds of Git, some HTTP proxies, and some archivers",
+					s.Over2GiBBlob, s.Over2GiBBlobCount, metric, "", 0.5),
+				I("over4GiBBlobCount", "Over 4 GiB",
+					"The number of blobs of at least 4 GiB, whose sizes overflow 32-bit fields",
+					s.Over4GiBBlob, s.Over4GiBBlobCount, metric, "", 0.1),
+			),
+
+			S("Metadata files",
+				I("maxGitmodulesSize", "Largest .gitmodules",
+					"The size of the largest version of a '.gitmodules' file",
+					s.MaxGitmodulesSizeBlob, s.MaxGitmodulesSize, binary, "B", 100e3),
+				I("uniqueGitmodulesSize", "Total .gitmodules",
+					"The total size of the distinct versions of '.gitmodules' files",
+					nil, s.UniqueGitmodulesSize, binary, "B", 10e6),
+				I("maxGitattributesSize", "Largest .gitattributes",
+					"The size of the largest version of a '.gitattributes' file",
+					s.MaxGitattributesSizeBlob, s.MaxGitattributesSize, binary, "B", 100e3),
+				I("uniqueGitattributesSize", "Total .gitattributes",
+					"The total size of the distinct versions of '.gitattributes' files",
+					nil, s.UniqueGitattributesSize, binary, "B", 10e6),
+				I("maxHookFileSize", "Largest hook file",
+					"The size of the largest version of a file named like a Git hook (e.g., 'pre-commit')",
+					s.MaxHookFileSizeBlob, s.MaxHookFileSize, binary, "B", 100e3),
+				I("uniqueHookFileSize", "Total hook files",
+					"The total size of the distinct versions of files named like Git hooks",
+					nil, s.UniqueHookFileSize, binary, "B", 10e6),
+			),
+
+			S("Lockfiles",
+				I("lockfileVersionCount", "Versions",
+					"The number of distinct versions of generated lockfiles (e.g., 'package-lock.json' or 'go.sum')",
+					nil, s.LockfileVersionCount, metric, "", 5e3),
+				I("uniqueLockfileSize", "Total size",
+					"The total size of the distinct versions of generated lockfiles",
+					nil, s.UniqueLockfileSize, binary, "B", 1e9),
+				I("maxLockfileSize", "Largest version",
+					"The size of the largest version of a generated lockfile",
+					s.MaxLockfileSizeBlob, s.MaxLockfileSize, binary, "B", 10e6),
+			),
+
+			S("Annotated tags",
+				I("maxTagSize", "Maximum size",
+					"The size of the largest annotated tag, including its message",
+					s.MaxTagSizeTag, s.MaxTagSize, binary, "B", 50e3),
 			),
 		),
 
@@ -572,6 +1106,18 @@ func (s *HistorySize) contents(refGroups []RefGroup) tableContents {
 			I("maxHistoryDepth", "Maximum history depth",
 				"The longest chain of commits in history",
 				nil, s.MaxHistoryDepth, metric, "", 500e3),
+			I("rootCommitCount", "Root commits",
+				"The number of commits that have no parents",
+				nil, s.RootCommitCount, metric, "", 10),
+			I("disconnectedHistoryCount", "Disconnected histories",
+				"The number of disjoint histories that are not connected by merges",
+				nil, s.DisconnectedHistoryCount, metric, "", 5),
+			I("maxMergeBubble", "Largest merge bubble",
+				"The most commits that a merge brought in that weren't in the history of its first parent",
+				s.MaxMergeBubbleCommit, s.MaxMergeBubble, metric, "", 10e3),
+			I("averageMergeBubble", "Average merge bubble",
+				"The mean number of commits that merges brought in that weren't in the history of their first parents",
+				nil, s.AverageMergeBubble, metric, "", 1000),
 			I("maxTagDepth", "Maximum tag depth",
 				"The longest chain of annotated tags pointing at one another",
 				s.MaxTagDepthTag, s.MaxTagDepth, metric, "", 1.001),
@@ -594,14 +1140,97 @@ func (s *HistorySize) contents(refGroups []RefGroup) tableContents {
 			I("maxCheckoutBlobSize", "Total size of files",
 				"The maximum sum of file sizes in any checkout",
 				s.MaxExpandedBlobSizeTree, s.MaxExpandedBlobSize, binary, "B", 1e9),
+			I("maxCheckoutIndexSize", "Estimated index size",
+				"The estimated size of the index file for the checkout with the largest index",
+				s.MaxCheckoutIndexSizeTree, s.MaxCheckoutIndexSize, binary, "B", 25e6),
+			I("maxCheckoutExecutableCount", "Executable files",
+				"The maximum number of files marked executable in any checkout",
+				s.MaxExpandedExecutableCountTree, s.MaxExpandedExecutableCount, metric, "", 1000),
 
 			I("maxCheckoutLinkCount", "Number of symlinks",
 				"The maximum number of symlinks in any checkout",
 				s.MaxExpandedLinkCountTree, s.MaxExpandedLinkCount, metric, "", 25e3),
 
+			I("absoluteSymlinkCount", "Absolute symlinks",
+				"The number of distinct symlinks whose targets are absolute paths",
+				s.AbsoluteSymlink, s.AbsoluteSymlinkCount, metric, "", 10),
+
+			I("symlinkCycleTreeCount", "Symlink cycles",
+				"The number of trees containing symlinks that refer to each other in a cycle",
+				s.SymlinkCycleTree, s.SymlinkCycleTreeCount, metric, "", 0.1),
+
+			I("symlinkTargetCount", "Distinct link targets",
+				"The number of distinct symlink targets",
+				nil, s.SymlinkTargetCount, metric, "", 25e3),
+
+			I("maxSymlinkTargetLength", "Longest link target",
+				"The length of the longest symlink target",
+				s.MaxSymlinkTargetLengthSymlink, s.MaxSymlinkTargetLength, binary, "B", 1000),
+
+			I("escapingSymlinkCount", "Escaping symlinks",
+				"The number of distinct symlinks whose targets climb above the root of the checkout",
+				s.EscapingSymlink, s.EscapingSymlinkCount, metric, "", 1),
+
 			I("maxCheckoutSubmoduleCount", "Number of submodules",
 				"The maximum number of submodules in any checkout",
 				s.MaxExpandedSubmoduleCountTree, s.MaxExpandedSubmoduleCount, metric, "", 100),
+
+			I("maxCheckoutWindowsUnsafeCount", "Windows-unsafe names",
+				"The maximum number of names that can't be checked out on Windows in the HEAD or biggest checkout",
+				s.MaxExpandedWindowsUnsafeCountTree, s.MaxExpandedWindowsUnsafeCount, metric, "", 1),
+
+			I("windowsUnsafeEntryCount", "Windows-unsafe paths",
+				"The number of distinct tree entries in the HEAD or biggest checkout whose names can't be checked out on Windows",
+				s.WindowsUnsafeEntry, s.WindowsUnsafeEntryCount, metric, "", 10),
+
+			I("normalizationCollisionTreeCount", "NFC/NFD collisions",
+				"The number of trees with entries whose names are equal after Unicode normalization",
+				s.NormalizationCollisionTree, s.NormalizationCollisionTreeCount, metric, "", 1),
+
+			I("unusualUnicodeEntryCount", "Unusual Unicode names",
+				"The number of distinct tree entries whose names are not NFC-normalized or mix scripts",
+				s.UnusualUnicodeEntry, s.UnusualUnicodeEntryCount, metric, "", 10),
+
+			I("nestedRepositoryTreeCount", "Nested repositories",
+				"The number of trees containing a .git entry or the layout of a Git repository",
+				s.NestedRepositoryTree, s.NestedRepositoryTreeCount, metric, "", 1),
+
+			I("potentialGitBombCount", "Potential git bombs",
+				"The number of trees whose checkouts exceeded the limit on expanded entries",
+				s.PotentialGitBombTree, s.PotentialGitBombCount, metric, "", 0.1),
+		),
+
+		S("Serving clones",
+			I("packObjectsMemory", "Pack-objects memory",
+				"The estimated peak memory that 'git pack-objects' needs to serve a clone",
+				nil, s.PackObjectsMemory, binary, "B", 2e9),
+		),
+
+		S("Custom statistics", s.customStatItems(I)...),
+
+		S(
+			"Scan memory",
+			I("graphBlobMemory", "Blob sizes",
+				"The estimated peak memory used to record the sizes of blobs",
+				nil, s.GraphBlobMemory, binary, "B", 1e9),
+			I("graphTreeMemory", "Tree sizes",
+				"The estimated peak memory used to record the sizes of trees",
+				nil, s.GraphTreeMemory, binary, "B", 1e9),
+			I("graphPendingTreeMemory", "Pending trees",
+				"The estimated peak memory used for trees waiting for the sizes of their entries",
+				nil, s.GraphPendingTreeMemory, binary, "B", 1e9),
+			I("graphCommitMemory", "Commit sizes",
+				"The estimated peak memory used to record the sizes of commits",
+				nil, s.GraphCommitMemory, binary, "B", 1e9),
+			I("graphTagMemory", "Tag sizes",
+				"The estimated peak memory used to record the sizes of annotated tags",
+				nil, s.GraphTagMemory, binary, "B", 1e9),
+			I("graphPathResolverMemory", "Path resolver",
+				"The estimated peak memory used to find the paths of the objects cited",
+				nil, s.GraphPathResolverMemory, binary, "B", 1e9),
+			I("graphMemory", "Total",
+				"The sum of the estimated peak memory of each of the above",
+				nil, s.GraphMemory, binary, "B", 4e9),
 		),
 	)
 }
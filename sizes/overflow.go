@@ -0,0 +1,57 @@
+package sizes
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// OverflowPolicy determines what a scan does if the value of one of
+// the selected statistics overflows its counter (see `ScanOptions`).
+// The arithmetic on `counts.Count32` and `counts.Count64` always
+// saturates, so the policy only determines how a saturated value is
+// reported.
+type OverflowPolicy int
+
+const (
+	// OverflowSaturate reports a saturated value as the counter's
+	// maximum value (which the table output shows as "∞"). This is
+	// the default.
+	OverflowSaturate OverflowPolicy = iota
+
+	// OverflowError causes the scan to fail with an error wrapping
+	// `ErrOverflow`.
+	OverflowError
+)
+
+// ErrOverflow is the error that is reported if a statistic overflows
+// under the `OverflowError` policy.
+var ErrOverflow = errors.New("counter overflowed")
+
+// checkOverflow returns an error wrapping `ErrOverflow` if any
+// of the selected statistics in `s` has saturated. If the expansion
+// of trees was limited (see `ScanOptions.MaxExpandedEntries`), the
+// checkout statistics are saturated deliberately for the trees that
+// exceeded the limit, so those aren't checked.
+func (s *HistorySize) checkOverflow(expansionLimited bool) error {
+	items := make(map[string]*item)
+	s.contents(nil).CollectItems(items)
+
+	symbols := make([]string, 0, len(items))
+	for symbol := range items {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	for _, symbol := range symbols {
+		if !s.stats.Contains(symbol) ||
+			expansionLimited && strings.HasPrefix(symbol, "maxCheckout") {
+			continue
+		}
+		if _, overflow := items[symbol].value.ToUint64(); overflow {
+			return fmt.Errorf("'%s': %w", symbol, ErrOverflow)
+		}
+	}
+	return nil
+}
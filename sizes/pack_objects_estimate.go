@@ -0,0 +1,188 @@
+package sizes
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// The following are approximations of the memory that `git
+// pack-objects` uses when it serves a clone. They don't account for
+// reachability bitmaps, which let it skip much of the work of
+// enumerating the objects, or for the reuse of existing deltas,
+// which lets it skip the delta search for most objects.
+const (
+	// packObjectsEntrySize is the memory used per object: its
+	// `struct object_entry`, its `struct object` in the object hash
+	// table, and its slots in the hash tables that index them.
+	packObjectsEntrySize = 160
+
+	// defaultPackThreads is the number of delta-search threads that
+	// is assumed if `pack.threads` isn't set. Git then uses one per
+	// CPU of the server, which this scan can't know.
+	defaultPackThreads = 8
+)
+
+// The defaults of the gitconfig settings that affect the memory used
+// by `git pack-objects`.
+const (
+	defaultPackWindow          = 10
+	defaultPackDepth           = 50
+	defaultBigFileThreshold    = 512 << 20
+	defaultDeltaBaseCacheLimit = 96 << 20
+)
+
+// PackObjectsEstimate is an estimate of the peak memory that `git
+// pack-objects` uses to serve a clone of the repository, assuming
+// that it has to search for deltas.
+type PackObjectsEstimate struct {
+	// The settings that the estimate is based on, from the
+	// repository's gitconfig or Git's defaults. WindowMemoryLimit is
+	// zero if the memory of the delta-search window isn't limited.
+	Window              int            `json:"window"`
+	Depth               int            `json:"depth"`
+	Threads             int            `json:"threads"`
+	WindowMemoryLimit   counts.Count64 `json:"window_memory_limit"`
+	BigFileThreshold    counts.Count64 `json:"big_file_threshold"`
+	DeltaBaseCacheLimit counts.Count64 `json:"delta_base_cache_limit"`
+
+	// ObjectCount is the number of objects to be packed.
+	ObjectCount counts.Count64 `json:"object_count"`
+
+	// ObjectMemory is the memory used to keep track of the objects.
+	ObjectMemory counts.Count64 `json:"object_memory"`
+
+	// WindowMemory is the memory used by the delta-search windows of
+	// all of the threads, which hold the contents of `Window` of the
+	// largest blobs and their delta indexes.
+	WindowMemory counts.Count64 `json:"window_memory"`
+
+	// DeltaBaseMemory is the memory used by the threads' delta base
+	// caches while they reconstruct objects from delta chains up to
+	// `Depth` deltas deep.
+	DeltaBaseMemory counts.Count64 `json:"delta_base_memory"`
+
+	// TotalMemory is the sum of the above.
+	TotalMemory counts.Count64 `json:"total_memory"`
+}
+
+// estimatePackObjects estimates the memory that `git pack-objects`
+// needs to serve a clone of the repository, based on the statistics
+// in `s` and on `repo`'s pack settings, and stores the result in
+// `s.PackObjectsEstimate` and `s.PackObjectsMemory`.
+func (s *HistorySize) estimatePackObjects(ctx context.Context, repo *git.Repository) error {
+	config := func(key string, defaultValue int) (int, error) {
+		v, err := repo.ConfigIntDefaultContext(ctx, key, defaultValue)
+		if err != nil {
+			return 0, fmt.Errorf("reading '%s': %w", key, err)
+		}
+		if v < 0 {
+			return defaultValue, nil
+		}
+		return v, nil
+	}
+
+	e := PackObjectsEstimate{}
+	var err error
+	if e.Window, err = config("pack.window", defaultPackWindow); err != nil {
+		return err
+	}
+	if e.Depth, err = config("pack.depth", defaultPackDepth); err != nil {
+		return err
+	}
+	if e.Threads, err = config("pack.threads", 0); err != nil {
+		return err
+	}
+	if e.Threads == 0 {
+		e.Threads = defaultPackThreads
+	}
+	windowMemoryLimit, err := config("pack.windowMemory", 0)
+	if err != nil {
+		return err
+	}
+	bigFileThreshold, err := config("core.bigFileThreshold", defaultBigFileThreshold)
+	if err != nil {
+		return err
+	}
+	deltaBaseCacheLimit, err := config("core.deltaBaseCacheLimit", defaultDeltaBaseCacheLimit)
+	if err != nil {
+		return err
+	}
+	e.WindowMemoryLimit = counts.NewCount64(uint64(windowMemoryLimit))
+	e.BigFileThreshold = counts.NewCount64(uint64(bigFileThreshold))
+	e.DeltaBaseCacheLimit = counts.NewCount64(uint64(deltaBaseCacheLimit))
+
+	e.ObjectCount = counts.Count64(s.UniqueCommitCount) +
+		counts.Count64(s.UniqueTreeCount) +
+		counts.Count64(s.UniqueBlobCount) +
+		counts.Count64(s.UniqueTagCount)
+	e.ObjectMemory = counts.NewCount64(uint64(e.ObjectCount) * packObjectsEntrySize)
+
+	// Blobs bigger than `core.bigFileThreshold` are never deltified,
+	// so they don't enter the window. Each entry in the window holds
+	// an object's contents and its delta index, which is about as big
+	// again:
+	candidate := counts.Count64(s.MaxBlobSize)
+	if candidate > e.BigFileThreshold {
+		candidate = e.BigFileThreshold
+	}
+	perThreadWindow := counts.NewCount64(uint64(e.Window) * 2 * uint64(candidate))
+	if e.WindowMemoryLimit != 0 && perThreadWindow > e.WindowMemoryLimit {
+		perThreadWindow = e.WindowMemoryLimit
+	}
+	e.WindowMemory = counts.NewCount64(uint64(e.Threads) * uint64(perThreadWindow))
+
+	// Reconstructing an object at the end of a delta chain requires
+	// its bases, which are kept in the delta base cache:
+	var averageBlobSize uint64
+	if s.UniqueBlobCount != 0 {
+		averageBlobSize = uint64(s.UniqueBlobSize) / uint64(s.UniqueBlobCount)
+	}
+	perThreadBases := counts.NewCount64(uint64(e.Depth) * averageBlobSize)
+	if perThreadBases > e.DeltaBaseCacheLimit {
+		perThreadBases = e.DeltaBaseCacheLimit
+	}
+	e.DeltaBaseMemory = counts.NewCount64(uint64(e.Threads) * uint64(perThreadBases))
+
+	e.TotalMemory = e.ObjectMemory.Plus(e.WindowMemory).Plus(e.DeltaBaseMemory)
+
+	s.PackObjectsEstimate = &e
+	s.PackObjectsMemory = e.TotalMemory
+	return nil
+}
+
+// packObjectsAdviceMemory is the estimated memory above which advice
+// about serving clones is given.
+const packObjectsAdviceMemory = 2 << 30
+
+// writeRecommendations writes advice about serving clones to `w`, if
+// `git pack-objects` is expected to need a lot of memory.
+func (e *PackObjectsEstimate) writeRecommendations(w io.Writer) {
+	if e == nil || e.TotalMemory < packObjectsAdviceMemory {
+		return
+	}
+
+	fmt.Fprintf(
+		w, "* Serving a clone could take 'git pack-objects' about %s of memory:\n",
+		formatBytes(e.TotalMemory),
+	)
+	objectCount, objectUnit := counts.Metric.Format(e.ObjectCount, "")
+	fmt.Fprintf(
+		w, "    * %s for tracking %s%s objects\n",
+		formatBytes(e.ObjectMemory), objectCount, objectUnit,
+	)
+	fmt.Fprintf(
+		w, "    * %s for %d delta-search windows of %d objects (set 'pack.windowMemory' to limit this)\n",
+		formatBytes(e.WindowMemory), e.Threads, e.Window,
+	)
+	fmt.Fprintf(
+		w, "    * %s for %d delta base caches (set 'core.deltaBaseCacheLimit' to limit this)\n",
+		formatBytes(e.DeltaBaseMemory), e.Threads,
+	)
+	fmt.Fprintf(
+		w, "    * reachability bitmaps ('git repack -adb') and reusing existing deltas avoid most of this\n",
+	)
+}
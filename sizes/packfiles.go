@@ -0,0 +1,266 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+const (
+	// smallPackObjectCount is the number of objects below which a
+	// packfile counts as small, if the repository has more than one
+	// packfile. Many small packfiles slow down object lookups until
+	// they are consolidated by a repack.
+	smallPackObjectCount = 1000
+
+	// smallPackWarningCount is the number of small packfiles at
+	// which the "Packfiles" section recommends repacking.
+	smallPackWarningCount = 10
+
+	// packFixupWarningCount is the number of deltas whose bases
+	// were appended to complete thin packs at which the "Packfiles"
+	// section recommends a full repack.
+	packFixupWarningCount = 10000
+
+	// packDuplicateWarningSize is the number of bytes wasted by
+	// objects that are stored in more than one packfile at which the
+	// "Packfiles" section recommends a full repack.
+	packDuplicateWarningSize = 100 * 1024 * 1024
+
+	// maxListedPackfiles is the maximum number of packfiles that are
+	// listed individually in the "Packfiles" section of the table
+	// output. All of them are included in the JSON output.
+	maxListedPackfiles = 20
+)
+
+// PackfileInfo describes a packfile in the repository's object
+// database.
+type PackfileInfo struct {
+	Name        string         `json:"name"`
+	ObjectCount counts.Count32 `json:"object_count"`
+	Size        counts.Count64 `json:"size"`
+	ModTime     time.Time      `json:"mtime"`
+	HasBitmap   bool           `json:"bitmap"`
+	HasRevIndex bool           `json:"rev_index"`
+	Keep        bool           `json:"keep"`
+
+	// Small is set if the packfile has fewer than
+	// `smallPackObjectCount` objects and isn't the only one.
+	Small bool `json:"small"`
+}
+
+// PackfileDuplicates summarizes the objects that are stored in more
+// than one packfile.
+type PackfileDuplicates struct {
+	// ObjectCount is the number of distinct objects that are stored
+	// in more than one packfile, and RedundantCopyCount is the number
+	// of their copies beyond the first.
+	ObjectCount        counts.Count32 `json:"object_count"`
+	RedundantCopyCount counts.Count32 `json:"redundant_copy_count"`
+
+	// WastedSize is the number of bytes taken up by the copies
+	// beyond the smallest one of each object, which a full repack
+	// would reclaim.
+	WastedSize counts.Count64 `json:"wasted_size"`
+}
+
+// PackfileFixups summarizes the objects in the packfiles that take
+// more than one access to the packs to read, because they are deltas
+// whose bases had to be appended to their packfiles to complete thin
+// packs (as fetches and pushes transfer them), or whose bases are in
+// other packfiles. They are a sign of incremental repacks that are
+// overdue for consolidation.
+type PackfileFixups struct {
+	// PackfileCount is the number of packfiles that have any such
+	// deltas.
+	PackfileCount counts.Count32 `json:"packfile_count"`
+
+	// DeltaCount is the number of deltas whose bases were appended
+	// to complete thin packs, and BaseCount and BaseSize are the
+	// number of those bases and the bytes that they take up.
+	DeltaCount counts.Count32 `json:"delta_count"`
+	BaseCount  counts.Count32 `json:"base_count"`
+	BaseSize   counts.Count64 `json:"base_size"`
+
+	// CrossPackDeltaCount is the number of deltas whose bases are
+	// in other packfiles.
+	CrossPackDeltaCount counts.Count32 `json:"cross_pack_delta_count"`
+}
+
+// collectPackfiles stores information about the packfiles in `repo`
+// in `s.Packfiles`, largest first, about the objects that are stored
+// in more than one of them in `s.PackfileDuplicates`, and about the
+// deltas whose bases aren't stored before them in the same packfile
+// in `s.PackfileFixups`.
+func (s *HistorySize) collectPackfiles(ctx context.Context, repo *git.Repository) error {
+	packs, err := repo.Packfiles(ctx)
+	if err != nil {
+		return err
+	}
+
+	dups, err := repo.PackDuplicates(ctx)
+	if err != nil {
+		return err
+	}
+	s.PackfileDuplicates = &PackfileDuplicates{
+		ObjectCount:        dups.ObjectCount,
+		RedundantCopyCount: dups.RedundantCopyCount,
+		WastedSize:         dups.WastedSize,
+	}
+
+	fixups, err := repo.PackFixups(ctx)
+	if err != nil {
+		return err
+	}
+	s.PackfileFixups = &PackfileFixups{
+		PackfileCount:       fixups.PackfileCount,
+		DeltaCount:          fixups.DeltaCount,
+		BaseCount:           fixups.BaseCount,
+		BaseSize:            fixups.BaseSize,
+		CrossPackDeltaCount: fixups.CrossPackDeltaCount,
+	}
+
+	s.Packfiles = make([]PackfileInfo, 0, len(packs))
+	for _, p := range packs {
+		s.Packfiles = append(s.Packfiles, PackfileInfo{
+			Name:        p.Name,
+			ObjectCount: p.ObjectCount,
+			Size:        p.Size,
+			ModTime:     p.ModTime,
+			HasBitmap:   p.HasBitmap,
+			HasRevIndex: p.HasRevIndex,
+			Keep:        p.Keep,
+			Small:       len(packs) > 1 && p.ObjectCount < smallPackObjectCount,
+		})
+	}
+
+	sort.SliceStable(s.Packfiles, func(i, j int) bool {
+		return s.Packfiles[i].Size > s.Packfiles[j].Size
+	})
+	return nil
+}
+
+// PackfilesTableString returns a table listing the packfiles, or the
+// empty string if they weren't collected.
+func (s *HistorySize) PackfilesTableString() string {
+	if s.Packfiles == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nPackfiles:\n\n")
+	if len(s.Packfiles) == 0 {
+		fmt.Fprintf(buf, "No packfiles; all objects are loose.\n")
+		return buf.String()
+	}
+
+	var totalSize counts.Count64
+	var smallCount int
+	for _, p := range s.Packfiles {
+		totalSize.Increment(p.Size)
+		if p.Small {
+			smallCount++
+		}
+	}
+
+	fmt.Fprintln(buf, "| Size      | Objects   | Modified         | Flags                 | Packfile")
+	fmt.Fprintln(buf, "| --------- | --------- | ---------------- | --------------------- | --------")
+	for i, p := range s.Packfiles {
+		if i == maxListedPackfiles {
+			fmt.Fprintf(buf, "| ... and %d more\n", len(s.Packfiles)-maxListedPackfiles)
+			break
+		}
+		objectCount, objectUnit := counts.Metric.Format(p.ObjectCount, "")
+		fmt.Fprintf(
+			buf, "| %s | %5s %-3s | %s | %-21s | %s\n",
+			formatSharedBytes(p.Size), objectCount, objectUnit,
+			p.ModTime.UTC().Format("2006-01-02 15:04"), packfileFlags(p), p.Name,
+		)
+	}
+
+	fmt.Fprintf(
+		buf, "\n%d packfiles, %s in total",
+		len(s.Packfiles), formatBytes(totalSize),
+	)
+	if smallCount > 0 {
+		fmt.Fprintf(buf, " (%d of them small)", smallCount)
+	}
+	fmt.Fprintf(buf, ".\n")
+	if dups := s.PackfileDuplicates; dups != nil && dups.ObjectCount > 0 {
+		objectCount, objectUnit := counts.Metric.Format(dups.ObjectCount, "")
+		fmt.Fprintf(
+			buf, "%s%s objects are stored in more than one packfile, wasting %s.\n",
+			objectCount, objectUnit, formatBytes(dups.WastedSize),
+		)
+		if dups.WastedSize >= packDuplicateWarningSize {
+			fmt.Fprintf(
+				buf,
+				"Consider a full repack ('git repack -a -d') to remove the duplicates.\n",
+			)
+		}
+	}
+	if fixups := s.PackfileFixups; fixups != nil &&
+		(fixups.DeltaCount > 0 || fixups.CrossPackDeltaCount > 0) {
+		objectCount, objectUnit := counts.Metric.Format(
+			fixups.DeltaCount+fixups.CrossPackDeltaCount, "",
+		)
+		fmt.Fprintf(
+			buf, "%s%s objects in %d packfiles take more than one pack access to read:\n",
+			objectCount, objectUnit, fixups.PackfileCount,
+		)
+		if fixups.DeltaCount > 0 {
+			deltaCount, deltaUnit := counts.Metric.Format(fixups.DeltaCount, "")
+			baseCount, baseUnit := counts.Metric.Format(fixups.BaseCount, "")
+			fmt.Fprintf(
+				buf, "  %s%s are deltas against %s%s bases (%s) that were appended to complete thin packs.\n",
+				deltaCount, deltaUnit, baseCount, baseUnit, formatBytes(fixups.BaseSize),
+			)
+		}
+		if fixups.CrossPackDeltaCount > 0 {
+			crossCount, crossUnit := counts.Metric.Format(fixups.CrossPackDeltaCount, "")
+			fmt.Fprintf(
+				buf, "  %s%s are deltas against bases in other packfiles.\n",
+				crossCount, crossUnit,
+			)
+		}
+		if fixups.DeltaCount+fixups.CrossPackDeltaCount >= packFixupWarningCount {
+			fmt.Fprintf(
+				buf,
+				"Consider a full repack ('git repack -a -d') to store them as ordinary deltas.\n",
+			)
+		}
+	}
+	if smallCount >= smallPackWarningCount {
+		fmt.Fprintf(
+			buf,
+			"Consider consolidating the small packfiles more often, for example using\n"+
+				"'git repack -d --geometric=2' or 'git maintenance'.\n",
+		)
+	}
+	return buf.String()
+}
+
+// packfileFlags returns a short description of the auxiliary files
+// and other properties of `p`.
+func packfileFlags(p PackfileInfo) string {
+	var flags []string
+	if p.HasBitmap {
+		flags = append(flags, "bitmap")
+	}
+	if p.HasRevIndex {
+		flags = append(flags, "rev")
+	}
+	if p.Keep {
+		flags = append(flags, "keep")
+	}
+	if p.Small {
+		flags = append(flags, "small")
+	}
+	return strings.Join(flags, ", ")
+}
@@ -19,6 +19,10 @@ import (
 //     object's reachability path, *in depth-first* order (i.e.,
 //     referents before referers) by calling `RecordTree()`,
 //     `RecordCommit()`, `RecordTag()`, and `RecordReference()`,.
+//     Index and reflog entries, recorded via `RecordIndexEntry()` and
+//     `RecordReflogEntry()`, can name objects that no reference
+//     leads to; they should be recorded last, so that names derived
+//     from references are preferred.
 //
 //   - Read the path out of the `Path` object using `Path.Path()`.
 //
@@ -33,11 +37,14 @@ import (
 // would otherwise continue consuming memory.
 type PathResolver interface {
 	RequestPath(oid git.OID, objectType string) *Path
+	RequestEntryPath(oid git.OID, name string, childOID git.OID, objectType string) *Path
 	ForgetPath(p *Path)
 	RecordName(name string, oid git.OID)
 	RecordTreeEntry(oid git.OID, name string, childOID git.OID)
 	RecordCommit(oid, tree git.OID)
 	RecordTag(oid git.OID, tag *git.Tag)
+	RecordIndexEntry(entry git.IndexEntry)
+	RecordReflogEntry(refname string, n int, oid git.OID)
 }
 
 type NullPathResolver struct {
@@ -58,6 +65,12 @@ func (n NullPathResolver) RequestPath(oid git.OID, objectType string) *Path {
 	}
 }
 
+func (n NullPathResolver) RequestEntryPath(
+	_ git.OID, _ string, childOID git.OID, objectType string,
+) *Path {
+	return n.RequestPath(childOID, objectType)
+}
+
 func (_ NullPathResolver) ForgetPath(p *Path) {}
 
 func (_ NullPathResolver) RecordName(name string, oid git.OID) {}
@@ -68,9 +81,23 @@ func (_ NullPathResolver) RecordCommit(oid, tree git.OID) {}
 
 func (_ NullPathResolver) RecordTag(oid git.OID, tag *git.Tag) {}
 
+func (_ NullPathResolver) RecordIndexEntry(entry git.IndexEntry) {}
+
+func (_ NullPathResolver) RecordReflogEntry(refname string, n int, oid git.OID) {}
+
 type InOrderPathResolver struct {
 	lock        sync.Mutex
 	soughtPaths map[git.OID]*Path
+
+	// The largest number of entries that `soughtPaths` has held at
+	// any one time.
+	maxSoughtPaths int
+
+	// budget limits the number of entries in `soughtPaths` and the
+	// length of the paths. limitedCount is the number of times that
+	// a path wasn't followed further because of it.
+	budget       NamesBudget
+	limitedCount int
 }
 
 // Structure for keeping track of an object whose path we want to know
@@ -105,6 +132,12 @@ type Path struct {
 	// the PathResolver.
 	seekerCount uint8
 
+	// The number of objects along the longest path that has been
+	// found so far from this object down to an object whose path is
+	// sought, including both (i.e., 1 for the latter). It is checked
+	// against `NamesBudget.MaxChainLength`.
+	chainLength uint16
+
 	// A path we found of a parent from which this object is
 	// referenced. This is set when we find a parent then never
 	// changed again. It is never set if the "parent" we find is a
@@ -133,6 +166,11 @@ func (p *Path) TreePrefix() string {
 			}
 		case p.relativePath != "":
 			return p.relativePath + "/"
+		case p.objectType == "tree":
+			// No referrer was found (e.g., because the
+			// `NamesBudget` was exceeded), but Git can find the
+			// entries of a tree given its OID:
+			return p.OID.String() + ":"
 		default:
 			return "???"
 		}
@@ -205,11 +243,84 @@ func (p *Path) String() string {
 	}
 }
 
+// PathStep is one step along the chain by which an object is
+// reachable, as returned by `Path.Chain()`.
+type PathStep struct {
+	// The type and OID of the object reached by this step.
+	ObjectType string  `json:"objectType"`
+	OID        git.OID `json:"objectName"`
+
+	// For the first step, the name of the reference that points at
+	// the object, if one was found. (This can also be another name
+	// that Git understands, like "HEAD@{3}" for a reflog entry or
+	// ":README" for an index entry.)
+	Ref string `json:"ref,omitempty"`
+
+	// For a blob or tree that is reached via a tree entry, the name
+	// of that entry.
+	Name string `json:"name,omitempty"`
+}
+
+// Chain returns the objects by which this object is reachable, from
+// the one that a reference points at (e.g., a commit) through the
+// commit's tree and the trees along the object's path to the object
+// itself. It is the structured form of `Path()`, and it is nil when
+// `Path()` is "".
+func (p *Path) Chain() []PathStep {
+	if p.Path() == "" {
+		return nil
+	}
+
+	var steps []PathStep
+	for q := p; q != nil; q = q.parent {
+		step := PathStep{
+			ObjectType: q.objectType,
+			OID:        q.OID,
+		}
+		if q.parent == nil {
+			step.Ref = q.relativePath
+		} else {
+			// A top-level tree has no entry name, and the
+			// `relativePath` of a commit or tag isn't used.
+			switch q.objectType {
+			case "blob", "tree":
+				step.Name = q.relativePath
+			}
+		}
+		steps = append(steps, step)
+	}
+
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+	return steps
+}
+
+// newReferencePath returns a `*Path` that names the object pointed
+// to by `ref` using the reference's name, anonymized by `a`. Such
+// paths don't need to be resolved, so they are not registered with a
+// `PathResolver`.
+func newReferencePath(ref git.Reference, a *Anonymizer) *Path {
+	return &Path{
+		OID:          ref.OID,
+		objectType:   string(ref.ObjectType),
+		seekerCount:  1,
+		relativePath: a.Refname(ref.Refname),
+	}
+}
+
 func (p *Path) MarshalJSON() ([]byte, error) {
 	return json.Marshal(p.String())
 }
 
 func NewPathResolver(nameStyle NameStyle) PathResolver {
+	return NewPathResolverWithBudget(nameStyle, NamesBudget{})
+}
+
+// NewPathResolverWithBudget is like `NewPathResolver()`, but if
+// `nameStyle` is `NameStyleFull`, the work that is done to find the
+// objects' paths is limited by `budget`.
+func NewPathResolverWithBudget(nameStyle NameStyle, budget NamesBudget) PathResolver {
 	switch nameStyle {
 	case NameStyleNone:
 		return NullPathResolver{false}
@@ -218,6 +329,7 @@ func NewPathResolver(nameStyle NameStyle) PathResolver {
 	case NameStyleFull:
 		return &InOrderPathResolver{
 			soughtPaths: make(map[git.OID]*Path),
+			budget:      budget,
 		}
 	default:
 		panic("Unexpected NameStyle value")
@@ -228,26 +340,100 @@ func NewPathResolver(nameStyle NameStyle) PathResolver {
 func (pr *InOrderPathResolver) RequestPath(oid git.OID, objectType string) *Path {
 	pr.lock.Lock()
 	defer pr.lock.Unlock()
-	return pr.requestPathLocked(oid, objectType)
+
+	if p := pr.requestPathLocked(oid, objectType, 1); p != nil {
+		return p
+	}
+	// The budget is exhausted, so the object will be named by its
+	// OID:
+	return &Path{
+		OID:         oid,
+		objectType:  objectType,
+		seekerCount: 1,
+	}
 }
 
-// Request that a path to the object named `oid` be computed.
-func (pr *InOrderPathResolver) requestPathLocked(oid git.OID, objectType string) *Path {
+// Request that a path to the object named `oid` be computed, as
+// part of a path of `chainLength` objects. Return nil if that would
+// exceed `pr.budget`.
+func (pr *InOrderPathResolver) requestPathLocked(
+	oid git.OID, objectType string, chainLength int,
+) *Path {
+	if !pr.budget.allowsChain(chainLength) {
+		pr.limitedCount++
+		return nil
+	}
+
 	p, ok := pr.soughtPaths[oid]
 	if ok {
 		p.seekerCount++
+		if cl := chainLengthOf(chainLength); cl > p.chainLength {
+			p.chainLength = cl
+		}
 		return p
 	}
 
+	if pr.budget.MaxObjects != 0 && len(pr.soughtPaths) >= pr.budget.MaxObjects {
+		pr.limitedCount++
+		return nil
+	}
+
 	p = &Path{
 		OID:         oid,
 		objectType:  objectType,
 		seekerCount: 1,
+		chainLength: chainLengthOf(chainLength),
 	}
 	pr.soughtPaths[oid] = p
+	if len(pr.soughtPaths) > pr.maxSoughtPaths {
+		pr.maxSoughtPaths = len(pr.soughtPaths)
+	}
 	return p
 }
 
+// namesBudgetLimitedCount returns the number of times that `pr`
+// stopped following a path because of its `NamesBudget`.
+func (pr *InOrderPathResolver) namesBudgetLimitedCount() int {
+	pr.lock.Lock()
+	defer pr.lock.Unlock()
+	return pr.limitedCount
+}
+
+// maxSoughtPathCount returns the largest number of objects whose
+// paths `pr` has been seeking at any one time.
+func (pr *InOrderPathResolver) maxSoughtPathCount() int {
+	pr.lock.Lock()
+	defer pr.lock.Unlock()
+	return pr.maxSoughtPaths
+}
+
+// RequestEntryPath requests a path to the object named `childOID`
+// via the entry called `name` in the tree named `oid`. This is
+// useful when it matters which of the object's names is reported.
+func (pr *InOrderPathResolver) RequestEntryPath(
+	oid git.OID, name string, childOID git.OID, objectType string,
+) *Path {
+	pr.lock.Lock()
+	defer pr.lock.Unlock()
+
+	parent := pr.requestPathLocked(oid, "tree", 2)
+	if parent == nil {
+		return &Path{
+			OID:         childOID,
+			objectType:  objectType,
+			seekerCount: 1,
+		}
+	}
+	return &Path{
+		OID:          childOID,
+		objectType:   objectType,
+		seekerCount:  1,
+		chainLength:  1,
+		parent:       parent,
+		relativePath: name,
+	}
+}
+
 // Record that the specified path is wanted by one less seeker. If its
 // seeker count goes to zero, remove it from `pr.soughtPaths`.
 func (pr *InOrderPathResolver) ForgetPath(p *Path) {
@@ -273,9 +459,11 @@ func (pr *InOrderPathResolver) forgetPathLocked(p *Path) {
 		// is wanted on account if this object. Decrement its
 		// seekerCount.
 		pr.forgetPathLocked(p.parent)
-	} else if p.relativePath == "" {
+	} else if p.relativePath == "" && pr.soughtPaths[p.OID] == p {
 		// We were still looking for this object's parent. Stop doing
-		// so.
+		// so. (If the object isn't in `soughtPaths` itself, then we
+		// had already stopped because of the budget, and another
+		// seeker might be looking for the same object.)
 		delete(pr.soughtPaths, p.OID)
 	}
 }
@@ -309,12 +497,16 @@ func (pr *InOrderPathResolver) RecordTreeEntry(oid git.OID, name string, childOI
 	if p.parent != nil {
 		panic("tree path parent unexpectedly filled in")
 	}
-	p.parent = pr.requestPathLocked(oid, "tree")
-
-	p.relativePath = name
 
 	// We don't need to keep looking for the child anymore:
 	delete(pr.soughtPaths, childOID)
+
+	p.parent = pr.requestPathLocked(oid, "tree", int(p.chainLength)+1)
+	if p.parent == nil {
+		// The budget is exhausted, so the path ends here.
+		return
+	}
+	p.relativePath = name
 }
 
 func (pr *InOrderPathResolver) RecordCommit(oid, tree git.OID) {
@@ -330,14 +522,43 @@ func (pr *InOrderPathResolver) RecordCommit(oid, tree git.OID) {
 	if p.parent != nil {
 		panic("commit tree parent unexpectedly filled in")
 	}
-	p.parent = pr.requestPathLocked(oid, "commit")
-
-	p.relativePath = ""
 
 	// We don't need to keep looking for the child anymore:
 	delete(pr.soughtPaths, tree)
+
+	p.parent = pr.requestPathLocked(oid, "commit", int(p.chainLength)+1)
+	p.relativePath = ""
 }
 
 func (pr *InOrderPathResolver) RecordTag(oid git.OID, tag *git.Tag) {
 	// Not implemented.
 }
+
+// RecordIndexEntry records that `entry.OID` is staged in the index,
+// naming it the way that Git does (e.g., ":README", or ":2:README"
+// for the "ours" version of a conflicted file).
+func (pr *InOrderPathResolver) RecordIndexEntry(entry git.IndexEntry) {
+	pr.RecordName(indexEntryName(entry.Path, entry.Stage), entry.OID)
+}
+
+// RecordReflogEntry records that `oid` is what the `n`th entry of the
+// reflog of `refname` (counting from the newest, which is 0) set the
+// reference to, naming it the way that Git does (e.g., "HEAD@{3}").
+func (pr *InOrderPathResolver) RecordReflogEntry(refname string, n int, oid git.OID) {
+	pr.RecordName(reflogEntryName(refname, n), oid)
+}
+
+// indexEntryName returns the name by which Git refers to the object
+// staged at `path` in stage `stage` of the index.
+func indexEntryName(path string, stage int) string {
+	if stage == 0 {
+		return ":" + path
+	}
+	return fmt.Sprintf(":%d:%s", stage, path)
+}
+
+// reflogEntryName returns the name by which Git refers to the object
+// recorded by the `n`th entry of the reflog of `refname`.
+func reflogEntryName(refname string, n int) string {
+	return fmt.Sprintf("%s@{%d}", refname, n)
+}
@@ -0,0 +1,97 @@
+package sizes
+
+import (
+	"sort"
+
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/scanpb"
+)
+
+// ScanResult returns the statistics in `s` (those that were selected
+// via `ScanOptions.Stats`, including the statistics of `refGroups`)
+// as a `scanpb.ScanResult` message, which can be encoded in the
+// protobuf wire format via its `Marshal()` method.
+func (s *HistorySize) ScanResult(refGroups []RefGroup) *scanpb.ScanResult {
+	contents := s.contents(refGroups)
+	items := make(map[string]*item)
+	contents.CollectItems(items)
+
+	symbols := make([]string, 0, len(items))
+	for symbol := range items {
+		if s.stats.Contains(symbol) {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Strings(symbols)
+
+	r := &scanpb.ScanResult{
+		ScanTime:        s.ScanTime.Unix(),
+		EmptyRepository: s.EmptyRepository,
+	}
+	for _, symbol := range symbols {
+		r.Statistics = append(r.Statistics, items[symbol].proto())
+	}
+	for _, rg := range refGroups {
+		g := &scanpb.RefGroup{
+			Symbol: string(rg.Symbol),
+			Name:   rg.Name,
+		}
+		if count, ok := s.ReferenceGroups[rg.Symbol]; ok && count != nil {
+			g.ReferenceCount, _ = count.ToUint64()
+		}
+		r.RefGroups = append(r.RefGroups, g)
+	}
+	return r
+}
+
+// proto returns `i` as a `scanpb.Statistic` message.
+func (i *item) proto() *scanpb.Statistic {
+	value, overflow := i.value.ToUint64()
+
+	stat := &scanpb.Statistic{
+		Symbol:         i.symbol,
+		Description:    i.description,
+		Value:          value,
+		Unit:           i.unit,
+		Prefixes:       i.humaner.Name(),
+		ReferenceValue: i.scale,
+		LevelOfConcern: i.concern(value),
+		Saturated:      overflow,
+		Object:         citedObjectProto(i.path),
+	}
+	if stat.Object != nil {
+		for _, symbol := range i.refGroups {
+			stat.RefGroups = append(stat.RefGroups, string(symbol))
+		}
+	}
+	for _, o := range i.top {
+		stat.TopObjects = append(stat.TopObjects, &scanpb.TopObject{
+			Value:          o.Value,
+			LevelOfConcern: i.concern(o.Value),
+			Object:         citedObjectProto(o.Object),
+		})
+	}
+	return stat
+}
+
+// citedObjectProto returns the object at `p` as a
+// `scanpb.CitedObject` message, or nil if there is none.
+func citedObjectProto(p *Path) *scanpb.CitedObject {
+	if p == nil || p.OID == git.NullOID {
+		return nil
+	}
+
+	o := &scanpb.CitedObject{
+		Name:        p.OID.String(),
+		Description: p.Path(),
+	}
+	for _, step := range p.Chain() {
+		o.Path = append(o.Path, &scanpb.PathStep{
+			ObjectType: step.ObjectType,
+			ObjectName: step.OID.String(),
+			Ref:        step.Ref,
+			Name:       step.Name,
+		})
+	}
+	return o
+}
@@ -0,0 +1,260 @@
+package sizes
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// RefUpdate is one of the reference updates of a push, as passed to
+// a `pre-receive` hook on its standard input.
+type RefUpdate struct {
+	Refname string
+	Old     git.OID
+	New     git.OID
+}
+
+// Deletion returns true iff the update deletes the reference.
+func (u RefUpdate) Deletion() bool {
+	return u.New == git.NullOID
+}
+
+// ParseRefUpdates reads reference updates in the format that Git
+// passes to `pre-receive` and `post-receive` hooks; i.e., one line per
+// update, of the form
+//
+//	<old> SP <new> SP <refname> LF
+func ParseRefUpdates(r io.Reader) ([]RefUpdate, error) {
+	var updates []RefUpdate
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		words := strings.SplitN(line, " ", 3)
+		if len(words) != 3 {
+			return nil, fmt.Errorf("malformed reference update: %q", line)
+		}
+		oldOID, err := git.NewOID(words[0])
+		if err != nil {
+			return nil, fmt.Errorf("malformed reference update: %q", line)
+		}
+		newOID, err := git.NewOID(words[1])
+		if err != nil {
+			return nil, fmt.Errorf("malformed reference update: %q", line)
+		}
+		updates = append(updates, RefUpdate{
+			Refname: words[2],
+			Old:     oldOID,
+			New:     newOID,
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading reference updates: %w", err)
+	}
+	return updates, nil
+}
+
+// PushOptions holds the settings for `ScanPush()`.
+type PushOptions struct {
+	// ScanOptions are used for the scan. Its `Exclude` field is
+	// extended with the existing references.
+	ScanOptions
+
+	// NameStyle determines how the objects cited by the statistics
+	// are named.
+	NameStyle NameStyle
+
+	// RejectThreshold is the level of concern at or above which an
+	// update is rejected. If it is zero, 30 (the most that is
+	// displayed, "!!!!!!!!!!!!!!!!!!!!!!!!!!!!!!") is used.
+	RejectThreshold Threshold
+}
+
+// Concern is a statistic that reached a level of concern of at least
+// 1 in the objects that an update adds.
+type Concern struct {
+	// Symbol is the statistic's symbol (e.g., "maxBlobSize"), and
+	// Name is its name, preceded by the names of the sections that
+	// contain it (e.g., "Biggest objects > Blobs > Maximum size").
+	Symbol string `json:"symbol"`
+	Name   string `json:"name"`
+
+	Value          uint64  `json:"value"`
+	LevelOfConcern float64 `json:"levelOfConcern"`
+
+	// Object is the object that the statistic cites, if any.
+	Object *Path `json:"object,omitempty"`
+}
+
+// UpdateVerdict holds the results for one of the updates of a push.
+type UpdateVerdict struct {
+	Update RefUpdate `json:"update"`
+
+	// Concerns lists the concerns of the whole push (see
+	// `PushResult.Size`) that the update is responsible for, in the
+	// order of the output table: those that cite an object that the
+	// update adds, and those that don't cite any object, if the
+	// update adds any objects. It is empty for a deletion.
+	Concerns []Concern `json:"concerns"`
+
+	// Rejected is true iff any of the concerns reached the
+	// `RejectThreshold`.
+	Rejected bool `json:"rejected"`
+}
+
+// PushResult holds the results of `ScanPush()`.
+type PushResult struct {
+	// Size describes all of the objects that the push adds.
+	Size HistorySize
+
+	// Verdicts holds the results for each update, in the order in
+	// which the updates were passed in.
+	Verdicts []UpdateVerdict
+}
+
+// Rejected returns true iff any of the updates was rejected.
+func (r *PushResult) Rejected() bool {
+	for _, v := range r.Verdicts {
+		if v.Rejected {
+			return true
+		}
+	}
+	return false
+}
+
+// ScanPush measures the objects that a push adds to the repository at
+// `repoPath`, for use by a `pre-receive` hook. `quarantinePath`, if
+// not empty, is the directory in which Git keeps the pushed objects
+// until the hook has accepted them (the hook's `GIT_QUARANTINE_PATH`).
+// `updates` are the reference updates of the push (see
+// `ParseRefUpdates()`).
+//
+// Only the objects that aren't reachable from any of the existing
+// references are read, so the results describe only what is new (see
+// `ScanOptions.Exclude`). They are scanned once, for the whole push,
+// and each update's verdict is derived from the concerns of the whole
+// push. An update is rejected if any of its concerns reaches
+// `opts.RejectThreshold`. If the objects that several updates add
+// raise the same statistic, it only cites the worst of them, so only
+// the update that adds that one is held responsible.
+func ScanPush(
+	ctx context.Context, repoPath, quarantinePath string, updates []RefUpdate,
+	opts PushOptions,
+) (*PushResult, error) {
+	repo, err := git.NewRepositoryFromPathWithOptions(ctx, repoPath, git.RepositoryOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if err := repo.CheckObjectFormat(ctx); err != nil {
+		return nil, err
+	}
+	if quarantinePath != "" {
+		if err := repo.SetQuarantine(ctx, quarantinePath); err != nil {
+			return nil, err
+		}
+	}
+
+	threshold := opts.RejectThreshold
+	if threshold == 0 {
+		threshold = 30
+	}
+
+	tips, err := referenceTips(ctx, repo)
+	if err != nil {
+		return nil, err
+	}
+	scanOpts := opts.ScanOptions
+	scanOpts.Exclude = append(append([]git.OID(nil), scanOpts.Exclude...), tips...)
+
+	var roots []Root
+	for _, u := range updates {
+		if !u.Deletion() {
+			roots = append(roots, NewExplicitRoot(u.Refname, u.New))
+		}
+	}
+
+	result := PushResult{
+		Verdicts: make([]UpdateVerdict, len(updates)),
+	}
+	result.Size, err = ScanRepositoryUsingGraph(
+		ctx, repo, roots, opts.NameStyle, meter.NoProgressMeter, scanOpts,
+	)
+	if err != nil {
+		return nil, err
+	}
+	concerns := result.Size.concerns()
+
+	for i, u := range updates {
+		verdict := UpdateVerdict{
+			Update:   u,
+			Concerns: []Concern{},
+		}
+		if !u.Deletion() {
+			// The objects that this update adds, if they have to
+			// be told apart from those of the other updates:
+			var added map[git.OID]struct{}
+			if len(roots) > 1 {
+				added, err = reachableObjects(
+					ctx, repo, []git.OID{u.New}, scanOpts.Exclude, meter.NoProgressMeter,
+				)
+				if err != nil {
+					return nil, err
+				}
+			}
+			for _, c := range concerns {
+				if added != nil {
+					switch {
+					case c.Object != nil:
+						if _, ok := added[c.Object.OID]; !ok {
+							continue
+						}
+					case len(added) == 0:
+						continue
+					}
+				}
+				verdict.Concerns = append(verdict.Concerns, c)
+				if c.LevelOfConcern >= float64(threshold) {
+					verdict.Rejected = true
+				}
+			}
+		}
+		result.Verdicts[i] = verdict
+	}
+
+	return &result, nil
+}
+
+// concerns returns the statistics in `s` whose level of concern is at
+// least 1.
+func (s *HistorySize) concerns() []Concern {
+	concerns := []Concern{}
+	s.contents(nil).collectDefinitions(nil, func(path []string, i *item) {
+		if !s.stats.Contains(i.symbol) {
+			return
+		}
+		value, overflow := i.value.ToUint64()
+		level := math.Inf(1)
+		if !overflow {
+			level = i.concern(value)
+		}
+		if level < 1 {
+			return
+		}
+		concerns = append(concerns, Concern{
+			Symbol:         i.symbol,
+			Name:           strings.Join(append(path, i.name), " > "),
+			Value:          value,
+			LevelOfConcern: level,
+			Object:         i.path,
+		})
+	})
+	return concerns
+}
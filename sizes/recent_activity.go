@@ -0,0 +1,167 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// RecentActivity totals, by type, the objects that were introduced
+// by the last few commits on the default branch; i.e., those that are
+// reachable from the branch but not from its Nth first-parent
+// ancestor. Objects that the commits merged in from other branches
+// count as introduced, too. Unlike the totals over the whole history,
+// which are dominated by the past, these reflect how the repository
+// is being used now.
+type RecentActivity struct {
+	// Branch is the branch that `HEAD` points at, or "HEAD" if it is
+	// detached, and Tip is the commit that it points at.
+	Branch string  `json:"branch"`
+	Tip    git.OID `json:"tip"`
+
+	// CommitLimit is the number of first-parent commits that were
+	// requested. Base is the first commit that is excluded, or is
+	// omitted if the branch has no more than `CommitLimit`
+	// first-parent commits, in which case all of its objects are
+	// counted.
+	CommitLimit counts.Count32 `json:"commit_limit"`
+	Base        *git.OID       `json:"base,omitempty"`
+
+	Commits ODBTypeTotals `json:"commits"`
+	Trees   ODBTypeTotals `json:"trees"`
+	Blobs   ODBTypeTotals `json:"blobs"`
+	Tags    ODBTypeTotals `json:"tags"`
+}
+
+// measureRecentActivity totals the objects introduced by the last
+// `limit` first-parent commits on the branch that `HEAD` points at,
+// and stores the results in `s.RecentActivity`. If `HEAD` is unborn,
+// there is nothing to measure.
+func (s *HistorySize) measureRecentActivity(
+	ctx context.Context, repo *git.Repository, limit int, progressMeter meter.Progress,
+) error {
+	head, err := repo.ResolveHead(ctx)
+	if err != nil {
+		return err
+	}
+	if head.Unborn() {
+		return nil
+	}
+
+	ra := RecentActivity{
+		Branch:      "HEAD",
+		Tip:         head.OID,
+		CommitLimit: counts.NewCount32(uint64(limit)),
+	}
+	if !head.Detached() {
+		ra.Branch = s.anonymizer.Refname(head.Target)
+	}
+	if base, err := repo.ResolveObjectContext(
+		ctx, fmt.Sprintf("%s~%d^{commit}", head.OID, limit),
+	); err == nil {
+		ra.Base = &base
+	}
+
+	objIter, err := repo.NewObjectIter(ctx)
+	if err != nil {
+		return err
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		defer objIter.Close()
+
+		errChan <- func() error {
+			if err := objIter.AddRoot(ra.Tip); err != nil {
+				return err
+			}
+			if ra.Base != nil {
+				if err := objIter.ExcludeRoot(*ra.Base); err != nil {
+					return err
+				}
+			}
+			return nil
+		}()
+	}()
+
+	progressMeter.Start("Totaling recent objects on the default branch: %d")
+	for {
+		obj, ok, err := objIter.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		progressMeter.Inc()
+
+		var t *ODBTypeTotals
+		switch obj.ObjectType {
+		case "commit":
+			t = &ra.Commits
+		case "tree":
+			t = &ra.Trees
+		case "blob":
+			t = &ra.Blobs
+		case "tag":
+			t = &ra.Tags
+		default:
+			continue
+		}
+		t.Count.Increment(1)
+		t.Size.Increment(counts.Count64(obj.ObjectSize))
+		t.DiskSize.Increment(obj.DiskSize)
+	}
+	progressMeter.Done()
+
+	if err := <-errChan; err != nil {
+		return err
+	}
+
+	s.RecentActivity = &ra
+	return nil
+}
+
+// RecentActivityTableString formats the totals of the objects that
+// were introduced by the last few commits on the default branch, or
+// returns the empty string if they weren't measured.
+func (s *HistorySize) RecentActivityTableString() string {
+	ra := s.RecentActivity
+	if ra == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(
+		buf, "\nRecent activity (objects introduced by the last %d commits on %s):\n\n",
+		ra.CommitLimit, ra.Branch,
+	)
+	fmt.Fprintln(buf, "| Type             | Objects   | Total size | Size on disk |")
+	fmt.Fprintln(buf, "| ---------------- | --------- | ---------- | ------------ |")
+	for _, row := range []struct {
+		name  string
+		total ODBTypeTotals
+	}{
+		{"Commits", ra.Commits},
+		{"Trees", ra.Trees},
+		{"Blobs", ra.Blobs},
+		{"Annotated tags", ra.Tags},
+	} {
+		fmt.Fprintf(
+			buf, "| %-16s | %9d |  %s |    %s |\n",
+			row.name, row.total.Count,
+			formatSharedBytes(row.total.Size), formatSharedBytes(row.total.DiskSize),
+		)
+	}
+	if ra.Base == nil {
+		fmt.Fprintf(
+			buf, "\n%s has no more than %d first-parent commits, so all of its objects\nare counted.\n",
+			ra.Branch, ra.CommitLimit,
+		)
+	}
+	return buf.String()
+}
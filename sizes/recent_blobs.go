@@ -0,0 +1,274 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// RecentBlob describes the blobs that were introduced into a refgroup
+// by recent commits; i.e., those that are reachable from the
+// refgroup's walked references but not from any commit older than
+// `RecentBlobs.Since`.
+type RecentBlob struct {
+	RefGroup RefGroupSymbol `json:"refgroup"`
+
+	// BlobCount and BlobSize are the number and total size of the
+	// new blobs.
+	BlobCount counts.Count32 `json:"blob_count"`
+	BlobSize  counts.Count64 `json:"blob_size"`
+
+	// MaxBlob is the largest of the new blobs, and MaxBlobSize is its
+	// size.
+	MaxBlob     git.OID        `json:"max_blob"`
+	MaxBlobSize counts.Count32 `json:"max_blob_size"`
+
+	// Commit is the earliest recent commit that added `MaxBlob`, and
+	// Path is where it added it. They are omitted if the commit
+	// couldn't be found (e.g., because the blob was added by a merge).
+	Commit *git.OID `json:"commit,omitempty"`
+	Path   string   `json:"path,omitempty"`
+}
+
+// RecentBlobs lists, for each refgroup, the largest blob that was
+// introduced by commits newer than `Since`.
+type RecentBlobs struct {
+	Since time.Time `json:"since"`
+
+	// RefGroups lists the refgroups that gained any blobs, ordered
+	// by decreasing size of their largest new blob.
+	RefGroups []RecentBlob `json:"refgroups"`
+}
+
+// findRecentBlobs finds, for each refgroup, the blobs that were
+// introduced by commits newer than `window` before the scan, and
+// stores the results in `s.RecentBlobs`. This takes one walk of the
+// recent history per refgroup.
+func (s *HistorySize) findRecentBlobs(
+	ctx context.Context, repo *git.Repository, roots []Root, window time.Duration,
+	progressMeter meter.Progress,
+) error {
+	groupRoots := make(map[RefGroupSymbol][]git.OID)
+	for _, root := range roots {
+		refRoot, ok := root.(ReferenceRoot)
+		if !ok || !root.Walk() {
+			continue
+		}
+		for _, group := range refRoot.Groups() {
+			if group == "" {
+				// Skip the top-level group, which contains
+				// everything.
+				continue
+			}
+			groupRoots[group] = append(groupRoots[group], root.OID())
+		}
+	}
+
+	groups := make([]RefGroupSymbol, 0, len(groupRoots))
+	for group := range groupRoots {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i] < groups[j] })
+
+	since := s.ScanTime.Add(-window)
+	recent := RecentBlobs{
+		Since:     since,
+		RefGroups: []RecentBlob{},
+	}
+	for _, group := range groups {
+		rb, err := recentBlobs(ctx, repo, group, groupRoots[group], since, progressMeter)
+		if err != nil {
+			return err
+		}
+		if rb.BlobCount == 0 {
+			continue
+		}
+		commit, path, ok, err := blobIntroduction(ctx, repo, rb.MaxBlob, groupRoots[group], since)
+		if err != nil {
+			return err
+		}
+		if ok {
+			rb.Commit = &commit
+			rb.Path = s.anonymizer.Path(path)
+		}
+		recent.RefGroups = append(recent.RefGroups, rb)
+	}
+
+	sort.SliceStable(recent.RefGroups, func(i, j int) bool {
+		return recent.RefGroups[i].MaxBlobSize > recent.RefGroups[j].MaxBlobSize
+	})
+
+	s.RecentBlobs = &recent
+	return nil
+}
+
+// recentBlobs returns the number and total size of the blobs that
+// are reachable from `oids` but not from any commit older than
+// `since`, and the largest of them.
+func recentBlobs(
+	ctx context.Context, repo *git.Repository, group RefGroupSymbol, oids []git.OID,
+	since time.Time, progressMeter meter.Progress,
+) (RecentBlob, error) {
+	oldCommits, err := repo.CommitsBefore(ctx, oids, since)
+	if err != nil {
+		return RecentBlob{}, err
+	}
+
+	objIter, err := repo.NewObjectIter(ctx)
+	if err != nil {
+		return RecentBlob{}, err
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		defer objIter.Close()
+
+		errChan <- func() error {
+			for _, oid := range oids {
+				if err := objIter.AddRoot(oid); err != nil {
+					return err
+				}
+			}
+			for _, oid := range oldCommits {
+				if err := objIter.ExcludeRoot(oid); err != nil {
+					return err
+				}
+			}
+			return nil
+		}()
+	}()
+
+	rb := RecentBlob{RefGroup: group}
+
+	progressMeter.Start(fmt.Sprintf("Finding recent blobs in refgroup %s: %%d", group))
+	for {
+		obj, ok, err := objIter.Next()
+		if err != nil {
+			return RecentBlob{}, err
+		}
+		if !ok {
+			break
+		}
+		progressMeter.Inc()
+		if obj.ObjectType != "blob" {
+			continue
+		}
+		rb.BlobCount.Increment(1)
+		rb.BlobSize.Increment(counts.Count64(obj.ObjectSize))
+		if rb.BlobCount == 1 || obj.ObjectSize > rb.MaxBlobSize {
+			rb.MaxBlob = obj.OID
+			rb.MaxBlobSize = obj.ObjectSize
+		}
+	}
+	progressMeter.Done()
+
+	if err := <-errChan; err != nil {
+		return RecentBlob{}, err
+	}
+
+	return rb, nil
+}
+
+// blobIntroduction runs `git log --find-object` to find the earliest
+// commit newer than `since` and reachable from `tips` that added
+// `blob`, and the path at which it added it. It returns false if
+// there is no such commit.
+func blobIntroduction(
+	ctx context.Context, repo *git.Repository, blob git.OID, tips []git.OID, since time.Time,
+) (git.OID, string, bool, error) {
+	revs := &bytes.Buffer{}
+	for _, oid := range tips {
+		fmt.Fprintf(revs, "%s\n", oid)
+	}
+
+	cmd := repo.GitCommandContext(
+		ctx, "log", "--stdin", "--no-renames", "--no-abbrev", "--raw", "-z",
+		"--pretty=format:%H", fmt.Sprintf("--since=%d", since.Unix()),
+		"--find-object="+blob.String(),
+	)
+	cmd.Stdin = revs
+	out, err := cmd.Output()
+	if err != nil {
+		return git.NullOID, "", false, fmt.Errorf("finding the commit that added %s: %w", blob, err)
+	}
+
+	// The output consists of NUL-terminated fields. Each commit
+	// starts with its name, followed by an LF and the header of its
+	// first change, like ":000000 100644 <old> <new> A". Each header
+	// is followed by the path that it applies to. The commits are
+	// listed newest first, so the last match wins:
+	var commit, found git.OID
+	var path string
+	var ok bool
+	fields := strings.Split(string(out), "\x00")
+	for i := 0; i < len(fields); i++ {
+		header := fields[i]
+		if j := strings.LastIndexByte(header, '\n'); j >= 0 {
+			if name := strings.Trim(header[:j], "\n"); name != "" {
+				oid, err := git.NewOID(name)
+				if err != nil {
+					return git.NullOID, "", false, fmt.Errorf(
+						"unexpected output from 'git log': %q", header,
+					)
+				}
+				commit = oid
+			}
+			header = header[j+1:]
+		}
+		if !strings.HasPrefix(header, ":") {
+			continue
+		}
+		words := strings.Fields(header[1:])
+		if len(words) != 5 || i+1 >= len(fields) {
+			return git.NullOID, "", false, fmt.Errorf("unexpected output from 'git log': %q", header)
+		}
+		i++
+		if words[3] == blob.String() {
+			found, path, ok = commit, fields[i], true
+		}
+	}
+
+	return found, path, ok, nil
+}
+
+// RecentBlobsTableString returns a table showing the largest blob
+// that recent commits introduced into each refgroup, or the empty
+// string if they weren't looked for.
+func (s *HistorySize) RecentBlobsTableString() string {
+	if s.RecentBlobs == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(
+		buf, "\nLargest blobs added to each refgroup since %s:\n\n",
+		s.RecentBlobs.Since.UTC().Format("2006-01-02 15:04:05 MST"),
+	)
+	if len(s.RecentBlobs.RefGroups) == 0 {
+		fmt.Fprintln(buf, "No blobs were added to any refgroup.")
+		return buf.String()
+	}
+	fmt.Fprintln(buf, "| Refgroup         | New blobs | Total size | Largest   | Blob")
+	fmt.Fprintln(buf, "| ---------------- | --------- | ---------- | --------- | ----")
+	for _, rb := range s.RecentBlobs.RefGroups {
+		blob := rb.MaxBlob.String()
+		if rb.Commit != nil {
+			blob = fmt.Sprintf("%s (%s:%s)", blob, rb.Commit, rb.Path)
+		}
+		fmt.Fprintf(
+			buf, "| %-16s | %9d |  %s | %s | %s\n",
+			rb.RefGroup, rb.BlobCount,
+			formatSharedBytes(rb.BlobSize),
+			formatSharedBytes(counts.Count64(rb.MaxBlobSize)),
+			blob,
+		)
+	}
+	return buf.String()
+}
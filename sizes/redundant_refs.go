@@ -0,0 +1,193 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// maxTableRedundantRefs is the most branches that are listed in the
+// table of redundant branches. The JSON output lists all of them.
+const maxTableRedundantRefs = 50
+
+// RedundantRefs describes the walked branches that are redundant:
+// those whose tips have the same root tree as another branch's tip
+// (deleting them loses none of the files at their tips, though it
+// may lose commits), and those whose tips are strict ancestors of
+// another branch's tip (deleting them loses nothing). Reference
+// cleanups usually start with these.
+type RedundantRefs struct {
+	// BranchCount is the number of walked branches whose tips are
+	// commits.
+	BranchCount counts.Count32 `json:"branch_count"`
+
+	// RedundantCount is the number of those branches that are
+	// redundant for either reason; SameTreeCount and AncestorCount
+	// are the numbers of branches that are redundant for each
+	// reason.
+	RedundantCount counts.Count32 `json:"redundant_count"`
+	SameTreeCount  counts.Count32 `json:"same_tree_count"`
+	AncestorCount  counts.Count32 `json:"ancestor_count"`
+
+	// Refs lists the redundant branches, by refname.
+	Refs []RedundantRef `json:"refs"`
+}
+
+// RedundantRef is a branch that is redundant, and why.
+type RedundantRef struct {
+	Refname string `json:"refname"`
+
+	// SameTreeAs, if set, is another branch whose tip has the same
+	// root tree as this one's. Of the branches whose tips share a
+	// tree, the first one (by refname) that isn't an ancestor of
+	// another branch is kept, and the others are redundant.
+	SameTreeAs string `json:"same_tree_as,omitempty"`
+
+	// Ancestor is true if the tip of this branch is a strict
+	// ancestor of another branch's tip.
+	Ancestor bool `json:"ancestor"`
+}
+
+// findRedundantRefs looks for redundant branches among the walked
+// references in `roots`, and stores the results in
+// `s.RedundantRefs`. This takes one walk of the commit history.
+func (s *HistorySize) findRedundantRefs(
+	ctx context.Context, repo *git.Repository, roots []Root,
+) error {
+	var refnames []string
+	var tips []git.OID
+	for _, root := range roots {
+		refRoot, ok := root.(ReferenceRoot)
+		if !ok || !root.Walk() {
+			continue
+		}
+		refname := refRoot.Reference().Refname
+		if !strings.HasPrefix(refname, "refs/heads/") {
+			continue
+		}
+		refnames = append(refnames, refname)
+		tips = append(tips, root.OID())
+	}
+
+	trees, err := repo.RootTrees(ctx, tips)
+	if err != nil {
+		return err
+	}
+
+	// The branches whose tips are commits, by refname:
+	type branch struct {
+		refname string
+		tip     git.OID
+		tree    git.OID
+	}
+	var branches []branch
+	var commits []git.OID
+	for i, tree := range trees {
+		if tree == git.NullOID {
+			continue
+		}
+		branches = append(branches, branch{refname: refnames[i], tip: tips[i], tree: tree})
+		commits = append(commits, tips[i])
+	}
+	sort.Slice(branches, func(i, j int) bool { return branches[i].refname < branches[j].refname })
+
+	ancestors, err := repo.StrictAncestors(ctx, commits)
+	if err != nil {
+		return err
+	}
+
+	byTree := make(map[git.OID][]branch)
+	for _, b := range branches {
+		byTree[b.tree] = append(byTree[b.tree], b)
+	}
+	sameTreeAs := make(map[string]string)
+	for _, group := range byTree {
+		if len(group) < 2 {
+			continue
+		}
+		// Keep a branch that isn't redundant for the other reason,
+		// if there is one:
+		kept := group[0].refname
+		for _, b := range group {
+			if !ancestors[b.tip] {
+				kept = b.refname
+				break
+			}
+		}
+		for _, b := range group {
+			if b.refname != kept {
+				sameTreeAs[b.refname] = kept
+			}
+		}
+	}
+
+	r := RedundantRefs{
+		BranchCount: counts.NewCount32(uint64(len(branches))),
+		Refs:        []RedundantRef{},
+	}
+	for _, b := range branches {
+		rr := RedundantRef{
+			Refname:  s.anonymizer.Refname(b.refname),
+			Ancestor: ancestors[b.tip],
+		}
+		if kept, ok := sameTreeAs[b.refname]; ok {
+			rr.SameTreeAs = s.anonymizer.Refname(kept)
+			r.SameTreeCount.Increment(1)
+		}
+		if rr.Ancestor {
+			r.AncestorCount.Increment(1)
+		}
+		if rr.SameTreeAs == "" && !rr.Ancestor {
+			continue
+		}
+		r.RedundantCount.Increment(1)
+		r.Refs = append(r.Refs, rr)
+	}
+
+	s.RedundantRefs = &r
+	return nil
+}
+
+// RedundantRefsTableString lists the redundant branches, or returns
+// the empty string if they weren't sought.
+func (s *HistorySize) RedundantRefsTableString() string {
+	r := s.RedundantRefs
+	if r == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nRedundant branches:\n\n")
+	if len(r.Refs) == 0 {
+		fmt.Fprintf(buf, "None of the %d branches is redundant.\n", r.BranchCount)
+		return buf.String()
+	}
+
+	fmt.Fprintln(buf, "| Branch                                   | Redundant because")
+	fmt.Fprintln(buf, "| ---------------------------------------- | -----------------")
+	for i, rr := range r.Refs {
+		if i == maxTableRedundantRefs {
+			fmt.Fprintf(buf, "     ... and %d more branches\n", len(r.Refs)-i)
+			break
+		}
+		var reasons []string
+		if rr.SameTreeAs != "" {
+			reasons = append(reasons, "same tree as "+rr.SameTreeAs)
+		}
+		if rr.Ancestor {
+			reasons = append(reasons, "ancestor of another branch")
+		}
+		fmt.Fprintf(buf, "| %-40s | %s\n", rr.Refname, strings.Join(reasons, "; "))
+	}
+	fmt.Fprintf(
+		buf, "\n%d of %d branches are redundant: %d have the same tree as another branch,\n"+
+			"and %d are strict ancestors of another branch.\n",
+		r.RedundantCount, r.BranchCount, r.SameTreeCount, r.AncestorCount,
+	)
+	return buf.String()
+}
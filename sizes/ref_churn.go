@@ -0,0 +1,138 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// maxRefChurnRefs is the maximum number of references listed in
+// `RefChurn.TopRefs`.
+const maxRefChurnRefs = 10
+
+// RefChurn describes how often references were updated recently,
+// according to their reflogs. References that are updated very often
+// (e.g., a branch to which CI pushes status commits) cause many small
+// packs to be received, and so drive repository maintenance.
+type RefChurn struct {
+	// Days is the length of the period, ending at the scan, whose
+	// updates are counted.
+	Days counts.Count32 `json:"days"`
+
+	// UpdateCount is the total number of updates to all
+	// references in the period, and RefCount is the number of
+	// references that were updated at least once.
+	UpdateCount counts.Count64 `json:"update_count"`
+	RefCount    counts.Count32 `json:"ref_count"`
+
+	// TopRefs lists the most frequently updated references, most
+	// frequently updated first.
+	TopRefs []RefUpdates `json:"top_refs"`
+}
+
+// RefUpdates is the number of times that one reference was updated
+// in the period of a `RefChurn`.
+type RefUpdates struct {
+	Refname       string         `json:"refname"`
+	UpdateCount   counts.Count64 `json:"update_count"`
+	UpdatesPerDay float64        `json:"updates_per_day"`
+	LastUpdate    time.Time      `json:"last_update"`
+}
+
+// measureRefChurn counts the updates to each reference in the
+// `period` before the scan, going by the reflogs, and stores the
+// results in `s.RefChurn`. The reflog of `HEAD` is ignored, because
+// it records checkouts as well as updates to the current branch,
+// which are already recorded in the branch's own reflog.
+func (s *HistorySize) measureRefChurn(
+	ctx context.Context, repo *git.Repository, period time.Duration,
+) error {
+	entries, err := repo.ReflogEntries(ctx)
+	if err != nil {
+		return err
+	}
+
+	cutoff := s.ScanTime.Add(-period)
+	days := period / (24 * time.Hour)
+	c := RefChurn{
+		Days:    counts.NewCount32(uint64(days)),
+		TopRefs: []RefUpdates{},
+	}
+
+	updates := make(map[string]*RefUpdates)
+	for _, entry := range entries {
+		if entry.Refname == "HEAD" || entry.Time.Before(cutoff) {
+			continue
+		}
+		c.UpdateCount.Increment(1)
+		u, ok := updates[entry.Refname]
+		if !ok {
+			u = &RefUpdates{Refname: s.anonymizer.Refname(entry.Refname)}
+			updates[entry.Refname] = u
+		}
+		u.UpdateCount.Increment(1)
+		if entry.Time.After(u.LastUpdate) {
+			u.LastUpdate = entry.Time.UTC()
+		}
+	}
+	c.RefCount = counts.NewCount32(uint64(len(updates)))
+
+	refs := make([]*RefUpdates, 0, len(updates))
+	for _, u := range updates {
+		if days > 0 {
+			u.UpdatesPerDay = float64(u.UpdateCount) / float64(days)
+		}
+		refs = append(refs, u)
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].UpdateCount != refs[j].UpdateCount {
+			return refs[i].UpdateCount > refs[j].UpdateCount
+		}
+		return refs[i].Refname < refs[j].Refname
+	})
+	for _, u := range refs {
+		if len(c.TopRefs) == maxRefChurnRefs {
+			break
+		}
+		c.TopRefs = append(c.TopRefs, *u)
+	}
+
+	s.RefChurn = &c
+	return nil
+}
+
+// RefChurnTableString lists the most frequently updated references,
+// or returns the empty string if the updates weren't counted.
+func (s *HistorySize) RefChurnTableString() string {
+	c := s.RefChurn
+	if c == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nReference updates in the last %d days, according to the reflogs:\n\n", c.Days)
+	if c.UpdateCount == 0 {
+		fmt.Fprintln(
+			buf,
+			"No updates were logged. (Bare repositories don't keep reflogs unless\n"+
+				"'core.logAllRefUpdates' is set.)",
+		)
+		return buf.String()
+	}
+
+	fmt.Fprintln(buf, "| Reference                                | Updates   | Per day   | Last update")
+	fmt.Fprintln(buf, "| ---------------------------------------- | --------- | --------- | -----------")
+	for _, u := range c.TopRefs {
+		fmt.Fprintf(
+			buf, "| %-40s | %9d | %9.1f | %s\n",
+			u.Refname, u.UpdateCount, u.UpdatesPerDay, u.LastUpdate.Format("2006-01-02"),
+		)
+	}
+	fmt.Fprintf(buf, "\n%d updates to %d references in total.\n", c.UpdateCount, c.RefCount)
+	return buf.String()
+}
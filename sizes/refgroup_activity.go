@@ -0,0 +1,258 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// activityProfileLimit is the maximum number of (the most recent)
+// periods that are shown in the activity profiles of
+// `RefGroupActivityTableString()`.
+const activityProfileLimit = 24
+
+// activityLevels are the characters used to draw the activity
+// profiles, from no commits to the most commits in any one period.
+var activityLevels = []rune(".▁▂▃▄▅▆▇█")
+
+// ActivityPeriod is the length of the periods into which the commits
+// of each refgroup are bucketed for `HistorySize.RefGroupActivity`.
+type ActivityPeriod string
+
+const (
+	ActivityYear  ActivityPeriod = "year"
+	ActivityMonth ActivityPeriod = "month"
+)
+
+// ParseActivityPeriod parses the name of an activity period. The
+// empty string means that the activity isn't wanted.
+func ParseActivityPeriod(s string) (ActivityPeriod, error) {
+	switch p := ActivityPeriod(s); p {
+	case "", ActivityYear, ActivityMonth:
+		return p, nil
+	default:
+		return "", fmt.Errorf(
+			"unknown activity period '%s' (must be 'year' or 'month')", s,
+		)
+	}
+}
+
+// index returns the number of the period (counting from year 0) that
+// contains the time `t` (in seconds since the epoch), in UTC.
+func (p ActivityPeriod) index(t int64) int {
+	tm := time.Unix(t, 0).UTC()
+	if p == ActivityMonth {
+		return 12*tm.Year() + int(tm.Month()) - 1
+	}
+	return tm.Year()
+}
+
+// label returns the name of the period whose number is `i`; e.g.,
+// "2024" or "2024-03".
+func (p ActivityPeriod) label(i int) string {
+	if p == ActivityMonth {
+		return fmt.Sprintf("%04d-%02d", i/12, i%12+1)
+	}
+	return fmt.Sprintf("%04d", i)
+}
+
+// RefGroupActivity holds the number of commits that were committed in
+// each period (in UTC), for each refgroup. Refgroups that have had no
+// commits for a long time are candidates for archiving.
+type RefGroupActivity struct {
+	Period    ActivityPeriod           `json:"period"`
+	RefGroups []RefGroupCommitActivity `json:"ref_groups"`
+}
+
+// RefGroupCommitActivity holds the commits that are reachable from
+// the walked references in one refgroup, bucketed by period.
+type RefGroupCommitActivity struct {
+	RefGroup    RefGroupSymbol `json:"refgroup"`
+	CommitCount counts.Count64 `json:"commit_count"`
+
+	// LastCommitTime is the newest committer time of the commits, or
+	// nil if there are none.
+	LastCommitTime *time.Time `json:"last_commit_time,omitempty"`
+
+	// Buckets are the periods in which there were commits, oldest
+	// first.
+	Buckets []ActivityBucket `json:"buckets"`
+}
+
+// ActivityBucket is the number of commits in one period.
+type ActivityBucket struct {
+	// Period is the name of the period, like "2024" or "2024-03".
+	Period      string         `json:"period"`
+	CommitCount counts.Count64 `json:"commit_count"`
+
+	index int
+}
+
+// computeRefGroupActivity buckets the commits that are reachable from
+// the walked references in each refgroup (other than the top-level
+// group) by `period`, and stores the results in `s.RefGroupActivity`.
+// This takes one walk of the commit history per refgroup.
+func (s *HistorySize) computeRefGroupActivity(
+	ctx context.Context, repo *git.Repository, roots []Root, period ActivityPeriod,
+	progressMeter meter.Progress,
+) error {
+	groupRoots := make(map[RefGroupSymbol][]git.OID)
+	for _, root := range roots {
+		refRoot, ok := root.(ReferenceRoot)
+		if !ok || !root.Walk() {
+			continue
+		}
+		for _, group := range refRoot.Groups() {
+			if group != "" {
+				groupRoots[group] = append(groupRoots[group], root.OID())
+			}
+		}
+	}
+
+	groups := make([]RefGroupSymbol, 0, len(groupRoots))
+	for group := range groupRoots {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i] < groups[j] })
+
+	activity := RefGroupActivity{
+		Period:    period,
+		RefGroups: make([]RefGroupCommitActivity, 0, len(groups)),
+	}
+
+	progressMeter.Start("Bucketing commits by refgroup: %d")
+	for _, group := range groups {
+		times, err := repo.CommitTimes(ctx, groupRoots[group])
+		if err != nil {
+			progressMeter.Done()
+			return err
+		}
+		progressMeter.Inc()
+
+		ga := RefGroupCommitActivity{
+			RefGroup:    group,
+			CommitCount: counts.Count64(len(times)),
+			Buckets:     []ActivityBucket{},
+		}
+		buckets := make(map[int]counts.Count64)
+		var last int64
+		for i, t := range times {
+			buckets[period.index(t)]++
+			if i == 0 || t > last {
+				last = t
+			}
+		}
+		if len(times) > 0 {
+			lastTime := time.Unix(last, 0).UTC()
+			ga.LastCommitTime = &lastTime
+		}
+		for i, n := range buckets {
+			ga.Buckets = append(ga.Buckets, ActivityBucket{
+				Period:      period.label(i),
+				CommitCount: n,
+				index:       i,
+			})
+		}
+		sort.Slice(ga.Buckets, func(i, j int) bool {
+			return ga.Buckets[i].index < ga.Buckets[j].index
+		})
+		activity.RefGroups = append(activity.RefGroups, ga)
+	}
+	progressMeter.Done()
+
+	s.RefGroupActivity = &activity
+	return nil
+}
+
+// activityProfile draws the number of commits in `ga` in each of the
+// periods from `first` to `last` (inclusive) as one character per
+// period, scaled relative to the busiest period of the refgroup.
+func activityProfile(ga RefGroupCommitActivity, first, last int) string {
+	busiest := counts.Count64(1)
+	for _, b := range ga.Buckets {
+		if b.CommitCount > busiest {
+			busiest = b.CommitCount
+		}
+	}
+
+	commits := make(map[int]counts.Count64, len(ga.Buckets))
+	for _, b := range ga.Buckets {
+		commits[b.index] = b.CommitCount
+	}
+
+	bars := uint64(len(activityLevels) - 1)
+	var sb strings.Builder
+	for i := first; i <= last; i++ {
+		n := commits[i]
+		// Round up, so that any commits at all show up as at least
+		// the lowest bar:
+		level := int((uint64(n)*bars + uint64(busiest) - 1) / uint64(busiest))
+		sb.WriteRune(activityLevels[level])
+	}
+	return sb.String()
+}
+
+// RefGroupActivityTableString returns a table showing the commit
+// activity of each refgroup, or the empty string if it wasn't
+// requested.
+func (s *HistorySize) RefGroupActivityTableString() string {
+	a := s.RefGroupActivity
+	if a == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nCommits per %s, by refgroup:\n\n", a.Period)
+	if len(a.RefGroups) == 0 {
+		fmt.Fprintln(buf, "No refgroups have walked references.")
+		return buf.String()
+	}
+
+	// All of the profiles cover the same periods, so that they can
+	// be compared:
+	first, last := 0, 0
+	for _, ga := range a.RefGroups {
+		for _, b := range ga.Buckets {
+			if first == 0 || b.index < first {
+				first = b.index
+			}
+			if b.index > last {
+				last = b.index
+			}
+		}
+	}
+	if last-first >= activityProfileLimit {
+		first = last - activityProfileLimit + 1
+	}
+
+	profileHeader := "Activity"
+	if last != 0 {
+		profileHeader = fmt.Sprintf(
+			"Activity (%s to %s)", a.Period.label(first), a.Period.label(last),
+		)
+	}
+
+	fmt.Fprintf(buf, "| Refgroup         | Commits   | Last commit | %s\n", profileHeader)
+	fmt.Fprintf(buf, "| ---------------- | --------- | ----------- | %s\n", strings.Repeat("-", len(profileHeader)))
+	for _, ga := range a.RefGroups {
+		lastCommit := "never"
+		profile := ""
+		if ga.LastCommitTime != nil {
+			lastCommit = ga.LastCommitTime.Format("2006-01-02")
+			profile = activityProfile(ga, first, last)
+		}
+		fmt.Fprintf(
+			buf, "| %-16s | %s | %-11s | %s\n",
+			ga.RefGroup, formatGrowthValue(ga.CommitCount, &counts.Metric, ""),
+			lastCommit, profile,
+		)
+	}
+	return buf.String()
+}
@@ -0,0 +1,173 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// defaultReflogExpireUnreachable is Git's default for the
+// `gc.reflogExpireUnreachable` setting.
+const defaultReflogExpireUnreachable = "30.days.ago"
+
+// ReflogOnly describes the objects that are reachable from reflogs
+// (including the stash, and the reflog of `HEAD`, which remembers
+// the commits of deleted branches), but not from any reference. Git
+// keeps these objects until the reflog entries expire, so they are
+// data that can safely be reclaimed.
+type ReflogOnly struct {
+	// EntryCount is the number of reflog entries, and ObjectCount
+	// and DiskSize are the number of objects that are reachable
+	// only from them and the bytes that those objects occupy on
+	// disk.
+	EntryCount  counts.Count32 `json:"entry_count"`
+	ObjectCount counts.Count64 `json:"object_count"`
+	DiskSize    counts.Count64 `json:"disk_size"`
+
+	// ExpireAge is the age, in days, beyond which the reflog entries
+	// were considered for expiry. ExpirableEntryCount is the number
+	// of entries that are older than that, and ExpirableObjectCount
+	// and ExpirableDiskSize describe the objects that would become
+	// unreachable if those entries were expired.
+	ExpireAge            counts.Count32 `json:"expire_age"`
+	ExpirableEntryCount  counts.Count32 `json:"expirable_entry_count"`
+	ExpirableObjectCount counts.Count64 `json:"expirable_object_count"`
+	ExpirableDiskSize    counts.Count64 `json:"expirable_disk_size"`
+
+	// ExpireUnreachable is the repository's current setting of
+	// `gc.reflogExpireUnreachable`, which determines when Git expires
+	// reflog entries whose objects aren't otherwise reachable.
+	ExpireUnreachable string `json:"expire_unreachable"`
+}
+
+// measureReflogOnly computes the sizes of the objects that are
+// reachable only from reflogs, overall and from the entries that are
+// more than `age` old, and stores the results in `s.ReflogOnly`.
+func (s *HistorySize) measureReflogOnly(
+	ctx context.Context, repo *git.Repository, age time.Duration,
+) error {
+	entries, err := repo.ReflogEntries(ctx)
+	if err != nil {
+		return err
+	}
+
+	expireUnreachable, err := repo.ConfigStringDefaultContext(
+		ctx, "gc.reflogExpireUnreachable", defaultReflogExpireUnreachable,
+	)
+	if err != nil {
+		return err
+	}
+
+	excluded, err := referenceTips(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	cutoff := s.ScanTime.Add(-age)
+	var all, expirable, retained []git.OID
+	r := ReflogOnly{
+		EntryCount:        counts.NewCount32(uint64(len(entries))),
+		ExpireAge:         counts.NewCount32(uint64(age / (24 * time.Hour))),
+		ExpireUnreachable: expireUnreachable,
+	}
+	for _, entry := range entries {
+		oids := make([]git.OID, 0, 2)
+		for _, oid := range []git.OID{entry.Old, entry.New} {
+			if oid != git.NullOID {
+				oids = append(oids, oid)
+			}
+		}
+		all = append(all, oids...)
+		if entry.Time.Before(cutoff) {
+			r.ExpirableEntryCount.Increment(1)
+			expirable = append(expirable, oids...)
+		} else {
+			retained = append(retained, oids...)
+		}
+	}
+
+	r.ObjectCount, r.DiskSize, err = repo.CountReachable(ctx, all, excluded)
+	if err != nil {
+		return err
+	}
+	r.ExpirableObjectCount, r.ExpirableDiskSize, err = repo.CountReachable(
+		ctx, expirable, append(excluded, retained...),
+	)
+	if err != nil {
+		return err
+	}
+
+	s.ReflogOnly = &r
+	return nil
+}
+
+// referenceTips returns the objects that all of the references in
+// `repo`, plus `HEAD`, point at. These are what keep objects
+// reachable when the reflogs are expired.
+func referenceTips(ctx context.Context, repo *git.Repository) ([]git.OID, error) {
+	iter, err := repo.NewReferenceIterWithoutDates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var tips []git.OID
+	for {
+		ref, ok, err := iter.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		tips = append(tips, ref.OID)
+	}
+
+	// `HEAD` might be detached, or unborn:
+	if oid, err := repo.ResolveObjectContext(ctx, "HEAD"); err == nil {
+		tips = append(tips, oid)
+	}
+
+	return tips, nil
+}
+
+// ReflogOnlyString describes the objects that are reachable only from
+// reflogs, or returns the empty string if they weren't measured.
+func (s *HistorySize) ReflogOnlyString() string {
+	r := s.ReflogOnly
+	if r == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(
+		buf, "\nObjects reachable only from reflogs (including the stash and deleted branches):\n\n",
+	)
+	fmt.Fprintln(buf, "| Reflog entries         | Entries   | Objects   | Size on disk")
+	fmt.Fprintln(buf, "| ---------------------- | --------- | --------- | ------------")
+	row := func(name string, entryCount counts.Count32, objectCount, diskSize counts.Count64) {
+		fmt.Fprintf(
+			buf, "| %-22s | %9d | %9d | %s\n",
+			name, entryCount, objectCount, formatSharedBytes(diskSize),
+		)
+	}
+	row("All", r.EntryCount, r.ObjectCount, r.DiskSize)
+	row(fmt.Sprintf("Older than %d days", r.ExpireAge),
+		r.ExpirableEntryCount, r.ExpirableObjectCount, r.ExpirableDiskSize)
+
+	if r.ExpirableObjectCount != 0 {
+		fmt.Fprintf(
+			buf,
+			"\nTo reclaim the space held by reflog entries older than %d days, run\n\n"+
+				"     git reflog expire --expire-unreachable=%d.days.ago --all\n"+
+				"     git gc --prune=now\n\n"+
+				"To have 'git gc' expire them routinely, set 'gc.reflogExpireUnreachable'\n"+
+				"to '%d.days.ago' (currently '%s').\n",
+			r.ExpireAge, r.ExpireAge, r.ExpireAge, r.ExpireUnreachable,
+		)
+	}
+	return buf.String()
+}
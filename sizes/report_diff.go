@@ -0,0 +1,259 @@
+package sizes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+)
+
+// Report is a JSON report that was saved by an earlier scan (using
+// `--json` or `--tee-json`), of either version. Reports can be
+// compared using `DiffReports()` without the repository.
+type Report struct {
+	// Version is the version of the JSON output (1 or 2).
+	Version int
+
+	// Stats holds the statistics in the report, by symbol (or, for
+	// version 1 reports, by JSON field name).
+	Stats map[string]ReportStat
+
+	// RefGroupTotals holds the refgroup totals, if they were
+	// computed (e.g., using `--save-baseline` or `--baseline`).
+	RefGroupTotals map[RefGroupSymbol]RefGroupTotal
+}
+
+// ReportStat is the value of one statistic in a `Report`.
+type ReportStat struct {
+	Description string `json:"description,omitempty"`
+	Value       uint64 `json:"value"`
+	Unit        string `json:"unit"`
+	Prefixes    string `json:"prefixes"`
+}
+
+// ReadReport reads a JSON report from the file at `path`, which may
+// be gzipped.
+func ReadReport(path string) (*Report, error) {
+	buf, err := readDecompressing(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading report: %w", err)
+	}
+	r, err := ParseReport(buf)
+	if err != nil {
+		return nil, fmt.Errorf("reading report %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// ParseReport parses a JSON report. Version 2 reports are recognized
+// by their "effectiveConfig" member; anything else is taken to be a
+// version 1 report.
+func ParseReport(buf []byte) (*Report, error) {
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(buf, &m); err != nil {
+		return nil, err
+	}
+
+	r := Report{Stats: make(map[string]ReportStat)}
+	if _, ok := m["effectiveConfig"]; ok {
+		r.Version = 2
+		for key, raw := range m {
+			if key == "refgroupTotals" {
+				if err := json.Unmarshal(raw, &r.RefGroupTotals); err != nil {
+					return nil, fmt.Errorf("parsing '%s': %w", key, err)
+				}
+				continue
+			}
+			if checkStatSymbol(key) != nil {
+				continue
+			}
+			var stat ReportStat
+			if err := json.Unmarshal(raw, &stat); err != nil {
+				return nil, fmt.Errorf("parsing '%s': %w", key, err)
+			}
+			r.Stats[key] = stat
+		}
+		return &r, nil
+	}
+
+	r.Version = 1
+	for key, raw := range m {
+		switch key {
+		case "ref_group_totals":
+			if err := json.Unmarshal(raw, &r.RefGroupTotals); err != nil {
+				return nil, fmt.Errorf("parsing '%s': %w", key, err)
+			}
+			continue
+		}
+
+		// Version 1 reports hold the statistics as plain numbers,
+		// without units:
+		var value uint64
+		if err := json.Unmarshal(raw, &value); err != nil {
+			continue
+		}
+		stat := ReportStat{Value: value, Prefixes: counts.Metric.Name()}
+		if strings.HasSuffix(key, "_size") {
+			stat.Unit = "B"
+			stat.Prefixes = counts.Binary.Name()
+		}
+		r.Stats[key] = stat
+	}
+	if len(r.Stats) == 0 {
+		return nil, fmt.Errorf("no statistics found; is this a git-sizer JSON report?")
+	}
+	return &r, nil
+}
+
+// StatDelta is the change in one statistic between two reports.
+type StatDelta struct {
+	Symbol      string `json:"symbol"`
+	Description string `json:"description,omitempty"`
+	Unit        string `json:"unit"`
+	Prefixes    string `json:"prefixes"`
+	Before      uint64 `json:"before"`
+	After       uint64 `json:"after"`
+	Change      int64  `json:"change"`
+}
+
+// ReportDiff describes the differences between two reports.
+type ReportDiff struct {
+	// Growth is the growth of each refgroup, if both reports hold
+	// refgroup totals. It is ordered like `HistorySize.Growth`. Its
+	// `Since` is unset, because reports don't record when they were
+	// made.
+	Growth *Growth `json:"growth,omitempty"`
+
+	// Stats lists the statistics that appear in both reports, in
+	// the order of the table output.
+	Stats []StatDelta `json:"stats"`
+
+	// OnlyBefore and OnlyAfter list the statistics that appear in
+	// only the first or only the second report, respectively.
+	OnlyBefore []string `json:"only_before,omitempty"`
+	OnlyAfter  []string `json:"only_after,omitempty"`
+}
+
+// DiffReports compares the reports `before` and `after`, which must
+// be of the same version.
+func DiffReports(before, after *Report) (*ReportDiff, error) {
+	if before.Version != after.Version {
+		return nil, fmt.Errorf(
+			"can't compare a version %d report with a version %d report",
+			before.Version, after.Version,
+		)
+	}
+
+	d := ReportDiff{Stats: []StatDelta{}}
+
+	if before.RefGroupTotals != nil && after.RefGroupTotals != nil {
+		s := HistorySize{RefGroupTotals: after.RefGroupTotals}
+		s.computeGrowth(&Baseline{RefGroups: before.RefGroupTotals})
+		d.Growth = s.Growth
+	}
+
+	for _, symbol := range reportStatOrder(before, after) {
+		b, inBefore := before.Stats[symbol]
+		a, inAfter := after.Stats[symbol]
+		switch {
+		case !inAfter:
+			d.OnlyBefore = append(d.OnlyBefore, symbol)
+		case !inBefore:
+			d.OnlyAfter = append(d.OnlyAfter, symbol)
+		default:
+			d.Stats = append(d.Stats, StatDelta{
+				Symbol:      symbol,
+				Description: a.Description,
+				Unit:        a.Unit,
+				Prefixes:    a.Prefixes,
+				Before:      b.Value,
+				After:       a.Value,
+				Change:      int64(a.Value) - int64(b.Value),
+			})
+		}
+	}
+
+	return &d, nil
+}
+
+// reportStatOrder returns the symbols of the statistics in either
+// report, in the order in which they appear in the table output,
+// followed by the rest in alphabetical order.
+func reportStatOrder(reports ...*Report) []string {
+	all := make(map[string]bool)
+	for _, r := range reports {
+		for symbol := range r.Stats {
+			all[symbol] = true
+		}
+	}
+
+	symbols := make([]string, 0, len(all))
+	for _, def := range Definitions(nil, ProfileDefault, nil) {
+		if all[def.Symbol] {
+			symbols = append(symbols, def.Symbol)
+			delete(all, def.Symbol)
+		}
+	}
+	rest := make([]string, 0, len(all))
+	for symbol := range all {
+		rest = append(rest, symbol)
+	}
+	sort.Strings(rest)
+	return append(symbols, rest...)
+}
+
+// humanerNamed returns the `Humaner` called `name`.
+func humanerNamed(name string) *counts.Humaner {
+	switch name {
+	case counts.Binary.Name():
+		return &counts.Binary
+	case counts.Plain.Name():
+		return &counts.Plain
+	default:
+		return &counts.Metric
+	}
+}
+
+// String formats `d` as tables: the growth of each refgroup, in the
+// same form as for `--baseline`, followed by the statistics that
+// changed.
+func (d *ReportDiff) String() string {
+	buf := &bytes.Buffer{}
+	if d.Growth != nil {
+		fmt.Fprintf(buf, "Growth sources between the reports:\n\n")
+		d.Growth.writeTable(buf)
+		fmt.Fprintln(buf)
+	}
+
+	unchanged := 0
+	fmt.Fprintf(buf, "Changed statistics:\n\n")
+	fmt.Fprintln(buf, "| Statistic                                | Before    | After     | Change     |")
+	fmt.Fprintln(buf, "| ---------------------------------------- | --------- | --------- | ---------- |")
+	for _, sd := range d.Stats {
+		if sd.Change == 0 {
+			unchanged++
+			continue
+		}
+		humaner := humanerNamed(sd.Prefixes)
+		fmt.Fprintf(
+			buf, "| %-40s | %s | %s | %s |\n",
+			sd.Symbol,
+			formatGrowthValue(counts.Count64(sd.Before), humaner, sd.Unit),
+			formatGrowthValue(counts.Count64(sd.After), humaner, sd.Unit),
+			formatGrowth(sd.Change, humaner, sd.Unit),
+		)
+	}
+	if unchanged != 0 {
+		fmt.Fprintf(buf, "\n%d other statistics are unchanged.\n", unchanged)
+	}
+	if len(d.OnlyBefore) != 0 {
+		fmt.Fprintf(buf, "\nOnly in the first report: %s\n", strings.Join(d.OnlyBefore, ", "))
+	}
+	if len(d.OnlyAfter) != 0 {
+		fmt.Fprintf(buf, "\nOnly in the second report: %s\n", strings.Join(d.OnlyAfter, ", "))
+	}
+	return buf.String()
+}
@@ -0,0 +1,162 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// RootMaximum holds the maxima over the objects that are reachable
+// from a single explicit root.
+type RootMaximum struct {
+	// Root is the name of the root as it was given (e.g., "main").
+	Root string  `json:"root"`
+	OID  git.OID `json:"oid"`
+
+	// MaxBlob is the largest blob reachable from the root, and
+	// MaxBlobSize is its size. MaxBlob is omitted if no blobs are
+	// reachable.
+	MaxBlob     *git.OID       `json:"max_blob,omitempty"`
+	MaxBlobSize counts.Count32 `json:"max_blob_size"`
+
+	// MaxCheckoutBlobCount is the largest number of files in the
+	// checkout of any commit reachable from the root (or of the root
+	// itself, if it is a tree), and MaxCheckoutBlobCountCommit is the
+	// commit or tree that has them.
+	MaxCheckoutBlobCount       counts.Count32 `json:"max_checkout_blob_count"`
+	MaxCheckoutBlobCountCommit *git.OID       `json:"max_checkout_blob_count_commit,omitempty"`
+
+	// MaxCheckoutBlobSize is the largest total size of the files in
+	// the checkout of any commit reachable from the root, and
+	// MaxCheckoutBlobSizeCommit is the commit or tree that has them.
+	MaxCheckoutBlobSize       counts.Count64 `json:"max_checkout_blob_size"`
+	MaxCheckoutBlobSizeCommit *git.OID       `json:"max_checkout_blob_size_commit,omitempty"`
+}
+
+// findRootMaxima finds the largest blob and the biggest checkout
+// reachable from each walked explicit root in `roots`, and stores
+// them in `s.RootMaxima`. This takes one walk of the history per
+// root. The sizes of the checkouts are looked up in `g`, so they are
+// only found if the scan collected the sizes of trees.
+func (s *HistorySize) findRootMaxima(
+	ctx context.Context, repo *git.Repository, g *Graph, roots []Root,
+	progressMeter meter.Progress,
+) error {
+	maxima := []RootMaximum{}
+	for _, root := range roots {
+		if _, ok := root.(ExplicitRoot); !ok || !root.Walk() {
+			continue
+		}
+		rm, err := s.rootMaximum(ctx, repo, g, root, progressMeter)
+		if err != nil {
+			return err
+		}
+		maxima = append(maxima, rm)
+	}
+
+	s.RootMaxima = maxima
+	return nil
+}
+
+// rootMaximum walks the history reachable from `root` and returns
+// its maxima.
+func (s *HistorySize) rootMaximum(
+	ctx context.Context, repo *git.Repository, g *Graph, root Root,
+	progressMeter meter.Progress,
+) (RootMaximum, error) {
+	objIter, err := repo.NewObjectIter(ctx)
+	if err != nil {
+		return RootMaximum{}, err
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		defer objIter.Close()
+
+		errChan <- objIter.AddRoot(root.OID())
+	}()
+
+	rm := RootMaximum{Root: s.anonymizer.Refname(root.Name()), OID: root.OID()}
+
+	recordCheckout := func(oid git.OID) {
+		checkout, ok := g.checkoutSize(oid)
+		if !ok {
+			return
+		}
+		if rm.MaxCheckoutBlobCount.AdjustMaxIfNecessary(checkout.ExpandedBlobCount) {
+			oid := oid
+			rm.MaxCheckoutBlobCountCommit = &oid
+		}
+		if rm.MaxCheckoutBlobSize.AdjustMaxIfNecessary(checkout.ExpandedBlobSize) {
+			oid := oid
+			rm.MaxCheckoutBlobSizeCommit = &oid
+		}
+	}
+
+	progressMeter.Start(fmt.Sprintf("Finding maxima for root %s: %%d", root.Name()))
+	for {
+		obj, ok, err := objIter.Next()
+		if err != nil {
+			return RootMaximum{}, err
+		}
+		if !ok {
+			break
+		}
+		progressMeter.Inc()
+		switch obj.ObjectType {
+		case "blob":
+			if rm.MaxBlob == nil || obj.ObjectSize > rm.MaxBlobSize {
+				oid := obj.OID
+				rm.MaxBlob = &oid
+				rm.MaxBlobSize = obj.ObjectSize
+			}
+		case "commit":
+			recordCheckout(obj.OID)
+		case "tree":
+			if obj.OID == root.OID() {
+				recordCheckout(obj.OID)
+			}
+		}
+	}
+	progressMeter.Done()
+
+	if err := <-errChan; err != nil {
+		return RootMaximum{}, err
+	}
+
+	return rm, nil
+}
+
+// RootMaximaTableString returns a table showing the largest blob and
+// the biggest checkout reachable from each explicit root, or the
+// empty string if they weren't looked for.
+func (s *HistorySize) RootMaximaTableString() string {
+	if len(s.RootMaxima) == 0 {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprint(buf, "\nMaxima for each root:\n\n")
+	fmt.Fprintln(buf, "| Root             | Largest blob | Checkout files | Checkout size | Blob")
+	fmt.Fprintln(buf, "| ---------------- | ------------ | -------------- | ------------- | ----")
+	for _, rm := range s.RootMaxima {
+		blob := ""
+		if rm.MaxBlob != nil {
+			blob = rm.MaxBlob.String()
+		}
+		fileCount, fileUnit := counts.Metric.Format(rm.MaxCheckoutBlobCount, "")
+		fmt.Fprintf(
+			buf, "| %-16s |    %s |    %5s %-3s   |     %s | %s\n",
+			rm.Root,
+			formatSharedBytes(counts.Count64(rm.MaxBlobSize)),
+			fileCount, fileUnit,
+			formatSharedBytes(rm.MaxCheckoutBlobSize),
+			blob,
+		)
+	}
+	return buf.String()
+}
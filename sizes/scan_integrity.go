@@ -0,0 +1,112 @@
+package sizes
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// maxUnreadObjects is the maximum number of unread objects of each
+// type that are listed in a `ScanIntegrity`.
+const maxUnreadObjects = 10
+
+// ScanIntegrity records the discrepancies between the trees, commits,
+// and tags that were enumerated in the first pass of a scan and the
+// objects that could actually be read in the second pass (e.g.,
+// because objects were pruned in the meantime, or because a
+// checkpoint or object list refers to objects that the repository
+// doesn't have). An object that can't be read is treated as if it
+// were empty, so the sizes that depend on it are underestimates.
+type ScanIntegrity struct {
+	Trees   ScanIntegrityCounts `json:"trees"`
+	Commits ScanIntegrityCounts `json:"commits"`
+	Tags    ScanIntegrityCounts `json:"tags"`
+}
+
+// ScanIntegrityCounts compares the number of objects of one type that
+// were expected with the number that were read.
+type ScanIntegrityCounts struct {
+	Expected counts.Count32 `json:"expected"`
+	Read     counts.Count32 `json:"read"`
+
+	// Unread lists the first few objects that couldn't be read.
+	Unread []UnreadObject `json:"unread,omitempty"`
+}
+
+// UnreadObject is an object that was enumerated but couldn't be read.
+type UnreadObject struct {
+	OID git.OID `json:"oid"`
+
+	// Found is the type of the object that was found instead, or
+	// "missing" if it doesn't exist at all.
+	Found git.ObjectType `json:"found"`
+}
+
+func (c *ScanIntegrityCounts) recordRead() {
+	c.Expected.Increment(1)
+	c.Read.Increment(1)
+}
+
+func (c *ScanIntegrityCounts) recordUnread(oid git.OID, found git.ObjectType) {
+	c.Expected.Increment(1)
+	if len(c.Unread) < maxUnreadObjects {
+		c.Unread = append(c.Unread, UnreadObject{OID: oid, Found: found})
+	}
+}
+
+// ok returns true iff all of the objects that were expected were
+// read.
+func (si *ScanIntegrity) ok() bool {
+	return si.Trees.Read == si.Trees.Expected &&
+		si.Commits.Read == si.Commits.Expected &&
+		si.Tags.Read == si.Tags.Expected
+}
+
+// ScanIntegrityString describes the objects that were enumerated but
+// couldn't be read, or returns the empty string if there were none.
+func (s *HistorySize) ScanIntegrityString() string {
+	si := s.ScanIntegrity
+	if si == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nScan integrity:\n\n")
+	fmt.Fprintln(buf, "| Type    | Expected  | Read      | Unread")
+	fmt.Fprintln(buf, "| ------- | --------- | --------- | ---------")
+	row := func(name string, c ScanIntegrityCounts) {
+		fmt.Fprintf(
+			buf, "| %-7s | %9d | %9d | %9d\n",
+			name, c.Expected, c.Read, c.Expected-c.Read,
+		)
+	}
+	row("Trees", si.Trees)
+	row("Commits", si.Commits)
+	row("Tags", si.Tags)
+
+	fmt.Fprintf(buf, "\nObjects that couldn't be read:\n\n")
+	list := func(expected string, c ScanIntegrityCounts) {
+		for _, u := range c.Unread {
+			if u.Found == "missing" {
+				fmt.Fprintf(buf, "    %s %s is missing\n", expected, u.OID)
+			} else {
+				fmt.Fprintf(buf, "    %s %s is a %s\n", expected, u.OID, u.Found)
+			}
+		}
+		if n := int(c.Expected - c.Read); n > len(c.Unread) {
+			fmt.Fprintf(buf, "    ...and %d more %ss\n", n-len(c.Unread), expected)
+		}
+	}
+	list("tree", si.Trees)
+	list("commit", si.Commits)
+	list("tag", si.Tags)
+
+	fmt.Fprintf(
+		buf,
+		"\nThe unread objects were treated as empty, so the sizes above may be\n"+
+			"underestimates. Run 'git fsck' to check the repository.\n",
+	)
+	return buf.String()
+}
@@ -0,0 +1,121 @@
+package sizes
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/github/git-sizer/git"
+)
+
+// maxTableScopeRoots is the maximum number of roots that are listed
+// in the "Scan scope" section of the table output. (The JSON output
+// lists all of them.)
+const maxTableScopeRoots = 10
+
+// ScopeRoot describes one of the roots from which the scan started.
+type ScopeRoot struct {
+	// Name is the refname of the root, or the name that the user
+	// specified on the command line for an explicit root.
+	Name string `json:"name"`
+
+	// OID is the object that the root resolved to.
+	OID git.OID `json:"oid"`
+
+	// Explicit is true iff the root was specified on the command
+	// line rather than selected among the references.
+	Explicit bool `json:"explicit,omitempty"`
+}
+
+// recordScanScope records the roots that were walked in `s.ScanScope`.
+func (s *HistorySize) recordScanScope(roots []Root) {
+	s.ScanScope = []ScopeRoot{}
+	for _, root := range roots {
+		if !root.Walk() {
+			continue
+		}
+		_, isRef := root.(ReferenceRoot)
+		s.ScanScope = append(s.ScanScope, ScopeRoot{
+			Name:     s.anonymizer.Refname(root.Name()),
+			OID:      root.OID(),
+			Explicit: !isRef,
+		})
+	}
+}
+
+// recordHead records what `HEAD` referred to in `s.Head`, if it was
+// requested as a root (see `ScanOptions.Head`).
+func (s *HistorySize) recordHead(head *git.Head) {
+	if head == nil {
+		return
+	}
+	h := *head
+	if !h.Detached() {
+		h.Target = s.anonymizer.Refname(h.Target)
+	}
+	s.Head = &h
+}
+
+// emptyString returns a note explaining that the repository is
+// empty, or the empty string if it isn't.
+func (s *HistorySize) emptyString() string {
+	if !s.EmptyRepository {
+		return ""
+	}
+	return "\nThe repository is empty (it has no references, and HEAD is unborn),\n" +
+		"so there was nothing to measure and all of the statistics are zero.\n"
+}
+
+// SetShowScope chooses whether `TableString()` includes the "Scan
+// scope" section. (The JSON output always includes the scope.) It is
+// included anyway if only the objects introduced since a certain time
+// were counted.
+func (s *HistorySize) SetShowScope(show bool) {
+	s.showScope = show
+}
+
+// scopeString returns the "Scan scope" section of the table output,
+// or the empty string if the scope wasn't recorded or isn't to be
+// shown.
+func (s *HistorySize) scopeString() string {
+	if s.ScanScope == nil || (!s.showScope && s.ObjectsSince == nil) {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nScan scope:\n\n")
+	for i, root := range s.ScanScope {
+		if i == maxTableScopeRoots {
+			fmt.Fprintf(buf, "     ... and %d more\n", len(s.ScanScope)-i)
+			break
+		}
+		name := root.Name
+		if root.Explicit {
+			name += " (explicit)"
+		}
+		fmt.Fprintf(buf, "     %s  %s\n", root.OID, name)
+	}
+	if len(s.ScanScope) == 0 {
+		fmt.Fprintf(buf, "     (nothing was scanned)\n")
+	}
+	if h := s.Head; h != nil {
+		switch {
+		case h.Detached():
+			fmt.Fprintf(buf, "\nHEAD is detached at %s.\n", h.OID)
+		case h.Unborn():
+			fmt.Fprintf(
+				buf, "\nHEAD points at %s, which doesn't exist yet, so it wasn't scanned.\n",
+				h.Target,
+			)
+		default:
+			fmt.Fprintf(buf, "\nHEAD points at %s (%s).\n", h.Target, h.OID)
+		}
+	}
+	if s.ObjectsSince != nil {
+		fmt.Fprintf(
+			buf, "\nOnly objects introduced by commits since %s are counted.\n",
+			s.ObjectsSince.Format(time.RFC3339),
+		)
+	}
+	return buf.String()
+}
@@ -0,0 +1,107 @@
+package sizes
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+)
+
+// ReportSections lists the names of the sections of the main table
+// that can be selected by `SectionStatSet()`, in the order that they
+// appear in the output.
+var ReportSections = []string{
+	"overall",
+	"reference-tips",
+	"biggest-objects",
+	"history-structure",
+	"biggest-checkouts",
+}
+
+// sectionTitles maps the name of each of the `ReportSections` to its
+// title in the table output.
+var sectionTitles = map[string]string{
+	"overall":           "Overall repository size",
+	"reference-tips":    "Reference tips",
+	"biggest-objects":   "Biggest objects",
+	"history-structure": "History structure",
+	"biggest-checkouts": "Biggest checkouts",
+}
+
+// ParseSections parses a comma-separated list of section names (see
+// `ReportSections`).
+func ParseSections(s string) ([]string, error) {
+	var sections []string
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if _, ok := sectionTitles[name]; !ok {
+			return nil, fmt.Errorf(
+				"unknown section '%s' (known sections: %s)",
+				name, strings.Join(ReportSections, ", "),
+			)
+		}
+		sections = append(sections, name)
+	}
+	return sections, nil
+}
+
+// SectionStatSet returns the set of statistics that are reported in
+// the named `sections` (see `ReportSections`), including the
+// statistics about each of `refGroups`. Passing the result to
+// `ScanOptions.Stats` makes the scan collect only the data needed for
+// those sections.
+func SectionStatSet(sections []string, refGroups []RefGroup) (StatSet, error) {
+	// Build the table for a `HistorySize` that has an entry for
+	// every refgroup, so that their statistics are included:
+	var s HistorySize
+	s.ReferenceGroups = make(map[RefGroupSymbol]*counts.Count32)
+	s.ReferenceGroupTips = make(map[RefGroupSymbol]*RefGroupTipSize)
+	for _, rg := range refGroups {
+		s.ReferenceGroups[rg.Symbol] = new(counts.Count32)
+		s.ReferenceGroupTips[rg.Symbol] = &RefGroupTipSize{}
+	}
+	top, ok := s.contents(refGroups).(*section)
+	if !ok {
+		panic("the table contents aren't a section")
+	}
+
+	ss := make(StatSet)
+	for _, name := range sections {
+		title, ok := sectionTitles[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown section '%s'", name)
+		}
+		for _, c := range top.contents {
+			if sec, ok := c.(*section); ok && sec.name == title {
+				items := make(map[string]*item)
+				sec.CollectItems(items)
+				for symbol := range items {
+					ss[symbol] = true
+				}
+			}
+		}
+	}
+	return ss, nil
+}
+
+// Intersect returns the set of statistics that are in both `ss` and
+// `other`.
+func (ss StatSet) Intersect(other StatSet) StatSet {
+	switch {
+	case ss == nil && other == nil:
+		return nil
+	case ss == nil:
+		ss, other = other, ss
+	}
+
+	result := make(StatSet)
+	for symbol := range ss {
+		if other.Contains(symbol) {
+			result[symbol] = true
+		}
+	}
+	return result
+}
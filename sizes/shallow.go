@@ -0,0 +1,95 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// ShallowBoundary describes where the history of a shallow clone is
+// cut off. The statistics of a shallow clone only cover the history
+// up to the boundary.
+type ShallowBoundary struct {
+	// Commits are the commits whose parents are missing from the
+	// clone.
+	Commits []git.OID `json:"commits"`
+
+	// Remote is the repository in which the history beyond the
+	// boundary was counted, or the empty string if it wasn't
+	// counted. BeyondCommitCount is the number of commits beyond the
+	// boundary, and BeyondObjectCount is the number of objects
+	// (including those commits) that are reachable only from beyond
+	// the boundary.
+	Remote            string          `json:"remote,omitempty"`
+	BeyondCommitCount *counts.Count64 `json:"beyond_commit_count,omitempty"`
+	BeyondObjectCount *counts.Count64 `json:"beyond_object_count,omitempty"`
+}
+
+// recordShallowBoundary records the shallow boundary, consisting of
+// `commits`, in `s.ShallowBoundary`, unless `commits` is empty (i.e.,
+// the repository is a full clone). If `remote` is
+// non-nil, the history beyond the boundary is counted in it. If that
+// fails (e.g., because `remote` doesn't have the boundary commits),
+// it is left uncounted.
+func (s *HistorySize) recordShallowBoundary(
+	ctx context.Context, commits []git.OID, remote *git.Repository,
+) error {
+	if len(commits) == 0 {
+		return nil
+	}
+
+	s.ShallowBoundary = &ShallowBoundary{Commits: commits}
+	if remote == nil {
+		return nil
+	}
+
+	commitCount, objectCount, err := remote.CountBeyond(ctx, commits)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return nil
+	}
+	s.ShallowBoundary.Remote = s.anonymizer.Path(remote.GitDir())
+	s.ShallowBoundary.BeyondCommitCount = &commitCount
+	s.ShallowBoundary.BeyondObjectCount = &objectCount
+	return nil
+}
+
+// shallowString returns a section of the table output describing
+// the shallow boundary, or the empty string if the repository isn't
+// a shallow clone.
+func (s *HistorySize) shallowString() string {
+	b := s.ShallowBoundary
+	if b == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(
+		buf, "\nThis is a shallow clone, so the statistics above only cover part of the history.\n"+
+			"The history is cut off at the following commits:\n\n",
+	)
+	for i, oid := range b.Commits {
+		if i == maxTableScopeRoots {
+			fmt.Fprintf(buf, "     ... and %d more\n", len(b.Commits)-i)
+			break
+		}
+		fmt.Fprintf(buf, "     %s\n", oid)
+	}
+	fmt.Fprintln(buf)
+	if b.BeyondCommitCount == nil {
+		fmt.Fprintf(buf, "The size of the rest of the history is unknown.\n")
+	} else {
+		commitValue, commitUnit := counts.Metric.Format(*b.BeyondCommitCount, "")
+		objectValue, objectUnit := counts.Metric.Format(*b.BeyondObjectCount, "")
+		fmt.Fprintf(
+			buf, "Beyond them, %s has %s%s more commits and %s%s more objects.\n",
+			b.Remote, commitValue, commitUnit, objectValue, objectUnit,
+		)
+	}
+	return buf.String()
+}
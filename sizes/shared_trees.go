@@ -0,0 +1,372 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// sharedTreeMinSize is the smallest expanded blob size of a tree that
+// is considered for `HistorySize.SharedTrees`. Smaller trees (and
+// therefore all of their subtrees) are not even read.
+const sharedTreeMinSize = 1 << 20
+
+// sharedTreePathLimit is the maximum number of paths that are listed
+// for each shared tree.
+const sharedTreePathLimit = 10
+
+// SharedTree describes a heavy tree that appears at several paths in
+// the checkouts of the walked references (and other roots), either
+// under different top-level directories or in different refgroups.
+// Such a tree is typically a copy of a vendored library, which could
+// be consolidated into a single directory.
+type SharedTree struct {
+	OID               git.OID        `json:"oid"`
+	ExpandedBlobCount counts.Count32 `json:"expanded_blob_count"`
+	ExpandedBlobSize  counts.Count64 `json:"expanded_blob_size"`
+
+	// PathCount is the number of distinct paths at which the tree
+	// appears. Paths lists (at most `sharedTreePathLimit` of) them,
+	// each preceded by the name of a root whose checkout has the tree
+	// at that path, like "refs/heads/main:vendor/lib".
+	PathCount int      `json:"path_count"`
+	Paths     []string `json:"paths"`
+
+	// TopLevelDirs are the top-level directories under which the
+	// tree appears.
+	TopLevelDirs []string `json:"top_level_dirs"`
+
+	// RefGroups are the refgroups (other than the top-level group) of
+	// the references in whose checkouts the tree appears.
+	RefGroups []RefGroupSymbol `json:"ref_groups,omitempty"`
+}
+
+// sharedTreeInfo collects the contexts in which a heavy tree appears
+// while `findSharedTrees()` walks the checkouts.
+type sharedTreeInfo struct {
+	// paths maps each path at which the tree appears to the name of
+	// the first root in whose checkout it was found there.
+	paths map[string]string
+
+	// parents holds the distinct trees that contain this one.
+	parents map[git.OID]struct{}
+
+	topLevelDirs map[string]struct{}
+	refGroups    map[RefGroupSymbol]struct{}
+}
+
+// shared returns true iff the tree appears at several paths, under
+// different top-level directories or in different refgroups.
+func (info *sharedTreeInfo) shared() bool {
+	if len(info.paths) < 2 {
+		return false
+	}
+	if len(info.topLevelDirs) >= 2 {
+		return true
+	}
+	groups := 0
+	for group := range info.refGroups {
+		if group != "" {
+			groups++
+		}
+	}
+	return groups >= 2
+}
+
+// sharedTreeVisit is a tree at a particular path in a checkout.
+type sharedTreeVisit struct {
+	oid  git.OID
+	path string
+}
+
+// sharedTreeTask is a tree that `findSharedTrees()` still has to
+// expand, along with the name of the root whose checkout it is in and
+// the refgroups that are new to it at this path.
+type sharedTreeTask struct {
+	sharedTreeVisit
+	root   string
+	groups []RefGroupSymbol
+}
+
+// findSharedTrees walks the checkouts of the walked roots, descending
+// only into trees whose expanded blob size is at least
+// `sharedTreeMinSize`, and stores the (at most) `limit` heaviest
+// shared trees in `s.SharedTrees`. A shared tree is only listed if at
+// least one of its parents isn't shared, too; otherwise, all of its
+// copies are part of copies of a bigger tree, which is listed
+// instead.
+func (s *HistorySize) findSharedTrees(
+	ctx context.Context, repo *git.Repository, g *Graph, roots []Root, limit int,
+	progressMeter meter.Progress,
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	heavy := func(oid git.OID) (TreeSize, bool) {
+		g.treeLock.Lock()
+		defer g.treeLock.Unlock()
+		size, ok := g.treeSizes[oid]
+		return size, ok && size.ExpandedBlobSize >= sharedTreeMinSize
+	}
+
+	objIter, err := repo.NewBatchObjectIter(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The heavy subtrees of each tree that has been read:
+	subtrees := make(map[git.OID][]git.TreeEntry)
+	readSubtrees := func(oid git.OID) ([]git.TreeEntry, error) {
+		if entries, ok := subtrees[oid]; ok {
+			return entries, nil
+		}
+
+		if err := objIter.RequestObject(oid); err != nil {
+			return nil, err
+		}
+		obj, ok, err := objIter.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("tree %s could not be read", oid)
+		}
+		if obj.ObjectType != "tree" {
+			return nil, fmt.Errorf("expected tree; read %#v", obj.ObjectType)
+		}
+		progressMeter.Inc()
+
+		tree, err := git.ParseTree(obj.OID, obj.Data)
+		if err != nil {
+			return nil, err
+		}
+		var entries []git.TreeEntry
+		iter := tree.Iter()
+		for {
+			entry, ok, err := iter.NextEntry()
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				break
+			}
+			if entry.Filemode&0o170000 != 0o40000 {
+				continue
+			}
+			if _, ok := heavy(entry.OID); ok {
+				entries = append(entries, entry)
+			}
+		}
+		subtrees[oid] = entries
+		return entries, nil
+	}
+
+	infos := make(map[git.OID]*sharedTreeInfo)
+
+	// The refgroups for which each tree has already been expanded at
+	// each path:
+	visited := make(map[sharedTreeVisit]map[RefGroupSymbol]struct{})
+
+	progressMeter.Start("Finding shared trees: %d")
+	for _, root := range roots {
+		if !root.Walk() {
+			continue
+		}
+		oid := g.checkoutTree(root.OID())
+		if _, ok := heavy(oid); !ok {
+			continue
+		}
+		var groups []RefGroupSymbol
+		if refRoot, ok := root.(ReferenceRoot); ok {
+			groups = refRoot.Groups()
+		}
+
+		stack := []sharedTreeTask{{
+			sharedTreeVisit: sharedTreeVisit{oid: oid},
+			root:            root.Name(),
+			groups:          groups,
+		}}
+		for len(stack) > 0 {
+			task := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			// Only expand the tree if it hasn't been expanded at
+			// this path yet, or if some of the refgroups are new:
+			seen, ok := visited[task.sharedTreeVisit]
+			if !ok {
+				seen = make(map[RefGroupSymbol]struct{})
+				visited[task.sharedTreeVisit] = seen
+			}
+			var fresh []RefGroupSymbol
+			for _, group := range task.groups {
+				if _, ok := seen[group]; !ok {
+					seen[group] = struct{}{}
+					fresh = append(fresh, group)
+				}
+			}
+			if ok && len(fresh) == 0 {
+				continue
+			}
+
+			entries, err := readSubtrees(task.oid)
+			if err != nil {
+				objIter.Close()
+				return err
+			}
+			for _, entry := range entries {
+				path := entry.Name
+				if task.path != "" {
+					path = task.path + "/" + entry.Name
+				}
+
+				info, ok := infos[entry.OID]
+				if !ok {
+					info = &sharedTreeInfo{
+						paths:        make(map[string]string),
+						parents:      make(map[git.OID]struct{}),
+						topLevelDirs: make(map[string]struct{}),
+						refGroups:    make(map[RefGroupSymbol]struct{}),
+					}
+					infos[entry.OID] = info
+				}
+				if _, ok := info.paths[path]; !ok {
+					info.paths[path] = task.root
+				}
+				info.parents[task.oid] = struct{}{}
+				if i := strings.IndexByte(path, '/'); i >= 0 {
+					info.topLevelDirs[path[:i]] = struct{}{}
+				} else {
+					info.topLevelDirs[path] = struct{}{}
+				}
+				for _, group := range fresh {
+					info.refGroups[group] = struct{}{}
+				}
+
+				stack = append(stack, sharedTreeTask{
+					sharedTreeVisit: sharedTreeVisit{oid: entry.OID, path: path},
+					root:            task.root,
+					groups:          fresh,
+				})
+			}
+		}
+	}
+	progressMeter.Done()
+
+	objIter.Close()
+	for {
+		_, ok, err := objIter.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+	}
+
+	var shared []SharedTree
+	for oid, info := range infos {
+		if !info.shared() {
+			continue
+		}
+		outermost := false
+		for parent := range info.parents {
+			if parentInfo, ok := infos[parent]; !ok || !parentInfo.shared() {
+				outermost = true
+				break
+			}
+		}
+		if !outermost {
+			continue
+		}
+
+		size, _ := heavy(oid)
+		shared = append(shared, s.newSharedTree(oid, size, info))
+	}
+
+	sort.Slice(shared, func(i, j int) bool {
+		if shared[i].ExpandedBlobSize != shared[j].ExpandedBlobSize {
+			return shared[i].ExpandedBlobSize > shared[j].ExpandedBlobSize
+		}
+		return shared[i].OID.String() < shared[j].OID.String()
+	})
+	if len(shared) > limit {
+		shared = shared[:limit]
+	}
+	if shared == nil {
+		shared = []SharedTree{}
+	}
+	s.SharedTrees = shared
+	return nil
+}
+
+// newSharedTree returns the `SharedTree` for the tree `oid`, whose
+// size is `size`, from the contexts in `info`.
+func (s *HistorySize) newSharedTree(oid git.OID, size TreeSize, info *sharedTreeInfo) SharedTree {
+	st := SharedTree{
+		OID:               oid,
+		ExpandedBlobCount: size.ExpandedBlobCount,
+		ExpandedBlobSize:  size.ExpandedBlobSize,
+		PathCount:         len(info.paths),
+	}
+
+	paths := make([]string, 0, len(info.paths))
+	for path := range info.paths {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	if len(paths) > sharedTreePathLimit {
+		paths = paths[:sharedTreePathLimit]
+	}
+	for _, path := range paths {
+		st.Paths = append(
+			st.Paths,
+			s.anonymizer.Refname(info.paths[path])+":"+s.anonymizer.Path(path),
+		)
+	}
+
+	for dir := range info.topLevelDirs {
+		st.TopLevelDirs = append(st.TopLevelDirs, s.anonymizer.Path(dir))
+	}
+	sort.Strings(st.TopLevelDirs)
+
+	for group := range info.refGroups {
+		if group != "" {
+			st.RefGroups = append(st.RefGroups, group)
+		}
+	}
+	sort.Slice(st.RefGroups, func(i, j int) bool { return st.RefGroups[i] < st.RefGroups[j] })
+
+	return st
+}
+
+// SharedTreesTableString returns a table listing the shared trees, or
+// the empty string if they weren't looked for.
+func (s *HistorySize) SharedTreesTableString() string {
+	if s.SharedTrees == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nTrees shared across top-level directories or refgroups:\n\n")
+	if len(s.SharedTrees) == 0 {
+		fmt.Fprintln(buf, "No heavy trees are shared.")
+		return buf.String()
+	}
+	fmt.Fprintln(buf, "| Tree         | Blobs   | Blob size | Paths | Example path")
+	fmt.Fprintln(buf, "| ------------ | ------- | --------- | ----- | ------------")
+	for _, st := range s.SharedTrees {
+		blobCount, blobUnit := counts.Metric.Format(st.ExpandedBlobCount, "")
+		fmt.Fprintf(
+			buf, "| %-12s | %5s %-1s | %s | %5d | %s\n",
+			st.OID.String()[:12], blobCount, blobUnit,
+			formatSharedBytes(st.ExpandedBlobSize), st.PathCount, st.Paths[0],
+		)
+	}
+	return buf.String()
+}
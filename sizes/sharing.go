@@ -0,0 +1,199 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// sharingSampleRate is the reciprocal of the fraction of objects
+// that are sampled when estimating how many bytes refgroups share.
+// Whether an object is sampled depends only on its OID, so the same
+// objects are sampled for every refgroup, and (because OIDs are
+// hashes) the sample is effectively random. See `isSharingSample()`.
+const sharingSampleRate = 64
+
+// RefGroupSharing holds an estimate of the sizes of the unique
+// objects reachable from the references in two refgroups. The
+// estimates are extrapolated from a sample of the objects, so they
+// are only approximate, and are always multiples of the sample rate.
+type RefGroupSharing struct {
+	A RefGroupSymbol `json:"a"`
+	B RefGroupSymbol `json:"b"`
+
+	// The total size of the objects reachable from both groups.
+	SharedBytes counts.Count64 `json:"shared_bytes"`
+
+	// The total size of the objects reachable only from A.
+	OnlyABytes counts.Count64 `json:"only_a_bytes"`
+
+	// The total size of the objects reachable only from B.
+	OnlyBBytes counts.Count64 `json:"only_b_bytes"`
+}
+
+// isSharingSample returns true iff `oid` is in the sample used to
+// estimate the sharing between refgroups.
+func isSharingSample(oid git.OID) bool {
+	// Sample the OIDs whose first byte is less than 4:
+	hex := oid.String()
+	return hex[0] == '0' && hex[1] < '4'
+}
+
+// computeRefGroupSharing estimates the pairwise sharing of objects
+// among the (at most) `k` refgroups that have the most walked
+// references, and stores the result in `s.RefGroupSharing`.
+func (s *HistorySize) computeRefGroupSharing(
+	ctx context.Context, repo *git.Repository, roots []Root, k int,
+	progressMeter meter.Progress,
+) error {
+	groupRoots := make(map[RefGroupSymbol][]git.OID)
+	for _, root := range roots {
+		refRoot, ok := root.(ReferenceRoot)
+		if !ok || !root.Walk() {
+			continue
+		}
+		for _, group := range refRoot.Groups() {
+			if group == "" {
+				// Skip the top-level group, which contains
+				// everything.
+				continue
+			}
+			groupRoots[group] = append(groupRoots[group], root.OID())
+		}
+	}
+
+	groups := make([]RefGroupSymbol, 0, len(groupRoots))
+	for group := range groupRoots {
+		groups = append(groups, group)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		ni, nj := len(groupRoots[groups[i]]), len(groupRoots[groups[j]])
+		if ni != nj {
+			return ni > nj
+		}
+		return groups[i] < groups[j]
+	})
+	if len(groups) > k {
+		groups = groups[:k]
+	}
+
+	samples := make([]map[git.OID]uint64, len(groups))
+	for i, group := range groups {
+		sample, err := sampleObjects(ctx, repo, group, groupRoots[group], progressMeter)
+		if err != nil {
+			return err
+		}
+		samples[i] = sample
+	}
+
+	s.RefGroupSharing = []RefGroupSharing{}
+	for i := range groups {
+		for j := i + 1; j < len(groups); j++ {
+			var shared, onlyA, onlyB uint64
+			for oid, size := range samples[i] {
+				if _, ok := samples[j][oid]; ok {
+					shared += size
+				} else {
+					onlyA += size
+				}
+			}
+			for oid, size := range samples[j] {
+				if _, ok := samples[i][oid]; !ok {
+					onlyB += size
+				}
+			}
+			s.RefGroupSharing = append(s.RefGroupSharing, RefGroupSharing{
+				A:           groups[i],
+				B:           groups[j],
+				SharedBytes: counts.NewCount64(shared * sharingSampleRate),
+				OnlyABytes:  counts.NewCount64(onlyA * sharingSampleRate),
+				OnlyBBytes:  counts.NewCount64(onlyB * sharingSampleRate),
+			})
+		}
+	}
+
+	return nil
+}
+
+// sampleObjects returns the sizes of the sampled objects that are
+// reachable from `oids`.
+func sampleObjects(
+	ctx context.Context, repo *git.Repository, group RefGroupSymbol, oids []git.OID,
+	progressMeter meter.Progress,
+) (map[git.OID]uint64, error) {
+	objIter, err := repo.NewObjectIter(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		defer objIter.Close()
+
+		errChan <- func() error {
+			for _, oid := range oids {
+				if err := objIter.AddRoot(oid); err != nil {
+					return err
+				}
+			}
+			return nil
+		}()
+	}()
+
+	sample := make(map[git.OID]uint64)
+
+	progressMeter.Start(fmt.Sprintf("Sampling objects in refgroup %s: %%d", group))
+	for {
+		obj, ok, err := objIter.Next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		progressMeter.Inc()
+		if isSharingSample(obj.OID) {
+			sample[obj.OID] = uint64(obj.ObjectSize)
+		}
+	}
+	progressMeter.Done()
+
+	if err := <-errChan; err != nil {
+		return nil, err
+	}
+
+	return sample, nil
+}
+
+// SharingTableString returns a table showing the estimated sharing
+// between refgroups, or the empty string if it wasn't computed.
+func (s *HistorySize) SharingTableString() string {
+	if len(s.RefGroupSharing) == 0 {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nEstimated sharing of unique object bytes between refgroups:\n\n")
+	fmt.Fprintln(buf, "| Refgroup A       | Refgroup B       | Shared    | Only in A | Only in B |")
+	fmt.Fprintln(buf, "| ---------------- | ---------------- | --------- | --------- | --------- |")
+	for _, sharing := range s.RefGroupSharing {
+		fmt.Fprintf(
+			buf, "| %-16s | %-16s | %s | %s | %s |\n",
+			sharing.A, sharing.B,
+			formatSharedBytes(sharing.SharedBytes),
+			formatSharedBytes(sharing.OnlyABytes),
+			formatSharedBytes(sharing.OnlyBBytes),
+		)
+	}
+	return buf.String()
+}
+
+func formatSharedBytes(n counts.Count64) string {
+	valueString, unitString := counts.Binary.Format(n, "B")
+	return fmt.Sprintf("%5s %-3s", valueString, unitString)
+}
@@ -0,0 +1,158 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// maxTableBudgetPaths is the most paths that are listed in the table
+// output of the size budget report. The JSON output lists them all.
+const maxTableBudgetPaths = 50
+
+// PathSize is the number and total size of the unique blobs that
+// were found at one path.
+type PathSize struct {
+	Path      string         `json:"path"`
+	BlobCount counts.Count32 `json:"blob_count"`
+	BlobSize  counts.Count64 `json:"blob_size"`
+}
+
+// SizeBudget lists the smallest set of paths whose unique blobs
+// account for `Percent` percent of the total size of the unique
+// blobs. Each blob is attributed to only one path (the first one
+// at which `git rev-list --objects` encounters it), so the sizes of
+// the paths add up to the total.
+type SizeBudget struct {
+	Percent int `json:"percent"`
+
+	// TotalBlobSize is the total size of the unique blobs, and
+	// PathCount is the number of distinct paths that they were
+	// attributed to.
+	TotalBlobSize counts.Count64 `json:"total_blob_size"`
+	PathCount     int            `json:"path_count"`
+
+	// Paths are the paths in the set, biggest first, and BlobSize is
+	// their total size.
+	Paths    []PathSize     `json:"paths"`
+	BlobSize counts.Count64 `json:"blob_size"`
+}
+
+// computeSizeBudget attributes the unique blobs that are reachable
+// from the walked `roots` to paths, and stores the smallest set of
+// paths that account for `percent` percent of their total size in
+// `s.SizeBudget`. The blobs' sizes are looked up in `g`.
+func (s *HistorySize) computeSizeBudget(
+	ctx context.Context, repo *git.Repository, g *Graph, roots []Root, percent int,
+	progressMeter meter.Progress,
+) error {
+	var tips []git.OID
+	for _, root := range roots {
+		if root.Walk() {
+			tips = append(tips, root.OID())
+		}
+	}
+
+	var total counts.Count64
+	paths := make(map[string]*PathSize)
+	progressMeter.Start("Attributing blobs to paths: %d")
+	err := repo.ObjectPaths(ctx, tips, func(oid git.OID, path string) {
+		progressMeter.Inc()
+		size, ok := g.blobSizes[oid]
+		if !ok || !g.countsTowardTotals(oid) {
+			// Not a blob, or not counted.
+			return
+		}
+		total.Increment(counts.Count64(size.Size))
+		ps, ok := paths[path]
+		if !ok {
+			ps = &PathSize{Path: path}
+			paths[path] = ps
+		}
+		ps.BlobCount.Increment(1)
+		ps.BlobSize.Increment(counts.Count64(size.Size))
+	})
+	progressMeter.Done()
+	if err != nil {
+		return err
+	}
+
+	all := make([]*PathSize, 0, len(paths))
+	for _, ps := range paths {
+		all = append(all, ps)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].BlobSize != all[j].BlobSize {
+			return all[i].BlobSize > all[j].BlobSize
+		}
+		return all[i].Path < all[j].Path
+	})
+
+	budget := SizeBudget{
+		Percent:       percent,
+		TotalBlobSize: total,
+		PathCount:     len(paths),
+		Paths:         []PathSize{},
+	}
+	target := float64(total) * float64(percent) / 100
+	for _, ps := range all {
+		if float64(budget.BlobSize) >= target {
+			break
+		}
+		p := *ps
+		p.Path = s.anonymizer.Path(p.Path)
+		budget.Paths = append(budget.Paths, p)
+		budget.BlobSize.Increment(p.BlobSize)
+	}
+
+	s.SizeBudget = &budget
+	return nil
+}
+
+// SizeBudgetTableString returns a table listing the paths that
+// account for the requested share of the unique blob size, or the
+// empty string if they weren't requested.
+func (s *HistorySize) SizeBudgetTableString() string {
+	b := s.SizeBudget
+	if b == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(
+		buf, "\n%d of %d paths account for %s of the %s of unique blobs (at least %d%%):\n\n",
+		len(b.Paths), b.PathCount, formatBytes(b.BlobSize), formatBytes(b.TotalBlobSize),
+		b.Percent,
+	)
+	if len(b.Paths) == 0 {
+		return buf.String()
+	}
+	fmt.Fprintln(buf, "| Blobs     | Total size | Share  | Path")
+	fmt.Fprintln(buf, "| --------- | ---------- | ------ | ----")
+	var listed counts.Count64
+	for i, ps := range b.Paths {
+		if i == maxTableBudgetPaths {
+			fmt.Fprintf(
+				buf, "     ... and %d more paths, with %s\n",
+				len(b.Paths)-i, formatBytes(b.BlobSize-listed),
+			)
+			break
+		}
+		listed.Increment(ps.BlobSize)
+		path := ps.Path
+		if path == "" {
+			path = "(none)"
+		}
+		fmt.Fprintf(
+			buf, "| %9d |  %s | %5.1f%% | %s\n",
+			ps.BlobCount, formatSharedBytes(ps.BlobSize),
+			100*float64(ps.BlobSize)/float64(b.TotalBlobSize), path,
+		)
+	}
+	return buf.String()
+}
@@ -1,7 +1,15 @@
+// Package sizes scans the objects in a Git repository and computes
+// statistics about their sizes. It is the library behind the
+// `git-sizer` command, and doesn't depend on any command-line parsing
+// or terminal libraries; see "Using git-sizer as a library" in
+// README.md for the compatibility guarantees.
 package sizes
 
 import (
 	"fmt"
+	"math"
+	"strings"
+	"time"
 
 	"github.com/github/git-sizer/counts"
 	"github.com/github/git-sizer/git"
@@ -33,11 +41,63 @@ type TreeSize struct {
 	// The total size of all blobs, including duplicates.
 	ExpandedBlobSize counts.Count64 `json:"expanded_blob_size"`
 
+	// The total number of blobs that are marked executable,
+	// including duplicates.
+	ExpandedExecutableCount counts.Count32 `json:"expanded_executable_count"`
+
 	// The total number of symbolic links, including duplicates.
 	ExpandedLinkCount counts.Count32 `json:"expanded_link_count"`
 
 	// The total number of submodules referenced, including duplicates.
 	ExpandedSubmoduleCount counts.Count32 `json:"expanded_submodule_count"`
+
+	// The total length of the paths (relative to this tree) of all
+	// of the blobs, symlinks, and submodules, including duplicates.
+	// These are the paths that would be stored in the index.
+	ExpandedPathLength counts.Count64 `json:"expanded_path_length"`
+
+	// ExpansionLimited is true if the expansion of this tree, or of
+	// one of its descendants, was cut short because it exceeded the
+	// configured limit on expanded entries. In that case, the
+	// `Expanded*` counts are saturated.
+	ExpansionLimited bool `json:"expansion_limited,omitempty"`
+
+	// The number of entries directly in this tree.
+	entryCount counts.Count32
+
+	// An upper bound on the number of distinct objects in the
+	// checkout of this tree, including the tree itself. Entries that
+	// refer to the same subtree count it only once, so, unlike the
+	// `Expanded*` counts, this stays small for a git bomb.
+	uniqueEntryBound counts.Count64
+}
+
+// expandedEntryCount returns the total number of entries, including
+// duplicates, in the checkout of this tree.
+func (s *TreeSize) expandedEntryCount() uint64 {
+	return uint64(s.ExpandedTreeCount) + uint64(s.ExpandedBlobCount) +
+		uint64(s.ExpandedLinkCount) + uint64(s.ExpandedSubmoduleCount)
+}
+
+// expandedIndexEntryCount returns the number of entries, including
+// duplicates, that the index would hold for a checkout of this tree.
+func (s *TreeSize) expandedIndexEntryCount() uint64 {
+	return uint64(s.ExpandedBlobCount) + uint64(s.ExpandedLinkCount) +
+		uint64(s.ExpandedSubmoduleCount)
+}
+
+// limitExpansion marks `s` as having been cut short and saturates its
+// `Expanded*` counts, so that no more time is spent accumulating
+// them.
+func (s *TreeSize) limitExpansion() {
+	s.ExpansionLimited = true
+	s.ExpandedTreeCount = math.MaxUint32
+	s.ExpandedBlobCount = math.MaxUint32
+	s.ExpandedBlobSize = math.MaxUint64
+	s.ExpandedExecutableCount = math.MaxUint32
+	s.ExpandedLinkCount = math.MaxUint32
+	s.ExpandedSubmoduleCount = math.MaxUint32
+	s.ExpandedPathLength = math.MaxUint64
 }
 
 func (s *TreeSize) addDescendent(filename string, s2 TreeSize) {
@@ -49,39 +109,84 @@ func (s *TreeSize) addDescendent(filename string, s2 TreeSize) {
 	} else {
 		s.MaxPathLength.AdjustMaxIfNecessary(counts.NewCount32(uint64(len(filename))))
 	}
+	if s.ExpansionLimited {
+		return
+	}
+	if s2.ExpansionLimited {
+		s.limitExpansion()
+		return
+	}
 	s.ExpandedTreeCount.Increment(s2.ExpandedTreeCount)
 	s.ExpandedBlobCount.Increment(s2.ExpandedBlobCount)
 	s.ExpandedBlobSize.Increment(s2.ExpandedBlobSize)
+	s.ExpandedExecutableCount.Increment(s2.ExpandedExecutableCount)
 	s.ExpandedLinkCount.Increment(s2.ExpandedLinkCount)
 	s.ExpandedSubmoduleCount.Increment(s2.ExpandedSubmoduleCount)
+	// Each of the descendant's paths is prefixed with `filename/`:
+	s.ExpandedPathLength.Increment(s2.ExpandedPathLength)
+	s.ExpandedPathLength.Increment(
+		counts.NewCount64(s2.expandedIndexEntryCount() * uint64(len(filename)+1)),
+	)
 }
 
 // Record that the object has a blob of the specified `size` as a
-// direct descendant.
-func (s *TreeSize) addBlob(filename string, size BlobSize) {
+// direct descendant. `executable` tells whether the blob is marked
+// executable.
+func (s *TreeSize) addBlob(filename string, size BlobSize, executable bool) {
 	s.MaxPathDepth.AdjustMaxIfNecessary(1)
 	s.MaxPathLength.AdjustMaxIfNecessary(counts.NewCount32(uint64(len(filename))))
+	s.uniqueEntryBound.Increment(1)
+	if s.ExpansionLimited {
+		return
+	}
 	s.ExpandedBlobSize.Increment(counts.Count64(size.Size))
 	s.ExpandedBlobCount.Increment(1)
+	s.ExpandedPathLength.Increment(counts.Count64(len(filename)))
+	if executable {
+		s.ExpandedExecutableCount.Increment(1)
+	}
 }
 
 // Record that the object has a link as a direct descendant.
 func (s *TreeSize) addLink(filename string) {
 	s.MaxPathDepth.AdjustMaxIfNecessary(1)
 	s.MaxPathLength.AdjustMaxIfNecessary(counts.NewCount32(uint64(len(filename))))
+	s.uniqueEntryBound.Increment(1)
+	if s.ExpansionLimited {
+		return
+	}
 	s.ExpandedLinkCount.Increment(1)
+	s.ExpandedPathLength.Increment(counts.Count64(len(filename)))
 }
 
 // Record that the object has a submodule as a direct descendant.
 func (s *TreeSize) addSubmodule(filename string) {
 	s.MaxPathDepth.AdjustMaxIfNecessary(1)
 	s.MaxPathLength.AdjustMaxIfNecessary(counts.NewCount32(uint64(len(filename))))
+	s.uniqueEntryBound.Increment(1)
+	if s.ExpansionLimited {
+		return
+	}
 	s.ExpandedSubmoduleCount.Increment(1)
+	s.ExpandedPathLength.Increment(counts.Count64(len(filename)))
 }
 
 type CommitSize struct {
 	// The height of the ancestor graph, including this commit.
 	MaxAncestorDepth counts.Count32 `json:"max_ancestor_depth"`
+
+	// The index (in `Graph.historyComponents`) of a connected
+	// component of the history that this commit belongs to.
+	component int
+
+	// The OID of this commit's tree.
+	tree git.OID
+
+	// The parents of this commit that were registered (i.e., none for
+	// a commit at the boundary of a shallow clone). They are only
+	// kept if the merge bubbles are measured (see
+	// `Graph.mergeBubbles`).
+	parents []git.OID
 }
 
 func (s *CommitSize) addParent(s2 CommitSize) {
@@ -95,9 +200,130 @@ type TagSize struct {
 	// The number of tags that have to be traversed (including this
 	// one) to get to an object.
 	TagDepth counts.Count32
+
+	// The OID and type of the object that this tag refers to.
+	referent     git.OID
+	referentType git.ObjectType
+}
+
+// RefGroupTipSize holds statistics about the tips of the references
+// in a single reference group.
+type RefGroupTipSize struct {
+	// The length of the longest refname in the group, in bytes.
+	MaxRefnameLength counts.Count32 `json:"max_refname_length"`
+
+	// The reference with the longest refname.
+	MaxRefnameLengthRef *Path `json:"max_refname_length_ref,omitempty"`
+
+	// The largest number of slash-separated components in any
+	// refname in the group (e.g., 3 for "refs/heads/main").
+	MaxRefnameDepth counts.Count32 `json:"max_refname_depth"`
+
+	// The reference with the deepest refname.
+	MaxRefnameDepthRef *Path `json:"max_refname_depth_ref,omitempty"`
+
+	// The committer date of the oldest commit at the tip of a
+	// reference in the group.
+	OldestTipDate time.Time `json:"oldest_tip_date"`
+
+	// The reference whose tip is the oldest.
+	OldestTipRef *Path `json:"oldest_tip_ref,omitempty"`
+
+	// The committer date of the newest commit at the tip of a
+	// reference in the group.
+	NewestTipDate time.Time `json:"newest_tip_date"`
+
+	// The reference whose tip is the newest.
+	NewestTipRef *Path `json:"newest_tip_ref,omitempty"`
+
+	// The number of references whose tips are older than the
+	// configured stale reference age.
+	StaleRefCount counts.Count32 `json:"stale_ref_count"`
+
+	// The largest number of files in the checkout of the tip of any
+	// reference in the group.
+	MaxCheckoutBlobCount counts.Count32 `json:"max_checkout_blob_count"`
+
+	// The reference whose checkout has the most files.
+	MaxCheckoutBlobCountRef *Path `json:"max_checkout_blob_count_ref,omitempty"`
+
+	// The largest total size of the files in the checkout of the tip
+	// of any reference in the group.
+	MaxCheckoutBlobSize counts.Count64 `json:"max_checkout_blob_size"`
+
+	// The reference whose checkout is the largest.
+	MaxCheckoutBlobSizeRef *Path `json:"max_checkout_blob_size_ref,omitempty"`
+}
+
+// IgnoredRefs describes the references that were not walked.
+type IgnoredRefs struct {
+	// Count is the total number of references that were not walked.
+	Count counts.Count32 `json:"count"`
+
+	// Refnames are the names of the first references that were not
+	// walked, up to the limit set by `ScanOptions.ListIgnoredRefs`.
+	// If `Count` is larger than its length, the list was truncated.
+	Refnames []string `json:"refnames"`
+}
+
+// tipAge returns the age of a tip with the specified date, relative
+// to `now`, in whole days. Tips that aren't commits have no age.
+func tipAge(now, date time.Time) counts.Count32 {
+	if date.IsZero() || date.After(now) {
+		return 0
+	}
+	return counts.NewCount32(uint64(now.Sub(date) / (24 * time.Hour)))
 }
 
 type HistorySize struct {
+	// stats is the set of statistics that were computed (nil means
+	// all of them). Only those statistics are included in the
+	// output.
+	stats StatSet
+
+	// profile selects the reference values that are used to compute
+	// the levels of concern, and referenceValues overrides the
+	// reference values of individual statistics.
+	profile         Profile
+	referenceValues ReferenceValues
+
+	// thresholds is the thresholds file that the profile and
+	// reference values came from, if any.
+	thresholds *Thresholds
+
+	// docLinks are the documentation links of the statistics.
+	docLinks DocLinks
+
+	// concernWeights overrides how the levels of concern of
+	// individual statistics are computed.
+	concernWeights ConcernWeights
+
+	// color is true if the table output should be colored (see
+	// `SetColor()`).
+	color bool
+
+	// showScope is true if the table output should include the "Scan
+	// scope" section (see `SetShowScope()`).
+	showScope bool
+
+	// anonymizer, if non-nil, anonymizes the paths and refnames in
+	// the output.
+	anonymizer *Anonymizer
+
+	// The time at which the scan was run. Ages are computed relative
+	// to this time. It isn't part of the JSON output, which would
+	// otherwise differ between two scans of the same repository.
+	ScanTime time.Time `json:"-"`
+
+	// The roots from which the scan started; i.e., the references
+	// that were walked plus any explicit roots.
+	ScanScope []ScopeRoot `json:"scan_scope,omitempty"`
+
+	// EmptyRepository is set if the repository has no references and
+	// an unborn `HEAD` (e.g., because it was just initialized), in
+	// which case all of the statistics are zero.
+	EmptyRepository bool `json:"empty_repository,omitempty"`
+
 	// The total number of unique commits analyzed.
 	UniqueCommitCount counts.Count32 `json:"unique_commit_count"`
 
@@ -119,6 +345,78 @@ type HistorySize struct {
 	// The commit with the maximum number of direct parents.
 	MaxParentCountCommit *Path `json:"max_parent_count_commit,omitempty"`
 
+	// The number of analyzed merge commits. Like the merge bubble
+	// statistics, it is only counted if those are selected.
+	MergeCount counts.Count32 `json:"merge_count"`
+
+	// The largest number of commits that any analyzed merge commit
+	// merged in; i.e., the commits that are reachable from its other
+	// parents but not from its first parent (the "merge bubble").
+	MaxMergeBubble counts.Count32 `json:"max_merge_bubble"`
+
+	// The merge commit with the largest merge bubble.
+	MaxMergeBubbleCommit *Path `json:"max_merge_bubble_commit,omitempty"`
+
+	// The total and the mean number of commits that the analyzed
+	// merge commits merged in.
+	MergeBubbleTotal   counts.Count64 `json:"merge_bubble_total"`
+	AverageMergeBubble counts.Count32 `json:"average_merge_bubble"`
+
+	// The maximum size of the header block of any analyzed commit.
+	MaxCommitHeaderSize counts.Count32 `json:"max_commit_header_size"`
+
+	// The commit with the largest header block.
+	MaxCommitHeaderSizeCommit *Path `json:"max_commit_header_size_commit,omitempty"`
+
+	// The number of headers in analyzed commits and tags that Git
+	// doesn't write itself, or that are repeated even though they
+	// should appear only once.
+	NonstandardHeaderCount counts.Count32 `json:"nonstandard_header_count"`
+
+	// The first commit or tag found with nonstandard headers.
+	NonstandardHeaderObject *Path `json:"nonstandard_header_object,omitempty"`
+
+	// The number of analyzed commits that declare an encoding other
+	// than UTF-8.
+	NonUTF8EncodingCount counts.Count32 `json:"non_utf8_encoding_count"`
+
+	// The number of analyzed commits whose log messages are not valid
+	// UTF-8.
+	InvalidUTF8MessageCount counts.Count32 `json:"invalid_utf8_message_count"`
+
+	// The maximum size of any analyzed commit that declares a
+	// non-UTF-8 encoding or whose log message is not valid UTF-8.
+	MaxNonUTF8CommitSize counts.Count32 `json:"max_non_utf8_commit_size"`
+
+	// The largest commit that declares a non-UTF-8 encoding or whose
+	// log message is not valid UTF-8.
+	MaxNonUTF8CommitSizeCommit *Path `json:"max_non_utf8_commit,omitempty"`
+
+	// The number of distinct author and committer identities (name
+	// and email address) among the analyzed commits.
+	UniqueAuthorCount    counts.Count32 `json:"unique_author_count"`
+	UniqueCommitterCount counts.Count32 `json:"unique_committer_count"`
+
+	// TopCommitters lists the committers whose analyzed commits are
+	// biggest in total, biggest first. It is only set if requested
+	// via `ScanOptions.TopCommitters`.
+	TopCommitters []CommitterTotal `json:"top_committers,omitempty"`
+
+	// The number of analyzed merge commits whose tree is identical
+	// to the tree of one of their parents.
+	EmptyMergeCount counts.Count32 `json:"empty_merge_count"`
+
+	// The number of analyzed non-merge commits whose tree is
+	// identical to the tree of their parent.
+	EmptyCommitCount counts.Count32 `json:"empty_commit_count"`
+
+	// The number of analyzed commits that have no parents.
+	RootCommitCount counts.Count32 `json:"root_commit_count"`
+
+	// The number of disjoint histories among the analyzed commits;
+	// i.e., the number of connected components of the commit graph.
+	DisconnectedHistoryCount counts.Count32 `json:"disconnected_history_count"`
+
 	// The total number of unique trees analyzed.
 	UniqueTreeCount counts.Count32 `json:"unique_tree_count"`
 
@@ -134,6 +432,38 @@ type HistorySize struct {
 	// The tree with the maximum number of entries.
 	MaxTreeEntriesTree *Path `json:"max_tree_entries_tree,omitempty"`
 
+	// The maximum and minimum number of entries in the root tree of
+	// any analyzed commit, and the commits having those root trees.
+	MaxRootTreeEntries       counts.Count32 `json:"max_root_tree_entries"`
+	MaxRootTreeEntriesCommit *Path          `json:"max_root_tree_entries_commit,omitempty"`
+	MinRootTreeEntries       counts.Count32 `json:"min_root_tree_entries"`
+	MinRootTreeEntriesCommit *Path          `json:"min_root_tree_entries_commit,omitempty"`
+
+	// The number of entries in the root tree of the newest analyzed
+	// commit (i.e., the first one listed by `git rev-list`), and that
+	// commit.
+	CurrentRootTreeEntries       counts.Count32 `json:"current_root_tree_entries"`
+	CurrentRootTreeEntriesCommit *Path          `json:"current_root_tree_entries_commit,omitempty"`
+
+	// The size of the largest tree that is reachable from a tag but
+	// not from any branch.
+	MaxTagOnlyTreeSize counts.Count32 `json:"max_tag_only_tree_size"`
+
+	// The largest tree that is reachable only from tags.
+	MaxTagOnlyTreeSizeTree *Path `json:"max_tag_only_tree_size_tree,omitempty"`
+
+	// The number of unique trees that contain more than one entry
+	// referring to the same subtree.
+	DuplicateSubtreeTreeCount counts.Count32 `json:"duplicate_subtree_tree_count"`
+
+	// The maximum number of entries in any single tree that refer to
+	// the same subtree. Large values are a hallmark of git bombs.
+	MaxDuplicateSubtreeEntries counts.Count32 `json:"max_duplicate_subtree_entries"`
+
+	// The tree with the maximum number of entries referring to the
+	// same subtree.
+	MaxDuplicateSubtreeEntriesTree *Path `json:"max_duplicate_subtree_entries_tree,omitempty"`
+
 	// The total number of unique blobs analyzed.
 	UniqueBlobCount counts.Count32 `json:"unique_blob_count"`
 
@@ -146,24 +476,363 @@ type HistorySize struct {
 	// The biggest blob found.
 	MaxBlobSizeBlob *Path `json:"max_blob_size_blob,omitempty"`
 
+	// The maximum size of any blob that is marked executable in some
+	// tree.
+	MaxExecutableBlobSize counts.Count32 `json:"max_executable_blob_size"`
+
+	// The tree entry of the biggest executable blob found.
+	MaxExecutableBlobSizeBlob *Path `json:"max_executable_blob_size_blob,omitempty"`
+
+	// The size of the largest blob that is reachable from a tag but
+	// not from any branch.
+	MaxTagOnlyBlobSize counts.Count32 `json:"max_tag_only_blob_size"`
+
+	// The largest blob that is reachable only from tags.
+	MaxTagOnlyBlobSizeBlob *Path `json:"max_tag_only_blob_size_blob,omitempty"`
+
+	// The number of blobs of at least 2 GiB, which 32-bit builds of
+	// Git, some HTTP proxies, and some archive formats can't handle,
+	// and one of them.
+	Over2GiBBlobCount counts.Count32 `json:"over_2gib_blob_count"`
+	Over2GiBBlob      *Path          `json:"over_2gib_blob,omitempty"`
+
+	// The number of blobs of at least 4 GiB, whose sizes overflow
+	// 32-bit counters (e.g., in the index or in zip archives), and
+	// one of them.
+	Over4GiBBlobCount counts.Count32 `json:"over_4gib_blob_count"`
+	Over4GiBBlob      *Path          `json:"over_4gib_blob,omitempty"`
+
+	// The size of the largest version of any `.gitmodules` file, the
+	// tree entry of that version, and the total size of the distinct
+	// versions.
+	MaxGitmodulesSize     counts.Count32 `json:"max_gitmodules_size"`
+	MaxGitmodulesSizeBlob *Path          `json:"max_gitmodules_size_blob,omitempty"`
+	UniqueGitmodulesSize  counts.Count64 `json:"unique_gitmodules_size"`
+
+	// The same for `.gitattributes` files.
+	MaxGitattributesSize     counts.Count32 `json:"max_gitattributes_size"`
+	MaxGitattributesSizeBlob *Path          `json:"max_gitattributes_size_blob,omitempty"`
+	UniqueGitattributesSize  counts.Count64 `json:"unique_gitattributes_size"`
+
+	// The same for files named like Git hooks (e.g., "pre-commit").
+	MaxHookFileSize     counts.Count32 `json:"max_hook_file_size"`
+	MaxHookFileSizeBlob *Path          `json:"max_hook_file_size_blob,omitempty"`
+	UniqueHookFileSize  counts.Count64 `json:"unique_hook_file_size"`
+
+	// The number of distinct versions of generated lockfiles (e.g.,
+	// `package-lock.json` or `go.sum`), their total size, and the
+	// largest of them along with its tree entry.
+	LockfileVersionCount counts.Count32 `json:"lockfile_version_count"`
+	UniqueLockfileSize   counts.Count64 `json:"unique_lockfile_size"`
+	MaxLockfileSize      counts.Count32 `json:"max_lockfile_size"`
+	MaxLockfileSizeBlob  *Path          `json:"max_lockfile_size_blob,omitempty"`
+
+	// The same, broken down by lockfile name, with the most total
+	// bytes first.
+	Lockfiles []LockfileChurn `json:"lockfiles,omitempty"`
+
 	// The total number of unique tag objects analyzed.
 	UniqueTagCount counts.Count32 `json:"unique_tag_count"`
 
+	// The total size of all tag objects analyzed.
+	UniqueTagSize counts.Count64 `json:"unique_tag_size"`
+
+	// The number of analyzed tags that are signed.
+	SignedTagCount counts.Count32 `json:"signed_tag_count"`
+
+	// The maximum size of any analyzed tag.
+	MaxTagSize counts.Count32 `json:"max_tag_size"`
+
+	// The tag with the maximum size.
+	MaxTagSizeTag *Path `json:"max_tag_size_tag,omitempty"`
+
 	// The maximum number of tags in a chain.
 	MaxTagDepth counts.Count32 `json:"max_tag_depth"`
 
 	// The tag with the maximum tag depth.
 	MaxTagDepthTag *Path `json:"max_tag_depth_tag,omitempty"`
 
+	// The total number of bytes that the reachable objects occupy in
+	// the object database (i.e., compressed and possibly deltified).
+	ReachableDiskSize counts.Count64 `json:"reachable_disk_size"`
+
+	// The number of loose objects in the object database (whether
+	// reachable or not).
+	LooseObjectCount counts.Count32 `json:"loose_object_count"`
+
+	// The largest number of loose objects in any one fan-out
+	// directory, and the name of that directory.
+	MaxLooseObjectShardCount counts.Count32 `json:"max_loose_object_shard_count"`
+	MaxLooseObjectShard      string         `json:"max_loose_object_shard,omitempty"`
+
+	// The age, in days, of the oldest loose object. Old loose
+	// objects suggest that `git gc` is failing.
+	OldestLooseObjectAge counts.Count32 `json:"oldest_loose_object_age"`
+
 	// The number of references analyzed. Note that we don't eliminate
 	// duplicates if the user passes the same reference more than
 	// once.
 	ReferenceCount counts.Count32 `json:"reference_count"`
 
+	// The number of branches (references under `refs/heads/`) that
+	// point at something other than a commit, and the first one
+	// found. Git won't create such branches itself, but they can be
+	// pushed or written by other tools.
+	NonCommitBranchCount counts.Count32 `json:"non_commit_branch_count"`
+	NonCommitBranch      *Path          `json:"non_commit_branch,omitempty"`
+
+	// The number of tags (references under `refs/tags/`) that don't
+	// lead to a commit, even after peeling any annotated tags, and
+	// the first one found.
+	NonCommitTagCount counts.Count32 `json:"non_commit_tag_count"`
+	NonCommitTag      *Path          `json:"non_commit_tag,omitempty"`
+
 	// ReferenceGroups keeps track of how many references in each
 	// reference group were scanned.
 	ReferenceGroups map[RefGroupSymbol]*counts.Count32 `json:"reference_groups"`
 
+	// ReferenceGroupTips keeps track of statistics about the tips
+	// of the references in each reference group.
+	ReferenceGroupTips map[RefGroupSymbol]*RefGroupTipSize `json:"reference_group_tips"`
+
+	// objectRefGroups holds the refgroups from which each cited
+	// object is reachable. See `attributeRefGroups()`.
+	objectRefGroups map[git.OID][]RefGroupSymbol
+
+	// RefGroupSharing holds estimates of how many bytes of unique
+	// objects pairs of refgroups share. It is only set if requested
+	// via `ScanOptions.SharingMatrix`.
+	RefGroupSharing []RefGroupSharing `json:"ref_group_sharing,omitempty"`
+
+	// SharedTrees lists the heaviest trees that appear at several
+	// paths under different top-level directories or in different
+	// refgroups, heaviest first. It is only set if requested via
+	// `ScanOptions.SharedTrees`.
+	SharedTrees []SharedTree `json:"shared_trees,omitempty"`
+
+	// RefGroupEmptyCommits counts the empty commits in each
+	// refgroup. It is only set if requested via
+	// `ScanOptions.EmptyCommitGroups`.
+	RefGroupEmptyCommits []RefGroupEmptyCommits `json:"refgroup_empty_commits,omitempty"`
+
+	// RefGroupTotals holds the number and total size of the unique
+	// objects reachable from each refgroup. It is only set if
+	// requested via `ScanOptions.RefGroupTotals` or
+	// `ScanOptions.Baseline`.
+	RefGroupTotals map[RefGroupSymbol]RefGroupTotal `json:"ref_group_totals,omitempty"`
+
+	// Growth holds how much each refgroup has grown since a
+	// baseline. It is only set if requested via
+	// `ScanOptions.Baseline`.
+	Growth *Growth `json:"growth,omitempty"`
+
+	// HealthScore summarizes the levels of concern of the statistics
+	// as a single number. It is only set if computed via
+	// `ComputeHealthScore()`.
+	HealthScore *HealthScore `json:"health_score,omitempty"`
+
+	// RefGroupActivity holds the number of commits in each refgroup
+	// per period. It is only set if requested via
+	// `ScanOptions.RefGroupActivity`.
+	RefGroupActivity *RefGroupActivity `json:"ref_group_activity,omitempty"`
+
+	// RecentBlobs lists the largest blob that recent commits
+	// introduced into each refgroup. It is only set if requested via
+	// `ScanOptions.RecentBlobs`.
+	RecentBlobs *RecentBlobs `json:"recent_blobs,omitempty"`
+
+	// RecentActivity totals the objects that were introduced by the
+	// last few commits on the default branch. It is only set if
+	// requested via `ScanOptions.RecentCommits`.
+	RecentActivity *RecentActivity `json:"recent_activity,omitempty"`
+
+	// RootMaxima holds the largest blob and the biggest checkout
+	// reachable from each explicit root. It is only set if requested
+	// via `ScanOptions.RootMaxima`.
+	RootMaxima []RootMaximum `json:"root_maxima,omitempty"`
+
+	// ODBDelta describes the objects that were added to the object
+	// database since a saved state. It is only set if requested via
+	// `ScanOptions.SinceState`.
+	ODBDelta *ODBDelta `json:"odb_delta,omitempty"`
+
+	// Packfiles describes the packfiles in the repository's object
+	// database, largest first. It is only set if requested via
+	// `ScanOptions.Packfiles`.
+	Packfiles []PackfileInfo `json:"packfiles,omitempty"`
+
+	// PackfileDuplicates summarizes the objects that are stored in
+	// more than one packfile. It is only set if requested via
+	// `ScanOptions.Packfiles`.
+	PackfileDuplicates *PackfileDuplicates `json:"packfile_duplicates,omitempty"`
+
+	// PackfileFixups summarizes the objects in the packfiles that
+	// take more than one pack access to read. It is only set if
+	// requested via `ScanOptions.Packfiles`.
+	PackfileFixups *PackfileFixups `json:"packfile_fixups,omitempty"`
+
+	// BlobCompressibility holds estimates of how well the largest
+	// blobs compress, largest first. It is only set if requested via
+	// `ScanOptions.Compressibility`.
+	BlobCompressibility []BlobCompressibility `json:"blob_compressibility,omitempty"`
+
+	// FileLineage holds the histories of the files that hold the
+	// largest blobs, followed across renames. It is only set if
+	// requested via `ScanOptions.FileLineage`.
+	FileLineage []FileLineage `json:"file_lineage,omitempty"`
+
+	// LineEndingDuplicates holds the sets of large text blobs that
+	// only differ by their line endings, most wasteful first. It is
+	// only set if requested via `ScanOptions.LineEndingDuplicates`.
+	LineEndingDuplicates []LineEndingDuplicates `json:"line_ending_duplicates,omitempty"`
+
+	// NormalizedDuplicates describes the small text blobs that only
+	// differ from others by whitespace or a byte order mark. It is
+	// only set if requested via
+	// `ScanOptions.NormalizedDuplicatesCutoff`.
+	NormalizedDuplicates *NormalizedDuplicates `json:"normalized_duplicates,omitempty"`
+
+	// CloneEstimate is a rough estimate of how long a clone of the
+	// repository takes. It is only set if requested via
+	// `ScanOptions.CloneBandwidth` and all statistics are computed.
+	CloneEstimate *CloneEstimate `json:"clone_estimate,omitempty"`
+
+	// ObjectsSince, if set, is the date since which objects were
+	// counted. See `ScanOptions.ObjectsSince`.
+	ObjectsSince *time.Time `json:"objects_since,omitempty"`
+
+	// HostedSize is the size that a hosting service reports for its
+	// copy of the repository. It is only set via `SetHostedSize()`.
+	HostedSize *HostedSize `json:"hosted_size,omitempty"`
+
+	// Head describes what `HEAD` referred to, if it was requested as
+	// a root via `ScanOptions.Head`.
+	Head *git.Head `json:"head,omitempty"`
+
+	// ShallowBoundary describes where the history is cut off, if
+	// the repository is a shallow clone.
+	ShallowBoundary *ShallowBoundary `json:"shallow_boundary,omitempty"`
+
+	// Caveats describes the replace references, grafts, and shallow
+	// boundary that make the measured history differ from the one
+	// that other Git commands show. It is nil if there are none.
+	Caveats *Caveats `json:"caveats,omitempty"`
+
+	// AgeBuckets holds the number and size of the unique objects,
+	// grouped by the year in which they first appeared in the
+	// history, oldest first. It is only set if requested via
+	// `ScanOptions.AgeBuckets`.
+	AgeBuckets []AgeBucket `json:"age_buckets,omitempty"`
+
+	// SizeBudget lists the paths whose blobs account for a share of
+	// the total size of the unique blobs. It is only set if requested
+	// via `ScanOptions.SizeBudget`.
+	SizeBudget *SizeBudget `json:"size_budget,omitempty"`
+
+	// ImportArtifacts describes the leftovers of an import from
+	// another version control system. It is only set if requested
+	// via `ScanOptions.ImportArtifacts`.
+	ImportArtifacts *ImportArtifacts `json:"import_artifacts,omitempty"`
+
+	// DirectorySizes holds the cumulative historical size of every
+	// path, for looking up the sizes of parts of the tree. It is only
+	// set if requested via `ScanOptions.DirectorySizes`, and isn't
+	// included in the JSON output.
+	DirectorySizes *DirectorySizes `json:"-"`
+
+	// LFSMigration estimates the savings of migrating the biggest
+	// files to Git LFS. It is only set if requested via
+	// `ScanOptions.LFSCutoff`.
+	LFSMigration *LFSMigration `json:"lfs_migration,omitempty"`
+
+	// HostingLimits counts the blobs that exceed the limits of each
+	// of the hosting presets requested via
+	// `ScanOptions.HostingPresets`.
+	HostingLimits []HostingLimitViolations `json:"hosting_limits,omitempty"`
+
+	// ReflogOnly describes the objects that are reachable only from
+	// reflogs. It is only set if requested via
+	// `ScanOptions.Reflogs`.
+	ReflogOnly *ReflogOnly `json:"reflog_only,omitempty"`
+
+	// ForcePushes describes the history that was replaced by
+	// non-fast-forward updates of branches and is kept only by their
+	// reflogs. It is only set if requested via
+	// `ScanOptions.ForcePushes`.
+	ForcePushes *ForcePushes `json:"force_pushes,omitempty"`
+
+	// RefChurn lists the most frequently updated references. It is
+	// only set if requested via `ScanOptions.RefChurn`.
+	RefChurn *RefChurn `json:"ref_churn,omitempty"`
+
+	// RedundantRefs lists the branches that duplicate or are
+	// contained in other branches. It is only set if requested via
+	// `ScanOptions.RedundantRefs`.
+	RedundantRefs *RedundantRefs `json:"redundant_refs,omitempty"`
+
+	// ExactCheckout compares the expanded counts of the biggest
+	// checkout with the exact number of distinct paths and blobs in
+	// it. It is only set if requested via
+	// `ScanOptions.ExactCheckout`.
+	ExactCheckout *ExactCheckout `json:"exact_checkout,omitempty"`
+
+	// CheckoutExtensions breaks down the checkout with the largest
+	// total size by extension. It is only set if requested via
+	// `ScanOptions.CheckoutExtensions`.
+	CheckoutExtensions *CheckoutExtensions `json:"checkout_extensions,omitempty"`
+
+	// HardLimits lists the hard limits of Git and of common
+	// filesystems that the measured maxima exceed or approach.
+	HardLimits []HardLimitFinding `json:"hard_limits,omitempty"`
+
+	// UnreachableObjects describes the unreachable objects and how
+	// much of them `git gc` would prune. It is only set if requested
+	// via `ScanOptions.Unreachable`.
+	UnreachableObjects *UnreachableObjects `json:"unreachable_objects,omitempty"`
+
+	// ScanIntegrity records the objects that were enumerated but
+	// couldn't be read during the scan. It is only set if there were
+	// any.
+	ScanIntegrity *ScanIntegrity `json:"scan_integrity,omitempty"`
+
+	// GitCapabilities describes the optional features of the `git`
+	// executable that was used for the scan. It is only set if the
+	// "gitCapabilities" statistic was requested explicitly.
+	GitCapabilities *git.Capabilities `json:"git_capabilities,omitempty"`
+
+	// NamesBudget records how often the search for the names of
+	// the objects that the output cites was cut short by
+	// `ScanOptions.NamesBudget`. It is only set if that happened.
+	NamesBudget *NamesBudgetUsage `json:"names_budget,omitempty"`
+
+	// IgnoredRefs lists the references that were not walked. It is
+	// only set if requested via `ScanOptions.ListIgnoredRefs`.
+	IgnoredRefs *IgnoredRefs `json:"ignored_refs,omitempty"`
+
+	// Estimates of the peak memory, in bytes, that the scan used to
+	// keep track of the sizes of blobs, trees, commits, and tags, of
+	// the trees that were waiting for their entries' sizes, and of
+	// the objects whose paths were being sought, plus the sum of
+	// those peaks. See `recordMemoryUsage()`.
+	GraphBlobMemory         counts.Count64 `json:"graph_blob_memory"`
+	GraphTreeMemory         counts.Count64 `json:"graph_tree_memory"`
+	GraphPendingTreeMemory  counts.Count64 `json:"graph_pending_tree_memory"`
+	GraphCommitMemory       counts.Count64 `json:"graph_commit_memory"`
+	GraphTagMemory          counts.Count64 `json:"graph_tag_memory"`
+	GraphPathResolverMemory counts.Count64 `json:"graph_path_resolver_memory"`
+	GraphMemory             counts.Count64 `json:"graph_memory"`
+
+	// PackObjectsMemory is the estimated peak memory that `git
+	// pack-objects` uses to serve a clone of the repository, and
+	// PackObjectsEstimate explains how it was estimated. See
+	// `PackObjectsEstimate`.
+	PackObjectsMemory   counts.Count64       `json:"pack_objects_memory"`
+	PackObjectsEstimate *PackObjectsEstimate `json:"pack_objects_estimate,omitempty"`
+
+	// CustomStats holds the values of the statistics that are
+	// defined in gitconfig. See `ScanOptions.CustomStats`.
+	CustomStats []*CustomStatValue `json:"custom_stats,omitempty"`
+
 	// The maximum TreeSize in the analyzed history (where each
 	// attribute is maximized separately).
 
@@ -193,12 +862,80 @@ type HistorySize struct {
 	// The tree with the maximum expanded blob count.
 	MaxExpandedBlobCountTree *Path `json:"max_expanded_blob_count_tree,omitempty"`
 
+	// maxExpandedBlobCountTreeSize is the `TreeSize` of
+	// `MaxExpandedBlobCountTree`.
+	maxExpandedBlobCountTreeSize TreeSize
+
 	// The total size of all blobs, including duplicates.
 	MaxExpandedBlobSize counts.Count64 `json:"max_expanded_blob_size"`
 
 	// The tree with the maximum expanded blob size.
 	MaxExpandedBlobSizeTree *Path `json:"max_expanded_blob_size_tree,omitempty"`
 
+	// maxExpandedBlobSizeTreeOID is the OID of the tree with the
+	// maximum expanded blob size, which is known even if objects
+	// aren't being named.
+	maxExpandedBlobSizeTreeOID git.OID
+
+	// The estimated size of the index file for the checkout that
+	// would have the largest index. See `estimateIndexSize()`.
+	MaxCheckoutIndexSize counts.Count64 `json:"max_checkout_index_size"`
+
+	// The tree whose checkout would have the largest index.
+	MaxCheckoutIndexSizeTree *Path `json:"max_checkout_index_size_tree,omitempty"`
+
+	// maxCheckoutIndexTreeSize is the `TreeSize` of
+	// `MaxCheckoutIndexSizeTree`.
+	maxCheckoutIndexTreeSize TreeSize
+
+	// IndexEstimate describes the index of the checkout with the
+	// largest index, and whether its size calls for any of Git's
+	// features for large indexes. It is only set if
+	// `maxCheckoutIndexSize` is computed.
+	IndexEstimate *IndexEstimate `json:"index_estimate,omitempty"`
+
+	// ConfigDrift lists the gitconfig settings of the repository
+	// that don't suit its size, as measured by the scan, along with
+	// their recommended values. See `ConfigDrift`.
+	ConfigDrift []ConfigDrift `json:"config_drift,omitempty"`
+
+	// CommitGraphWrite describes the commit-graph that was written
+	// after the scan. It is only set if requested via
+	// `ScanOptions.WriteCommitGraph`.
+	CommitGraphWrite *CommitGraphWrite `json:"commit_graph_write,omitempty"`
+
+	// WideTrees lists the widest trees that have more entries than
+	// the reference value of "maxTreeEntries", most of them with
+	// machine-generated names, widest first. It is only set if
+	// `maxTreeEntries` is computed.
+	WideTrees []WideTree `json:"wide_trees,omitempty"`
+
+	// TopObjects lists, for each statistic that cites an object
+	// (e.g., "maxBlobSize"), the objects with the biggest values,
+	// biggest first, keyed by the statistic's symbol. It is only set
+	// if more than one object per statistic was requested via
+	// `ScanOptions.TopObjects`.
+	TopObjects map[string][]TopObject `json:"top_objects,omitempty"`
+
+	// ObjectNotes holds the `git notes` attached to the objects that
+	// the report cites, keyed by the objects' OIDs. It is only set if
+	// any of the notes refs in `ScanOptions.NotesRefs` has notes for
+	// them.
+	ObjectNotes map[string][]ObjectNote `json:"object_notes,omitempty"`
+
+	// AnomalyExamples lists, for each statistic that counts anomalies
+	// (e.g., "windowsUnsafeEntryCount"), some of the objects that it
+	// counted, lowest OID first, keyed by the statistic's symbol. It
+	// is only set if requested via `ScanOptions.AnomalyExamples`.
+	AnomalyExamples map[string][]AnomalyExample `json:"anomaly_examples,omitempty"`
+
+	// The total number of blobs marked executable, including
+	// duplicates.
+	MaxExpandedExecutableCount counts.Count32 `json:"max_expanded_executable_count"`
+
+	// The tree with the maximum expanded executable count.
+	MaxExpandedExecutableCountTree *Path `json:"max_expanded_executable_count_tree,omitempty"`
+
 	// The total number of symbolic links, including duplicates.
 	MaxExpandedLinkCount counts.Count32 `json:"max_expanded_link_count"`
 
@@ -210,6 +947,90 @@ type HistorySize struct {
 
 	// The tree with the maximum expanded submodule count.
 	MaxExpandedSubmoduleCountTree *Path `json:"max_expanded_submodule_count_tree,omitempty"`
+
+	// The number of trees whose expansion was cut short because they
+	// exceeded the limit on expanded entries, and had many times
+	// more entries than distinct objects (not counting trees that
+	// merely contain such a tree). Each one is a potential git bomb.
+	PotentialGitBombCount counts.Count32 `json:"potential_git_bomb_count"`
+
+	// The first tree found whose expansion was cut short.
+	PotentialGitBombTree *Path `json:"potential_git_bomb_tree,omitempty"`
+
+	// The number of distinct symlinks (i.e., symlink blobs) whose
+	// targets are absolute paths.
+	AbsoluteSymlinkCount counts.Count32 `json:"absolute_symlink_count"`
+
+	// A symlink whose target is an absolute path.
+	AbsoluteSymlink *Path `json:"absolute_symlink,omitempty"`
+
+	// The number of trees containing symlinks that, directly or via
+	// each other, refer back to themselves.
+	SymlinkCycleTreeCount counts.Count32 `json:"symlink_cycle_tree_count"`
+
+	// A tree containing a symlink cycle.
+	SymlinkCycleTree *Path `json:"symlink_cycle_tree,omitempty"`
+
+	// The number of distinct symlink targets. (Since a symlink's
+	// target is the contents of its blob, this is also the number of
+	// distinct symlink blobs.)
+	SymlinkTargetCount counts.Count32 `json:"symlink_target_count"`
+
+	// The length of the longest symlink target.
+	MaxSymlinkTargetLength counts.Count32 `json:"max_symlink_target_length"`
+
+	// A symlink with the longest target.
+	MaxSymlinkTargetLengthSymlink *Path `json:"max_symlink_target_length_symlink,omitempty"`
+
+	// The number of distinct symlinks whose targets climb, via "..",
+	// above the root of the checkout. See `findEscapingSymlinks()`.
+	EscapingSymlinkCount counts.Count32 `json:"escaping_symlink_count"`
+
+	// A symlink whose target climbs above the root of the checkout.
+	EscapingSymlink *Path `json:"escaping_symlink,omitempty"`
+
+	// The maximum number of paths whose names can't be checked out
+	// on Windows in the checkout of `HEAD` or in the checkout with
+	// the largest total size of files, and that checkout. See
+	// `measureWindowsUnsafeNames()`.
+	MaxExpandedWindowsUnsafeCount     counts.Count32 `json:"max_expanded_windows_unsafe_count"`
+	MaxExpandedWindowsUnsafeCountTree *Path          `json:"max_expanded_windows_unsafe_count_tree,omitempty"`
+
+	// The number of distinct tree entries in those checkouts whose
+	// names can't be checked out on Windows, and one of them.
+	WindowsUnsafeEntryCount counts.Count32 `json:"windows_unsafe_entry_count"`
+	WindowsUnsafeEntry      *Path          `json:"windows_unsafe_entry,omitempty"`
+
+	// maxEntryNameLength is the length of the longest tree entry
+	// name, and maxEntryNameLengthEntry the entry, if it is at least
+	// `minRecordedEntryNameLength`. It is only used to check the
+	// hard limits.
+	maxEntryNameLength      counts.Count32
+	maxEntryNameLengthEntry *Path
+
+	// The number of trees containing entries whose names are
+	// different but equivalent under Unicode normalization.
+	NormalizationCollisionTreeCount counts.Count32 `json:"normalization_collision_tree_count"`
+
+	// A tree containing entries whose names collide under Unicode
+	// normalization.
+	NormalizationCollisionTree *Path `json:"normalization_collision_tree,omitempty"`
+
+	// The number of distinct tree entries whose names are not in
+	// Unicode normalization form C or mix letters from several
+	// scripts.
+	UnusualUnicodeEntryCount counts.Count32 `json:"unusual_unicode_entry_count"`
+
+	// A tree entry whose name is not normalized or mixes scripts.
+	UnusualUnicodeEntry *Path `json:"unusual_unicode_entry,omitempty"`
+
+	// The number of trees that contain a `.git` entry, or that look
+	// like a Git repository (with `HEAD`, `objects/`, and `refs/`)
+	// committed as a regular directory.
+	NestedRepositoryTreeCount counts.Count32 `json:"nested_repository_tree_count"`
+
+	// A tree containing a nested repository.
+	NestedRepositoryTree *Path `json:"nested_repository_tree,omitempty"`
 }
 
 // Convenience function: forget `*path` if it is non-nil and overwrite
@@ -227,22 +1048,103 @@ func setPath(
 }
 
 func (s *HistorySize) recordBlob(g *Graph, oid git.OID, blobSize BlobSize) {
+	if !g.countsTowardTotals(oid) {
+		return
+	}
 	s.UniqueBlobCount.Increment(1)
 	s.UniqueBlobSize.Increment(counts.Count64(blobSize.Size))
+	s.recordInteroperabilityBoundaries(g, oid, blobSize.Size)
+	if !g.countsTowardMaxima(oid) {
+		return
+	}
 	if s.MaxBlobSize.AdjustMaxIfNecessary(blobSize.Size) {
 		setPath(g.pathResolver, &s.MaxBlobSizeBlob, oid, "blob")
 	}
+	g.recordTopObject("maxBlobSize", uint64(blobSize.Size), oid, "blob")
+	if g.isTagOnly(oid) {
+		if s.MaxTagOnlyBlobSize.AdjustMaxIfNecessary(blobSize.Size) {
+			setPath(g.pathResolver, &s.MaxTagOnlyBlobSizeBlob, oid, "blob")
+		}
+		g.recordTopObject("maxTagOnlyBlobSize", uint64(blobSize.Size), oid, "blob")
+	}
+}
+
+// recordInteroperabilityBoundaries records whether the blob with the
+// specified `oid` is at least 2 GiB or 4 GiB in size. Blob sizes are
+// 32-bit counters, so a blob of 4 GiB or more has a saturated size.
+func (s *HistorySize) recordInteroperabilityBoundaries(g *Graph, oid git.OID, size counts.Count32) {
+	if size < 1<<31 {
+		return
+	}
+	s.Over2GiBBlobCount.Increment(1)
+	g.recordAnomalyExample("over2GiBBlobCount", oid, "blob")
+	if s.Over2GiBBlob == nil {
+		s.Over2GiBBlob = g.pathResolver.RequestPath(oid, "blob")
+	}
+
+	if _, overflow := size.ToUint64(); !overflow {
+		return
+	}
+	s.Over4GiBBlobCount.Increment(1)
+	g.recordAnomalyExample("over4GiBBlobCount", oid, "blob")
+	if s.Over4GiBBlob == nil {
+		s.Over4GiBBlob = g.pathResolver.RequestPath(oid, "blob")
+	}
 }
 
 func (s *HistorySize) recordTree(
 	g *Graph, oid git.OID, treeSize TreeSize, size counts.Count32, treeEntries counts.Count32,
+	duplicateSubtrees counts.Count32,
 ) {
+	if !g.countsTowardTotals(oid) {
+		return
+	}
 	s.UniqueTreeCount.Increment(1)
 	s.UniqueTreeSize.Increment(counts.Count64(size))
 	s.UniqueTreeEntries.Increment(counts.Count64(treeEntries))
+	if duplicateSubtrees > 0 {
+		s.DuplicateSubtreeTreeCount.Increment(1)
+		g.recordAnomalyExample("duplicateSubtreeTreeCount", oid, "tree")
+	}
+
+	if !g.countsTowardMaxima(oid) {
+		return
+	}
+
 	if s.MaxTreeEntries.AdjustMaxIfNecessary(treeEntries) {
 		setPath(g.pathResolver, &s.MaxTreeEntriesTree, oid, "tree")
 	}
+	if g.isTagOnly(oid) {
+		if s.MaxTagOnlyTreeSize.AdjustMaxIfNecessary(size) {
+			setPath(g.pathResolver, &s.MaxTagOnlyTreeSizeTree, oid, "tree")
+		}
+		g.recordTopObject("maxTagOnlyTreeSize", uint64(size), oid, "tree")
+	}
+	if duplicateSubtrees > 0 &&
+		s.MaxDuplicateSubtreeEntries.AdjustMaxIfNecessary(duplicateSubtrees) {
+		setPath(g.pathResolver, &s.MaxDuplicateSubtreeEntriesTree, oid, "tree")
+	}
+
+	if g.topObjects > 1 {
+		for _, top := range []struct {
+			symbol string
+			value  uint64
+		}{
+			{"maxTreeEntries", uint64(treeEntries)},
+			{"maxDuplicateSubtreeEntries", uint64(duplicateSubtrees)},
+			{"maxCheckoutPathDepth", uint64(treeSize.MaxPathDepth)},
+			{"maxCheckoutPathLength", uint64(treeSize.MaxPathLength)},
+			{"maxCheckoutTreeCount", uint64(treeSize.ExpandedTreeCount)},
+			{"maxCheckoutBlobCount", uint64(treeSize.ExpandedBlobCount)},
+			{"maxCheckoutBlobSize", uint64(treeSize.ExpandedBlobSize)},
+			{"maxCheckoutIndexSize", uint64(estimateIndexSize(treeSize))},
+			{"maxCheckoutExecutableCount", uint64(treeSize.ExpandedExecutableCount)},
+			{"maxCheckoutLinkCount", uint64(treeSize.ExpandedLinkCount)},
+			{"maxCheckoutSubmoduleCount", uint64(treeSize.ExpandedSubmoduleCount)},
+		} {
+			g.recordTopObject(top.symbol, top.value, oid, "tree")
+		}
+	}
 
 	if s.MaxPathDepth.AdjustMaxIfNecessary(treeSize.MaxPathDepth) {
 		setPath(g.pathResolver, &s.MaxPathDepthTree, oid, "tree")
@@ -255,9 +1157,18 @@ func (s *HistorySize) recordTree(
 	}
 	if s.MaxExpandedBlobCount.AdjustMaxIfNecessary(treeSize.ExpandedBlobCount) {
 		setPath(g.pathResolver, &s.MaxExpandedBlobCountTree, oid, "tree")
+		s.maxExpandedBlobCountTreeSize = treeSize
 	}
 	if s.MaxExpandedBlobSize.AdjustMaxIfNecessary(treeSize.ExpandedBlobSize) {
 		setPath(g.pathResolver, &s.MaxExpandedBlobSizeTree, oid, "tree")
+		s.maxExpandedBlobSizeTreeOID = oid
+	}
+	if s.MaxCheckoutIndexSize.AdjustMaxIfNecessary(estimateIndexSize(treeSize)) {
+		setPath(g.pathResolver, &s.MaxCheckoutIndexSizeTree, oid, "tree")
+		s.maxCheckoutIndexTreeSize = treeSize
+	}
+	if s.MaxExpandedExecutableCount.AdjustMaxIfNecessary(treeSize.ExpandedExecutableCount) {
+		setPath(g.pathResolver, &s.MaxExpandedExecutableCountTree, oid, "tree")
 	}
 	if s.MaxExpandedLinkCount.AdjustMaxIfNecessary(treeSize.ExpandedLinkCount) {
 		setPath(g.pathResolver, &s.MaxExpandedLinkCountTree, oid, "tree")
@@ -267,33 +1178,277 @@ func (s *HistorySize) recordTree(
 	}
 }
 
+// recordExecutableBlob records that the tree with the specified `oid`
+// has an entry called `name`, referring to the blob `blobOID` of the
+// specified `size`, that is marked executable.
+func (s *HistorySize) recordExecutableBlob(
+	g *Graph, oid git.OID, name string, blobOID git.OID, size BlobSize,
+) {
+	if !g.countsTowardMaxima(blobOID) {
+		return
+	}
+	if s.MaxExecutableBlobSize.AdjustMaxIfNecessary(size.Size) {
+		if s.MaxExecutableBlobSizeBlob != nil {
+			g.pathResolver.ForgetPath(s.MaxExecutableBlobSizeBlob)
+		}
+		s.MaxExecutableBlobSizeBlob = g.pathResolver.RequestEntryPath(oid, name, blobOID, "blob")
+	}
+	g.recordTopEntry("maxExecutableBlobSize", uint64(size.Size), oid, name, blobOID, "blob")
+}
+
+// recordUnusualUnicodeEntry records that the tree with the specified
+// `oid` has an entry called `name`, referring to `childOID`, whose
+// name is not normalized or mixes scripts.
+func (s *HistorySize) recordUnusualUnicodeEntry(
+	g *Graph, oid git.OID, name string, childOID git.OID, objectType string,
+) {
+	s.UnusualUnicodeEntryCount.Increment(1)
+	g.recordAnomalyEntryExample("unusualUnicodeEntryCount", oid, name)
+	if s.UnusualUnicodeEntry == nil {
+		s.UnusualUnicodeEntry = g.pathResolver.RequestEntryPath(oid, name, childOID, objectType)
+	}
+}
+
+// recordNormalizationCollisionTree records that the tree with the
+// specified `oid` has entries whose names collide under Unicode
+// normalization.
+func (s *HistorySize) recordNormalizationCollisionTree(g *Graph, oid git.OID) {
+	s.NormalizationCollisionTreeCount.Increment(1)
+	g.recordAnomalyExample("normalizationCollisionTreeCount", oid, "tree")
+	if s.NormalizationCollisionTree == nil {
+		s.NormalizationCollisionTree = g.pathResolver.RequestPath(oid, "tree")
+	}
+}
+
+// recordNestedRepositoryTree records that the tree with the
+// specified `oid` contains a nested repository.
+func (s *HistorySize) recordNestedRepositoryTree(g *Graph, oid git.OID) {
+	s.NestedRepositoryTreeCount.Increment(1)
+	g.recordAnomalyExample("nestedRepositoryTreeCount", oid, "tree")
+	if s.NestedRepositoryTree == nil {
+		s.NestedRepositoryTree = g.pathResolver.RequestPath(oid, "tree")
+	}
+}
+
+// recordPotentialGitBomb records that the expansion of the tree with
+// the specified `oid` was cut short.
+func (s *HistorySize) recordPotentialGitBomb(g *Graph, oid git.OID) {
+	s.PotentialGitBombCount.Increment(1)
+	g.recordAnomalyExample("potentialGitBombCount", oid, "tree")
+	if s.PotentialGitBombTree == nil {
+		s.PotentialGitBombTree = g.pathResolver.RequestPath(oid, "tree")
+	}
+}
+
+// recordSymlink records the symlink blob `oid`, whose target is
+// `target`. It has to be called before any tree entry referring to
+// the blob is recorded, so that the blob's path can be resolved.
+func (s *HistorySize) recordSymlink(g *Graph, oid git.OID, target string) {
+	s.SymlinkTargetCount.Increment(1)
+	if s.MaxSymlinkTargetLength.AdjustMaxIfNecessary(counts.NewCount32(uint64(len(target)))) {
+		setPath(g.pathResolver, &s.MaxSymlinkTargetLengthSymlink, oid, "blob")
+	}
+
+	if !strings.HasPrefix(target, "/") {
+		return
+	}
+
+	s.AbsoluteSymlinkCount.Increment(1)
+	g.recordAnomalyExample("absoluteSymlinkCount", oid, "blob")
+	if s.AbsoluteSymlink == nil {
+		s.AbsoluteSymlink = g.pathResolver.RequestPath(oid, "blob")
+	}
+}
+
+// recordSymlinkCycleTree records that the tree with the specified
+// `oid` contains a symlink cycle.
+func (s *HistorySize) recordSymlinkCycleTree(g *Graph, oid git.OID) {
+	s.SymlinkCycleTreeCount.Increment(1)
+	g.recordAnomalyExample("symlinkCycleTreeCount", oid, "tree")
+	if s.SymlinkCycleTree == nil {
+		s.SymlinkCycleTree = g.pathResolver.RequestPath(oid, "tree")
+	}
+}
+
 func (s *HistorySize) recordCommit(
 	g *Graph, oid git.OID, commitSize CommitSize,
 	size counts.Count32, parentCount counts.Count32,
 ) {
+	if !g.countsTowardTotals(oid) {
+		return
+	}
 	s.UniqueCommitCount.Increment(1)
 	s.UniqueCommitSize.Increment(counts.Count64(size))
+	if parentCount == 0 {
+		s.RootCommitCount.Increment(1)
+	}
+	if !g.countsTowardMaxima(oid) {
+		return
+	}
 	if s.MaxCommitSize.AdjustMaxIfPossible(size) {
 		setPath(g.pathResolver, &s.MaxCommitSizeCommit, oid, "commit")
 	}
+	g.recordTopObject("maxCommitSize", uint64(size), oid, "commit")
 	s.MaxHistoryDepth.AdjustMaxIfPossible(commitSize.MaxAncestorDepth)
 	if s.MaxParentCount.AdjustMaxIfPossible(parentCount) {
 		setPath(g.pathResolver, &s.MaxParentCountCommit, oid, "commit")
 	}
+	g.recordTopObject("maxCommitParentCount", uint64(parentCount), oid, "commit")
+}
+
+// recordRootTree records that the root tree of the commit with the
+// specified `oid` has `entryCount` entries.
+func (s *HistorySize) recordRootTree(g *Graph, oid git.OID, entryCount counts.Count32) {
+	if !g.countsTowardMaxima(oid) {
+		return
+	}
+	if s.MaxRootTreeEntries.AdjustMaxIfNecessary(entryCount) {
+		setPath(g.pathResolver, &s.MaxRootTreeEntriesCommit, oid, "commit")
+	}
+	if !g.rootTreeSeen || entryCount < s.MinRootTreeEntries {
+		s.MinRootTreeEntries = entryCount
+		setPath(g.pathResolver, &s.MinRootTreeEntriesCommit, oid, "commit")
+		g.rootTreeSeen = true
+	}
+	g.recordTopObject("maxRootTreeEntries", uint64(entryCount), oid, "commit")
+}
+
+// recordCurrentRootTree records that the root tree of the newest
+// commit, `oid`, has `entryCount` entries.
+func (s *HistorySize) recordCurrentRootTree(g *Graph, oid git.OID, entryCount counts.Count32) {
+	s.CurrentRootTreeEntries = entryCount
+	setPath(g.pathResolver, &s.CurrentRootTreeEntriesCommit, oid, "commit")
+}
+
+// recordCommitHeaders records the size of the header block of the
+// commit with the specified `oid`, and the number of nonstandard
+// headers that it contains.
+func (s *HistorySize) recordCommitHeaders(
+	g *Graph, oid git.OID, headerSize, nonstandardHeaderCount counts.Count32,
+) {
+	s.recordNonstandardHeaders(g, oid, "commit", nonstandardHeaderCount)
+	if !g.countsTowardMaxima(oid) {
+		return
+	}
+	if s.MaxCommitHeaderSize.AdjustMaxIfNecessary(headerSize) {
+		setPath(g.pathResolver, &s.MaxCommitHeaderSizeCommit, oid, "commit")
+	}
+	g.recordTopObject("maxCommitHeaderSize", uint64(headerSize), oid, "commit")
+}
+
+// recordCommitEncoding records the encoding-related properties of the
+// commit with the specified `oid`.
+func (s *HistorySize) recordCommitEncoding(g *Graph, oid git.OID, commit *git.Commit) {
+	nonUTF8 := commit.HasNonUTF8Encoding()
+	if nonUTF8 {
+		s.NonUTF8EncodingCount.Increment(1)
+		g.recordAnomalyExample("nonUTF8EncodingCount", oid, "commit")
+	}
+	if commit.InvalidUTF8Message {
+		s.InvalidUTF8MessageCount.Increment(1)
+		g.recordAnomalyExample("invalidUTF8MessageCount", oid, "commit")
+	}
+	if !nonUTF8 && !commit.InvalidUTF8Message {
+		return
+	}
+	if !g.countsTowardMaxima(oid) {
+		return
+	}
+	if s.MaxNonUTF8CommitSize.AdjustMaxIfNecessary(commit.Size) {
+		setPath(g.pathResolver, &s.MaxNonUTF8CommitSizeCommit, oid, "commit")
+	}
+	g.recordTopObject("maxNonUTF8CommitSize", uint64(commit.Size), oid, "commit")
+}
+
+// recordNonstandardHeaders records that the commit or tag with the
+// specified `oid` has `n` nonstandard headers.
+func (s *HistorySize) recordNonstandardHeaders(
+	g *Graph, oid git.OID, objectType string, n counts.Count32,
+) {
+	if n == 0 {
+		return
+	}
+	s.NonstandardHeaderCount.Increment(n)
+	g.recordAnomalyExample("nonstandardHeaderCount", oid, objectType)
+	if s.NonstandardHeaderObject == nil {
+		s.NonstandardHeaderObject = g.pathResolver.RequestPath(oid, objectType)
+	}
+}
+
+// recordEmptyCommit records a commit with `parentCount` parents
+// whose tree is identical to that of one of its parents.
+func (s *HistorySize) recordEmptyCommit(parentCount counts.Count32) {
+	if parentCount > 1 {
+		s.EmptyMergeCount.Increment(1)
+	} else {
+		s.EmptyCommitCount.Increment(1)
+	}
 }
 
-func (s *HistorySize) recordTag(g *Graph, oid git.OID, tagSize TagSize, size counts.Count32) {
+func (s *HistorySize) recordTag(
+	g *Graph, oid git.OID, tagSize TagSize, size counts.Count32, signed bool,
+) {
+	if !g.countsTowardTotals(oid) {
+		return
+	}
 	s.UniqueTagCount.Increment(1)
+	s.UniqueTagSize.Increment(counts.Count64(size))
+	if signed {
+		s.SignedTagCount.Increment(1)
+	}
+	if !g.countsTowardMaxima(oid) {
+		return
+	}
+	if s.MaxTagSize.AdjustMaxIfNecessary(size) {
+		setPath(g.pathResolver, &s.MaxTagSizeTag, oid, "tag")
+	}
+	g.recordTopObject("maxTagSize", uint64(size), oid, "tag")
 	if s.MaxTagDepth.AdjustMaxIfNecessary(tagSize.TagDepth) {
 		setPath(g.pathResolver, &s.MaxTagDepthTag, oid, "tag")
 	}
+	g.recordTopObject("maxTagDepth", uint64(tagSize.TagDepth), oid, "tag")
 }
 
-func (s *HistorySize) recordReference(g *Graph, ref git.Reference) {
+// recordReference records `ref`. `peeledType` is the type of the
+// object that it leads to after peeling any annotated tags, or the
+// empty string if that isn't known.
+func (s *HistorySize) recordReference(g *Graph, ref git.Reference, peeledType git.ObjectType) {
 	s.ReferenceCount.Increment(1)
+
+	switch {
+	case strings.HasPrefix(ref.Refname, "refs/heads/"):
+		if ref.ObjectType != "commit" {
+			s.NonCommitBranchCount.Increment(1)
+			if s.NonCommitBranch == nil {
+				s.NonCommitBranch = newReferencePath(ref, s.anonymizer)
+			}
+		}
+	case strings.HasPrefix(ref.Refname, "refs/tags/"):
+		if peeledType != "" && peeledType != "commit" {
+			s.NonCommitTagCount.Increment(1)
+			if s.NonCommitTag == nil {
+				s.NonCommitTag = newReferencePath(ref, s.anonymizer)
+			}
+		}
+	}
 }
 
-func (s *HistorySize) recordReferenceGroup(g *Graph, group RefGroupSymbol) {
+func (s *HistorySize) recordIgnoredReference(g *Graph, ref git.Reference) {
+	if s.IgnoredRefs == nil {
+		return
+	}
+	s.IgnoredRefs.Count.Increment(1)
+	if len(s.IgnoredRefs.Refnames) < g.listIgnoredRefs {
+		s.IgnoredRefs.Refnames = append(s.IgnoredRefs.Refnames, s.anonymizer.Refname(ref.Refname))
+	}
+}
+
+// recordReferenceGroup records `ref` as a member of `group`.
+// `checkout` is the size of the tree that the reference's tip would
+// check out, if known.
+func (s *HistorySize) recordReferenceGroup(
+	g *Graph, group RefGroupSymbol, ref git.Reference, checkout *TreeSize,
+) {
 	c, ok := s.ReferenceGroups[group]
 	if ok {
 		c.Increment(1)
@@ -301,4 +1456,43 @@ func (s *HistorySize) recordReferenceGroup(g *Graph, group RefGroupSymbol) {
 		n := counts.Count32(1)
 		s.ReferenceGroups[group] = &n
 	}
+
+	tips, ok := s.ReferenceGroupTips[group]
+	if !ok {
+		tips = &RefGroupTipSize{}
+		s.ReferenceGroupTips[group] = tips
+	}
+
+	if tips.MaxRefnameLength.AdjustMaxIfNecessary(counts.NewCount32(uint64(len(ref.Refname)))) {
+		tips.MaxRefnameLengthRef = newReferencePath(ref, s.anonymizer)
+	}
+	depth := counts.NewCount32(uint64(strings.Count(ref.Refname, "/") + 1))
+	if tips.MaxRefnameDepth.AdjustMaxIfNecessary(depth) {
+		tips.MaxRefnameDepthRef = newReferencePath(ref, s.anonymizer)
+	}
+
+	if checkout != nil {
+		if tips.MaxCheckoutBlobCount.AdjustMaxIfNecessary(checkout.ExpandedBlobCount) {
+			tips.MaxCheckoutBlobCountRef = newReferencePath(ref, s.anonymizer)
+		}
+		if tips.MaxCheckoutBlobSize.AdjustMaxIfNecessary(checkout.ExpandedBlobSize) {
+			tips.MaxCheckoutBlobSizeRef = newReferencePath(ref, s.anonymizer)
+		}
+	}
+
+	date := ref.CommitterDate
+	if date.IsZero() {
+		return
+	}
+	if tips.OldestTipDate.IsZero() || date.Before(tips.OldestTipDate) {
+		tips.OldestTipDate = date
+		tips.OldestTipRef = newReferencePath(ref, s.anonymizer)
+	}
+	if tips.NewestTipDate.IsZero() || date.After(tips.NewestTipDate) {
+		tips.NewestTipDate = date
+		tips.NewestTipRef = newReferencePath(ref, s.anonymizer)
+	}
+	if g.staleRefAge != 0 && s.ScanTime.Sub(date) > g.staleRefAge {
+		tips.StaleRefCount.Increment(1)
+	}
 }
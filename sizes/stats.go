@@ -0,0 +1,477 @@
+package sizes
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// scanNeeds is a bitmask describing which kinds of data have to be
+// collected during a scan in order to compute a statistic.
+type scanNeeds uint
+
+const (
+	// needTrees means that tree objects have to be read and
+	// processed.
+	needTrees scanNeeds = 1 << iota
+
+	// needCommits means that commit objects have to be read and
+	// processed.
+	needCommits
+
+	// needTags means that annotated tag objects have to be read and
+	// processed.
+	needTags
+
+	// needSymlinks means that the targets of symbolic links have to
+	// be read and examined. It only makes sense together with
+	// `needTrees`.
+	needSymlinks
+
+	// needPaths means that the statistic cites an object, so a
+	// `PathResolver` is needed to name it.
+	needPaths
+
+	needAll = needTrees | needCommits | needTags | needSymlinks | needPaths
+)
+
+// statNeeds maps the symbol of each statistic (as used for the keys
+// of the JSON output) to the data that have to be collected to
+// compute it. Reference-group statistics, whose symbols start with
+// "refgroup.", are mostly computed from the references alone and
+// aren't listed here; see `refGroupStatNeeds`.
+var statNeeds = map[string]scanNeeds{
+	"uniqueCommitCount":         needCommits,
+	"uniqueCommitSize":          needCommits,
+	"emptyCommitCount":          needCommits,
+	"emptyMergeCount":           needCommits,
+	"nonUTF8EncodingCount":      needCommits,
+	"invalidUTF8MessageCount":   needCommits,
+	"uniqueAuthorCount":         needCommits,
+	"uniqueCommitterCount":      needCommits,
+	"uniqueTreeCount":           needTrees,
+	"uniqueTreeSize":            needTrees,
+	"uniqueTreeEntries":         needTrees,
+	"duplicateSubtreeTreeCount": needTrees,
+	"uniqueBlobCount":           0,
+	"uniqueBlobSize":            0,
+	"uniqueTagCount":            needTags,
+	"uniqueTagSize":             needTags,
+	"signedTagCount":            needTags,
+	"referenceCount":            0,
+	"nonCommitBranchCount":      0,
+	"nonCommitTagCount":         needTags,
+
+	"looseObjectCount":         0,
+	"maxLooseObjectShardCount": 0,
+	"oldestLooseObjectAge":     0,
+
+	"maxCommitSize":              needCommits | needPaths,
+	"maxCommitParentCount":       needCommits | needPaths,
+	"maxCommitHeaderSize":        needCommits | needPaths,
+	"nonstandardHeaderCount":     needCommits | needTags | needPaths,
+	"maxNonUTF8CommitSize":       needCommits | needPaths,
+	"maxTreeEntries":             needTrees | needPaths,
+	"maxRootTreeEntries":         needTrees | needCommits | needPaths,
+	"minRootTreeEntries":         needTrees | needCommits | needPaths,
+	"currentRootTreeEntries":     needTrees | needCommits | needPaths,
+	"maxDuplicateSubtreeEntries": needTrees | needPaths,
+	"maxTagOnlyTreeSize":         needTrees | needPaths,
+	"maxBlobSize":                needPaths,
+	"maxExecutableBlobSize":      needTrees | needPaths,
+	"maxTagOnlyBlobSize":         needPaths,
+	"over2GiBBlobCount":          needPaths,
+	"over4GiBBlobCount":          needPaths,
+	"maxGitmodulesSize":          needTrees | needPaths,
+	"uniqueGitmodulesSize":       needTrees,
+	"maxGitattributesSize":       needTrees | needPaths,
+	"uniqueGitattributesSize":    needTrees,
+	"maxHookFileSize":            needTrees | needPaths,
+	"uniqueHookFileSize":         needTrees,
+	"lockfileVersionCount":       needTrees,
+	"uniqueLockfileSize":         needTrees,
+	"maxLockfileSize":            needTrees | needPaths,
+	"maxTagSize":                 needTags | needPaths,
+
+	"maxHistoryDepth":          needCommits,
+	"rootCommitCount":          needCommits,
+	"disconnectedHistoryCount": needCommits,
+	"maxMergeBubble":           needCommits | needPaths,
+	"averageMergeBubble":       needCommits,
+	"maxTagDepth":              needTags | needPaths,
+
+	"maxCheckoutTreeCount":       needTrees | needPaths,
+	"maxCheckoutPathDepth":       needTrees | needPaths,
+	"maxCheckoutPathLength":      needTrees | needPaths,
+	"maxCheckoutBlobCount":       needTrees | needPaths,
+	"maxCheckoutBlobSize":        needTrees | needPaths,
+	"maxCheckoutIndexSize":       needTrees | needPaths,
+	"maxCheckoutExecutableCount": needTrees | needPaths,
+	"maxCheckoutLinkCount":       needTrees | needPaths,
+	"maxCheckoutSubmoduleCount":  needTrees | needPaths,
+
+	"potentialGitBombCount": needTrees | needPaths,
+
+	"maxCheckoutWindowsUnsafeCount": needTrees | needPaths,
+	"windowsUnsafeEntryCount":       needTrees | needPaths,
+
+	"normalizationCollisionTreeCount": needTrees | needPaths,
+	"unusualUnicodeEntryCount":        needTrees | needPaths,
+
+	"nestedRepositoryTreeCount": needTrees | needPaths,
+
+	"absoluteSymlinkCount":   needTrees | needSymlinks | needPaths,
+	"symlinkCycleTreeCount":  needTrees | needSymlinks | needPaths,
+	"symlinkTargetCount":     needTrees | needSymlinks,
+	"maxSymlinkTargetLength": needTrees | needSymlinks | needPaths,
+	"escapingSymlinkCount":   needTrees | needSymlinks | needPaths,
+
+	// This is estimated from the numbers of objects of each type:
+	"packObjectsMemory": needCommits | needTrees | needTags,
+
+	// These describe the memory used by a full scan, so they don't
+	// narrow it:
+	"graphBlobMemory":         needAll,
+	"graphTreeMemory":         needAll,
+	"graphPendingTreeMemory":  needAll,
+	"graphCommitMemory":       needAll,
+	"graphTagMemory":          needAll,
+	"graphPathResolverMemory": needAll,
+	"graphMemory":             needAll,
+
+	// This describes the `git` executable, not the repository:
+	"gitCapabilities": 0,
+}
+
+// explicitStats are the statistics that are only reported if they
+// are selected explicitly (e.g., via `--stats`), because they
+// describe the scan rather than the repository, or because they are
+// expensive to compute.
+var explicitStats = map[string]bool{
+	"maxMergeBubble":          true,
+	"averageMergeBubble":      true,
+	"graphBlobMemory":         true,
+	"graphTreeMemory":         true,
+	"graphPendingTreeMemory":  true,
+	"graphCommitMemory":       true,
+	"graphTagMemory":          true,
+	"graphPathResolverMemory": true,
+	"graphMemory":             true,
+	"gitCapabilities":         true,
+}
+
+// refGroupStatNeeds maps the last component of the symbol of a
+// reference-group statistic (e.g., "maxCheckoutBlobCount" for
+// "refgroup.branches.maxCheckoutBlobCount") to the data that have to
+// be collected to compute it, for those statistics that need more
+// than the references themselves.
+var refGroupStatNeeds = map[string]scanNeeds{
+	"maxCheckoutBlobCount": needTrees | needCommits | needTags,
+	"maxCheckoutBlobSize":  needTrees | needCommits | needTags,
+}
+
+// StatSet is a set of statistics, identified by their symbols (the
+// keys used in the JSON output). A nil `StatSet` selects all
+// statistics.
+type StatSet map[string]bool
+
+// ParseStatSet parses a comma-separated list of statistic symbols
+// into a `StatSet`. The empty string selects all statistics.
+func ParseStatSet(s string) (StatSet, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	ss := make(StatSet)
+	for _, symbol := range strings.Split(s, ",") {
+		symbol = strings.TrimSpace(symbol)
+		if symbol == "" {
+			continue
+		}
+		if _, ok := statNeeds[symbol]; !ok && !isDynamicStat(symbol) {
+			return nil, fmt.Errorf(
+				"unknown statistic '%s' (known statistics: %s)",
+				symbol, strings.Join(knownStats(), ", "),
+			)
+		}
+		ss[symbol] = true
+	}
+	return ss, nil
+}
+
+// isDynamicStat returns true iff `symbol` is the symbol of a
+// statistic that isn't listed in `statNeeds` because it depends on
+// the configuration: a reference-group statistic or a custom
+// statistic (see `CustomStat`).
+func isDynamicStat(symbol string) bool {
+	return strings.HasPrefix(symbol, "refgroup.") || strings.HasPrefix(symbol, "custom.")
+}
+
+// knownStats returns the symbols of the statistics listed in
+// `statNeeds`, sorted.
+func knownStats() []string {
+	symbols := make([]string, 0, len(statNeeds))
+	for symbol := range statNeeds {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	return symbols
+}
+
+// Contains returns true iff the statistic with the specified symbol
+// is selected by `ss`. A nil `StatSet` selects all statistics except
+// for `explicitStats`.
+func (ss StatSet) Contains(symbol string) bool {
+	if ss == nil {
+		return !explicitStats[symbol]
+	}
+	return ss[symbol]
+}
+
+// needs returns the data that have to be collected to compute all of
+// the statistics in `ss`.
+func (ss StatSet) needs() scanNeeds {
+	if ss == nil {
+		return needAll
+	}
+
+	var needs scanNeeds
+	for symbol := range ss {
+		if strings.HasPrefix(symbol, "refgroup.") {
+			needs |= refGroupStatNeeds[symbol[strings.LastIndexByte(symbol, '.')+1:]]
+			continue
+		}
+		if strings.HasPrefix(symbol, "custom.") {
+			// Custom statistics that match paths are computed
+			// while the trees are registered, and "maxSize" ones
+			// cite a blob.
+			needs |= needTrees | needPaths
+			continue
+		}
+		needs |= statNeeds[symbol]
+	}
+	return needs
+}
+
+// Profile selects a set of reference values for the statistics,
+// calibrated for a class of repositories. The level of concern of a
+// statistic is its value divided by its reference value, so a profile
+// can make git-sizer more or less tolerant of particular aspects of a
+// repository.
+type Profile string
+
+const (
+	// ProfileDefault uses the reference values that git-sizer has
+	// always used, which suit a typical project repository.
+	ProfileDefault Profile = "default"
+
+	// ProfileSmall is stricter across the board, for repositories
+	// that are expected to remain small (e.g., libraries or
+	// configuration repositories).
+	ProfileSmall Profile = "small"
+
+	// ProfileMonorepo tolerates much bigger histories and trees, but
+	// is stricter about large blobs, which hurt every developer in a
+	// monorepo.
+	ProfileMonorepo Profile = "monorepo"
+
+	// ProfileForge is for repositories hosted by a Git server (a
+	// "forge"), which accumulate many references (e.g., for pull
+	// requests), but which should be kept well packed.
+	ProfileForge Profile = "forge"
+)
+
+// profileScales maps each profile to the reference values that it
+// uses in place of the defaults, keyed by the symbols of the
+// statistics. Statistics that a profile doesn't list keep their
+// default reference values.
+var profileScales = map[Profile]map[string]float64{
+	ProfileDefault: {},
+
+	ProfileSmall: {
+		"uniqueCommitCount":    50e3,
+		"uniqueCommitSize":     25e6,
+		"uniqueTreeCount":      150e3,
+		"uniqueTreeSize":       200e6,
+		"uniqueTreeEntries":    5e6,
+		"uniqueBlobCount":      150e3,
+		"uniqueBlobSize":       1e9,
+		"uniqueTagCount":       2500,
+		"uniqueTagSize":        5e6,
+		"referenceCount":       2500,
+		"maxBlobSize":          1e6,
+		"maxTagOnlyBlobSize":   1e6,
+		"maxHistoryDepth":      50e3,
+		"maxCheckoutTreeCount": 500,
+		"maxCheckoutBlobCount": 5000,
+		"maxCheckoutBlobSize":  100e6,
+		"maxCheckoutIndexSize": 2.5e6,
+	},
+
+	ProfileMonorepo: {
+		"uniqueCommitCount":     5e6,
+		"uniqueCommitSize":      2.5e9,
+		"uniqueAuthorCount":     1e6,
+		"uniqueCommitterCount":  1e6,
+		"uniqueTreeCount":       50e6,
+		"uniqueTreeSize":        50e9,
+		"uniqueTreeEntries":     2e9,
+		"uniqueBlobCount":       50e6,
+		"uniqueBlobSize":        100e9,
+		"referenceCount":        250e3,
+		"maxTreeEntries":        5000,
+		"maxBlobSize":           1e6,
+		"maxExecutableBlobSize": 100e3,
+		"maxTagOnlyBlobSize":    1e6,
+		"maxHistoryDepth":       5e6,
+		"maxCheckoutTreeCount":  100e3,
+		"maxCheckoutPathDepth":  20,
+		"maxCheckoutPathLength": 200,
+		"maxCheckoutBlobCount":  2e6,
+		"maxCheckoutBlobSize":   10e9,
+		"maxCheckoutIndexSize":  500e6,
+	},
+
+	ProfileForge: {
+		"referenceCount":           1e6,
+		"uniqueTagCount":           100e3,
+		"uniqueTagSize":            200e6,
+		"looseObjectCount":         10e3,
+		"maxLooseObjectShardCount": 100,
+		"oldestLooseObjectAge":     14,
+	},
+}
+
+// Profiles returns the names of the known profiles, sorted.
+func Profiles() []string {
+	names := make([]string, 0, len(profileScales))
+	for p := range profileScales {
+		names = append(names, string(p))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// scale returns the reference value that `p` uses for the statistic
+// with the specified symbol, which is `defaultScale` unless `p`
+// overrides it.
+func (p Profile) scale(symbol string, defaultScale float64) float64 {
+	if scale, ok := profileScales[p][symbol]; ok {
+		return scale
+	}
+	return defaultScale
+}
+
+// Methods to implement FlagValue:
+
+func (p *Profile) String() string {
+	if *p == "" {
+		return string(ProfileDefault)
+	}
+	return string(*p)
+}
+
+func (p *Profile) Set(s string) error {
+	if _, ok := profileScales[Profile(s)]; !ok {
+		return fmt.Errorf(
+			"unknown profile '%s' (known profiles: %s)",
+			s, strings.Join(Profiles(), ", "),
+		)
+	}
+	*p = Profile(s)
+	return nil
+}
+
+func (p *Profile) Type() string {
+	return "profile"
+}
+
+// ReferenceValues overrides the reference values of individual
+// statistics, keyed by their symbols. An override takes precedence
+// over both the default reference value and the one chosen by the
+// profile.
+type ReferenceValues map[string]float64
+
+// Override sets the reference value of the statistic with the
+// specified symbol to `value`, which is parsed as a floating-point
+// number and must be positive.
+func (rv *ReferenceValues) Override(symbol, value string) error {
+	if err := checkStatSymbol(symbol); err != nil {
+		return err
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("error parsing reference value %q for '%s': %w", value, symbol, err)
+	}
+	if err := checkReferenceValue(symbol, v); err != nil {
+		return err
+	}
+	if *rv == nil {
+		*rv = make(ReferenceValues)
+	}
+	(*rv)[symbol] = v
+	return nil
+}
+
+// checkStatSymbol returns an error if `symbol` isn't the symbol of a
+// known statistic.
+func checkStatSymbol(symbol string) error {
+	if _, ok := statNeeds[symbol]; !ok && !isDynamicStat(symbol) {
+		return fmt.Errorf(
+			"unknown statistic '%s' (known statistics: %s)",
+			symbol, strings.Join(knownStats(), ", "),
+		)
+	}
+	return nil
+}
+
+// checkReferenceValue returns an error if `v` isn't a valid reference
+// value for the statistic with the specified symbol.
+func checkReferenceValue(symbol string, v float64) error {
+	if err := checkStatSymbol(symbol); err != nil {
+		return err
+	}
+	if !(v > 0) || math.IsInf(v, 1) {
+		return fmt.Errorf("reference value for '%s' must be a positive number", symbol)
+	}
+	return nil
+}
+
+// scale returns the reference value for the statistic with the
+// specified symbol, which is its override in `rv`, if any; otherwise,
+// the one that `p` uses; otherwise, `defaultScale`.
+func (rv ReferenceValues) scale(p Profile, symbol string, defaultScale float64) float64 {
+	if scale, ok := rv[symbol]; ok {
+		return scale
+	}
+	return p.scale(symbol, defaultScale)
+}
+
+// Methods to implement FlagValue:
+
+func (rv *ReferenceValues) String() string {
+	symbols := make([]string, 0, len(*rv))
+	for symbol := range *rv {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	overrides := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		overrides[i] = fmt.Sprintf("%s=%g", symbol, (*rv)[symbol])
+	}
+	return strings.Join(overrides, ",")
+}
+
+func (rv *ReferenceValues) Set(s string) error {
+	eq := strings.IndexByte(s, '=')
+	if eq == -1 {
+		return fmt.Errorf("reference value %q is not of the form SYMBOL=VALUE", s)
+	}
+	return rv.Override(strings.TrimSpace(s[:eq]), strings.TrimSpace(s[eq+1:]))
+}
+
+func (rv *ReferenceValues) Type() string {
+	return "symbol=value"
+}
@@ -0,0 +1,129 @@
+package sizes
+
+import (
+	"context"
+	"sync"
+
+	"github.com/github/git-sizer/git"
+)
+
+// symlinkReader reads the targets of symlinks while the trees are
+// being processed, so that what they reveal about a tree (e.g., that
+// its symlinks form a cycle) is known before the tree is finalized.
+// The blobs are read by a `git cat-file` process of its own, which is
+// only started when the first symlink is requested. Its methods must
+// all be called from the same goroutine.
+type symlinkReader struct {
+	ctx  context.Context
+	repo *git.Repository
+
+	objectIter *git.BatchObjectIter
+
+	// outstanding is the number of blobs that have been requested but
+	// not yet returned by `take()`.
+	outstanding int
+
+	// lock protects `read` and `err`, which are filled in by the
+	// goroutine that reads the blobs.
+	lock sync.Mutex
+	read []git.ObjectRecord
+	err  error
+
+	// ready receives a value whenever `read` or `err` has been
+	// changed.
+	ready chan struct{}
+
+	// done is closed when the goroutine that reads the blobs exits.
+	done chan struct{}
+}
+
+func newSymlinkReader(ctx context.Context, repo *git.Repository) *symlinkReader {
+	return &symlinkReader{
+		ctx:   ctx,
+		repo:  repo,
+		ready: make(chan struct{}, 1),
+	}
+}
+
+// request asks for the blob `oid` to be read.
+func (sr *symlinkReader) request(oid git.OID) error {
+	if sr.objectIter == nil {
+		objectIter, err := sr.repo.NewBatchObjectIter(sr.ctx)
+		if err != nil {
+			return err
+		}
+		sr.objectIter = objectIter
+		sr.done = make(chan struct{})
+		go sr.run(objectIter)
+	}
+
+	sr.outstanding++
+	return sr.objectIter.RequestObject(oid)
+}
+
+// run reads the requested blobs from `objectIter` until it is closed
+// or fails.
+func (sr *symlinkReader) run(objectIter *git.BatchObjectIter) {
+	defer close(sr.done)
+
+	for {
+		obj, ok, err := objectIter.Next()
+		sr.lock.Lock()
+		if ok {
+			sr.read = append(sr.read, obj)
+		} else {
+			sr.err = err
+		}
+		sr.lock.Unlock()
+
+		select {
+		case sr.ready <- struct{}{}:
+		default:
+		}
+
+		if !ok {
+			return
+		}
+	}
+}
+
+// take returns the blobs that have been read since the last call. If
+// `wait` is true and some blobs are still outstanding, it waits until
+// at least one of them has been read.
+func (sr *symlinkReader) take(wait bool) ([]git.ObjectRecord, error) {
+	for {
+		sr.lock.Lock()
+		objs, err := sr.read, sr.err
+		sr.read = nil
+		sr.lock.Unlock()
+
+		sr.outstanding -= len(objs)
+		switch {
+		case len(objs) != 0:
+			return objs, nil
+		case err != nil:
+			return nil, err
+		case !wait || sr.outstanding == 0:
+			return nil, nil
+		}
+
+		select {
+		case <-sr.ready:
+		case <-sr.ctx.Done():
+			return nil, sr.ctx.Err()
+		}
+	}
+}
+
+// close stops the `git cat-file` process, if one was started, and
+// reports any error that it encountered. It may be called more than
+// once.
+func (sr *symlinkReader) close() error {
+	if sr.objectIter == nil {
+		return nil
+	}
+	sr.objectIter.Close()
+	sr.objectIter = nil
+	<-sr.done
+	return sr.err
+}
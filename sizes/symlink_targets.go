@@ -0,0 +1,127 @@
+package sizes
+
+import (
+	"context"
+	"strings"
+
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// symlinkClimb returns the number of directories that the relative
+// symlink `target` climbs above the directory containing the symlink
+// via ".." components (e.g., 0 for "a/../b", 1 for "../a", and 2 for
+// "a/../../../b"). It returns 0 for absolute targets.
+func symlinkClimb(target string) int {
+	if strings.HasPrefix(target, "/") {
+		return 0
+	}
+
+	climb, depth := 0, 0
+	for _, component := range strings.Split(target, "/") {
+		switch component {
+		case "", ".":
+		case "..":
+			if depth > 0 {
+				depth--
+			} else {
+				climb++
+			}
+		default:
+			depth++
+		}
+	}
+	return climb
+}
+
+// findEscapingSymlinks records the symlinks, whose targets are given
+// by `targets`, that climb above the root of the checkout. Since the
+// same tree can appear at many paths, the symlinks in each tree are
+// judged at the path at which `git rev-list --objects` first
+// encounters the tree when walking the `roots`; e.g., "../x" escapes
+// in a top-level tree, but not in a subdirectory. Nothing has to be
+// walked unless some target starts by climbing.
+func (g *Graph) findEscapingSymlinks(
+	ctx context.Context, repo *git.Repository, roots []Root,
+	targets map[git.OID]string, progressMeter meter.Progress,
+) error {
+	climbs := make(map[git.OID]int)
+	for oid, target := range targets {
+		if climb := symlinkClimb(target); climb > 0 {
+			climbs[oid] = climb
+		}
+	}
+	if len(climbs) == 0 {
+		return nil
+	}
+
+	// The trees containing symlinks that climb, and the largest
+	// number of directories that one of their symlinks climbs:
+	treeClimbs := make(map[git.OID]int)
+	for _, tree := range g.symlinkTrees {
+		for _, entry := range tree.entries {
+			if climbs[entry.oid] > treeClimbs[tree.oid] {
+				treeClimbs[tree.oid] = climbs[entry.oid]
+			}
+		}
+	}
+
+	var tips []git.OID
+	for _, root := range roots {
+		if root.Walk() {
+			tips = append(tips, root.OID())
+		}
+	}
+
+	// The depth of each of those trees (0 for a top-level tree), if
+	// some of its symlinks escape:
+	depths := make(map[git.OID]int)
+	progressMeter.Start("Finding escaping symlinks: %d")
+	err := repo.ObjectPaths(ctx, tips, func(oid git.OID, path string) {
+		progressMeter.Inc()
+		climb, ok := treeClimbs[oid]
+		if !ok {
+			return
+		}
+		depth := 0
+		if path != "" {
+			depth = strings.Count(path, "/") + 1
+		}
+		if climb > depth {
+			depths[oid] = depth
+		}
+	})
+	progressMeter.Done()
+	if err != nil {
+		return err
+	}
+
+	escaping := make(map[git.OID]bool)
+	for _, tree := range g.symlinkTrees {
+		depth, ok := depths[tree.oid]
+		if !ok {
+			continue
+		}
+		for _, entry := range tree.entries {
+			if climbs[entry.oid] > depth {
+				escaping[entry.oid] = true
+			}
+		}
+	}
+
+	g.historyLock.Lock()
+	defer g.historyLock.Unlock()
+
+	s := &g.historySize
+	for _, blob := range g.symlinkBlobs {
+		if !escaping[blob.oid] {
+			continue
+		}
+		s.EscapingSymlinkCount.Increment(1)
+		g.recordAnomalyExample("escapingSymlinkCount", blob.oid, "blob")
+		if s.EscapingSymlink == nil {
+			s.EscapingSymlink = blob.path
+		}
+	}
+	return nil
+}
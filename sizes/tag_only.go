@@ -0,0 +1,93 @@
+package sizes
+
+import (
+	"context"
+	"strings"
+
+	"github.com/github/git-sizer/git"
+	"github.com/github/git-sizer/meter"
+)
+
+// findTagOnlyObjects fills in `g.tagOnlyObjects` with the blobs and
+// trees that are reachable from the walked tags but not from any of
+// the walked branches. Such objects, like release artifacts that were
+// committed only on a tag, are easy to overlook. If no tags are
+// walked, it leaves `g.tagOnlyObjects` nil.
+func (g *Graph) findTagOnlyObjects(
+	ctx context.Context, repo *git.Repository, roots []Root, progressMeter meter.Progress,
+) error {
+	var tags, branches []git.OID
+	for _, root := range roots {
+		refRoot, ok := root.(ReferenceRoot)
+		if !ok || !root.Walk() {
+			continue
+		}
+		refname := refRoot.Reference().Refname
+		switch {
+		case strings.HasPrefix(refname, "refs/tags/"):
+			tags = append(tags, root.OID())
+		case strings.HasPrefix(refname, "refs/heads/"):
+			branches = append(branches, root.OID())
+		}
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	objIter, err := repo.NewObjectIter(ctx)
+	if err != nil {
+		return err
+	}
+
+	errChan := make(chan error, 1)
+	go func() {
+		defer objIter.Close()
+
+		errChan <- func() error {
+			for _, oid := range tags {
+				if err := objIter.AddRoot(oid); err != nil {
+					return err
+				}
+			}
+			for _, oid := range branches {
+				if err := objIter.ExcludeRoot(oid); err != nil {
+					return err
+				}
+			}
+			return nil
+		}()
+	}()
+
+	tagOnlyObjects := make(map[git.OID]struct{})
+
+	progressMeter.Start("Finding objects reachable only from tags: %d")
+	for {
+		obj, ok, err := objIter.Next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+		progressMeter.Inc()
+		switch obj.ObjectType {
+		case "blob", "tree":
+			tagOnlyObjects[obj.OID] = struct{}{}
+		}
+	}
+	progressMeter.Done()
+
+	if err := <-errChan; err != nil {
+		return err
+	}
+
+	g.tagOnlyObjects = tagOnlyObjects
+	return nil
+}
+
+// isTagOnly returns true iff `oid` is a blob or tree that is
+// reachable from tags but not from any branch.
+func (g *Graph) isTagOnly(oid git.OID) bool {
+	_, ok := g.tagOnlyObjects[oid]
+	return ok
+}
@@ -0,0 +1,97 @@
+package sizes
+
+import (
+	"errors"
+)
+
+// Thresholds is a policy for judging repositories, typically read
+// from a thresholds file by the caller, so that the same policy can
+// be reviewed once and then applied identically by command-line runs
+// and by server hooks (see `Thresholds.PushOptions()`).
+type Thresholds struct {
+	// Name, if set, identifies the policy in reports.
+	Name string
+
+	// Version is the version of the format of the file that the
+	// policy was read from.
+	Version int
+
+	// Profile, if set, is the profile whose reference values are
+	// used for the statistics that aren't in `ReferenceValues`.
+	Profile Profile
+
+	// Threshold, if non-nil, is the minimum level of concern that is
+	// reported, and RejectThreshold, if non-nil, the level of
+	// concern at or above which a push is rejected.
+	Threshold       *Threshold
+	RejectThreshold *Threshold
+
+	// ReferenceValues holds the reference values of individual
+	// statistics, keyed by their symbols.
+	ReferenceValues ReferenceValues
+
+	// Path is where the thresholds were read from, and SHA256 is the
+	// hex-encoded SHA-256 of the file's contents, by which reports
+	// identify the exact policy that was applied.
+	Path   string
+	SHA256 string
+}
+
+// Validate returns an error if `t` isn't a valid policy: if one of
+// its thresholds is out of range, or one of its reference values is
+// invalid or is for an unknown statistic.
+func (t *Thresholds) Validate() error {
+	if t.Threshold != nil && !(*t.Threshold >= 0) {
+		return errors.New("'threshold' must not be negative")
+	}
+	if t.RejectThreshold != nil && !(*t.RejectThreshold > 0) {
+		return errors.New("'reject_threshold' must be positive")
+	}
+	for symbol, v := range t.ReferenceValues {
+		if err := checkReferenceValue(symbol, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReferenceValuesWith returns the reference values of `t`, with those
+// in `overrides` taking precedence.
+func (t *Thresholds) ReferenceValuesWith(overrides ReferenceValues) ReferenceValues {
+	if len(t.ReferenceValues) == 0 && len(overrides) == 0 {
+		return nil
+	}
+	rv := make(ReferenceValues, len(t.ReferenceValues)+len(overrides))
+	for symbol, v := range t.ReferenceValues {
+		rv[symbol] = v
+	}
+	for symbol, v := range overrides {
+		rv[symbol] = v
+	}
+	return rv
+}
+
+// PushOptions returns the options for `ScanPush()` that apply the
+// policy `t` on top of `scanOpts`.
+func (t *Thresholds) PushOptions(scanOpts ScanOptions) PushOptions {
+	if t.Profile != "" {
+		scanOpts.Profile = t.Profile
+	}
+	scanOpts.ReferenceValues = t.ReferenceValuesWith(nil)
+	scanOpts.Thresholds = t
+
+	opts := PushOptions{ScanOptions: scanOpts}
+	if t.RejectThreshold != nil {
+		opts.RejectThreshold = *t.RejectThreshold
+	}
+	return opts
+}
+
+// ThresholdsInfo identifies the thresholds file that was applied to a
+// report.
+type ThresholdsInfo struct {
+	Name    string `json:"name,omitempty"`
+	Version int    `json:"version"`
+	Path    string `json:"path,omitempty"`
+	SHA256  string `json:"sha256"`
+}
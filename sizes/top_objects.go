@@ -0,0 +1,168 @@
+package sizes
+
+import (
+	"bytes"
+	"container/heap"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// TopObject is one of the objects with the biggest values of a
+// statistic that cites an object (e.g., "maxBlobSize"). See
+// `ScanOptions.TopObjects`.
+type TopObject struct {
+	Value  uint64 `json:"value"`
+	Object *Path  `json:"object,omitempty"`
+}
+
+// topObject is a candidate for `HistorySize.TopObjects`.
+type topObject struct {
+	oid   git.OID
+	value uint64
+	path  *Path
+}
+
+// topObjectHeap is a min-heap of `topObject`s, ordered by value, so
+// that the smallest can be evicted when a bigger one is found.
+type topObjectHeap []topObject
+
+func (h topObjectHeap) Len() int            { return len(h) }
+func (h topObjectHeap) Less(i, j int) bool  { return h[i].value < h[j].value }
+func (h topObjectHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *topObjectHeap) Push(x interface{}) { *h = append(*h, x.(topObject)) }
+
+func (h *topObjectHeap) Pop() interface{} {
+	old := *h
+	o := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return o
+}
+
+// topObjectCollector keeps track of the objects with the biggest
+// values of one statistic.
+type topObjectCollector struct {
+	heap topObjectHeap
+
+	// oids is the set of objects in `heap`, so that an object that
+	// is recorded more than once (e.g., an executable blob that
+	// appears in several trees) is only listed once.
+	oids map[git.OID]struct{}
+}
+
+// recordTopObject considers the object `oid`, of the specified type,
+// as one of the `g.topObjects` objects with the biggest values of
+// the statistic `symbol`. The caller must hold `g.historyLock`.
+func (g *Graph) recordTopObject(symbol string, value uint64, oid git.OID, objectType string) {
+	g.addTopObject(symbol, value, oid, func() *Path {
+		return g.pathResolver.RequestPath(oid, objectType)
+	})
+}
+
+// recordTopEntry is like `recordTopObject()`, but for the object
+// `childOID`, which is known as the entry `name` of the tree `oid`.
+func (g *Graph) recordTopEntry(
+	symbol string, value uint64, oid git.OID, name string, childOID git.OID, objectType string,
+) {
+	g.addTopObject(symbol, value, childOID, func() *Path {
+		return g.pathResolver.RequestEntryPath(oid, name, childOID, objectType)
+	})
+}
+
+func (g *Graph) addTopObject(symbol string, value uint64, oid git.OID, requestPath func() *Path) {
+	if g.topObjects <= 1 || value == 0 || !g.historySize.stats.Contains(symbol) {
+		return
+	}
+
+	c, ok := g.topObjectCollectors[symbol]
+	if !ok {
+		c = &topObjectCollector{oids: make(map[git.OID]struct{})}
+		g.topObjectCollectors[symbol] = c
+	}
+	if _, ok := c.oids[oid]; ok {
+		return
+	}
+
+	if len(c.heap) == g.topObjects {
+		if value <= c.heap[0].value {
+			return
+		}
+		evicted := heap.Pop(&c.heap).(topObject)
+		delete(c.oids, evicted.oid)
+		if evicted.path != nil {
+			g.pathResolver.ForgetPath(evicted.path)
+		}
+	}
+
+	heap.Push(&c.heap, topObject{oid: oid, value: value, path: requestPath()})
+	c.oids[oid] = struct{}{}
+}
+
+// findTopObjects fills in `s.TopObjects` from the objects recorded by
+// `recordTopObject()` and `recordTopEntry()`, biggest first.
+func (s *HistorySize) findTopObjects(g *Graph) {
+	g.historyLock.Lock()
+	defer g.historyLock.Unlock()
+
+	s.TopObjects = make(map[string][]TopObject, len(g.topObjectCollectors))
+	for symbol, c := range g.topObjectCollectors {
+		objects := append([]topObject(nil), c.heap...)
+		sort.Slice(objects, func(i, j int) bool {
+			if objects[i].value != objects[j].value {
+				return objects[i].value > objects[j].value
+			}
+			return bytes.Compare(objects[i].oid.Bytes(), objects[j].oid.Bytes()) < 0
+		})
+
+		top := make([]TopObject, len(objects))
+		for i, o := range objects {
+			top[i] = TopObject{Value: o.value, Object: o.path}
+		}
+		s.TopObjects[symbol] = top
+	}
+}
+
+// TopObjectsTableString returns lists of the objects with the biggest
+// values of each statistic that is shown in the table output and
+// cites an object, or the empty string if they weren't collected
+// (see `ScanOptions.TopObjects`).
+func (s *HistorySize) TopObjectsTableString(
+	refGroups []RefGroup, threshold Threshold, nameStyle NameStyle,
+) string {
+	if s.TopObjects == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	s.contents(refGroups).collectDefinitions(nil, func(path []string, i *item) {
+		top := i.top
+		if len(top) == 0 || !s.stats.Contains(i.symbol) {
+			return
+		}
+		if _, interesting := i.levelOfConcern(threshold); !interesting {
+			return
+		}
+
+		fmt.Fprintf(buf, "\n%s:\n\n", strings.Join(append(path, i.name), " > "))
+		for n, o := range top {
+			value, unit := i.humaner.Format(counts.NewCount64(o.Value), i.unit)
+			name := ""
+			if o.Object != nil {
+				switch nameStyle {
+				case NameStyleHash:
+					name = o.Object.OID.String()
+				case NameStyleFull:
+					name = o.Object.String() + objectNoteSuffix(notesFor(s.ObjectNotes, o.Object))
+				}
+			}
+			fmt.Fprintf(buf, "%4d. %6s %-3s %s\n", n+1, value, unit, name)
+		}
+	})
+	if buf.Len() == 0 {
+		return ""
+	}
+	return "\nBiggest objects for each statistic:\n" + buf.String()
+}
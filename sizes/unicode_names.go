@@ -0,0 +1,112 @@
+package sizes
+
+import (
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// isASCII returns true iff `name` consists only of ASCII characters,
+// in which case it can't have any Unicode normalization problems.
+func isASCII(name string) bool {
+	for i := 0; i < len(name); i++ {
+		if name[i] >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// nameScripts are the scripts that are considered when checking
+// whether a name mixes scripts. The scripts that are commonly used
+// together to write East Asian languages are treated as one.
+var nameScripts = []struct {
+	name   string
+	tables []*unicode.RangeTable
+}{
+	{"Latin", []*unicode.RangeTable{unicode.Latin}},
+	{"Greek", []*unicode.RangeTable{unicode.Greek}},
+	{"Cyrillic", []*unicode.RangeTable{unicode.Cyrillic}},
+	{"Armenian", []*unicode.RangeTable{unicode.Armenian}},
+	{"Hebrew", []*unicode.RangeTable{unicode.Hebrew}},
+	{"Arabic", []*unicode.RangeTable{unicode.Arabic}},
+	{"Devanagari", []*unicode.RangeTable{unicode.Devanagari}},
+	{"Thai", []*unicode.RangeTable{unicode.Thai}},
+	{"Georgian", []*unicode.RangeTable{unicode.Georgian}},
+	{
+		"CJK",
+		[]*unicode.RangeTable{
+			unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul, unicode.Bopomofo,
+		},
+	},
+}
+
+// isMixedScriptName returns true iff `name` mixes letters from
+// scripts that aren't normally used together. Following the
+// "moderately restrictive" profile of Unicode Technical Standard #39,
+// Latin letters may be combined with one other script (e.g., for a
+// file extension), except for Greek and Cyrillic, whose letters are
+// easily confused with Latin ones.
+func isMixedScriptName(name string) bool {
+	latin := false
+	other := ""
+	for _, r := range name {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		for _, s := range nameScripts {
+			if !unicode.In(r, s.tables...) {
+				continue
+			}
+			switch {
+			case s.name == "Latin":
+				latin = true
+			case other == "":
+				other = s.name
+			case other != s.name:
+				return true
+			}
+			break
+		}
+	}
+	return latin && (other == "Greek" || other == "Cyrillic")
+}
+
+// isUnusualUnicodeName returns true iff `name` is not in Unicode
+// normalization form C (as is typical of names created on macOS) or
+// mixes letters from several scripts.
+func isUnusualUnicodeName(name string) bool {
+	if isASCII(name) {
+		return false
+	}
+	return !norm.NFC.IsNormalString(name) || isMixedScriptName(name)
+}
+
+// normalizationCollisions keeps track of the names of the entries in
+// a tree, to detect entries whose names are different but equivalent
+// under Unicode normalization. Such entries collide when checked out
+// on filesystems that normalize file names, like those of macOS. The
+// zero value is ready to use.
+type normalizationCollisions struct {
+	names map[string]string
+}
+
+// add records the entry called `name`, and returns true iff it
+// collides with a previous entry.
+func (c *normalizationCollisions) add(name string) bool {
+	if isASCII(name) {
+		// ASCII names are already normalized, and can't collide
+		// with anything but themselves.
+		return false
+	}
+	key := norm.NFC.String(name)
+	if c.names == nil {
+		c.names = make(map[string]string)
+	}
+	if other, ok := c.names[key]; ok {
+		return other != name
+	}
+	c.names[key] = name
+	return false
+}
@@ -0,0 +1,217 @@
+package sizes
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// defaultPruneExpire is Git's default for the `gc.pruneExpire`
+// setting.
+const defaultPruneExpire = "2.weeks.ago"
+
+// DefaultPruneExpireSettings are the settings of `gc.pruneExpire`
+// whose effects are simulated if no others are requested. The
+// repository's current setting is always simulated, too.
+var DefaultPruneExpireSettings = []string{
+	"now", "1.day.ago", "2.weeks.ago", "1.month.ago", "3.months.ago", "1.year.ago",
+}
+
+// unreachableAgeBuckets are the ranges of age into which the
+// unreachable objects are bucketed. Each bucket holds the objects
+// that are younger than its `maxAge` but not younger than the
+// previous bucket's; the last one has no upper limit.
+var unreachableAgeBuckets = []struct {
+	label  string
+	maxAge time.Duration
+}{
+	{"Under 1 day", 24 * time.Hour},
+	{"1 day to 2 weeks", 14 * 24 * time.Hour},
+	{"2 weeks to 1 month", 30 * 24 * time.Hour},
+	{"1 to 3 months", 90 * 24 * time.Hour},
+	{"3 months to 1 year", 365 * 24 * time.Hour},
+	{"Over 1 year", 0},
+}
+
+// UnreachableObjects describes the objects in the object database
+// that aren't reachable from any reference, reflog, or the index,
+// bucketed by age, and how much of them `git gc` would prune under
+// various settings of `gc.pruneExpire`. The age of an object is that
+// of its mtime, which is what `git gc` goes by: the mtime of a loose
+// object's file, the mtime that a cruft pack recorded for it, or
+// otherwise the mtime of its packfile.
+type UnreachableObjects struct {
+	ObjectCount counts.Count64 `json:"object_count"`
+	DiskSize    counts.Count64 `json:"disk_size"`
+
+	// AgeBuckets holds the unreachable objects of each range of age,
+	// youngest first.
+	AgeBuckets []UnreachableAgeBucket `json:"age_buckets"`
+
+	// PruneExpire is the repository's current setting of
+	// `gc.pruneExpire`.
+	PruneExpire string `json:"prune_expire"`
+
+	// PruneSimulations holds the effect of each of the simulated
+	// settings of `gc.pruneExpire`, in the order that they were
+	// requested.
+	PruneSimulations []PruneSimulation `json:"prune_simulations"`
+}
+
+// UnreachableAgeBucket holds the unreachable objects of one range of
+// age.
+type UnreachableAgeBucket struct {
+	Label       string         `json:"label"`
+	ObjectCount counts.Count64 `json:"object_count"`
+	DiskSize    counts.Count64 `json:"disk_size"`
+}
+
+// PruneSimulation is the number of unreachable objects that `git gc`
+// would prune with one setting of `gc.pruneExpire`, and the bytes
+// that it would reclaim. Git also keeps the unreachable objects that
+// are referred to by recent ones, so this is an upper bound.
+type PruneSimulation struct {
+	Setting     string         `json:"setting"`
+	Current     bool           `json:"current,omitempty"`
+	ObjectCount counts.Count64 `json:"object_count"`
+	DiskSize    counts.Count64 `json:"disk_size"`
+}
+
+// measureUnreachable finds the unreachable objects in `repo`, buckets
+// them by age, and simulates the effect of each of `settings` (and of
+// the current setting) of `gc.pruneExpire`, storing the results in
+// `s.UnreachableObjects`.
+func (s *HistorySize) measureUnreachable(
+	ctx context.Context, repo *git.Repository, settings []string,
+) error {
+	pruneExpire, err := repo.ConfigStringDefaultContext(ctx, "gc.pruneExpire", defaultPruneExpire)
+	if err != nil {
+		return err
+	}
+
+	if len(settings) == 0 {
+		settings = DefaultPruneExpireSettings
+	}
+	current := false
+	for _, setting := range settings {
+		if setting == pruneExpire {
+			current = true
+		}
+	}
+	if !current {
+		settings = append([]string{pruneExpire}, settings...)
+	}
+
+	u := UnreachableObjects{
+		AgeBuckets:       make([]UnreachableAgeBucket, len(unreachableAgeBuckets)),
+		PruneExpire:      pruneExpire,
+		PruneSimulations: make([]PruneSimulation, len(settings)),
+	}
+	for i, b := range unreachableAgeBuckets {
+		u.AgeBuckets[i].Label = b.label
+	}
+	cutoffs := make([]uint64, len(settings))
+	for i, setting := range settings {
+		cutoffs[i], err = repo.ExpiryCutoff(ctx, setting)
+		if err != nil {
+			return fmt.Errorf("simulating 'gc.pruneExpire': %w", err)
+		}
+		u.PruneSimulations[i] = PruneSimulation{
+			Setting: setting,
+			Current: setting == pruneExpire,
+		}
+	}
+
+	objects, err := repo.UnreachableObjects(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, o := range objects {
+		size := counts.NewCount64(o.Size)
+		u.ObjectCount.Increment(1)
+		u.DiskSize.Increment(size)
+
+		age := s.ScanTime.Sub(o.ModTime)
+		for i, b := range unreachableAgeBuckets {
+			if b.maxAge == 0 || age < b.maxAge {
+				u.AgeBuckets[i].ObjectCount.Increment(1)
+				u.AgeBuckets[i].DiskSize.Increment(size)
+				break
+			}
+		}
+
+		// Git prunes the objects whose mtimes are no later than the
+		// cutoff:
+		var mtime uint64
+		if t := o.ModTime.Unix(); t > 0 {
+			mtime = uint64(t)
+		}
+		for i, cutoff := range cutoffs {
+			if mtime <= cutoff {
+				u.PruneSimulations[i].ObjectCount.Increment(1)
+				u.PruneSimulations[i].DiskSize.Increment(size)
+			}
+		}
+	}
+
+	s.UnreachableObjects = &u
+	return nil
+}
+
+// UnreachableObjectsString describes the unreachable objects and how
+// much space each of the simulated settings of `gc.pruneExpire` would
+// reclaim, or returns the empty string if they weren't measured.
+func (s *HistorySize) UnreachableObjectsString() string {
+	u := s.UnreachableObjects
+	if u == nil {
+		return ""
+	}
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "\nUnreachable objects, by age:\n\n")
+	fmt.Fprintln(buf, "| Age                  | Objects   | Size on disk")
+	fmt.Fprintln(buf, "| -------------------- | --------- | ------------")
+	for _, b := range u.AgeBuckets {
+		fmt.Fprintf(
+			buf, "| %-20s | %9d | %s\n",
+			b.Label, b.ObjectCount, formatSharedBytes(b.DiskSize),
+		)
+	}
+	fmt.Fprintf(
+		buf, "| %-20s | %9d | %s\n",
+		"Total", u.ObjectCount, formatSharedBytes(u.DiskSize),
+	)
+
+	fmt.Fprintf(buf, "\nObjects that 'git gc' would prune, by setting of 'gc.pruneExpire':\n\n")
+	fmt.Fprintln(buf, "| gc.pruneExpire                 | Objects   | Size on disk")
+	fmt.Fprintln(buf, "| ------------------------------ | --------- | ------------")
+	for _, p := range u.PruneSimulations {
+		setting := p.Setting
+		if p.Current {
+			setting += " (current)"
+		}
+		fmt.Fprintf(
+			buf, "| %-30s | %9d | %s\n",
+			setting, p.ObjectCount, formatSharedBytes(p.DiskSize),
+		)
+	}
+
+	if u.ObjectCount != 0 {
+		fmt.Fprintf(
+			buf,
+			"\nUnreachable objects that are referred to by more recent ones are kept, too,\n"+
+				"so these are upper bounds. To prune the unreachable objects that are older\n"+
+				"than SETTING once, run\n\n"+
+				"     git gc --prune=SETTING\n\n"+
+				"To have 'git gc' prune them routinely, set 'gc.pruneExpire' to SETTING\n"+
+				"(currently '%s').\n",
+			u.PruneExpire,
+		)
+	}
+	return buf.String()
+}
@@ -0,0 +1,227 @@
+package sizes
+
+import (
+	"container/heap"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// wideTreeLimit is the number of the widest trees with machine-generated
+// names that are kept in `Graph.wideTrees`.
+const wideTreeLimit = 10
+
+// The following patterns match the parts of file names that are
+// typical of machine-generated names. They are replaced by
+// placeholders to find the pattern that the names of a tree's entries
+// have in common.
+var (
+	uuidNamePattern = regexp.MustCompile(
+		`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`,
+	)
+
+	// hexNamePattern matches hashes, but only counts if the match
+	// contains both letters and digits.
+	hexNamePattern = regexp.MustCompile(`[0-9a-fA-F]{8,}`)
+
+	// numberNamePattern matches sequence numbers and timestamps
+	// (e.g., "0001" or "2023-01-31T12-00-00").
+	numberNamePattern = regexp.MustCompile(`[0-9]{2,}(?:[-_.:T]?[0-9]{2,})*`)
+)
+
+// WideTree describes a tree that has more entries than the reference
+// value of "maxTreeEntries", most of whose names follow a single
+// machine-generated pattern. Such a flat directory (e.g., of uploaded
+// assets) slows down every operation that reads the tree, and every
+// change to one of the files rewrites the whole tree.
+type WideTree struct {
+	Tree       *Path          `json:"tree"`
+	EntryCount counts.Count32 `json:"entry_count"`
+
+	// Pattern is the pattern that most of the entries' names follow,
+	// with `{uuid}`, `{hex}`, and `{n}` standing for UUIDs, hashes,
+	// and numbers (e.g., sequence numbers or timestamps). It is
+	// omitted if the output is anonymized. PatternCount is the number
+	// of the entries whose names follow it.
+	Pattern      string         `json:"pattern,omitempty"`
+	PatternCount counts.Count32 `json:"pattern_count"`
+}
+
+// wideTree is a candidate for `HistorySize.WideTrees`.
+type wideTree struct {
+	oid          git.OID
+	entryCount   counts.Count32
+	pattern      string
+	patternCount counts.Count32
+	path         *Path
+}
+
+// wideTreeHeap is a min-heap of `wideTree`s, ordered by entry count,
+// so that the narrowest can be evicted when a wider one is found.
+type wideTreeHeap []wideTree
+
+func (h wideTreeHeap) Len() int            { return len(h) }
+func (h wideTreeHeap) Less(i, j int) bool  { return h[i].entryCount < h[j].entryCount }
+func (h wideTreeHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *wideTreeHeap) Push(x interface{}) { *h = append(*h, x.(wideTree)) }
+
+func (h *wideTreeHeap) Pop() interface{} {
+	old := *h
+	t := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return t
+}
+
+// namePattern returns `name` with the parts that look
+// machine-generated replaced by placeholders.
+func namePattern(name string) string {
+	pattern := uuidNamePattern.ReplaceAllLiteralString(name, "{uuid}")
+	pattern = hexNamePattern.ReplaceAllStringFunc(pattern, func(s string) string {
+		if strings.IndexAny(s, "0123456789") == -1 ||
+			strings.IndexAny(s, "abcdefABCDEF") == -1 {
+			return s
+		}
+		return "{hex}"
+	})
+	return numberNamePattern.ReplaceAllLiteralString(pattern, "{n}")
+}
+
+// dominantNamePattern returns the machine-generated pattern that the
+// names of the majority of `tree`'s entries follow, and the number of
+// entries that follow it. It returns false if there is no such
+// pattern.
+func dominantNamePattern(tree *git.Tree, entryCount counts.Count32) (string, counts.Count32, bool, error) {
+	patterns := make(map[string]counts.Count32)
+	iter := tree.Iter()
+	for {
+		entry, ok, err := iter.NextEntry()
+		if err != nil {
+			return "", 0, false, err
+		}
+		if !ok {
+			break
+		}
+		if pattern := namePattern(entry.Name); pattern != entry.Name {
+			patterns[pattern]++
+		}
+	}
+
+	var best string
+	var bestCount counts.Count32
+	for pattern, n := range patterns {
+		if n > bestCount || (n == bestCount && pattern < best) {
+			best, bestCount = pattern, n
+		}
+	}
+	if 2*uint64(bestCount) < uint64(entryCount) {
+		return "", 0, false, nil
+	}
+	return best, bestCount, true, nil
+}
+
+// recordWideTree considers the tree `oid`, which has `entryCount`
+// entries, for `HistorySize.WideTrees`. The names of its entries are
+// only examined if it is wider than `g.wideTreeEntries` and would be
+// among the `wideTreeLimit` widest trees seen so far.
+func (g *Graph) recordWideTree(oid git.OID, tree *git.Tree, entryCount counts.Count32) error {
+	if g.wideTreeEntries == 0 || entryCount <= g.wideTreeEntries || !g.countsTowardMaxima(oid) {
+		return nil
+	}
+
+	g.historyLock.Lock()
+	admitted := len(g.wideTrees) < wideTreeLimit || entryCount > g.wideTrees[0].entryCount
+	g.historyLock.Unlock()
+	if !admitted {
+		return nil
+	}
+
+	pattern, patternCount, ok, err := dominantNamePattern(tree, entryCount)
+	if err != nil {
+		return fmt.Errorf("reading tree %s: %w", oid, err)
+	}
+	if !ok {
+		return nil
+	}
+
+	g.historyLock.Lock()
+	defer g.historyLock.Unlock()
+
+	if len(g.wideTrees) == wideTreeLimit {
+		if entryCount <= g.wideTrees[0].entryCount {
+			return nil
+		}
+		evicted := heap.Pop(&g.wideTrees).(wideTree)
+		if evicted.path != nil {
+			g.pathResolver.ForgetPath(evicted.path)
+		}
+	}
+
+	heap.Push(&g.wideTrees, wideTree{
+		oid:          oid,
+		entryCount:   entryCount,
+		pattern:      pattern,
+		patternCount: patternCount,
+		path:         g.pathResolver.RequestPath(oid, "tree"),
+	})
+	return nil
+}
+
+// findWideTrees fills in `s.WideTrees` from the trees recorded by
+// `recordWideTree()`, widest first.
+func (s *HistorySize) findWideTrees(g *Graph) {
+	g.historyLock.Lock()
+	trees := append([]wideTree(nil), g.wideTrees...)
+	g.historyLock.Unlock()
+
+	sort.Slice(trees, func(i, j int) bool {
+		if trees[i].entryCount != trees[j].entryCount {
+			return trees[i].entryCount > trees[j].entryCount
+		}
+		return trees[i].oid.String() < trees[j].oid.String()
+	})
+
+	s.WideTrees = make([]WideTree, len(trees))
+	for i, t := range trees {
+		wt := WideTree{
+			Tree:         t.path,
+			EntryCount:   t.entryCount,
+			PatternCount: t.patternCount,
+		}
+		if s.anonymizer == nil {
+			wt.Pattern = t.pattern
+		}
+		s.WideTrees[i] = wt
+	}
+}
+
+// writeWideTreeRecommendations writes advice about sharding the trees
+// in `trees` to `w`, if there are any.
+func writeWideTreeRecommendations(w io.Writer, trees []WideTree) {
+	if len(trees) == 0 {
+		return
+	}
+
+	fmt.Fprintf(
+		w, "* These directories hold many files with machine-generated names; consider sharding them into subdirectories (e.g., by the first characters of the names):\n",
+	)
+	for _, t := range trees {
+		entryCount, entryUnit := counts.Metric.Format(t.EntryCount, "")
+		name := "a tree"
+		if t.Tree != nil {
+			name = t.Tree.BestPath()
+		}
+		pattern := ""
+		if t.Pattern != "" {
+			pattern = fmt.Sprintf(" like '%s'", t.Pattern)
+		}
+		fmt.Fprintf(
+			w, "    * %s: %s%s entries, %d of them named%s\n",
+			name, entryCount, entryUnit, t.PatternCount, pattern,
+		)
+	}
+}
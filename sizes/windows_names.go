@@ -0,0 +1,164 @@
+package sizes
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/github/git-sizer/counts"
+	"github.com/github/git-sizer/git"
+)
+
+// windowsReservedNames are the device names that Windows doesn't
+// allow as file names, even with an extension, in any case.
+var windowsReservedNames = []string{
+	"CON", "PRN", "AUX", "NUL",
+	"COM1", "COM2", "COM3", "COM4", "COM5", "COM6", "COM7", "COM8", "COM9",
+	"LPT1", "LPT2", "LPT3", "LPT4", "LPT5", "LPT6", "LPT7", "LPT8", "LPT9",
+}
+
+// isWindowsUnsafeName returns true iff `name` can't be used as a file
+// name on NTFS or FAT filesystems; i.e., if it contains a reserved or
+// control character, ends with a dot or a space, or is a reserved
+// device name (possibly followed by an extension).
+func isWindowsUnsafeName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c < 0x20 || strings.IndexByte(`<>:"|?*\`, c) != -1 {
+			return true
+		}
+	}
+
+	if last := name[len(name)-1]; last == '.' || last == ' ' {
+		return true
+	}
+
+	base := name
+	if i := strings.IndexByte(base, '.'); i != -1 {
+		base = base[:i]
+	}
+	base = strings.TrimRight(base, " ")
+	if len(base) != 3 && len(base) != 4 {
+		return false
+	}
+	for _, reserved := range windowsReservedNames {
+		if strings.EqualFold(base, reserved) {
+			return true
+		}
+	}
+	return false
+}
+
+// maxWindowsUnsafeCheckoutPaths is the most paths that a checkout
+// may have for `measureWindowsUnsafeNames()` to list it. Bigger ones,
+// like those of git bombs, would take too long to list.
+const maxWindowsUnsafeCheckoutPaths = 10_000_000
+
+// measureWindowsUnsafeNames looks for names that can't be checked out
+// on Windows in the checkout of `HEAD` (if it was scanned) and in the
+// checkout with the largest total size of files, which are the ones
+// that users are most likely to check out. Only those are listed,
+// rather than every tree entry in the history being checked.
+// Checkouts with more than `maxWindowsUnsafeCheckoutPaths` paths are
+// skipped.
+func (s *HistorySize) measureWindowsUnsafeNames(
+	ctx context.Context, repo *git.Repository, g *Graph,
+) error {
+	if s.ScanIntegrity != nil {
+		// Some of the objects couldn't be read, so the checkouts
+		// might not be listable either.
+		return nil
+	}
+
+	listable := func(oid git.OID) bool {
+		size, ok := g.checkoutSize(oid)
+		return ok && !size.ExpansionLimited &&
+			size.expandedIndexEntryCount()+uint64(size.ExpandedTreeCount) <= maxWindowsUnsafeCheckoutPaths
+	}
+
+	// The checkouts to be listed, biggest first, so that it is cited
+	// if `HEAD` has the same tree:
+	var checkouts []*Path
+	tree := s.maxExpandedBlobSizeTreeOID
+	if tree == git.NullOID && s.MaxExpandedBlobSizeTree != nil {
+		tree = s.MaxExpandedBlobSizeTree.OID
+	}
+	if tree != git.NullOID && listable(tree) {
+		p := s.MaxExpandedBlobSizeTree
+		if p == nil {
+			p = &Path{OID: tree, objectType: "tree", seekerCount: 1}
+		}
+		checkouts = append(checkouts, p)
+	}
+	if head, err := repo.ResolveObjectContext(ctx, "HEAD"); err == nil && listable(head) {
+		if headTree := g.checkoutTree(head); headTree != tree {
+			checkouts = append(checkouts, &Path{
+				OID:         headTree,
+				objectType:  "tree",
+				seekerCount: 1,
+				parent: &Path{
+					OID:          head,
+					objectType:   "commit",
+					seekerCount:  1,
+					relativePath: "HEAD",
+				},
+			})
+		}
+	}
+
+	// The entries that have been counted, each identified by the
+	// tree that contains it and its name:
+	seen := make(map[AnomalyExample]struct{})
+	for _, checkout := range checkouts {
+		// The trees in the checkout, by path, so that the tree that
+		// contains each entry is known. `git ls-tree -t` lists each
+		// tree before its entries.
+		trees := map[string]git.OID{".": checkout.OID}
+		var count counts.Count32
+		if err := repo.TreePaths(
+			ctx, checkout.OID,
+			func(tp git.TreePath) error {
+				if tp.ObjectType == "tree" {
+					trees[tp.Path] = tp.OID
+				}
+				name := path.Base(tp.Path)
+				if !isWindowsUnsafeName(name) {
+					return nil
+				}
+				count.Increment(1)
+
+				e := AnomalyExample{OID: trees[path.Dir(tp.Path)], ObjectType: "tree", EntryName: name}
+				if _, ok := seen[e]; ok {
+					return nil
+				}
+				seen[e] = struct{}{}
+				s.WindowsUnsafeEntryCount.Increment(1)
+				g.historyLock.Lock()
+				g.recordAnomalyEntryExample("windowsUnsafeEntryCount", e.OID, name)
+				g.historyLock.Unlock()
+				if s.WindowsUnsafeEntry == nil {
+					s.WindowsUnsafeEntry = &Path{
+						OID:          tp.OID,
+						objectType:   string(tp.ObjectType),
+						seekerCount:  1,
+						parent:       checkout,
+						relativePath: s.anonymizer.Path(tp.Path),
+					}
+				}
+				return nil
+			},
+		); err != nil {
+			return fmt.Errorf("looking for Windows-unsafe names in %s: %w", checkout, err)
+		}
+
+		if count > 0 && s.MaxExpandedWindowsUnsafeCount.AdjustMaxIfNecessary(count) {
+			s.MaxExpandedWindowsUnsafeCountTree = checkout
+		}
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/github/git-sizer/sizes"
+)
+
+// thresholdsVersion is the version of the thresholds file format.
+const thresholdsVersion = 1
+
+// thresholdsFile is the format of a thresholds file. JSON is a subset
+// of YAML, so both are decoded as YAML.
+type thresholdsFile struct {
+	Version         int                `yaml:"version"`
+	Name            string             `yaml:"name"`
+	Profile         string             `yaml:"profile"`
+	Threshold       *float64           `yaml:"threshold"`
+	RejectThreshold *float64           `yaml:"reject_threshold"`
+	ReferenceValues map[string]float64 `yaml:"reference_values"`
+}
+
+// readThresholds reads and validates the thresholds file at `path`,
+// which can be YAML or JSON. Its statistics must all be known (see
+// `--explain-stats`), and unknown fields are errors, so that a typo
+// doesn't silently loosen the policy.
+func readThresholds(path string) (*sizes.Thresholds, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading thresholds file: %w", err)
+	}
+	t, err := parseThresholds(buf)
+	if err != nil {
+		return nil, fmt.Errorf("thresholds file %s: %w", path, err)
+	}
+	t.Path = path
+	return t, nil
+}
+
+// parseThresholds parses and validates the contents of a thresholds
+// file. See `readThresholds()`.
+func parseThresholds(buf []byte) (*sizes.Thresholds, error) {
+	dec := yaml.NewDecoder(bytes.NewReader(buf))
+	dec.KnownFields(true)
+	var f thresholdsFile
+	if err := dec.Decode(&f); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("the file is empty")
+		}
+		return nil, err
+	}
+
+	if f.Version != thresholdsVersion {
+		return nil, fmt.Errorf(
+			"unsupported version %d (expected 'version: %d')", f.Version, thresholdsVersion,
+		)
+	}
+
+	sum := sha256.Sum256(buf)
+	t := sizes.Thresholds{
+		Name:    f.Name,
+		Version: f.Version,
+		SHA256:  hex.EncodeToString(sum[:]),
+	}
+
+	if f.Profile != "" {
+		if err := t.Profile.Set(f.Profile); err != nil {
+			return nil, err
+		}
+	}
+	if f.Threshold != nil {
+		threshold := sizes.Threshold(*f.Threshold)
+		t.Threshold = &threshold
+	}
+	if f.RejectThreshold != nil {
+		threshold := sizes.Threshold(*f.RejectThreshold)
+		t.RejectThreshold = &threshold
+	}
+	if len(f.ReferenceValues) != 0 {
+		t.ReferenceValues = sizes.ReferenceValues(f.ReferenceValues)
+	}
+
+	if err := t.Validate(); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}